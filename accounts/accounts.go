@@ -216,6 +216,12 @@ const (
 
 	// WalletDropped
 	WalletDropped
+
+	// WalletUnlocked is fired when the key of a wallet is decrypted into
+	// memory, and WalletLocked once it is dropped again, on request or when
+	// a timed unlock expires.
+	WalletUnlocked
+	WalletLocked
 )
 
 // WalletEvent is an event fired by an account backend when a wallet arrival or
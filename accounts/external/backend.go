@@ -16,254 +16,304 @@
 
 package external
 
-//import (
-//	"fmt"
-//	"math/big"
-//	"sync"
-//
-//	"github.com/amazechain/amc/accounts"
-//	"github.com/amazechain/amc/common"
-//	types2 "github.com/amazechain/amc/common/types"
-//	"github.com/amazechain/amc/internal/avm/common/hexutil"
-//	"github.com/amazechain/amc/internal/avm/types"
-//	"github.com/amazechain/amc/log"
-//	event "github.com/amazechain/amc/modules/event/v2"
-//	"github.com/amazechain/amc/rpc"
-//	"github.com/amazechain/amc/signer/core/apitypes"
-//)
-//
-//type ExternalBackend struct {
-//	signers []accounts.Wallet
-//}
-//
-//func (eb *ExternalBackend) Wallets() []accounts.Wallet {
-//	return eb.signers
-//}
-//
-//func NewExternalBackend(endpoint string) (*ExternalBackend, error) {
-//	signer, err := NewExternalSigner(endpoint)
-//	if err != nil {
-//		return nil, err
-//	}
-//	return &ExternalBackend{
-//		signers: []accounts.Wallet{signer},
-//	}, nil
-//}
-//
-//func (eb *ExternalBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
-//	return event.NewSubscription(func(quit <-chan struct{}) error {
-//		<-quit
-//		return nil
-//	})
-//}
-//
-//// ExternalSigner provides an API to interact with an external signer (clef)
-//// It proxies request to the external signer while forwarding relevant
-//// request headers
-//type ExternalSigner struct {
-//	client   *rpc.Client
-//	endpoint string
-//	status   string
-//	cacheMu  sync.RWMutex
-//	cache    []accounts.Account
-//}
-//
-//func NewExternalSigner(endpoint string) (*ExternalSigner, error) {
-//	client, err := rpc.Dial(endpoint)
-//	if err != nil {
-//		return nil, err
-//	}
-//	extsigner := &ExternalSigner{
-//		client:   client,
-//		endpoint: endpoint,
-//	}
-//	// Check if reachable
-//	version, err := extsigner.pingVersion()
-//	if err != nil {
-//		return nil, err
-//	}
-//	extsigner.status = fmt.Sprintf("ok [version=%v]", version)
-//	return extsigner, nil
-//}
-//
-//func (api *ExternalSigner) URL() accounts.URL {
-//	return accounts.URL{
-//		Scheme: "extapi",
-//		Path:   api.endpoint,
-//	}
-//}
-//
-//func (api *ExternalSigner) Status() (string, error) {
-//	return api.status, nil
-//}
-//
-//func (api *ExternalSigner) Open(passphrase string) error {
-//	return fmt.Errorf("operation not supported on external signers")
-//}
-//
-//func (api *ExternalSigner) Close() error {
-//	return fmt.Errorf("operation not supported on external signers")
-//}
-//
-//func (api *ExternalSigner) Accounts() []accounts.Account {
-//	var accnts []accounts.Account
-//	res, err := api.listAccounts()
-//	if err != nil {
-//		log.Error("account listing failed", "error", err)
-//		return accnts
-//	}
-//	for _, addr := range res {
-//		accnts = append(accnts, accounts.Account{
-//			URL: accounts.URL{
-//				Scheme: "extapi",
-//				Path:   api.endpoint,
-//			},
-//			Address: addr,
-//		})
-//	}
-//	api.cacheMu.Lock()
-//	api.cache = accnts
-//	api.cacheMu.Unlock()
-//	return accnts
-//}
-//
-//func (api *ExternalSigner) Contains(account accounts.Account) bool {
-//	api.cacheMu.RLock()
-//	defer api.cacheMu.RUnlock()
-//	if api.cache == nil {
-//		// If we haven't already fetched the accounts, it's time to do so now
-//		api.cacheMu.RUnlock()
-//		api.Accounts()
-//		api.cacheMu.RLock()
-//	}
-//	for _, a := range api.cache {
-//		if a.Address == account.Address && (account.URL == (accounts.URL{}) || account.URL == api.URL()) {
-//			return true
-//		}
-//	}
-//	return false
-//}
-//
-//func (api *ExternalSigner) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
-//	return accounts.Account{}, fmt.Errorf("operation not supported on external signers")
-//}
-//
-//func (api *ExternalSigner) SelfDerive(bases []accounts.DerivationPath, chain common.ChainStateReader) {
-//	log.Error("operation SelfDerive not supported on external signers")
-//}
-//
-//// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed
-//func (api *ExternalSigner) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
-//	var res hexutil.Bytes
-//	var signAddress = common.NewMixedcaseAddress(account.Address)
-//	if err := api.client.Call(&res, "account_signData",
-//		mimeType,
-//		&signAddress, // Need to use the pointer here, because of how MarshalJSON is defined
-//		hexutil.Encode(data)); err != nil {
-//		return nil, err
-//	}
-//	// If V is on 27/28-form, convert to 0/1 for Clique
-//	if mimeType == accounts.MimetypeClique && (res[64] == 27 || res[64] == 28) {
-//		res[64] -= 27 // Transform V from 27/28 to 0/1 for Clique use
-//	}
-//	return res, nil
-//}
-//
-//func (api *ExternalSigner) SignText(account accounts.Account, text []byte) ([]byte, error) {
-//	var signature hexutil.Bytes
-//	var signAddress = common.NewMixedcaseAddress(account.Address)
-//	if err := api.client.Call(&signature, "account_signData",
-//		accounts.MimetypeTextPlain,
-//		&signAddress, // Need to use the pointer here, because of how MarshalJSON is defined
-//		hexutil.Encode(text)); err != nil {
-//		return nil, err
-//	}
-//	if signature[64] == 27 || signature[64] == 28 {
-//		// If clef is used as a backend, it may already have transformed
-//		// the signature to ethereum-type signature.
-//		signature[64] -= 27 // Transform V from Ethereum-legacy to 0/1
-//	}
-//	return signature, nil
-//}
-//
-//// signTransactionResult represents the signinig result returned by clef.
-//type signTransactionResult struct {
-//	Raw hexutil.Bytes      `json:"raw"`
-//	Tx  *types.Transaction `json:"tx"`
-//}
-//
-//// SignTx sends the transaction to the external signer.
-//// If chainID is nil, or tx.ChainID is zero, the chain ID will be assigned
-//// by the external signer. For non-legacy transactions, the chain ID of the
-//// transaction overrides the chainID parameter.
-//func (api *ExternalSigner) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
-//	data := hexutil.Bytes(tx.Data())
-//	var to *common.MixedcaseAddress
-//	if tx.To() != nil {
-//		t := common.NewMixedcaseAddress(*tx.To())
-//		to = &t
-//	}
-//	args := &apitypes.SendTxArgs{
-//		Data:  &data,
-//		Nonce: hexutil.Uint64(tx.Nonce()),
-//		Value: hexutil.Big(*tx.Value()),
-//		Gas:   hexutil.Uint64(tx.Gas()),
-//		To:    to,
-//		From:  common.NewMixedcaseAddress(account.Address),
-//	}
-//	switch tx.Type() {
-//	case types.LegacyTxType, types.AccessListTxType:
-//		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
-//	case types.DynamicFeeTxType:
-//		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
-//		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
-//	default:
-//		return nil, fmt.Errorf("unsupported tx type %d", tx.Type())
-//	}
-//	// We should request the default chain id that we're operating with
-//	// (the chain we're executing on)
-//	if chainID != nil && chainID.Sign() != 0 {
-//		args.ChainID = (*hexutil.Big)(chainID)
-//	}
-//	if tx.Type() != types.LegacyTxType {
-//		// However, if the user asked for a particular chain id, then we should
-//		// use that instead.
-//		if tx.ChainId().Sign() != 0 {
-//			args.ChainID = (*hexutil.Big)(tx.ChainId())
-//		}
-//		accessList := tx.AccessList()
-//		args.AccessList = &accessList
-//	}
-//	var res signTransactionResult
-//	if err := api.client.Call(&res, "account_signTransaction", args); err != nil {
-//		return nil, err
-//	}
-//	return res.Tx, nil
-//}
-//
-//func (api *ExternalSigner) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
-//	return []byte{}, fmt.Errorf("password-operations not supported on external signers")
-//}
-//
-//func (api *ExternalSigner) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
-//	return nil, fmt.Errorf("password-operations not supported on external signers")
-//}
-//func (api *ExternalSigner) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
-//	return nil, fmt.Errorf("password-operations not supported on external signers")
-//}
-//
-//func (api *ExternalSigner) listAccounts() ([]types2.Address, error) {
-//	var res []types2.Address
-//	if err := api.client.Call(&res, "account_list"); err != nil {
-//		return nil, err
-//	}
-//	return res, nil
-//}
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	avmtypes "github.com/amazechain/amc/internal/avm/types"
+	"github.com/amazechain/amc/log"
+	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+)
+
+var (
+	errNotSupported = errors.New("operation not supported on external signers")
+	errPassphrase   = errors.New("password-operations not supported on external signers")
+)
+
+type ExternalBackend struct {
+	signers []accounts.Wallet
+}
+
+func (eb *ExternalBackend) Wallets() []accounts.Wallet {
+	return eb.signers
+}
+
+func NewExternalBackend(endpoint string) (*ExternalBackend, error) {
+	signer, err := NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalBackend{
+		signers: []accounts.Wallet{signer},
+	}, nil
+}
+
+func (eb *ExternalBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// ExternalSigner provides an API to interact with an external signer (clef)
+// It proxies request to the external signer while forwarding relevant
+// request headers
+type ExternalSigner struct {
+	client   *jsonrpc.Client
+	endpoint string
+	status   string
+	cacheMu  sync.RWMutex
+	cache    []accounts.Account
+}
+
+func NewExternalSigner(endpoint string) (*ExternalSigner, error) {
+	client, err := jsonrpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	extsigner := &ExternalSigner{
+		client:   client,
+		endpoint: endpoint,
+	}
+	// Check if reachable
+	version, err := extsigner.pingVersion()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	extsigner.status = fmt.Sprintf("ok [version=%v]", version)
+	return extsigner, nil
+}
+
+func (api *ExternalSigner) URL() accounts.URL {
+	return accounts.URL{
+		Scheme: "extapi",
+		Path:   api.endpoint,
+	}
+}
+
+func (api *ExternalSigner) Status() (string, error) {
+	return api.status, nil
+}
+
+func (api *ExternalSigner) Open(passphrase string) error {
+	return errNotSupported
+}
+
+func (api *ExternalSigner) Close() error {
+	return errNotSupported
+}
+
+func (api *ExternalSigner) Accounts() []accounts.Account {
+	var accnts []accounts.Account
+	res, err := api.listAccounts()
+	if err != nil {
+		log.Error("account listing failed", "error", err)
+		return accnts
+	}
+	for _, addr := range res {
+		accnts = append(accnts, accounts.Account{
+			URL:     api.URL(),
+			Address: addr,
+		})
+	}
+	api.cacheMu.Lock()
+	api.cache = accnts
+	api.cacheMu.Unlock()
+	return accnts
+}
+
+func (api *ExternalSigner) Contains(account accounts.Account) bool {
+	api.cacheMu.RLock()
+	cache := api.cache
+	api.cacheMu.RUnlock()
+	if cache == nil {
+		// If we haven't already fetched the accounts, it's time to do so now
+		cache = api.Accounts()
+	}
+	for _, a := range cache {
+		if a.Address == account.Address && (account.URL == (accounts.URL{}) || account.URL == api.URL()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (api *ExternalSigner) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, errNotSupported
+}
+
+func (api *ExternalSigner) SelfDerive(bases []accounts.DerivationPath, chain common.ChainStateReader) {
+	log.Error("operation SelfDerive not supported on external signers")
+}
+
+// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed
+func (api *ExternalSigner) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	var res hexutil.Bytes
+	if err := api.client.Call(&res, "account_signData",
+		mimeType,
+		account.Address.Hex(),
+		hexutil.Encode(data)); err != nil {
+		return nil, err
+	}
+	if len(res) != 65 {
+		return nil, fmt.Errorf("external signer returned a %d bytes signature", len(res))
+	}
+	// If V is on 27/28-form, convert to 0/1 for Clique
+	if mimeType == accounts.MimetypeClique && (res[64] == 27 || res[64] == 28) {
+		res[64] -= 27 // Transform V from 27/28 to 0/1 for Clique use
+	}
+	return res, nil
+}
+
+func (api *ExternalSigner) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	var signature hexutil.Bytes
+	if err := api.client.Call(&signature, "account_signData",
+		accounts.MimetypeTextPlain,
+		account.Address.Hex(),
+		hexutil.Encode(text)); err != nil {
+		return nil, err
+	}
+	if len(signature) != 65 {
+		return nil, fmt.Errorf("external signer returned a %d bytes signature", len(signature))
+	}
+	if signature[64] == 27 || signature[64] == 28 {
+		// If clef is used as a backend, it may already have transformed
+		// the signature to ethereum-type signature.
+		signature[64] -= 27 // Transform V from Ethereum-legacy to 0/1
+	}
+	return signature, nil
+}
+
+// sendTxArgs is the transaction clef's account_signTransaction takes.
+type sendTxArgs struct {
+	From                 types.Address           `json:"from"`
+	To                   *types.Address          `json:"to"`
+	Gas                  hexutil.Uint64          `json:"gas"`
+	GasPrice             *hexutil.Big            `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big            `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big            `json:"maxPriorityFeePerGas,omitempty"`
+	Value                hexutil.Big             `json:"value"`
+	Nonce                hexutil.Uint64          `json:"nonce"`
+	Data                 *hexutil.Bytes          `json:"data"`
+	AccessList           *transaction.AccessList `json:"accessList,omitempty"`
+	ChainID              *hexutil.Big            `json:"chainId,omitempty"`
+}
+
+// signTransactionResult represents the signinig result returned by clef.
+type signTransactionResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+// SignTx sends the transaction to the external signer.
+// If chainID is nil, or tx.ChainID is zero, the chain ID will be assigned
+// by the external signer. For non-legacy transactions, the chain ID of the
+// transaction overrides the chainID parameter.
 //
-//func (api *ExternalSigner) pingVersion() (string, error) {
-//	var v string
-//	if err := api.client.Call(&v, "account_version"); err != nil {
-//		return "", err
-//	}
-//	return v, nil
-//}
+// Only the signature of the returned transaction is kept: it is attached to
+// tx and checked against the account, so a transaction the signer changed
+// before signing is refused.
+func (api *ExternalSigner) SignTx(account accounts.Account, tx *transaction.Transaction, chainID *big.Int) (*transaction.Transaction, error) {
+	data := hexutil.Bytes(tx.Data())
+	args := &sendTxArgs{
+		Data:  &data,
+		Nonce: hexutil.Uint64(tx.Nonce()),
+		Value: hexutil.Big(*tx.Value().ToBig()),
+		Gas:   hexutil.Uint64(tx.Gas()),
+		To:    tx.To(),
+		From:  account.Address,
+	}
+	switch tx.Type() {
+	case transaction.LegacyTxType, transaction.AccessListTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice().ToBig())
+	case transaction.DynamicFeeTxType:
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap().ToBig())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap().ToBig())
+	default:
+		return nil, fmt.Errorf("unsupported tx type %d", tx.Type())
+	}
+	// We should request the default chain id that we're operating with
+	// (the chain we're executing on)
+	if chainID != nil && chainID.Sign() != 0 {
+		args.ChainID = (*hexutil.Big)(chainID)
+	}
+	if tx.Type() != transaction.LegacyTxType {
+		// However, if the user asked for a particular chain id, then we should
+		// use that instead.
+		if id := tx.ChainId(); id != nil && id.Sign() != 0 {
+			args.ChainID = (*hexutil.Big)(id.ToBig())
+		}
+		accessList := tx.AccessList()
+		args.AccessList = &accessList
+	}
+	var res signTransactionResult
+	if err := api.client.Call(&res, "account_signTransaction", args); err != nil {
+		return nil, err
+	}
+	signed := new(avmtypes.Transaction)
+	if err := signed.UnmarshalBinary(res.Raw); err != nil {
+		return nil, fmt.Errorf("external signer returned an invalid transaction: %v", err)
+	}
+	v, r, s := signed.RawSignatureValues()
+	recovery := v.Uint64()
+	switch {
+	case signed.Type() != avmtypes.LegacyTxType:
+	case recovery >= 35:
+		recovery = (recovery - 35) % 2
+	default:
+		recovery -= 27
+	}
+	sig := make([]byte, 65)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	sig[64] = byte(recovery)
+
+	var signerID *big.Int
+	if args.ChainID != nil {
+		signerID = args.ChainID.ToInt()
+	}
+	signer := transaction.LatestSignerForChainID(signerID)
+	result, err := tx.WithSignature(signer, sig)
+	if err != nil {
+		return nil, err
+	}
+	if from, err := transaction.Sender(signer, result); err != nil || from != account.Address {
+		return nil, errors.New("external signer signed a different transaction")
+	}
+	return result, nil
+}
+
+func (api *ExternalSigner) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return []byte{}, errPassphrase
+}
+
+func (api *ExternalSigner) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *transaction.Transaction, chainID *big.Int) (*transaction.Transaction, error) {
+	return nil, errPassphrase
+}
+func (api *ExternalSigner) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return nil, errPassphrase
+}
+
+func (api *ExternalSigner) listAccounts() ([]types.Address, error) {
+	var res []types.Address
+	if err := api.client.Call(&res, "account_list"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (api *ExternalSigner) pingVersion() (string, error) {
+	var v string
+	if err := api.client.Call(&v, "account_version"); err != nil {
+		return "", err
+	}
+	return v, nil
+}
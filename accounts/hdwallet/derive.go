@@ -0,0 +1,135 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/common/crypto"
+)
+
+// errInvalidChild is returned for the about 1 in 2^127 indexes that give
+// no valid key, the path must then be skipped.
+var errInvalidChild = errors.New("derived key is invalid, try the next index")
+
+// hardened is the first index of the hardened children, derived from the
+// private key instead of the public one.
+const hardened = 0x80000000
+
+// ExtendedKey is a BIP-32 private key along with the chain code its children
+// are derived with.
+type ExtendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// NewMasterKey derives the root key of the wallet of a seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	k := new(big.Int).SetBytes(sum[:32])
+	if k.Sign() == 0 || k.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errInvalidChild
+	}
+	return &ExtendedKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// Child derives the child key at index.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	var data []byte
+	if index >= hardened {
+		data = append([]byte{0}, k.key...)
+	} else {
+		priv, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return nil, err
+		}
+		data = crypto.CompressPubkey(&priv.PublicKey)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return nil, errInvalidChild
+	}
+	child := tweak.Add(tweak, new(big.Int).SetBytes(k.key))
+	if child.Mod(child, n).Sign() == 0 {
+		return nil, errInvalidChild
+	}
+	return &ExtendedKey{key: child.FillBytes(make([]byte, 32)), chainCode: sum[32:]}, nil
+}
+
+// Derive derives the key at a path below k.
+func (k *ExtendedKey) Derive(path accounts.DerivationPath) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// PrivateKey returns the signing key.
+func (k *ExtendedKey) PrivateKey() (*ecdsa.PrivateKey, error) {
+	return crypto.ToECDSA(k.key)
+}
+
+// DeriveKeys derives count keys of the wallet of seed, at base and the paths
+// following it by their last index, skipping those without a valid key.
+func DeriveKeys(seed []byte, base accounts.DerivationPath, count int) ([]*ecdsa.PrivateKey, []accounts.DerivationPath, error) {
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		keys  = make([]*ecdsa.PrivateKey, 0, count)
+		paths = make([]accounts.DerivationPath, 0, count)
+		next  = accounts.DefaultIterator(base)
+	)
+	for len(keys) < count {
+		path := next()
+		key, err := master.Derive(path)
+		if errors.Is(err, errInvalidChild) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		priv, err := key.PrivateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, priv)
+		paths = append(paths, append(accounts.DerivationPath(nil), path...))
+	}
+	return keys, paths, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/common/crypto"
+)
+
+func TestMnemonicRoundTrip(t *testing.T) {
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		entropy, err := NewEntropy(bits)
+		if err != nil {
+			t.Fatalf("%d bits: %v", bits, err)
+		}
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Fatalf("%d bits: %v", bits, err)
+		}
+		decoded, err := MnemonicToEntropy(mnemonic)
+		if err != nil {
+			t.Fatalf("%d bits: %v", bits, err)
+		}
+		if !bytes.Equal(decoded, entropy) {
+			t.Errorf("%d bits: entropy mismatch: have %x, want %x", bits, decoded, entropy)
+		}
+	}
+	if mnemonic, _ := NewMnemonic(make([]byte, 16)); mnemonic != "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about" {
+		t.Errorf("zero entropy mnemonic mismatch: %s", mnemonic)
+	}
+	if _, err := MnemonicToEntropy("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"); err != ErrChecksum {
+		t.Errorf("bad checksum accepted: %v", err)
+	}
+}
+
+func TestDeriveKeys(t *testing.T) {
+	seed, err := NewSeed("test test test test test test test test test test test junk", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, paths, err := DeriveKeys(seed, accounts.DefaultBaseDerivationPath, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"}
+	for i, key := range keys {
+		if addr := crypto.PubkeyToAddress(key.PublicKey); addr.Hex() != want[i] {
+			t.Errorf("key %d: address mismatch: have %s, want %s", i, addr.Hex(), want[i])
+		}
+	}
+	if paths[1].String() != "m/44'/60'/0'/0/1" {
+		t.Errorf("path mismatch: %s", paths[1])
+	}
+}
@@ -318,6 +318,19 @@ func (ks *KeyStore) Unlock(a accounts.Account, passphrase string) error {
 	return ks.TimedUnlock(a, passphrase, 0)
 }
 
+// LockAll removes all the unlocked private keys from memory.
+func (ks *KeyStore) LockAll() {
+	ks.mu.RLock()
+	addrs := make([]types.Address, 0, len(ks.unlocked))
+	for addr := range ks.unlocked {
+		addrs = append(addrs, addr)
+	}
+	ks.mu.RUnlock()
+	for _, addr := range addrs {
+		ks.Lock(addr)
+	}
+}
+
 // Lock removes the private key with the given address from memory.
 func (ks *KeyStore) Lock(addr types.Address) error {
 	ks.mu.Lock()
@@ -344,12 +357,12 @@ func (ks *KeyStore) TimedUnlock(a accounts.Account, passphrase string, timeout t
 	}
 
 	ks.mu.Lock()
-	defer ks.mu.Unlock()
 	u, found := ks.unlocked[a.Address]
 	if found {
 		if u.abort == nil {
 			// The address was unlocked indefinitely, so unlocking
 			// it with a timeout would be confusing.
+			ks.mu.Unlock()
 			zeroKey(key.PrivateKey)
 			return nil
 		}
@@ -363,9 +376,31 @@ func (ks *KeyStore) TimedUnlock(a accounts.Account, passphrase string, timeout t
 		u = &unlocked{Key: key}
 	}
 	ks.unlocked[a.Address] = u
+	ks.mu.Unlock()
+
+	if !found {
+		ks.notifyLock(a.Address, accounts.WalletUnlocked)
+	}
 	return nil
 }
 
+// notifyLock tells the subscribers the key of an account was unlocked or
+// locked.
+func (ks *KeyStore) notifyLock(addr types.Address, kind accounts.WalletEventType) {
+	var wallet accounts.Wallet
+	ks.mu.RLock()
+	for _, w := range ks.wallets {
+		if w.Accounts()[0].Address == addr {
+			wallet = w
+			break
+		}
+	}
+	ks.mu.RUnlock()
+	if wallet != nil {
+		ks.updateFeed.Send(accounts.WalletEvent{Wallet: wallet, Kind: kind})
+	}
+}
+
 // Find resolves the given account into a unique entry in the keystore.
 func (ks *KeyStore) Find(a accounts.Account) (accounts.Account, error) {
 	ks.cache.maybeReload()
@@ -396,11 +431,15 @@ func (ks *KeyStore) expire(addr types.Address, u *unlocked, timeout time.Duratio
 		// was launched with. we can check that using pointer equality
 		// because the map stores a new pointer every time the key is
 		// unlocked.
-		if ks.unlocked[addr] == u {
+		dropped := ks.unlocked[addr] == u
+		if dropped {
 			zeroKey(u.PrivateKey)
 			delete(ks.unlocked, addr)
 		}
 		ks.mu.Unlock()
+		if dropped {
+			ks.notifyLock(addr, accounts.WalletLocked)
+		}
 	}
 }
 
@@ -68,6 +68,29 @@ const (
 	scryptDKLen = 32
 )
 
+// ScryptParams returns the scrypt N and P parameters new key files are
+// encrypted with: those of the light or the standard preset, each replaced
+// by n or p if not zero.
+func ScryptParams(light bool, n, p int) (int, int, error) {
+	scryptN, scryptP := StandardScryptN, StandardScryptP
+	if light {
+		scryptN, scryptP = LightScryptN, LightScryptP
+	}
+	if n != 0 {
+		scryptN = n
+	}
+	if p != 0 {
+		scryptP = p
+	}
+	if scryptN <= 1 || scryptN&(scryptN-1) != 0 {
+		return 0, 0, fmt.Errorf("scrypt N must be a power of 2 greater than 1, got %d", scryptN)
+	}
+	if scryptP < 1 || uint64(scryptP)*scryptR >= 1<<30 {
+		return 0, 0, fmt.Errorf("scrypt P out of range: %d", scryptP)
+	}
+	return scryptN, scryptP, nil
+}
+
 type keyStorePassphrase struct {
 	keysDirPath string
 	scryptN     int
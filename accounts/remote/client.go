@@ -0,0 +1,100 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// signTimeout bounds a single signing request, well below a block period.
+const signTimeout = 2 * time.Second
+
+// Client talks to a remote signer. Its SignData matches the signer function
+// the consensus engines expect in Authorize.
+type Client struct {
+	client *jsonrpc.Client
+}
+
+// Dial connects to the remote signer at the given HTTP endpoint. With a JWT
+// secret, every request is authenticated as on the authenticated RPC of the
+// node.
+func Dial(endpoint string, jwtSecret []byte) (*Client, error) {
+	httpClient := new(http.Client)
+	if len(jwtSecret) != 0 {
+		httpClient.Transport = &jwtTransport{secret: jwtSecret, next: http.DefaultTransport}
+	}
+	client, err := jsonrpc.DialHTTPWithClient(endpoint, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{client: client}, nil
+}
+
+// jwtTransport adds a freshly issued token to every request, the signer
+// refusing tokens older than a minute.
+type jwtTransport struct {
+	secret []byte
+	next   http.RoundTripper
+}
+
+func (t *jwtTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+	})
+	signed, err := token.SignedString(t.secret)
+	if err != nil {
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+signed)
+	return t.next.RoundTrip(r)
+}
+
+// Accounts returns the addresses the remote signer holds keys for.
+func (c *Client) Accounts() ([]types.Address, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+
+	var addrs []types.Address
+	err := c.client.CallContext(ctx, &addrs, Namespace+"_accounts")
+	return addrs, err
+}
+
+// SignData requests a signature over data from the remote signer.
+func (c *Client) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+
+	var sig hexutil.Bytes
+	if err := c.client.CallContext(ctx, &sig, Namespace+"_signData", mimeType, account.Address, hexutil.Bytes(data)); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// Close tears down the connection.
+func (c *Client) Close() {
+	c.client.Close()
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestClientJWT(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	guard, err := OpenGuard(filepath.Join(t.TempDir(), "protection.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(NewService(guard, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var claims jwt.RegisteredClaims
+		_, err := jwt.ParseWithClaims(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &claims, func(*jwt.Token) (interface{}, error) {
+			return secret, nil
+		}, jwt.WithValidMethods([]string{"HS256"}))
+		if err != nil || claims.IssuedAt == nil || time.Since(claims.IssuedAt.Time) > time.Minute {
+			http.Error(w, "invalid token", http.StatusForbidden)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	client, err := Dial(httpServer.URL, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	addrs, err := client.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("accounts %v, want %s", addrs, crypto.PubkeyToAddress(key.PublicKey))
+	}
+
+	unauthenticated, err := Dial(httpServer.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unauthenticated.Close()
+	if _, err := unauthenticated.Accounts(); err == nil {
+		t.Fatal("unauthenticated client served")
+	}
+}
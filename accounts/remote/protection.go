@@ -0,0 +1,106 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/amazechain/amc/common/types"
+)
+
+// signedSeal is the highest proposal a signer has produced so far.
+type signedSeal struct {
+	Number uint64     `json:"number"`
+	Hash   types.Hash `json:"hash"`
+}
+
+// Guard keeps the slashing protection state of the signer: the last height
+// every key sealed, persisted to disk before any signature leaves the process.
+// A node that restarts, or two nodes sharing the same signer, can therefore
+// never obtain two different signatures for the same height.
+type Guard struct {
+	path string
+
+	lock sync.Mutex
+	last map[types.Address]signedSeal
+}
+
+// OpenGuard loads the protection state stored at path, starting empty if the
+// file doesn't exist yet.
+func OpenGuard(path string) (*Guard, error) {
+	g := &Guard{path: path, last: make(map[types.Address]signedSeal)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &g.last); err != nil {
+		return nil, fmt.Errorf("corrupt slashing protection file %s: %v", path, err)
+	}
+	return g, nil
+}
+
+// Approve checks that signing hash at the given height cannot produce a
+// conflicting seal and records it. Re-signing the very same header is allowed.
+func (g *Guard) Approve(signer types.Address, number uint64, hash types.Hash) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if last, ok := g.last[signer]; ok {
+		switch {
+		case number < last.Number:
+			return fmt.Errorf("refusing to sign block %d below last signed %d", number, last.Number)
+		case number == last.Number && hash != last.Hash:
+			return fmt.Errorf("refusing to double sign block %d: already signed %s", number, last.Hash)
+		case number == last.Number:
+			return nil
+		}
+	}
+	prev, had := g.last[signer]
+	g.last[signer] = signedSeal{Number: number, Hash: hash}
+	if err := g.flush(); err != nil {
+		if had {
+			g.last[signer] = prev
+		} else {
+			delete(g.last, signer)
+		}
+		return err
+	}
+	return nil
+}
+
+// flush atomically rewrites the protection file.
+func (g *Guard) flush() error {
+	data, err := json.MarshalIndent(g.last, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(g.path), 0700); err != nil {
+		return err
+	}
+	tmp := g.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, g.path)
+}
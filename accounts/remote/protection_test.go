@@ -0,0 +1,101 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+)
+
+func TestGuardApprove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signer", "protection.json")
+	guard, err := OpenGuard(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		alice, bob = types.Address{0xa}, types.Address{0xb}
+		h1, h2     = types.Hash{1}, types.Hash{2}
+	)
+	tests := []struct {
+		signer types.Address
+		number uint64
+		hash   types.Hash
+		ok     bool
+	}{
+		{alice, 10, h1, true},
+		{alice, 10, h1, true},  // Re-signing the same header
+		{alice, 10, h2, false}, // Double signing
+		{alice, 9, h2, false},  // Below the last signed height
+		{bob, 9, h2, true},     // Heights are kept per key
+		{alice, 11, h2, true},
+		{alice, 10, h1, false},
+	}
+	for i, tt := range tests {
+		if err := guard.Approve(tt.signer, tt.number, tt.hash); (err == nil) != tt.ok {
+			t.Errorf("test %d: approving %d for %x: error %v, want ok %v", i, tt.number, tt.signer, err, tt.ok)
+		}
+	}
+
+	// The state survives restarts.
+	guard, err = OpenGuard(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Approve(alice, 11, h1); err == nil {
+		t.Error("double signing allowed after reopening")
+	}
+	if err := guard.Approve(bob, 8, h1); err == nil {
+		t.Error("signing below the last height allowed after reopening")
+	}
+	if err := guard.Approve(bob, 9, h2); err != nil {
+		t.Errorf("re-signing refused after reopening: %v", err)
+	}
+}
+
+func TestGuardFlushFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "signer")
+	guard, err := OpenGuard(filepath.Join(dir, "protection.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The protection file can't be written below a regular file.
+	if err := os.WriteFile(dir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	signer := types.Address{0xa}
+	if err := guard.Approve(signer, 10, types.Hash{1}); err == nil {
+		t.Fatal("approved a signature that wasn't persisted")
+	}
+	// Nothing was recorded, so the failed signature doesn't block another one.
+	if _, ok := guard.last[signer]; ok {
+		t.Error("unpersisted signature recorded")
+	}
+}
+
+func TestOpenGuardCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "protection.json")
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenGuard(path); err == nil {
+		t.Fatal("corrupt protection file accepted")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+)
+
+// Namespace is the RPC namespace served by the remote signer.
+const Namespace = "signer"
+
+// sealNumberIndex is the position of the block number in the RLP list the
+// consensus engines hand over for sealing.
+const sealNumberIndex = 8
+
+var errUnknownAccount = errors.New("unknown account")
+
+// Service signs block seals with the keys it holds, consulting the guard
+// before every signature.
+type Service struct {
+	keys  map[types.Address]*ecdsa.PrivateKey
+	guard *Guard
+}
+
+// NewService creates a signing service for the given keys.
+func NewService(guard *Guard, keys ...*ecdsa.PrivateKey) *Service {
+	s := &Service{keys: make(map[types.Address]*ecdsa.PrivateKey), guard: guard}
+	for _, key := range keys {
+		s.keys[crypto.PubkeyToAddress(key.PublicKey)] = key
+	}
+	return s
+}
+
+// NewServer exposes the service over JSON-RPC.
+func NewServer(service *Service) (*jsonrpc.Server, error) {
+	server := jsonrpc.NewServer()
+	if err := server.RegisterName(Namespace, service); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// Accounts lists the addresses the signer may sign for.
+func (s *Service) Accounts() []types.Address {
+	addrs := make([]types.Address, 0, len(s.keys))
+	for addr := range s.keys {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// SignData signs a header seal. Only clique-style headers are accepted, as
+// they are the only payload whose height can be checked against the guard.
+func (s *Service) SignData(mimeType string, address types.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	key, ok := s.keys[address]
+	if !ok {
+		return nil, errUnknownAccount
+	}
+	if mimeType != accounts.MimetypeClique {
+		return nil, fmt.Errorf("unsupported content type %q", mimeType)
+	}
+	number, err := sealNumber(data)
+	if err != nil {
+		return nil, err
+	}
+	hash := crypto.Keccak256(data)
+	if err := s.guard.Approve(address, number, types.BytesToHash(hash)); err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash, key)
+}
+
+// sealNumber extracts the block number from an encoded seal header.
+func sealNumber(data []byte) (uint64, error) {
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(data, &fields); err != nil {
+		return 0, fmt.Errorf("invalid header: %v", err)
+	}
+	if len(fields) <= sealNumberIndex {
+		return 0, errors.New("invalid header: missing block number")
+	}
+	number := new(big.Int)
+	if err := rlp.DecodeBytes(fields[sealNumberIndex], number); err != nil {
+		return 0, fmt.Errorf("invalid block number: %v", err)
+	}
+	if !number.IsUint64() {
+		return 0, errors.New("invalid block number: overflow")
+	}
+	return number.Uint64(), nil
+}
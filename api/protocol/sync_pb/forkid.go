@@ -0,0 +1,58 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package sync_pb
+
+import (
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// forkIDSize is the encoded size of a ForkID.
+const forkIDSize = 12
+
+// ForkID is the EIP-2124 fork identifier exchanged after the status, so that
+// peers scheduling forks differently disconnect before syncing from each
+// other. Like the state sync messages, it is encoded by hand.
+type ForkID struct {
+	Hash [4]byte
+	Next uint64
+}
+
+// MarshalSSZ ssz marshals the ForkID object
+func (f *ForkID) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(f)
+}
+
+// MarshalSSZTo ssz marshals the ForkID object to a target array
+func (f *ForkID) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = append(buf, f.Hash[:]...)
+	return ssz.MarshalUint64(dst, f.Next), nil
+}
+
+// UnmarshalSSZ ssz unmarshals the ForkID object
+func (f *ForkID) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != forkIDSize {
+		return ssz.ErrSize
+	}
+	copy(f.Hash[:], buf[0:4])
+	f.Next = ssz.UnmarshallUint64(buf[4:12])
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the ForkID object
+func (f *ForkID) SizeSSZ() int {
+	return forkIDSize
+}
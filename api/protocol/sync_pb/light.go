@@ -0,0 +1,162 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package sync_pb
+
+import (
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// The light client messages are written out by hand like the state sync ones:
+// they refer to a block by hash and carry RLP encoded answers.
+
+const (
+	// MaxLightStorageKeys bounds the storage slots an account request asks for.
+	MaxLightStorageKeys = 64
+	// MaxLightDataSize bounds the encoded answer of a light response.
+	MaxLightDataSize = 1 << 20
+
+	lightAccountRequestFixedSize = 57
+	lightResponseFixedSize       = 12
+)
+
+// LightReceiptsRequest asks for the receipts of a block.
+type LightReceiptsRequest struct {
+	BlockHash [32]byte
+}
+
+// MarshalSSZ ssz marshals the LightReceiptsRequest object
+func (l *LightReceiptsRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(l)
+}
+
+// MarshalSSZTo ssz marshals the LightReceiptsRequest object to a target array
+func (l *LightReceiptsRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	return append(buf, l.BlockHash[:]...), nil
+}
+
+// UnmarshalSSZ ssz unmarshals the LightReceiptsRequest object
+func (l *LightReceiptsRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != 32 {
+		return ssz.ErrSize
+	}
+	copy(l.BlockHash[:], buf)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the LightReceiptsRequest object
+func (l *LightReceiptsRequest) SizeSSZ() int {
+	return 32
+}
+
+// LightAccountRequest asks for an account and some of its storage slots in
+// the state of a block, along with its code if Code is set. Keys holds the
+// 32 byte slot keys back to back.
+type LightAccountRequest struct {
+	BlockHash [32]byte
+	Address   [20]byte
+	Code      bool
+	Keys      []byte
+}
+
+// MarshalSSZ ssz marshals the LightAccountRequest object
+func (l *LightAccountRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(l)
+}
+
+// MarshalSSZTo ssz marshals the LightAccountRequest object to a target array
+func (l *LightAccountRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	if len(l.Keys) > MaxLightStorageKeys*32 || len(l.Keys)%32 != 0 {
+		return nil, ssz.ErrBytesLength
+	}
+	dst = buf
+	dst = append(dst, l.BlockHash[:]...)
+	dst = append(dst, l.Address[:]...)
+	dst = ssz.MarshalBool(dst, l.Code)
+	dst = ssz.WriteOffset(dst, lightAccountRequestFixedSize)
+	dst = append(dst, l.Keys...)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the LightAccountRequest object
+func (l *LightAccountRequest) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < lightAccountRequestFixedSize {
+		return ssz.ErrSize
+	}
+	if o := ssz.ReadOffset(buf[53:57]); o != lightAccountRequestFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if keys := size - lightAccountRequestFixedSize; keys > MaxLightStorageKeys*32 || keys%32 != 0 {
+		return ssz.ErrBytesLength
+	}
+	copy(l.BlockHash[:], buf[0:32])
+	copy(l.Address[:], buf[32:52])
+	l.Code = ssz.UnmarshalBool(buf[52:53])
+	l.Keys = append([]byte{}, buf[lightAccountRequestFixedSize:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the LightAccountRequest object
+func (l *LightAccountRequest) SizeSSZ() int {
+	return lightAccountRequestFixedSize + len(l.Keys)
+}
+
+// LightResponse carries the answer to a light request along with the flow
+// control buffer the client has left at the server after it.
+type LightResponse struct {
+	Buffer uint64
+	Data   []byte
+}
+
+// MarshalSSZ ssz marshals the LightResponse object
+func (l *LightResponse) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(l)
+}
+
+// MarshalSSZTo ssz marshals the LightResponse object to a target array
+func (l *LightResponse) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	if len(l.Data) > MaxLightDataSize {
+		return nil, ssz.ErrBytesLength
+	}
+	dst = buf
+	dst = ssz.MarshalUint64(dst, l.Buffer)
+	dst = ssz.WriteOffset(dst, lightResponseFixedSize)
+	dst = append(dst, l.Data...)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the LightResponse object
+func (l *LightResponse) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < lightResponseFixedSize {
+		return ssz.ErrSize
+	}
+	if o := ssz.ReadOffset(buf[8:12]); o != lightResponseFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if size-lightResponseFixedSize > MaxLightDataSize {
+		return ssz.ErrBytesLength
+	}
+	l.Buffer = ssz.UnmarshallUint64(buf[0:8])
+	l.Data = append([]byte{}, buf[lightResponseFixedSize:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the LightResponse object
+func (l *LightResponse) SizeSSZ() int {
+	return lightResponseFixedSize + len(l.Data)
+}
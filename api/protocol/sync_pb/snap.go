@@ -0,0 +1,176 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package sync_pb
+
+import (
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// The snap requests ask for the flat state by account, storage and code like
+// the GetAccountRange, GetStorageRanges and GetByteCodes messages of the snap
+// protocol. They are answered with a StateResponse. There is no state trie,
+// so the answers carry no proofs, and there is no trie node request: entries
+// downloaded over several heads are healed with state changes requests.
+
+const (
+	// MaxStorageRangeAccounts bounds the accounts of a storage ranges request.
+	MaxStorageRangeAccounts = 128
+	// MaxByteCodesHashes bounds the code hashes of a byte codes request.
+	MaxByteCodesHashes = 1024
+	// StorageOriginSize is the size of the incarnation and slot a storage
+	// range resumes from.
+	StorageOriginSize = 34
+
+	accountRangeRequestFixedSize  = 48
+	storageRangesRequestFixedSize = 46
+	byteCodesRequestFixedSize     = 12
+)
+
+// AccountRangeRequest asks for the accounts from Origin up to Limit, both
+// included. A zero Limit stands for the last account. Bytes is the soft size
+// limit of the answer, the server's own is used if it is zero or larger.
+type AccountRangeRequest struct {
+	Origin [20]byte
+	Limit  [20]byte
+	Bytes  uint64
+}
+
+// MarshalSSZ ssz marshals the AccountRangeRequest object
+func (a *AccountRangeRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(a)
+}
+
+// MarshalSSZTo ssz marshals the AccountRangeRequest object to a target array
+func (a *AccountRangeRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+	dst = append(dst, a.Origin[:]...)
+	dst = append(dst, a.Limit[:]...)
+	dst = ssz.MarshalUint64(dst, a.Bytes)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the AccountRangeRequest object
+func (a *AccountRangeRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != accountRangeRequestFixedSize {
+		return ssz.ErrSize
+	}
+	copy(a.Origin[:], buf[0:20])
+	copy(a.Limit[:], buf[20:40])
+	a.Bytes = ssz.UnmarshallUint64(buf[40:48])
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the AccountRangeRequest object
+func (a *AccountRangeRequest) SizeSSZ() int {
+	return accountRangeRequestFixedSize
+}
+
+// StorageRangesRequest asks for the storage of the accounts held back to back
+// in Accounts. The storage of the first account starts at the incarnation and
+// slot in Origin, which is zero unless an earlier answer was cut short.
+type StorageRangesRequest struct {
+	Origin   [StorageOriginSize]byte
+	Bytes    uint64
+	Accounts []byte
+}
+
+// MarshalSSZ ssz marshals the StorageRangesRequest object
+func (s *StorageRangesRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(s)
+}
+
+// MarshalSSZTo ssz marshals the StorageRangesRequest object to a target array
+func (s *StorageRangesRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	if len(s.Accounts) > MaxStorageRangeAccounts*20 || len(s.Accounts)%20 != 0 {
+		return nil, ssz.ErrBytesLength
+	}
+	dst = buf
+	dst = append(dst, s.Origin[:]...)
+	dst = ssz.MarshalUint64(dst, s.Bytes)
+	dst = ssz.WriteOffset(dst, storageRangesRequestFixedSize)
+	dst = append(dst, s.Accounts...)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the StorageRangesRequest object
+func (s *StorageRangesRequest) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < storageRangesRequestFixedSize {
+		return ssz.ErrSize
+	}
+	if o := ssz.ReadOffset(buf[42:46]); o != storageRangesRequestFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if accounts := size - storageRangesRequestFixedSize; accounts > MaxStorageRangeAccounts*20 || accounts%20 != 0 {
+		return ssz.ErrBytesLength
+	}
+	copy(s.Origin[:], buf[0:34])
+	s.Bytes = ssz.UnmarshallUint64(buf[34:42])
+	s.Accounts = append([]byte{}, buf[storageRangesRequestFixedSize:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the StorageRangesRequest object
+func (s *StorageRangesRequest) SizeSSZ() int {
+	return storageRangesRequestFixedSize + len(s.Accounts)
+}
+
+// ByteCodesRequest asks for the contract codes of the 32 byte code hashes held
+// back to back in Hashes.
+type ByteCodesRequest struct {
+	Bytes  uint64
+	Hashes []byte
+}
+
+// MarshalSSZ ssz marshals the ByteCodesRequest object
+func (b *ByteCodesRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(b)
+}
+
+// MarshalSSZTo ssz marshals the ByteCodesRequest object to a target array
+func (b *ByteCodesRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	if len(b.Hashes) > MaxByteCodesHashes*32 || len(b.Hashes)%32 != 0 {
+		return nil, ssz.ErrBytesLength
+	}
+	dst = buf
+	dst = ssz.MarshalUint64(dst, b.Bytes)
+	dst = ssz.WriteOffset(dst, byteCodesRequestFixedSize)
+	dst = append(dst, b.Hashes...)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the ByteCodesRequest object
+func (b *ByteCodesRequest) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < byteCodesRequestFixedSize {
+		return ssz.ErrSize
+	}
+	if o := ssz.ReadOffset(buf[8:12]); o != byteCodesRequestFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if hashes := size - byteCodesRequestFixedSize; hashes > MaxByteCodesHashes*32 || hashes%32 != 0 {
+		return ssz.ErrBytesLength
+	}
+	b.Bytes = ssz.UnmarshallUint64(buf[0:8])
+	b.Hashes = append([]byte{}, buf[byteCodesRequestFixedSize:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the ByteCodesRequest object
+func (b *ByteCodesRequest) SizeSSZ() int {
+	return byteCodesRequestFixedSize + len(b.Hashes)
+}
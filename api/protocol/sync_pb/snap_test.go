@@ -0,0 +1,82 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package sync_pb
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSnapRequestsSSZ(t *testing.T) {
+	account := &AccountRangeRequest{Origin: [20]byte{1}, Limit: [20]byte{2}, Bytes: 1024}
+	storage := &StorageRangesRequest{Origin: [StorageOriginSize]byte{0, 1, 3}, Bytes: 2048, Accounts: bytes.Repeat([]byte{0xaa}, 2*20)}
+	codes := &ByteCodesRequest{Bytes: 4096, Hashes: bytes.Repeat([]byte{0xcc}, 2*32)}
+
+	for _, test := range []struct {
+		msg, dec interface {
+			MarshalSSZ() ([]byte, error)
+			UnmarshalSSZ([]byte) error
+			SizeSSZ() int
+		}
+	}{
+		{account, new(AccountRangeRequest)},
+		{storage, new(StorageRangesRequest)},
+		{codes, new(ByteCodesRequest)},
+	} {
+		enc, err := test.msg.MarshalSSZ()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enc) != test.msg.SizeSSZ() {
+			t.Fatalf("%T encoded in %d bytes, size %d", test.msg, len(enc), test.msg.SizeSSZ())
+		}
+		if err := test.dec.UnmarshalSSZ(enc); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.dec, test.msg) {
+			t.Fatalf("decoded %+v, want %+v", test.dec, test.msg)
+		}
+		if err := test.dec.UnmarshalSSZ(enc[:len(enc)-1]); err == nil {
+			t.Errorf("truncated %T decoded", test.msg)
+		}
+	}
+
+	// Too many or partial accounts and hashes are refused.
+	if _, err := (&StorageRangesRequest{Accounts: make([]byte, 19)}).MarshalSSZ(); err == nil {
+		t.Error("partial account encoded")
+	}
+	if _, err := (&StorageRangesRequest{Accounts: make([]byte, (MaxStorageRangeAccounts+1)*20)}).MarshalSSZ(); err == nil {
+		t.Error("too many accounts encoded")
+	}
+	if _, err := (&ByteCodesRequest{Hashes: make([]byte, (MaxByteCodesHashes+1)*32)}).MarshalSSZ(); err == nil {
+		t.Error("too many hashes encoded")
+	}
+	enc, err := codes.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc[8] = 13
+	if err := new(ByteCodesRequest).UnmarshalSSZ(enc); err == nil {
+		t.Error("byte codes request with a wrong offset decoded")
+	}
+	oversized := append(make([]byte, storageRangesRequestFixedSize), make([]byte, (MaxStorageRangeAccounts+1)*20)...)
+	oversized[42] = storageRangesRequestFixedSize
+	if err := new(StorageRangesRequest).UnmarshalSSZ(oversized); err == nil {
+		t.Error("storage ranges request with too many accounts decoded")
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package sync_pb
+
+import (
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// The state sync messages below are not generated from sync_pb.proto: they
+// carry opaque database ranges and only need the SSZ codec of the req/resp
+// protocol, which is written out by hand.
+
+const (
+	// MaxStateOriginSize bounds the database key a range request starts at.
+	MaxStateOriginSize = 128
+	// MaxStateEntriesSize bounds the encoded entries of a state response.
+	MaxStateEntriesSize = 1 << 20
+
+	stateRangeRequestFixedSize = 20
+	stateResponseFixedSize     = 53
+)
+
+// StateRangeRequest asks for up to Count entries of a state table, starting
+// at the first key greater or equal to Origin.
+type StateRangeRequest struct {
+	Table  uint64
+	Count  uint64
+	Origin []byte
+}
+
+// MarshalSSZ ssz marshals the StateRangeRequest object
+func (s *StateRangeRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(s)
+}
+
+// MarshalSSZTo ssz marshals the StateRangeRequest object to a target array
+func (s *StateRangeRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	if len(s.Origin) > MaxStateOriginSize {
+		return nil, ssz.ErrBytesLength
+	}
+	dst = buf
+	dst = ssz.MarshalUint64(dst, s.Table)
+	dst = ssz.MarshalUint64(dst, s.Count)
+	dst = ssz.WriteOffset(dst, stateRangeRequestFixedSize)
+	dst = append(dst, s.Origin...)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the StateRangeRequest object
+func (s *StateRangeRequest) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < stateRangeRequestFixedSize {
+		return ssz.ErrSize
+	}
+	if o := ssz.ReadOffset(buf[16:20]); o != stateRangeRequestFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if size-stateRangeRequestFixedSize > MaxStateOriginSize {
+		return ssz.ErrBytesLength
+	}
+	s.Table = ssz.UnmarshallUint64(buf[0:8])
+	s.Count = ssz.UnmarshallUint64(buf[8:16])
+	s.Origin = append([]byte{}, buf[stateRangeRequestFixedSize:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the StateRangeRequest object
+func (s *StateRangeRequest) SizeSSZ() int {
+	return stateRangeRequestFixedSize + len(s.Origin)
+}
+
+// StateChangesRequest asks for the current value of every state entry that
+// changed after block From.
+type StateChangesRequest struct {
+	From uint64
+}
+
+// MarshalSSZ ssz marshals the StateChangesRequest object
+func (s *StateChangesRequest) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(s)
+}
+
+// MarshalSSZTo ssz marshals the StateChangesRequest object to a target array
+func (s *StateChangesRequest) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	return ssz.MarshalUint64(buf, s.From), nil
+}
+
+// UnmarshalSSZ ssz unmarshals the StateChangesRequest object
+func (s *StateChangesRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != 8 {
+		return ssz.ErrSize
+	}
+	s.From = ssz.UnmarshallUint64(buf)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the StateChangesRequest object
+func (s *StateChangesRequest) SizeSSZ() int {
+	return 8
+}
+
+// StateResponse carries state entries read at the serving peer's head block.
+// Covered is the last block whose changes are included in a changes response,
+// or the number of accounts a storage ranges response holds the whole storage
+// of; Complete reports that a range reached the end of its table or limit, or
+// that a changes response covers everything up to Head.
+type StateResponse struct {
+	Head     uint64
+	HeadHash [32]byte
+	Covered  uint64
+	Complete bool
+	Entries  []byte
+}
+
+// MarshalSSZ ssz marshals the StateResponse object
+func (s *StateResponse) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(s)
+}
+
+// MarshalSSZTo ssz marshals the StateResponse object to a target array
+func (s *StateResponse) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	if len(s.Entries) > MaxStateEntriesSize {
+		return nil, ssz.ErrBytesLength
+	}
+	dst = buf
+	dst = ssz.MarshalUint64(dst, s.Head)
+	dst = append(dst, s.HeadHash[:]...)
+	dst = ssz.MarshalUint64(dst, s.Covered)
+	dst = ssz.MarshalBool(dst, s.Complete)
+	dst = ssz.WriteOffset(dst, stateResponseFixedSize)
+	dst = append(dst, s.Entries...)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the StateResponse object
+func (s *StateResponse) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < stateResponseFixedSize {
+		return ssz.ErrSize
+	}
+	if o := ssz.ReadOffset(buf[49:53]); o != stateResponseFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if size-stateResponseFixedSize > MaxStateEntriesSize {
+		return ssz.ErrBytesLength
+	}
+	s.Head = ssz.UnmarshallUint64(buf[0:8])
+	copy(s.HeadHash[:], buf[8:40])
+	s.Covered = ssz.UnmarshallUint64(buf[40:48])
+	s.Complete = ssz.UnmarshalBool(buf[48:49])
+	s.Entries = append([]byte{}, buf[stateResponseFixedSize:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the StateResponse object
+func (s *StateResponse) SizeSSZ() int {
+	return stateResponseFixedSize + len(s.Entries)
+}
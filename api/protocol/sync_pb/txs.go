@@ -0,0 +1,121 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package sync_pb
+
+import (
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// The transaction propagation messages. Most peers are only told the hashes of
+// new transactions and fetch the bodies they are missing, a few get the bodies
+// pushed right away.
+
+const (
+	// MaxTxAnnounceHashes bounds the hashes of a single announcement.
+	MaxTxAnnounceHashes = 4096
+	// MaxPooledTxsHashes bounds the transactions asked for in one request.
+	MaxPooledTxsHashes = 256
+	// MaxTxsDataSize bounds the encoded transactions of a single message.
+	MaxTxsDataSize = 512 * 1024
+
+	txHashesFixedSize = 4
+	txsFixedSize      = 4
+)
+
+// TxHashes carries 32 byte transaction hashes back to back, either announcing
+// transactions or asking for their bodies.
+type TxHashes struct {
+	Hashes []byte
+}
+
+// MarshalSSZ ssz marshals the TxHashes object
+func (t *TxHashes) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(t)
+}
+
+// MarshalSSZTo ssz marshals the TxHashes object to a target array
+func (t *TxHashes) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	if len(t.Hashes) > MaxTxAnnounceHashes*32 || len(t.Hashes)%32 != 0 {
+		return nil, ssz.ErrBytesLength
+	}
+	dst = ssz.WriteOffset(buf, txHashesFixedSize)
+	dst = append(dst, t.Hashes...)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the TxHashes object
+func (t *TxHashes) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < txHashesFixedSize {
+		return ssz.ErrSize
+	}
+	if o := ssz.ReadOffset(buf[0:4]); o != txHashesFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if hashes := size - txHashesFixedSize; hashes > MaxTxAnnounceHashes*32 || hashes%32 != 0 {
+		return ssz.ErrBytesLength
+	}
+	t.Hashes = append([]byte{}, buf[txHashesFixedSize:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the TxHashes object
+func (t *TxHashes) SizeSSZ() int {
+	return txHashesFixedSize + len(t.Hashes)
+}
+
+// Txs carries RLP encoded transaction bodies, pushed to a peer or answering
+// a request for them.
+type Txs struct {
+	Data []byte
+}
+
+// MarshalSSZ ssz marshals the Txs object
+func (t *Txs) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(t)
+}
+
+// MarshalSSZTo ssz marshals the Txs object to a target array
+func (t *Txs) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	if len(t.Data) > MaxTxsDataSize {
+		return nil, ssz.ErrBytesLength
+	}
+	dst = ssz.WriteOffset(buf, txsFixedSize)
+	dst = append(dst, t.Data...)
+	return dst, nil
+}
+
+// UnmarshalSSZ ssz unmarshals the Txs object
+func (t *Txs) UnmarshalSSZ(buf []byte) error {
+	size := uint64(len(buf))
+	if size < txsFixedSize {
+		return ssz.ErrSize
+	}
+	if o := ssz.ReadOffset(buf[0:4]); o != txsFixedSize {
+		return ssz.ErrInvalidVariableOffset
+	}
+	if size-txsFixedSize > MaxTxsDataSize {
+		return ssz.ErrBytesLength
+	}
+	t.Data = append([]byte{}, buf[txsFixedSize:]...)
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the Txs object
+func (t *Txs) SizeSSZ() int {
+	return txsFixedSize + len(t.Data)
+}
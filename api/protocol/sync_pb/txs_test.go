@@ -0,0 +1,86 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package sync_pb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTxHashesSSZ(t *testing.T) {
+	msg := &TxHashes{Hashes: bytes.Repeat([]byte{0xab}, 3*32)}
+	enc, err := msg.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enc) != msg.SizeSSZ() {
+		t.Fatalf("encoded %d bytes, size %d", len(enc), msg.SizeSSZ())
+	}
+	dec := new(TxHashes)
+	if err := dec.UnmarshalSSZ(enc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec.Hashes, msg.Hashes) {
+		t.Fatalf("decoded hashes %x, want %x", dec.Hashes, msg.Hashes)
+	}
+
+	// Partial hashes, too many hashes and wrong offsets are refused.
+	if _, err := (&TxHashes{Hashes: make([]byte, 31)}).MarshalSSZ(); err == nil {
+		t.Error("partial hash encoded")
+	}
+	if _, err := (&TxHashes{Hashes: make([]byte, (MaxTxAnnounceHashes+1)*32)}).MarshalSSZ(); err == nil {
+		t.Error("too many hashes encoded")
+	}
+	for name, buf := range map[string][]byte{
+		"short":        enc[:3],
+		"partial hash": enc[:len(enc)-1],
+		"offset":       append([]byte{5, 0, 0, 0}, enc[4:]...),
+		"oversized":    append(enc[:4:4], make([]byte, (MaxTxAnnounceHashes+1)*32)...),
+	} {
+		if err := new(TxHashes).UnmarshalSSZ(buf); err == nil {
+			t.Errorf("%s message decoded", name)
+		}
+	}
+}
+
+func TestTxsSSZ(t *testing.T) {
+	msg := &Txs{Data: []byte{0xc2, 0x01, 0x02}}
+	enc, err := msg.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(Txs)
+	if err := dec.UnmarshalSSZ(enc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec.Data, msg.Data) {
+		t.Fatalf("decoded data %x, want %x", dec.Data, msg.Data)
+	}
+
+	if _, err := (&Txs{Data: make([]byte, MaxTxsDataSize+1)}).MarshalSSZ(); err == nil {
+		t.Error("oversized transactions encoded")
+	}
+	for name, buf := range map[string][]byte{
+		"short":     enc[:3],
+		"offset":    append([]byte{8, 0, 0, 0}, enc[4:]...),
+		"oversized": append(enc[:4:4], make([]byte, MaxTxsDataSize+1)...),
+	} {
+		if err := new(Txs).UnmarshalSSZ(buf); err == nil {
+			t.Errorf("%s message decoded", name)
+		}
+	}
+}
@@ -1,5 +1,5 @@
 // Code generated by fastssz. DO NOT EDIT.
-// Hash: ff4c0802b1b743077781dae4de752322633988f240bb8e40a9f01dbceed1d869
+// Hash: 8247e69b27e45a426ad2ba1db8374a8e5c9a612ddf7dcb7984b2e61fdd134a84
 package types_pb
 
 import (
@@ -806,132 +806,132 @@ func (b *Block) HashTreeRootWith(hh *ssz.Hasher) (err error) {
 	return
 }
 
-// MarshalSSZ ssz marshals the Header object
-func (h *Header) MarshalSSZ() ([]byte, error) {
-	return ssz.MarshalSSZ(h)
+// MarshalSSZ ssz marshals the legacyHeader object
+func (l *legacyHeader) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(l)
 }
 
-// MarshalSSZTo ssz marshals the Header object to a target array
-func (h *Header) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+// MarshalSSZTo ssz marshals the legacyHeader object to a target array
+func (l *legacyHeader) MarshalSSZTo(buf []byte) (dst []byte, err error) {
 	dst = buf
 	offset := int(664)
 
 	// Field (0) 'ParentHash'
-	if h.ParentHash == nil {
-		h.ParentHash = new(H256)
+	if l.ParentHash == nil {
+		l.ParentHash = new(H256)
 	}
-	if dst, err = h.ParentHash.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.ParentHash.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (1) 'Coinbase'
-	if h.Coinbase == nil {
-		h.Coinbase = new(H160)
+	if l.Coinbase == nil {
+		l.Coinbase = new(H160)
 	}
-	if dst, err = h.Coinbase.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.Coinbase.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (2) 'Root'
-	if h.Root == nil {
-		h.Root = new(H256)
+	if l.Root == nil {
+		l.Root = new(H256)
 	}
-	if dst, err = h.Root.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.Root.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (3) 'TxHash'
-	if h.TxHash == nil {
-		h.TxHash = new(H256)
+	if l.TxHash == nil {
+		l.TxHash = new(H256)
 	}
-	if dst, err = h.TxHash.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.TxHash.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (4) 'ReceiptHash'
-	if h.ReceiptHash == nil {
-		h.ReceiptHash = new(H256)
+	if l.ReceiptHash == nil {
+		l.ReceiptHash = new(H256)
 	}
-	if dst, err = h.ReceiptHash.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.ReceiptHash.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (5) 'Difficulty'
-	if h.Difficulty == nil {
-		h.Difficulty = new(H256)
+	if l.Difficulty == nil {
+		l.Difficulty = new(H256)
 	}
-	if dst, err = h.Difficulty.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.Difficulty.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (6) 'Number'
-	if h.Number == nil {
-		h.Number = new(H256)
+	if l.Number == nil {
+		l.Number = new(H256)
 	}
-	if dst, err = h.Number.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.Number.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (7) 'GasLimit'
-	dst = ssz.MarshalUint64(dst, h.GasLimit)
+	dst = ssz.MarshalUint64(dst, l.GasLimit)
 
 	// Field (8) 'GasUsed'
-	dst = ssz.MarshalUint64(dst, h.GasUsed)
+	dst = ssz.MarshalUint64(dst, l.GasUsed)
 
 	// Field (9) 'Time'
-	dst = ssz.MarshalUint64(dst, h.Time)
+	dst = ssz.MarshalUint64(dst, l.Time)
 
 	// Field (10) 'Nonce'
-	dst = ssz.MarshalUint64(dst, h.Nonce)
+	dst = ssz.MarshalUint64(dst, l.Nonce)
 
 	// Field (11) 'BaseFee'
-	if h.BaseFee == nil {
-		h.BaseFee = new(H256)
+	if l.BaseFee == nil {
+		l.BaseFee = new(H256)
 	}
-	if dst, err = h.BaseFee.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.BaseFee.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Offset (12) 'Extra'
 	dst = ssz.WriteOffset(dst, offset)
-	offset += len(h.Extra)
+	offset += len(l.Extra)
 
 	// Field (13) 'Signature'
-	if h.Signature == nil {
-		h.Signature = new(H768)
+	if l.Signature == nil {
+		l.Signature = new(H768)
 	}
-	if dst, err = h.Signature.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.Signature.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (14) 'Bloom'
-	if h.Bloom == nil {
-		h.Bloom = new(H2048)
+	if l.Bloom == nil {
+		l.Bloom = new(H2048)
 	}
-	if dst, err = h.Bloom.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.Bloom.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (15) 'MixDigest'
-	if h.MixDigest == nil {
-		h.MixDigest = new(H256)
+	if l.MixDigest == nil {
+		l.MixDigest = new(H256)
 	}
-	if dst, err = h.MixDigest.MarshalSSZTo(dst); err != nil {
+	if dst, err = l.MixDigest.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
 	// Field (12) 'Extra'
-	if size := len(h.Extra); size > 117 {
+	if size := len(l.Extra); size > 117 {
 		err = ssz.ErrBytesLengthFn("--.Extra", size, 117)
 		return
 	}
-	dst = append(dst, h.Extra...)
+	dst = append(dst, l.Extra...)
 
 	return
 }
 
-// UnmarshalSSZ ssz unmarshals the Header object
-func (h *Header) UnmarshalSSZ(buf []byte) error {
+// UnmarshalSSZ ssz unmarshals the legacyHeader object
+func (l *legacyHeader) UnmarshalSSZ(buf []byte) error {
 	var err error
 	size := uint64(len(buf))
 	if size < 664 {
@@ -942,78 +942,78 @@ func (h *Header) UnmarshalSSZ(buf []byte) error {
 	var o12 uint64
 
 	// Field (0) 'ParentHash'
-	if h.ParentHash == nil {
-		h.ParentHash = new(H256)
+	if l.ParentHash == nil {
+		l.ParentHash = new(H256)
 	}
-	if err = h.ParentHash.UnmarshalSSZ(buf[0:32]); err != nil {
+	if err = l.ParentHash.UnmarshalSSZ(buf[0:32]); err != nil {
 		return err
 	}
 
 	// Field (1) 'Coinbase'
-	if h.Coinbase == nil {
-		h.Coinbase = new(H160)
+	if l.Coinbase == nil {
+		l.Coinbase = new(H160)
 	}
-	if err = h.Coinbase.UnmarshalSSZ(buf[32:52]); err != nil {
+	if err = l.Coinbase.UnmarshalSSZ(buf[32:52]); err != nil {
 		return err
 	}
 
 	// Field (2) 'Root'
-	if h.Root == nil {
-		h.Root = new(H256)
+	if l.Root == nil {
+		l.Root = new(H256)
 	}
-	if err = h.Root.UnmarshalSSZ(buf[52:84]); err != nil {
+	if err = l.Root.UnmarshalSSZ(buf[52:84]); err != nil {
 		return err
 	}
 
 	// Field (3) 'TxHash'
-	if h.TxHash == nil {
-		h.TxHash = new(H256)
+	if l.TxHash == nil {
+		l.TxHash = new(H256)
 	}
-	if err = h.TxHash.UnmarshalSSZ(buf[84:116]); err != nil {
+	if err = l.TxHash.UnmarshalSSZ(buf[84:116]); err != nil {
 		return err
 	}
 
 	// Field (4) 'ReceiptHash'
-	if h.ReceiptHash == nil {
-		h.ReceiptHash = new(H256)
+	if l.ReceiptHash == nil {
+		l.ReceiptHash = new(H256)
 	}
-	if err = h.ReceiptHash.UnmarshalSSZ(buf[116:148]); err != nil {
+	if err = l.ReceiptHash.UnmarshalSSZ(buf[116:148]); err != nil {
 		return err
 	}
 
 	// Field (5) 'Difficulty'
-	if h.Difficulty == nil {
-		h.Difficulty = new(H256)
+	if l.Difficulty == nil {
+		l.Difficulty = new(H256)
 	}
-	if err = h.Difficulty.UnmarshalSSZ(buf[148:180]); err != nil {
+	if err = l.Difficulty.UnmarshalSSZ(buf[148:180]); err != nil {
 		return err
 	}
 
 	// Field (6) 'Number'
-	if h.Number == nil {
-		h.Number = new(H256)
+	if l.Number == nil {
+		l.Number = new(H256)
 	}
-	if err = h.Number.UnmarshalSSZ(buf[180:212]); err != nil {
+	if err = l.Number.UnmarshalSSZ(buf[180:212]); err != nil {
 		return err
 	}
 
 	// Field (7) 'GasLimit'
-	h.GasLimit = ssz.UnmarshallUint64(buf[212:220])
+	l.GasLimit = ssz.UnmarshallUint64(buf[212:220])
 
 	// Field (8) 'GasUsed'
-	h.GasUsed = ssz.UnmarshallUint64(buf[220:228])
+	l.GasUsed = ssz.UnmarshallUint64(buf[220:228])
 
 	// Field (9) 'Time'
-	h.Time = ssz.UnmarshallUint64(buf[228:236])
+	l.Time = ssz.UnmarshallUint64(buf[228:236])
 
 	// Field (10) 'Nonce'
-	h.Nonce = ssz.UnmarshallUint64(buf[236:244])
+	l.Nonce = ssz.UnmarshallUint64(buf[236:244])
 
 	// Field (11) 'BaseFee'
-	if h.BaseFee == nil {
-		h.BaseFee = new(H256)
+	if l.BaseFee == nil {
+		l.BaseFee = new(H256)
 	}
-	if err = h.BaseFee.UnmarshalSSZ(buf[244:276]); err != nil {
+	if err = l.BaseFee.UnmarshalSSZ(buf[244:276]); err != nil {
 		return err
 	}
 
@@ -1027,26 +1027,26 @@ func (h *Header) UnmarshalSSZ(buf []byte) error {
 	}
 
 	// Field (13) 'Signature'
-	if h.Signature == nil {
-		h.Signature = new(H768)
+	if l.Signature == nil {
+		l.Signature = new(H768)
 	}
-	if err = h.Signature.UnmarshalSSZ(buf[280:376]); err != nil {
+	if err = l.Signature.UnmarshalSSZ(buf[280:376]); err != nil {
 		return err
 	}
 
 	// Field (14) 'Bloom'
-	if h.Bloom == nil {
-		h.Bloom = new(H2048)
+	if l.Bloom == nil {
+		l.Bloom = new(H2048)
 	}
-	if err = h.Bloom.UnmarshalSSZ(buf[376:632]); err != nil {
+	if err = l.Bloom.UnmarshalSSZ(buf[376:632]); err != nil {
 		return err
 	}
 
 	// Field (15) 'MixDigest'
-	if h.MixDigest == nil {
-		h.MixDigest = new(H256)
+	if l.MixDigest == nil {
+		l.MixDigest = new(H256)
 	}
-	if err = h.MixDigest.UnmarshalSSZ(buf[632:664]); err != nil {
+	if err = l.MixDigest.UnmarshalSSZ(buf[632:664]); err != nil {
 		return err
 	}
 
@@ -1056,94 +1056,94 @@ func (h *Header) UnmarshalSSZ(buf []byte) error {
 		if len(buf) > 117 {
 			return ssz.ErrBytesLength
 		}
-		if cap(h.Extra) == 0 {
-			h.Extra = make([]byte, 0, len(buf))
+		if cap(l.Extra) == 0 {
+			l.Extra = make([]byte, 0, len(buf))
 		}
-		h.Extra = append(h.Extra, buf...)
+		l.Extra = append(l.Extra, buf...)
 	}
 	return err
 }
 
-// SizeSSZ returns the ssz encoded size in bytes for the Header object
-func (h *Header) SizeSSZ() (size int) {
+// SizeSSZ returns the ssz encoded size in bytes for the legacyHeader object
+func (l *legacyHeader) SizeSSZ() (size int) {
 	size = 664
 
 	// Field (12) 'Extra'
-	size += len(h.Extra)
+	size += len(l.Extra)
 
 	return
 }
 
-// HashTreeRoot ssz hashes the Header object
-func (h *Header) HashTreeRoot() ([32]byte, error) {
-	return ssz.HashWithDefaultHasher(h)
+// HashTreeRoot ssz hashes the legacyHeader object
+func (l *legacyHeader) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(l)
 }
 
-// HashTreeRootWith ssz hashes the Header object with a hasher
-func (h *Header) HashTreeRootWith(hh *ssz.Hasher) (err error) {
+// HashTreeRootWith ssz hashes the legacyHeader object with a hasher
+func (l *legacyHeader) HashTreeRootWith(hh *ssz.Hasher) (err error) {
 	indx := hh.Index()
 
 	// Field (0) 'ParentHash'
-	if err = h.ParentHash.HashTreeRootWith(hh); err != nil {
+	if err = l.ParentHash.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (1) 'Coinbase'
-	if err = h.Coinbase.HashTreeRootWith(hh); err != nil {
+	if err = l.Coinbase.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (2) 'Root'
-	if err = h.Root.HashTreeRootWith(hh); err != nil {
+	if err = l.Root.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (3) 'TxHash'
-	if err = h.TxHash.HashTreeRootWith(hh); err != nil {
+	if err = l.TxHash.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (4) 'ReceiptHash'
-	if err = h.ReceiptHash.HashTreeRootWith(hh); err != nil {
+	if err = l.ReceiptHash.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (5) 'Difficulty'
-	if err = h.Difficulty.HashTreeRootWith(hh); err != nil {
+	if err = l.Difficulty.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (6) 'Number'
-	if err = h.Number.HashTreeRootWith(hh); err != nil {
+	if err = l.Number.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (7) 'GasLimit'
-	hh.PutUint64(h.GasLimit)
+	hh.PutUint64(l.GasLimit)
 
 	// Field (8) 'GasUsed'
-	hh.PutUint64(h.GasUsed)
+	hh.PutUint64(l.GasUsed)
 
 	// Field (9) 'Time'
-	hh.PutUint64(h.Time)
+	hh.PutUint64(l.Time)
 
 	// Field (10) 'Nonce'
-	hh.PutUint64(h.Nonce)
+	hh.PutUint64(l.Nonce)
 
 	// Field (11) 'BaseFee'
-	if err = h.BaseFee.HashTreeRootWith(hh); err != nil {
+	if err = l.BaseFee.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (12) 'Extra'
 	{
 		elemIndx := hh.Index()
-		byteLen := uint64(len(h.Extra))
+		byteLen := uint64(len(l.Extra))
 		if byteLen > 117 {
 			err = ssz.ErrIncorrectListSize
 			return
 		}
-		hh.PutBytes(h.Extra)
+		hh.PutBytes(l.Extra)
 		if ssz.EnableVectorizedHTR {
 			hh.MerkleizeWithMixinVectorizedHTR(elemIndx, byteLen, (117+31)/32)
 		} else {
@@ -1152,20 +1152,435 @@ func (h *Header) HashTreeRootWith(hh *ssz.Hasher) (err error) {
 	}
 
 	// Field (13) 'Signature'
-	if err = h.Signature.HashTreeRootWith(hh); err != nil {
+	if err = l.Signature.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (14) 'Bloom'
-	if err = h.Bloom.HashTreeRootWith(hh); err != nil {
+	if err = l.Bloom.HashTreeRootWith(hh); err != nil {
 		return
 	}
 
 	// Field (15) 'MixDigest'
-	if err = h.MixDigest.HashTreeRootWith(hh); err != nil {
+	if err = l.MixDigest.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	if ssz.EnableVectorizedHTR {
+		hh.MerkleizeVectorizedHTR(indx)
+	} else {
+		hh.Merkleize(indx)
+	}
+	return
+}
+
+// MarshalSSZ ssz marshals the extendedHeader object
+func (e *extendedHeader) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(e)
+}
+
+// MarshalSSZTo ssz marshals the extendedHeader object to a target array
+func (e *extendedHeader) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+	offset := int(668)
+
+	// Field (0) 'ParentHash'
+	if e.ParentHash == nil {
+		e.ParentHash = new(H256)
+	}
+	if dst, err = e.ParentHash.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (1) 'Coinbase'
+	if e.Coinbase == nil {
+		e.Coinbase = new(H160)
+	}
+	if dst, err = e.Coinbase.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (2) 'Root'
+	if e.Root == nil {
+		e.Root = new(H256)
+	}
+	if dst, err = e.Root.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (3) 'TxHash'
+	if e.TxHash == nil {
+		e.TxHash = new(H256)
+	}
+	if dst, err = e.TxHash.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (4) 'ReceiptHash'
+	if e.ReceiptHash == nil {
+		e.ReceiptHash = new(H256)
+	}
+	if dst, err = e.ReceiptHash.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (5) 'Difficulty'
+	if e.Difficulty == nil {
+		e.Difficulty = new(H256)
+	}
+	if dst, err = e.Difficulty.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (6) 'Number'
+	if e.Number == nil {
+		e.Number = new(H256)
+	}
+	if dst, err = e.Number.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (7) 'GasLimit'
+	dst = ssz.MarshalUint64(dst, e.GasLimit)
+
+	// Field (8) 'GasUsed'
+	dst = ssz.MarshalUint64(dst, e.GasUsed)
+
+	// Field (9) 'Time'
+	dst = ssz.MarshalUint64(dst, e.Time)
+
+	// Field (10) 'Nonce'
+	dst = ssz.MarshalUint64(dst, e.Nonce)
+
+	// Field (11) 'BaseFee'
+	if e.BaseFee == nil {
+		e.BaseFee = new(H256)
+	}
+	if dst, err = e.BaseFee.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Offset (12) 'Extra'
+	dst = ssz.WriteOffset(dst, offset)
+	offset += len(e.Extra)
+
+	// Field (13) 'Signature'
+	if e.Signature == nil {
+		e.Signature = new(H768)
+	}
+	if dst, err = e.Signature.MarshalSSZTo(dst); err != nil {
 		return
 	}
 
+	// Field (14) 'Bloom'
+	if e.Bloom == nil {
+		e.Bloom = new(H2048)
+	}
+	if dst, err = e.Bloom.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (15) 'MixDigest'
+	if e.MixDigest == nil {
+		e.MixDigest = new(H256)
+	}
+	if dst, err = e.MixDigest.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Offset (16) 'Extension'
+	dst = ssz.WriteOffset(dst, offset)
+	offset += len(e.Extension)
+
+	// Field (12) 'Extra'
+	if size := len(e.Extra); size > 117 {
+		err = ssz.ErrBytesLengthFn("--.Extra", size, 117)
+		return
+	}
+	dst = append(dst, e.Extra...)
+
+	// Field (16) 'Extension'
+	if size := len(e.Extension); size > 1024 {
+		err = ssz.ErrBytesLengthFn("--.Extension", size, 1024)
+		return
+	}
+	dst = append(dst, e.Extension...)
+
+	return
+}
+
+// UnmarshalSSZ ssz unmarshals the extendedHeader object
+func (e *extendedHeader) UnmarshalSSZ(buf []byte) error {
+	var err error
+	size := uint64(len(buf))
+	if size < 668 {
+		return ssz.ErrSize
+	}
+
+	tail := buf
+	var o12, o16 uint64
+
+	// Field (0) 'ParentHash'
+	if e.ParentHash == nil {
+		e.ParentHash = new(H256)
+	}
+	if err = e.ParentHash.UnmarshalSSZ(buf[0:32]); err != nil {
+		return err
+	}
+
+	// Field (1) 'Coinbase'
+	if e.Coinbase == nil {
+		e.Coinbase = new(H160)
+	}
+	if err = e.Coinbase.UnmarshalSSZ(buf[32:52]); err != nil {
+		return err
+	}
+
+	// Field (2) 'Root'
+	if e.Root == nil {
+		e.Root = new(H256)
+	}
+	if err = e.Root.UnmarshalSSZ(buf[52:84]); err != nil {
+		return err
+	}
+
+	// Field (3) 'TxHash'
+	if e.TxHash == nil {
+		e.TxHash = new(H256)
+	}
+	if err = e.TxHash.UnmarshalSSZ(buf[84:116]); err != nil {
+		return err
+	}
+
+	// Field (4) 'ReceiptHash'
+	if e.ReceiptHash == nil {
+		e.ReceiptHash = new(H256)
+	}
+	if err = e.ReceiptHash.UnmarshalSSZ(buf[116:148]); err != nil {
+		return err
+	}
+
+	// Field (5) 'Difficulty'
+	if e.Difficulty == nil {
+		e.Difficulty = new(H256)
+	}
+	if err = e.Difficulty.UnmarshalSSZ(buf[148:180]); err != nil {
+		return err
+	}
+
+	// Field (6) 'Number'
+	if e.Number == nil {
+		e.Number = new(H256)
+	}
+	if err = e.Number.UnmarshalSSZ(buf[180:212]); err != nil {
+		return err
+	}
+
+	// Field (7) 'GasLimit'
+	e.GasLimit = ssz.UnmarshallUint64(buf[212:220])
+
+	// Field (8) 'GasUsed'
+	e.GasUsed = ssz.UnmarshallUint64(buf[220:228])
+
+	// Field (9) 'Time'
+	e.Time = ssz.UnmarshallUint64(buf[228:236])
+
+	// Field (10) 'Nonce'
+	e.Nonce = ssz.UnmarshallUint64(buf[236:244])
+
+	// Field (11) 'BaseFee'
+	if e.BaseFee == nil {
+		e.BaseFee = new(H256)
+	}
+	if err = e.BaseFee.UnmarshalSSZ(buf[244:276]); err != nil {
+		return err
+	}
+
+	// Offset (12) 'Extra'
+	if o12 = ssz.ReadOffset(buf[276:280]); o12 > size {
+		return ssz.ErrOffset
+	}
+
+	if o12 < 668 {
+		return ssz.ErrInvalidVariableOffset
+	}
+
+	// Field (13) 'Signature'
+	if e.Signature == nil {
+		e.Signature = new(H768)
+	}
+	if err = e.Signature.UnmarshalSSZ(buf[280:376]); err != nil {
+		return err
+	}
+
+	// Field (14) 'Bloom'
+	if e.Bloom == nil {
+		e.Bloom = new(H2048)
+	}
+	if err = e.Bloom.UnmarshalSSZ(buf[376:632]); err != nil {
+		return err
+	}
+
+	// Field (15) 'MixDigest'
+	if e.MixDigest == nil {
+		e.MixDigest = new(H256)
+	}
+	if err = e.MixDigest.UnmarshalSSZ(buf[632:664]); err != nil {
+		return err
+	}
+
+	// Offset (16) 'Extension'
+	if o16 = ssz.ReadOffset(buf[664:668]); o16 > size || o12 > o16 {
+		return ssz.ErrOffset
+	}
+
+	// Field (12) 'Extra'
+	{
+		buf = tail[o12:o16]
+		if len(buf) > 117 {
+			return ssz.ErrBytesLength
+		}
+		if cap(e.Extra) == 0 {
+			e.Extra = make([]byte, 0, len(buf))
+		}
+		e.Extra = append(e.Extra, buf...)
+	}
+
+	// Field (16) 'Extension'
+	{
+		buf = tail[o16:]
+		if len(buf) > 1024 {
+			return ssz.ErrBytesLength
+		}
+		if cap(e.Extension) == 0 {
+			e.Extension = make([]byte, 0, len(buf))
+		}
+		e.Extension = append(e.Extension, buf...)
+	}
+	return err
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the extendedHeader object
+func (e *extendedHeader) SizeSSZ() (size int) {
+	size = 668
+
+	// Field (12) 'Extra'
+	size += len(e.Extra)
+
+	// Field (16) 'Extension'
+	size += len(e.Extension)
+
+	return
+}
+
+// HashTreeRoot ssz hashes the extendedHeader object
+func (e *extendedHeader) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(e)
+}
+
+// HashTreeRootWith ssz hashes the extendedHeader object with a hasher
+func (e *extendedHeader) HashTreeRootWith(hh *ssz.Hasher) (err error) {
+	indx := hh.Index()
+
+	// Field (0) 'ParentHash'
+	if err = e.ParentHash.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (1) 'Coinbase'
+	if err = e.Coinbase.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (2) 'Root'
+	if err = e.Root.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (3) 'TxHash'
+	if err = e.TxHash.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (4) 'ReceiptHash'
+	if err = e.ReceiptHash.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (5) 'Difficulty'
+	if err = e.Difficulty.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (6) 'Number'
+	if err = e.Number.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (7) 'GasLimit'
+	hh.PutUint64(e.GasLimit)
+
+	// Field (8) 'GasUsed'
+	hh.PutUint64(e.GasUsed)
+
+	// Field (9) 'Time'
+	hh.PutUint64(e.Time)
+
+	// Field (10) 'Nonce'
+	hh.PutUint64(e.Nonce)
+
+	// Field (11) 'BaseFee'
+	if err = e.BaseFee.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (12) 'Extra'
+	{
+		elemIndx := hh.Index()
+		byteLen := uint64(len(e.Extra))
+		if byteLen > 117 {
+			err = ssz.ErrIncorrectListSize
+			return
+		}
+		hh.PutBytes(e.Extra)
+		if ssz.EnableVectorizedHTR {
+			hh.MerkleizeWithMixinVectorizedHTR(elemIndx, byteLen, (117+31)/32)
+		} else {
+			hh.MerkleizeWithMixin(elemIndx, byteLen, (117+31)/32)
+		}
+	}
+
+	// Field (13) 'Signature'
+	if err = e.Signature.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (14) 'Bloom'
+	if err = e.Bloom.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (15) 'MixDigest'
+	if err = e.MixDigest.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (16) 'Extension'
+	{
+		elemIndx := hh.Index()
+		byteLen := uint64(len(e.Extension))
+		if byteLen > 1024 {
+			err = ssz.ErrIncorrectListSize
+			return
+		}
+		hh.PutBytes(e.Extension)
+		if ssz.EnableVectorizedHTR {
+			hh.MerkleizeWithMixinVectorizedHTR(elemIndx, byteLen, (1024+31)/32)
+		} else {
+			hh.MerkleizeWithMixin(elemIndx, byteLen, (1024+31)/32)
+		}
+	}
+
 	if ssz.EnableVectorizedHTR {
 		hh.MerkleizeVectorizedHTR(indx)
 	} else {
@@ -0,0 +1,150 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types_pb
+
+import (
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// The SSZ encoding of a Header has two layouts. Headers without extension
+// keep the legacy layout of the nodes before the header extension, 664 bytes
+// of fixed fields and no Extension offset, so those nodes still decode them;
+// headers with an extension append its offset and the field. The layouts are
+// generated for legacyHeader and extendedHeader, Header picks one.
+
+// legacyHeaderExtraOffset is where the offset of Extra sits in both layouts.
+const legacyHeaderExtraOffset = 276
+
+// legacyHeaderFixedSize is the size of the fixed part of the legacy layout,
+// the offset of Extra in it.
+const legacyHeaderFixedSize = 664
+
+// legacyHeader is the layout of headers without extension.
+type legacyHeader struct {
+	ParentHash  *H256
+	Coinbase    *H160
+	Root        *H256
+	TxHash      *H256
+	ReceiptHash *H256
+	Difficulty  *H256
+	Number      *H256
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        uint64
+	Nonce       uint64
+	BaseFee     *H256
+	Extra       []byte `ssz-max:"117"`
+	Signature   *H768
+	Bloom       *H2048
+	MixDigest   *H256
+}
+
+// extendedHeader is the layout of headers with an extension.
+type extendedHeader struct {
+	ParentHash  *H256
+	Coinbase    *H160
+	Root        *H256
+	TxHash      *H256
+	ReceiptHash *H256
+	Difficulty  *H256
+	Number      *H256
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        uint64
+	Nonce       uint64
+	BaseFee     *H256
+	Extra       []byte `ssz-max:"117"`
+	Signature   *H768
+	Bloom       *H2048
+	MixDigest   *H256
+	Extension   []byte `ssz-max:"1024"`
+}
+
+// layout returns the header in the layout it is encoded with.
+func (h *Header) layout() ssz.HashRoot {
+	if len(h.Extension) == 0 {
+		return &legacyHeader{
+			ParentHash: h.ParentHash, Coinbase: h.Coinbase, Root: h.Root, TxHash: h.TxHash,
+			ReceiptHash: h.ReceiptHash, Difficulty: h.Difficulty, Number: h.Number,
+			GasLimit: h.GasLimit, GasUsed: h.GasUsed, Time: h.Time, Nonce: h.Nonce,
+			BaseFee: h.BaseFee, Extra: h.Extra, Signature: h.Signature, Bloom: h.Bloom,
+			MixDigest: h.MixDigest,
+		}
+	}
+	return &extendedHeader{
+		ParentHash: h.ParentHash, Coinbase: h.Coinbase, Root: h.Root, TxHash: h.TxHash,
+		ReceiptHash: h.ReceiptHash, Difficulty: h.Difficulty, Number: h.Number,
+		GasLimit: h.GasLimit, GasUsed: h.GasUsed, Time: h.Time, Nonce: h.Nonce,
+		BaseFee: h.BaseFee, Extra: h.Extra, Signature: h.Signature, Bloom: h.Bloom,
+		MixDigest: h.MixDigest, Extension: h.Extension,
+	}
+}
+
+// MarshalSSZ ssz marshals the Header object
+func (h *Header) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(h)
+}
+
+// MarshalSSZTo ssz marshals the Header object to a target array
+func (h *Header) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	return h.layout().(ssz.Marshaler).MarshalSSZTo(buf)
+}
+
+// UnmarshalSSZ ssz unmarshals the Header object. Extra starting right after
+// the legacy fixed fields tells the legacy layout apart.
+func (h *Header) UnmarshalSSZ(buf []byte) error {
+	if len(buf) < legacyHeaderFixedSize {
+		return ssz.ErrSize
+	}
+	if ssz.ReadOffset(buf[legacyHeaderExtraOffset:legacyHeaderExtraOffset+4]) == legacyHeaderFixedSize {
+		var l legacyHeader
+		if err := l.UnmarshalSSZ(buf); err != nil {
+			return err
+		}
+		h.ParentHash, h.Coinbase, h.Root, h.TxHash = l.ParentHash, l.Coinbase, l.Root, l.TxHash
+		h.ReceiptHash, h.Difficulty, h.Number = l.ReceiptHash, l.Difficulty, l.Number
+		h.GasLimit, h.GasUsed, h.Time, h.Nonce = l.GasLimit, l.GasUsed, l.Time, l.Nonce
+		h.BaseFee, h.Extra, h.Signature, h.Bloom = l.BaseFee, l.Extra, l.Signature, l.Bloom
+		h.MixDigest, h.Extension = l.MixDigest, nil
+		return nil
+	}
+	var e extendedHeader
+	if err := e.UnmarshalSSZ(buf); err != nil {
+		return err
+	}
+	h.ParentHash, h.Coinbase, h.Root, h.TxHash = e.ParentHash, e.Coinbase, e.Root, e.TxHash
+	h.ReceiptHash, h.Difficulty, h.Number = e.ReceiptHash, e.Difficulty, e.Number
+	h.GasLimit, h.GasUsed, h.Time, h.Nonce = e.GasLimit, e.GasUsed, e.Time, e.Nonce
+	h.BaseFee, h.Extra, h.Signature, h.Bloom = e.BaseFee, e.Extra, e.Signature, e.Bloom
+	h.MixDigest, h.Extension = e.MixDigest, e.Extension
+	return nil
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the Header object
+func (h *Header) SizeSSZ() (size int) {
+	return h.layout().(ssz.Marshaler).SizeSSZ()
+}
+
+// HashTreeRoot ssz hashes the Header object
+func (h *Header) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(h)
+}
+
+// HashTreeRootWith ssz hashes the Header object with a hasher
+func (h *Header) HashTreeRootWith(hh *ssz.Hasher) (err error) {
+	return h.layout().HashTreeRootWith(hh)
+}
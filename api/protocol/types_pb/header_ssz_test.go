@@ -0,0 +1,128 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types_pb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// legacyHeaderSSZ returns a header in the 664 byte layout of the nodes before
+// the header extension.
+func legacyHeaderSSZ(extra []byte) []byte {
+	buf := make([]byte, 664, 664+len(extra))
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+	binary.LittleEndian.PutUint32(buf[276:280], 664)
+	return append(buf, extra...)
+}
+
+func TestHeaderLegacySSZ(t *testing.T) {
+	enc := legacyHeaderSSZ([]byte("extra"))
+	var h Header
+	if err := h.UnmarshalSSZ(enc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(h.Extra, []byte("extra")) || len(h.Extension) != 0 {
+		t.Fatalf("decoded extra %q, extension %x", h.Extra, h.Extension)
+	}
+	if size := h.SizeSSZ(); size != len(enc) {
+		t.Fatalf("size = %d, want %d", size, len(enc))
+	}
+	got, err := h.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, enc) {
+		t.Fatalf("header without extension not encoded in the legacy layout:\n got %x\nwant %x", got, enc)
+	}
+}
+
+func TestHeaderExtensionSSZ(t *testing.T) {
+	var h Header
+	if err := h.UnmarshalSSZ(legacyHeaderSSZ([]byte("extra"))); err != nil {
+		t.Fatal(err)
+	}
+	legacyRoot, err := h.HashTreeRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Extension = []byte{0x01, 0x02, 0x03}
+	enc, err := h.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enc) != 668+5+3 || len(enc) != h.SizeSSZ() {
+		t.Fatalf("encoded %d bytes, size %d", len(enc), h.SizeSSZ())
+	}
+	if offset := binary.LittleEndian.Uint32(enc[276:280]); offset != 668 {
+		t.Fatalf("extra offset = %d, want 668", offset)
+	}
+	root, err := h.HashTreeRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root == legacyRoot {
+		t.Fatal("extension not in the hash tree root")
+	}
+
+	var dec Header
+	if err := dec.UnmarshalSSZ(enc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec.Extra, h.Extra) || !bytes.Equal(dec.Extension, h.Extension) {
+		t.Fatalf("decoded extra %q, extension %x", dec.Extra, dec.Extension)
+	}
+}
+
+func TestHeaderSSZMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  func() []byte
+		err  error
+	}{
+		{"short", func() []byte { return legacyHeaderSSZ(nil)[:663] }, ssz.ErrSize},
+		{"extra offset between layouts", func() []byte {
+			enc := legacyHeaderSSZ(make([]byte, 8))
+			binary.LittleEndian.PutUint32(enc[276:280], 666)
+			return enc
+		}, ssz.ErrInvalidVariableOffset},
+		{"extension offset before extra", func() []byte {
+			enc := legacyHeaderSSZ(make([]byte, 8))
+			binary.LittleEndian.PutUint32(enc[276:280], 670)
+			binary.LittleEndian.PutUint32(enc[664:668], 669)
+			return enc
+		}, ssz.ErrOffset},
+		{"extension offset past the end", func() []byte {
+			enc := legacyHeaderSSZ(make([]byte, 8))
+			binary.LittleEndian.PutUint32(enc[276:280], 668)
+			binary.LittleEndian.PutUint32(enc[664:668], 673)
+			return enc
+		}, ssz.ErrOffset},
+	}
+	for _, tt := range tests {
+		var h Header
+		if err := h.UnmarshalSSZ(tt.enc()); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
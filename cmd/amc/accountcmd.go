@@ -17,21 +17,111 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/amazechain/amc/log"
 	"github.com/urfave/cli/v2"
 	"os"
+	"time"
 
 	"github.com/amazechain/amc/cmd/utils"
 
 	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/accounts/hdwallet"
 	"github.com/amazechain/amc/accounts/keystore"
 	"github.com/amazechain/amc/common/crypto"
 	"github.com/amazechain/amc/conf"
-	"github.com/amazechain/amc/internal/node"
+	"github.com/amazechain/amc/console/prompt"
 )
 
 var (
+	hdPathFlag = &cli.StringFlag{
+		Name:  "hd.path",
+		Usage: "Derivation path of the first account, the next ones increment its last index",
+		Value: accounts.DefaultBaseDerivationPath.String(),
+	}
+	hdCountFlag = &cli.IntFlag{
+		Name:  "hd.count",
+		Usage: "Number of accounts to derive",
+		Value: 1,
+	}
+	hdWordsFlag = &cli.IntFlag{
+		Name:  "hd.words",
+		Usage: "Number of words of the new mnemonic (12, 15, 18, 21 or 24)",
+		Value: 12,
+	}
+	hdMnemonicFileFlag = &cli.PathFlag{
+		Name:      "hd.mnemonic",
+		Usage:     "File holding the mnemonic to import, prompted for otherwise",
+		TakesFile: true,
+	}
+	hdPassphraseFlag = &cli.BoolFlag{
+		Name:  "hd.passphrase",
+		Usage: "Prompt for the optional BIP-39 passphrase protecting the mnemonic",
+	}
+
+	hdCommand = &cli.Command{
+		Name:  "hd",
+		Usage: "Manage BIP-39 mnemonic wallets",
+		Description: `
+Derives accounts from a BIP-39 mnemonic along BIP-32 paths and imports their
+keys into the keystore, where they are unlocked, used for signing and listed
+like any other account.
+
+The mnemonic itself isn't stored, only the derived keys. More accounts of the
+same wallet can be added later by importing the mnemonic again with a higher
+--hd.count; the accounts already in the keystore are skipped.`,
+		Subcommands: []*cli.Command{
+			{
+				Name:   "new",
+				Usage:  "Create a new mnemonic and derive accounts from it",
+				Action: hdCreate,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					KeyStoreDirFlag,
+					PasswordFileFlag,
+					LightKDFFlag,
+					ScryptNFlag,
+					ScryptPFlag,
+					hdWordsFlag,
+					hdPathFlag,
+					hdCountFlag,
+					hdPassphraseFlag,
+				},
+				Description: `
+    AmazeChain account hd new [--hd.words 24] [--hd.count <n>]
+
+Generates a mnemonic, prints it, and imports the first accounts of its wallet.
+Write the mnemonic down: it is the only backup of the wallet and it is never
+shown again.
+`,
+			},
+			{
+				Name:   "import",
+				Usage:  "Derive accounts from an existing mnemonic",
+				Action: hdImport,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					KeyStoreDirFlag,
+					PasswordFileFlag,
+					LightKDFFlag,
+					ScryptNFlag,
+					ScryptPFlag,
+					hdMnemonicFileFlag,
+					hdPathFlag,
+					hdCountFlag,
+					hdPassphraseFlag,
+				},
+				Description: `
+    AmazeChain account hd import [--hd.mnemonic <file>] [--hd.path <path>] [--hd.count <n>]
+
+Imports the accounts of the wallet of a mnemonic, from the path given by
+--hd.path on, m/44'/60'/0'/0/0 by default.
+`,
+			},
+		},
+	}
+
 	walletCommand = &cli.Command{
 		Name:      "wallet",
 		Usage:     "Manage AmazeChain presale wallets",
@@ -54,6 +144,8 @@ passwordfile as argument containing the wallet password in plaintext.`,
 					KeyStoreDirFlag,
 					PasswordFileFlag,
 					LightKDFFlag,
+					ScryptNFlag,
+					ScryptPFlag,
 				},
 				Description: `
 	AmazeChain wallet [options] /path/to/my/presale.wallet
@@ -70,8 +162,9 @@ passwordfile as argument containing the wallet password in plaintext.`,
 		Usage: "Manage accounts",
 		Description: `
 
-Manage accounts, list all existing accounts, import a private key into a new
-account, create a new account or update an existing account.
+Manage accounts, list all existing accounts, import a private key or a key file
+into a new account, create a new account, export an account to a key file or
+update an existing account.
 
 It supports interactive mode, when you are prompted for password as well as
 non-interactive mode where passwords are supplied via a given password file.
@@ -83,12 +176,16 @@ either new or import). Without it you are not able to unlock your account.
 
 Note that exporting your key in unencrypted format is NOT supported.
 
+The commands only work on the key files, they don't need the node to be
+stopped. A running node locks all its unlocked accounts on SIGUSR1.
+
 Keys are stored under <DATADIR>/keystore.
 It is safe to transfer the entire directory or the individual keys therein
 between ethereum nodes by simply copying.
 
 Make sure you backup your keys regularly.`,
 		Subcommands: []*cli.Command{
+			hdCommand,
 			{
 				Name:   "list",
 				Usage:  "Print summary of existing accounts",
@@ -109,6 +206,8 @@ Make sure you backup your keys regularly.`,
 					KeyStoreDirFlag,
 					PasswordFileFlag,
 					LightKDFFlag,
+					ScryptNFlag,
+					ScryptPFlag,
 				},
 				Description: `
     AmazeChain account new
@@ -133,7 +232,10 @@ password to file or expose in any other way.
 				Flags: []cli.Flag{
 					DataDirFlag,
 					KeyStoreDirFlag,
+					PasswordFileFlag,
 					LightKDFFlag,
+					ScryptNFlag,
+					ScryptPFlag,
 				},
 				Description: `
     AmazeChain account update <address>
@@ -146,12 +248,12 @@ for a password to unlock the account and another to save the updated file.
 This same command can therefore be used to migrate an account of a deprecated
 format to the newest format or change the password for an account.
 
-For non-interactive use the password can be specified with the --password flag:
+For non-interactive use the passwords can be given with the --account.password
+flag, the current one on the first line and the new one on the second:
 
-    AmazeChain account update [options] <address>
+    AmazeChain account update --account.password <file> <address>
 
-Since only one password can be given, only format update can be performed,
-changing your password is only possible interactively.
+With a single line, only the format is updated and the password kept.
 `,
 			},
 			{
@@ -163,21 +265,27 @@ changing your password is only possible interactively.
 					KeyStoreDirFlag,
 					PasswordFileFlag,
 					LightKDFFlag,
+					ScryptNFlag,
+					ScryptPFlag,
 				},
 				ArgsUsage: "<keyFile>",
 				Description: `
     AmazeChain account import <keyfile>
 
-Imports an unencrypted private key from <keyfile> and creates a new account.
+Imports a private key from <keyfile> and creates a new account.
 Prints the address.
 
-The keyfile is assumed to contain an unencrypted private key in hexadecimal format.
+The keyfile either contains an unencrypted private key in hexadecimal format,
+or is an encrypted key file, such as one written by account export. The
+password of an encrypted key file is asked for first, the one of the new
+account after it; with --account.password they are taken from its first and
+second lines.
 
 The account is saved in encrypted format, you are prompted for a password.
 
 You must remember this password to unlock your account in the future.
 
-For non-interactive use the password can be specified with the -password flag:
+For non-interactive use the password can be specified with the --account.password flag:
 
     AmazeChain account import [options] <keyfile>
 
@@ -185,12 +293,64 @@ Note:
 As you can directly copy your encrypted accounts to another ethereum instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export an account into an encrypted key file",
+				Action:    accountExport,
+				ArgsUsage: "<address> [<keyFile>]",
+				Flags: []cli.Flag{
+					DataDirFlag,
+					KeyStoreDirFlag,
+					PasswordFileFlag,
+					LightKDFFlag,
+					ScryptNFlag,
+					ScryptPFlag,
+				},
+				Description: `
+    AmazeChain account export <address> [<keyfile>]
+
+Writes the key of an account to <keyfile>, or to the standard output, in the
+encrypted key file format. You are prompted for the password of the account,
+the file is encrypted with the same one.
+
+The file can be imported into another keystore with account import, or copied
+into its directory.
 `,
 			},
 		},
 	}
 )
 
+// openKeyStore opens the keystore selected by the data dir and keystore flags,
+// creating its directory if needed. The node isn't started, so the accounts
+// can be managed while it is running.
+func openKeyStore() *keystore.KeyStore {
+	cfg := DefaultConfig
+	// Load config file.
+	if len(cfgFile) > 0 {
+		if err := conf.LoadConfigFromFile(cfgFile, &cfg); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
+	keydir, err := cfg.NodeCfg.KeyDirConfig()
+	if err != nil {
+		utils.Fatalf("Failed to read configuration: %v", err)
+	}
+	if keydir == "" {
+		utils.Fatalf("No keystore directory, set --%s or --%s", DataDirFlag.Name, KeyStoreDirFlag.Name)
+	}
+	if err := os.MkdirAll(keydir, 0700); err != nil {
+		utils.Fatalf("Failed to create the keystore directory: %v", err)
+	}
+	scryptN, scryptP, err := keystore.ScryptParams(cfg.NodeCfg.UseLightweightKDF, cfg.NodeCfg.ScryptN, cfg.NodeCfg.ScryptP)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	return keystore.NewKeyStore(keydir, scryptN, scryptP)
+}
+
 func importWallet(ctx *cli.Context) error {
 	if ctx.Args().Len() != 1 {
 		utils.Fatalf("keyfile must be given as the only argument")
@@ -201,14 +361,9 @@ func importWallet(ctx *cli.Context) error {
 		utils.Fatalf("Could not read wallet file: %v", err)
 	}
 
-	stack, err := node.NewNode(ctx, &DefaultConfig)
-	if err != nil {
-		return err
-	}
-
 	passphrase := utils.GetPassPhraseWithList("", false, 0, MakePasswordList(ctx))
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	ks := openKeyStore()
 	acct, err := ks.ImportPreSaleKey(keyJSON, passphrase)
 	if err != nil {
 		utils.Fatalf("%v", err)
@@ -218,25 +373,8 @@ func importWallet(ctx *cli.Context) error {
 }
 
 func accountList(ctx *cli.Context) error {
-	cfg := DefaultConfig
-	// Load config file.
-	if len(cfgFile) > 0 {
-		if err := conf.LoadConfigFromFile(cfgFile, &cfg); err != nil {
-			utils.Fatalf("%v", err)
-		}
-	}
-
-	stack, err := node.NewNode(ctx, &cfg)
-	if err != nil {
-		return err
-	}
-
-	var index int
-	for _, wallet := range stack.AccountManager().Wallets() {
-		for _, account := range wallet.Accounts() {
-			fmt.Printf("Account #%d: {%s} %s\n", index, account.Address, &account.URL)
-			index++
-		}
+	for index, account := range openKeyStore().Accounts() {
+		fmt.Printf("Account #%d: {%s} %s\n", index, account.Address, &account.URL)
 	}
 	return nil
 }
@@ -255,11 +393,9 @@ func accountCreate(ctx *cli.Context) error {
 	if err != nil {
 		utils.Fatalf("Failed to read configuration: %v", err)
 	}
-	scryptN := keystore.StandardScryptN
-	scryptP := keystore.StandardScryptP
-	if cfg.NodeCfg.UseLightweightKDF {
-		scryptN = keystore.LightScryptN
-		scryptP = keystore.LightScryptP
+	scryptN, scryptP, err := keystore.ScryptParams(cfg.NodeCfg.UseLightweightKDF, cfg.NodeCfg.ScryptN, cfg.NodeCfg.ScryptP)
+	if err != nil {
+		utils.Fatalf("%v", err)
 	}
 
 	password := utils.GetPassPhraseWithList("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, MakePasswordList(ctx))
@@ -279,8 +415,8 @@ func accountCreate(ctx *cli.Context) error {
 	return nil
 }
 
-// tries unlocking the specified account a few times.
-func unlockAccount(ks *keystore.KeyStore, address string, i int, passwords []string) (accounts.Account, string) {
+// tries unlocking the specified account a few times, for timeout if not zero.
+func unlockAccount(ks *keystore.KeyStore, address string, i int, passwords []string, timeout time.Duration) (accounts.Account, string) {
 	account, err := utils.MakeAddress(ks, address)
 	if err != nil {
 		utils.Fatalf("Could not list accounts: %v", err)
@@ -288,14 +424,14 @@ func unlockAccount(ks *keystore.KeyStore, address string, i int, passwords []str
 	for trials := 0; trials < 3; trials++ {
 		prompt := fmt.Sprintf("Unlocking account %s | Attempt %d/%d", address, trials+1, 3)
 		password := utils.GetPassPhraseWithList(prompt, false, i, passwords)
-		err = ks.Unlock(account, password)
+		err = ks.TimedUnlock(account, password, timeout)
 		if err == nil {
 			log.Info("Unlocked account", "address", account.Address.Hex())
 			return account, password
 		}
 		if err, ok := err.(*keystore.AmbiguousAddrError); ok {
 			log.Info("Unlocked account", "address", account.Address.Hex())
-			return ambiguousAddrRecovery(ks, err, password), password
+			return ambiguousAddrRecovery(ks, err, password, timeout), password
 		}
 		if err != keystore.ErrDecrypt {
 			// No need to prompt again if the error is not decryption-related.
@@ -308,7 +444,7 @@ func unlockAccount(ks *keystore.KeyStore, address string, i int, passwords []str
 	return accounts.Account{}, ""
 }
 
-func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrError, auth string) accounts.Account {
+func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrError, auth string, timeout time.Duration) accounts.Account {
 	fmt.Printf("Multiple key files exist for address %x:\n", err.Addr)
 	for _, a := range err.Matches {
 		fmt.Println("  ", a.URL)
@@ -316,7 +452,7 @@ func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrErr
 	fmt.Println("Testing your password against all of them...")
 	var match *accounts.Account
 	for i, a := range err.Matches {
-		if e := ks.Unlock(a, auth); e == nil {
+		if e := ks.TimedUnlock(a, auth, timeout); e == nil {
 			match = &err.Matches[i]
 			break
 		}
@@ -342,16 +478,12 @@ func accountUpdate(ctx *cli.Context) error {
 		utils.Fatalf("No accounts specified to update")
 	}
 
-	stack, err := node.NewNode(ctx, &DefaultConfig)
-	if err != nil {
-		return err
-	}
-
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	ks := openKeyStore()
+	passwords := MakePasswordList(ctx)
 
 	for _, addr := range ctx.Args().Slice() {
-		account, oldPassword := unlockAccount(ks, addr, 0, nil)
-		newPassword := utils.GetPassPhraseWithList("Please give a new password. Do not forget this password.", true, 0, nil)
+		account, oldPassword := unlockAccount(ks, addr, 0, passwords, 0)
+		newPassword := utils.GetPassPhraseWithList("Please give a new password. Do not forget this password.", true, 1, passwords)
 		if err := ks.Update(account, oldPassword, newPassword); err != nil {
 			utils.Fatalf("Could not update the account: %v", err)
 		}
@@ -364,23 +496,142 @@ func accountImport(ctx *cli.Context) error {
 		utils.Fatalf("keyfile must be given as the only argument")
 	}
 	keyfile := ctx.Args().First()
+	ks := openKeyStore()
+	passwords := MakePasswordList(ctx)
+
+	// An encrypted key file is a JSON object, a raw key is hex.
+	if keyJSON, err := os.ReadFile(keyfile); err == nil && json.Valid(keyJSON) {
+		passphrase := utils.GetPassPhraseWithList("Please give the password of the key file.", false, 0, passwords)
+		newPassphrase := utils.GetPassPhraseWithList("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 1, passwords)
+		acct, err := ks.Import(keyJSON, passphrase, newPassphrase)
+		if err != nil {
+			utils.Fatalf("Could not import the key file: %v", err)
+		}
+		fmt.Printf("Address: {%x}\n", acct.Address)
+		return nil
+	}
 	key, err := crypto.LoadECDSA(keyfile)
 	if err != nil {
 		utils.Fatalf("Failed to load the private key: %v", err)
 	}
 
-	stack, err := node.NewNode(ctx, &DefaultConfig)
+	passphrase := utils.GetPassPhraseWithList("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, passwords)
+
+	acct, err := ks.ImportECDSA(key, passphrase)
+	if err != nil {
+		utils.Fatalf("Could not create the account: %v", err)
+	}
+	fmt.Printf("Address: {%x}\n", acct.Address)
+	return nil
+}
+
+// accountExport writes the key of an account, encrypted with its password, to
+// a file or the standard output.
+func accountExport(ctx *cli.Context) error {
+	if ctx.Args().Len() < 1 || ctx.Args().Len() > 2 {
+		utils.Fatalf("The address and optionally the key file must be given as arguments")
+	}
+	ks := openKeyStore()
+	account, password := unlockAccount(ks, ctx.Args().First(), 0, MakePasswordList(ctx), 0)
+	defer ks.Lock(account.Address)
+
+	keyJSON, err := ks.Export(account, password, password)
+	if err != nil {
+		utils.Fatalf("Could not export the account: %v", err)
+	}
+	if ctx.Args().Len() == 1 {
+		fmt.Println(string(keyJSON))
+		return nil
+	}
+	// Refuse to overwrite, the file may be the only copy of another key.
+	fd, err := os.OpenFile(ctx.Args().Get(1), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
 	if err != nil {
+		utils.Fatalf("Could not create the key file: %v", err)
+	}
+	defer fd.Close()
+	if _, err := fd.Write(keyJSON); err != nil {
+		utils.Fatalf("Could not write the key file: %v", err)
+	}
+	fmt.Printf("Exported {%x} to %s\n", account.Address, ctx.Args().Get(1))
+	return nil
+}
+
+// hdCreate generates a mnemonic and imports the first accounts of its wallet.
+func hdCreate(ctx *cli.Context) error {
+	words := ctx.Int(hdWordsFlag.Name)
+	entropy, err := hdwallet.NewEntropy(words / 3 * 32)
+	if err != nil || words%3 != 0 {
+		utils.Fatalf("Invalid number of words %d, must be 12, 15, 18, 21 or 24", words)
+	}
+	mnemonic, err := hdwallet.NewMnemonic(entropy)
+	if err != nil {
+		utils.Fatalf("Failed to create the mnemonic: %v", err)
+	}
+	if err := hdDerive(ctx, mnemonic); err != nil {
 		return err
 	}
+	fmt.Printf("\nYour new mnemonic is:\n\n    %s\n\n", mnemonic)
+	fmt.Printf("- Write it down and keep it offline! It restores every account of the wallet.\n")
+	fmt.Printf("- Anyone knowing it controls the funds of the wallet.\n\n")
+	return nil
+}
 
-	passphrase := utils.GetPassPhraseWithList("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, MakePasswordList(ctx))
+// hdImport imports the accounts of an existing mnemonic.
+func hdImport(ctx *cli.Context) error {
+	var mnemonic string
+	if file := ctx.Path(hdMnemonicFileFlag.Name); file != "" {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read the mnemonic: %v", err)
+		}
+		mnemonic = string(text)
+	} else {
+		text, err := prompt.Stdin.PromptPassword("Mnemonic: ")
+		if err != nil {
+			utils.Fatalf("Failed to read the mnemonic: %v", err)
+		}
+		mnemonic = text
+	}
+	return hdDerive(ctx, mnemonic)
+}
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
-	acct, err := ks.ImportECDSA(key, passphrase)
+// hdDerive imports the accounts of the wallet of a mnemonic into the keystore.
+func hdDerive(ctx *cli.Context, mnemonic string) error {
+	base, err := accounts.ParseDerivationPath(ctx.String(hdPathFlag.Name))
 	if err != nil {
-		utils.Fatalf("Could not create the account: %v", err)
+		utils.Fatalf("Invalid derivation path: %v", err)
+	}
+	count := ctx.Int(hdCountFlag.Name)
+	if count < 1 {
+		utils.Fatalf("At least one account must be derived")
+	}
+	var passphrase string
+	if ctx.Bool(hdPassphraseFlag.Name) {
+		if passphrase, err = prompt.Stdin.PromptPassword("Mnemonic passphrase: "); err != nil {
+			utils.Fatalf("Failed to read the passphrase: %v", err)
+		}
+	}
+	seed, err := hdwallet.NewSeed(mnemonic, passphrase)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	keys, paths, err := hdwallet.DeriveKeys(seed, base, count)
+	if err != nil {
+		utils.Fatalf("Failed to derive the accounts: %v", err)
+	}
+
+	ks := openKeyStore()
+	password := utils.GetPassPhraseWithList("The accounts are locked with a password. Please give a password. Do not forget this password.", true, 0, MakePasswordList(ctx))
+	for i, key := range keys {
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		if ks.HasAddress(address) {
+			fmt.Printf("%s: {%x} already in the keystore\n", paths[i], address)
+			continue
+		}
+		if _, err := ks.ImportECDSA(key, password); err != nil {
+			utils.Fatalf("Could not import the account %s: %v", paths[i], err)
+		}
+		fmt.Printf("%s: {%x}\n", paths[i], address)
 	}
-	fmt.Printf("Address: {%x}\n", acct.Address)
 	return nil
 }
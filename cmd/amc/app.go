@@ -17,14 +17,13 @@
 package main
 
 import (
-	"fmt"
-	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/debug"
 	"github.com/amazechain/amc/log"
-	"net/http"
-	_ "net/http/pprof"
+	"net"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -40,56 +39,105 @@ import (
 )
 
 func appRun(ctx *cli.Context) error {
+	stack, err := makeNode(ctx)
+	if err != nil {
+		return err
+	}
+	runNode(ctx, stack, false)
+	stack.Wait()
+
+	return nil
+}
+
+// makeNode applies the configuration, sets up logging and profiling and
+// creates the node.
+func makeNode(ctx *cli.Context) (*node.Node, error) {
 	if len(cfgFile) > 0 {
 		if err := conf.LoadConfigFromFile(cfgFile, &DefaultConfig); err != nil {
-			return err
+			return nil, err
 		}
 	} else {
-		DefaultConfig.NetworkCfg.ListenersAddress = listenAddress.Value()
-		DefaultConfig.NetworkCfg.BootstrapPeers = bootstraps.Value()
-		if len(privateKey) > 0 {
-			DefaultConfig.NetworkCfg.LocalPeerKey = privateKey
+		applyListFlags(ctx)
+	}
+	if DefaultConfig.NodeCfg.Dev {
+		if err := applyDevDefaults(ctx); err != nil {
+			return nil, err
 		}
-
-		DefaultConfig.P2PCfg.StaticPeers = p2pStaticPeers.Value()
-		DefaultConfig.P2PCfg.BootstrapNodeAddr = p2pBootstrapNode.Value()
-		DefaultConfig.P2PCfg.DenyListCIDR = p2pDenyList.Value()
-
-		//
-		DefaultConfig.P2PCfg.DataDir = DefaultConfig.NodeCfg.DataDir
 	}
 
 	log.Init(DefaultConfig.NodeCfg, DefaultConfig.LoggerCfg)
 
-	if DefaultConfig.PprofCfg.Pprof {
-		if DefaultConfig.PprofCfg.MaxCpu > 0 {
-			runtime.GOMAXPROCS(DefaultConfig.PprofCfg.MaxCpu)
+	if cfg := DefaultConfig.PprofCfg; cfg.Pprof {
+		if cfg.MaxCpu > 0 {
+			runtime.GOMAXPROCS(cfg.MaxCpu)
 		}
-		if DefaultConfig.PprofCfg.TraceMutex {
-			runtime.SetMutexProfileFraction(1)
+		blockRate, mutexFraction := cfg.BlockProfileRate, cfg.MutexProfileFraction
+		if blockRate == 0 && cfg.TraceBlock {
+			blockRate = 1
 		}
-		if DefaultConfig.PprofCfg.TraceBlock {
-			runtime.SetBlockProfileRate(1)
+		if mutexFraction == 0 && cfg.TraceMutex {
+			mutexFraction = 1
 		}
-
-		go func() {
-			if err := http.ListenAndServe(fmt.Sprintf(":%d", DefaultConfig.PprofCfg.Port), nil); err != nil {
-				log.Error("failed to setup go pprof", "err", err)
-				os.Exit(0)
-			}
-		}()
+		debug.SetProfileRates(blockRate, mutexFraction)
+		debug.StartPProf(net.JoinHostPort(cfg.Addr, strconv.Itoa(cfg.Port)))
 	}
 
 	stack, err := node.NewNode(ctx, &DefaultConfig)
 	if err != nil {
 		log.Error("Failed start Node", "err", err)
-		return err
+		return nil, err
+	}
+	return stack, nil
+}
+
+// applyDevDefaults sets up a dev node: a private chain that keeps nothing on
+// disk unless a data dir is given, mines, finds no peers and serves every API
+// over HTTP and WS on the loopback interface. Explicit flags win.
+func applyDevDefaults(ctx *cli.Context) error {
+	nodeCfg, p2pCfg := &DefaultConfig.NodeCfg, DefaultConfig.P2PCfg
+	if !ctx.IsSet(DataDirFlag.Name) {
+		// The database and the keystore are in memory, the network keys
+		// still need a directory.
+		dir, err := os.MkdirTemp("", "amc-dev")
+		if err != nil {
+			return err
+		}
+		nodeCfg.DataDir, p2pCfg.DataDir = "", dir
+	}
+	nodeCfg.Chain = "private"
+	nodeCfg.Miner = true
+	if !ctx.IsSet("http") {
+		nodeCfg.HTTP = true
+	}
+	if !ctx.IsSet("http.addr") {
+		nodeCfg.HTTPHost = "127.0.0.1"
 	}
+	if !ctx.IsSet("http.api") {
+		nodeCfg.HTTPApi = "all"
+	}
+	if !ctx.IsSet("ws") {
+		nodeCfg.WS = true
+	}
+	if !ctx.IsSet("ws.addr") {
+		nodeCfg.WSHost = "127.0.0.1"
+	}
+	if !ctx.IsSet("ws.api") {
+		nodeCfg.WSApi = "all"
+	}
+	// Nobody else seals the chain, there is nothing to sync from.
+	p2pCfg.NoDiscovery = true
+	p2pCfg.MinSyncPeers = 0
+	return nil
+}
 
-	StartNode(ctx, stack, false)
+// runNode starts the node, unlocks the requested accounts and opens the
+// wallets as they appear. In console mode SIGINT is left to the console.
+func runNode(ctx *cli.Context, stack *node.Node, isConsole bool) {
+	StartNode(ctx, stack, isConsole)
 
 	// Unlock any account specifically requested
 	unlockAccounts(ctx, stack, &DefaultConfig)
+	lockOnSignal(stack)
 
 	// Register wallet event handlers to open and auto-derive wallets
 	events := make(chan accounts.WalletEvent, 16)
@@ -127,10 +175,6 @@ func appRun(ctx *cli.Context) error {
 			}
 		}
 	}()
-
-	stack.Wait()
-
-	return nil
 }
 
 // unlockAccounts unlocks any account specifically requested.
@@ -152,10 +196,14 @@ func unlockAccounts(ctx *cli.Context, stack *node.Node, cfg *conf.Config) {
 	if !cfg.NodeCfg.InsecureUnlockAllowed && cfg.NodeCfg.ExtRPCEnabled() {
 		utils.Fatalf("Account unlock with HTTP access is forbidden!")
 	}
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	backends := stack.AccountManager().Backends(keystore.KeyStoreType)
+	if len(backends) == 0 {
+		utils.Fatalf("Accounts can't be unlocked with --%s, the external signer holds them", ExternalSignerFlag.Name)
+	}
+	ks := backends[0].(*keystore.KeyStore)
 	passwords := MakePasswordList(ctx)
 	for i, account := range unlocks {
-		unlockAccount(ks, account, i, passwords)
+		unlockAccount(ks, account, i, passwords, cfg.NodeCfg.UnlockDuration)
 	}
 }
 
@@ -186,21 +234,30 @@ func StartNode(ctx *cli.Context, stack *node.Node, isConsole bool) {
 		signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
 		defer signal.Stop(sigc)
 
-		if ctx.IsSet(MinFreeDiskSpaceFlag.Name) {
-			minFreeDiskSpace := ctx.Int(MinFreeDiskSpaceFlag.Name)
-			go monitorFreeDiskSpace(sigc, stack.InstanceDir(), uint64(minFreeDiskSpace)*1024*1024*1024)
-		}
-
+		// The node stops its services in order, a second signal or the
+		// shutdown timeout exits without waiting for them.
 		shutdown := func() {
 			log.Info("Got interrupt, shutting down...")
-			go stack.Close()
-			for i := 10; i > 0; i-- {
-				<-sigc
-				if i > 1 {
-					log.Warn("Already shutting down, interrupt more to panic.", "times", i-1)
+			done := make(chan struct{})
+			go func() {
+				if err := stack.Close(); err != nil {
+					log.Error("Failed to stop the node", "err", err)
 				}
+				close(done)
+			}()
+			var timeout <-chan time.Time
+			if d := DefaultConfig.NodeCfg.ShutdownTimeout; d > 0 {
+				timeout = time.After(d)
+			}
+			select {
+			case <-done:
+				return
+			case <-sigc:
+				log.Warn("Got a second interrupt, exiting without waiting for the services")
+			case <-timeout:
+				log.Error("The services didn't stop in time, exiting", "timeout", DefaultConfig.NodeCfg.ShutdownTimeout)
 			}
-			panic("Panic closing the amc node")
+			os.Exit(1)
 		}
 
 		if isConsole {
@@ -219,24 +276,3 @@ func StartNode(ctx *cli.Context, stack *node.Node, isConsole bool) {
 		}
 	}()
 }
-
-func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritical uint64) {
-	if path == "" {
-		return
-	}
-	for {
-		freeSpace, err := getFreeDiskSpace(path)
-		if err != nil {
-			log.Warn("Failed to get free disk space", "path", path, "err", err)
-			break
-		}
-		if freeSpace < freeDiskSpaceCritical {
-			log.Error("Low disk space. Gracefully shutting down Geth to prevent database corruption.", "available", types.StorageSize(freeSpace), "path", path)
-			sigc <- syscall.SIGTERM
-			break
-		} else if freeSpace < 2*freeDiskSpaceCritical {
-			log.Warn("Disk space is running low. Geth will shutdown if disk space runs below critical level.", "available", types.StorageSize(freeSpace), "critical_level", types.StorageSize(freeDiskSpaceCritical), "path", path)
-		}
-		time.Sleep(30 * time.Second)
-	}
-}
@@ -0,0 +1,239 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/amazechain/amc/cmd/utils"
+	"github.com/amazechain/amc/internal/node"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	importCommand = &cli.Command{
+		Name:      "import",
+		Usage:     "Import a blockchain file",
+		ArgsUsage: "<filename> (<filename 2> ... <filename N>) ",
+		Action:    importChain,
+		Flags: []cli.Flag{
+			DataDirFlag,
+		},
+		Description: `
+The import command imports blocks from a file written by the export command.
+The blocks are executed and verified as if they arrived over the network, and
+those already in the chain are skipped. Files ending in .gz are read as gzip
+compressed. If several files are given, an invalid block in one of them stops
+the import.`,
+	}
+	verifyExecFlag = &cli.BoolFlag{
+		Name:  "exec",
+		Usage: "Also execute the blocks again and validate their outcome",
+	}
+	verifyChainCommand = &cli.Command{
+		Name:      "verify-chain",
+		Usage:     "Check the integrity of the local chain data",
+		ArgsUsage: "[<blockNumFirst> <blockNumLast>]",
+		Action:    verifyChain,
+		Flags: []cli.Flag{
+			DataDirFlag,
+			verifyExecFlag,
+		},
+		Description: `
+The verify-chain command checks the canonical blocks stored in the datadir,
+the whole chain or the given range: the headers must link to each other and
+their transactions and receipts must match the header roots. With --exec the
+blocks are also executed again on the state history, which must cover the
+range. The first corrupt item found is reported and the command fails, so it
+can be used to check a datadir after an unclean shutdown or disk errors.`,
+	}
+	exportCSVFlag = &cli.BoolFlag{
+		Name:  "csv",
+		Usage: "Write CSV files with a header line instead of NDJSON",
+	}
+	exportNDJSONCommand = &cli.Command{
+		Name:      "export-ndjson",
+		Usage:     "Export blocks, transactions, receipts and logs to flat files for analytics",
+		ArgsUsage: "<dir> [<blockNumFirst> <blockNumLast>]",
+		Action:    exportTables,
+		Flags: []cli.Flag{
+			DataDirFlag,
+			exportCSVFlag,
+		},
+		Description: `
+The export-ndjson command writes the canonical chain, or the given range of it,
+to the files blocks.ndjson, transactions.ndjson, receipts.ndjson and
+logs.ndjson in the given directory, a JSON object per line, ready to be loaded
+into a data warehouse. With --csv the files are CSV with a header line. The
+columns of each table are fixed, amounts are decimal strings and hashes,
+addresses and byte strings 0x prefixed hex. The receipts of the range must not
+have been pruned.`,
+	}
+	rollbackForceFlag = &cli.BoolFlag{
+		Name:  "force",
+		Usage: "Rewind even past the finalized block, moving the finality markers back",
+	}
+	rollbackCommand = &cli.Command{
+		Name:      "rollback",
+		Usage:     "Rewind the canonical chain to an earlier block",
+		ArgsUsage: "<blockNumber>",
+		Action:    rollbackChain,
+		Flags: []cli.Flag{
+			DataDirFlag,
+			rollbackForceFlag,
+		},
+		Description: `
+The rollback command makes the given block the head of the local chain, to
+recover from a bad import. The canonical markers, receipts and transaction
+index entries of the blocks above it are dropped and the state is rolled back
+with the state history, the blocks themselves are kept as side blocks. The
+command fails if the history of the block was pruned, or if the block is below
+the finalized one unless --force is given.`,
+	}
+)
+
+// verifyChain checks the local chain, or the given range of it.
+func verifyChain(ctx *cli.Context) error {
+	if ctx.Args().Len() != 0 && ctx.Args().Len() != 2 {
+		utils.Fatalf("This command requires zero or two arguments.")
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	chain := stack.BlockChain()
+	first, last := uint64(0), chain.CurrentBlock().Number64().Uint64()
+	if ctx.Args().Len() == 2 {
+		if first, err = strconv.ParseUint(ctx.Args().Get(0), 10, 64); err != nil {
+			utils.Fatalf("Verify error in parsing parameters: block number not an integer")
+		}
+		if last, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			utils.Fatalf("Verify error in parsing parameters: block number not an integer")
+		}
+	}
+	if err := node.VerifyChain(ctx.Context, chain, first, last, ctx.Bool(verifyExecFlag.Name)); err != nil {
+		utils.Fatalf("Verify error: %v", err)
+	}
+	return nil
+}
+
+// exportTables writes the local chain, or the given range of it, to flat
+// analytical files.
+func exportTables(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 && ctx.Args().Len() != 3 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	chain := stack.BlockChain()
+	first, last := uint64(0), chain.CurrentBlock().Number64().Uint64()
+	if ctx.Args().Len() == 3 {
+		if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer")
+		}
+		if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer")
+		}
+	}
+	format := "ndjson"
+	if ctx.Bool(exportCSVFlag.Name) {
+		format = "csv"
+	}
+	if err := node.ExportTables(ctx.Context, chain, ctx.Args().First(), format, first, last); err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	return nil
+}
+
+// rollbackChain rewinds the local chain to the given block.
+func rollbackChain(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	number, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		utils.Fatalf("Rollback error in parsing parameters: block number not an integer")
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	chain, ok := stack.BlockChain().(interface {
+		Rewind(number uint64, force bool) error
+	})
+	if !ok {
+		utils.Fatalf("Rollback error: the chain can't be rewound")
+	}
+	if err := chain.Rewind(number, ctx.Bool(rollbackForceFlag.Name)); err != nil {
+		utils.Fatalf("Rollback error: %v", err)
+	}
+	return nil
+}
+
+// importChain inserts the blocks of the given chain files into the local chain.
+func importChain(ctx *cli.Context) error {
+	if ctx.Args().Len() < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	for _, fn := range ctx.Args().Slice() {
+		if err := node.ImportChain(ctx.Context, stack.BlockChain(), fn); err != nil {
+			utils.Fatalf("Import error: %v", err)
+		}
+	}
+	return nil
+}
+
+// exportChain writes the local chain, or the given range of it, to a file.
+func exportChain(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 && ctx.Args().Len() != 3 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	chain := stack.BlockChain()
+	first, last := uint64(0), chain.CurrentBlock().Number64().Uint64()
+	if ctx.Args().Len() == 3 {
+		if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer")
+		}
+		if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer")
+		}
+	}
+	if err := node.ExportChain(chain, ctx.Args().First(), first, last); err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	return nil
+}
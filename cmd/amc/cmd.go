@@ -17,6 +17,10 @@
 package main
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/params"
 	"github.com/amazechain/amc/params/networkname"
 	"github.com/urfave/cli/v2"
 )
@@ -33,6 +37,7 @@ var (
 	p2pStaticPeers   = cli.NewStringSlice()
 	p2pBootstrapNode = cli.NewStringSlice()
 	p2pDenyList      = cli.NewStringSlice()
+	p2pDiscoveryDNS  = cli.NewStringSlice()
 )
 
 var rootCmd []*cli.Command
@@ -77,6 +82,12 @@ var rpcFlags = []cli.Flag{
 		Value:       DefaultConfig.NodeCfg.IPCPath,
 		Destination: &DefaultConfig.NodeCfg.IPCPath,
 	},
+	&cli.StringFlag{
+		Name:        "ipc.api",
+		Usage:       "API's offered over the IPC interface (default = all)",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.IPCApi,
+	},
 
 	&cli.BoolFlag{
 		Name:        "http",
@@ -109,6 +120,12 @@ var rpcFlags = []cli.Flag{
 		Value:       "",
 		Destination: &DefaultConfig.NodeCfg.HTTPCors,
 	},
+	&cli.StringFlag{
+		Name:        "http.vhosts",
+		Usage:       "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value:       DefaultConfig.NodeCfg.HTTPVirtualHosts,
+		Destination: &DefaultConfig.NodeCfg.HTTPVirtualHosts,
+	},
 
 	&cli.BoolFlag{
 		Name:        "ws",
@@ -142,6 +159,61 @@ var rpcFlags = []cli.Flag{
 		Value:       "",
 		Destination: &DefaultConfig.NodeCfg.WSOrigins,
 	},
+
+	&cli.Uint64Flag{
+		Name:        "rpc.gascap",
+		Usage:       "Sets a cap on gas that can be used in eth_call/estimateGas (0=infinite)",
+		Value:       DefaultConfig.NodeCfg.RPCGasCap,
+		Destination: &DefaultConfig.NodeCfg.RPCGasCap,
+	},
+	&cli.DurationFlag{
+		Name:        "rpc.evmtimeout",
+		Usage:       "Sets a timeout used for eth_call (0=infinite)",
+		Value:       DefaultConfig.NodeCfg.RPCEVMTimeout,
+		Destination: &DefaultConfig.NodeCfg.RPCEVMTimeout,
+	},
+	&cli.Float64Flag{
+		Name:        "rpc.ratelimit",
+		Usage:       "Maximum requests per second accepted from a single remote host over HTTP and WS (0=unlimited)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.RPCRateLimit,
+	},
+	&cli.IntFlag{
+		Name:        "rpc.rateburst",
+		Usage:       "Number of requests a remote host may burst above the rate limit (default = one second worth)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.RPCRateBurst,
+	},
+	&cli.StringFlag{
+		Name:        "rpc.methodlimits",
+		Usage:       "Comma separated list of method=rate pairs limiting the requests per second of single methods",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.RPCMethodLimits,
+	},
+	&cli.DurationFlag{
+		Name:        "rpc.calltimeout",
+		Usage:       "Aborts RPC requests running longer than this (0=unlimited)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.RPCCallTimeout,
+	},
+	&cli.IntFlag{
+		Name:        "rpc.maxresponsesize",
+		Usage:       "Maximum size in bytes of an RPC response or batch of responses (0=unlimited)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.RPCMaxResponseSize,
+	},
+	&cli.DurationFlag{
+		Name:        "rpc.slowthreshold",
+		Usage:       "Log RPC requests taking longer than this at warning level (0=disabled)",
+		Value:       0,
+		Destination: &DefaultConfig.NodeCfg.RPCSlowThreshold,
+	},
+	&cli.StringFlag{
+		Name:        "rpc.apikeys",
+		Usage:       "JSON file of API keys with their allowed namespaces, rate limits and CORS origins, required by the public HTTP and WS endpoints if set",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.RPCAPIKeys,
+	},
 }
 
 var consensusFlag = []cli.Flag{
@@ -163,6 +235,19 @@ var consensusFlag = []cli.Flag{
 		Value:       "",
 		Destination: &DefaultConfig.Miner.Etherbase,
 	},
+	&cli.StringFlag{
+		Name:        "engine.signer",
+		Usage:       "HTTP endpoint of a remote signer used to seal blocks",
+		Value:       "",
+		Destination: &DefaultConfig.Miner.Signer,
+	},
+}
+
+// ConfigFileFlag loads the node settings from a TOML file, see dumpconfig.
+var ConfigFileFlag = &cli.PathFlag{
+	Name:      "config",
+	Usage:     "TOML configuration file, overridden by the flags given on the command line",
+	TakesFile: true,
 }
 
 var configFlag = []cli.Flag{
@@ -171,6 +256,7 @@ var configFlag = []cli.Flag{
 		Usage:       "Loading a Configuration File",
 		Destination: &cfgFile,
 	},
+	ConfigFileFlag,
 }
 
 var pprofCfg = []cli.Flag{
@@ -199,12 +285,28 @@ var pprofCfg = []cli.Flag{
 		Value:       0,
 		Destination: &DefaultConfig.PprofCfg.MaxCpu,
 	},
+	&cli.StringFlag{
+		Name:        "pprof.addr",
+		Usage:       "pprof HTTP server listening interface",
+		Value:       "127.0.0.1",
+		Destination: &DefaultConfig.PprofCfg.Addr,
+	},
 	&cli.IntFlag{
 		Name:        "pprof.port",
 		Usage:       "pprof HTTP server listening port",
-		Value:       0,
+		Value:       6061,
 		Destination: &DefaultConfig.PprofCfg.Port,
 	},
+	&cli.IntFlag{
+		Name:        "pprof.blockprofilerate",
+		Usage:       "Turn on block profiling with the given rate in nanoseconds (overrides --pprof.block)",
+		Destination: &DefaultConfig.PprofCfg.BlockProfileRate,
+	},
+	&cli.IntFlag{
+		Name:        "pprof.mutexprofilefraction",
+		Usage:       "Turn on mutex profiling, sampling one in the given number of contention events (overrides --pprof.mutex)",
+		Destination: &DefaultConfig.PprofCfg.MutexProfileFraction,
+	},
 }
 
 var loggerFlag = []cli.Flag{
@@ -215,16 +317,34 @@ var loggerFlag = []cli.Flag{
 		Destination: &DefaultConfig.LoggerCfg.LogFile,
 	},
 
+	&cli.PathFlag{
+		Name:        "log.file",
+		Usage:       "Path of the log file, overriding --log.name (default = inside the log directory of the datadir)",
+		TakesFile:   true,
+		Destination: &DefaultConfig.LoggerCfg.File,
+	},
+	&cli.StringFlag{
+		Name:        "log.format",
+		Usage:       "Format of the console log output (value:[text,json]), the log file is always JSON",
+		Value:       "text",
+		Destination: &DefaultConfig.LoggerCfg.Format,
+	},
+
 	&cli.StringFlag{
 		Name:        "log.level",
 		Usage:       "logger output level (value:[debug,info,warn,error,dpanic,panic,fatal])",
 		Value:       "debug",
 		Destination: &DefaultConfig.LoggerCfg.Level,
 	},
+	&cli.StringFlag{
+		Name:        "log.vmodule",
+		Usage:       "Per-module log levels, such as internal/sync=debug,txpool=trace (modules: package paths or downloader, txpool, consensus, miner, p2p, vm, rpc, state)",
+		Destination: &DefaultConfig.LoggerCfg.Vmodule,
+	},
 
 	&cli.IntFlag{
 		Name:        "log.maxSize",
-		Usage:       "logger file max size M",
+		Usage:       "logger file max size M before it is rotated",
 		Value:       10,
 		Destination: &DefaultConfig.LoggerCfg.MaxSize,
 	},
@@ -236,13 +356,13 @@ var loggerFlag = []cli.Flag{
 	},
 	&cli.IntFlag{
 		Name:        "log.maxAge",
-		Usage:       "logger file max age",
+		Usage:       "logger file max age in days, older rotated files are removed",
 		Value:       30,
 		Destination: &DefaultConfig.LoggerCfg.MaxAge,
 	},
 	&cli.BoolFlag{
 		Name:        "log.compress",
-		Usage:       "logger file compress",
+		Usage:       "logger file compress, gzipping the rotated files",
 		Value:       false,
 		Destination: &DefaultConfig.LoggerCfg.Compress,
 	},
@@ -267,6 +387,12 @@ var (
 		Usage:       "The address of bootstrap node. Beacon node will connect for peer discovery via DHT.  Multiple nodes can be passed by using the flag multiple times but not comma-separated. You can also pass YAML files containing multiple nodes.",
 		Destination: p2pBootstrapNode,
 	}
+	// P2PDiscoveryDNS lists the DNS node trees the dial candidates are also taken from.
+	P2PDiscoveryDNS = &cli.StringSliceFlag{
+		Name:        "discovery.dns",
+		Usage:       "Sets DNS discovery entry points (enrtree:// URLs). This flag may be used multiple times.",
+		Destination: p2pDiscoveryDNS,
+	}
 	// P2PRelayNode tells the beacon node which relay node to connect to.
 	P2PRelayNode = &cli.StringFlag{
 		Name: "p2p.relay-node",
@@ -310,6 +436,13 @@ var (
 		Value:       "",
 		Destination: &DefaultConfig.P2PCfg.HostDNS,
 	}
+	// P2PNAT selects how the listening ports are mapped on the gateway.
+	P2PNAT = &cli.StringFlag{
+		Name:        "nat",
+		Usage:       "NAT port mapping mechanism (any|none|upnp|pmp|pmp:<IP>|extip:<IP>)",
+		Value:       "any",
+		Destination: &DefaultConfig.P2PCfg.NAT,
+	}
 	// P2PPrivKey defines a flag to specify the location of the private key file for libp2p.
 	P2PPrivKey = &cli.StringFlag{
 		Name:        "p2p.priv-key",
@@ -317,6 +450,11 @@ var (
 		Value:       "",
 		Destination: &DefaultConfig.P2PCfg.PrivateKey,
 	}
+	P2PPrivKeyHex = &cli.StringFlag{
+		Name:        "p2p.priv-key-hex",
+		Usage:       "The hex encoded private key to use in communications with other peers, instead of --p2p.priv-key.",
+		Destination: &DefaultConfig.P2PCfg.PrivateKeyHex,
+	}
 	P2PStaticID = &cli.BoolFlag{
 		Name:        "p2p.static-id",
 		Usage:       "Enables the peer id of the node to be fixed by saving the generated network key to the default key path.",
@@ -337,6 +475,30 @@ var (
 		Value:       5,
 		Destination: &DefaultConfig.P2PCfg.MaxPeers,
 	}
+	// P2PMaxInboundPeers defines a flag to cap the peers dialing in.
+	P2PMaxInboundPeers = &cli.IntFlag{
+		Name:        "p2p.max-inbound-peers",
+		Usage:       "The max number of inbound peers (default: derived from --p2p.dial-ratio, or 80% of --p2p.max-peers).",
+		Destination: &DefaultConfig.P2PCfg.MaxInboundPeers,
+	}
+	// P2PMaxOutboundPeers defines a flag to cap the peers we dial.
+	P2PMaxOutboundPeers = &cli.IntFlag{
+		Name:        "p2p.max-outbound-peers",
+		Usage:       "The max number of outbound peers (default: --p2p.max-peers).",
+		Destination: &DefaultConfig.P2PCfg.MaxOutboundPeers,
+	}
+	// P2PDialRatio defines a flag to reserve peer slots for outbound connections.
+	P2PDialRatio = &cli.IntFlag{
+		Name:        "p2p.dial-ratio",
+		Usage:       "Reserve one in every n peer slots for outbound connections, unless --p2p.max-inbound-peers is set (0 = 20% of the slots).",
+		Destination: &DefaultConfig.P2PCfg.DialRatio,
+	}
+	// P2PNetRestrict defines the networks peers are restricted to.
+	P2PNetRestrict = &cli.StringFlag{
+		Name:        "netrestrict",
+		Usage:       "Restricts network communication to the given IP networks (CIDR masks, comma separated)",
+		Destination: &DefaultConfig.P2PCfg.NetRestrict,
+	}
 	// P2PAllowList defines a CIDR subnet to exclusively allow connections.
 	P2PAllowList = &cli.StringFlag{
 		Name: "p2p.allowlist",
@@ -364,6 +526,12 @@ var (
 		Value:       1,
 		Destination: &DefaultConfig.P2PCfg.MinSyncPeers,
 	}
+	// P2PMaxClockDisparity bounds the timestamps of gossiped blocks.
+	P2PMaxClockDisparity = &cli.IntFlag{
+		Name:        "p2p.max-clock-disparity",
+		Usage:       "Seconds a gossiped block may be stamped in the future before it is rejected (0 = 15s).",
+		Destination: &DefaultConfig.P2PCfg.MaxClockDisparity,
+	}
 
 	// P2PBlockBatchLimit specifies the requested block batch size.
 	P2PBlockBatchLimit = &cli.IntFlag{
@@ -396,13 +564,121 @@ var (
 		Destination: &DefaultConfig.NodeCfg.DataDir,
 	}
 
+	DBEngineFlag = &cli.StringFlag{
+		Name:        "db.engine",
+		Usage:       `Key-value store of the chain database ("mdbx", "pebble" or "memory"), fixed when the database is created`,
+		Value:       "mdbx",
+		Destination: &DefaultConfig.NodeCfg.DBEngine,
+	}
+
 	MinFreeDiskSpaceFlag = &cli.IntFlag{
 		Name:        "data.dir.minfreedisk",
-		Usage:       "Minimum free disk space in GB, once reached triggers auto shut down (default = 10GB, 0 = disabled)",
+		Usage:       "Minimum free disk space in GB, below which block import is paused while the RPC keeps serving (0 = disabled)",
 		Value:       10,
 		Destination: &DefaultConfig.NodeCfg.MinFreeDiskSpace,
 	}
 
+	WarnFreeDiskSpaceFlag = &cli.IntFlag{
+		Name:        "data.dir.warnfreedisk",
+		Usage:       "Free disk space in GB below which low disk space is warned about (default = twice data.dir.minfreedisk)",
+		Destination: &DefaultConfig.NodeCfg.WarnFreeDiskSpace,
+	}
+
+	ShutdownTimeoutFlag = &cli.DurationFlag{
+		Name:        "shutdown.timeout",
+		Usage:       "Time the services may take to stop on SIGINT or SIGTERM before the process exits anyway (0 = wait forever)",
+		Value:       time.Minute,
+		Destination: &DefaultConfig.NodeCfg.ShutdownTimeout,
+	}
+
+	ReadyMinPeersFlag = &cli.IntFlag{
+		Name:        "ready.minpeers",
+		Usage:       "Connected peers required for /ready to report the node as ready",
+		Value:       1,
+		Destination: &DefaultConfig.NodeCfg.ReadyMinPeers,
+	}
+
+	ReadyMaxBlockLagFlag = &cli.Uint64Flag{
+		Name:        "ready.maxlag",
+		Usage:       "Blocks the head may be behind the highest peer for /ready to report the node as ready",
+		Value:       16,
+		Destination: &DefaultConfig.NodeCfg.ReadyMaxBlockLag,
+	}
+
+	StallTimeoutFlag = &cli.DurationFlag{
+		Name:        "ready.stalltimeout",
+		Usage:       "Time without a new block after which the head is reported stalled and /ready fails (0 = ten block periods)",
+		Destination: &DefaultConfig.NodeCfg.StallTimeout,
+	}
+
+	ReorgAlertDepthFlag = &cli.Uint64Flag{
+		Name:        "reorg.alertdepth",
+		Usage:       "Dropped blocks from which a chain reorg is logged as a warning and reported",
+		Value:       64,
+		Destination: &DefaultConfig.NodeCfg.ReorgAlertDepth,
+	}
+
+	ReorgWebhookFlag = &cli.StringFlag{
+		Name:        "reorg.webhook",
+		Usage:       "URL the deep chain reorgs are posted to as JSON",
+		Destination: &DefaultConfig.NodeCfg.ReorgWebhook,
+	}
+
+	DBCompressFlag = &cli.BoolFlag{
+		Name:        "db.compress",
+		Usage:       "Compress the transactions and receipts written to the chain database with snappy",
+		Destination: &DefaultConfig.NodeCfg.DBCompress,
+	}
+
+	DBCompactIntervalFlag = &cli.DurationFlag{
+		Name:        "db.compact.interval",
+		Usage:       "Interval between background compactions of a pebble chain database (0 = disabled)",
+		Destination: &DefaultConfig.NodeCfg.DBCompactInterval,
+	}
+
+	DBCompactMinFreeDiskFlag = &cli.IntFlag{
+		Name:        "db.compact.minfreedisk",
+		Usage:       "Minimum free disk space in GB for a database compaction to run",
+		Value:       20,
+		Destination: &DefaultConfig.NodeCfg.DBCompactMinFreeDisk,
+	}
+
+	DBReadOnlyFlag = &cli.BoolFlag{
+		Name:        "db.read-only",
+		Usage:       "Open the chain database read-only, to serve the RPC from the data directory of a running node (mdbx only)",
+		Destination: &DefaultConfig.NodeCfg.DBReadOnly,
+	}
+
+	PreimagesFlag = &cli.BoolFlag{
+		Name:        "cache.preimages",
+		Usage:       "Record the preimages of the hashes computed by imported blocks",
+		Destination: &DefaultConfig.NodeCfg.Preimages,
+	}
+
+	ParallelExecFlag = &cli.BoolFlag{
+		Name:        "exec.parallel",
+		Usage:       "Execute the transactions of imported blocks in parallel, re-executing the conflicting ones",
+		Destination: &DefaultConfig.NodeCfg.ParallelExec,
+	}
+
+	EVMProfileFlag = &cli.BoolFlag{
+		Name:        "vm.profile",
+		Usage:       "Profile the gas and time blocks spend per opcode and contract, dumped by debug_evmProfile",
+		Destination: &DefaultConfig.NodeCfg.EVMProfile,
+	}
+
+	DevFlag = &cli.BoolFlag{
+		Name:        "dev",
+		Usage:       "Ephemeral single node network with a funded developer account, mining as soon as transactions are pending",
+		Destination: &DefaultConfig.NodeCfg.Dev,
+	}
+
+	DevPeriodFlag = &cli.Uint64Flag{
+		Name:        "dev.period",
+		Usage:       "Block period of the dev network in seconds (0 = mine only when transactions are pending)",
+		Destination: &DefaultConfig.NodeCfg.DevPeriod,
+	}
+
 	FromDataDirFlag = &cli.StringFlag{
 		Name:  "chaindata.from",
 		Usage: "source data  dir",
@@ -418,6 +694,67 @@ var (
 		Value:       networkname.MainnetChainName,
 		Destination: &DefaultConfig.NodeCfg.Chain,
 	}
+
+	SyncModeFlag = &cli.StringFlag{
+		Name:        "sync.mode",
+		Usage:       `Blockchain sync mode ("full", "snap" or "light")`,
+		Value:       "full",
+		Destination: &DefaultConfig.NodeCfg.SyncMode,
+	}
+
+	LightServeFlag = &cli.IntFlag{
+		Name:        "light.serve",
+		Usage:       "Maximum number of light clients served at a time (0 = don't serve light clients)",
+		Destination: &DefaultConfig.NodeCfg.LightServe,
+	}
+
+	LightConfirmFlag = &cli.IntFlag{
+		Name:        "light.confirm",
+		Usage:       "Number of light servers that have to return the same account before a light client trusts it; account state is not proven, so that many colluding servers can fake it",
+		Value:       2,
+		Destination: &DefaultConfig.NodeCfg.LightConfirm,
+	}
+
+	SyncCheckpointFlag = &cli.StringFlag{
+		Name:        "sync.checkpoint",
+		Usage:       `Trusted block "<number>:<hash>" whose header chain is fetched first, overriding the network default`,
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.Checkpoint,
+	}
+
+	GCModeFlag = &cli.StringFlag{
+		Name:        "gcmode",
+		Usage:       `State garbage collection mode ("full" or "archive" to keep the state of every block)`,
+		Value:       "full",
+		Destination: &DefaultConfig.NodeCfg.GCMode,
+	}
+
+	PruneHistoryFlag = &cli.Uint64Flag{
+		Name:        "prune.history",
+		Usage:       "Number of recent blocks whose state a full node keeps, older state history is pruned in the background (0 = keep all)",
+		Value:       90000,
+		Destination: &DefaultConfig.NodeCfg.PruneHistory,
+	}
+
+	AncientThresholdFlag = &cli.Uint64Flag{
+		Name:        "ancient.threshold",
+		Usage:       "Number of recent blocks kept in the chain database, older headers, bodies and receipts move to flat files in <datadir>/ancient (0 = disabled)",
+		Value:       90000,
+		Destination: &DefaultConfig.NodeCfg.AncientThreshold,
+	}
+
+	TxLookupLimitFlag = &cli.Uint64Flag{
+		Name:        "txlookuplimit",
+		Usage:       "Number of recent blocks whose transactions are indexed by hash, older ones are unindexed in the background (0 = entire chain)",
+		Destination: &DefaultConfig.NodeCfg.TxLookupLimit,
+	}
+
+	TrieCacheFlag = &cli.IntFlag{
+		Name:        "cache.trie",
+		Usage:       "Megabytes of memory allocated to caching the state read by block execution",
+		Value:       256,
+		Destination: &DefaultConfig.NodeCfg.TrieCache,
+	}
 )
 
 var (
@@ -445,6 +782,24 @@ var (
 		Value:       "",
 		Destination: &DefaultConfig.NodeCfg.JWTSecret,
 	}
+	AuthRPCApiFlag = &cli.StringFlag{
+		Name:        "authrpc.api",
+		Usage:       "API's offered over the authenticated RPC interface (default = all authenticated namespaces)",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.AuthApi,
+	}
+	AuthRPCCorsFlag = &cli.StringFlag{
+		Name:        "authrpc.corsdomain",
+		Usage:       "Comma separated list of domains from which to accept cross origin requests to the authenticated RPC interface",
+		Value:       "",
+		Destination: &DefaultConfig.NodeCfg.AuthCors,
+	}
+	AuthRPCVirtualHostsFlag = &cli.StringFlag{
+		Name:        "authrpc.vhosts",
+		Usage:       "Comma separated list of virtual hostnames from which to accept authenticated RPC requests. Accepts '*' wildcard.",
+		Value:       DefaultConfig.NodeCfg.AuthVirtualHosts,
+		Destination: &DefaultConfig.NodeCfg.AuthVirtualHosts,
+	}
 )
 
 var (
@@ -454,14 +809,30 @@ var (
 		Usage: "Comma separated list of accounts to unlock",
 		Value: "",
 	}
+	UnlockDurationFlag = &cli.DurationFlag{
+		Name:        "account.unlock.duration",
+		Usage:       "Duration the accounts of --account.unlock stay unlocked (0 = until the node stops)",
+		Destination: &DefaultConfig.NodeCfg.UnlockDuration,
+	}
+	ScryptNFlag = &cli.IntFlag{
+		Name:        "account.scrypt.n",
+		Usage:       "Scrypt N parameter of new key files, a power of 2 (default = that of the standard or light preset)",
+		Destination: &DefaultConfig.NodeCfg.ScryptN,
+	}
+	ScryptPFlag = &cli.IntFlag{
+		Name:        "account.scrypt.p",
+		Usage:       "Scrypt P parameter of new key files (default = that of the standard or light preset)",
+		Destination: &DefaultConfig.NodeCfg.ScryptP,
+	}
 	PasswordFileFlag = &cli.PathFlag{
 		Name:        "account.password",
 		Usage:       "Password file to use for non-interactive password input",
 		Destination: &DefaultConfig.NodeCfg.PasswordFile,
 	}
 	LightKDFFlag = &cli.BoolFlag{
-		Name:  "account.lightkdf",
-		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
+		Name:        "account.lightkdf",
+		Usage:       "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
+		Destination: &DefaultConfig.NodeCfg.UseLightweightKDF,
 	}
 	KeyStoreDirFlag = &cli.PathFlag{
 		Name:        "account.keystore",
@@ -469,6 +840,11 @@ var (
 		TakesFile:   true,
 		Destination: &DefaultConfig.NodeCfg.KeyStoreDir,
 	}
+	ExternalSignerFlag = &cli.StringFlag{
+		Name:        "signer",
+		Usage:       "External signer (IPC socket path, http:// or ws:// endpoint) listing the accounts and signing for them instead of the keystore",
+		Destination: &DefaultConfig.NodeCfg.ExternalSigner,
+	}
 	InsecureUnlockAllowedFlag = &cli.BoolFlag{
 		Name:        "account.allow.insecure.unlock",
 		Usage:       "Allow insecure account unlocking when account-related RPCs are exposed by http",
@@ -503,6 +879,100 @@ Please note that --` + MetricsHTTPFlag.Name + ` must be set to start the server.
 		// Category: flags.MetricsCategory,
 		Destination: &DefaultConfig.MetricsCfg.Port,
 	}
+
+	MetricsInfluxDBFlag = &cli.BoolFlag{
+		Name:        "metrics.influxdb",
+		Usage:       "Push metrics to an InfluxDB 1.x database",
+		Destination: &DefaultConfig.MetricsCfg.InfluxDB,
+	}
+	MetricsInfluxDBV2Flag = &cli.BoolFlag{
+		Name:        "metrics.influxdbv2",
+		Usage:       "Push metrics to an InfluxDB 2.x bucket",
+		Destination: &DefaultConfig.MetricsCfg.InfluxDBV2,
+	}
+	MetricsInfluxDBEndpointFlag = &cli.StringFlag{
+		Name:        "metrics.influxdb.endpoint",
+		Usage:       "InfluxDB API endpoint to push metrics to",
+		Value:       DefaultConfig.MetricsCfg.InfluxDBEndpoint,
+		Destination: &DefaultConfig.MetricsCfg.InfluxDBEndpoint,
+	}
+	MetricsInfluxDBDatabaseFlag = &cli.StringFlag{
+		Name:        "metrics.influxdb.database",
+		Usage:       "InfluxDB 1.x database name to push metrics to",
+		Value:       DefaultConfig.MetricsCfg.InfluxDBDatabase,
+		Destination: &DefaultConfig.MetricsCfg.InfluxDBDatabase,
+	}
+	MetricsInfluxDBUsernameFlag = &cli.StringFlag{
+		Name:        "metrics.influxdb.username",
+		Usage:       "Username to authorize access to the InfluxDB 1.x database",
+		Destination: &DefaultConfig.MetricsCfg.InfluxDBUsername,
+	}
+	MetricsInfluxDBPasswordFlag = &cli.StringFlag{
+		Name:        "metrics.influxdb.password",
+		Usage:       "Password to authorize access to the InfluxDB 1.x database",
+		Destination: &DefaultConfig.MetricsCfg.InfluxDBPassword,
+	}
+	MetricsInfluxDBTokenFlag = &cli.StringFlag{
+		Name:        "metrics.influxdb.token",
+		Usage:       "Token to authorize access to the InfluxDB 2.x bucket",
+		Destination: &DefaultConfig.MetricsCfg.InfluxDBToken,
+	}
+	MetricsInfluxDBBucketFlag = &cli.StringFlag{
+		Name:        "metrics.influxdb.bucket",
+		Usage:       "InfluxDB 2.x bucket to push metrics to",
+		Value:       DefaultConfig.MetricsCfg.InfluxDBBucket,
+		Destination: &DefaultConfig.MetricsCfg.InfluxDBBucket,
+	}
+	MetricsInfluxDBOrganizationFlag = &cli.StringFlag{
+		Name:        "metrics.influxdb.organization",
+		Usage:       "InfluxDB 2.x organization owning the bucket",
+		Value:       DefaultConfig.MetricsCfg.InfluxDBOrganization,
+		Destination: &DefaultConfig.MetricsCfg.InfluxDBOrganization,
+	}
+	MetricsOTLPEndpointFlag = &cli.StringFlag{
+		Name:        "metrics.otlp.endpoint",
+		Usage:       "OTLP/HTTP collector to push metrics to, e.g. http://localhost:4318",
+		Destination: &DefaultConfig.MetricsCfg.OTLPEndpoint,
+	}
+	MetricsOTLPHeadersFlag = &cli.StringFlag{
+		Name:        "metrics.otlp.headers",
+		Usage:       "Comma separated key=value headers sent to the OTLP collector, e.g. for authentication",
+		Destination: &DefaultConfig.MetricsCfg.OTLPHeaders,
+	}
+	MetricsPushIntervalFlag = &cli.DurationFlag{
+		Name:        "metrics.push.interval",
+		Usage:       "Interval between two pushes to InfluxDB or OTLP",
+		Value:       DefaultConfig.MetricsCfg.PushInterval,
+		Destination: &DefaultConfig.MetricsCfg.PushInterval,
+	}
+	MetricsTagsFlag = &cli.StringFlag{
+		Name:        "metrics.tags",
+		Usage:       "Comma separated key=value tags added to the pushed metrics, host and network default to the host name and the chain",
+		Destination: &DefaultConfig.MetricsCfg.Tags,
+	}
+
+	TracingFlag = &cli.BoolFlag{
+		Name:        "tracing",
+		Usage:       "Trace the stages of the block imports and export the spans over OTLP/HTTP",
+		Destination: &DefaultConfig.MetricsCfg.Tracing,
+	}
+	TracingEndpointFlag = &cli.StringFlag{
+		Name:        "tracing.endpoint",
+		Usage:       "OTLP/HTTP collector to export the traces to",
+		Value:       DefaultConfig.MetricsCfg.TracingEndpoint,
+		Destination: &DefaultConfig.MetricsCfg.TracingEndpoint,
+	}
+	TracingHeadersFlag = &cli.StringFlag{
+		Name:        "tracing.headers",
+		Usage:       "Comma separated key=value headers sent to the trace collector",
+		Destination: &DefaultConfig.MetricsCfg.TracingHeaders,
+	}
+	TracingSampleRatioFlag = &cli.Float64Flag{
+		Name:        "tracing.sample",
+		Usage:       "Fraction of the imported blocks traced, in (0, 1]",
+		Value:       DefaultConfig.MetricsCfg.TracingSampleRatio,
+		Destination: &DefaultConfig.MetricsCfg.TracingSampleRatio,
+	}
 )
 
 var (
@@ -511,43 +981,101 @@ var (
 		AuthRPCListenFlag,
 		AuthRPCPortFlag,
 		JWTSecretFlag,
+		AuthRPCApiFlag,
+		AuthRPCCorsFlag,
+		AuthRPCVirtualHostsFlag,
 	}
 	settingFlag = []cli.Flag{
 		DataDirFlag,
+		DBEngineFlag,
 		ChainFlag,
 		MinFreeDiskSpaceFlag,
+		WarnFreeDiskSpaceFlag,
+		ShutdownTimeoutFlag,
+		ReadyMinPeersFlag,
+		ReadyMaxBlockLagFlag,
+		StallTimeoutFlag,
+		ReorgAlertDepthFlag,
+		ReorgWebhookFlag,
+		DBCompressFlag,
+		DBCompactIntervalFlag,
+		DBCompactMinFreeDiskFlag,
+		DBReadOnlyFlag,
+		SyncModeFlag,
+		SyncCheckpointFlag,
+		LightServeFlag,
+		LightConfirmFlag,
+		GCModeFlag,
+		PruneHistoryFlag,
+		AncientThresholdFlag,
+		TxLookupLimitFlag,
+		TrieCacheFlag,
+		PreimagesFlag,
+		ParallelExecFlag,
+		EVMProfileFlag,
+		DevFlag,
+		DevPeriodFlag,
 	}
 	accountFlag = []cli.Flag{
 		PasswordFileFlag,
 		KeyStoreDirFlag,
 		LightKDFFlag,
+		ScryptNFlag,
+		ScryptPFlag,
+		ExternalSignerFlag,
 		InsecureUnlockAllowedFlag,
 		UnlockedAccountFlag,
+		UnlockDurationFlag,
 	}
 
 	metricsFlags = []cli.Flag{
 		MetricsEnabledFlag,
 		MetricsHTTPFlag,
 		MetricsPortFlag,
+		MetricsInfluxDBFlag,
+		MetricsInfluxDBV2Flag,
+		MetricsInfluxDBEndpointFlag,
+		MetricsInfluxDBDatabaseFlag,
+		MetricsInfluxDBUsernameFlag,
+		MetricsInfluxDBPasswordFlag,
+		MetricsInfluxDBTokenFlag,
+		MetricsInfluxDBBucketFlag,
+		MetricsInfluxDBOrganizationFlag,
+		MetricsOTLPEndpointFlag,
+		MetricsOTLPHeadersFlag,
+		MetricsPushIntervalFlag,
+		MetricsTagsFlag,
+		TracingFlag,
+		TracingEndpointFlag,
+		TracingHeadersFlag,
+		TracingSampleRatioFlag,
 	}
 
 	p2pFlags = []cli.Flag{
 		P2PNoDiscovery,
+		P2PNetRestrict,
 		P2PAllowList,
 		P2PBootstrapNode,
+		P2PDiscoveryDNS,
 		P2PDenyList,
 		P2PIP,
 		P2PHost,
 		P2PMaxPeers,
+		P2PMaxInboundPeers,
+		P2PMaxOutboundPeers,
+		P2PDialRatio,
 		P2PMetadata,
 		P2PStaticID,
 		P2PPrivKey,
+		P2PPrivKeyHex,
 		P2PHostDNS,
+		P2PNAT,
 		P2PRelayNode,
 		P2PStaticPeers,
 		P2PUDPPort,
 		P2PTCPPort,
 		P2PMinSyncPeers,
+		P2PMaxClockDisparity,
 	}
 
 	p2pLimitFlags = []cli.Flag{
@@ -555,4 +1083,31 @@ var (
 		P2PBlockBatchLimitBurstFactor,
 		P2PBlockBatchLimiterPeriod,
 	}
+
+	// forkOverrideFlags holds an --override.<fork> flag per hard fork.
+	forkOverrideFlags = makeForkOverrideFlags()
 )
+
+// makeForkOverrideFlags creates the flags rescheduling the hard forks of the
+// chain config, to test fork transitions on a copy of a network.
+func makeForkOverrideFlags() []cli.Flag {
+	flags := make([]cli.Flag, 0, len(params.ForkNames()))
+	for _, name := range params.ForkNames() {
+		name, unit := name, "block number"
+		if params.IsTimestampFork(name) {
+			unit = "block timestamp"
+		}
+		flags = append(flags, &cli.Uint64Flag{
+			Name:  "override." + name,
+			Usage: fmt.Sprintf("Manually specify the %s fork %s, overriding the chain config (testing only)", name, unit),
+			Action: func(_ *cli.Context, at uint64) error {
+				if DefaultConfig.NodeCfg.ForkOverrides == nil {
+					DefaultConfig.NodeCfg.ForkOverrides = make(map[string]uint64)
+				}
+				DefaultConfig.NodeCfg.ForkOverrides[name] = at
+				return nil
+			},
+		})
+	}
+	return flags
+}
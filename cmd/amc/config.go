@@ -32,6 +32,15 @@ var DefaultConfig = conf.Config{
 		HTTPPort:    "8545",
 		IPCPath:     "amc.ipc",
 		Miner:       false,
+
+		HTTPVirtualHosts: "localhost",
+		AuthVirtualHosts: "localhost",
+
+		RPCGasCap:     50000000,
+		RPCEVMTimeout: 5 * time.Second,
+
+		// Blocks are executed serially unless --exec.parallel is set.
+		ParallelExec: false,
 	},
 	NetworkCfg: conf.NetWorkConfig{
 		Bootstrapped: true,
@@ -46,7 +55,8 @@ var DefaultConfig = conf.Config{
 	},
 	PprofCfg: conf.PprofConfig{
 		MaxCpu:     0,
-		Port:       6060,
+		Addr:       "127.0.0.1",
+		Port:       6061,
 		TraceMutex: true,
 		TraceBlock: true,
 		Pprof:      false,
@@ -62,8 +72,15 @@ var DefaultConfig = conf.Config{
 		MaxReaders: 1000,
 	},
 	MetricsCfg: conf.MetricsConfig{
-		Port: 6060,
-		HTTP: "127.0.0.1",
+		Port:                 6060,
+		HTTP:                 "127.0.0.1",
+		InfluxDBEndpoint:     "http://localhost:8086",
+		InfluxDBDatabase:     "amc",
+		InfluxDBBucket:       "amc",
+		InfluxDBOrganization: "amc",
+		PushInterval:         10 * time.Second,
+		TracingEndpoint:      "http://localhost:4318",
+		TracingSampleRatio:   1,
 	},
 
 	P2PCfg: &conf.P2PConfig{P2PLimit: &conf.P2PLimit{}},
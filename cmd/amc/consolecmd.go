@@ -0,0 +1,135 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/amazechain/amc/cmd/utils"
+	"github.com/amazechain/amc/console"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	jsPathFlag = &cli.StringFlag{
+		Name:  "jspath",
+		Usage: "JavaScript root path for `loadScript`",
+		Value: ".",
+	}
+	execFlag = &cli.StringFlag{
+		Name:  "exec",
+		Usage: "Execute JavaScript statement and exit",
+	}
+	preloadFlag = &cli.StringFlag{
+		Name:  "preload",
+		Usage: "Comma separated list of JavaScript files to preload into the console",
+	}
+
+	consoleFlags = []cli.Flag{jsPathFlag, execFlag, preloadFlag}
+
+	consoleCommand = &cli.Command{
+		Name:   "console",
+		Usage:  "Start an interactive JavaScript environment",
+		Action: localConsole,
+		Flags:  consoleFlags,
+		Description: `
+The console command starts the node and an interactive JavaScript environment
+attached to it. Every RPC namespace of the node is bound as a global object, so
+eth.blockNumber() calls eth_blockNumber; request(method, ...params) sends any
+other call. The console also offers console.log, loadScript and sleep.
+The node stops when the console exits.`,
+	}
+
+	attachCommand = &cli.Command{
+		Name:      "attach",
+		Usage:     "Start an interactive JavaScript environment (connect to node)",
+		ArgsUsage: "[endpoint]",
+		Action:    remoteConsole,
+		Flags:     append([]cli.Flag{DataDirFlag}, consoleFlags...),
+		Description: `
+The attach command opens the JavaScript console on a running node. The endpoint
+is an IPC socket path or a ws:// or http:// URL, it defaults to the IPC socket
+in the data directory.`,
+	}
+)
+
+// localConsole starts a new node and attaches a console to it in-process.
+func localConsole(ctx *cli.Context) error {
+	stack, err := makeNode(ctx)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+	runNode(ctx, stack, true)
+
+	client := stack.Attach()
+	defer client.Close()
+	return runConsole(ctx, client, stack.InstanceDir())
+}
+
+// remoteConsole attaches a console to a node that is already running.
+func remoteConsole(ctx *cli.Context) error {
+	if ctx.Args().Len() > 1 {
+		utils.Fatalf("This command takes at most one argument.")
+	}
+	endpoint := ctx.Args().First()
+	if endpoint == "" {
+		endpoint = filepath.Join(DefaultConfig.NodeCfg.DataDir, DefaultConfig.NodeCfg.IPCPath)
+	}
+	client, err := jsonrpc.Dial(endpoint)
+	if err != nil {
+		utils.Fatalf("Unable to attach to remote amc: %v", err)
+	}
+	defer client.Close()
+
+	// The history is only kept next to a local node.
+	var datadir string
+	if !strings.Contains(endpoint, "://") {
+		datadir = filepath.Dir(endpoint)
+	}
+	return runConsole(ctx, client, datadir)
+}
+
+// runConsole runs the --exec statement, or else the interactive console.
+func runConsole(ctx *cli.Context, client *jsonrpc.Client, datadir string) error {
+	var preload []string
+	for _, file := range strings.Split(ctx.String(preloadFlag.Name), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			preload = append(preload, file)
+		}
+	}
+	c, err := console.New(console.Config{
+		DataDir: datadir,
+		DocRoot: ctx.String(jsPathFlag.Name),
+		Client:  client,
+		Preload: preload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start the JavaScript console: %v", err)
+	}
+	defer c.Stop()
+
+	if script := ctx.String(execFlag.Name); script != "" {
+		return c.Evaluate(script)
+	}
+	c.Welcome()
+	c.Interactive()
+	return nil
+}
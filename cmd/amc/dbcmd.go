@@ -0,0 +1,242 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/amazechain/amc/cmd/utils"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/node"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	dbCommand = &cli.Command{
+		Name:  "db",
+		Usage: "Inspect the chain database",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "inspect",
+				Usage:  "Report the entries and disk space of every table",
+				Action: inspectDatabase,
+				Flags: []cli.Flag{
+					DataDirFlag,
+				},
+				Description: `
+The inspect command walks every table of the chain database and prints the
+number of entries and the disk space of each one, grouped by category: headers,
+bodies, receipts, state, state history, indices and the rest. Counting reads
+the whole database, which takes a while on a large one.`,
+			},
+			{
+				Name:   "compact",
+				Usage:  "Compact the chain database of a stopped node",
+				Action: compactDatabase,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					DBCompactMinFreeDiskFlag,
+				},
+				Description: `
+The compact command reclaims the disk space held by deleted and overwritten
+entries. A pebble database is compacted in place, table by table; an mdbx one
+is copied into a new file replacing the old one, which needs room for the copy.
+It refuses to run if less than --db.compact.minfreedisk GB would be left free.`,
+			},
+			{
+				Name:      "export-preimages",
+				Usage:     "Export the recorded preimages to a file",
+				ArgsUsage: "<file>",
+				Action:    exportPreimages,
+				Flags: []cli.Flag{
+					DataDirFlag,
+				},
+				Description: `
+The export-preimages command writes the preimages a node recorded with
+--cache.preimages to the given file, one hash and its preimage per line, both
+in hex.`,
+			},
+			{
+				Name:      "backup",
+				Usage:     "Back up the chain database, keystore and network key",
+				ArgsUsage: "<dest>",
+				Action:    backupDatabase,
+				Flags: []cli.Flag{
+					DataDirFlag,
+				},
+				Description: `
+The backup command writes a consistent snapshot of the chain database to the
+given directory, which must not exist, along with the keystore and the network
+key. If the node is running, the snapshot is taken by the node itself through
+its IPC endpoint and it keeps processing blocks meanwhile; otherwise the
+database is opened read-only. A pebble snapshot hard links the database files
+when the destination is on the same filesystem.`,
+			},
+			{
+				Name:      "restore",
+				Usage:     "Restore a backup into the data directory",
+				ArgsUsage: "<src>",
+				Action:    restoreDatabase,
+				Flags: []cli.Flag{
+					DataDirFlag,
+				},
+				Description: `
+The restore command copies a backup made by the backup command into the data
+directory of a stopped node. It refuses to overwrite an existing chain
+database, key file or network key.`,
+			},
+			{
+				Name:   "stat",
+				Usage:  "Print the statistics of the database engine",
+				Action: databaseStats,
+				Flags: []cli.Flag{
+					DataDirFlag,
+				},
+			},
+		},
+	}
+)
+
+// inspectDatabase prints the size of the tables of the chain database.
+func inspectDatabase(ctx *cli.Context) error {
+	stats, err := node.InspectDatabase(ctx.Context, &DefaultConfig)
+	if err != nil {
+		utils.Fatalf("Inspect error: %v", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Category\tTable\tEntries\tSize\t")
+
+	var (
+		entries, size uint64
+		total         uint64
+	)
+	for i, stat := range stats {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t\n", stat.Category, stat.Name, stat.Entries, types.StorageSize(stat.Size))
+		entries, size = entries+stat.Entries, size+stat.Size
+		if i == len(stats)-1 || stats[i+1].Category != stat.Category {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t\n", stat.Category, "total", entries, types.StorageSize(size))
+			fmt.Fprintln(w, "\t\t\t\t")
+			total += size
+			entries, size = 0, 0
+		}
+	}
+	fmt.Fprintf(w, "\t%s\t\t%s\t\n", "Total", types.StorageSize(total))
+	return w.Flush()
+}
+
+// compactDatabase compacts the chain database and reports the space reclaimed.
+func compactDatabase(ctx *cli.Context) error {
+	before, after, err := node.CompactDatabase(ctx.Context, &DefaultConfig)
+	if err != nil {
+		utils.Fatalf("Compact error: %v", err)
+	}
+	reclaimed := types.StorageSize(0)
+	if after < before {
+		reclaimed = types.StorageSize(before - after)
+	}
+	log.Info("Database compacted", "before", types.StorageSize(before), "after", types.StorageSize(after), "reclaimed", reclaimed)
+	return nil
+}
+
+// exportPreimages writes the recorded preimages to the file given as argument.
+func exportPreimages(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	f, err := os.Create(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	defer f.Close()
+	count, err := node.ExportPreimages(ctx.Context, &DefaultConfig, f)
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	log.Info("Exported preimages", "count", count, "file", ctx.Args().First())
+	return nil
+}
+
+// databaseStats prints what the database engine reports about itself.
+func databaseStats(ctx *cli.Context) error {
+	stats, err := node.DatabaseStats(ctx.Context, &DefaultConfig)
+	if err != nil {
+		utils.Fatalf("Stat error: %v", err)
+	}
+	fmt.Print(stats)
+	return nil
+}
+
+// backupDatabase backs up the data directory, through the node if it is running.
+func backupDatabase(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	dest, err := filepath.Abs(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Backup error: %v", err)
+	}
+	if client := dialRunningNode(); client != nil {
+		defer client.Close()
+		log.Info("Backing up through the running node", "dest", dest)
+		if err := client.CallContext(ctx.Context, nil, "admin_backup", dest); err != nil {
+			utils.Fatalf("Backup error: %v", err)
+		}
+		log.Info("Backup complete", "dest", dest)
+		return nil
+	}
+	if err := node.BackupDatabase(ctx.Context, &DefaultConfig, dest); err != nil {
+		utils.Fatalf("Backup error: %v", err)
+	}
+	return nil
+}
+
+// restoreDatabase restores a backup into the data directory of a stopped node.
+func restoreDatabase(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	if client := dialRunningNode(); client != nil {
+		client.Close()
+		utils.Fatalf("Restore error: the node is running, stop it first")
+	}
+	if err := node.RestoreDatabase(&DefaultConfig, ctx.Args().First()); err != nil {
+		utils.Fatalf("Restore error: %v", err)
+	}
+	return nil
+}
+
+// dialRunningNode connects to the IPC endpoint of a node running on the data
+// directory, nil if there is none.
+func dialRunningNode() *jsonrpc.Client {
+	if DefaultConfig.NodeCfg.IPCPath == "" {
+		return nil
+	}
+	endpoint := filepath.Join(DefaultConfig.NodeCfg.DataDir, DefaultConfig.NodeCfg.IPCPath)
+	if _, err := os.Stat(endpoint); err != nil {
+		return nil
+	}
+	client, err := jsonrpc.Dial(endpoint)
+	if err != nil {
+		return nil
+	}
+	return client
+}
@@ -0,0 +1,246 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/amazechain/amc/cmd/utils"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/internal/p2p/dnsdisc"
+	"github.com/amazechain/amc/internal/p2p/enode"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	treeNodesFile = "nodes.json"
+	treeMetaFile  = "enrtree-info.json"
+)
+
+var (
+	dnsDomainFlag = &cli.StringFlag{
+		Name:  "domain",
+		Usage: "Domain name of the tree",
+	}
+	dnsSeqFlag = &cli.UintFlag{
+		Name:  "seq",
+		Usage: "New sequence number of the tree",
+	}
+
+	dnsCommand = &cli.Command{
+		Name:  "dns",
+		Usage: "Manage DNS discovery trees",
+		Description: `
+A DNS discovery tree publishes a signed list of node records as TXT records
+under a domain. Nodes started with --discovery.dns enrtree://<key>@<domain>
+resolve it periodically and dial the nodes it holds.
+
+A tree directory holds the node records in nodes.json, a JSON list of enr:
+strings, and the signed tree metadata in enrtree-info.json.`,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "sign",
+				Usage:     "Sign the tree in a directory",
+				ArgsUsage: "<tree-dir> <key-file>",
+				Action:    dnsSign,
+				Flags: []cli.Flag{
+					dnsDomainFlag,
+					dnsSeqFlag,
+				},
+				Description: `
+Builds the tree of the records in nodes.json and the links of
+enrtree-info.json, signs it with the hex-encoded secp256k1 key in the key file
+and prints the enrtree:// URL of the tree. The sequence number is increased
+unless --seq is given.`,
+			},
+			{
+				Name:      "to-txt",
+				Usage:     "Print the TXT records of a signed tree",
+				ArgsUsage: "<tree-dir> [<output-file>]",
+				Action:    dnsToTXT,
+				Description: `
+Writes the TXT records to deploy as a JSON object mapping every name to its
+record, to the output file or else to the standard output.`,
+			},
+			{
+				Name:      "sync",
+				Usage:     "Download a tree",
+				ArgsUsage: "<url> [<tree-dir>]",
+				Action:    dnsSync,
+				Description: `
+Resolves the whole tree at the enrtree:// URL, checking its signature, and
+writes it to the tree directory if one is given.`,
+			},
+		},
+	}
+)
+
+// dnsDefinition is the content of a tree directory.
+type dnsDefinition struct {
+	Meta  dnsMetaJSON
+	Nodes []*enode.Node
+}
+
+type dnsMetaJSON struct {
+	URL          string    `json:"url,omitempty"`
+	Seq          uint      `json:"seq"`
+	Sig          string    `json:"signature,omitempty"`
+	Links        []string  `json:"links"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+func dnsSign(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		utils.Fatalf("This command requires a tree directory and a key file.")
+	}
+	dir, keyfile := ctx.Args().Get(0), ctx.Args().Get(1)
+	def, err := loadTreeDefinition(dir)
+	if err != nil {
+		utils.Fatalf("Could not load tree: %v", err)
+	}
+	domain := ctx.String(dnsDomainFlag.Name)
+	if domain == "" {
+		if def.Meta.URL == "" {
+			utils.Fatalf("The tree was never signed, --%s is required.", dnsDomainFlag.Name)
+		}
+		if domain, _, err = dnsdisc.ParseURL(def.Meta.URL); err != nil {
+			utils.Fatalf("Invalid tree URL: %v", err)
+		}
+	}
+	key, err := crypto.LoadECDSA(keyfile)
+	if err != nil {
+		utils.Fatalf("Could not load key: %v", err)
+	}
+	seq := def.Meta.Seq + 1
+	if ctx.IsSet(dnsSeqFlag.Name) {
+		seq = ctx.Uint(dnsSeqFlag.Name)
+	}
+	t, err := dnsdisc.MakeTree(seq, def.Nodes, def.Meta.Links)
+	if err != nil {
+		utils.Fatalf("Could not build tree: %v", err)
+	}
+	url, err := t.Sign(key, domain)
+	if err != nil {
+		utils.Fatalf("Could not sign tree: %v", err)
+	}
+	def.Meta.URL, def.Meta.Seq, def.Meta.Sig = url, t.Seq(), t.Signature()
+	def.Meta.LastModified = time.Now()
+	if err := writeJSON(filepath.Join(dir, treeMetaFile), &def.Meta); err != nil {
+		utils.Fatalf("Could not write tree: %v", err)
+	}
+	fmt.Println(url)
+	return nil
+}
+
+func dnsToTXT(ctx *cli.Context) error {
+	if ctx.Args().Len() < 1 || ctx.Args().Len() > 2 {
+		utils.Fatalf("This command requires a tree directory and an optional output file.")
+	}
+	def, err := loadTreeDefinition(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Could not load tree: %v", err)
+	}
+	if def.Meta.URL == "" || def.Meta.Sig == "" {
+		utils.Fatalf("The tree isn't signed.")
+	}
+	domain, pubkey, err := dnsdisc.ParseURL(def.Meta.URL)
+	if err != nil {
+		utils.Fatalf("Invalid tree URL: %v", err)
+	}
+	t, err := dnsdisc.MakeTree(def.Meta.Seq, def.Nodes, def.Meta.Links)
+	if err != nil {
+		utils.Fatalf("Could not build tree: %v", err)
+	}
+	if err := t.SetSignature(pubkey, def.Meta.Sig); err != nil {
+		utils.Fatalf("The signature doesn't match the tree, sign it again: %v", err)
+	}
+	records := t.ToTXT(domain)
+	if ctx.Args().Len() == 2 {
+		return writeJSON(ctx.Args().Get(1), records)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+func dnsSync(ctx *cli.Context) error {
+	if ctx.Args().Len() < 1 || ctx.Args().Len() > 2 {
+		utils.Fatalf("This command requires a tree URL and an optional directory.")
+	}
+	url := ctx.Args().First()
+	t, err := dnsdisc.NewClient(dnsdisc.Config{}).SyncTree(url)
+	if err != nil {
+		utils.Fatalf("Could not sync tree: %v", err)
+	}
+	nodes := t.Nodes()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID().String() < nodes[j].ID().String() })
+	fmt.Printf("%s: seq %d, %d nodes, %d links\n", url, t.Seq(), len(nodes), len(t.Links()))
+	if ctx.Args().Len() == 1 {
+		return nil
+	}
+	dir := ctx.Args().Get(1)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	meta := dnsMetaJSON{URL: url, Seq: t.Seq(), Sig: t.Signature(), Links: t.Links(), LastModified: time.Now()}
+	if err := writeJSON(filepath.Join(dir, treeNodesFile), nodes); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(dir, treeMetaFile), &meta)
+}
+
+// loadTreeDefinition reads a tree directory. The metadata file is optional
+// before the tree is first signed.
+func loadTreeDefinition(dir string) (*dnsDefinition, error) {
+	def := new(dnsDefinition)
+	data, err := os.ReadFile(filepath.Join(dir, treeNodesFile))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &def.Nodes); err != nil {
+		return nil, fmt.Errorf("%s: %w", treeNodesFile, err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, treeMetaFile))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &def.Meta); err != nil {
+			return nil, fmt.Errorf("%s: %w", treeMetaFile, err)
+		}
+	}
+	for _, l := range def.Meta.Links {
+		if _, _, err := dnsdisc.ParseURL(l); err != nil {
+			return nil, fmt.Errorf("invalid link %q: %w", l, err)
+		}
+	}
+	return def, nil
+}
+
+func writeJSON(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/amazechain/amc/conf"
+	"github.com/urfave/cli/v2"
+)
+
+var dumpConfigCommand = &cli.Command{
+	Name:      "dumpconfig",
+	Usage:     "Export the effective configuration in TOML",
+	ArgsUsage: "[<file>]",
+	Action:    dumpConfig,
+	Description: `
+Writes the configuration the node would run with, that of --config with the
+command line flags applied over it, to the given file or else to stdout. The
+output can be edited and passed back with --config.`,
+}
+
+// loadConfigFile loads the TOML file of --config into DefaultConfig, before
+// the node or any command reads it.
+//
+// The flags have already written their values, defaults included, into
+// DefaultConfig. Those given on the command line are read first and set
+// again once the file is decoded, so that they override it while the file
+// overrides the defaults of the others.
+func loadConfigFile(ctx *cli.Context) error {
+	file := ctx.String(ConfigFileFlag.Name)
+	if file == "" {
+		return nil
+	}
+	if len(cfgFile) > 0 {
+		return errors.New("--config and --blockchain can't be used together")
+	}
+	type setFlag struct {
+		flag  cli.Flag
+		value string
+	}
+	var set []setFlag
+	for _, f := range ctx.App.Flags {
+		name := f.Names()[0]
+		if !ctx.IsSet(name) || name == ConfigFileFlag.Name {
+			continue
+		}
+		// The list flags aren't bound to DefaultConfig, see applyListFlags.
+		if _, ok := f.(*cli.StringSliceFlag); ok {
+			continue
+		}
+		set = append(set, setFlag{flag: f, value: fmt.Sprint(ctx.Value(name))})
+	}
+	if err := conf.LoadTOMLConfig(file, &DefaultConfig); err != nil {
+		return err
+	}
+	for _, s := range set {
+		fs := flag.NewFlagSet(s.flag.Names()[0], flag.ContinueOnError)
+		if err := s.flag.Apply(fs); err != nil {
+			return err
+		}
+		if err := fs.Set(s.flag.Names()[0], s.value); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s: %v", s.value, s.flag.Names()[0], err)
+		}
+	}
+	return nil
+}
+
+// applyListFlags copies the flags kept outside DefaultConfig into it. Over a
+// --config file, only those given on the command line are copied.
+func applyListFlags(ctx *cli.Context) {
+	fromFile := ctx.IsSet(ConfigFileFlag.Name)
+	apply := func(name string, value *cli.StringSlice, dst *[]string) {
+		if !fromFile || ctx.IsSet(name) {
+			*dst = value.Value()
+		}
+	}
+	apply("p2p.listen", listenAddress, &DefaultConfig.NetworkCfg.ListenersAddress)
+	apply("p2p.bootstrap", bootstraps, &DefaultConfig.NetworkCfg.BootstrapPeers)
+	if len(privateKey) > 0 {
+		DefaultConfig.NetworkCfg.LocalPeerKey = privateKey
+	}
+
+	apply("p2p.peer", p2pStaticPeers, &DefaultConfig.P2PCfg.StaticPeers)
+	apply("p2p.bootstrap-node", p2pBootstrapNode, &DefaultConfig.P2PCfg.BootstrapNodeAddr)
+	apply("p2p.denylist", p2pDenyList, &DefaultConfig.P2PCfg.DenyListCIDR)
+	apply("discovery.dns", p2pDiscoveryDNS, &DefaultConfig.P2PCfg.DiscoveryDNS)
+
+	//
+	DefaultConfig.P2PCfg.DataDir = DefaultConfig.NodeCfg.DataDir
+}
+
+func dumpConfig(ctx *cli.Context) error {
+	if len(cfgFile) > 0 {
+		if err := conf.LoadConfigFromFile(cfgFile, &DefaultConfig); err != nil {
+			return err
+		}
+	} else {
+		applyListFlags(ctx)
+	}
+	out := os.Stdout
+	if ctx.NArg() > 0 {
+		fd, err := os.OpenFile(ctx.Args().First(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		out = fd
+	}
+	return conf.EncodeTOMLConfig(out, &DefaultConfig)
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/amazechain/amc/cmd/utils"
+	"github.com/amazechain/amc/internal/era"
+	"github.com/amazechain/amc/internal/node"
+	"github.com/amazechain/amc/log"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	eraCommand = &cli.Command{
+		Name:  "era",
+		Usage: "Manage immutable archives of the finalized chain",
+		Description: `
+Era archives hold fixed ranges of finalized blocks with their receipts, and
+are checksummed so that they can be shared out of band and verified without
+trusting where they came from.`,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Export finalized blocks into archives",
+				ArgsUsage: "<dir> [<blockNumFirst> <blockNumLast>]",
+				Action:    exportEra,
+				Flags: []cli.Flag{
+					DataDirFlag,
+				},
+				Description: `
+Writes one archive per range of 8192 blocks, aligned to multiples of it, along
+with a checksums.txt file. Every finalized block is exported unless a range is
+given.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import the blocks of the archives in a directory",
+				ArgsUsage: "<dir>",
+				Action:    importEra,
+				Flags: []cli.Flag{
+					DataDirFlag,
+				},
+				Description: `
+Verifies every archive before executing its blocks. Blocks already in the chain
+are skipped.`,
+			},
+			{
+				Name:      "verify",
+				Usage:     "Verify the archives in a directory",
+				ArgsUsage: "<dir>",
+				Action:    verifyEra,
+				Description: `
+Checks the checksum of every archive, that its blocks link and match the
+transaction and receipt roots of their headers, and that consecutive archives
+link to each other. No database is needed.`,
+			},
+		},
+	}
+)
+
+func exportEra(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 && ctx.Args().Len() != 3 {
+		utils.Fatalf("This command requires a directory and an optional block range.")
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	chain := stack.BlockChain()
+	first, last := uint64(0), uint64(0)
+	if ctx.Args().Len() == 3 {
+		if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer")
+		}
+		if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer")
+		}
+	} else if last, err = era.Finalized(chain); err != nil {
+		return err
+	}
+	if err := era.Export(chain, ctx.Args().First(), first, last); err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	return nil
+}
+
+func importEra(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, err := node.NewNode(ctx, &DefaultConfig)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	if err := era.Import(ctx.Context, stack.BlockChain(), ctx.Args().First()); err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	return nil
+}
+
+func verifyEra(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	if err := era.VerifyDir(ctx.Args().First()); err != nil {
+		utils.Fatalf("Verification failed: %v", err)
+	}
+	log.Info("All archives verified")
+	return nil
+}
@@ -0,0 +1,303 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/tracers/logger"
+	"github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/internal/vm/runtime"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/amazechain/amc/tests"
+	"github.com/holiman/uint256"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	evmTraceFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "Write an EIP-3155 trace of the execution to stderr, one JSON object per line",
+	}
+	evmMemoryFlag = &cli.BoolFlag{
+		Name:  "trace.memory",
+		Usage: "Include the memory in the trace",
+	}
+	evmNoStackFlag = &cli.BoolFlag{
+		Name:  "trace.nostack",
+		Usage: "Leave the stack out of the trace",
+	}
+	evmReturnDataFlag = &cli.BoolFlag{
+		Name:  "trace.returndata",
+		Usage: "Include the return data in the trace",
+	}
+	evmForkFlag = &cli.StringFlag{
+		Name:  "fork",
+		Usage: "Fork rules to execute with, as named in the test fixtures",
+		Value: "Cancun",
+	}
+
+	evmCodeFlag = &cli.StringFlag{
+		Name:  "code",
+		Usage: "Hex bytecode to execute",
+	}
+	evmCodeFileFlag = &cli.StringFlag{
+		Name:  "codefile",
+		Usage: "File holding the hex bytecode to execute, - for stdin",
+	}
+	evmInputFlag = &cli.StringFlag{
+		Name:  "input",
+		Usage: "Hex call data",
+	}
+	evmGasFlag = &cli.Uint64Flag{
+		Name:  "gas",
+		Usage: "Gas limit of the execution",
+		Value: 10000000,
+	}
+	evmValueFlag = &cli.Uint64Flag{
+		Name:  "value",
+		Usage: "Value sent with the call, in wei",
+	}
+	evmSenderFlag = &cli.StringFlag{
+		Name:  "sender",
+		Usage: "Address the code is called from",
+		Value: "0x000000000000000000000000000000000000c0de",
+	}
+	evmCreateFlag = &cli.BoolFlag{
+		Name:  "create",
+		Usage: "Run the code as init code, creating a contract",
+	}
+	evmForkFilterFlag = &cli.StringFlag{
+		Name:  "statetest.fork",
+		Usage: "Only run the subtests of this fork",
+	}
+
+	evmTraceFlags = []cli.Flag{evmTraceFlag, evmMemoryFlag, evmNoStackFlag, evmReturnDataFlag}
+
+	evmCommand = &cli.Command{
+		Name:  "evm",
+		Usage: "Execute EVM bytecode or state tests outside of a node",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "run",
+				Usage:     "Execute bytecode against an empty state",
+				ArgsUsage: "[code]",
+				Action:    evmRun,
+				Flags:     append([]cli.Flag{evmCodeFlag, evmCodeFileFlag, evmInputFlag, evmGasFlag, evmValueFlag, evmSenderFlag, evmCreateFlag, evmForkFlag}, evmTraceFlags...),
+				Description: `
+The run command executes the given bytecode in a fresh in-memory state and
+prints what it returned. With --json, each executed opcode is written to
+stderr as an EIP-3155 trace line, followed by a summary of the execution,
+using the struct logger of the debug tracing API.`,
+			},
+			{
+				Name:      "statetest",
+				Usage:     "Execute the transactions of state test fixtures",
+				ArgsUsage: "<file>...",
+				Action:    evmStateTest,
+				Flags:     append([]cli.Flag{evmForkFilterFlag}, evmTraceFlags...),
+				Description: `
+The statetest command runs every subtest of the GeneralStateTests fixtures
+given, and prints their results as a JSON list on stdout. With --json, the
+EIP-3155 trace of each subtest is written to stderr and closed by the state
+root line, for differential fuzzing against other clients.
+
+The flat state of amc isn't hashed into an Ethereum state root: a subtest
+passes when its transaction is rejected exactly if the fixture expects it,
+and the root printed is the hash of the flat post state.`,
+			},
+		},
+	}
+)
+
+// evmConfig returns the EVM config tracing to stderr, if asked to.
+func evmConfig(ctx *cli.Context, out io.Writer) vm.Config {
+	if !ctx.Bool(evmTraceFlag.Name) {
+		return vm.Config{}
+	}
+	return vm.Config{
+		Debug: true,
+		Tracer: logger.NewJSONLogger(&logger.Config{
+			EnableMemory:     ctx.Bool(evmMemoryFlag.Name),
+			DisableStack:     ctx.Bool(evmNoStackFlag.Name),
+			EnableReturnData: ctx.Bool(evmReturnDataFlag.Name),
+		}, out),
+	}
+}
+
+// evmCode reads the bytecode from the flags or the first argument.
+func evmCode(ctx *cli.Context) ([]byte, error) {
+	var hexcode string
+	switch {
+	case ctx.String(evmCodeFlag.Name) != "":
+		hexcode = ctx.String(evmCodeFlag.Name)
+	case ctx.String(evmCodeFileFlag.Name) == "-":
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		hexcode = string(src)
+	case ctx.String(evmCodeFileFlag.Name) != "":
+		src, err := os.ReadFile(ctx.String(evmCodeFileFlag.Name))
+		if err != nil {
+			return nil, err
+		}
+		hexcode = string(src)
+	case ctx.Args().Len() > 0:
+		hexcode = ctx.Args().First()
+	default:
+		return nil, fmt.Errorf("no code given, use --code, --codefile or an argument")
+	}
+	return hexutil.Decode("0x" + strings.TrimPrefix(strings.TrimSpace(hexcode), "0x"))
+}
+
+// evmRun executes the bytecode given on the command line.
+func evmRun(ctx *cli.Context) error {
+	code, err := evmCode(ctx)
+	if err != nil {
+		return fmt.Errorf("invalid code: %w", err)
+	}
+	var input []byte
+	if s := ctx.String(evmInputFlag.Name); s != "" {
+		if input, err = hexutil.Decode("0x" + strings.TrimPrefix(s, "0x")); err != nil {
+			return fmt.Errorf("invalid input: %w", err)
+		}
+	}
+	sender, err := types.HexToString(ctx.String(evmSenderFlag.Name))
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	chainConfig, ok := tests.Forks[ctx.String(evmForkFlag.Name)]
+	if !ok {
+		return fmt.Errorf("unknown fork %q, available: %s", ctx.String(evmForkFlag.Name), strings.Join(tests.AvailableForks(), ", "))
+	}
+
+	db := memdb.New()
+	defer db.Close()
+	tx, err := db.BeginRw(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	value := uint256.NewInt(ctx.Uint64(evmValueFlag.Name))
+	statedb := state.New(state.NewPlainStateReader(tx))
+	statedb.AddBalance(sender, value)
+	cfg := &runtime.Config{
+		ChainConfig: chainConfig,
+		Origin:      sender,
+		BlockNumber: new(big.Int),
+		Time:        new(big.Int),
+		GasLimit:    ctx.Uint64(evmGasFlag.Name),
+		Value:       value,
+		EVMConfig:   evmConfig(ctx, os.Stderr),
+		State:       statedb,
+	}
+	var output []byte
+	if ctx.Bool(evmCreateFlag.Name) {
+		output, _, _, err = runtime.Create(append(code, input...), cfg, 0)
+	} else {
+		output, _, err = runtime.Execute(code, input, cfg, 0)
+	}
+	fmt.Printf("%#x\n", output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+	return nil
+}
+
+// stateTestResult is the outcome of a subtest, as printed by the statetest command.
+type stateTestResult struct {
+	Name   string     `json:"name"`
+	Pass   bool       `json:"pass"`
+	Root   types.Hash `json:"stateRoot"`
+	Fork   string     `json:"fork"`
+	Error  string     `json:"error,omitempty"`
+	Output string     `json:"output,omitempty"`
+}
+
+// evmStateTest runs the subtests of the state test files.
+func evmStateTest(ctx *cli.Context) error {
+	if ctx.Args().Len() == 0 {
+		return fmt.Errorf("no state test file given")
+	}
+	var results []stateTestResult
+	for _, path := range ctx.Args().Slice() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var fixtures map[string]tests.StateTest
+		if err := json.Unmarshal(src, &fixtures); err != nil {
+			return fmt.Errorf("invalid state test %s: %w", path, err)
+		}
+		names := make([]string, 0, len(fixtures))
+		for name := range fixtures {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			test := fixtures[name]
+			subtests := test.Subtests()
+			sort.Slice(subtests, func(i, j int) bool {
+				if subtests[i].Fork != subtests[j].Fork {
+					return subtests[i].Fork < subtests[j].Fork
+				}
+				return subtests[i].Index < subtests[j].Index
+			})
+			for _, st := range subtests {
+				if fork := ctx.String(evmForkFilterFlag.Name); fork != "" && fork != st.Fork {
+					continue
+				}
+				results = append(results, runStateSubtest(ctx, &test, name, st))
+			}
+		}
+	}
+	out, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// runStateSubtest runs a subtest, closing its trace with the state root.
+func runStateSubtest(ctx *cli.Context, test *tests.StateTest, name string, st tests.StateSubtest) stateTestResult {
+	result := stateTestResult{Name: name, Fork: st.Fork, Pass: true}
+	res, err := test.Run(st, evmConfig(ctx, os.Stderr))
+	if err != nil {
+		result.Pass, result.Error = false, err.Error()
+	}
+	if res != nil {
+		result.Root, result.Output = res.Root, hexutil.Encode(res.Output)
+	}
+	if ctx.Bool(evmTraceFlag.Name) {
+		fmt.Fprintf(os.Stderr, "{\"stateRoot\": \"%#x\"}\n", result.Root)
+	}
+	return result
+}
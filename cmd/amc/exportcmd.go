@@ -37,10 +37,18 @@ import (
 
 var (
 	exportCommand = &cli.Command{
-		Name:        "export",
-		Usage:       "Export AmazeChain data",
-		ArgsUsage:   "",
-		Description: ``,
+		Name:      "export",
+		Usage:     "Export AmazeChain data",
+		ArgsUsage: "<filename> [<blockNumFirst> <blockNumLast>]",
+		Action:    exportChain,
+		Flags: []cli.Flag{
+			DataDirFlag,
+		},
+		Description: `
+Without a subcommand, export writes the blockchain to a file that the import
+command reads back. The whole chain is exported unless the first and last
+block numbers are given. If the file name ends with .gz, the output is gzip
+compressed.`,
 		Subcommands: []*cli.Command{
 			{
 				Name:      "txs",
@@ -3,19 +3,25 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/amazechain/amc/cmd/utils"
 	"github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/types"
 	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal"
 	"github.com/amazechain/amc/internal/node"
 	"github.com/amazechain/amc/log"
 	"github.com/amazechain/amc/modules/rawdb"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/urfave/cli/v2"
 	"os"
+	"path/filepath"
 )
 
+// errGenesisExists aborts the write of a genesis the database already holds.
+var errGenesisExists = errors.New("genesis already written")
+
 var (
 	initCommand = &cli.Command{
 		Name:      "init",
@@ -30,7 +36,20 @@ The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
 participating.
 
-It expects the genesis file as argument.`,
+It expects the genesis file as argument. The file is validated first: its
+chain config and consensus section, the initial signers listed in miners and
+the balances of alloc. The data dir must not hold another genesis; running
+init again with the same file does nothing.
+
+A large allocation can be kept out of the genesis file: allocFile names a file
+in the format of alloc, with the accounts sorted by address, which is streamed
+into the state with progress reports instead of being loaded in memory. It is
+read twice, once to compute the genesis hash and check the file, once more
+to write the state.
+
+The node then has to be started with --chain private: the database genesis
+is checked on every start, and a node refuses to run one that isn't that of
+the selected chain.`,
 	}
 )
 
@@ -58,6 +77,16 @@ func initGenesis(cliCtx *cli.Context) error {
 	if err := json.NewDecoder(file).Decode(genesis); err != nil {
 		utils.Fatalf("invalid genesis file: %v", err)
 	}
+	if err := genesis.Validate(); err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
+	if genesis.AllocFile != "" && !filepath.IsAbs(genesis.AllocFile) {
+		genesis.AllocFile = filepath.Join(filepath.Dir(genesisPath), genesis.AllocFile)
+	}
+	expected, _, err := (&internal.GenesisBlock{GenesisConfig: genesis}).ToBlock()
+	if err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
 
 	chaindb, err := node.OpenDatabase(&DefaultConfig, nil, kv.ChainDB.String())
 	if err != nil {
@@ -71,9 +100,12 @@ func initGenesis(cliCtx *cli.Context) error {
 			return err
 		}
 
+		if storedHash == expected.Hash() {
+			genesisBlock = expected
+			return errGenesisExists
+		}
 		if storedHash != (types.Hash{}) {
-			return fmt.Errorf("genesis state are already exists")
-
+			return fmt.Errorf("the data dir already holds genesis %s, not %s", storedHash, expected.Hash())
 		}
 		genesisBlock, err = node.WriteGenesisBlock(tx, genesis)
 		if nil != err {
@@ -83,8 +115,11 @@ func initGenesis(cliCtx *cli.Context) error {
 			return err
 		}
 		return nil
-	}); err != nil {
-		utils.Fatalf("Failed to wrote genesis state to database: %w", err)
+	}); errors.Is(err, errGenesisExists) {
+		log.Info("Genesis already written", "hash", genesisBlock.Hash())
+		return nil
+	} else if err != nil {
+		utils.Fatalf("Failed to write genesis state to database: %v", err)
 	}
 	log.Info("Successfully wrote genesis state", "hash", genesisBlock.Hash())
 	return nil
@@ -0,0 +1,45 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/amazechain/amc/accounts/keystore"
+	"github.com/amazechain/amc/internal/node"
+	"github.com/amazechain/amc/log"
+)
+
+// lockOnSignal locks all the unlocked accounts of the node on SIGUSR1, which
+// takes the keys out of memory without stopping the node.
+func lockOnSignal(stack *node.Node) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGUSR1)
+	go func() {
+		for range sigc {
+			for _, backend := range stack.AccountManager().Backends(keystore.KeyStoreType) {
+				backend.(*keystore.KeyStore).LockAll()
+			}
+			log.Warn("Locked all accounts on SIGUSR1")
+		}
+	}()
+}
@@ -37,12 +37,13 @@ func main() {
 	flags = append(flags, authRPCFlag...)
 	flags = append(flags, configFlag...)
 	flags = append(flags, settingFlag...)
+	flags = append(flags, forkOverrideFlags...)
 	flags = append(flags, accountFlag...)
 	flags = append(flags, metricsFlags...)
 	flags = append(flags, p2pFlags...)
 	flags = append(flags, p2pLimitFlags...)
 
-	rootCmd = append(rootCmd, walletCommand, accountCommand, exportCommand, initCommand)
+	rootCmd = append(rootCmd, walletCommand, accountCommand, exportCommand, exportNDJSONCommand, importCommand, rollbackCommand, verifyChainCommand, eraCommand, initCommand, signerCommand, snapshotCommand, evmCommand, versionCommand, consoleCommand, attachCommand, dumpConfigCommand, dbCommand, dnsCommand, nodeKeyCommand)
 	commands := rootCmd
 
 	app := &cli.App{
@@ -51,8 +52,9 @@ func main() {
 		Flags:    flags,
 		Commands: commands,
 		//Version:                version.FormatVersion(),
-		Version:                params.VersionWithCommit(params.GitCommit, ""),
+		Version:                params.VersionWithCommit(params.GitCommit, params.BuildDate),
 		UseShortOptionHandling: true,
+		Before:                 loadConfigFile,
 		Action:                 appRun,
 	}
 
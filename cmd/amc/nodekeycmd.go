@@ -0,0 +1,127 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+
+	"github.com/amazechain/amc/cmd/utils"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/p2p/enode"
+	"github.com/amazechain/amc/internal/p2p/enr"
+	amcutils "github.com/amazechain/amc/utils"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	nodeKeyFlags = []cli.Flag{
+		DataDirFlag,
+		P2PHost,
+		P2PIP,
+		P2PTCPPort,
+		P2PUDPPort,
+	}
+
+	nodeKeyCommand = &cli.Command{
+		Name:  "nodekey",
+		Usage: "Manage the network key of the node",
+		Description: `
+The network key stored in the data directory gives the node its peer id and
+signs its node record, so the peers that dial it as a static or trusted peer
+know it under a single identity. It is created on the first start, readable
+by the owner only, unless a key is given with --p2p.priv-key or
+--p2p.priv-key-hex.`,
+		Subcommands: []*cli.Command{
+			{
+				Name:   "show",
+				Usage:  "Print the identity of the stored network key",
+				Action: showNodeKey,
+				Flags:  nodeKeyFlags,
+				Description: `
+Prints the peer id, the enode URL and the node record derived from the network
+key in --data.dir. The address comes from --p2p.host-ip, or else --p2p.local-ip,
+and the ports from --p2p.tcp-port and --p2p.udp-port; the record of the running
+node also carries its fork entries.`,
+			},
+			{
+				Name:   "rotate",
+				Usage:  "Replace the stored network key with a new one",
+				Action: rotateNodeKey,
+				Flags:  nodeKeyFlags,
+				Description: `
+Generates a new network key in --data.dir and prints the resulting identity,
+as the show command does. The previous key is kept beside the new one,
+suffixed with the time of the rotation. Stop the node first, and update the
+static peer lists naming the old identity.`,
+			},
+		},
+	}
+)
+
+func showNodeKey(ctx *cli.Context) error {
+	key, err := p2p.NodeKey(DefaultConfig.NodeCfg.DataDir)
+	if err != nil {
+		utils.Fatalf("Could not load the network key: %v", err)
+	}
+	return printNodeIdentity(key)
+}
+
+func rotateNodeKey(ctx *cli.Context) error {
+	key, err := p2p.RotateNodeKey(DefaultConfig.NodeCfg.DataDir)
+	if err != nil {
+		utils.Fatalf("Could not rotate the network key: %v", err)
+	}
+	return printNodeIdentity(key)
+}
+
+// printNodeIdentity prints the peer id and node record of a network key.
+func printNodeIdentity(key *ecdsa.PrivateKey) error {
+	pubkey, err := amcutils.ConvertToInterfacePubkey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+	id, err := peer.IDFromPublicKey(pubkey)
+	if err != nil {
+		return err
+	}
+	cfg := DefaultConfig.P2PCfg
+	ip := net.ParseIP(cfg.HostAddress)
+	if ip == nil {
+		ip = net.ParseIP(cfg.LocalIP)
+	}
+	if ip == nil {
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+	var r enr.Record
+	r.Set(enr.IP(ip))
+	r.Set(enr.TCP(cfg.TCPPort))
+	r.Set(enr.UDP(cfg.UDPPort))
+	if err := enode.SignV4(&r, key); err != nil {
+		return err
+	}
+	node, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Peer ID:", id)
+	fmt.Println("Enode:  ", node.URLv4())
+	fmt.Println("ENR:    ", node.String())
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/amazechain/amc/accounts/keystore"
+	"github.com/amazechain/amc/accounts/remote"
+	"github.com/amazechain/amc/cmd/utils"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/internal/node"
+	"github.com/amazechain/amc/log"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	signerKeyFlag = &cli.PathFlag{
+		Name:      "signer.keyfile",
+		Usage:     "Keystore file of the sealing key",
+		TakesFile: true,
+	}
+	signerListenFlag = &cli.StringFlag{
+		Name:  "signer.addr",
+		Usage: "Listening address of the signer HTTP-RPC server",
+		Value: "127.0.0.1:8552",
+	}
+	signerJWTSecretFlag = &cli.PathFlag{
+		Name:      "signer.jwtsecret",
+		Usage:     "Path to the JWT secret of the node (--authrpc.jwtsecret), required to listen on other than loopback",
+		TakesFile: true,
+	}
+
+	signerCommand = &cli.Command{
+		Name:   "signer",
+		Usage:  "Run a remote signer sealing blocks for a validator node",
+		Action: runSigner,
+		Flags: []cli.Flag{
+			DataDirFlag,
+			signerKeyFlag,
+			signerListenFlag,
+			signerJWTSecretFlag,
+			PasswordFileFlag,
+		},
+		Description: `
+    amc signer --signer.keyfile <keyfile> [--signer.addr 127.0.0.1:8552] [--signer.jwtsecret <file>]
+
+Serves seal signatures over HTTP-RPC so that the validator key never has to
+live on the node. Start the node with --engine.signer http://<addr> pointing at
+it. With --signer.jwtsecret, only the nodes holding the JWT secret of their
+authenticated RPC may sign; without it the signer only listens on loopback.
+The signer refuses to sign two different blocks at the same height, or any
+block below the last one it signed; that state is kept in
+<data.dir>/signer/protection.json and must move together with the key.`,
+	}
+)
+
+func runSigner(ctx *cli.Context) error {
+	keyfile := ctx.Path(signerKeyFlag.Name)
+	if keyfile == "" {
+		utils.Fatalf("The key file must be given with --%s", signerKeyFlag.Name)
+	}
+	keyjson, err := os.ReadFile(keyfile)
+	if err != nil {
+		utils.Fatalf("Failed to read the key file: %v", err)
+	}
+	passphrase := utils.GetPassPhraseWithList("", false, 0, MakePasswordList(ctx))
+	key, err := keystore.DecryptKey(keyjson, passphrase)
+	if err != nil {
+		utils.Fatalf("Failed to decrypt the key file: %v", err)
+	}
+
+	guard, err := remote.OpenGuard(filepath.Join(DefaultConfig.NodeCfg.DataDir, "signer", "protection.json"))
+	if err != nil {
+		utils.Fatalf("Failed to load slashing protection: %v", err)
+	}
+	server, err := remote.NewServer(remote.NewService(guard, key.PrivateKey))
+	if err != nil {
+		return err
+	}
+	defer server.Stop()
+
+	var jwtSecret []byte
+	if path := ctx.Path(signerJWTSecretFlag.Name); path != "" {
+		if jwtSecret, err = readJWTSecret(path); err != nil {
+			utils.Fatalf("Failed to load the JWT secret: %v", err)
+		}
+	}
+	listener, err := net.Listen("tcp", ctx.String(signerListenFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to listen: %v", err)
+	}
+	if addr := listener.Addr().(*net.TCPAddr); jwtSecret == nil && !addr.IP.IsLoopback() {
+		listener.Close()
+		utils.Fatalf("Refusing to serve signatures on %s without --%s", addr, signerJWTSecretFlag.Name)
+	}
+	// Unauthenticated signers only answer requests naming a loopback host.
+	vhosts := []string{"localhost"}
+	if jwtSecret != nil {
+		vhosts = []string{"*"}
+	}
+	go http.Serve(listener, node.NewHTTPHandlerStack(server, nil, vhosts, jwtSecret))
+	log.Info("Remote signer started", "address", key.Address, "endpoint", listener.Addr())
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Remote signer stopping")
+	return listener.Close()
+}
+
+// readJWTSecret loads a hex encoded JWT secret, as written by the node.
+func readJWTSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hexutil.Decode(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT secret %s: %v", path, err)
+	}
+	if len(secret) != 32 {
+		return nil, fmt.Errorf("invalid JWT secret %s: %d bytes, want 32", path, len(secret))
+	}
+	return secret, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/amazechain/amc/cmd/utils"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/node"
+	"github.com/amazechain/amc/log"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	snapshotCommand = &cli.Command{
+		Name:  "snapshot",
+		Usage: "Maintain the state of a stopped node",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "prune-state",
+				Usage:  "Rebuild a compact chain database without the old state history",
+				Action: pruneState,
+				Flags: []cli.Flag{
+					DataDirFlag,
+					PruneHistoryFlag,
+				},
+				Description: `
+The prune-state command copies the chain database of a stopped node into a new
+one, leaving out the state history older than the last --prune.history blocks
+(4096 at least), and replaces the old database with it. The blocks and the
+current state are kept whole, only the state as of older blocks can't be
+queried anymore. The reclaimed space is reported once done.
+
+The old database is only removed after the copy completed, so the disk needs
+room for the compacted one beside it. Run the node with --prune.history
+afterwards to keep the history from growing back.`,
+			},
+		},
+	}
+)
+
+// pruneState compacts the chain database of the stopped node.
+func pruneState(ctx *cli.Context) error {
+	before, after, err := node.PruneState(ctx.Context, &DefaultConfig, DefaultConfig.NodeCfg.PruneHistory)
+	if err != nil {
+		utils.Fatalf("Prune error: %v", err)
+	}
+	log.Info("State pruned", "before", types.StorageSize(before), "after", types.StorageSize(after), "reclaimed", types.StorageSize(before-after))
+	return nil
+}
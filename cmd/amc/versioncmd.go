@@ -0,0 +1,57 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/amazechain/amc/params"
+	"github.com/urfave/cli/v2"
+)
+
+var versionCommand = &cli.Command{
+	Name:      "version",
+	Usage:     "Print the version and build information",
+	ArgsUsage: " ",
+	Action:    printVersion,
+	Description: `
+The version command prints the release, the git commit, branch and tag and the
+date the binary was built at, along with the platform and the Go version. The
+same build is reported by web3_clientVersion and the amc_build_info metric.`,
+}
+
+func printVersion(_ *cli.Context) error {
+	fmt.Println(params.ClientName)
+	fmt.Println("Version:", params.VersionWithMeta)
+	if params.GitCommit != "" {
+		fmt.Println("Git Commit:", params.GitCommit)
+	}
+	if params.GitBranch != "" {
+		fmt.Println("Git Branch:", params.GitBranch)
+	}
+	if params.GitTag != "" {
+		fmt.Println("Git Tag:", params.GitTag)
+	}
+	if params.BuildDate != "" {
+		fmt.Println("Build Date:", params.BuildDate)
+	}
+	fmt.Println("Architecture:", runtime.GOARCH)
+	fmt.Println("Go Version:", runtime.Version())
+	fmt.Println("Operating System:", runtime.GOOS)
+	return nil
+}
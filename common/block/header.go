@@ -73,6 +73,10 @@ type Header struct {
 	// BaseFee was added by EIP-1559 and is ignored in legacy headers.
 	BaseFee *uint256.Int `json:"baseFeePerGas" rlp:"optional"`
 
+	// Extension holds the fields added by later forks, it is left out of the
+	// hash of the headers without any.
+	Extension HeaderExtension `json:"extension,omitempty"`
+
 	hash atomic.Value
 
 	Signature types.Signature `json:"signature"`
@@ -135,6 +139,7 @@ func (h *Header) ToProtoMessage() proto.Message {
 		Signature:   utils.ConvertSignatureToH768(h.Signature),
 		Bloom:       utils.ConvertBytesToH2048(h.Bloom.Bytes()),
 		MixDigest:   utils.ConvertHashToH256(h.MixDigest),
+		Extension:   h.Extension.Encode(),
 	}
 }
 
@@ -164,6 +169,11 @@ func (h *Header) FromProtoMessage(message proto.Message) error {
 	h.Signature = utils.ConvertH768ToSignature(pbHeader.Signature)
 	h.Bloom = utils.ConvertH2048ToBloom(pbHeader.Bloom)
 	h.MixDigest = utils.ConvertH256ToHash(pbHeader.MixDigest)
+	ext, err := DecodeHeaderExtension(pbHeader.Extension)
+	if err != nil {
+		return err
+	}
+	h.Extension = ext
 	return nil
 }
 
@@ -201,6 +211,7 @@ func CopyHeader(h *Header) *Header {
 		cpy.Extra = make([]byte, len(h.Extra))
 		copy(cpy.Extra, h.Extra)
 	}
+	cpy.Extension = h.Extension.Copy()
 	return &cpy
 }
 
@@ -0,0 +1,245 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package block
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/params"
+)
+
+// HeaderField numbers a typed field of the header extension.
+type HeaderField uint16
+
+// The fields of the header extension. A new field takes the next number and
+// an entry in headerFields naming the fork it comes with; numbers are never
+// reused.
+const (
+	WithdrawalsRootField HeaderField = iota + 1
+	BlobGasUsedField
+	ExcessBlobGasField
+	ParentBeaconRootField
+)
+
+// headerExtensionVersion is the version of the extension encoding. Decoders
+// refuse later versions rather than misread them.
+const headerExtensionVersion = 1
+
+var (
+	ErrUnknownHeaderField  = errors.New("unknown header extension field")
+	ErrInactiveHeaderField = errors.New("header extension field not active yet")
+	errHeaderFieldSize     = errors.New("invalid header extension field size")
+	errHeaderFieldOrder    = errors.New("header extension fields not in ascending order")
+)
+
+// headerFieldSpec describes a known field of the extension.
+type headerFieldSpec struct {
+	name     string
+	size     int // the values are fixed size
+	quantity bool
+	active   func(*params.Rules) bool
+}
+
+func isShanghai(r *params.Rules) bool { return r.IsShanghai }
+func isCancun(r *params.Rules) bool   { return r.IsCancun }
+
+var headerFields = map[HeaderField]headerFieldSpec{
+	WithdrawalsRootField:  {name: "withdrawalsRoot", size: types.HashLength, active: isShanghai},
+	BlobGasUsedField:      {name: "blobGasUsed", size: 8, quantity: true, active: isCancun},
+	ExcessBlobGasField:    {name: "excessBlobGas", size: 8, quantity: true, active: isCancun},
+	ParentBeaconRootField: {name: "parentBeaconBlockRoot", size: types.HashLength, active: isCancun},
+}
+
+// String returns the JSON name of a known field.
+func (f HeaderField) String() string {
+	if spec, ok := headerFields[f]; ok {
+		return spec.name
+	}
+	return fmt.Sprintf("field%d", uint16(f))
+}
+
+// HeaderExtensionField is a field of the extension with its raw value.
+type HeaderExtensionField struct {
+	Field HeaderField   `json:"field"`
+	Value hexutil.Bytes `json:"value"`
+}
+
+// Interface returns the value of the field as it is shown to users: a hash,
+// a quantity, or the raw bytes of a field this version doesn't know.
+func (f HeaderExtensionField) Interface() interface{} {
+	spec, ok := headerFields[f.Field]
+	switch {
+	case !ok || len(f.Value) != spec.size:
+		return f.Value
+	case spec.quantity:
+		return hexutil.Uint64(binary.BigEndian.Uint64(f.Value))
+	}
+	return types.BytesToHash(f.Value)
+}
+
+// HeaderExtension holds the fields forks add to the header, sorted by number.
+// It is encoded as a whole into the header, so adding a field changes no
+// other encoding and leaves the headers without it as they were. Fields
+// unknown to this version are kept as they are: headers of a later fork are
+// stored and relayed unchanged, they only fail Verify.
+type HeaderExtension []HeaderExtensionField
+
+// Get returns the raw value of a field.
+func (e HeaderExtension) Get(f HeaderField) ([]byte, bool) {
+	i := e.search(f)
+	if i < len(e) && e[i].Field == f {
+		return e[i].Value, true
+	}
+	return nil, false
+}
+
+// Set sets the raw value of a field.
+func (e *HeaderExtension) Set(f HeaderField, value []byte) {
+	field := HeaderExtensionField{Field: f, Value: types.CopyBytes(value)}
+	i := e.search(f)
+	if i < len(*e) && (*e)[i].Field == f {
+		(*e)[i] = field
+		return
+	}
+	*e = append(*e, HeaderExtensionField{})
+	copy((*e)[i+1:], (*e)[i:])
+	(*e)[i] = field
+}
+
+// Delete removes a field.
+func (e *HeaderExtension) Delete(f HeaderField) {
+	if i := e.search(f); i < len(*e) && (*e)[i].Field == f {
+		*e = append((*e)[:i], (*e)[i+1:]...)
+	}
+	if len(*e) == 0 {
+		*e = nil
+	}
+}
+
+// Hash returns the value of a hash field.
+func (e HeaderExtension) Hash(f HeaderField) (types.Hash, bool) {
+	value, ok := e.Get(f)
+	if !ok || len(value) != types.HashLength {
+		return types.Hash{}, false
+	}
+	return types.BytesToHash(value), true
+}
+
+// SetHash sets the value of a hash field.
+func (e *HeaderExtension) SetHash(f HeaderField, hash types.Hash) {
+	e.Set(f, hash[:])
+}
+
+// Uint64 returns the value of a quantity field.
+func (e HeaderExtension) Uint64(f HeaderField) (uint64, bool) {
+	value, ok := e.Get(f)
+	if !ok || len(value) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(value), true
+}
+
+// SetUint64 sets the value of a quantity field.
+func (e *HeaderExtension) SetUint64(f HeaderField, v uint64) {
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], v)
+	e.Set(f, value[:])
+}
+
+func (e HeaderExtension) search(f HeaderField) int {
+	return sort.Search(len(e), func(i int) bool { return e[i].Field >= f })
+}
+
+// Verify checks that every field is known, of the right size and active under
+// the rules of the header's block.
+func (e HeaderExtension) Verify(rules *params.Rules) error {
+	for _, field := range e {
+		spec, ok := headerFields[field.Field]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownHeaderField, field.Field)
+		}
+		if len(field.Value) != spec.size {
+			return fmt.Errorf("%w: %s has %d bytes, want %d", errHeaderFieldSize, field.Field, len(field.Value), spec.size)
+		}
+		if !spec.active(rules) {
+			return fmt.Errorf("%w: %s", ErrInactiveHeaderField, field.Field)
+		}
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the extension.
+func (e HeaderExtension) Copy() HeaderExtension {
+	if e == nil {
+		return nil
+	}
+	cpy := make(HeaderExtension, len(e))
+	for i, field := range e {
+		cpy[i] = HeaderExtensionField{Field: field.Field, Value: types.CopyBytes(field.Value)}
+	}
+	return cpy
+}
+
+// Encode returns the binary encoding of the extension, nothing if it's empty:
+// the version byte, then for every field its number and the length of its
+// value as uvarints, followed by the value.
+func (e HeaderExtension) Encode() []byte {
+	if len(e) == 0 {
+		return nil
+	}
+	enc := []byte{headerExtensionVersion}
+	for _, field := range e {
+		enc = binary.AppendUvarint(enc, uint64(field.Field))
+		enc = binary.AppendUvarint(enc, uint64(len(field.Value)))
+		enc = append(enc, field.Value...)
+	}
+	return enc
+}
+
+// DecodeHeaderExtension decodes an extension encoded by Encode.
+func DecodeHeaderExtension(data []byte) (HeaderExtension, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] != headerExtensionVersion {
+		return nil, fmt.Errorf("unsupported header extension version %d", data[0])
+	}
+	var e HeaderExtension
+	for rest := data[1:]; len(rest) > 0; {
+		f, n := binary.Uvarint(rest)
+		if n <= 0 || f > 0xffff {
+			return nil, errors.New("invalid header extension field number")
+		}
+		rest = rest[n:]
+		size, n := binary.Uvarint(rest)
+		if n <= 0 || size > uint64(len(rest)-n) {
+			return nil, errHeaderFieldSize
+		}
+		rest = rest[n:]
+		if len(e) > 0 && HeaderField(f) <= e[len(e)-1].Field {
+			return nil, errHeaderFieldOrder
+		}
+		e = append(e, HeaderExtensionField{Field: HeaderField(f), Value: types.CopyBytes(rest[:size])})
+		rest = rest[size:]
+	}
+	return e, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package block
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+)
+
+func TestHeaderExtensionEncoding(t *testing.T) {
+	var ext HeaderExtension
+	ext.SetUint64(ExcessBlobGasField, 7)
+	ext.SetHash(WithdrawalsRootField, types.HexToHash("0x01"))
+	ext.Set(HeaderField(1000), []byte{0xaa})
+	if ext[0].Field != WithdrawalsRootField || ext[1].Field != ExcessBlobGasField {
+		t.Fatalf("fields not sorted: %v", ext)
+	}
+	dec, err := DecodeHeaderExtension(ext.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec.Encode(), ext.Encode()) {
+		t.Fatalf("decoded %v, want %v", dec, ext)
+	}
+	if v, ok := dec.Uint64(ExcessBlobGasField); !ok || v != 7 {
+		t.Fatalf("excessBlobGas %d %v", v, ok)
+	}
+	if _, err := DecodeHeaderExtension([]byte{headerExtensionVersion + 1}); err == nil {
+		t.Fatal("later version decoded")
+	}
+	if _, err := DecodeHeaderExtension(append(ext.Encode(), 1, 0)); !errors.Is(err, errHeaderFieldOrder) {
+		t.Fatalf("unsorted fields: %v", err)
+	}
+
+	ext.Delete(HeaderField(1000))
+	shanghai := &params.Rules{IsShanghai: true}
+	if err := ext.Verify(shanghai); !errors.Is(err, ErrInactiveHeaderField) {
+		t.Fatalf("cancun field before cancun: %v", err)
+	}
+	shanghai.IsCancun = true
+	if err := ext.Verify(shanghai); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHeaderExtensionHash(t *testing.T) {
+	header := &Header{
+		Difficulty: uint256.NewInt(1),
+		Number:     uint256.NewInt(5),
+		GasLimit:   8000000,
+		BaseFee:    uint256.NewInt(params.InitialBaseFee),
+		Extra:      []byte{1, 2},
+	}
+	legacy := header.Hash()
+
+	// Headers without extension keep their hash through the storage encoding.
+	var dec Header
+	if err := dec.FromProtoMessage(header.ToProtoMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Extension != nil || dec.Hash() != legacy {
+		t.Fatalf("hash changed without extension: %x != %x", dec.Hash(), legacy)
+	}
+
+	extended := CopyHeader(header)
+	extended.Extension.SetUint64(BlobGasUsedField, 131072)
+	if extended.Hash() == legacy {
+		t.Fatal("extension not hashed")
+	}
+	data, err := extended.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored Header
+	if err := restored.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Hash() != extended.Hash() {
+		t.Fatalf("hash %x, want %x", restored.Hash(), extended.Hash())
+	}
+}
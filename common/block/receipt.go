@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/amazechain/amc/api/protocol/types_pb"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/transaction"
 	"github.com/amazechain/amc/common/types"
 	"github.com/amazechain/amc/internal/avm/rlp"
 	"github.com/amazechain/amc/utils"
@@ -212,3 +214,101 @@ type storedLog struct {
 	Topics  []types.Hash
 	Data    []byte
 }
+
+// receiptForStorage is the compact form receipts are stored in, holding only
+// what can't be derived from the block, see DeriveFields.
+type receiptForStorage struct {
+	Type              uint8
+	PostState         []byte
+	Status            uint64
+	CumulativeGasUsed uint64
+	Logs              []*storedLog
+}
+
+// MarshalForStorage encodes the receipts in their compact stored form.
+func (rs Receipts) MarshalForStorage() ([]byte, error) {
+	stored := make([]*receiptForStorage, len(rs))
+	for i, r := range rs {
+		logs := make([]*storedLog, len(r.Logs))
+		for k, log := range r.Logs {
+			logs[k] = &storedLog{Address: log.Address, Topics: log.Topics, Data: log.Data}
+		}
+		stored[i] = &receiptForStorage{
+			Type:              r.Type,
+			PostState:         r.PostState,
+			Status:            r.Status,
+			CumulativeGasUsed: r.CumulativeGasUsed,
+			Logs:              logs,
+		}
+	}
+	return rlp.EncodeToBytes(stored)
+}
+
+// UnmarshalForStorage decodes receipts stored by MarshalForStorage. Only the
+// stored fields are set, DeriveFields fills in the others.
+func (rs *Receipts) UnmarshalForStorage(data []byte) error {
+	var stored []*receiptForStorage
+	if err := rlp.DecodeBytes(data, &stored); err != nil {
+		return err
+	}
+	receipts := make(Receipts, len(stored))
+	for i, s := range stored {
+		r := &Receipt{
+			Type:              s.Type,
+			PostState:         s.PostState,
+			Status:            s.Status,
+			CumulativeGasUsed: s.CumulativeGasUsed,
+			Logs:              make([]*Log, len(s.Logs)),
+		}
+		for k, log := range s.Logs {
+			r.Logs[k] = &Log{Address: log.Address, Topics: log.Topics, Data: log.Data}
+		}
+		receipts[i] = r
+	}
+	*rs = receipts
+	return nil
+}
+
+// DeriveFields fills in the fields of the receipts of a block that aren't
+// stored: the bloom, the gas used, the created contract, the inclusion
+// information and the positions of the logs. The senders of the transactions
+// must be known.
+func (rs Receipts) DeriveFields(hash types.Hash, number uint64, txs []*transaction.Transaction) error {
+	if len(txs) != len(rs) {
+		return fmt.Errorf("transaction and receipt count mismatch, tx count = %d, receipts count = %d", len(txs), len(rs))
+	}
+	var (
+		blockNumber = uint256.NewInt(number)
+		logIndex    uint
+	)
+	for i, r := range rs {
+		tx := txs[i]
+		r.TxHash = tx.Hash()
+		r.BlockHash = hash
+		r.BlockNumber = blockNumber
+		r.TransactionIndex = uint(i)
+
+		r.GasUsed = r.CumulativeGasUsed
+		if i > 0 {
+			r.GasUsed -= rs[i-1].CumulativeGasUsed
+		}
+		r.ContractAddress = types.Address{}
+		if tx.To() == nil {
+			from := tx.From()
+			if from == nil {
+				return fmt.Errorf("unknown sender of transaction %d (%s)", i, r.TxHash)
+			}
+			r.ContractAddress = crypto.CreateAddress(*from, tx.Nonce())
+		}
+		for _, log := range r.Logs {
+			log.BlockNumber = blockNumber
+			log.BlockHash = hash
+			log.TxHash = r.TxHash
+			log.TxIndex = uint(i)
+			log.Index = logIndex
+			logIndex++
+		}
+		r.Bloom = CreateBloom(Receipts{r})
+	}
+	return nil
+}
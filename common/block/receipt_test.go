@@ -0,0 +1,188 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package block
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/holiman/uint256"
+)
+
+var (
+	testSender   = types.HexToAddress("0x1000000000000000000000000000000000000001")
+	testContract = types.HexToAddress("0x2000000000000000000000000000000000000002")
+	testBlock    = types.HexToHash("0x3000000000000000000000000000000000000000000000000000000000000003")
+)
+
+// testReceipts returns the transactions of a block and their receipts with
+// every field set, as the block processor hands them over: a call emitting
+// logs, a failed call, a contract creation and a call without logs.
+func testReceipts() ([]*transaction.Transaction, Receipts) {
+	gasPrice := uint256.NewInt(1)
+	txs := []*transaction.Transaction{
+		transaction.NewTransaction(0, testSender, &testContract, uint256.NewInt(0), 50000, gasPrice, []byte{0x01}),
+		transaction.NewTransaction(1, testSender, &testContract, uint256.NewInt(0), 50000, gasPrice, nil),
+		transaction.NewTx(&transaction.LegacyTx{Nonce: 2, From: &testSender, Value: uint256.NewInt(0), Gas: 100000, GasPrice: gasPrice, Data: []byte{0x60, 0x00}}),
+		transaction.NewTransaction(3, testSender, &testContract, uint256.NewInt(7), 21000, gasPrice, nil),
+	}
+
+	receipts := Receipts{
+		{
+			Status:            ReceiptStatusSuccessful,
+			CumulativeGasUsed: 30000,
+			Logs: []*Log{
+				{Address: testContract, Topics: []types.Hash{{0x01}}, Data: []byte{0xaa}},
+				{Address: testContract, Topics: []types.Hash{{0x02}, {0x03}}, Data: nil},
+			},
+		},
+		{
+			Status:            ReceiptStatusFailed,
+			CumulativeGasUsed: 55000,
+		},
+		{
+			Status:            ReceiptStatusSuccessful,
+			CumulativeGasUsed: 120000,
+			Logs: []*Log{
+				{Address: crypto.CreateAddress(testSender, 2), Topics: []types.Hash{{0x04}}, Data: []byte{0xbb, 0xcc}},
+			},
+			ContractAddress: crypto.CreateAddress(testSender, 2),
+		},
+		{
+			Status:            ReceiptStatusSuccessful,
+			CumulativeGasUsed: 141000,
+		},
+	}
+	var (
+		logIndex uint
+		previous uint64
+	)
+	for i, r := range receipts {
+		r.TxHash = txs[i].Hash()
+		r.GasUsed = r.CumulativeGasUsed - previous
+		previous = r.CumulativeGasUsed
+		r.BlockHash = testBlock
+		r.BlockNumber = uint256.NewInt(42)
+		r.TransactionIndex = uint(i)
+		for _, l := range r.Logs {
+			l.BlockNumber = r.BlockNumber
+			l.BlockHash = testBlock
+			l.TxHash = r.TxHash
+			l.TxIndex = uint(i)
+			l.Index = logIndex
+			logIndex++
+		}
+		r.Bloom = CreateBloom(Receipts{r})
+	}
+	return txs, receipts
+}
+
+// TestReceiptsForStorage checks that the receipts rebuilt from the compact
+// form match the ones kept in the legacy full encoding.
+func TestReceiptsForStorage(t *testing.T) {
+	txs, receipts := testReceipts()
+
+	legacy, err := receipts.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want Receipts
+	if err := want.Unmarshal(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	compact, err := receipts.MarshalForStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compact) >= len(legacy) {
+		t.Errorf("compact receipts take %d bytes, legacy ones %d", len(compact), len(legacy))
+	}
+	var got Receipts
+	if err := got.UnmarshalForStorage(compact); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.DeriveFields(testBlock, 42, txs); err != nil {
+		t.Fatal(err)
+	}
+	checkReceipts(t, got, want)
+	checkReceipts(t, got, receipts)
+}
+
+func TestReceiptsDeriveFieldsErrors(t *testing.T) {
+	txs, receipts := testReceipts()
+	compact, err := receipts.MarshalForStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Receipts
+	if err := stored.UnmarshalForStorage(compact); err != nil {
+		t.Fatal(err)
+	}
+	if err := stored.DeriveFields(testBlock, 42, txs[:3]); err == nil {
+		t.Error("no error on a missing transaction")
+	}
+	txs[2] = transaction.NewContractCreation(2, uint256.NewInt(0), 100000, uint256.NewInt(1), []byte{0x60, 0x00})
+	if err := stored.DeriveFields(testBlock, 42, txs); err == nil {
+		t.Error("no error on a contract creation without sender")
+	}
+}
+
+// checkReceipts compares the receipts field by field, an empty slice being
+// equal to a nil one.
+func checkReceipts(t *testing.T, got, want Receipts) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d receipts, want %d", len(got), len(want))
+	}
+	for i, r := range got {
+		w := want[i]
+		if r.Type != w.Type || !bytes.Equal(r.PostState, w.PostState) || r.Status != w.Status {
+			t.Errorf("receipt %d: type %d, post state %x, status %d, want %d, %x, %d", i, r.Type, r.PostState, r.Status, w.Type, w.PostState, w.Status)
+		}
+		if r.CumulativeGasUsed != w.CumulativeGasUsed || r.GasUsed != w.GasUsed {
+			t.Errorf("receipt %d: cumulative gas %d, gas %d, want %d, %d", i, r.CumulativeGasUsed, r.GasUsed, w.CumulativeGasUsed, w.GasUsed)
+		}
+		if r.Bloom != w.Bloom {
+			t.Errorf("receipt %d: wrong bloom", i)
+		}
+		if r.TxHash != w.TxHash || r.ContractAddress != w.ContractAddress {
+			t.Errorf("receipt %d: tx hash %s, contract %s, want %s, %s", i, r.TxHash, r.ContractAddress, w.TxHash, w.ContractAddress)
+		}
+		if r.BlockHash != w.BlockHash || !r.BlockNumber.Eq(w.BlockNumber) || r.TransactionIndex != w.TransactionIndex {
+			t.Errorf("receipt %d: block %s #%d index %d, want %s #%d index %d", i, r.BlockHash, r.BlockNumber, r.TransactionIndex, w.BlockHash, w.BlockNumber, w.TransactionIndex)
+		}
+		if len(r.Logs) != len(w.Logs) {
+			t.Errorf("receipt %d: %d logs, want %d", i, len(r.Logs), len(w.Logs))
+			continue
+		}
+		for k, l := range r.Logs {
+			wl := w.Logs[k]
+			if l.Address != wl.Address || len(l.Topics) != len(wl.Topics) || (len(l.Topics) > 0 && !reflect.DeepEqual(l.Topics, wl.Topics)) || !bytes.Equal(l.Data, wl.Data) {
+				t.Errorf("receipt %d log %d: %+v, want %+v", i, k, l, wl)
+			}
+			if l.Index != wl.Index || l.TxHash != wl.TxHash || l.TxIndex != wl.TxIndex || l.BlockHash != wl.BlockHash || !l.BlockNumber.Eq(wl.BlockNumber) || l.Removed != wl.Removed {
+				t.Errorf("receipt %d log %d: index %d tx %s #%d block %s #%d, want %d tx %s #%d block %s #%d", i, k,
+					l.Index, l.TxHash, l.TxIndex, l.BlockHash, l.BlockNumber, wl.Index, wl.TxHash, wl.TxIndex, wl.BlockHash, wl.BlockNumber)
+			}
+		}
+	}
+}
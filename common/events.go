@@ -17,8 +17,12 @@
 package common
 
 import (
+	"time"
+
+	amazechain "github.com/amazechain/amc"
 	"github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
 	"github.com/amazechain/amc/modules/state"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
@@ -29,12 +33,53 @@ type NewLocalTxsEvent struct{ Txs []*transaction.Transaction }
 // NewTxsEvent txs
 type NewTxsEvent struct{ Txs []*transaction.Transaction }
 
+// TxDropReason tells why the transaction pool dropped a transaction.
+type TxDropReason string
+
+const (
+	// TxReplaced is a transaction replaced by one with the same nonce paying more.
+	TxReplaced TxDropReason = "replaced"
+	// TxUnderpriced is a transaction evicted from a full pool by better paying ones.
+	TxUnderpriced TxDropReason = "underpriced"
+	// TxStale is a transaction whose nonce was used by another transaction
+	// included in a block.
+	TxStale TxDropReason = "stale"
+	// TxUnpayable is a transaction the sender can't pay for anymore, or above
+	// the block gas limit.
+	TxUnpayable TxDropReason = "unpayable"
+	// TxOverflow is a transaction evicted by the per account or pool limits.
+	TxOverflow TxDropReason = "overflow"
+)
+
+// DroppedTx is a transaction the pool dropped without it being included in a
+// block. Replacement is the transaction it was replaced by, if any.
+type DroppedTx struct {
+	Tx          *transaction.Transaction
+	Reason      TxDropReason
+	Replacement types.Hash
+}
+
+// DroppedTxsEvent is posted when transactions leave the pool for good
+// without being included, so they will never confirm.
+type DroppedTxsEvent struct{ Txs []DroppedTx }
+
 // NewLogsEvent new logs
 type NewLogsEvent struct{ Logs []*block.Log }
 
-// RemovedLogsEvent is posted when a reorg happens // todo blockchain v2
+// RemovedLogsEvent is posted when a reorg drops blocks from the canonical
+// chain, with their logs marked as removed, the newest first.
 type RemovedLogsEvent struct{ Logs []*block.Log }
 
+// ChainSideEvent is posted for every block a reorg drops from the canonical chain.
+type ChainSideEvent struct{ Block block.IBlock }
+
+// SafeHeadEvent is posted when the safe block, the latest justified
+// checkpoint, moves.
+type SafeHeadEvent struct{ Header block.IHeader }
+
+// FinalizedHeadEvent is posted when the finalized block moves forward.
+type FinalizedHeadEvent struct{ Header block.IHeader }
+
 // NewPendingLogsEvent is posted when a reorg happens // todo miner v2
 type NewPendingLogsEvent struct{ Logs []*block.Log }
 
@@ -50,6 +95,62 @@ type DownloaderStartEvent struct{}
 // DownloaderFinishEvent finish download
 type DownloaderFinishEvent struct{}
 
+// SyncStartedEvent is posted when the node starts catching up with the
+// network. Blocks and transactions built on the local head are stale until
+// the matching SyncFinishedEvent or SyncFailedEvent.
+type SyncStartedEvent struct{ StartingBlock uint64 }
+
+// SyncProgressEvent is posted as the sync imports blocks and downloads state.
+type SyncProgressEvent struct{ Progress amazechain.SyncProgress }
+
+// SyncFinishedEvent is posted once the node caught up with the network.
+type SyncFinishedEvent struct{ Head uint64 }
+
+// SyncFailedEvent is posted when a sync stops before reaching the network
+// head, including when the node shuts down.
+type SyncFailedEvent struct {
+	Head uint64
+	Err  error
+}
+
+// ChainEvent is posted when a block has been written as the new canonical head
+type ChainEvent struct {
+	Block block.IBlock
+	Hash  types.Hash
+	Logs  []*block.Log
+}
+
+// BadBlockEvent is posted when a block fails validation. The block is kept
+// in the database and never imported again.
+type BadBlockEvent struct {
+	Block  block.IBlock
+	Reason string
+}
+
+// ReorgEvent is posted when a reorganization drops at least the configured
+// alert depth of canonical blocks. Depth counts the dropped blocks and
+// DroppedTxs the transactions they held that the new chain doesn't.
+type ReorgEvent struct {
+	OldHead      types.Hash
+	OldNumber    uint64
+	NewHead      types.Hash
+	NewNumber    uint64
+	CommonHash   types.Hash
+	CommonNumber uint64
+	Depth        uint64
+	DroppedTxs   []types.Hash
+}
+
+// StalledEvent is posted when no block has been imported for the stall
+// timeout. Imported is when the head at Number was imported, Lag the time
+// since then.
+type StalledEvent struct {
+	Number   uint64
+	Hash     types.Hash
+	Imported time.Time
+	Lag      time.Duration
+}
+
 type ChainHighestBlock struct {
 	Block    block.Block
 	Inserted bool
@@ -57,3 +158,13 @@ type ChainHighestBlock struct {
 type MinedEntireEvent struct {
 	Entire state.EntireCode
 }
+
+// DoubleSignEvent is posted when a validator is caught signing two different
+// headers at the same height. Evidence carries the encoded proof as gossiped
+// between nodes and included into blocks.
+type DoubleSignEvent struct {
+	Signer   types.Address
+	Number   uint64
+	Hashes   [2]types.Hash
+	Evidence []byte
+}
@@ -17,7 +17,7 @@
 //go:build !windows && !openbsd
 // +build !windows,!openbsd
 
-package main
+package paths
 
 import (
 	"fmt"
@@ -25,7 +25,8 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func getFreeDiskSpace(path string) (uint64, error) {
+// FreeDiskSpace returns the disk space available to the user at path.
+func FreeDiskSpace(path string) (uint64, error) {
 	var stat unix.Statfs_t
 	if err := unix.Statfs(path, &stat); err != nil {
 		return 0, fmt.Errorf("failed to call Statfs: %v", err)
@@ -504,25 +504,25 @@ func (tx *Transaction) EffectiveGasTipCmp(other *Transaction, baseFee *uint256.I
 }
 
 // EffectiveGasTip returns the effective miner gasTipCap for the given base fee.
-// Note: if the effective gasTipCap is negative, this method returns both error
-// the actual negative value, _and_ ErrGasFeeCapTooLow
+// Note: if the effective gasTipCap is negative, this method returns zero and
+// ErrGasFeeCapTooLow
 func (tx *Transaction) EffectiveGasTip(baseFee *uint256.Int) (*uint256.Int, error) {
 	if baseFee == nil {
 		return tx.GasTipCap(), nil
 	}
-	var err error
 	gasFeeCap := tx.GasFeeCap()
 	if gasFeeCap.Cmp(baseFee) == -1 {
-		err = ErrGasFeeCapTooLow
+		// The tip would be negative, which an unsigned value can't hold.
+		return new(uint256.Int), ErrGasFeeCapTooLow
 	}
-	return uint256Min(tx.GasTipCap(), new(uint256.Int).Sub(gasFeeCap, baseFee)), err
+	return uint256Min(tx.GasTipCap(), new(uint256.Int).Sub(gasFeeCap, baseFee)), nil
 }
 
 func uint256Min(x, y *uint256.Int) *uint256.Int {
 	if x.Cmp(y) == 1 {
-		return x
+		return y
 	}
-	return y
+	return x
 }
 
 func isProtectedV(V *big.Int) bool {
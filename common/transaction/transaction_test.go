@@ -86,3 +86,45 @@ func TestNewDynamicTx(t *testing.T) {
 	//addr := types.PublicToAddress(pub)
 
 }
+
+func TestEffectiveGasTip(t *testing.T) {
+	var (
+		to      = types.HexToAddress("0x01")
+		legacy  = NewTransaction(0, to, &to, uint256.NewInt(0), 21000, uint256.NewInt(10), nil)
+		dynamic = NewTx(&DynamicFeeTx{GasTipCap: uint256.NewInt(2), GasFeeCap: uint256.NewInt(10), Gas: 21000, To: &to, Value: uint256.NewInt(0)})
+	)
+	tests := []struct {
+		tx      *Transaction
+		baseFee *uint256.Int
+		tip     uint64
+		err     error
+	}{
+		// Legacy transactions tip whatever their price leaves over.
+		{legacy, nil, 10, nil},
+		{legacy, uint256.NewInt(0), 10, nil},
+		{legacy, uint256.NewInt(4), 6, nil},
+		{legacy, uint256.NewInt(10), 0, nil},
+		{legacy, uint256.NewInt(11), 0, ErrGasFeeCapTooLow},
+		// Dynamic fee transactions tip at most their tip cap.
+		{dynamic, nil, 2, nil},
+		{dynamic, uint256.NewInt(0), 2, nil},
+		{dynamic, uint256.NewInt(8), 2, nil},
+		{dynamic, uint256.NewInt(9), 1, nil},
+		{dynamic, uint256.NewInt(11), 0, ErrGasFeeCapTooLow},
+	}
+	for i, tt := range tests {
+		tip, err := tt.tx.EffectiveGasTip(tt.baseFee)
+		if err != tt.err || !tip.Eq(uint256.NewInt(tt.tip)) {
+			t.Errorf("test %d: tip %v (%v), want %d (%v)", i, tip, err, tt.tip, tt.err)
+		}
+		if value := tt.tx.EffectiveGasTipValue(tt.baseFee); !value.Eq(uint256.NewInt(tt.tip)) {
+			t.Errorf("test %d: tip value %v, want %d", i, value, tt.tip)
+		}
+	}
+	if cmp := legacy.EffectiveGasTipCmp(dynamic, uint256.NewInt(9)); cmp != 0 {
+		t.Errorf("tips compared %d at an equal tip", cmp)
+	}
+	if cmp := legacy.EffectiveGasTipCmp(dynamic, uint256.NewInt(0)); cmp != 1 {
+		t.Errorf("tips compared %d, want the legacy one higher", cmp)
+	}
+}
@@ -18,10 +18,13 @@ package conf
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"github.com/amazechain/amc/params"
+	"io"
 	"os"
 
+	"github.com/pelletier/go-toml/v2"
 	"gopkg.in/yaml.v2"
 )
 
@@ -69,3 +72,36 @@ func LoadConfigFromFile(file string, config *Config) error {
 	//return toml.NewDecoder(reader).Decode(blockchain)
 	return yaml.NewDecoder(reader).Decode(config)
 }
+
+// LoadTOMLConfig decodes a TOML file over config, leaving the settings the file
+// doesn't mention as they are. The tables and keys are named after the fields
+// of Config, as written by EncodeTOMLConfig, and unknown keys are rejected so
+// that a misspelled setting doesn't go unnoticed.
+func LoadTOMLConfig(file string, config *Config) error {
+	fd, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	err = toml.NewDecoder(bufio.NewReader(fd)).DisallowUnknownFields().Decode(config)
+	var (
+		strict    *toml.StrictMissingError
+		decodeErr *toml.DecodeError
+	)
+	switch {
+	case errors.As(err, &strict):
+		return fmt.Errorf("%s: unknown settings\n%s", file, strict.String())
+	case errors.As(err, &decodeErr):
+		row, col := decodeErr.Position()
+		return fmt.Errorf("%s, line %d, column %d: %v", file, row, col, decodeErr)
+	case err != nil:
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	return nil
+}
+
+// EncodeTOMLConfig writes config in the format read by LoadTOMLConfig.
+func EncodeTOMLConfig(w io.Writer, config *Config) error {
+	return toml.NewEncoder(w).Encode(config)
+}
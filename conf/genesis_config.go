@@ -17,6 +17,10 @@
 package conf
 
 import (
+	"errors"
+	"fmt"
+	"math/big"
+
 	"github.com/amazechain/amc/common/types"
 	"github.com/holiman/uint256"
 
@@ -36,6 +40,11 @@ type Genesis struct {
 	//Engine *ConsensusConfig `json:"engine" yaml:"engine"`
 	Miners []string     `json:"miners" yaml:"miners"`
 	Alloc  GenesisAlloc `json:"alloc" yaml:"alloc"  gencodec:"required"`
+	// AllocFile names a file holding the allocation instead of Alloc, in the
+	// same format with the accounts sorted by address. It is streamed into the
+	// state, so it may hold more accounts than fit in memory. A relative path
+	// is taken from the directory of the genesis file.
+	AllocFile string `json:"allocFile,omitempty" yaml:"alloc_file,omitempty"`
 
 	// These fields are used for consensus tests. Please don't use them
 	// in actual genesis blocks.
@@ -55,3 +64,56 @@ type GenesisAccount struct {
 	Storage map[types.Hash]types.Hash `json:"storage,omitempty"`
 	Nonce   uint64                    `json:"nonce,omitempty"`
 }
+
+// Validate checks a genesis specification before its block is written: the
+// chain config and its consensus section, the initial validators and the
+// balances of the allocation.
+func (g *Genesis) Validate() error {
+	if g.Config == nil {
+		return errors.New("missing chain config")
+	}
+	if g.Config.ChainID == nil || g.Config.ChainID.Sign() <= 0 {
+		return errors.New("missing or invalid chainId")
+	}
+	if err := g.Config.CheckConsensus(); err != nil {
+		return err
+	}
+	if err := g.Config.CheckConfigForkOrder(); err != nil {
+		return err
+	}
+	if g.GasLimit == 0 {
+		return errors.New("gasLimit must not be zero")
+	}
+	if len(g.Miners) == 0 {
+		return errors.New("no initial signers in miners")
+	}
+	seen := make(map[types.Address]struct{}, len(g.Miners))
+	for _, miner := range g.Miners {
+		addr, err := types.HexToString(miner)
+		if err != nil {
+			return fmt.Errorf("invalid miner %q: %v", miner, err)
+		}
+		if _, ok := seen[addr]; ok {
+			return fmt.Errorf("duplicate miner %s", miner)
+		}
+		seen[addr] = struct{}{}
+	}
+	if g.AllocFile != "" && len(g.Alloc) > 0 {
+		return errors.New("alloc and allocFile are exclusive")
+	}
+	for addr, account := range g.Alloc {
+		if err := account.Validate(); err != nil {
+			return fmt.Errorf("%v of %s in alloc", err, addr)
+		}
+	}
+	return nil
+}
+
+// Validate checks the balance of an allocated account.
+func (a *GenesisAccount) Validate() error {
+	balance, ok := new(big.Int).SetString(a.Balance, 10)
+	if !ok || balance.Sign() < 0 || balance.BitLen() > 256 {
+		return fmt.Errorf("invalid balance %q", a.Balance)
+	}
+	return nil
+}
@@ -17,8 +17,17 @@
 package conf
 
 type LoggerConfig struct {
-	LogFile    string `json:"name" yaml:"name"`
-	Level      string `json:"level" yaml:"level"`
+	LogFile string `json:"name" yaml:"name"`
+	// File is the path of the log file, overriding LogFile, which is kept in
+	// the log directory of the data directory.
+	File  string `json:"file" yaml:"file"`
+	Level string `json:"level" yaml:"level"`
+	// Format is the format of the console output, "text" or "json". The log
+	// file is always written in JSON.
+	Format string `json:"format" yaml:"format"`
+	// Vmodule sets the level of single modules, as a comma separated list of
+	// module=level pairs such as "internal/sync=debug,txpool=trace".
+	Vmodule    string `json:"vmodule" yaml:"vmodule"`
 	MaxSize    int    `json:"max_size" yaml:"max_size"`
 	MaxBackups int    `json:"max_count" yaml:"max_count"`
 	MaxAge     int    `json:"max_day" yaml:"max_day"`
@@ -16,8 +16,31 @@
 
 package conf
 
+import "time"
+
 type MetricsConfig struct {
 	Enable bool   `json:"enable" yaml:"enable"`
 	Port   int    `json:"port" yaml:"port"`
 	HTTP   string `json:"http" yaml:"http"`
+
+	// Exporters pushing the metrics, for monitoring stacks that can't scrape.
+	InfluxDB             bool          `json:"influxdb" yaml:"influxdb"`
+	InfluxDBV2           bool          `json:"influxdbv2" yaml:"influxdbv2"`
+	InfluxDBEndpoint     string        `json:"influxdb_endpoint" yaml:"influxdb_endpoint"`
+	InfluxDBDatabase     string        `json:"influxdb_database" yaml:"influxdb_database"`
+	InfluxDBUsername     string        `json:"influxdb_username" yaml:"influxdb_username"`
+	InfluxDBPassword     string        `json:"influxdb_password" yaml:"influxdb_password"`
+	InfluxDBToken        string        `json:"influxdb_token" yaml:"influxdb_token"`
+	InfluxDBBucket       string        `json:"influxdb_bucket" yaml:"influxdb_bucket"`
+	InfluxDBOrganization string        `json:"influxdb_organization" yaml:"influxdb_organization"`
+	OTLPEndpoint         string        `json:"otlp_endpoint" yaml:"otlp_endpoint"`
+	OTLPHeaders          string        `json:"otlp_headers" yaml:"otlp_headers"`
+	PushInterval         time.Duration `json:"push_interval" yaml:"push_interval"`
+	Tags                 string        `json:"tags" yaml:"tags"`
+
+	// Traces of the block import pipeline, exported over OTLP/HTTP.
+	Tracing            bool    `json:"tracing" yaml:"tracing"`
+	TracingEndpoint    string  `json:"tracing_endpoint" yaml:"tracing_endpoint"`
+	TracingHeaders     string  `json:"tracing_headers" yaml:"tracing_headers"`
+	TracingSampleRatio float64 `json:"tracing_sample_ratio" yaml:"tracing_sample_ratio"`
 }
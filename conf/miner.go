@@ -26,4 +26,5 @@ type MinerConfig struct {
 	GasCeil   uint64        // Target gas ceiling for mined blocks.
 	GasPrice  *big.Int      // Minimum gas price for mining a transaction
 	Recommit  time.Duration // The time interval for miner to re-create mining work
+	Signer    string        // Endpoint of a remote signer sealing blocks instead of the local keystore
 }
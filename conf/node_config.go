@@ -17,8 +17,12 @@
 package conf
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -35,6 +39,9 @@ type NodeConfig struct {
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
 	// useless for custom HTTP clients.
 	HTTPCors string `json:"http_cors" yaml:"http_cors"`
+	// HTTPVirtualHosts is the comma separated list of virtual hostnames which are
+	// allowed on incoming requests. Requests addressed to an IP are always served.
+	HTTPVirtualHosts string `json:"http_vhosts" yaml:"http_vhosts"`
 
 	WS     bool   `json:"ws" yaml:"ws" `
 	WSHost string `json:"ws_host" yaml:"ws_host" `
@@ -43,12 +50,116 @@ type NodeConfig struct {
 	// WSOrigins is the list of domain to accept websocket requests from. Please be
 	// aware that the server can only act upon the HTTP request the client sends and
 	// cannot verify the validity of the request header.
-	WSOrigins        string `toml:",omitempty"`
-	IPCPath          string `json:"ipc_path" yaml:"ipc_path"`
-	DataDir          string `json:"data_dir" yaml:"data_dir"`
-	MinFreeDiskSpace int    `json:"min_free_disk_space" yaml:"min_free_disk_space"`
-	Chain            string `json:"chain" yaml:"chain"`
-	Miner            bool   `json:"miner" yaml:"miner"`
+	WSOrigins string `toml:",omitempty"`
+	IPCPath   string `json:"ipc_path" yaml:"ipc_path"`
+	IPCApi    string `json:"ipc_api" yaml:"ipc_api"`
+	DataDir   string `json:"data_dir" yaml:"data_dir"`
+	// DBEngine is the key-value store of the chain database: "mdbx", "pebble"
+	// or "memory", which keeps nothing once the node stops.
+	DBEngine string `json:"db_engine" yaml:"db_engine"`
+	// MinFreeDiskSpace is the free space, in GB, of the data directory volume
+	// below which the block import is paused (0 = never). WarnFreeDiskSpace
+	// is the one below which a warning is logged.
+	MinFreeDiskSpace  int    `json:"min_free_disk_space" yaml:"min_free_disk_space"`
+	WarnFreeDiskSpace int    `json:"warn_free_disk_space" yaml:"warn_free_disk_space"`
+	Chain             string `json:"chain" yaml:"chain"`
+	Miner             bool   `json:"miner" yaml:"miner"`
+	// DBCompactInterval is how often a Pebble chain database is compacted in
+	// the background (0 = never). DBCompactMinFreeDisk is the free disk space,
+	// in GB, below which compactions don't run.
+	DBCompactInterval    time.Duration `json:"db_compact_interval" yaml:"db_compact_interval"`
+	DBCompactMinFreeDisk int           `json:"db_compact_min_free_disk" yaml:"db_compact_min_free_disk"`
+	// SyncMode selects how an empty node catches up with the network: "full"
+	// executes every block, "snap" downloads the state of a recent block first
+	// and "light" only follows the headers, asking full nodes for the rest.
+	SyncMode string `json:"sync_mode" yaml:"sync_mode"`
+	// LightServe is the number of light clients a full node serves at a time
+	// (0 = don't serve). LightConfirm is the number of servers a light client
+	// needs to return the same account before trusting it, account state
+	// isn't proven (see docs/run/light-client.md).
+	LightServe   int `json:"light_serve" yaml:"light_serve"`
+	LightConfirm int `json:"light_confirm" yaml:"light_confirm"`
+	// Checkpoint is a trusted "<number>:<hash>" block overriding the network's
+	// default sync checkpoint.
+	Checkpoint string `json:"checkpoint" yaml:"checkpoint"`
+	// GCMode is "full" to prune the state history older than PruneHistory
+	// blocks, or "archive" to keep the state of every block.
+	GCMode string `json:"gc_mode" yaml:"gc_mode"`
+	// PruneHistory is the number of recent blocks whose state history a full
+	// node keeps, older history being pruned in the background (0 = keep all).
+	PruneHistory uint64 `json:"prune_history" yaml:"prune_history"`
+	// AncientThreshold is the number of recent blocks kept in the chain
+	// database, older finalized blocks are moved to the ancient store in the
+	// data directory (0 = move none).
+	AncientThreshold uint64 `json:"ancient_threshold" yaml:"ancient_threshold"`
+	// TxLookupLimit is the number of recent blocks whose transactions are
+	// indexed by hash, older entries being deleted in the background
+	// (0 = index the whole chain).
+	TxLookupLimit uint64 `json:"tx_lookup_limit" yaml:"tx_lookup_limit"`
+	// TrieCache is the memory, in megabytes, of the cache of state entries
+	// serving block execution. The flat state has no trie nodes to cache.
+	TrieCache int `json:"trie_cache" yaml:"trie_cache"`
+	// DBCompress compresses the transactions and receipts written to the
+	// chain database with snappy.
+	DBCompress bool `json:"db_compress" yaml:"db_compress"`
+	// DBReadOnly opens the chain database of another node without writing to
+	// it, to serve the RPC or run analytics next to that node.
+	DBReadOnly bool `json:"db_read_only" yaml:"db_read_only"`
+	// Preimages records the inputs of the keccak256 hashes of the imported
+	// blocks, so that hashed addresses and storage keys can be resolved.
+	Preimages bool `json:"preimages" yaml:"preimages"`
+	// ParallelExec executes the transactions of the imported blocks in
+	// parallel, executing again those conflicting with earlier ones.
+	ParallelExec bool `json:"parallel_exec" yaml:"parallel_exec"`
+	// EVMProfile aggregates the gas and time the imported blocks spend per
+	// opcode and per contract, for debug_evmProfile.
+	EVMProfile bool `json:"evm_profile" yaml:"evm_profile"`
+	// Dev runs a single node network on a generated genesis, sealed by a
+	// funded developer account. Blocks are sealed as soon as transactions
+	// are pending, or every DevPeriod seconds if it is set.
+	Dev       bool   `json:"dev" yaml:"dev"`
+	DevPeriod uint64 `json:"dev_period" yaml:"dev_period"`
+	// ReadyMinPeers and ReadyMaxBlockLag are the thresholds of the /ready
+	// endpoint: the peers to be connected to and the number of blocks the
+	// head may be behind the highest peer.
+	ReadyMinPeers    int    `json:"ready_min_peers" yaml:"ready_min_peers"`
+	ReadyMaxBlockLag uint64 `json:"ready_max_block_lag" yaml:"ready_max_block_lag"`
+	// StallTimeout is the time without a new head after which the head is
+	// reported stalled and the node unready (0 = ten block periods).
+	StallTimeout time.Duration `json:"stall_timeout" yaml:"stall_timeout"`
+	// ReorgAlertDepth is the number of dropped blocks from which a reorg is
+	// reported, and ReorgWebhook the URL the reports are posted to as JSON.
+	ReorgAlertDepth uint64 `json:"reorg_alert_depth" yaml:"reorg_alert_depth"`
+	ReorgWebhook    string `json:"reorg_webhook" yaml:"reorg_webhook"`
+	// ShutdownTimeout is how long the services may take to stop on a signal
+	// before the process exits anyway (0 = wait forever).
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	// ForkOverrides reschedules hard forks of the chain config by name, at a
+	// block number or, for the timestamp forks, a block time. For testing.
+	ForkOverrides map[string]uint64 `json:"fork_overrides" yaml:"fork_overrides"`
+
+	// RPCGasCap is the global gas cap for eth_call-like requests (0 = no cap)
+	// and RPCEVMTimeout the global timeout of their EVM execution.
+	RPCGasCap     uint64        `json:"rpc_gas_cap" yaml:"rpc_gas_cap"`
+	RPCEVMTimeout time.Duration `json:"rpc_evm_timeout" yaml:"rpc_evm_timeout"`
+
+	// RPCRateLimit is the number of requests per second accepted from a single
+	// remote host over HTTP and WS, RPCRateBurst the size of its burst.
+	RPCRateLimit float64 `json:"rpc_rate_limit" yaml:"rpc_rate_limit"`
+	RPCRateBurst int     `json:"rpc_rate_burst" yaml:"rpc_rate_burst"`
+	// RPCMethodLimits is a comma separated list of method=rate pairs capping
+	// the requests per second of individual methods, e.g. "eth_getLogs=10".
+	RPCMethodLimits string `json:"rpc_method_limits" yaml:"rpc_method_limits"`
+	// RPCCallTimeout aborts requests running longer than this (0 = unlimited).
+	RPCCallTimeout time.Duration `json:"rpc_call_timeout" yaml:"rpc_call_timeout"`
+	// RPCMaxResponseSize is the maximum size of a response in bytes (0 = unlimited).
+	RPCMaxResponseSize int `json:"rpc_max_response_size" yaml:"rpc_max_response_size"`
+	// RPCSlowThreshold is the duration above which served requests are logged
+	// as slow (0 = disabled).
+	RPCSlowThreshold time.Duration `json:"rpc_slow_threshold" yaml:"rpc_slow_threshold"`
+	// RPCAPIKeys is a JSON file of API keys; when set, the public HTTP and WS
+	// endpoints only serve requests carrying one of them.
+	RPCAPIKeys string `json:"rpc_api_keys" yaml:"rpc_api_keys"`
 
 	AuthRPC bool `json:"auth_rpc" yaml:"auth_rpc"`
 	// AuthAddr is the listening address on which authenticated APIs are provided.
@@ -57,9 +168,16 @@ type NodeConfig struct {
 	// AuthPort is the port number on which authenticated APIs are provided.
 	AuthPort int `json:"auth_port" yaml:"auth_port"`
 
-	// AuthVirtualHosts is the list of virtual hostnames which are allowed on incoming requests
-	// for the authenticated api. This is by default {'localhost'}.
-	AuthVirtualHosts []string `json:"auth_virtual_hosts" yaml:"auth_virtual_hosts"`
+	// AuthApi is the comma separated list of namespaces served by the authenticated api.
+	AuthApi string `json:"auth_api" yaml:"auth_api"`
+
+	// AuthCors is the list of origins allowed to issue cross origin requests
+	// to the authenticated api.
+	AuthCors string `json:"auth_cors" yaml:"auth_cors"`
+
+	// AuthVirtualHosts is the comma separated list of virtual hostnames which are allowed
+	// on incoming requests for the authenticated api. This is by default "localhost".
+	AuthVirtualHosts string `json:"auth_virtual_hosts" yaml:"auth_virtual_hosts"`
 
 	// JWTSecret is the path to the hex-encoded jwt secret.
 	JWTSecret string `json:"jwt_secret" yaml:"jwt_secret"`
@@ -80,6 +198,16 @@ type NodeConfig struct {
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `json:"use_lightweight_kdf" yaml:"use_lightweight_kdf"`
 
+	// ScryptN and ScryptP replace the scrypt parameters of the standard or
+	// light preset for the key files written from now on. Zero keeps those
+	// of the preset.
+	ScryptN int `json:"scrypt_n" yaml:"scrypt_n"`
+	ScryptP int `json:"scrypt_p" yaml:"scrypt_p"`
+
+	// UnlockDuration is how long the accounts unlocked at startup stay
+	// unlocked, zero meaning until the node stops.
+	UnlockDuration time.Duration `json:"unlock_duration" yaml:"unlock_duration"`
+
 	// InsecureUnlockAllowed allows user to unlock accounts in unsafe http environment.
 	InsecureUnlockAllowed bool `json:"insecure_unlock_allowed" yaml:"insecure_unlock_allowed"`
 
@@ -134,5 +262,27 @@ func getKeyStoreDir(conf *NodeConfig) (string, bool, error) {
 // ExtRPCEnabled returns the indicator whether node enables the external
 // RPC(http, ws or graphql).
 func (c *NodeConfig) ExtRPCEnabled() bool {
-	return c.HTTPHost != "" || c.WSHost != ""
+	return (c.HTTP && c.HTTPHost != "") || (c.WS && c.WSHost != "")
+}
+
+// MethodRateLimits parses RPCMethodLimits into a map of method names to their
+// allowed requests per second.
+func (c *NodeConfig) MethodRateLimits() (map[string]float64, error) {
+	limits := make(map[string]float64)
+	for _, entry := range strings.Split(c.RPCMethodLimits, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rpc method limit %q, want method=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate in rpc method limit %q", entry)
+		}
+		limits[strings.TrimSpace(parts[0])] = rate
+	}
+	return limits, nil
 }
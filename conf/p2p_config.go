@@ -30,21 +30,43 @@ type P2PConfig struct {
 	StaticPeers         []string `json:"static_peers" yaml:"static_peers"`
 	BootstrapNodeAddr   []string `json:"bootstrap_node_addr" yaml:"bootstrap_node_addr"`
 	Discv5BootStrapAddr []string `json:"discv5_bootstrap_addr" yaml:"discv5_bootstrap_addr"`
+	DiscoveryDNS        []string `json:"discovery_dns" yaml:"discovery_dns"`
 	RelayNodeAddr       string   `json:"relay_node_addr" yaml:"relay_node_addr"`
 	LocalIP             string   `json:"local_ip" yaml:"local_ip"`
 	HostAddress         string   `json:"host_address" yaml:"host_address"`
 	HostDNS             string   `json:"host_dns" yaml:"host_dns"`
+	NAT                 string   `json:"nat" yaml:"nat"`
 	PrivateKey          string   `json:"private_key" yaml:"private_key"`
+	PrivateKeyHex       string   `json:"private_key_hex" yaml:"private_key_hex"`
 	DataDir             string   `json:"data_dir" yaml:"data_dir"`
 	MetaDataDir         string   `json:"metadata_dir" yaml:"metadata_dir"`
 	TCPPort             int      `json:"tcp_port" yaml:"tcp_port"`
 	UDPPort             int      `json:"udp_port" yaml:"udp_port"`
 	MaxPeers            int      `json:"max_peers" yaml:"max_peers"`
+	MaxInboundPeers     int      `json:"max_inbound_peers" yaml:"max_inbound_peers"`
+	MaxOutboundPeers    int      `json:"max_outbound_peers" yaml:"max_outbound_peers"`
+	DialRatio           int      `json:"dial_ratio" yaml:"dial_ratio"`
 	AllowListCIDR       string   `json:"allow_list_cidr" yaml:"allow_list_cidr"`
 	DenyListCIDR        []string `json:"deny_list_cidr" yaml:"deny_list_cidr"`
+	NetRestrict         string   `json:"net_restrict" yaml:"net_restrict"`
 	MinSyncPeers        int      `json:"min_sync_peers" yaml:"min_sync_peers"`
+	MaxClockDisparity   int      `json:"max_clock_disparity" yaml:"max_clock_disparity"`
 
-	P2PLimit *P2PLimit
+	P2PLimit    *P2PLimit
+	GossipScore *GossipScore
+}
+
+// GossipScore tunes the gossipsub peer scoring, zero values keep the defaults.
+// Peers below the thresholds lose gossip, publishing and finally all of their
+// messages; every invalid block costs a peer InvalidBlockWeight.
+type GossipScore struct {
+	GossipThreshold             float64 `json:"gossip_threshold" yaml:"gossip_threshold"`
+	PublishThreshold            float64 `json:"publish_threshold" yaml:"publish_threshold"`
+	GraylistThreshold           float64 `json:"graylist_threshold" yaml:"graylist_threshold"`
+	AcceptPXThreshold           float64 `json:"accept_px_threshold" yaml:"accept_px_threshold"`
+	OpportunisticGraftThreshold float64 `json:"opportunistic_graft_threshold" yaml:"opportunistic_graft_threshold"`
+	IPColocationFactorThreshold int     `json:"ip_colocation_factor_threshold" yaml:"ip_colocation_factor_threshold"`
+	InvalidBlockWeight          float64 `json:"invalid_block_weight" yaml:"invalid_block_weight"`
 }
 
 type P2PLimit struct {
@@ -17,9 +17,15 @@
 package conf
 
 type PprofConfig struct {
-	MaxCpu     int  `json:"cpu" yaml:"cpu"`
-	Port       int  `json:"port" yaml:"port"`
-	TraceMutex bool `json:"trace_mutex" yaml:"trace_mutex"`
-	TraceBlock bool `json:"trace_block" yaml:"trace_block"`
-	Pprof      bool `json:"pprof" yaml:"pprof"`
+	MaxCpu     int    `json:"cpu" yaml:"cpu"`
+	Addr       string `json:"addr" yaml:"addr"`
+	Port       int    `json:"port" yaml:"port"`
+	TraceMutex bool   `json:"trace_mutex" yaml:"trace_mutex"`
+	TraceBlock bool   `json:"trace_block" yaml:"trace_block"`
+	Pprof      bool   `json:"pprof" yaml:"pprof"`
+	// BlockProfileRate and MutexProfileFraction are the runtime sampling
+	// rates of the block and mutex profiles, 0 leaving them to TraceBlock and
+	// TraceMutex, which sample every event.
+	BlockProfileRate     int `json:"block_profile_rate" yaml:"block_profile_rate"`
+	MutexProfileFraction int `json:"mutex_profile_fraction" yaml:"mutex_profile_fraction"`
 }
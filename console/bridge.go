@@ -0,0 +1,208 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// passwordArgs holds the position of the password argument of the methods
+// prompting for it when it's left out.
+var passwordArgs = map[string]int{
+	"personal_newAccount":    0,
+	"personal_unlockAccount": 1,
+}
+
+// namespace exposes the methods of an RPC namespace as the functions of a
+// JavaScript object: eth.blockNumber() calls eth_blockNumber. Without the
+// method list of the node, any property is taken for a method.
+type namespace struct {
+	c       *Console
+	name    string
+	methods []string
+}
+
+func (ns *namespace) Get(key string) goja.Value {
+	if !ns.Has(key) {
+		return nil
+	}
+	method := ns.name + "_" + key
+	return ns.c.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		return ns.c.call(method, call.Arguments)
+	})
+}
+
+func (ns *namespace) Has(key string) bool {
+	if ns.methods == nil {
+		return key != ""
+	}
+	i := sort.SearchStrings(ns.methods, key)
+	return i < len(ns.methods) && ns.methods[i] == key
+}
+
+func (ns *namespace) Set(string, goja.Value) bool { return false }
+func (ns *namespace) Delete(string) bool          { return false }
+func (ns *namespace) Keys() []string              { return ns.methods }
+
+// bind installs the console helpers and one object per RPC namespace.
+func (c *Console) bind() error {
+	console := c.vm.NewObject()
+	console.Set("log", c.log)
+	console.Set("error", c.log)
+	c.vm.Set("console", console)
+	// The rpc namespace is taken by the node, raw calls go through request.
+	c.vm.Set("request", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) == 0 {
+			panic(c.vm.NewTypeError("request: method name missing"))
+		}
+		return c.call(call.Argument(0).String(), call.Arguments[1:])
+	})
+	c.vm.Set("loadScript", func(call goja.FunctionCall) goja.Value {
+		path := call.Argument(0).String()
+		code, err := os.ReadFile(c.resolve(path))
+		if err != nil {
+			c.throw(err)
+		}
+		value, err := c.vm.RunScript(path, string(code))
+		if err != nil {
+			c.throw(err)
+		}
+		return value
+	})
+	c.vm.Set("sleep", func(call goja.FunctionCall) goja.Value {
+		time.Sleep(time.Duration(call.Argument(0).ToFloat() * float64(time.Second)))
+		return goja.Undefined()
+	})
+
+	modules, err := c.client.SupportedModules()
+	if err != nil {
+		return fmt.Errorf("api modules: %v", err)
+	}
+	// Nodes not listing their methods get namespaces accepting any of them.
+	var methods map[string][]string
+	if err := c.client.Call(&methods, "rpc_methods"); err != nil {
+		methods = nil
+	}
+	for name := range modules {
+		c.modules = append(c.modules, name)
+	}
+	sort.Strings(c.modules)
+	for _, name := range c.modules {
+		ns := &namespace{c: c, name: name}
+		if methods != nil {
+			ns.methods = methods[name]
+			sort.Strings(ns.methods)
+		}
+		if err := c.vm.Set(name, c.vm.NewDynamicObject(ns)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call sends an RPC request carrying the JavaScript arguments and converts
+// the result back, errors are thrown.
+func (c *Console) call(method string, arguments []goja.Value) goja.Value {
+	args := make([]interface{}, len(arguments))
+	for i, arg := range arguments {
+		args[i] = arg.Export()
+	}
+	// Trailing undefined arguments are left out, the node fills in defaults.
+	for len(args) > 0 && args[len(args)-1] == nil {
+		args = args[:len(args)-1]
+	}
+	if i, ok := passwordArgs[method]; ok && i >= len(args) {
+		password, err := c.prompter.PromptPassword("Passphrase: ")
+		if err != nil {
+			c.throw(err)
+		}
+		for len(args) < i {
+			args = append(args, nil)
+		}
+		args = append(args, password)
+	}
+
+	var raw json.RawMessage
+	if err := c.client.CallContext(context.Background(), &raw, method, args...); err != nil {
+		c.throw(err)
+	}
+	var result interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &result); err != nil {
+			c.throw(err)
+		}
+	}
+	if result == nil {
+		return goja.Null()
+	}
+	return c.vm.ToValue(result)
+}
+
+// throw raises err as a JavaScript Error.
+func (c *Console) throw(err error) {
+	constructor, _ := goja.AssertConstructor(c.vm.Get("Error"))
+	if obj, cerr := constructor(nil, c.vm.ToValue(err.Error())); cerr == nil {
+		panic(obj)
+	}
+	panic(c.vm.NewGoError(err))
+}
+
+// log prints its arguments like console.log.
+func (c *Console) log(call goja.FunctionCall) goja.Value {
+	parts := make([]string, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		if s, ok := arg.Export().(string); ok {
+			parts[i] = s
+		} else {
+			parts[i] = c.format(arg)
+		}
+	}
+	fmt.Fprintln(c.printer, strings.Join(parts, " "))
+	return goja.Undefined()
+}
+
+// format renders a value as indented JSON, functions and namespaces by name.
+func (c *Console) format(value goja.Value) string {
+	if value == nil || goja.IsUndefined(value) {
+		return "undefined"
+	}
+	if _, ok := goja.AssertFunction(value); ok {
+		return "function()"
+	}
+	switch exported := value.Export().(type) {
+	case *namespace:
+		if exported.methods == nil {
+			return "{ " + exported.name + " }"
+		}
+		return "{ " + exported.name + ": " + strings.Join(exported.methods, ", ") + " }"
+	case error:
+		return exported.Error()
+	}
+	out, err := json.MarshalIndent(value.Export(), "", "  ")
+	if err != nil {
+		return value.String()
+	}
+	return string(out)
+}
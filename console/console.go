@@ -0,0 +1,374 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package console
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/amazechain/amc/console/prompt"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/dop251/goja"
+	"github.com/peterh/liner"
+)
+
+const (
+	// HistoryFile is the file within the data directory to store the input
+	// scrollback history in.
+	HistoryFile = "history"
+
+	// DefaultPrompt is the default prompt line prefix to use for user input querying.
+	DefaultPrompt = "> "
+
+	// maxHistory is the number of commands kept in the history file.
+	maxHistory = 1000
+)
+
+var (
+	// passwordRegexp matches the commands that may carry a password, which are
+	// never written to the history.
+	passwordRegexp = regexp.MustCompile(`personal\.[nui]`)
+
+	// errInterrupted is raised in the runtime when the user hits ctrl-c during
+	// an evaluation.
+	errInterrupted = errors.New("interrupted")
+)
+
+// Config is the collection of configurations to fine tune the behavior of the
+// JavaScript console.
+type Config struct {
+	DataDir  string              // Data directory to store the console history at
+	DocRoot  string              // Filesystem path from where to load JavaScript files from
+	Client   *jsonrpc.Client     // RPC client to execute AmazeChain requests through
+	Prompt   string              // Input prompt prefix string (defaults to DefaultPrompt)
+	Prompter prompt.UserPrompter // Input prompter to allow interactive user feedback (defaults to prompt.Stdin)
+	Printer  io.Writer           // Output writer to serialize any display strings to (defaults to os.Stdout)
+	Preload  []string            // Absolute paths to JavaScript files to preload
+}
+
+// Console is a JavaScript interpreted runtime environment with every RPC
+// namespace of the attached node bound as a global object.
+type Console struct {
+	client   *jsonrpc.Client
+	vm       *goja.Runtime
+	prompt   string
+	prompter prompt.UserPrompter
+	printer  io.Writer
+	docRoot  string
+	histPath string
+	history  []string
+	modules  []string
+}
+
+// New initializes a JavaScript interpreted runtime environment and sets
+// defaults with the config struct.
+func New(config Config) (*Console, error) {
+	if config.Client == nil {
+		return nil, errors.New("console: no RPC client")
+	}
+	if config.Prompter == nil {
+		config.Prompter = prompt.Stdin
+	}
+	if config.Prompt == "" {
+		config.Prompt = DefaultPrompt
+	}
+	if config.Printer == nil {
+		config.Printer = os.Stdout
+	}
+	c := &Console{
+		client:   config.Client,
+		vm:       goja.New(),
+		prompt:   config.Prompt,
+		prompter: config.Prompter,
+		printer:  config.Printer,
+		docRoot:  config.DocRoot,
+	}
+	if config.DataDir != "" {
+		c.histPath = filepath.Join(config.DataDir, HistoryFile)
+	}
+	if err := c.init(config.Preload); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// init binds the console helpers and the RPC namespaces, loads the history
+// and runs the preloaded scripts.
+func (c *Console) init(preload []string) error {
+	if err := c.bind(); err != nil {
+		return err
+	}
+	if c.histPath != "" {
+		if content, err := os.ReadFile(c.histPath); err == nil {
+			c.history = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+			c.prompter.SetHistory(c.history)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read history file %s: %v", c.histPath, err)
+		}
+	}
+	c.prompter.SetWordCompleter(c.complete)
+
+	for _, path := range preload {
+		if err := c.Execute(path); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// Welcome shows a summary of the node the console is attached to.
+func (c *Console) Welcome() {
+	message := "Welcome to the AmazeChain JavaScript console!\n\n"
+
+	var version, coinbase, number string
+	if err := c.client.Call(&version, "web3_clientVersion"); err == nil {
+		message += "instance: " + version + "\n"
+	}
+	if err := c.client.Call(&coinbase, "eth_coinbase"); err == nil {
+		message += "coinbase: " + coinbase + "\n"
+	}
+	if err := c.client.Call(&number, "eth_blockNumber"); err == nil {
+		message += "at block: " + number + "\n"
+	}
+	if len(c.modules) > 0 {
+		message += " modules: " + strings.Join(c.modules, " ") + "\n"
+	}
+	message += "\nTo exit, press ctrl-d or type exit"
+	fmt.Fprintln(c.printer, message)
+}
+
+// Evaluate executes code and pretty prints the result to the specified output
+// stream. Errors are printed as well as returned.
+func (c *Console) Evaluate(statement string) error {
+	value, err := c.run("<console>", statement)
+	if err != nil {
+		fmt.Fprintln(c.printer, formatError(err))
+		return err
+	}
+	fmt.Fprintln(c.printer, c.format(value))
+	return nil
+}
+
+// Execute runs the JavaScript file specified as the argument.
+func (c *Console) Execute(path string) error {
+	code, err := os.ReadFile(c.resolve(path))
+	if err != nil {
+		return err
+	}
+	_, err = c.run(path, string(code))
+	return err
+}
+
+// run evaluates code, which ctrl-c interrupts.
+func (c *Console) run(name, code string) (goja.Value, error) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(sigc)
+		close(done)
+		c.vm.ClearInterrupt()
+	}()
+	go func() {
+		select {
+		case <-sigc:
+			c.vm.Interrupt(errInterrupted)
+		case <-done:
+		}
+	}()
+	return c.vm.RunScript(name, code)
+}
+
+// Interactive starts an interactive user session, where input is prompted from
+// the configured user prompter. Statements spanning several lines are read
+// until their brackets are balanced.
+func (c *Console) Interactive() {
+	var (
+		input  string
+		indent int
+	)
+	for {
+		line, err := c.prompter.PromptInput(c.prompt + strings.Repeat(".", indent*3) + spaceIf(indent > 0))
+		if err != nil {
+			if errors.Is(err, liner.ErrPromptAborted) {
+				// Ctrl-C drops the statement being typed.
+				if input == "" {
+					fmt.Fprintln(c.printer, "(To exit, press ctrl-d or type exit)")
+				}
+				input, indent = "", 0
+				continue
+			}
+			if err != io.EOF {
+				fmt.Fprintln(c.printer, "Error reading input:", err)
+			}
+			fmt.Fprintln(c.printer)
+			return
+		}
+		if indent <= 0 && strings.TrimSpace(line) == "exit" {
+			return
+		}
+		input += line + "\n"
+		if indent = countIndents(input); indent > 0 {
+			continue
+		}
+		statement := strings.TrimSpace(input)
+		input = ""
+		if statement == "" {
+			continue
+		}
+		if !passwordRegexp.MatchString(statement) && (len(c.history) == 0 || c.history[len(c.history)-1] != statement) {
+			c.history = append(c.history, statement)
+			c.prompter.AppendHistory(statement)
+		}
+		c.Evaluate(statement)
+	}
+}
+
+// Stop cleans up the console and writes the history to disk.
+func (c *Console) Stop() error {
+	if c.histPath == "" || len(c.history) == 0 {
+		return nil
+	}
+	history := c.history
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return os.WriteFile(c.histPath, []byte(strings.Join(history, "\n")+"\n"), 0600)
+}
+
+// resolve makes a script path relative to the document root.
+func (c *Console) resolve(path string) string {
+	if filepath.IsAbs(path) || c.docRoot == "" {
+		return path
+	}
+	return filepath.Join(c.docRoot, path)
+}
+
+// complete offers the properties of the object left of the last dot of the
+// edited word, or the globals if there is none.
+func (c *Console) complete(line string, pos int) (string, []string, string) {
+	if len(line) == 0 || pos == 0 {
+		return "", nil, ""
+	}
+	start := pos - 1
+	for ; start > 0; start-- {
+		if ch := line[start-1]; ch != '.' && ch != '_' && !isIdentChar(ch) {
+			break
+		}
+	}
+	word := line[start:pos]
+
+	obj := c.vm.GlobalObject()
+	path, partial := "", word
+	if dot := strings.LastIndexByte(word, '.'); dot >= 0 {
+		path, partial = word[:dot+1], word[dot+1:]
+		for _, name := range strings.Split(word[:dot], ".") {
+			value := obj.Get(name)
+			if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+				return "", nil, ""
+			}
+			if obj = value.ToObject(c.vm); obj == nil {
+				return "", nil, ""
+			}
+		}
+	}
+	var candidates []string
+	for _, key := range obj.Keys() {
+		if strings.HasPrefix(key, partial) {
+			candidates = append(candidates, path+key)
+		}
+	}
+	sort.Strings(candidates)
+	return line[:start], candidates, line[pos:]
+}
+
+// countIndents returns the number of brackets left open in input, ignoring
+// those within strings and comments.
+func countIndents(input string) int {
+	var (
+		indents    int
+		quote      byte
+		escaped    bool
+		lineCmt    bool
+		blockCmt   bool
+		charBefore byte
+	)
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+		switch {
+		case lineCmt:
+			lineCmt = ch != '\n'
+		case blockCmt:
+			blockCmt = !(charBefore == '*' && ch == '/')
+		case quote != 0:
+			if escaped {
+				escaped = false
+			} else if ch == '\\' {
+				escaped = true
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '/' && charBefore == '/':
+			lineCmt = true
+		case ch == '*' && charBefore == '/':
+			blockCmt = true
+			ch = 0 // a closing slash right after doesn't end the comment
+		case ch == '"' || ch == '\'' || ch == '`':
+			quote = ch
+		case ch == '(' || ch == '[' || ch == '{':
+			indents++
+		case ch == ')' || ch == ']' || ch == '}':
+			indents--
+		}
+		charBefore = ch
+	}
+	return indents
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '$' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func spaceIf(ok bool) string {
+	if ok {
+		return " "
+	}
+	return ""
+}
+
+// formatError strips the JavaScript stack from errors thrown by the runtime.
+func formatError(err error) string {
+	var (
+		exception   *goja.Exception
+		interrupted *goja.InterruptedError
+	)
+	switch {
+	case errors.As(err, &interrupted):
+		return "Error: " + errInterrupted.Error()
+	case errors.As(err, &exception):
+		return exception.Value().String()
+	}
+	return "Error: " + err.Error()
+}
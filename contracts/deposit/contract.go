@@ -18,7 +18,9 @@ package deposit
 
 import (
 	"context"
+	"fmt"
 	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/crypto/bls"
 	"github.com/amazechain/amc/common/hexutil"
 	"github.com/amazechain/amc/common/transaction"
@@ -32,6 +34,10 @@ import (
 	"sync"
 )
 
+// defaultEpochLength matches the APoS engine default when the genesis
+// doesn't configure an epoch.
+const defaultEpochLength = 30000
+
 const (
 	//
 	DayPerMonth = 30
@@ -138,13 +144,14 @@ type Deposit struct {
 	blockChain common.IBlockChain
 	db         kv.RwDB
 
-	logsSub   event.Subscription // Subscription for new log event
 	rmLogsSub event.Subscription // Subscription for removed log event
 
-	logsCh   chan common.NewLogsEvent     // Channel to receive new log event
 	rmLogsCh chan common.RemovedLogsEvent // Channel to receive removed log event
 
 	depositContracts map[types.Address]DepositContract
+
+	epoch uint64 // APoS epoch length, the unit of activation and exit
+	churn uint64 // Maximum activations and exits per epoch, 0 for unlimited
 }
 
 func NewDeposit(ctx context.Context, bc common.IBlockChain, db kv.RwDB, depositContracts map[types.Address]DepositContract) *Deposit {
@@ -154,15 +161,20 @@ func NewDeposit(ctx context.Context, bc common.IBlockChain, db kv.RwDB, depositC
 		cancel:           cancel,
 		blockChain:       bc,
 		db:               db,
-		logsCh:           make(chan common.NewLogsEvent),
 		rmLogsCh:         make(chan common.RemovedLogsEvent),
 		depositContracts: depositContracts,
+		epoch:            defaultEpochLength,
+	}
+	if config := bc.Config(); config != nil && config.Apos != nil {
+		if config.Apos.Epoch != 0 {
+			d.epoch = config.Apos.Epoch
+		}
+		d.churn = config.Apos.ChurnLimit
 	}
 
-	d.logsSub = event.GlobalEvent.Subscribe(d.logsCh)
 	d.rmLogsSub = event.GlobalEvent.Subscribe(d.rmLogsCh)
 
-	if d.logsSub == nil || d.rmLogsSub == nil {
+	if d.rmLogsSub == nil {
 		log.Error("Subscribe for event system failed")
 	}
 	return d
@@ -208,7 +220,6 @@ func (d *Deposit) IsDepositAction(txs *transaction.Transaction) bool {
 func (d *Deposit) eventLoop() {
 	// Ensure all subscriptions get cleaned up
 	defer func() {
-		d.logsSub.Unsubscribe()
 		d.rmLogsSub.Unsubscribe()
 		d.wg.Done()
 		log.Info("Context closed, exiting goroutine (eventLoop)")
@@ -216,22 +227,10 @@ func (d *Deposit) eventLoop() {
 
 	for {
 		select {
-		case logEvent := <-d.logsCh:
-			for _, l := range logEvent.Logs {
-				if depositContract, found := d.depositContracts[l.Address]; found {
-					if l.Topics[0] == depositContract.DepositSignature() {
-						d.handleDepositEvent(l.TxHash, l.Data, depositContract)
-					} else if l.Topics[0] == depositContract.WithdrawnSignature() {
-						d.handleWithdrawnEvent(l.TxHash, l.Data)
-					}
-				}
-			}
 		case logRemovedEvent := <-d.rmLogsCh:
 			for _, l := range logRemovedEvent.Logs {
-				log.Info("logEvent", "address", l.Address, "data", l.Data, "")
+				log.Debug("Removed log", "address", l.Address, "block", l.BlockNumber, "tx", l.TxHash)
 			}
-		case <-d.logsSub.Err():
-			return
 		case <-d.rmLogsSub.Err():
 			return
 		case <-d.ctx.Done():
@@ -240,78 +239,209 @@ func (d *Deposit) eventLoop() {
 	}
 }
 
-func (d *Deposit) handleDepositEvent(txHash types.Hash, data []byte, depositContract DepositContract) {
-	// 1
+// Epoch returns the APoS epoch of the given block.
+func (d *Deposit) Epoch(number uint64) uint64 {
+	return number / d.epoch
+}
+
+// blockEvents returns the deposits and withdrawals of a block, in order.
+func (d *Deposit) blockEvents(b block.IBlock, receipts []*block.Receipt) []depositEvent {
+	var events []depositEvent
+	for _, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			depositContract, found := d.depositContracts[l.Address]
+			if !found || len(l.Topics) == 0 {
+				continue
+			}
+			var kind uint8
+			switch l.Topics[0] {
+			case depositContract.DepositSignature():
+				kind = eventDeposit
+			case depositContract.WithdrawnSignature():
+				kind = eventWithdrawal
+			default:
+				continue
+			}
+			from, ok := senderOf(b, l.TxHash)
+			if !ok {
+				log.Error("cannot find Transaction", "hash", l.TxHash)
+				continue
+			}
+			events = append(events, depositEvent{Kind: kind, Contract: l.Address, From: from, Data: l.Data})
+		}
+	}
+	return events
+}
+
+// processBlock applies the deposits and withdrawals of a block to the
+// validator lifecycle. Deposits queue for activation and withdrawals for
+// exit; at every epoch boundary the validators whose epoch arrived join or
+// leave the deposit set.
+func (d *Deposit) processBlock(tx kv.RwTx, number uint64, events []depositEvent) error {
+	epoch := d.Epoch(number)
+	for _, e := range events {
+		var err error
+		switch e.Kind {
+		case eventDeposit:
+			depositContract, found := d.depositContracts[e.Contract]
+			if !found {
+				log.Warn("Ignoring deposit to an unknown contract", "contract", e.Contract, "address", e.From)
+				continue
+			}
+			err = d.handleDeposit(tx, number, epoch, e.From, e.Data, depositContract)
+		case eventWithdrawal:
+			err = d.handleExit(tx, epoch, e.From)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if number%d.epoch == 0 {
+		return d.processEpoch(tx, epoch)
+	}
+	return nil
+}
+
+// senderOf returns the sender of the given transaction of the block.
+func senderOf(b block.IBlock, hash types.Hash) (types.Address, bool) {
+	for _, tx := range b.Transactions() {
+		if tx.Hash() == hash && tx.From() != nil {
+			return *tx.From(), true
+		}
+	}
+	return types.Address{}, false
+}
+
+// verifyDeposit unpacks a deposit log and checks the BLS signature over the
+// deposited amount.
+func verifyDeposit(data []byte, depositContract DepositContract) (types.PublicKey, *uint256.Int, error) {
 	pb, sig, amount, err := depositContract.UnpackDepositLogData(data)
 	if err != nil {
-		log.Warn("cannot unpack deposit log data", "err", err)
-		return
+		return types.PublicKey{}, nil, fmt.Errorf("cannot unpack deposit log data: %v", err)
 	}
-	// 2
 	signature, err := bls.SignatureFromBytes(sig)
 	if err != nil {
-		log.Warn("cannot unpack BLS signature", "signature", hexutil.Encode(sig), "err", err)
-		return
+		return types.PublicKey{}, nil, fmt.Errorf("cannot unpack BLS signature %s: %v", hexutil.Encode(sig), err)
 	}
-	// 3
 	publicKey, err := bls.PublicKeyFromBytes(pb)
 	if err != nil {
-		log.Warn("cannot unpack BLS publicKey", "publicKey", hexutil.Encode(pb), "err", err)
-		return
+		return types.PublicKey{}, nil, fmt.Errorf("cannot unpack BLS publicKey %s: %v", hexutil.Encode(pb), err)
 	}
-	// 4
 	log.Trace("DepositEvent verify:", "signature", hexutil.Encode(signature.Marshal()), "publicKey", hexutil.Encode(publicKey.Marshal()), "msg", hexutil.Encode(amount.Bytes()))
-	if signature.Verify(publicKey, amount.Bytes()) {
-		var tx *transaction.Transaction
-		rwTx, err := d.db.BeginRw(d.ctx)
-		defer rwTx.Rollback()
-		if err != nil {
-			log.Error("cannot open db", "err", err)
-			return
+	if !signature.Verify(publicKey, amount.Bytes()) {
+		return types.PublicKey{}, nil, fmt.Errorf("cannot verify deposit signature %s of publicKey %s", hexutil.Encode(sig), hexutil.Encode(pb))
+	}
+	var pub types.PublicKey
+	pub.SetBytes(publicKey.Marshal())
+	return pub, amount, nil
+}
+
+// handleDeposit queues a new validator for activation. Deposits of validators
+// that are already pending or active update their key and amount in place.
+func (d *Deposit) handleDeposit(tx kv.RwTx, number, epoch uint64, addr types.Address, data []byte, depositContract DepositContract) error {
+	pub, amount, err := verifyDeposit(data, depositContract)
+	if err != nil {
+		log.Warn("Ignoring invalid deposit", "address", addr, "err", err)
+		return nil
+	}
+	l, err := GetLifecycle(tx, addr)
+	if err != nil {
+		return err
+	}
+	if l != nil && l.ExitEpoch == 0 {
+		l.PublicKey, l.Amount = pub, amount
+		if l.Status(epoch) == StatusActive {
+			if err := rawdb.PutDeposit(tx, addr, pub, *amount); err != nil {
+				return err
+			}
 		}
+		log.Info("update Deposit info", "address", addr, "amount", amount.String())
+		return putLifecycle(tx, addr, l)
+	}
+	activation, err := queueSlot(tx, epoch+1, d.churn, rawdb.ReadActivationQueue, rawdb.WriteActivationQueue)
+	if err != nil {
+		return err
+	}
+	log.Info("add Deposit info", "address", addr, "amount", amount.String(), "activationEpoch", activation)
+	return putLifecycle(tx, addr, &Lifecycle{
+		DepositBlock:    number,
+		ActivationEpoch: activation,
+		PublicKey:       pub,
+		Amount:          amount,
+	})
+}
 
-		tx, _, _, _, err = rawdb.ReadTransactionByHash(rwTx, txHash)
+// handleExit schedules the voluntary exit of a validator. Pending validators
+// leave the activation queue right away, active ones keep verifying until the
+// exit epoch assigned by the exit queue.
+func (d *Deposit) handleExit(tx kv.RwTx, epoch uint64, addr types.Address) error {
+	l, err := GetLifecycle(tx, addr)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		// Deposits made before the lifecycle was tracked are active
+		pub, amount, err := rawdb.GetDeposit(tx, addr)
 		if err != nil {
-			log.Error("rawdb.ReadTransactionByHash", "err", err, "hash", txHash)
+			log.Warn("Ignoring withdrawal without deposit", "address", addr)
+			return nil
 		}
-
-		if tx != nil {
-			log.Info("add Deposit info", "address", tx.From(), "amount", amount.String())
-
-			var pub types.PublicKey
-			pub.SetBytes(publicKey.Marshal())
-			//
-			rawdb.PutDeposit(rwTx, *tx.From(), pub, *amount)
-			rwTx.Commit()
+		l = &Lifecycle{PublicKey: pub, Amount: amount}
+	}
+	if l.ExitEpoch != 0 {
+		return nil
+	}
+	if l.Status(epoch) == StatusPending {
+		if err := release(tx, l.ActivationEpoch, rawdb.ReadActivationQueue, rawdb.WriteActivationQueue); err != nil {
+			return err
 		}
+		l.ExitEpoch = epoch
 	} else {
-		log.Error("DepositEvent cannot Verify signature", "signature", hexutil.Encode(sig), "publicKey", hexutil.Encode(pb), "message", hexutil.Encode(amount.Bytes()), "err", err)
+		if l.ExitEpoch, err = queueSlot(tx, epoch+1, d.churn, rawdb.ReadExitQueue, rawdb.WriteExitQueue); err != nil {
+			return err
+		}
 	}
+	log.Info("schedule Deposit exit", "address", addr, "exitEpoch", l.ExitEpoch)
+	return putLifecycle(tx, addr, l)
 }
 
-func (d *Deposit) handleWithdrawnEvent(txHash types.Hash, data []byte) {
-	var tx *transaction.Transaction
-
-	rwTx, err := d.db.BeginRw(d.ctx)
-	defer rwTx.Rollback()
-	if err != nil {
-		log.Error("cannot open db", "err", err)
-		return
+// processEpoch brings the deposit set in line with the lifecycles at the start
+// of the given epoch.
+func (d *Deposit) processEpoch(tx kv.RwTx, epoch uint64) error {
+	var (
+		activate []types.Address
+		exit     []types.Address
+		records  = make(map[types.Address]*Lifecycle)
+	)
+	if err := rawdb.ForEachValidatorLifecycle(tx, func(addr types.Address, data []byte) error {
+		l, err := decodeLifecycle(data)
+		if err != nil {
+			return err
+		}
+		switch status := l.Status(epoch); {
+		case (status == StatusActive || status == StatusExiting) && !rawdb.IsDeposit(tx, addr):
+			activate = append(activate, addr)
+			records[addr] = l
+		case status == StatusExited && rawdb.IsDeposit(tx, addr):
+			exit = append(exit, addr)
+		}
+		return nil
+	}); err != nil {
+		return err
 	}
-	tx, _, _, _, err = rawdb.ReadTransactionByHash(rwTx, txHash)
-	if err != nil {
-		log.Error("rawdb.ReadTransactionByHash", "err", err, "hash", txHash)
-		return
+	for _, addr := range activate {
+		l := records[addr]
+		if err := rawdb.PutDeposit(tx, addr, l.PublicKey, *l.Amount); err != nil {
+			return err
+		}
 	}
-	if tx == nil {
-		log.Error("cannot find Transaction", "err", err, "hash", txHash)
-		return
+	for _, addr := range exit {
+		if err := rawdb.DeleteDeposit(tx, addr); err != nil {
+			return err
+		}
 	}
-
-	err = rawdb.DeleteDeposit(rwTx, *tx.From())
-	if err != nil {
-		log.Error("cannot delete deposit", "err", err)
-		return
+	if len(activate) > 0 || len(exit) > 0 {
+		log.Info("Validator set changed", "epoch", epoch, "activated", len(activate), "exited", len(exit))
 	}
-	rwTx.Commit()
+	return nil
 }
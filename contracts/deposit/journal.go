@@ -0,0 +1,207 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package deposit
+
+import (
+	"fmt"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// The validator lifecycle follows the canonical chain. The deposits and
+// withdrawals of every block written are recorded by block hash, and only
+// applied once the block joins the canonical chain. What applying a block
+// overwrites in the deposit tables is journaled, so that the block can be
+// reverted when a reorg or a rewind drops it.
+
+// Kinds of deposit events.
+const (
+	eventDeposit uint8 = iota
+	eventWithdrawal
+)
+
+// depositEvent is a deposit or withdrawal of a block.
+type depositEvent struct {
+	Kind     uint8
+	Contract types.Address
+	From     types.Address
+	Data     []byte
+}
+
+// undoEntry is the value a key of the deposit tables had before a block, an
+// empty value if it didn't exist.
+type undoEntry struct {
+	Table string
+	Key   []byte
+	Value []byte
+}
+
+// journalTx records the first value every written key of the deposit tables
+// had, for the block being applied to be reverted.
+type journalTx struct {
+	kv.RwTx
+	entries []undoEntry
+	seen    map[string]struct{}
+}
+
+func newJournalTx(tx kv.RwTx) *journalTx {
+	return &journalTx{RwTx: tx, seen: make(map[string]struct{})}
+}
+
+func (j *journalTx) record(table string, k []byte) error {
+	id := table + "/" + string(k)
+	if _, ok := j.seen[id]; ok {
+		return nil
+	}
+	v, err := j.RwTx.GetOne(table, k)
+	if err != nil {
+		return err
+	}
+	j.seen[id] = struct{}{}
+	j.entries = append(j.entries, undoEntry{Table: table, Key: types.CopyBytes(k), Value: types.CopyBytes(v)})
+	return nil
+}
+
+func (j *journalTx) Put(table string, k, v []byte) error {
+	if err := j.record(table, k); err != nil {
+		return err
+	}
+	return j.RwTx.Put(table, k, v)
+}
+
+func (j *journalTx) Delete(table string, k []byte) error {
+	if err := j.record(table, k); err != nil {
+		return err
+	}
+	return j.RwTx.Delete(table, k)
+}
+
+// WriteBlock records the deposits and withdrawals of a block written with
+// state, for when it joins the canonical chain.
+func (d *Deposit) WriteBlock(tx kv.RwTx, b block.IBlock, receipts []*block.Receipt) error {
+	events := d.blockEvents(b, receipts)
+	if len(events) == 0 {
+		return nil
+	}
+	data, err := rlp.EncodeToBytes(events)
+	if err != nil {
+		return err
+	}
+	return rawdb.WriteDepositEvents(tx, b.Number64().Uint64(), b.Hash(), data)
+}
+
+// Apply brings the validator lifecycle to a block joining the canonical chain.
+// The lifecycle has to be at the parent of the block, unless it never followed
+// the chain yet.
+func (d *Deposit) Apply(tx kv.RwTx, b block.IBlock) error {
+	number, hash := b.Number64().Uint64(), b.Hash()
+	headNumber, headHash, ok, err := rawdb.ReadDepositHead(tx)
+	if err != nil {
+		return err
+	}
+	if ok && headHash == hash {
+		return nil
+	}
+	if ok && headHash != b.ParentHash() {
+		return fmt.Errorf("validator lifecycle is at block #%d %s, not at the parent of block #%d %s", headNumber, headHash, number, hash)
+	}
+
+	var events []depositEvent
+	data, err := rawdb.ReadDepositEvents(tx, number, hash)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		if err := rlp.DecodeBytes(data, &events); err != nil {
+			return fmt.Errorf("invalid deposit events of block #%d %s: %w", number, hash, err)
+		}
+	}
+	journal := newJournalTx(tx)
+	if err := d.processBlock(journal, number, events); err != nil {
+		return err
+	}
+	if len(journal.entries) == 0 {
+		if err := rawdb.DeleteDepositUndo(tx, number); err != nil {
+			return err
+		}
+	} else {
+		undo, err := rlp.EncodeToBytes(journal.entries)
+		if err != nil {
+			return err
+		}
+		if err := rawdb.WriteDepositUndo(tx, number, hash, undo); err != nil {
+			return err
+		}
+	}
+	return rawdb.WriteDepositHead(tx, number, hash)
+}
+
+// Revert puts the validator lifecycle back to the parent of a block leaving
+// the canonical chain.
+func (d *Deposit) Revert(tx kv.RwTx, b block.IBlock) error {
+	number, hash := b.Number64().Uint64(), b.Hash()
+	headNumber, headHash, ok, err := rawdb.ReadDepositHead(tx)
+	if err != nil {
+		return err
+	}
+	if !ok || headHash != hash {
+		return fmt.Errorf("validator lifecycle is at block #%d %s, not at the reverted block #%d %s", headNumber, headHash, number, hash)
+	}
+	undoHash, data, err := rawdb.ReadDepositUndo(tx, number)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if undoHash != hash {
+			return fmt.Errorf("deposit undo record #%d is of block %s, not %s", number, undoHash, hash)
+		}
+		var entries []undoEntry
+		if err := rlp.DecodeBytes(data, &entries); err != nil {
+			return fmt.Errorf("invalid deposit undo record of block #%d %s: %w", number, hash, err)
+		}
+		for _, e := range entries {
+			if e.Table != modules.Deposit && e.Table != modules.DepositLifecycle {
+				return fmt.Errorf("invalid table %q in deposit undo record of block #%d", e.Table, number)
+			}
+			if len(e.Value) == 0 {
+				err = tx.Delete(e.Table, e.Key)
+			} else {
+				err = tx.Put(e.Table, e.Key, e.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := rawdb.DeleteDepositUndo(tx, number); err != nil {
+			return err
+		}
+		log.Debug("Reverted validator lifecycle", "number", number, "hash", hash, "entries", len(entries))
+	}
+	return rawdb.WriteDepositHead(tx, number-1, b.ParentHash())
+}
+
+// Reset records that the validator lifecycle is at a block whose deposit
+// tables came with its state.
+func (d *Deposit) Reset(tx kv.RwTx, b block.IBlock) error {
+	return rawdb.WriteDepositHead(tx, b.Number64().Uint64(), b.Hash())
+}
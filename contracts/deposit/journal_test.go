@@ -0,0 +1,259 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package deposit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var (
+	testContractAddr = types.HexToAddress("0xd0")
+	testValidators   = []types.Address{types.HexToAddress("0xa1"), types.HexToAddress("0xa2"), types.HexToAddress("0xa3")}
+)
+
+// testContract unpacks every deposit to the 50 AMT deposit of TestBLS.
+type testContract struct{}
+
+func (testContract) DepositSignature() types.Hash   { return types.Hash{0x01} }
+func (testContract) WithdrawnSignature() types.Hash { return types.Hash{0x02} }
+func (testContract) IsDepositAction(sig [4]byte) bool {
+	return false
+}
+func (testContract) UnpackDepositLogData(data []byte) ([]byte, []byte, *uint256.Int, error) {
+	sig, _ := hexutil.Decode("0xab22c6b63e3595630ffe8ed2903dfeba2a781c2d33dc66f88442982b65c5fcce9a8078f9ae419c95eecd2a5546a06e371196855311a930a4ad404321083f4f058c41e2d6c2e1f2bf11b1cd9b73d65a0a169a81cc1e60b50164aa7b322396be67")
+	pub, _ := hexutil.Decode("0xa20699fa55487f79c1400e2be5bb6acf89b0c5880becfa4b0560b9994bd8050616886a8fb71bdf15065dd31dd2858c18")
+	return pub, sig, new(uint256.Int).Mul(uint256.NewInt(params.AMT), uint256.NewInt(50)), nil
+}
+
+func newTestDeposit() *Deposit {
+	return &Deposit{
+		depositContracts: map[types.Address]DepositContract{testContractAddr: testContract{}},
+		epoch:            4,
+	}
+}
+
+// testAction is a deposit or withdrawal of a validator in a test block.
+type testAction struct {
+	validator int
+	withdraw  bool
+}
+
+// testBlock is a block on top of parent with the given actions. fork tells
+// blocks at the same height apart.
+func testBlock(parent block.IBlock, fork byte, actions ...testAction) (block.IBlock, []*block.Receipt) {
+	var (
+		txs      []*transaction.Transaction
+		receipts []*block.Receipt
+	)
+	for i, a := range actions {
+		tx := transaction.NewTransaction(uint64(i), testValidators[a.validator], &testContractAddr, uint256.NewInt(0), 100000, uint256.NewInt(1), []byte{fork})
+		topic := testContract{}.DepositSignature()
+		if a.withdraw {
+			topic = testContract{}.WithdrawnSignature()
+		}
+		txs = append(txs, tx)
+		receipts = append(receipts, &block.Receipt{Logs: []*block.Log{{Address: testContractAddr, Topics: []types.Hash{topic}, TxHash: tx.Hash()}}})
+	}
+	header := &block.Header{
+		Number: uint256.NewInt(0),
+		Extra:  []byte{fork},
+	}
+	if parent != nil {
+		header.ParentHash = parent.Hash()
+		header.Number = new(uint256.Int).AddUint64(parent.Number64(), 1)
+	}
+	return block.NewBlock(header, txs), receipts
+}
+
+// testChain builds blocks on top of parent, the actions of block i being
+// actions[i].
+func testChain(parent block.IBlock, fork byte, actions map[int][]testAction, length int) ([]block.IBlock, [][]*block.Receipt) {
+	var (
+		blocks   []block.IBlock
+		receipts [][]*block.Receipt
+	)
+	for i := 0; i < length; i++ {
+		b, r := testBlock(parent, fork, actions[i]...)
+		blocks, receipts = append(blocks, b), append(receipts, r)
+		parent = b
+	}
+	return blocks, receipts
+}
+
+func applyBlocks(t *testing.T, d *Deposit, tx kv.RwTx, blocks []block.IBlock, receipts [][]*block.Receipt) {
+	t.Helper()
+	for i, b := range blocks {
+		if err := d.WriteBlock(tx, b, receipts[i]); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Apply(tx, b); err != nil {
+			t.Fatalf("apply block %d: %v", b.Number64().Uint64(), err)
+		}
+	}
+}
+
+func revertBlocks(t *testing.T, d *Deposit, tx kv.RwTx, blocks []block.IBlock) {
+	t.Helper()
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if err := d.Revert(tx, blocks[i]); err != nil {
+			t.Fatalf("revert block %d: %v", blocks[i].Number64().Uint64(), err)
+		}
+	}
+}
+
+// dumpLifecycle returns the content of the deposit tables.
+func dumpLifecycle(t *testing.T, tx kv.Tx) map[string]string {
+	t.Helper()
+	dump := make(map[string]string)
+	for _, table := range []string{modules.Deposit, modules.DepositLifecycle} {
+		if err := tx.ForEach(table, nil, func(k, v []byte) error {
+			dump[fmt.Sprintf("%s/%x", table, k)] = fmt.Sprintf("%x", v)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dump
+}
+
+func checkLifecycle(t *testing.T, name string, got, want map[string]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: %d entries, want %d:\n%v\nwant\n%v", name, len(got), len(want), got, want)
+		return
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: %s = %s, want %s", name, k, got[k], v)
+		}
+	}
+}
+
+func TestLifecycleReorg(t *testing.T) {
+	d := newTestDeposit()
+	genesis, _ := testBlock(nil, 0)
+
+	// Chain A: validators 0 and 1 deposit, are activated at block 4, then
+	// validator 0 leaves at block 8.
+	chainA, receiptsA := testChain(genesis, 'a', map[int][]testAction{
+		0: {{validator: 0}},
+		1: {{validator: 1}},
+		4: {{validator: 0, withdraw: true}},
+	}, 8)
+	// Chain B forks after block 4: validator 1 leaves instead of validator
+	// 0, and validator 2 deposits, to be activated at block 8.
+	chainB, receiptsB := testChain(chainA[3], 'b', map[int][]testAction{
+		0: {{validator: 1, withdraw: true}},
+		1: {{validator: 2}},
+	}, 4)
+
+	_, tx := memdb.NewTestTx(t)
+	applyBlocks(t, d, tx, chainA, receiptsA)
+	if rawdb.IsDeposit(tx, testValidators[0]) || !rawdb.IsDeposit(tx, testValidators[1]) {
+		t.Fatalf("deposits on chain A: %v, %v, want false, true", rawdb.IsDeposit(tx, testValidators[0]), rawdb.IsDeposit(tx, testValidators[1]))
+	}
+	// The blocks of chain B are written as side blocks, without effect.
+	for i, b := range chainB {
+		if err := d.WriteBlock(tx, b, receiptsB[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Apply(tx, chainB[0]); err == nil {
+		t.Fatal("block applied on a lifecycle not at its parent")
+	}
+	if err := d.Revert(tx, chainA[0]); err == nil {
+		t.Fatal("block reverted below the lifecycle head")
+	}
+
+	// Reverting to the fork point gives the lifecycle of the common blocks.
+	_, fresh := memdb.NewTestTx(t)
+	applyBlocks(t, d, fresh, chainA[:4], receiptsA[:4])
+	revertBlocks(t, d, tx, chainA[4:])
+	checkLifecycle(t, "reverted to block 4", dumpLifecycle(t, tx), dumpLifecycle(t, fresh))
+
+	// Then the blocks of chain B apply as if chain A never was.
+	for _, b := range chainB {
+		if err := d.Apply(tx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	applyBlocks(t, d, fresh, chainB, receiptsB)
+	checkLifecycle(t, "reorged to chain B", dumpLifecycle(t, tx), dumpLifecycle(t, fresh))
+	for i, want := range []bool{true, false, true} {
+		if deposit := rawdb.IsDeposit(tx, testValidators[i]); deposit != want {
+			t.Errorf("deposit of validator %d on chain B: %v, want %v", i, deposit, want)
+		}
+	}
+
+	// Reverting everything leaves nothing behind.
+	revertBlocks(t, d, tx, append(append([]block.IBlock{}, chainA[:4]...), chainB...))
+	checkLifecycle(t, "reverted to genesis", dumpLifecycle(t, tx), map[string]string{})
+	if number, hash, ok, err := rawdb.ReadDepositHead(tx); err != nil || !ok || number != 0 || hash != genesis.Hash() {
+		t.Errorf("lifecycle head #%d %s (%v, %v), want the genesis", number, hash, ok, err)
+	}
+}
+
+func TestLifecycleReapply(t *testing.T) {
+	d := newTestDeposit()
+	genesis, _ := testBlock(nil, 0)
+	chain, receipts := testChain(genesis, 'a', map[int][]testAction{0: {{validator: 0}}}, 4)
+
+	_, tx := memdb.NewTestTx(t)
+	applyBlocks(t, d, tx, chain, receipts)
+	want := dumpLifecycle(t, tx)
+
+	// A block applied twice, as when the head is rewritten, counts once.
+	if err := d.Apply(tx, chain[3]); err != nil {
+		t.Fatal(err)
+	}
+	checkLifecycle(t, "applied twice", dumpLifecycle(t, tx), want)
+
+	// Reverted blocks apply again from their recorded events.
+	revertBlocks(t, d, tx, chain)
+	for _, b := range chain {
+		if err := d.Apply(tx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkLifecycle(t, "applied again", dumpLifecycle(t, tx), want)
+
+	// Once pruned, the blocks can't be reverted anymore.
+	if err := rawdb.PruneDepositJournal(tx, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, undo, err := rawdb.ReadDepositUndo(tx, 1); err != nil || undo != nil {
+		t.Errorf("undo record of a pruned block: %x, %v", undo, err)
+	}
+	if _, undo, err := rawdb.ReadDepositUndo(tx, 4); err != nil || undo == nil {
+		t.Errorf("undo record of block 4 pruned: %v", err)
+	}
+	if events, err := rawdb.ReadDepositEvents(tx, 1, chain[0].Hash()); err != nil || len(events) != 0 {
+		t.Errorf("events of a pruned block: %x, %v", events, err)
+	}
+}
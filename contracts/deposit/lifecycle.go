@@ -0,0 +1,143 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package deposit
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// Status is the stage of a validator's lifecycle.
+type Status string
+
+const (
+	StatusUnknown Status = "unknown" // never deposited
+	StatusPending Status = "pending" // deposited, waiting in the activation queue
+	StatusActive  Status = "active"  // taking part in block verification
+	StatusExiting Status = "exiting" // exit requested, still active until the exit epoch
+	StatusExited  Status = "exited"  // left the validator set
+)
+
+// lifecycleLength is the encoded size of a Lifecycle without its amount.
+const lifecycleLength = 3*8 + types.PublicKeyLength
+
+// Lifecycle tracks a validator from its deposit to its exit. Epochs are
+// counted in APoS epochs; an ExitEpoch of zero means no exit was requested.
+type Lifecycle struct {
+	DepositBlock    uint64          `json:"depositBlock"`
+	ActivationEpoch uint64          `json:"activationEpoch"`
+	ExitEpoch       uint64          `json:"exitEpoch,omitempty"`
+	PublicKey       types.PublicKey `json:"publicKey"`
+	Amount          *uint256.Int    `json:"amount"`
+}
+
+// Status returns the lifecycle stage at the given epoch.
+func (l *Lifecycle) Status(epoch uint64) Status {
+	switch {
+	case l.ExitEpoch != 0 && epoch >= l.ExitEpoch:
+		return StatusExited
+	case epoch < l.ActivationEpoch:
+		return StatusPending
+	case l.ExitEpoch != 0:
+		return StatusExiting
+	default:
+		return StatusActive
+	}
+}
+
+func (l *Lifecycle) encode() []byte {
+	data := make([]byte, lifecycleLength, lifecycleLength+l.Amount.ByteLen())
+	binary.BigEndian.PutUint64(data[0:], l.DepositBlock)
+	binary.BigEndian.PutUint64(data[8:], l.ActivationEpoch)
+	binary.BigEndian.PutUint64(data[16:], l.ExitEpoch)
+	copy(data[24:], l.PublicKey[:])
+	return append(data, l.Amount.Bytes()...)
+}
+
+func decodeLifecycle(data []byte) (*Lifecycle, error) {
+	if len(data) < lifecycleLength {
+		return nil, fmt.Errorf("invalid validator lifecycle length %d", len(data))
+	}
+	l := &Lifecycle{
+		DepositBlock:    binary.BigEndian.Uint64(data[0:]),
+		ActivationEpoch: binary.BigEndian.Uint64(data[8:]),
+		ExitEpoch:       binary.BigEndian.Uint64(data[16:]),
+		Amount:          new(uint256.Int).SetBytes(data[lifecycleLength:]),
+	}
+	copy(l.PublicKey[:], data[24:lifecycleLength])
+	return l, nil
+}
+
+// GetLifecycle returns the lifecycle of a validator, nil if it never deposited
+// through the activation queue.
+func GetLifecycle(tx kv.Getter, addr types.Address) (*Lifecycle, error) {
+	data, err := rawdb.GetValidatorLifecycle(tx, addr)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	return decodeLifecycle(data)
+}
+
+// ValidatorStatus returns the lifecycle stage of a validator at the given epoch.
+// Validators that deposited before lifecycles were tracked have no record.
+func ValidatorStatus(tx kv.Tx, addr types.Address, epoch uint64) (Status, *Lifecycle, error) {
+	l, err := GetLifecycle(tx, addr)
+	if err != nil {
+		return StatusUnknown, nil, err
+	}
+	if l == nil {
+		if rawdb.IsDeposit(tx, addr) {
+			return StatusActive, nil, nil
+		}
+		return StatusUnknown, nil, nil
+	}
+	return l.Status(epoch), l, nil
+}
+
+// putLifecycle stores the lifecycle of a validator.
+func putLifecycle(tx kv.Putter, addr types.Address, l *Lifecycle) error {
+	return rawdb.PutValidatorLifecycle(tx, addr, l.encode())
+}
+
+// queueSlot reserves a place in the activation or exit queue, returning the
+// first epoch from the given one that is below the churn limit.
+func queueSlot(tx kv.RwTx, epoch, churn uint64, read func(kv.Getter, uint64) (uint64, error), write func(kv.Putter, uint64, uint64) error) (uint64, error) {
+	for {
+		size, err := read(tx, epoch)
+		if err != nil {
+			return 0, err
+		}
+		if churn == 0 || size < churn {
+			return epoch, write(tx, epoch, size+1)
+		}
+		epoch++
+	}
+}
+
+// release frees the place a validator held in a queue.
+func release(tx kv.RwTx, epoch uint64, read func(kv.Getter, uint64) (uint64, error), write func(kv.Putter, uint64, uint64) error) error {
+	size, err := read(tx, epoch)
+	if err != nil || size == 0 {
+		return err
+	}
+	return write(tx, epoch, size-1)
+}
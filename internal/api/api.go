@@ -20,9 +20,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	amazechain "github.com/amazechain/amc"
 	"github.com/amazechain/amc/conf"
 	"github.com/amazechain/amc/internal"
 	"github.com/amazechain/amc/internal/api/filters"
+	"github.com/amazechain/amc/internal/tracers/logger"
 	vm2 "github.com/amazechain/amc/internal/vm"
 	"github.com/amazechain/amc/internal/vm/evmtypes"
 	event "github.com/amazechain/amc/modules/event/v2"
@@ -32,6 +34,7 @@ import (
 
 	"math"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -55,9 +58,12 @@ import (
 
 const (
 	// todo
-	baseFee       = 5000000
-	rpcEVMTimeout = time.Duration(5 * time.Second)
-	rpcGasCap     = 50000000
+	baseFee = 5000000
+
+	// DefaultRPCEVMTimeout and DefaultRPCGasCap bound eth_call and
+	// eth_estimateGas unless configured otherwise.
+	DefaultRPCEVMTimeout = 5 * time.Second
+	DefaultRPCGasCap     = 50000000
 )
 
 // API compatible EthereumAPI provides an API to access related information.
@@ -69,10 +75,23 @@ type API struct {
 
 	accountManager *accounts.Manager
 	chainConfig    *params.ChainConfig
+	extRPCEnabled  bool
+
+	rpcGasCap     uint64
+	rpcEVMTimeout time.Duration
 
-	gpo *Oracle
+	gpo        *Oracle
+	syncReader amazechain.ChainSyncReader
+	syncing    atomic.Bool // the node is catching up, see FollowSync
 }
 
+// errNodeSyncing refuses transactions that would be checked against a stale head.
+var errNodeSyncing = errors.New("node is syncing, try again once it caught up")
+
+// errStateUnavailable is returned for the state of a block older than the
+// history the node keeps; archive nodes keep all of it.
+var errStateUnavailable = errors.New("historical state unavailable")
+
 // NewAPI creates a new protocol API.
 func NewAPI(bc common.IBlockChain, db kv.RwDB, engine consensus.Engine, txspool common.ITxsPool, accountManager *accounts.Manager, config *params.ChainConfig) *API {
 	return &API{
@@ -82,6 +101,8 @@ func NewAPI(bc common.IBlockChain, db kv.RwDB, engine consensus.Engine, txspool
 		txspool:        txspool,
 		accountManager: accountManager,
 		chainConfig:    config,
+		rpcGasCap:      DefaultRPCGasCap,
+		rpcEVMTimeout:  DefaultRPCEVMTimeout,
 	}
 }
 
@@ -89,6 +110,58 @@ func (api *API) SetGpo(gpo *Oracle) {
 	api.gpo = gpo
 }
 
+// SetSyncReader sets the source of the sync progress reported by eth_syncing.
+func (api *API) SetSyncReader(reader amazechain.ChainSyncReader) {
+	api.syncReader = reader
+}
+
+// FollowSync tracks the sync lifecycle events until ctx is done, so that
+// transactions sent while the node syncs are refused instead of queued
+// against a stale state.
+func (api *API) FollowSync(ctx context.Context) {
+	startedCh := make(chan common.SyncStartedEvent)
+	finishedCh := make(chan common.SyncFinishedEvent)
+	failedCh := make(chan common.SyncFailedEvent)
+	started := event.GlobalEvent.Subscribe(startedCh)
+	finished := event.GlobalEvent.Subscribe(finishedCh)
+	failed := event.GlobalEvent.Subscribe(failedCh)
+
+	go func() {
+		defer func() {
+			started.Unsubscribe()
+			finished.Unsubscribe()
+			failed.Unsubscribe()
+		}()
+		for {
+			select {
+			case <-startedCh:
+				api.syncing.Store(true)
+			case <-finishedCh:
+				api.syncing.Store(false)
+			case <-failedCh:
+				api.syncing.Store(false)
+			case <-started.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// SetExtRPCEnabled records whether the node serves RPC over the network
+// (http or ws), which forbids account unlocking unless explicitly allowed.
+func (api *API) SetExtRPCEnabled(enabled bool) {
+	api.extRPCEnabled = enabled
+}
+
+// SetRPCCaps sets the global gas cap and the EVM timeout applied to
+// eth_call-like requests. Zero disables the respective cap.
+func (api *API) SetRPCCaps(gasCap uint64, evmTimeout time.Duration) {
+	api.rpcGasCap = gasCap
+	api.rpcEVMTimeout = evmTimeout
+}
+
 func (api *API) Apis() []jsonrpc.API {
 	nonceLock := new(AddrLocker)
 	return []jsonrpc.API{
@@ -101,6 +174,12 @@ func (api *API) Apis() []jsonrpc.API {
 		}, {
 			Namespace: "eth",
 			Service:   NewTransactionAPI(api, nonceLock),
+		}, {
+			Namespace: "eth",
+			Service:   NewAccountAPI(api.accountManager),
+		}, {
+			Namespace: "personal",
+			Service:   NewPersonalAccountAPI(api, nonceLock),
 		}, {
 			Namespace: "web3",
 			Service:   &Web3API{api},
@@ -126,36 +205,57 @@ func (n *API) TxsPool() common.ITxsPool       { return n.txspool }
 func (n *API) Database() kv.RwDB              { return n.db }
 func (n *API) Engine() consensus.Engine       { return n.engine }
 func (n *API) BlockChain() common.IBlockChain { return n.bc }
-func (n *API) GetEvm(ctx context.Context, msg internal.Message, ibs evmtypes.IntraBlockState, header block.IHeader, vmConfig *vm2.Config) (*vm2.EVM, func() error, error) {
+
+// BlockContext returns the EVM context of the block of header, with the
+// header fields of overrides if any. The hashes of the ancestors are read
+// through tx.
+func (n *API) BlockContext(tx kv.Tx, header block.IHeader, overrides *BlockOverrides) evmtypes.BlockContext {
+	getHeader := func(hash types.Hash, number uint64) *block.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	}
+	blockCtx := internal.NewEVMBlockContext(header.(*block.Header), internal.GetHashFn(header.(*block.Header), getHeader), n.engine, nil)
+	overrides.Apply(&blockCtx)
+	return blockCtx
+}
+
+// GetEvm returns an EVM executing msg on ibs in the given block context.
+func (n *API) GetEvm(ctx context.Context, msg internal.Message, ibs evmtypes.IntraBlockState, blockCtx evmtypes.BlockContext, vmConfig *vm2.Config) (*vm2.EVM, func() error, error) {
 	vmError := func() error { return nil }
 
 	txContext := internal.NewEVMTxContext(msg)
-	context := internal.NewEVMBlockContext(header.(*block.Header), internal.GetHashFn(header.(*block.Header), nil), n.engine, nil)
-	//tx, err := n.db.BeginRo(ctx)
-	//if nil != err {
-	//	return nil, nil, err
-	//}
-	//defer tx.Rollback()
-	//
-	//stateReader := state.NewPlainStateReader(tx)
-
-	return vm2.NewEVM(context, txContext, ibs, n.GetChainConfig(), *vmConfig), vmError, nil
+	return vm2.NewEVM(blockCtx, txContext, ibs, n.GetChainConfig(), *vmConfig), vmError, nil
 }
 
-func (n *API) State(tx kv.Tx, blockNrOrHash jsonrpc.BlockNumberOrHash) evmtypes.IntraBlockState {
-
+// State returns the state as of the end of the given block.
+func (n *API) State(tx kv.Tx, blockNrOrHash jsonrpc.BlockNumberOrHash) (evmtypes.IntraBlockState, error) {
 	_, blockHash, err := rpchelper.GetCanonicalBlockNumber(blockNrOrHash, tx)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	blockNr := rawdb.ReadHeaderNumber(tx, blockHash)
 	if nil == blockNr {
-		return nil
+		return nil, fmt.Errorf("header %s not found", blockHash)
+	}
+	if err := checkStateHistory(tx, *blockNr); err != nil {
+		return nil, err
 	}
 
 	stateReader := state.NewPlainState(tx, *blockNr+1)
-	return state.New(stateReader)
+	return state.New(stateReader), nil
+}
+
+// checkStateHistory fails for a block whose state can't be rebuilt anymore,
+// because the node pruned its history or was snap synced past it.
+func checkStateHistory(tx kv.Tx, number uint64) error {
+	pruned, err := rawdb.ReadStatePruneProgress(tx)
+	if err != nil {
+		return err
+	}
+	if number+1 < pruned {
+		return fmt.Errorf("%w: state of block %d isn't kept, the oldest available is %d", errStateUnavailable, number, pruned-1)
+	}
+	return nil
 }
 
 func (n *API) GetChainConfig() *params.ChainConfig {
@@ -163,7 +263,11 @@ func (n *API) GetChainConfig() *params.ChainConfig {
 }
 
 func (n *API) RPCGasCap() uint64 {
-	return rpcGasCap
+	return n.rpcGasCap
+}
+
+func (n *API) RPCEVMTimeout() time.Duration {
+	return n.rpcEVMTimeout
 }
 
 // AmcAPI provides an API to access metadata related information.
@@ -184,7 +288,7 @@ func (s *AmcAPI) GasPrice(ctx context.Context) (*hexutil.Big, error) {
 	if err != nil {
 		return nil, err
 	}
-	if head := s.api.BlockChain().CurrentBlock().Header(); head.BaseFee64() != uint256.NewInt(0) {
+	if head := s.api.BlockChain().CurrentBlock().Header(); head.BaseFee64() != nil {
 		tipcap.Add(tipcap, head.BaseFee64().ToBig())
 	}
 	return (*hexutil.Big)(tipcap), nil
@@ -202,6 +306,46 @@ func (s *AmcAPI) MaxPriorityFeePerGas(ctx context.Context) (*hexutil.Big, error)
 	return (*hexutil.Big)(tipcap), err
 }
 
+// Syncing returns false in case the node is currently not syncing with the network. It can be up-to-date or has not
+// yet received the latest block headers from its peers. In case it is synchronizing:
+// - startingBlock: block number this node started to synchronize from
+// - currentBlock:  block number this node is currently importing
+// - highestBlock:  block number of the highest block header this node has received from peers
+// - synced*:       state entries downloaded by snap sync, per table
+// - healed*:       state entries fixed up after the download
+// - healing*:      blocks left to heal and codes left to repair
+func (s *AmcAPI) Syncing(ctx context.Context) (interface{}, error) {
+	if s.api.syncReader == nil {
+		return false, nil
+	}
+	progress, err := s.api.syncReader.SyncProgress(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Return not syncing if the synchronisation already completed
+	if progress == nil || progress.CurrentBlock >= progress.HighestBlock {
+		return false, nil
+	}
+	// Otherwise gather the block sync stats
+	return map[string]interface{}{
+		"startingBlock":       hexutil.Uint64(progress.StartingBlock),
+		"currentBlock":        hexutil.Uint64(progress.CurrentBlock),
+		"highestBlock":        hexutil.Uint64(progress.HighestBlock),
+		"syncedAccounts":      hexutil.Uint64(progress.SyncedAccounts),
+		"syncedAccountBytes":  hexutil.Uint64(progress.SyncedAccountBytes),
+		"syncedBytecodes":     hexutil.Uint64(progress.SyncedBytecodes),
+		"syncedBytecodeBytes": hexutil.Uint64(progress.SyncedBytecodeBytes),
+		"syncedStorage":       hexutil.Uint64(progress.SyncedStorage),
+		"syncedStorageBytes":  hexutil.Uint64(progress.SyncedStorageBytes),
+		"healedTrienodes":     hexutil.Uint64(progress.HealedTrienodes),
+		"healedTrienodeBytes": hexutil.Uint64(progress.HealedTrienodeBytes),
+		"healedBytecodes":     hexutil.Uint64(progress.HealedBytecodes),
+		"healedBytecodeBytes": hexutil.Uint64(progress.HealedBytecodeBytes),
+		"healingTrienodes":    hexutil.Uint64(progress.HealingTrienodes),
+		"healingBytecode":     hexutil.Uint64(progress.HealingBytecode),
+	}, nil
+}
+
 type feeHistoryResult struct {
 	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
 	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
@@ -211,22 +355,7 @@ type feeHistoryResult struct {
 
 // FeeHistory returns the fee market history.
 func (s *AmcAPI) FeeHistory(ctx context.Context, blockCount jsonrpc.DecimalOrHex, lastBlock jsonrpc.BlockNumber, rewardPercentiles []float64) (*feeHistoryResult, error) {
-
-	var (
-		resolvedLastBlock *uint256.Int
-		err               error
-	)
-
-	s.api.db.View(ctx, func(tx kv.Tx) error {
-		resolvedLastBlock, _, err = rpchelper.GetBlockNumber(jsonrpc.BlockNumberOrHashWithNumber(lastBlock), tx)
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	oldest, reward, baseFee, gasUsed, err := s.api.gpo.FeeHistory(ctx, int(blockCount), lastBlock, resolvedLastBlock, rewardPercentiles)
+	oldest, reward, baseFee, gasUsed, err := s.api.gpo.FeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
 	if err != nil {
 		return nil, err
 	}
@@ -301,17 +430,17 @@ func (s *TxPoolAPI) Inspect() map[string]map[string]map[string]string {
 // AccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type AccountAPI struct {
+	am *accounts.Manager
 }
 
 // NewAccountAPI creates a new AccountAPI.
-func NewAccountAPI() *AccountAPI {
-	return &AccountAPI{}
+func NewAccountAPI(am *accounts.Manager) *AccountAPI {
+	return &AccountAPI{am: am}
 }
 
 // Accounts returns the collection of accounts this node manages.
 func (s *AccountAPI) Accounts() []types.Address {
-	//return s.am.Accounts()
-	return nil
+	return s.am.Accounts()
 }
 
 // BlockChainAPI provides an API to access Ethereum blockchain data.
@@ -337,9 +466,9 @@ func (s *BlockChainAPI) GetBalance(ctx context.Context, address mvm_common.Addre
 	}
 	defer tx.Rollback()
 
-	state := s.api.State(tx, blockNrOrHash)
-	if state == nil {
-		return nil, nil
+	state, err := s.api.State(tx, blockNrOrHash)
+	if err != nil {
+		return nil, err
 	}
 	balance := state.GetBalance(*mvm_types.ToAmcAddress(&address))
 	return (*hexutil.Big)(balance.ToBig()), nil
@@ -359,9 +488,9 @@ func (s *BlockChainAPI) GetCode(ctx context.Context, address mvm_common.Address,
 	}
 	defer tx.Rollback()
 
-	state := s.api.State(tx, blockNrOrHash)
-	if state == nil {
-		return nil, nil
+	state, err := s.api.State(tx, blockNrOrHash)
+	if err != nil {
+		return nil, err
 	}
 	code := state.GetCode(*mvm_types.ToAmcAddress(&address))
 	return code, nil
@@ -377,9 +506,9 @@ func (s *BlockChainAPI) GetStorageAt(ctx context.Context, address types.Address,
 	}
 	defer tx.Rollback()
 
-	state := s.api.State(tx, blockNrOrHash)
-	if state == nil {
-		return nil, nil
+	state, err := s.api.State(tx, blockNrOrHash)
+	if err != nil {
+		return nil, err
 	}
 	var va uint256.Int
 	k := types.HexToHash(key)
@@ -452,6 +581,9 @@ func (diff *StateOverride) Apply(state *state.IntraBlockState) error {
 		return nil
 	}
 	for addr, account := range *diff {
+		if account.StatsPrint != nil && account.StateDiff != nil {
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.String())
+		}
 		// Override account nonce.
 		if account.Nonce != nil {
 			state.SetNonce(*mvm_types.ToAmcAddress(&addr), uint64(*account.Nonce))
@@ -465,9 +597,7 @@ func (diff *StateOverride) Apply(state *state.IntraBlockState) error {
 			balance, _ := uint256.FromBig((*big.Int)(*account.Balance))
 			state.SetBalance(*mvm_types.ToAmcAddress(&addr), balance)
 		}
-		if account.StatsPrint != nil && account.StateDiff != nil {
-			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.String())
-		}
+		// Replace entire state if caller requires.
 		if account.StatsPrint != nil {
 			statesPrint := make(map[types.Hash]uint256.Int)
 			for k, v := range *account.StatsPrint {
@@ -490,13 +620,13 @@ func (diff *StateOverride) Apply(state *state.IntraBlockState) error {
 
 // BlockOverrides is a set of header fields to override.
 type BlockOverrides struct {
-	Number     *hexutil.Big
-	Difficulty *hexutil.Big
-	Time       *hexutil.Uint64
-	GasLimit   *hexutil.Uint64
-	Coinbase   *types.Address
-	Random     *types.Hash
-	BaseFee    *hexutil.Big
+	Number     *hexutil.Big    `json:"number"`
+	Difficulty *hexutil.Big    `json:"difficulty"`
+	Time       *hexutil.Uint64 `json:"time"`
+	GasLimit   *hexutil.Uint64 `json:"gasLimit"`
+	Coinbase   *types.Address  `json:"coinbase"`
+	Random     *types.Hash     `json:"random"`
+	BaseFee    *hexutil.Big    `json:"baseFee"`
 }
 
 // Apply overrides the given header fields into the given block context.
@@ -527,14 +657,42 @@ func (diff *BlockOverrides) Apply(blockCtx *evmtypes.BlockContext) {
 	}
 }
 
-func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash jsonrpc.BlockNumberOrHash, overrides *StateOverride, timeout time.Duration, globalGasCap uint64) (*internal.ExecutionResult, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+// resolveCheckpoint turns the safe and finalized tags into the number of the
+// block they currently point at. Other block numbers are returned unchanged.
+func resolveCheckpoint(ctx context.Context, api *API, number jsonrpc.BlockNumber) (jsonrpc.BlockNumber, error) {
+	if number != jsonrpc.SafeBlockNumber && number != jsonrpc.FinalizedBlockNumber {
+		return number, nil
+	}
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	// header := api.BlockChain().CurrentBlock().Header()
-	//state := api.BlockChain().StateAt(header.Hash()).(*statedb.StateDB)
-	var header block.IHeader
-	var err error
+	var resolved *uint256.Int
+	if number == jsonrpc.SafeBlockNumber {
+		resolved, err = rpchelper.GetSafeBlockNumber(tx)
+	} else {
+		resolved, err = rpchelper.GetFinalizedBlockNumber(tx)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return jsonrpc.BlockNumber(resolved.Uint64()), nil
+}
+
+// headerByNumberOrHash resolves the header the given block selector refers to.
+// The safe and finalized tags resolve to the tracked checkpoints, the other
+// special block numbers (latest, pending) to the current head.
+func headerByNumberOrHash(api *API, blockNrOrHash jsonrpc.BlockNumberOrHash) (block.IHeader, error) {
+	var (
+		header block.IHeader
+		err    error
+	)
 	if blockNr, ok := blockNrOrHash.Number(); ok {
+		if blockNr, err = resolveCheckpoint(context.Background(), api, blockNr); err != nil {
+			return nil, err
+		}
 		if blockNr < jsonrpc.EarliestBlockNumber {
 			header = api.BlockChain().CurrentBlock().Header()
 		} else {
@@ -547,6 +705,21 @@ func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash j
 	if err != nil {
 		return nil, err
 	}
+	if header == nil {
+		return nil, errors.New("header not found")
+	}
+	return header, nil
+}
+
+func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash jsonrpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, timeout time.Duration, globalGasCap uint64) (*internal.ExecutionResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+
+	// header := api.BlockChain().CurrentBlock().Header()
+	//state := api.BlockChain().StateAt(header.Hash()).(*statedb.StateDB)
+	header, err := headerByNumberOrHash(api, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
 	//state := api.State(blockNrOrHash).(*statedb.StateDB)
 	tx, err := api.db.BeginRo(ctx)
 	if nil != err {
@@ -556,9 +729,9 @@ func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash j
 
 	//reader := state.NewPlainStateReader(tx)
 	//ibs := state.New(reader)
-	ibs := api.State(tx, blockNrOrHash)
-	if ibs == nil {
-		return nil, errors.New("cannot load state")
+	ibs, err := api.State(tx, blockNrOrHash)
+	if err != nil {
+		return nil, err
 	}
 	if err := overrides.Apply(ibs.(*state.IntraBlockState)); err != nil {
 		return nil, err
@@ -575,14 +748,18 @@ func DoCall(ctx context.Context, api *API, args TransactionArgs, blockNrOrHash j
 	// this makes sure resources are cleaned up.
 	defer cancel()
 
+	// Apply the block overrides on top of the resolved header's context,
+	// the message has to be priced against the overridden base fee.
+	blockCtx := api.BlockContext(tx, header, blockOverrides)
+
 	// Get a new instance of the EVM.
-	msg, err := args.ToMessage(globalGasCap, header.BaseFee64().ToBig())
+	msg, err := args.ToMessage(globalGasCap, blockCtx.BaseFee.ToBig())
 	if err != nil {
 		return nil, err
 	}
 
 	//todo debug: , Debug: true, Tracer: vm.NewMarkdownLogger(os.Stdout)
-	evm, vmError, err := api.GetEvm(ctx, msg, ibs, header, &vm2.Config{NoBaseFee: true})
+	evm, vmError, err := api.GetEvm(ctx, msg, ibs, blockCtx, &vm2.Config{NoBaseFee: true})
 	if err != nil {
 		return nil, err
 	}
@@ -641,16 +818,17 @@ func (e *revertError) ErrorData() interface{} {
 
 // Call executes the given transaction on the state for the given block number.
 //
-// Additionally, the caller can specify a batch of contract for fields overriding.
+// Additionally, the caller can specify a batch of contract for fields overriding
+// and a set of header fields the call should observe instead of the real ones.
 //
 // Note, this function doesn't make and changes in the state/blockchain and is
 // useful to execute and retrieve values.
-func (s *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockNrOrHash jsonrpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Bytes, error) {
+func (s *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockNrOrHash jsonrpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) (hexutil.Bytes, error) {
 
 	//b, _ := json.Marshal(args)
 	//log.Info("TransactionArgs %s", string(b))
 
-	result, err := DoCall(ctx, s.api, args, blockNrOrHash, overrides, rpcEVMTimeout, rpcGasCap)
+	result, err := DoCall(ctx, s.api, args, blockNrOrHash, overrides, blockOverrides, s.api.RPCEVMTimeout(), s.api.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
@@ -662,6 +840,10 @@ func (s *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockNrO
 }
 
 func BlockByNumber(ctx context.Context, number jsonrpc.BlockNumber, n *API) (block.IBlock, error) {
+	number, err := resolveCheckpoint(ctx, n, number)
+	if err != nil {
+		return nil, err
+	}
 	// todo
 	// Pending block is only known by the miner
 	if number == jsonrpc.PendingBlockNumber {
@@ -757,10 +939,18 @@ func BlockByNumberOrHash(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberO
 //	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 //}
 
+// DoEstimateGas returns the lowest gas limit the transaction succeeds with.
+//
+// The transaction is first executed with the highest allowance: one failing
+// there fails with any limit, its revert data or error is returned right
+// away. The limit is then binary searched between the gas used by that run,
+// at least the intrinsic gas, and the allowance, starting with a guess of
+// the gas used raised by the 1/64th the EVM withholds from calls. A run
+// failing for any reason, revert included, raises the lower bound, as
+// contracts may revert when gasleft() is too low.
 func DoEstimateGas(ctx context.Context, n *API, args TransactionArgs, blockNrOrHash jsonrpc.BlockNumberOrHash, gasCap uint64) (hexutil.Uint64, error) {
-	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
-		lo  = params.TxGas - 1
+		lo  uint64
 		hi  uint64
 		cap uint64
 	)
@@ -768,6 +958,21 @@ func DoEstimateGas(ctx context.Context, n *API, args TransactionArgs, blockNrOrH
 	if args.From == nil {
 		args.From = new(mvm_common.Address)
 	}
+	header, err := headerByNumberOrHash(n, blockNrOrHash)
+	if err != nil {
+		return 0, err
+	}
+	// No limit can be below the intrinsic gas of the transaction.
+	msg, err := args.ToMessage(0, nil)
+	if err != nil {
+		return 0, err
+	}
+	rules := n.GetChainConfig().Rules(header.Number64().Uint64(), header.(*block.Header).Time)
+	intrinsic, err := internal.IntrinsicGas(msg.Data(), msg.AccessList(), msg.To() == nil, rules.IsHomestead, rules.IsIstanbul, rules.IsShanghai)
+	if err != nil {
+		return 0, err
+	}
+	lo = intrinsic - 1
 	// Determine the highest gas limit can be used during the estimation.
 	if args.Gas != nil && uint64(*args.Gas) >= params.TxGas {
 		hi = uint64(*args.Gas)
@@ -800,9 +1005,9 @@ func DoEstimateGas(ctx context.Context, n *API, args TransactionArgs, blockNrOrH
 			return 0, err
 		}
 		defer tx.Rollback()
-		statedb := n.State(tx, blockNrOrHash)
-		if statedb == nil {
-			return 0, errors.New("cannot load stateDB")
+		statedb, err := n.State(tx, blockNrOrHash)
+		if err != nil {
+			return 0, err
 		}
 		balance := statedb.GetBalance(*mvm_types.ToAmcAddress(args.From)) // from
 
@@ -833,11 +1038,14 @@ func DoEstimateGas(ctx context.Context, n *API, args TransactionArgs, blockNrOrH
 		hi = gasCap
 	}
 	cap = hi
+	if hi <= lo {
+		return 0, fmt.Errorf("gas required exceeds allowance (%d)", cap)
+	}
 
 	// Create a helper to check if a gas allowance results in an executable transaction
 	executable := func(gas uint64) (bool, *internal.ExecutionResult, error) {
 		args.Gas = (*hexutil.Uint64)(&gas)
-		result, err := DoCall(ctx, n, args, blockNrOrHash, nil, 0, gasCap)
+		result, err := DoCall(ctx, n, args, blockNrOrHash, nil, nil, n.RPCEVMTimeout(), gasCap)
 		if err != nil {
 			if errors.Is(err, internal.ErrIntrinsicGas) {
 				return true, nil, nil // Special case, raise gas limit
@@ -846,6 +1054,37 @@ func DoEstimateGas(ctx context.Context, n *API, args TransactionArgs, blockNrOrH
 		}
 		return result.Failed(), result, nil
 	}
+	failed, result, err := executable(hi)
+	if err != nil {
+		return 0, err
+	}
+	if failed {
+		if result != nil && !errors.Is(result.Err, vm2.ErrOutOfGas) {
+			if len(result.Revert()) > 0 {
+				return 0, newRevertError(result)
+			}
+			return 0, result.Err
+		}
+		// Otherwise, the specified gas cap is too low
+		return 0, fmt.Errorf("gas required exceeds allowance (%d)", cap)
+	}
+	// The limit can't be below the gas the transaction consumed.
+	if result.UsedGas > lo+1 {
+		lo = result.UsedGas - 1
+	}
+	// Calls only get 63/64 of the gas left, the limit usually has to leave
+	// that much more than what was used.
+	if optimistic := (result.UsedGas + params.CallStipend) * 64 / 63; optimistic > lo && optimistic < hi {
+		failed, _, err := executable(optimistic)
+		if err != nil {
+			return 0, err
+		}
+		if failed {
+			lo = optimistic
+		} else {
+			hi = optimistic
+		}
+	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
@@ -863,23 +1102,6 @@ func DoEstimateGas(ctx context.Context, n *API, args TransactionArgs, blockNrOrH
 			hi = mid
 		}
 	}
-	// Reject the transaction as invalid if it still fails at the highest allowance
-	if hi == cap {
-		failed, result, err := executable(hi)
-		if err != nil {
-			return 0, err
-		}
-		if failed {
-			if result != nil && !errors.Is(result.Err, vm2.ErrOutOfGas) {
-				if len(result.Revert()) > 0 {
-					return 0, newRevertError(result)
-				}
-				return 0, result.Err
-			}
-			// Otherwise, the specified gas cap is too low
-			return 0, fmt.Errorf("gas required exceeds allowance (%d)", cap)
-		}
-	}
 	return hexutil.Uint64(hi), nil
 	//return hexutil.Uint64(baseFee), nil
 }
@@ -891,7 +1113,110 @@ func (s *BlockChainAPI) EstimateGas(ctx context.Context, args TransactionArgs, b
 	if blockNrOrHash != nil {
 		bNrOrHash = *blockNrOrHash
 	}
-	return DoEstimateGas(ctx, s.api, args, bNrOrHash, rpcGasCap)
+	return DoEstimateGas(ctx, s.api, args, bNrOrHash, s.api.RPCGasCap())
+}
+
+// accessListResult returns an optional accesslist
+// It's the result of the `eth_createAccessList` RPC call.
+// It contains an error if the transaction itself failed.
+type accessListResult struct {
+	Accesslist *mvm_types.AccessList `json:"accessList"`
+	Error      string                `json:"error,omitempty"`
+	GasUsed    hexutil.Uint64        `json:"gasUsed"`
+}
+
+// CreateAccessList creates an EIP-2930 type AccessList for the given transaction.
+// BlockNrOrHash can be specified to create the accessList on top of a certain state.
+func (s *BlockChainAPI) CreateAccessList(ctx context.Context, args TransactionArgs, blockNrOrHash *jsonrpc.BlockNumberOrHash) (*accessListResult, error) {
+	bNrOrHash := jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	acl, gasUsed, vmerr, err := AccessList(ctx, s.api, bNrOrHash, args)
+	if err != nil {
+		return nil, err
+	}
+	accessList := mvm_types.FromAmcAccessList(acl)
+	result := &accessListResult{Accesslist: &accessList, GasUsed: hexutil.Uint64(gasUsed)}
+	if vmerr != nil {
+		result.Error = vmerr.Error()
+	}
+	return result, nil
+}
+
+// AccessList creates an access list for the given transaction.
+// If the accesslist creation fails an error is returned.
+// If the transaction itself fails, an vmErr is returned.
+func AccessList(ctx context.Context, api *API, blockNrOrHash jsonrpc.BlockNumberOrHash, args TransactionArgs) (acl transaction.AccessList, gasUsed uint64, vmErr error, err error) {
+	// Retrieve the execution context
+	header, err := headerByNumberOrHash(api, blockNrOrHash)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	// If the gas amount is not set, default to RPC gas cap.
+	if args.Gas == nil {
+		tmp := hexutil.Uint64(api.RPCGasCap())
+		args.Gas = &tmp
+	}
+	// Ensure any missing fields are filled, extract the recipient and input data
+	if err := args.setDefaults(ctx, api); err != nil {
+		return nil, 0, nil, err
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if nil != err {
+		return nil, 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var to types.Address
+	if args.To != nil {
+		to = *mvm_types.ToAmcAddress(args.To)
+	} else {
+		to = crypto.CreateAddress(args.from(), uint64(*args.Nonce))
+	}
+	// Retrieve the precompiles since they don't need to be added to the access list
+	precompiles := vm2.ActivePrecompiles(api.GetChainConfig().Rules(header.Number64().Uint64(), header.(*block.Header).Time))
+
+	// Create an initial tracer
+	prevTracer := logger.NewAccessListTracer(nil, args.from(), to, precompiles)
+	if args.AccessList != nil {
+		prevTracer = logger.NewAccessListTracer(mvm_types.ToAmcAccessList(*args.AccessList), args.from(), to, precompiles)
+	}
+	for {
+		// Retrieve the current access list to expand
+		accessList := prevTracer.AccessList()
+		log.Trace("Creating access list", "input", accessList)
+
+		// Copy the original db so we don't modify it
+		ibs, err := api.State(tx, blockNrOrHash)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		// Set the accesslist to the last al
+		al := mvm_types.FromAmcAccessList(accessList)
+		args.AccessList = &al
+		msg, err := args.ToMessage(api.RPCGasCap(), header.BaseFee64().ToBig())
+		if err != nil {
+			return nil, 0, nil, err
+		}
+
+		// Apply the transaction with the access list tracer
+		tracer := logger.NewAccessListTracer(accessList, args.from(), to, precompiles)
+		config := vm2.Config{Tracer: tracer, Debug: true, NoBaseFee: true}
+		evm, _, err := api.GetEvm(ctx, msg, ibs, api.BlockContext(tx, header, nil), &config)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		res, err := internal.ApplyMessage(evm, msg, new(common.GasPool).AddGas(msg.Gas()), true, false)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("failed to apply transaction: %v err: %v", args.toTransaction().Hash(), err)
+		}
+		if tracer.Equal(prevTracer) {
+			return accessList, res.UsedGas, res.Err, nil
+		}
+		prevTracer = tracer
+	}
 }
 
 // GetBlockByNumber returns the requested canonical block.
@@ -905,6 +1230,9 @@ func (s *BlockChainAPI) GetBlockByNumber(ctx context.Context, number jsonrpc.Blo
 		block block.IBlock
 		err   error
 	)
+	if number, err = resolveCheckpoint(ctx, s.api, number); err != nil {
+		return nil, err
+	}
 	// header
 	if number == jsonrpc.LatestBlockNumber {
 		block = s.api.BlockChain().CurrentBlock()
@@ -1017,9 +1345,9 @@ func (s *TransactionAPI) GetTransactionCount(ctx context.Context, address mvm_co
 	}
 	defer tx.Rollback()
 
-	state := s.api.State(tx, blockNrOrHash)
-	if state == nil {
-		return nil, nil
+	state, err := s.api.State(tx, blockNrOrHash)
+	if err != nil {
+		return nil, err
 	}
 	nonce := state.GetNonce(*mvm_types.ToAmcAddress(&address))
 	return (*hexutil.Uint64)(&nonce), nil
@@ -1102,13 +1430,60 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash mvm_com
 	if len(receipts) <= int(index) {
 		return nil, nil
 	}
-	receipt := receipts[index]
+	header, err := s.api.BlockChain().GetHeaderByHash(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	fields := marshalReceipt(receipts[index], tx, blockHash, blockNumber, index, header)
+
+	//json, _ := json.Marshal(fields)
+	//log.Infof("GetTransactionReceipt, result %s", string(json))
+	return fields, nil
+}
+
+// GetBlockReceipts returns the receipts of all the transactions in a block,
+// read together with the block in a single database transaction.
+func (s *TransactionAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	iblock, err := BlockByNumberOrHash(ctx, blockNrOrHash, s.api)
+	if err != nil || iblock == nil {
+		return nil, err
+	}
+	var (
+		blk      *block.Block
+		receipts block.Receipts
+	)
+	if err := s.api.Database().View(ctx, func(t kv.Tx) error {
+		var senders []types.Address
+		if blk, senders, err = rawdb.ReadBlockWithSenders(t, iblock.Hash(), iblock.Number64().Uint64()); err != nil || blk == nil {
+			return err
+		}
+		receipts = rawdb.ReadReceipts(t, blk, senders)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if blk == nil {
+		return nil, nil
+	}
+	txs := blk.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("receipts of block %d don't match its transactions", blk.Number64().Uint64())
+	}
+	result := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		result[i] = marshalReceipt(receipt, txs[i], blk.Hash(), blk.Number64().Uint64(), uint64(i), blk.Header())
+	}
+	return result, nil
+}
 
+// marshalReceipt converts a receipt into the JSON form of the RPC, filling in
+// the fields taken from its transaction and block.
+func marshalReceipt(receipt *block.Receipt, tx *transaction.Transaction, blockHash types.Hash, blockNumber uint64, index uint64, header block.IHeader) map[string]interface{} {
 	from := tx.From()
 	fields := map[string]interface{}{
 		"blockHash":         mvm_types.FromAmcHash(blockHash),
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
+		"transactionHash":   mvm_types.FromAmcHash(tx.Hash()),
 		"transactionIndex":  hexutil.Uint64(index),
 		"from":              mvm_types.FromAmcAddress(from),
 		"to":                mvm_types.FromAmcAddress(tx.To()),
@@ -1123,10 +1498,6 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash mvm_com
 	if false {
 		fields["effectiveGasPrice"] = hexutil.Uint64(tx.GasPrice().Uint64())
 	} else {
-		header, err := s.api.BlockChain().GetHeaderByHash(blockHash)
-		if err != nil {
-			return nil, err
-		}
 		gasPrice := new(big.Int).Add(header.BaseFee64().ToBig(), tx.EffectiveGasTipValue(header.BaseFee64()).ToBig())
 		fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
 	}
@@ -1145,10 +1516,7 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash mvm_com
 	if !receipt.ContractAddress.IsNull() {
 		fields["contractAddress"] = mvm_types.FromAmcAddress(&receipt.ContractAddress)
 	}
-
-	//json, _ := json.Marshal(fields)
-	//log.Infof("GetTransactionReceipt, result %s", string(json))
-	return fields, nil
+	return fields
 }
 
 // GetBlockTransactionCountByHash returns the number of transactions in the block with the given hash.
@@ -1216,6 +1584,9 @@ func (s *TransactionAPI) GetTransactionByBlockHashAndIndex(ctx context.Context,
 
 // SubmitTransaction ?
 func SubmitTransaction(ctx context.Context, api *API, tx *transaction.Transaction) (mvm_common.Hash, error) {
+	if api.syncing.Load() {
+		return mvm_common.Hash{}, errNodeSyncing
+	}
 
 	if err := checkTxFee(*tx.GasPrice(), tx.Gas(), baseFee); err != nil {
 		return mvm_common.Hash{}, err
@@ -1260,11 +1631,76 @@ func (s *TransactionAPI) SendTransaction(ctx context.Context, args TransactionAr
 	if err != nil {
 		return mvm_common.Hash{}, err
 	}
-	// todo sign?
-	//signed := tx
 	return SubmitTransaction(ctx, s.api, signed)
 }
 
+// SignTransactionResult represents a RLP encoded signed transaction.
+type SignTransactionResult struct {
+	Raw hexutil.Bytes   `json:"raw"`
+	Tx  *RPCTransaction `json:"tx"`
+}
+
+// newSignTransactionResult encodes the signed transaction in its canonical
+// ethereum form so it can be submitted with eth_sendRawTransaction.
+func newSignTransactionResult(api *API, tx *transaction.Transaction) (*SignTransactionResult, error) {
+	var ethTx mvm_types.Transaction
+	ethTx.FromAmcTransaction(tx)
+	data, err := ethTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &SignTransactionResult{data, newRPCPendingTransaction(tx, api.BlockChain().CurrentBlock().Header())}, nil
+}
+
+// SignTransaction will sign the given transaction with the from account.
+// The node needs to have the private key of the account corresponding with
+// the given from address and it needs to be unlocked.
+func (s *TransactionAPI) SignTransaction(ctx context.Context, args TransactionArgs) (*SignTransactionResult, error) {
+	if args.Gas == nil {
+		return nil, errors.New("gas not specified")
+	}
+	if args.GasPrice == nil && (args.MaxPriorityFeePerGas == nil || args.MaxFeePerGas == nil) {
+		return nil, errors.New("missing gasPrice or maxFeePerGas/maxPriorityFeePerGas")
+	}
+	if args.Nonce == nil {
+		return nil, errors.New("nonce not specified")
+	}
+	if err := args.setDefaults(ctx, s.api); err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: args.from()}
+	wallet, err := s.api.accountManager.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := wallet.SignTx(account, args.toTransaction(), s.api.GetChainConfig().ChainID)
+	if err != nil {
+		return nil, err
+	}
+	return newSignTransactionResult(s.api, signed)
+}
+
+// Sign calculates an ECDSA signature for:
+// keccak256("\x19Ethereum Signed Message:\n" + len(message) + message).
+//
+// Note, the produced signature conforms to the secp256k1 curve R, S and V values,
+// where the V value will be 27 or 28 for legacy reasons.
+//
+// The account associated with addr must be unlocked.
+func (s *TransactionAPI) Sign(addr mvm_common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	account := accounts.Account{Address: *mvm_types.ToAmcAddress(&addr)}
+
+	wallet, err := s.api.accountManager.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignText(account, data)
+	if err == nil {
+		signature[crypto.RecoveryIDOffset] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	}
+	return signature, err
+}
+
 // checkTxFee  todo
 func checkTxFee(gasPrice uint256.Int, gas uint64, cap float64) error {
 	return nil
@@ -1283,8 +1719,9 @@ type Web3API struct {
 	stack *API
 }
 
+// ClientVersion returns the node name, version, platform and Go version.
 func (s *Web3API) ClientVersion() string {
-	return "testName"
+	return params.ClientVersion()
 }
 
 func (s *Web3API) Sha3(input hexutil.Bytes) hexutil.Bytes {
@@ -1300,15 +1737,166 @@ func NewDebugAPI(api *API) *DebugAPI {
 	return &DebugAPI{api: api}
 }
 
-// SetHead rewinds the head of the blockchain to a previous block.
-func (api *DebugAPI) SetHead(number hexutil.Uint64) {
-	api.api.BlockChain().SetHead(uint64(number))
+// SetHead rewinds the head of the blockchain to a previous block. It fails
+// if the state of the block is no longer available or the block is below the
+// finalized one.
+func (api *DebugAPI) SetHead(number hexutil.Uint64) error {
+	return api.api.BlockChain().SetHead(uint64(number))
 }
 
 func (debug *DebugAPI) GetAccount(ctx context.Context, address types.Address) {
 
 }
 
+// BadBlockArgs represents the entries in the list returned when bad blocks are queried.
+type BadBlockArgs struct {
+	Hash    types.Hash             `json:"hash"`
+	Block   map[string]interface{} `json:"block"`
+	Encoded hexutil.Bytes          `json:"encoded"`
+	Reason  string                 `json:"reason"`
+	Time    hexutil.Uint64         `json:"time"`
+}
+
+// GetBadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
+// and returns them as a JSON list of block hashes.
+func (api *DebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs, error) {
+	chain, ok := api.api.BlockChain().(*internal.BlockChain)
+	if !ok {
+		return nil, errors.New("bad blocks are not tracked")
+	}
+	bads, err := chain.BadBlocks()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*BadBlockArgs, 0, len(bads))
+	for _, bad := range bads {
+		var (
+			encoded, _ = bad.Block.Marshal()
+			fields, _  = RPCMarshalBlock(bad.Block, api.api.BlockChain(), true, true)
+		)
+		results = append(results, &BadBlockArgs{
+			Hash:    bad.Block.Hash(),
+			Block:   fields,
+			Encoded: encoded,
+			Reason:  bad.Reason,
+			Time:    hexutil.Uint64(bad.Time),
+		})
+	}
+	return results, nil
+}
+
+// diffChain type-asserts the chain able to compute state diffs.
+func (api *DebugAPI) diffChain() (*internal.BlockChain, error) {
+	chain, ok := api.api.BlockChain().(*internal.BlockChain)
+	if !ok {
+		return nil, errors.New("state diffs are not supported")
+	}
+	return chain, nil
+}
+
+// StateDiffBlock returns the changes to the balances, nonces, code and storage
+// of the accounts made by every transaction of a block, and by the consensus
+// engine finalizing it. The block is executed again, which needs its state
+// history.
+func (api *DebugAPI) StateDiffBlock(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash) (*internal.BlockStateDiff, error) {
+	chain, err := api.diffChain()
+	if err != nil {
+		return nil, err
+	}
+	var hash types.Hash
+	if number, ok := blockNrOrHash.Number(); ok {
+		switch {
+		case number == jsonrpc.LatestBlockNumber || number == jsonrpc.PendingBlockNumber:
+			number = jsonrpc.BlockNumber(chain.CurrentBlock().Number64().Uint64())
+		case number < jsonrpc.EarliestBlockNumber:
+			return nil, fmt.Errorf("unsupported block tag %d", number)
+		}
+		if hash = chain.GetCanonicalHash(uint256.NewInt(uint64(number))); hash == (types.Hash{}) {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+	} else if hash, ok = blockNrOrHash.Hash(); !ok {
+		return nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	var diff *internal.BlockStateDiff
+	err = api.api.Database().View(ctx, func(tx kv.Tx) error {
+		b, err := rawdb.ReadBlockByHash(tx, hash)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return fmt.Errorf("block %s not found", hash)
+		}
+		diff, err = chain.BlockStateDiff(tx, b)
+		return err
+	})
+	return diff, err
+}
+
+// StateDiffTransaction returns the changes to the state of the accounts made
+// by a transaction, executing the block up to it again.
+func (api *DebugAPI) StateDiffTransaction(ctx context.Context, hash types.Hash) (internal.StateDiff, error) {
+	chain, err := api.diffChain()
+	if err != nil {
+		return nil, err
+	}
+	var diff internal.StateDiff
+	err = api.api.Database().View(ctx, func(tx kv.Tx) error {
+		t, blockHash, _, index, err := rawdb.ReadTransactionByHash(tx, hash)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return fmt.Errorf("transaction %s not found", hash)
+		}
+		b, err := rawdb.ReadBlockByHash(tx, blockHash)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return fmt.Errorf("block %s not found", blockHash)
+		}
+		diff, err = chain.TransactionStateDiff(tx, b, int(index))
+		return err
+	})
+	return diff, err
+}
+
+// defaultProfileContracts is the number of contracts EvmProfile returns by default.
+const defaultProfileContracts = 100
+
+func (api *DebugAPI) evmProfiler() (*vm2.Profiler, error) {
+	chain, ok := api.api.BlockChain().(*internal.BlockChain)
+	if !ok || chain.EVMProfiler() == nil {
+		return nil, errors.New("EVM profiling is disabled, start the node with --vm.profile")
+	}
+	return chain.EVMProfiler(), nil
+}
+
+// EvmProfile returns the gas and time the executions of the imported blocks
+// spent per opcode, along with the contracts they spent the most time in,
+// limit of them (100 by default, 0 for all).
+func (api *DebugAPI) EvmProfile(limit *int) (*vm2.ProfileReport, error) {
+	profiler, err := api.evmProfiler()
+	if err != nil {
+		return nil, err
+	}
+	n := defaultProfileContracts
+	if limit != nil {
+		n = *limit
+	}
+	return profiler.Report(n), nil
+}
+
+// ResetEvmProfile drops what the EVM profiler collected so far.
+func (api *DebugAPI) ResetEvmProfile() error {
+	profiler, err := api.evmProfiler()
+	if err != nil {
+		return err
+	}
+	profiler.Reset()
+	return nil
+}
+
 // NetAPI offers network related RPC methods
 type NetAPI struct {
 	api            *API
@@ -28,12 +28,14 @@ import (
 	"github.com/amazechain/amc/internal"
 	"github.com/amazechain/amc/internal/vm"
 	"github.com/amazechain/amc/internal/vm/evmtypes"
+	"github.com/amazechain/amc/log"
 	"github.com/amazechain/amc/modules/rawdb"
 	rpc "github.com/amazechain/amc/modules/rpc/jsonrpc"
 	"github.com/amazechain/amc/modules/state"
 	"github.com/amazechain/amc/params"
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
+	"time"
 )
 
 // API implements ethapi.Backend for full nodes
@@ -377,16 +379,28 @@ func (b *API) AccountManager() *accounts.Manager {
 //	return b.eth.config.RPCTxFeeCap
 //}
 
-//func (b *API) BloomStatus() (uint64, uint64) {
-//	sections, _, _ := b.eth.bloomIndexer.Sections()
-//	return params.BloomBitsBlocks, sections
-//}
-//
-//func (b *API) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
-//	for i := 0; i < bloomFilterThreads; i++ {
-//		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
-//	}
-//}
+// bloomIndex is implemented by chains indexing the bloom bits of their blocks.
+type bloomIndex interface {
+	BloomStatus() (uint64, uint64)
+	BloomBits(bit uint, section uint64) ([]byte, error)
+}
+
+// BloomStatus returns the number of blocks per section of the bloom bits and
+// the number of sections indexed.
+func (b *API) BloomStatus() (uint64, uint64) {
+	if index, ok := b.bc.(bloomIndex); ok {
+		return index.BloomStatus()
+	}
+	return params.BloomBitsBlocks, 0
+}
+
+// BloomBits returns the bit vector of a bloom bit over an indexed section.
+func (b *API) BloomBits(bit uint, section uint64) ([]byte, error) {
+	if index, ok := b.bc.(bloomIndex); ok {
+		return index.BloomBits(bit, section)
+	}
+	return nil, errors.New("bloom bits not indexed")
+}
 
 func (b *API) CurrentHeader() *types.Header {
 	return b.bc.CurrentBlock().Header().(*types.Header)
@@ -405,7 +419,7 @@ func (b *API) CurrentHeader() *types.Header {
 //}
 
 // stateAtTransaction returns the execution environment of a certain transaction.
-func (eth *API) StateAtTransaction(ctx context.Context, dbTx kv.Tx, blk *types.Block, txIndex int) (*transaction.Message, evmtypes.BlockContext, *state.IntraBlockState, error) {
+func (eth *API) StateAtTransaction(ctx context.Context, dbTx kv.Tx, blk *types.Block, txIndex int, reexec uint64) (*transaction.Message, evmtypes.BlockContext, *state.IntraBlockState, error) {
 	// Short circuit if it's genesis block.
 	if blk.Number64().Uint64() == 0 {
 		return nil, evmtypes.BlockContext{}, nil, errors.New("no transaction in genesis")
@@ -418,7 +432,7 @@ func (eth *API) StateAtTransaction(ctx context.Context, dbTx kv.Tx, blk *types.B
 	// Lookup the statedb of parent block from the live database,
 	// otherwise regenerate it on the flight.
 
-	statedb, err := eth.StateAtBlock(ctx, dbTx, parent)
+	statedb, err := eth.StateAtBlock(ctx, dbTx, parent, reexec)
 	if err != nil {
 		return nil, evmtypes.BlockContext{}, nil, err
 	}
@@ -483,152 +497,64 @@ func (eth *API) StateAtTransaction(ctx context.Context, dbTx kv.Tx, blk *types.B
 	return nil, evmtypes.BlockContext{}, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, blk.Hash())
 }
 
+// defaultStateReexec is the number of blocks re-executed at most to
+// regenerate a state the database doesn't keep.
+const defaultStateReexec = uint64(128)
+
 // StateAtBlock retrieves the state database associated with a certain block.
-// If no state is locally available for the given block, a number of blocks
-// are attempted to be reexecuted to generate the desired state. The optional
-// base layer statedb can be provided which is regarded as the statedb of the
-// parent block.
 //
-// An additional release function will be returned if the requested state is
-// available. Release is expected to be invoked when the returned state is no longer needed.
-// Its purpose is to prevent resource leaking. Though it can be noop in some cases.
-//
-// Parameters:
-//   - block:      The block for which we want the state(state = block.Root)
-//   - reexec:     The maximum number of blocks to reprocess trying to obtain the desired state
-//   - base:       If the caller is tracing multiple blocks, the caller can provide the parent
-//     state continuously from the callsite.
-//   - readOnly:   If true, then the live 'blockchain' state database is used. No mutation should
-//     be made from caller, e.g. perform Commit or other 'save-to-disk' changes.
-//     Otherwise, the trash generated by caller may be persisted permanently.
-//   - preferDisk: this arg can be used by the caller to signal that even though the 'base' is
-//     provided, it would be preferable to start from a fresh state, if we have it
-//     on disk.
-func (eth *API) StateAtBlock(ctx context.Context, tx kv.Tx, blk *types.Block) (statedb *state.IntraBlockState, err error) {
+// The flat state only keeps the history of the canonical chain. The state of
+// a side chain block is regenerated by re-executing, on top of the state of
+// the canonical block it forks from, the side chain blocks up to it, at most
+// reexec of them. The states older than the history the node keeps can't be
+// regenerated: no older state is left to re-execute from.
+func (eth *API) StateAtBlock(ctx context.Context, tx kv.Tx, blk *types.Block, reexec uint64) (statedb *state.IntraBlockState, err error) {
 	var (
-		//current *block.Block
-		//// database state.Database
-		//report = true
-		origin = blk.Number64().Uint64()
+		base   = blk
+		replay []*types.Block
 	)
-	// The state is only for reading purposes, check the state presence in
-	// live database.
-	//if readOnly {
-	// The state is available in live database, create a reference
-	// on top to prevent garbage collection and return a release
-	// function to deref it.
-	statedb = eth.BlockChain().StateAt(tx, origin)
-	//statedb.Database().TrieDB().Reference(block.Root(), common.Hash{})
+	for {
+		number := base.Number64().Uint64()
+		canonical, err := rawdb.ReadCanonicalHash(tx, number)
+		if err != nil {
+			return nil, err
+		}
+		if canonical == base.Hash() {
+			break
+		}
+		if uint64(len(replay)) >= reexec {
+			return nil, fmt.Errorf("%w: block %d is more than %d blocks away from the canonical chain (reexec=%d)", errStateUnavailable, blk.Number64().Uint64(), reexec, reexec)
+		}
+		if number == 0 {
+			return nil, errors.New("genesis isn't canonical")
+		}
+		replay = append(replay, base)
+		parent, ok := eth.BlockChain().GetBlock(base.ParentHash(), number-1).(*types.Block)
+		if !ok || parent == nil {
+			return nil, fmt.Errorf("missing block %v %d", base.ParentHash(), number-1)
+		}
+		base = parent
+	}
+	if err := checkStateHistory(tx, base.Number64().Uint64()); err != nil {
+		return nil, err
+	}
+	statedb = eth.BlockChain().StateAt(tx, base.Number64().Uint64())
+	if len(replay) == 0 {
+		return statedb, nil
+	}
+	chain, ok := eth.BlockChain().(*internal.BlockChain)
+	if !ok {
+		return nil, fmt.Errorf("%w: blocks can't be re-executed", errStateUnavailable)
+	}
+	start := time.Now()
+	for i := len(replay) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := chain.ReplayBlock(tx, statedb, replay[i]); err != nil {
+			return nil, fmt.Errorf("processing block %d failed: %w", replay[i].Number64().Uint64(), err)
+		}
+	}
+	log.Debug("Regenerated side chain state", "block", blk.Number64().Uint64(), "fork", base.Number64().Uint64(), "blocks", len(replay), "elapsed", time.Since(start))
 	return statedb, nil
-	//}
-	//}
-	// The state is both for reading and writing, or it's unavailable in disk,
-	// try to construct/recover the state over an ephemeral trie.Database for
-	// isolating the live one.
-	//if base != nil {
-	//	if preferDisk {
-	//		// Create an ephemeral trie.Database for isolating the live one. Otherwise
-	//		// the internal junks created by tracing will be persisted into the disk.
-	//		database = state.NewDatabaseWithConfig(eth.chainDb, &trie.Config{Cache: 16})
-	//		if statedb, err = state.New(block.Root(), database, nil); err == nil {
-	//			log.Info("Found disk backend for state trie", "root", block.Root(), "number", block.Number())
-	//			return statedb, noopReleaser, nil
-	//		}
-	//	}
-	//	// The optional base statedb is given, mark the start point as parent block
-	//	statedb, database, report = base, base.Database(), false
-	//	current = eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
-	//} else {
-	// Otherwise, try to reexec blocks until we find a state or reach our limit
-	//current = block
-
-	// Create an ephemeral trie.Database for isolating the live one. Otherwise
-	// the internal junks created by tracing will be persisted into the disk.
-	// database = state.NewDatabaseWithConfig(eth.chainDb, &trie.Config{Cache: 16})
-
-	// If we didn't check the live database, do check state over ephemeral database,
-	// otherwise we would rewind past a persisted block (specific corner case is
-	// chain tracing from the genesis).
-	//if !readOnly {
-	//	statedb, err = state.New(current.Root(), database, nil)
-	//	if err == nil {
-	//		return statedb, noopReleaser, nil
-	//	}
-	//}
-	//// Database does not have the state for the given block, try to regenerate
-	//for i := uint64(0); i < reexec; i++ {
-	//	if err := ctx.Err(); err != nil {
-	//		return nil, nil, err
-	//	}
-	//	if current.NumberU64() == 0 {
-	//		return nil, nil, errors.New("genesis state is missing")
-	//	}
-	//	parent := eth.blockchain.GetBlock(current.ParentHash(), current.NumberU64()-1)
-	//	if parent == nil {
-	//		return nil, nil, fmt.Errorf("missing block %v %d", current.ParentHash(), current.NumberU64()-1)
-	//	}
-	//	current = parent
-	//
-	//	statedb, err = state.New(current.Root(), database, nil)
-	//	if err == nil {
-	//		break
-	//	}
-	//}
-	//if err != nil {
-	//	switch err.(type) {
-	//	case *trie.MissingNodeError:
-	//		return nil, nil, fmt.Errorf("required historical state unavailable (reexec=%d)", reexec)
-	//	default:
-	//		return nil, nil, err
-	//	}
-	//}
-	//}
-	// State is available at historical point, re-execute the blocks on top for
-	// the desired state.
-	//var (
-	//	start  = time.Now()
-	//	logged time.Time
-	//	parent common.Hash
-	//)
-	//for current.NumberU64() < origin {
-	//	if err := ctx.Err(); err != nil {
-	//		return nil, nil, err
-	//	}
-	//	// Print progress logs if long enough time elapsed
-	//	if time.Since(logged) > 8*time.Second && report {
-	//		log.Info("Regenerating historical state", "block", current.NumberU64()+1, "target", origin, "remaining", origin-current.NumberU64()-1, "elapsed", time.Since(start))
-	//		logged = time.Now()
-	//	}
-	//	// Retrieve the next block to regenerate and process it
-	//	next := current.NumberU64() + 1
-	//	if current = eth.blockchain.GetBlockByNumber(next); current == nil {
-	//		return nil, nil, fmt.Errorf("block #%d not found", next)
-	//	}
-	//	_, _, _, err := eth.blockchain.Processor().Process(current, statedb, vm.Config{})
-	//	if err != nil {
-	//		return nil, nil, fmt.Errorf("processing block %d failed: %v", current.NumberU64(), err)
-	//	}
-	//	// Finalize the state so any modifications are written to the trie
-	//	root, err := statedb.Commit(eth.blockchain.Config().IsEIP158(current.Number()))
-	//	if err != nil {
-	//		return nil, nil, fmt.Errorf("stateAtBlock commit failed, number %d root %v: %w",
-	//			current.NumberU64(), current.Root().Hex(), err)
-	//	}
-	//	statedb, err = state.New(root, database, nil)
-	//	if err != nil {
-	//		return nil, nil, fmt.Errorf("state reset after block %d failed: %v", current.NumberU64(), err)
-	//	}
-	//	// Hold the state reference and also drop the parent state
-	//	// to prevent accumulating too many nodes in memory.
-	//	database.TrieDB().Reference(root, common.Hash{})
-	//	if parent != (common.Hash{}) {
-	//		database.TrieDB().Dereference(parent)
-	//	}
-	//	parent = root
-	//}
-	//if report {
-	//	nodes, imgs := database.TrieDB().Size()
-	//	log.Info("Historical state regenerated", "block", current.NumberU64(), "elapsed", time.Since(start), "nodes", nodes, "preimages", imgs)
-	//}
-	//return statedb, func() { database.TrieDB().Dereference(block.Root()) }, nil
 }
@@ -0,0 +1,62 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+)
+
+func TestStateHistory(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	for number := uint64(0); number <= 10; number++ {
+		hash := types.Hash{byte(number + 1)}
+		if err := rawdb.WriteCanonicalHash(tx, hash, number); err != nil {
+			t.Fatal(err)
+		}
+		if err := rawdb.WriteHeaderNumber(tx, hash, number); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The changes of the blocks below 6 are pruned.
+	if err := rawdb.WriteStatePruneProgress(tx, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	api := &API{}
+	for number := int64(0); number <= 10; number++ {
+		state, err := api.State(tx, jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.BlockNumber(number)))
+		// The state as of the end of block 5 only needs the changes from 6 on.
+		if number < 5 {
+			if !errors.Is(err, errStateUnavailable) || state != nil {
+				t.Errorf("state of pruned block %d: %v", number, err)
+			}
+			continue
+		}
+		if err != nil || state == nil {
+			t.Errorf("state of block %d: %v", number, err)
+		}
+	}
+	if _, err := api.State(tx, jsonrpc.BlockNumberOrHashWithHash(types.Hash{0xff}, false)); err == nil {
+		t.Error("state of an unknown block")
+	}
+}
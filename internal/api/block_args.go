@@ -136,6 +136,9 @@ func RPCMarshalHeader(head block.IHeader) map[string]interface{} {
 	if header.BaseFee != nil {
 		result["baseFeePerGas"] = (*hexutil.Big)(header.BaseFee.ToBig())
 	}
+	for _, field := range header.Extension {
+		result[field.Field.String()] = field.Interface()
+	}
 
 	return result
 }
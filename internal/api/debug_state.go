@@ -0,0 +1,193 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/amazechain/amc/turbo/rpchelper"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// AccountRangeMaxResults is the maximum number of accounts returned by a
+// single debug_accountRange call.
+const AccountRangeMaxResults = 256
+
+// stateHeader resolves a block whose state is still kept.
+func stateHeader(tx kv.Tx, blockNrOrHash jsonrpc.BlockNumberOrHash) (*block.Header, error) {
+	number, hash, err := rpchelper.GetCanonicalBlockNumber(blockNrOrHash, tx)
+	if err != nil {
+		return nil, err
+	}
+	header := rawdb.ReadHeader(tx, hash, number.Uint64())
+	if header == nil {
+		return nil, fmt.Errorf("header %s not found", hash)
+	}
+	if err := checkStateHistory(tx, number.Uint64()); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// DumpBlock returns the whole state as of the end of the given block.
+func (api *DebugAPI) DumpBlock(ctx context.Context, blockNr jsonrpc.BlockNumber) (state.Dump, error) {
+	if blockNr == jsonrpc.PendingBlockNumber {
+		return state.Dump{}, fmt.Errorf("the pending block has no state")
+	}
+	tx, err := api.api.db.BeginRo(ctx)
+	if err != nil {
+		return state.Dump{}, err
+	}
+	defer tx.Rollback()
+
+	header, err := stateHeader(tx, jsonrpc.BlockNumberOrHashWithNumber(blockNr))
+	if err != nil {
+		return state.Dump{}, err
+	}
+	dump, err := state.DumpState(tx, header.Number64().Uint64()+1, nil)
+	if err != nil {
+		return state.Dump{}, err
+	}
+	return state.Dump{Root: header.Root.String(), Accounts: dump.Accounts}, nil
+}
+
+// AccountRange returns a page of the accounts as of the end of the given
+// block, in address order from start. The next field of the result is where
+// the following page starts. Every account in the flat state has its address,
+// so incompletes has nothing to add and is only accepted for compatibility.
+func (api *DebugAPI) AccountRange(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash, start hexutil.Bytes, maxResults int, nocode, nostorage, incompletes bool) (state.IteratorDump, error) {
+	if number, ok := blockNrOrHash.Number(); ok && number == jsonrpc.PendingBlockNumber {
+		return state.IteratorDump{}, fmt.Errorf("the pending block has no state")
+	}
+	tx, err := api.api.db.BeginRo(ctx)
+	if err != nil {
+		return state.IteratorDump{}, err
+	}
+	defer tx.Rollback()
+
+	header, err := stateHeader(tx, blockNrOrHash)
+	if err != nil {
+		return state.IteratorDump{}, err
+	}
+	if maxResults <= 0 || maxResults > AccountRangeMaxResults {
+		maxResults = AccountRangeMaxResults
+	}
+	dump, err := state.DumpState(tx, header.Number64().Uint64()+1, &state.DumpConfig{
+		SkipCode:    nocode,
+		SkipStorage: nostorage,
+		Start:       start,
+		Max:         uint64(maxResults),
+	})
+	if err != nil {
+		return state.IteratorDump{}, err
+	}
+	dump.Root = header.Root.String()
+	return *dump, nil
+}
+
+// Preimage returns the preimage of a keccak256 hash recorded by a node
+// running with --cache.preimages.
+func (api *DebugAPI) Preimage(ctx context.Context, hash types.Hash) (hexutil.Bytes, error) {
+	tx, err := api.api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	preimage, err := rawdb.ReadPreimage(tx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if preimage == nil {
+		return nil, errors.New("unknown preimage")
+	}
+	return preimage, nil
+}
+
+// StorageRangeResult is the result of a debug_storageRangeAt API call.
+type StorageRangeResult struct {
+	Storage storageMap  `json:"storage"`
+	NextKey *types.Hash `json:"nextKey"` // nil if Storage includes the last key in the trie.
+}
+
+type storageMap map[types.Hash]storageEntry
+
+type storageEntry struct {
+	Key   *types.Hash `json:"key"`
+	Value types.Hash  `json:"value"`
+}
+
+// StorageRangeAt returns the storage of a contract as seen by the transaction
+// at txIndex in the given block, that is after the transactions before it,
+// in slot order from keyStart.
+func (api *DebugAPI) StorageRangeAt(ctx context.Context, blockHash types.Hash, txIndex int, contractAddress types.Address, keyStart hexutil.Bytes, maxResult int) (StorageRangeResult, error) {
+	iblock, err := api.api.BlockChain().GetBlockByHash(blockHash)
+	if err != nil {
+		return StorageRangeResult{}, err
+	}
+	blk, ok := iblock.(*block.Block)
+	if !ok || blk == nil {
+		return StorageRangeResult{}, fmt.Errorf("block %s not found", blockHash)
+	}
+	tx, err := api.api.db.BeginRo(ctx)
+	if err != nil {
+		return StorageRangeResult{}, err
+	}
+	defer tx.Rollback()
+
+	_, _, statedb, err := api.api.StateAtTransaction(ctx, tx, blk, txIndex, defaultStateReexec)
+	if err != nil {
+		return StorageRangeResult{}, err
+	}
+	reader, ok := statedb.GetStateReader().(*state.PlainState)
+	if !ok {
+		return StorageRangeResult{}, fmt.Errorf("state of block %s can't be iterated", blockHash)
+	}
+	return storageRangeAt(reader, contractAddress, keyStart, maxResult)
+}
+
+// storageRangeAt reads one slot past maxResult to learn where the next page starts.
+func storageRangeAt(reader *state.PlainState, contractAddress types.Address, start []byte, maxResult int) (StorageRangeResult, error) {
+	result := StorageRangeResult{Storage: storageMap{}}
+	if maxResult <= 0 {
+		return result, nil
+	}
+	account, err := reader.ReadAccountData(contractAddress)
+	if err != nil || account == nil {
+		return result, err
+	}
+	err = reader.ForEachStorage(contractAddress, types.BytesToHash(start), func(key, seckey types.Hash, value uint256.Int) bool {
+		if len(result.Storage) == maxResult {
+			next := key
+			result.NextKey = &next
+			return false
+		}
+		k := key
+		result.Storage[seckey] = storageEntry{Key: &k, Value: value.Bytes32()}
+		return true
+	}, maxResult+1)
+	return result, err
+}
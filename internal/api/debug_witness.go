@@ -0,0 +1,87 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/amazechain/amc/turbo/rpchelper"
+)
+
+func (api *DebugAPI) witnessChain() (*internal.BlockChain, error) {
+	chain, ok := api.api.BlockChain().(*internal.BlockChain)
+	if !ok {
+		return nil, errors.New("blocks can't be re-executed")
+	}
+	return chain, nil
+}
+
+// ExecutionWitness executes a canonical block again and returns the state it
+// read: the accounts, slots, codes and ancestor hashes needed to execute it
+// without the database.
+func (api *DebugAPI) ExecutionWitness(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash) (*state.Witness, error) {
+	chain, err := api.witnessChain()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := api.api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	number, hash, err := rpchelper.GetCanonicalBlockNumber(blockNrOrHash, tx)
+	if err != nil {
+		return nil, err
+	}
+	if number.Uint64() > 0 {
+		if err := checkStateHistory(tx, number.Uint64()-1); err != nil {
+			return nil, err
+		}
+	}
+	blk, ok := chain.GetBlock(hash, number.Uint64()).(*block.Block)
+	if !ok || blk == nil {
+		return nil, fmt.Errorf("block %s not found", hash)
+	}
+	return chain.RecordWitness(tx, blk)
+}
+
+// ExecuteStateless executes a known block against the given witness alone and
+// fails unless the block validates.
+func (api *DebugAPI) ExecuteStateless(ctx context.Context, blockHash types.Hash, witness state.Witness) error {
+	chain, err := api.witnessChain()
+	if err != nil {
+		return err
+	}
+	iblock, err := chain.GetBlockByHash(blockHash)
+	if err != nil {
+		return err
+	}
+	blk, ok := iblock.(*block.Block)
+	if !ok || blk == nil {
+		return fmt.Errorf("block %s not found", blockHash)
+	}
+	_, err = chain.ExecuteStateless(blk, &witness)
+	return err
+}
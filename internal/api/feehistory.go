@@ -102,7 +102,9 @@ func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
 		bf.results.nextBaseFee = new(big.Int)
 	}
 
-	bf.results.gasUsedRatio = float64(bf.block.GasUsed()) / float64(bf.block.GasLimit())
+	// the block body is only fetched when rewards are requested, use the header
+	header := bf.header.(*block.Header)
+	bf.results.gasUsedRatio = float64(header.GasUsed) / float64(header.GasLimit)
 	if len(percentiles) == 0 {
 		// rewards were not requested, return null
 		return
@@ -221,7 +223,7 @@ func (oracle *Oracle) resolveBlockRange(ctx context.Context, reqEnd jsonrpc.Bloc
 //
 // Note: baseFee includes the next block after the newest of the returned range, because this
 // value can be derived from the newest block.
-func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLastBlock jsonrpc.BlockNumber, resolvedLastBlock *uint256.Int, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLastBlock jsonrpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
 	if blocks < 1 {
 		return common.Big0, nil, nil, nil, nil // returning with no data and no error means there are no retrievable blocks
 	}
@@ -282,15 +284,16 @@ func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLast
 						fees.results = p.(processedFees)
 						results <- fees
 					} else {
+						number := uint256.NewInt(blockNumber)
 						if len(rewardPercentiles) != 0 {
-							fees.block, fees.err = oracle.backend.GetBlockByNumber(resolvedLastBlock)
+							fees.block, fees.err = oracle.backend.GetBlockByNumber(number)
 							if fees.block != nil && fees.err == nil {
 								fees.receipts, fees.err = oracle.backend.GetReceipts(fees.block.Hash())
 								fees.header = fees.block.Header()
 							}
 						} else {
 							fees.err = nil
-							fees.header = oracle.backend.GetHeaderByNumber(resolvedLastBlock)
+							fees.header = oracle.backend.GetHeaderByNumber(number)
 						}
 						if fees.header != nil && fees.err == nil {
 							oracle.processBlock(fees, rewardPercentiles)
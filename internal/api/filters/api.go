@@ -3,6 +3,7 @@ package filters
 import (
 	"context"
 	"fmt"
+	"github.com/amazechain/amc/common"
 	"github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/types"
 	mvm_types "github.com/amazechain/amc/internal/avm/types"
@@ -146,6 +147,53 @@ func (filterApi *FilterAPI) NewPendingTransactions(ctx context.Context) (*jsonrp
 	return rpcSub, nil
 }
 
+// DroppedTransaction is the notification of a transaction dropped from the
+// transaction pool.
+type DroppedTransaction struct {
+	Hash       types.Hash          `json:"hash"`
+	Reason     common.TxDropReason `json:"reason"`
+	ReplacedBy *types.Hash         `json:"replacedBy,omitempty"`
+}
+
+// DroppedTransactions creates a subscription that is triggered each time a
+// transaction is dropped or replaced in the transaction pool without being
+// included, telling clients waiting on it that it will never confirm.
+func (filterApi *FilterAPI) DroppedTransactions(ctx context.Context) (*jsonrpc.Subscription, error) {
+	notifier, supported := jsonrpc.NotifierFromContext(ctx)
+	if !supported {
+		return &jsonrpc.Subscription{}, jsonrpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		dropped := make(chan []common.DroppedTx, 128)
+		droppedSub := filterApi.events.SubscribeDroppedTxs(dropped)
+
+		for {
+			select {
+			case txs := <-dropped:
+				for _, d := range txs {
+					n := &DroppedTransaction{Hash: d.Tx.Hash(), Reason: d.Reason}
+					if d.Replacement != (types.Hash{}) {
+						replacement := d.Replacement
+						n.ReplacedBy = &replacement
+					}
+					notifier.Notify(rpcSub.ID, n)
+				}
+			case <-rpcSub.Err():
+				droppedSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				droppedSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 func (filterApi *FilterAPI) NewBlockFilter() jsonrpc.ID {
@@ -181,6 +229,23 @@ func (filterApi *FilterAPI) NewBlockFilter() jsonrpc.ID {
 
 // NewHeads send a notification each time a new (header) block is appended to the chain.
 func (filterApi *FilterAPI) NewHeads(ctx context.Context) (*jsonrpc.Subscription, error) {
+	return filterApi.notifyHeaders(ctx, filterApi.events.SubscribeNewHeads)
+}
+
+// SafeHeads sends a notification each time the safe block moves, as the
+// consensus engine justifies a new checkpoint.
+func (filterApi *FilterAPI) SafeHeads(ctx context.Context) (*jsonrpc.Subscription, error) {
+	return filterApi.notifyHeaders(ctx, filterApi.events.SubscribeSafeHeads)
+}
+
+// FinalizedHeads sends a notification each time a new block is finalized.
+func (filterApi *FilterAPI) FinalizedHeads(ctx context.Context) (*jsonrpc.Subscription, error) {
+	return filterApi.notifyHeaders(ctx, filterApi.events.SubscribeFinalizedHeads)
+}
+
+// notifyHeaders forwards the headers of an event system subscription to an
+// RPC subscription.
+func (filterApi *FilterAPI) notifyHeaders(ctx context.Context, subscribe func(chan block.IHeader) *Subscription) (*jsonrpc.Subscription, error) {
 	notifier, supported := jsonrpc.NotifierFromContext(ctx)
 	if !supported {
 		return &jsonrpc.Subscription{}, jsonrpc.ErrNotificationsUnsupported
@@ -190,7 +255,7 @@ func (filterApi *FilterAPI) NewHeads(ctx context.Context) (*jsonrpc.Subscription
 
 	go func() {
 		headers := make(chan block.IHeader)
-		headersSub := filterApi.events.SubscribeNewHeads(headers)
+		headersSub := subscribe(headers)
 		for {
 			select {
 			case h := <-headers:
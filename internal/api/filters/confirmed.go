@@ -0,0 +1,211 @@
+package filters
+
+import (
+	"context"
+	"errors"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	mvm_types "github.com/amazechain/amc/internal/avm/types"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+const (
+	// maxConfirmedHistory is the number of emitted blocks a confirmed blocks
+	// subscription remembers to detect the reorgs rolling them back.
+	maxConfirmedHistory = 1024
+	// maxConfirmedBatch caps the blocks emitted per database read while a
+	// subscription catches up.
+	maxConfirmedBatch = 256
+)
+
+// Kinds of ConfirmedBlock notifications.
+const (
+	ConfirmedBlockType    = "block"
+	ConfirmedRollbackType = "rollback"
+)
+
+// ConfirmedBlocksCriteria selects when blocks are confirmed: once they are
+// Confirmations blocks deep, or once they are finalized if Finalized is set.
+// FromBlock is the first block to emit, by default the latest confirmed one,
+// so that a client resumes after the last block it processed.
+type ConfirmedBlocksCriteria struct {
+	Confirmations uint64          `json:"confirmations"`
+	Finalized     bool            `json:"finalized"`
+	FromBlock     *hexutil.Uint64 `json:"fromBlock"`
+}
+
+// ConfirmedBlock is a notification of a confirmed blocks subscription. Blocks
+// come in order, each the child of the previous one. A rollback means the
+// blocks in Removed, emitted from Number on, left the canonical chain: the
+// stream continues with the new blocks from Number, children of Hash.
+type ConfirmedBlock struct {
+	Type    string            `json:"type"`
+	Number  hexutil.Uint64    `json:"number"`
+	Hash    types.Hash        `json:"hash"`
+	Header  *mvm_types.Header `json:"header,omitempty"`
+	Removed []types.Hash      `json:"removed,omitempty"`
+}
+
+type confirmedRef struct {
+	number uint64
+	hash   types.Hash
+}
+
+// confirmedTracker follows the canonical chain up to the confirmed block and
+// works out what a subscription has to be sent.
+type confirmedTracker struct {
+	crit    ConfirmedBlocksCriteria
+	next    uint64         // number of the next block to emit
+	emitted []confirmedRef // last emitted blocks, in order
+}
+
+// target returns the latest confirmed block, false if there is none yet.
+func (t *confirmedTracker) target(tx kv.Tx) (uint64, bool) {
+	if t.crit.Finalized {
+		number := rawdb.ReadHeaderNumber(tx, rawdb.ReadFinalizedBlockHash(tx))
+		if number == nil {
+			return 0, false
+		}
+		return *number, true
+	}
+	head := rawdb.ReadHeaderNumber(tx, rawdb.ReadHeadBlockHash(tx))
+	if head == nil || *head < t.crit.Confirmations {
+		return 0, false
+	}
+	return *head - t.crit.Confirmations, true
+}
+
+// advance rolls back the emitted blocks that aren't canonical anymore and
+// emits the confirmed blocks that follow, at most maxConfirmedBatch of them.
+// It reports whether there are more to emit.
+func (t *confirmedTracker) advance(tx kv.Tx, emit func(*ConfirmedBlock)) (bool, error) {
+	// The emitted blocks link up, if the last one is canonical all are.
+	keep := len(t.emitted)
+	for keep > 0 {
+		ref := t.emitted[keep-1]
+		hash, err := rawdb.ReadCanonicalHash(tx, ref.number)
+		if err != nil {
+			return false, err
+		}
+		if hash == ref.hash {
+			break
+		}
+		keep--
+	}
+	if keep < len(t.emitted) {
+		removed := t.emitted[keep:]
+		rollback := &ConfirmedBlock{Type: ConfirmedRollbackType, Number: hexutil.Uint64(removed[0].number)}
+		if keep > 0 {
+			rollback.Hash = t.emitted[keep-1].hash
+		}
+		for _, ref := range removed {
+			rollback.Removed = append(rollback.Removed, ref.hash)
+		}
+		emit(rollback)
+		t.next, t.emitted = removed[0].number, t.emitted[:keep]
+	}
+
+	target, ok := t.target(tx)
+	if !ok {
+		return false, nil
+	}
+	for n := 0; t.next <= target; n++ {
+		if n == maxConfirmedBatch {
+			return true, nil
+		}
+		hash, err := rawdb.ReadCanonicalHash(tx, t.next)
+		if err != nil {
+			return false, err
+		}
+		header := rawdb.ReadHeader(tx, hash, t.next)
+		if header == nil {
+			return false, nil
+		}
+		emit(&ConfirmedBlock{Type: ConfirmedBlockType, Number: hexutil.Uint64(t.next), Hash: hash, Header: mvm_types.FromAmcHeader(header)})
+		t.emitted = append(t.emitted, confirmedRef{number: t.next, hash: hash})
+		if len(t.emitted) > maxConfirmedHistory {
+			t.emitted = t.emitted[len(t.emitted)-maxConfirmedHistory:]
+		}
+		t.next++
+	}
+	return false, nil
+}
+
+// ConfirmedBlocks sends the blocks once they are confirmed, as set by the
+// criteria, in chain order. The subscription keeps track of the reorgs itself:
+// if blocks it sent leave the canonical chain, it sends a rollback and then
+// the blocks replacing them, so clients crediting deposits see every block
+// exactly once per version of the chain.
+func (filterApi *FilterAPI) ConfirmedBlocks(ctx context.Context, crit ConfirmedBlocksCriteria) (*jsonrpc.Subscription, error) {
+	notifier, supported := jsonrpc.NotifierFromContext(ctx)
+	if !supported {
+		return &jsonrpc.Subscription{}, jsonrpc.ErrNotificationsUnsupported
+	}
+	if crit.Finalized && crit.Confirmations > 0 {
+		return nil, errors.New("confirmations and finalized are exclusive")
+	}
+	db := filterApi.api.Database()
+	tracker := &confirmedTracker{crit: crit}
+	if crit.FromBlock != nil {
+		tracker.next = uint64(*crit.FromBlock)
+	} else if err := db.View(ctx, func(tx kv.Tx) error {
+		tracker.next, _ = tracker.target(tx)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		var (
+			heads     = make(chan block.IHeader)
+			finalized = make(chan block.IHeader)
+			headsSub  = filterApi.events.SubscribeNewHeads(heads)
+			finalSub  = filterApi.events.SubscribeFinalizedHeads(finalized)
+			emit      = func(b *ConfirmedBlock) { notifier.Notify(rpcSub.ID, b) }
+		)
+		defer headsSub.Unsubscribe()
+		defer finalSub.Unsubscribe()
+
+		for {
+			var more bool
+			if err := db.View(context.Background(), func(tx kv.Tx) (err error) {
+				more, err = tracker.advance(tx, emit)
+				return err
+			}); err != nil {
+				log.Warn("Confirmed blocks subscription failed", "id", rpcSub.ID, "err", err)
+				return
+			}
+			// Keep the events flowing while catching up, the next round
+			// looks at the latest chain anyway.
+			if more {
+				select {
+				case <-heads:
+				case <-finalized:
+				case <-rpcSub.Err():
+					return
+				case <-notifier.Closed():
+					return
+				default:
+				}
+				continue
+			}
+			select {
+			case <-heads:
+			case <-finalized:
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
@@ -7,10 +7,12 @@ import (
 	"github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/types"
 	"github.com/amazechain/amc/internal"
+	"github.com/amazechain/amc/internal/bloombits"
 	"github.com/amazechain/amc/internal/consensus"
 	vm2 "github.com/amazechain/amc/internal/vm"
 	"github.com/amazechain/amc/internal/vm/evmtypes"
 	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/amazechain/amc/turbo/rpchelper"
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"math/big"
@@ -21,7 +23,9 @@ type Api interface {
 	Database() kv.RwDB
 	Engine() consensus.Engine
 	BlockChain() common.IBlockChain
-	GetEvm(ctx context.Context, msg internal.Message, ibs evmtypes.IntraBlockState, header block.IHeader, vmConfig *vm2.Config) (*vm2.EVM, func() error, error)
+	GetEvm(ctx context.Context, msg internal.Message, ibs evmtypes.IntraBlockState, blockCtx evmtypes.BlockContext, vmConfig *vm2.Config) (*vm2.EVM, func() error, error)
+	BloomStatus() (uint64, uint64)
+	BloomBits(bit uint, section uint64) ([]byte, error)
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -35,7 +39,7 @@ type Filter struct {
 	block      types.Hash // Block hash if filtering a single block
 	begin, end int64      // Range interval if filtering multiple blocks
 
-	//matcher *bloombits.Matcher
+	matcher *bloombits.Matcher
 }
 
 // NewRangeFilter creates a new filter which uses a bloom filter on blocks to
@@ -59,12 +63,12 @@ func NewRangeFilter(api Api, begin, end int64, addresses []types.Address, topics
 		}
 		filters = append(filters, filter)
 	}
-	//size, _ := api.BloomStatus()
+	size, _ := api.BloomStatus()
 
 	// Create a generic filter and convert it into a range filter
 	filter := newFilter(api, addresses, topics)
 
-	//filter.matcher = bloombits.NewMatcher(size, filters)
+	filter.matcher = bloombits.NewMatcher(size, filters)
 	filter.begin = begin
 	filter.end = end
 
@@ -119,34 +123,41 @@ func (f *Filter) Logs(ctx context.Context) ([]*block.Log, error) {
 	}
 	var (
 		head    = header.Number64().Uint64()
-		end     = uint64(f.end)
 		pending = f.end == jsonrpc.PendingBlockNumber.Int64()
 	)
-	if f.begin == jsonrpc.LatestBlockNumber.Int64() {
-		f.begin = int64(head)
+	begin, err := f.resolveNumber(ctx, f.begin, head)
+	if err != nil {
+		return nil, err
 	}
-	if f.end == jsonrpc.LatestBlockNumber.Int64() || f.end == jsonrpc.PendingBlockNumber.Int64() {
-		end = head
+	end, err := f.resolveNumber(ctx, f.end, head)
+	if err != nil {
+		return nil, err
 	}
+	f.begin = int64(begin)
+	if begin > end {
+		return nil, errors.New("invalid block range")
+	}
+	var logs []*block.Log
 	// Gather all indexed logs, and finish with non indexed ones
-	var (
-		logs           []*block.Log
-		err            error
-		size, sections = uint64(4096), uint64(10)
-		//todo
-	)
-	if indexed := sections * size; indexed > uint64(f.begin) {
-		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
-		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
-		}
-		if err != nil {
-			return logs, err
+	if f.matcher != nil && !f.matcher.Empty() {
+		size, sections := f.api.BloomStatus()
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			var err error
+			if indexed > end {
+				logs, err = f.indexedLogs(ctx, end)
+			} else {
+				logs, err = f.indexedLogs(ctx, indexed-1)
+			}
+			if err != nil {
+				return logs, err
+			}
 		}
 	}
 	rest, err := f.unindexedLogs(ctx, end)
 	logs = append(logs, rest...)
+	if err != nil {
+		return logs, err
+	}
 	if pending {
 		pendingLogs, err := f.pendingLogs()
 		if err != nil {
@@ -157,51 +168,52 @@ func (f *Filter) Logs(ctx context.Context) ([]*block.Log, error) {
 	return logs, err
 }
 
+// resolveNumber turns a range limit into a block number. The safe and
+// finalized tags resolve to the checkpoints tracked by the consensus engine,
+// latest and pending to the current head.
+func (f *Filter) resolveNumber(ctx context.Context, number int64, head uint64) (uint64, error) {
+	if number >= 0 {
+		return uint64(number), nil
+	}
+	if number != jsonrpc.SafeBlockNumber.Int64() && number != jsonrpc.FinalizedBlockNumber.Int64() {
+		return head, nil
+	}
+	var resolved *uint256.Int
+	err := f.db.View(ctx, func(tx kv.Tx) (err error) {
+		if number == jsonrpc.SafeBlockNumber.Int64() {
+			resolved, err = rpchelper.GetSafeBlockNumber(tx)
+		} else {
+			resolved, err = rpchelper.GetFinalizedBlockNumber(tx)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resolved.Uint64(), nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
-// bits indexed available locally or via the network.
+// bits indexed by the chain, reading only the blocks whose blooms may match.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*block.Log, error) {
-	// Create a matcher session and request servicing from the backend
-	matches := make(chan uint64, 64)
-
-	//session, err := f.matcher.Start(ctx, uint64(f.begin), end, matches)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//defer session.Close()
-
-	//f.api.ServiceFilter(ctx, session)
-
-	// Iterate over the matches until exhausted or context closed
+	numbers, err := f.matcher.Match(ctx, uint64(f.begin), end, f.api.BloomBits)
+	if err != nil {
+		return nil, err
+	}
 	var logs []*block.Log
-
-	for {
-		select {
-		case number, ok := <-matches:
-			// Abort if all matches have been fulfilled
-			if !ok {
-				//err := session.Error()
-				//if err == nil {
-				//	f.begin = int64(end) + 1
-				//}
-				return logs, nil
-			}
-			f.begin = int64(number) + 1
-
-			// Retrieve the suggested block and pull any truly matching logs
-			header := f.api.BlockChain().GetHeaderByNumber(uint256.NewInt(number))
-			if header == nil {
-				return logs, nil
-			}
-			found, err := f.checkMatches(ctx, header)
-			if err != nil {
-				return logs, err
-			}
-			logs = append(logs, found...)
-
-		case <-ctx.Done():
-			return logs, ctx.Err()
+	for _, number := range numbers {
+		header := f.api.BlockChain().GetHeaderByNumber(uint256.NewInt(number))
+		if header == nil {
+			return logs, nil
+		}
+		found, err := f.blockLogs(ctx, header)
+		if err != nil {
+			return logs, err
 		}
+		logs = append(logs, found...)
 	}
+	f.begin = int64(end) + 1
+	return logs, nil
 }
 
 // unindexedLogs returns the logs matching the filter criteria based on raw block
@@ -210,6 +222,11 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*block.Log, e
 	var logs []*block.Log
 
 	for ; f.begin <= int64(end); f.begin++ {
+		select {
+		case <-ctx.Done():
+			return logs, ctx.Err()
+		default:
+		}
 		header := f.api.BlockChain().GetHeaderByNumber(uint256.NewInt(uint64(f.begin)))
 		if header == nil {
 			return logs, nil
@@ -225,9 +242,7 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*block.Log, e
 
 // blockLogs returns the logs matching the filter criteria within a single block.
 func (f *Filter) blockLogs(ctx context.Context, header block.IHeader) (logs []*block.Log, err error) {
-	//todo header.Bloom
-	bloom, _ := types.NewBloom(100)
-	if bloomFilter(bloom, f.addresses, f.topics) {
+	if bloomFilter(header.(*block.Header).Bloom, f.addresses, f.topics) {
 		found, err := f.checkMatches(ctx, header)
 		if err != nil {
 			return logs, err
@@ -328,11 +343,11 @@ Logs:
 	return ret
 }
 
-func bloomFilter(bloom *types.Bloom, addresses []types.Address, topics [][]types.Hash) bool {
+func bloomFilter(bloom block.Bloom, addresses []types.Address, topics [][]types.Hash) bool {
 	if len(addresses) > 0 {
 		var included bool
 		for _, addr := range addresses {
-			if bloom.Contain(addr.Bytes()) {
+			if bloom.Test(addr.Bytes()) {
 				included = true
 				break
 			}
@@ -345,7 +360,7 @@ func bloomFilter(bloom *types.Bloom, addresses []types.Address, topics [][]types
 	for _, sub := range topics {
 		included := len(sub) == 0 // empty rule set == wildcard
 		for _, topic := range sub {
-			if bloom.Contain(topic.Bytes()) {
+			if bloom.Test(topic.Bytes()) {
 				included = true
 				break
 			}
@@ -33,6 +33,13 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// SafeHeadsSubscription queries headers of blocks becoming safe
+	SafeHeadsSubscription
+	// FinalizedHeadsSubscription queries headers of blocks becoming finalized
+	FinalizedHeadsSubscription
+	// DroppedTransactionsSubscription queries transactions dropped or
+	// replaced in the transaction pool
+	DroppedTransactionsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -57,6 +64,7 @@ type subscription struct {
 	logs      chan []*block.Log
 	hashes    chan []types.Hash
 	headers   chan block.IHeader
+	dropped   chan []common.DroppedTx
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -74,6 +82,9 @@ type EventSystem struct {
 	rmLogsSub      event.Subscription // Subscription for removed log event
 	pendingLogsSub event.Subscription // Subscription for pending log event
 	chainSub       event.Subscription // Subscription for new chain event
+	safeSub        event.Subscription // Subscription for safe head event
+	finalizedSub   event.Subscription // Subscription for finalized head event
+	droppedSub     event.Subscription // Subscription for dropped transactions event
 
 	// Channels
 	install       chan *subscription              // install filter for event notification
@@ -82,7 +93,10 @@ type EventSystem struct {
 	logsCh        chan common.NewLogsEvent        // Channel to receive new log event
 	pendingLogsCh chan common.NewPendingLogsEvent // Channel to receive new log event
 	rmLogsCh      chan common.RemovedLogsEvent    // Channel to receive removed log event
-	chainCh       chan common.ChainHighestBlock   // Channel to receive new chain event
+	chainCh       chan common.ChainEvent          // Channel to receive new chain event
+	safeCh        chan common.SafeHeadEvent       // Channel to receive safe head event
+	finalizedCh   chan common.FinalizedHeadEvent  // Channel to receive finalized head event
+	droppedCh     chan common.DroppedTxsEvent     // Channel to receive dropped transactions event
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -102,7 +116,10 @@ func NewEventSystem(api Api) *EventSystem {
 		logsCh:        make(chan common.NewLogsEvent),
 		rmLogsCh:      make(chan common.RemovedLogsEvent),
 		pendingLogsCh: make(chan common.NewPendingLogsEvent),
-		chainCh:       make(chan common.ChainHighestBlock),
+		chainCh:       make(chan common.ChainEvent),
+		safeCh:        make(chan common.SafeHeadEvent),
+		finalizedCh:   make(chan common.FinalizedHeadEvent),
+		droppedCh:     make(chan common.DroppedTxsEvent),
 	}
 
 	// Subscribe events
@@ -111,9 +128,12 @@ func NewEventSystem(api Api) *EventSystem {
 	m.rmLogsSub = event.GlobalEvent.Subscribe(m.rmLogsCh)
 	m.chainSub = event.GlobalEvent.Subscribe(m.chainCh)
 	m.pendingLogsSub = event.GlobalEvent.Subscribe(m.pendingLogsCh)
+	m.safeSub = event.GlobalEvent.Subscribe(m.safeCh)
+	m.finalizedSub = event.GlobalEvent.Subscribe(m.finalizedCh)
+	m.droppedSub = event.GlobalEvent.Subscribe(m.droppedCh)
 
 	// Make sure none of the subscriptions are empty
-	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.pendingLogsSub == nil {
+	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.pendingLogsSub == nil || m.safeSub == nil || m.finalizedSub == nil || m.droppedSub == nil {
 		log.Error("Subscribe for event system failed")
 	}
 
@@ -149,6 +169,7 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.dropped:
 			}
 		}
 
@@ -272,6 +293,32 @@ func (es *EventSystem) SubscribeNewHeads(headers chan block.IHeader) *Subscripti
 	return es.subscribe(sub)
 }
 
+// SubscribeSafeHeads creates a subscription that writes the header of every
+// block becoming the safe block.
+func (es *EventSystem) SubscribeSafeHeads(headers chan block.IHeader) *Subscription {
+	return es.subscribeHeaders(SafeHeadsSubscription, headers)
+}
+
+// SubscribeFinalizedHeads creates a subscription that writes the header of
+// every block becoming the finalized block.
+func (es *EventSystem) SubscribeFinalizedHeads(headers chan block.IHeader) *Subscription {
+	return es.subscribeHeaders(FinalizedHeadsSubscription, headers)
+}
+
+func (es *EventSystem) subscribeHeaders(typ Type, headers chan block.IHeader) *Subscription {
+	sub := &subscription{
+		id:        jsonrpc.NewID(),
+		typ:       typ,
+		created:   time.Now(),
+		logs:      make(chan []*block.Log),
+		hashes:    make(chan []types.Hash),
+		headers:   headers,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 // SubscribePendingTxs creates a subscription that writes transaction hashes for
 // transactions that enter the transaction pool.
 func (es *EventSystem) SubscribePendingTxs(hashes chan []types.Hash) *Subscription {
@@ -288,6 +335,23 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []types.Hash) *Subscripti
 	return es.subscribe(sub)
 }
 
+// SubscribeDroppedTxs creates a subscription that writes the transactions
+// dropped from the transaction pool, along with the reason.
+func (es *EventSystem) SubscribeDroppedTxs(dropped chan []common.DroppedTx) *Subscription {
+	sub := &subscription{
+		id:        jsonrpc.NewID(),
+		typ:       DroppedTransactionsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*block.Log),
+		hashes:    make(chan []types.Hash),
+		headers:   make(chan block.IHeader),
+		dropped:   dropped,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[jsonrpc.ID]*subscription
 
 func (es *EventSystem) handleLogs(filters filterIndex, ev common.NewLogsEvent) {
@@ -315,6 +379,9 @@ func (es *EventSystem) handlePendingLogs(filters filterIndex, ev common.NewPendi
 }
 
 func (es *EventSystem) handleRemovedLogs(filters filterIndex, ev common.RemovedLogsEvent) {
+	if len(ev.Logs) == 0 {
+		return
+	}
 	for _, f := range filters[LogsSubscription] {
 		matchedLogs := filterLogs(ev.Logs, f.logsCrit.FromBlock, f.logsCrit.ToBlock, f.logsCrit.Addresses, f.logsCrit.Topics)
 		if len(matchedLogs) > 0 {
@@ -334,7 +401,13 @@ func (es *EventSystem) handleTxsEvent(filters filterIndex, ev common.NewTxsEvent
 	}
 }
 
-func (es *EventSystem) handleChainEvent(filters filterIndex, ev common.ChainHighestBlock) {
+func (es *EventSystem) handleDroppedTxs(filters filterIndex, ev common.DroppedTxsEvent) {
+	for _, f := range filters[DroppedTransactionsSubscription] {
+		f.dropped <- ev.Txs
+	}
+}
+
+func (es *EventSystem) handleChainEvent(filters filterIndex, ev common.ChainEvent) {
 	for _, f := range filters[BlocksSubscription] {
 		f.headers <- ev.Block.Header()
 	}
@@ -349,6 +422,12 @@ func (es *EventSystem) handleChainEvent(filters filterIndex, ev common.ChainHigh
 	}
 }
 
+func (es *EventSystem) handleCheckpoint(filters filterIndex, typ Type, header block.IHeader) {
+	for _, f := range filters[typ] {
+		f.headers <- header
+	}
+}
+
 func (es *EventSystem) lightFilterNewHead(newHeader block.IHeader, callBack func(block.IHeader, bool)) {
 	oldh := es.lastHead
 	es.lastHead = newHeader
@@ -394,9 +473,7 @@ func (es *EventSystem) lightFilterNewHead(newHeader block.IHeader, callBack func
 
 // filter logs of a single header in light client mode
 func (es *EventSystem) lightFilterLogs(header block.IHeader, addresses []types.Address, topics [][]types.Hash, remove bool) []*block.Log {
-	//todo header.Bloom
-	bloom, _ := types.NewBloom(100)
-	if bloomFilter(bloom, addresses, topics) {
+	if bloomFilter(header.(*block.Header).Bloom, addresses, topics) {
 		// Get the logs of the block
 		_, cancel := context.WithTimeout(context.Background(), time.Second*5)
 		defer cancel()
@@ -443,6 +520,9 @@ func (es *EventSystem) eventLoop() {
 		es.rmLogsSub.Unsubscribe()
 		es.pendingLogsSub.Unsubscribe()
 		es.chainSub.Unsubscribe()
+		es.safeSub.Unsubscribe()
+		es.finalizedSub.Unsubscribe()
+		es.droppedSub.Unsubscribe()
 	}()
 
 	index := make(filterIndex)
@@ -461,9 +541,13 @@ func (es *EventSystem) eventLoop() {
 		case ev := <-es.pendingLogsCh:
 			es.handlePendingLogs(index, ev)
 		case ev := <-es.chainCh:
-			if ev.Inserted {
-				es.handleChainEvent(index, ev)
-			}
+			es.handleChainEvent(index, ev)
+		case ev := <-es.safeCh:
+			es.handleCheckpoint(index, SafeHeadsSubscription, ev.Header)
+		case ev := <-es.finalizedCh:
+			es.handleCheckpoint(index, FinalizedHeadsSubscription, ev.Header)
+		case ev := <-es.droppedCh:
+			es.handleDroppedTxs(index, ev)
 
 		case f := <-es.install:
 			if f.typ == MinedAndPendingLogsSubscription {
@@ -494,6 +578,14 @@ func (es *EventSystem) eventLoop() {
 			return
 		case <-es.chainSub.Err():
 			return
+		case <-es.pendingLogsSub.Err():
+			return
+		case <-es.safeSub.Err():
+			return
+		case <-es.finalizedSub.Err():
+			return
+		case <-es.droppedSub.Err():
+			return
 		}
 	}
 }
@@ -96,18 +96,24 @@ func NewOracle(backend common2.IBlockChain, miner common2.IMiner, chainConfig *p
 
 	cache, _ := lru.New(2048)
 
+	// Drop the cached fee history whenever the head does not extend the
+	// previous one, the cached blocks might have been reorged out.
 	highestBlockCh := make(chan common2.ChainHighestBlock)
-	defer close(highestBlockCh)
 	highestSub := event.GlobalEvent.Subscribe(highestBlockCh)
-	defer highestSub.Unsubscribe()
 
 	go func() {
+		defer highestSub.Unsubscribe()
 		var lastHead types2.Hash
-		for ev := range highestBlockCh {
-			if ev.Block.ParentHash() != lastHead {
-				cache.Purge()
+		for {
+			select {
+			case ev := <-highestBlockCh:
+				if ev.Block.ParentHash() != lastHead {
+					cache.Purge()
+				}
+				lastHead = ev.Block.Hash()
+			case <-highestSub.Err():
+				return
 			}
-			lastHead = ev.Block.Hash()
 		}
 	}()
 
@@ -0,0 +1,154 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/accounts/keystore"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/hexutil"
+	mvm_common "github.com/amazechain/amc/internal/avm/common"
+	mvm_types "github.com/amazechain/amc/internal/avm/types"
+)
+
+func newTestPersonalAPI(t *testing.T, extRPC, insecureUnlock bool) (*PersonalAccountAPI, *keystore.KeyStore) {
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	am := accounts.NewManager(&accounts.Config{InsecureUnlockAllowed: insecureUnlock}, ks)
+	t.Cleanup(func() { am.Close() })
+	return NewPersonalAccountAPI(&API{accountManager: am, extRPCEnabled: extRPC}, new(AddrLocker)), ks
+}
+
+// newTestAccount creates an account and waits for the account manager to
+// pick up its wallet, which it does in the background.
+func newTestAccount(t *testing.T, api *PersonalAccountAPI, password string) mvm_common.Address {
+	addr, err := api.NewAccount(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := api.am.Find(accounts.Account{Address: *mvm_types.ToAmcAddress(&addr)}); err == nil {
+			return addr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("account %v not picked up by the account manager", addr)
+	return addr
+}
+
+func TestPersonalUnlockAccount(t *testing.T) {
+	ctx := context.Background()
+	api, ks := newTestPersonalAPI(t, false, false)
+
+	addr := newTestAccount(t, api, "secret")
+	if list := api.ListAccounts(); len(list) != 1 || list[0] != addr {
+		t.Fatalf("accounts %v, want [%v]", list, addr)
+	}
+	account := accounts.Account{Address: *mvm_types.ToAmcAddress(&addr)}
+	hash := crypto.Keccak256([]byte("unlock"))
+	if _, err := ks.SignHash(account, hash); err != keystore.ErrLocked {
+		t.Fatalf("signing with a new account: %v, want %v", err, keystore.ErrLocked)
+	}
+
+	if ok, err := api.UnlockAccount(ctx, addr, "wrong", nil); ok || err == nil {
+		t.Fatalf("unlocked with the wrong password: %v, %v", ok, err)
+	}
+	tooLong := uint64(1 << 62)
+	if ok, err := api.UnlockAccount(ctx, addr, "secret", &tooLong); ok || err == nil {
+		t.Fatalf("unlocked for %d seconds: %v, %v", tooLong, ok, err)
+	}
+	if ok, err := api.UnlockAccount(ctx, addr, "secret", nil); !ok || err != nil {
+		t.Fatalf("failed to unlock: %v", err)
+	}
+	if _, err := ks.SignHash(account, hash); err != nil {
+		t.Fatalf("signing with an unlocked account: %v", err)
+	}
+	if !api.LockAccount(addr) {
+		t.Fatal("failed to lock")
+	}
+	if _, err := ks.SignHash(account, hash); err != keystore.ErrLocked {
+		t.Fatalf("signing with a locked account: %v, want %v", err, keystore.ErrLocked)
+	}
+}
+
+func TestPersonalUnlockOverExternalRPC(t *testing.T) {
+	tests := []struct {
+		extRPC, insecureUnlock bool
+		unlocked               bool
+	}{
+		{extRPC: false, insecureUnlock: false, unlocked: true},
+		{extRPC: true, insecureUnlock: false, unlocked: false},
+		{extRPC: true, insecureUnlock: true, unlocked: true},
+	}
+	for i, tt := range tests {
+		api, _ := newTestPersonalAPI(t, tt.extRPC, tt.insecureUnlock)
+		addr := newTestAccount(t, api, "secret")
+		ok, err := api.UnlockAccount(context.Background(), addr, "secret", nil)
+		if ok != tt.unlocked || (err == nil) != tt.unlocked {
+			t.Errorf("test %d: unlocked %v (%v), want %v", i, ok, err, tt.unlocked)
+		}
+	}
+}
+
+func TestPersonalSignAndRecover(t *testing.T) {
+	ctx := context.Background()
+	api, _ := newTestPersonalAPI(t, false, false)
+
+	addr := newTestAccount(t, api, "secret")
+	data := hexutil.Bytes("hello amc")
+
+	// Signing needs the password, not an unlocked account.
+	if _, err := api.Sign(ctx, data, addr, "wrong"); err == nil {
+		t.Fatal("signed with the wrong password")
+	}
+	sig, err := api.Sign(ctx, data, addr, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sig) != crypto.SignatureLength {
+		t.Fatalf("signature of %d bytes, want %d", len(sig), crypto.SignatureLength)
+	}
+	if v := sig[crypto.RecoveryIDOffset]; v != 27 && v != 28 {
+		t.Fatalf("signature V %d, want 27 or 28", v)
+	}
+	signer, err := api.EcRecover(ctx, data, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer != addr {
+		t.Errorf("recovered %v, want %v", signer, addr)
+	}
+	if sig[crypto.RecoveryIDOffset] < 27 {
+		t.Error("recovering modified the signature")
+	}
+
+	// Other data recovers to another signer, malformed signatures fail.
+	if other, err := api.EcRecover(ctx, hexutil.Bytes("other"), sig); err == nil && other == addr {
+		t.Error("recovered the signer from other data")
+	}
+	if _, err := api.EcRecover(ctx, data, sig[:len(sig)-1]); err == nil {
+		t.Error("recovered from a short signature")
+	}
+	bad := append(hexutil.Bytes{}, sig...)
+	bad[crypto.RecoveryIDOffset] -= 27
+	if _, err := api.EcRecover(ctx, data, bad); err == nil {
+		t.Error("recovered from a signature with V of 0 or 1")
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal"
+	mvm_types "github.com/amazechain/amc/internal/avm/types"
+	vm2 "github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks a single
+	// eth_simulateV1 request may simulate.
+	maxSimulateBlocks = 256
+
+	// simTimestampIncrement is the default time between simulated blocks.
+	simTimestampIncrement = 12
+
+	// simVMErrorCode is the error code of calls failing inside the EVM for
+	// other reasons than a revert.
+	simVMErrorCode = -32015
+)
+
+// simBlock is a batch of calls executed on top of the previous simulated block.
+type simBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// simOpts are the inputs of eth_simulateV1.
+type simOpts struct {
+	BlockStateCalls []simBlock `json:"blockStateCalls"`
+	Validation      bool       `json:"validation"`
+}
+
+// simCallError is the error of a call that failed during execution.
+type simCallError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Data    string `json:"data,omitempty"`
+}
+
+// simCallResult is the outcome of a single simulated call.
+type simCallResult struct {
+	ReturnValue hexutil.Bytes    `json:"returnData"`
+	Logs        []*mvm_types.Log `json:"logs"`
+	GasUsed     hexutil.Uint64   `json:"gasUsed"`
+	Status      hexutil.Uint64   `json:"status"`
+	Error       *simCallError    `json:"error,omitempty"`
+}
+
+// simBlockResult describes a simulated block and the results of its calls.
+type simBlockResult struct {
+	Number        hexutil.Uint64  `json:"number"`
+	Hash          types.Hash      `json:"hash"`
+	ParentHash    types.Hash      `json:"parentHash"`
+	Timestamp     hexutil.Uint64  `json:"timestamp"`
+	GasLimit      hexutil.Uint64  `json:"gasLimit"`
+	GasUsed       hexutil.Uint64  `json:"gasUsed"`
+	FeeRecipient  types.Address   `json:"miner"`
+	BaseFeePerGas *hexutil.Big    `json:"baseFeePerGas,omitempty"`
+	Calls         []simCallResult `json:"calls"`
+}
+
+// simulator executes simulated blocks sequentially on one ephemeral state.
+type simulator struct {
+	api        *API
+	state      *state.IntraBlockState
+	base       *block.Header
+	coinbase   types.Address
+	validate   bool
+	gasBudget  uint64
+	hashes     map[uint64]types.Hash // hashes of the simulated blocks
+	baseHashFn func(n uint64) types.Hash
+}
+
+// SimulateV1 executes a series of blocks, each holding a list of calls, on top
+// of the given block. Every block may override header fields and state before
+// its calls run, and every call observes the effects of all calls before it.
+// Nothing is persisted.
+//
+// When validation is enabled, calls are checked like real transactions: nonces
+// must match, the sender has to pay for gas and the base fee is enforced.
+func (s *BlockChainAPI) SimulateV1(ctx context.Context, opts simOpts, blockNrOrHash *jsonrpc.BlockNumberOrHash) ([]*simBlockResult, error) {
+	if len(opts.BlockStateCalls) == 0 {
+		return nil, errors.New("empty input")
+	}
+	if len(opts.BlockStateCalls) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks, at most %d allowed", maxSimulateBlocks)
+	}
+	bNrOrHash := jsonrpc.BlockNumberOrHashWithNumber(jsonrpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	header, err := headerByNumberOrHash(s.api, bNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ibs, err := s.api.State(tx, bNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if timeout := s.api.RPCEVMTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	base := header.(*block.Header)
+	coinbase, _ := s.api.engine.Author(base)
+	gasBudget := s.api.RPCGasCap()
+	if gasBudget == 0 {
+		gasBudget = math.MaxUint64
+	}
+	sim := &simulator{
+		api:       s.api,
+		state:     ibs.(*state.IntraBlockState),
+		base:      base,
+		coinbase:  coinbase,
+		validate:  opts.Validation,
+		gasBudget: gasBudget,
+		hashes:    make(map[uint64]types.Hash),
+		baseHashFn: internal.GetHashFn(base, func(hash types.Hash, number uint64) *block.Header {
+			h := s.api.BlockChain().GetHeader(hash, uint256.NewInt(number))
+			if h == nil {
+				return nil
+			}
+			return h.(*block.Header)
+		}),
+	}
+	return sim.execute(ctx, opts.BlockStateCalls)
+}
+
+func (sim *simulator) execute(ctx context.Context, blocks []simBlock) ([]*simBlockResult, error) {
+	var (
+		parent  = sim.base
+		results = make([]*simBlockResult, 0, len(blocks))
+	)
+	for i := range blocks {
+		header, err := sim.makeHeader(parent, blocks[i].BlockOverrides)
+		if err != nil {
+			return nil, err
+		}
+		if err := blocks[i].StateOverrides.Apply(sim.state); err != nil {
+			return nil, err
+		}
+		result, err := sim.processBlock(ctx, header, &blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+		parent = header
+	}
+	return results, nil
+}
+
+// makeHeader assembles the header of the next simulated block, defaulting
+// every field that is not overridden to follow the parent.
+func (sim *simulator) makeHeader(parent *block.Header, overrides *BlockOverrides) (*block.Header, error) {
+	header := &block.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   sim.coinbase,
+		Difficulty: new(uint256.Int).Set(sim.base.Difficulty),
+		Number:     new(uint256.Int).AddUint64(parent.Number, 1),
+		GasLimit:   sim.base.GasLimit,
+		Time:       parent.Time + simTimestampIncrement,
+		MixDigest:  sim.base.MixDigest,
+	}
+	if sim.validate && sim.base.BaseFee != nil {
+		header.BaseFee = new(uint256.Int).Set(sim.base.BaseFee)
+	}
+	if overrides == nil {
+		return header, nil
+	}
+	if overrides.Number != nil {
+		number, overflow := uint256.FromBig(overrides.Number.ToInt())
+		if overflow || number.Cmp(parent.Number) <= 0 {
+			return nil, fmt.Errorf("block number %v must be greater than its parent %v", overrides.Number.ToInt(), parent.Number)
+		}
+		header.Number = number
+	}
+	if overrides.Time != nil {
+		if uint64(*overrides.Time) <= parent.Time {
+			return nil, fmt.Errorf("block timestamp %d must be greater than its parent %d", *overrides.Time, parent.Time)
+		}
+		header.Time = uint64(*overrides.Time)
+	}
+	if overrides.GasLimit != nil {
+		header.GasLimit = uint64(*overrides.GasLimit)
+	}
+	if overrides.Coinbase != nil {
+		header.Coinbase = *overrides.Coinbase
+	}
+	if overrides.Difficulty != nil {
+		header.Difficulty, _ = uint256.FromBig(overrides.Difficulty.ToInt())
+	}
+	if overrides.Random != nil {
+		header.MixDigest = *overrides.Random
+	}
+	if overrides.BaseFee != nil {
+		header.BaseFee, _ = uint256.FromBig(overrides.BaseFee.ToInt())
+	}
+	return header, nil
+}
+
+// getHash resolves block hashes for the BLOCKHASH opcode, covering both the
+// canonical chain and the blocks simulated so far.
+func (sim *simulator) getHash(n uint64) types.Hash {
+	if hash, ok := sim.hashes[n]; ok {
+		return hash
+	}
+	if n < sim.base.Number.Uint64() {
+		return sim.baseHashFn(n)
+	}
+	if n == sim.base.Number.Uint64() {
+		return sim.base.Hash()
+	}
+	return types.Hash{}
+}
+
+func (sim *simulator) processBlock(ctx context.Context, header *block.Header, blk *simBlock) (*simBlockResult, error) {
+	blockCtx := internal.NewEVMBlockContext(header, sim.getHash, sim.api.engine, &header.Coinbase)
+	if header.BaseFee == nil {
+		blockCtx.BaseFee = new(uint256.Int)
+	}
+	evm := vm2.NewEVM(blockCtx, internal.NewEVMTxContext(transaction.Message{}), sim.state, sim.api.GetChainConfig(), vm2.Config{NoBaseFee: !sim.validate})
+
+	// Abort the execution as soon as the request is cancelled or times out.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			evm.Cancel()
+		case <-done:
+		}
+	}()
+
+	var (
+		gp       = new(common.GasPool).AddGas(header.GasLimit)
+		gasUsed  uint64
+		calls    = make([]simCallResult, 0, len(blk.Calls))
+		callLogs = make([][]*block.Log, 0, len(blk.Calls))
+	)
+	for i := range blk.Calls {
+		args := blk.Calls[i]
+		// Default the gas of each call to what is left in the block, also
+		// bounded by the remaining global gas budget of the request.
+		if args.Gas == nil {
+			gas := hexutil.Uint64(gp.Gas())
+			if sim.gasBudget < gp.Gas() {
+				gas = hexutil.Uint64(sim.gasBudget)
+			}
+			args.Gas = &gas
+		}
+		if uint64(*args.Gas) > gp.Gas() {
+			return nil, fmt.Errorf("block %v call %d: gas limit %d exceeds remaining block gas %d", header.Number, i, *args.Gas, gp.Gas())
+		}
+		if uint64(*args.Gas) > sim.gasBudget {
+			return nil, fmt.Errorf("block %v call %d: gas limit %d exceeds remaining gas cap %d", header.Number, i, *args.Gas, sim.gasBudget)
+		}
+		msg, err := args.ToMessage(0, blockCtx.BaseFee.ToBig())
+		if err != nil {
+			return nil, err
+		}
+		if sim.validate {
+			nonce := sim.state.GetNonce(msg.From())
+			if args.Nonce != nil {
+				nonce = uint64(*args.Nonce)
+			}
+			msg = transaction.NewMessage(msg.From(), msg.To(), nonce, msg.Value(), msg.Gas(), msg.GasPrice(), msg.FeeCap(), msg.Tip(), msg.Data(), msg.AccessList(), true, false)
+		}
+		txHash := simTxHash(header.Number.Uint64(), i)
+		sim.state.Prepare(txHash, types.Hash{}, i)
+		evm.Reset(internal.NewEVMTxContext(msg), sim.state)
+
+		result, err := internal.ApplyMessage(evm, msg, gp, true, false)
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", sim.api.RPCEVMTimeout())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("block %v call %d: %w", header.Number, i, err)
+		}
+		if err := sim.state.FinalizeTx(evm.ChainRules(), state.NewNoopWriter()); err != nil {
+			return nil, err
+		}
+		gasUsed += result.UsedGas
+		sim.gasBudget -= result.UsedGas
+
+		call := simCallResult{
+			ReturnValue: result.Return(),
+			GasUsed:     hexutil.Uint64(result.UsedGas),
+			Status:      hexutil.Uint64(block.ReceiptStatusSuccessful),
+		}
+		if result.Failed() {
+			call.Status = hexutil.Uint64(block.ReceiptStatusFailed)
+			if len(result.Revert()) > 0 {
+				revert := newRevertError(result)
+				call.ReturnValue = result.Revert()
+				call.Error = &simCallError{Message: revert.Error(), Code: revert.ErrorCode(), Data: revert.reason}
+			} else {
+				call.Error = &simCallError{Message: result.Err.Error(), Code: simVMErrorCode}
+			}
+		}
+		calls = append(calls, call)
+		callLogs = append(callLogs, sim.state.GetLogs(txHash))
+	}
+	header.GasUsed = gasUsed
+	hash := header.Hash()
+	sim.hashes[header.Number.Uint64()] = hash
+
+	// The block hash is only known once all calls ran, patch it into the logs
+	// together with the per block log index.
+	var index uint
+	for i, txLogs := range callLogs {
+		for _, l := range txLogs {
+			l.BlockHash = hash
+			l.BlockNumber = header.Number
+			l.Index = index
+			index++
+		}
+		calls[i].Logs = []*mvm_types.Log{}
+		if len(txLogs) > 0 {
+			calls[i].Logs = mvm_types.FromAmcLogs(txLogs)
+		}
+	}
+	res := &simBlockResult{
+		Number:       hexutil.Uint64(header.Number.Uint64()),
+		Hash:         hash,
+		ParentHash:   header.ParentHash,
+		Timestamp:    hexutil.Uint64(header.Time),
+		GasLimit:     hexutil.Uint64(header.GasLimit),
+		GasUsed:      hexutil.Uint64(header.GasUsed),
+		FeeRecipient: header.Coinbase,
+		Calls:        calls,
+	}
+	if header.BaseFee != nil {
+		res.BaseFeePerGas = (*hexutil.Big)(header.BaseFee.ToBig())
+	}
+	return res, nil
+}
+
+// simTxHash derives a unique placeholder hash for a simulated call, as the
+// calls are unsigned and have no transaction hash of their own.
+func simTxHash(number uint64, index int) types.Hash {
+	var enc [16]byte
+	binary.BigEndian.PutUint64(enc[:8], number)
+	binary.BigEndian.PutUint64(enc[8:], uint64(index))
+	return crypto.Keccak256Hash([]byte("simulate"), enc[:])
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/amazechain/amc/contracts/deposit"
 	"github.com/amazechain/amc/internal/metrics/prometheus"
 	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/tracing"
+	"github.com/amazechain/amc/internal/vm"
 	"github.com/holiman/uint256"
 	"google.golang.org/protobuf/proto"
 	"sort"
@@ -31,6 +33,7 @@ import (
 	"time"
 
 	"github.com/amazechain/amc/modules/state"
+	"github.com/amazechain/amc/modules/state/snapshot"
 	"github.com/amazechain/amc/params"
 	"github.com/ledgerwatch/erigon-lib/kv"
 
@@ -83,6 +86,9 @@ const (
 	headerCacheLimit = 1024
 	tdCacheLimit     = 1024
 	numberCacheLimit = 2048
+
+	// defaultReorgAlertDepth is the reorg depth reported when none is configured.
+	defaultReorgAlertDepth = 64
 )
 
 type BlockChain struct {
@@ -115,6 +121,7 @@ type BlockChain struct {
 
 	procInterrupt int32 // insert chain
 	futureBlocks  *lru.Cache[types.Hash, *block2.Block]
+	badBlocks     *lru.Cache[types.Hash, struct{}]
 	receiptCache  *lru.Cache[types.Hash, []*block2.Receipt]
 	blockCache    *lru.Cache[types.Hash, *block2.Block]
 
@@ -122,8 +129,39 @@ type BlockChain struct {
 	numberCache *lru.Cache[types.Hash, uint64]
 	tdCache     *lru.Cache[types.Hash, *uint256.Int]
 
-	forker    *ForkChoice
+	forker    ForkChooser
 	validator Validator
+
+	hooks []ChainHook
+
+	checkpoint atomic.Pointer[params.SyncCheckpoint]
+
+	snaps *snapshot.Tree
+
+	recordPreimages bool   // keep the keccak256 preimages of imported blocks
+	parallelExec    bool   // execute the transactions of imported blocks in parallel
+	reorgAlertDepth uint64 // dropped blocks from which a reorg is reported
+	profiler        *vm.Profiler
+	pause           importPause
+
+	loops sync.WaitGroup // background maintenance, waited for on Close
+}
+
+// ChainHook keeps data derived from the blocks, such as the validator
+// lifecycle, in step with the canonical chain. Its methods run inside the
+// database transaction that moves the chain, so that their effects are
+// persisted together with it.
+type ChainHook interface {
+	// WriteBlock is called for every block written with state, canonical or not.
+	WriteBlock(tx kv.RwTx, b block2.IBlock, receipts []*block2.Receipt) error
+	// Apply is called when a block written with state joins the canonical
+	// chain on top of its parent.
+	Apply(tx kv.RwTx, b block2.IBlock) error
+	// Revert is called when a block leaves the canonical chain, the head first.
+	Revert(tx kv.RwTx, b block2.IBlock) error
+	// Reset is called when the head moves to a block whose derived data came
+	// with its state: the snap sync pivot or the genesis.
+	Reset(tx kv.RwTx, b block2.IBlock) error
 }
 
 type insertStats struct {
@@ -154,6 +192,7 @@ func NewBlockChain(ctx context.Context, genesisBlock block2.IBlock, engine conse
 
 	blockCache, _ := lru.New[types.Hash, *block2.Block](blockCacheLimit)
 	futureBlocks, _ := lru.New[types.Hash, *block2.Block](maxFutureBlocks)
+	badBlocks, _ := lru.New[types.Hash, struct{}](maxBadBlocks)
 	receiptsCache, _ := lru.New[types.Hash, []*block2.Receipt](receiptsCacheLimit)
 	tdCache, _ := lru.New[types.Hash, *uint256.Int](tdCacheLimit)
 	numberCache, _ := lru.New[types.Hash, uint64](numberCacheLimit)
@@ -176,18 +215,26 @@ func NewBlockChain(ctx context.Context, genesisBlock block2.IBlock, engine conse
 		blockCache:    blockCache,
 		tdCache:       tdCache,
 		futureBlocks:  futureBlocks,
+		badBlocks:     badBlocks,
 		receiptCache:  receiptsCache,
 
 		numberCache: numberCache,
 		headerCache: headerCache,
+		snaps:       snapshot.New(current.Hash(), snapshot.DefaultCacheSize),
+
+		reorgAlertDepth: defaultReorgAlertDepth,
 	}
 
 	bc.currentBlock.Store(current)
 	headBlockGauge.Set(current.Number64().Uint64())
 	bc.forker = NewForkChoice(bc, nil)
+	if tracker, ok := engine.(consensus.FinalityTracker); ok {
+		bc.forker = NewFinalityForkChoice(bc, tracker, bc.forker)
+	}
 	//bc.process = avm.NewVMProcessor(ctx, bc, engine)
 	bc.process = NewStateProcessor(config, bc, engine)
 	bc.validator = NewBlockValidator(config, bc, engine)
+	bc.loadBadBlocks()
 
 	return bc, nil
 }
@@ -827,6 +874,9 @@ func (bc *BlockChain) InsertChain(chain []block2.IBlock) (int, error) {
 				prev.Hash().Bytes()[:4], i, block.Number64().String(), block.Hash().Bytes()[:4], block.ParentHash().Bytes()[:4])
 		}
 	}
+	if err := bc.checkPaused(); err != nil {
+		return 0, err
+	}
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
 	return bc.insertChain(chain)
@@ -837,6 +887,13 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 		return 0, nil
 	}
 
+	for i, block := range chain {
+		if bc.isBadBlock(block.Hash()) {
+			log.Debug("Refusing bad block", "number", block.Number64().Uint64(), "hash", block.Hash())
+			return i, ErrBannedHash
+		}
+	}
+
 	var (
 		stats     = insertStats{startTime: time.Now()}
 		lastCanon block2.IBlock
@@ -850,14 +907,7 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 	}()
 
 	// Start the parallel header verifier
-	headers := make([]block2.IHeader, len(chain))
-	seals := make([]bool, len(chain))
-
-	for i, block := range chain {
-		headers[i] = block.Header()
-		seals[i] = true
-	}
-	abort, results := bc.engine.VerifyHeaders(bc, headers, seals)
+	abort, results := bc.verifyHeaders(chain)
 	defer close(abort)
 
 	// Peek the error for the first block to decide the directing import logic
@@ -952,7 +1002,7 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 	//	batch.Rollback()
 	//}()
 
-	evmRecord := func(ctx context.Context, db kv.RwDB, blockNr uint64, f func(tx kv.Tx, ibs *state.IntraBlockState, reader state.StateReader, writer state.WriterWithChangeSets) (map[types.Address]*uint256.Int, error)) (*state.IntraBlockState, map[types.Address]*uint256.Int, error) {
+	evmRecord := func(ctx context.Context, db kv.RwDB, blockNr uint64, parent types.Hash, f func(tx kv.Tx, ibs *state.IntraBlockState, reader state.StateReader, writer state.WriterWithChangeSets) (map[types.Address]*uint256.Int, error)) (*state.IntraBlockState, map[types.Address]*uint256.Int, error) {
 		tx, err := db.BeginRo(ctx)
 		if nil != err {
 			return nil, nil, err
@@ -965,8 +1015,11 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 		//	return err
 		//}
 
-		stateReader := state.NewPlainStateReader(tx)
+		stateReader := state.NewPlainStateReader(bc.snaps.Reader(tx, parent))
 		ibs := state.New(stateReader)
+		if bc.recordPreimages {
+			ibs.BeginRecordPreimages()
+		}
 		//stateWriter := state.NewPlainStateWriter(tx, tx, block.Number64().Uint64())
 		stateWriter := state.NewNoopWriter()
 
@@ -1002,10 +1055,18 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 		//}
 		//ibs := state.New(stateReader)
 
+		// The block joins the trace started on its arrival, or starts one.
+		traceCtx, endTrace := tracing.BeginBlock(bc.ctx, block.Hash(), block.Number64().Uint64(), "insert")
+		_, verifySpan := tracing.StartAt(traceCtx, "verify", it.verifyStart)
+		verifySpan.EndAt(it.verifyEnd)
+
 		var receipts block2.Receipts
 		var logs []*block2.Log
 		var usedGas uint64
-		ibs, nopay, err := evmRecord(bc.ctx, bc.ChainDB, block.Number64().Uint64(), func(tx kv.Tx, ibs *state.IntraBlockState, reader state.StateReader, writer state.WriterWithChangeSets) (map[types.Address]*uint256.Int, error) {
+		var invalid bool // the block failed, as opposed to the database
+		stopPrefetch := bc.prefetch(block)
+		_, execSpan := tracing.Start(traceCtx, "execute", "txs", len(block.Transactions()))
+		ibs, nopay, err := evmRecord(bc.ctx, bc.ChainDB, block.Number64().Uint64(), block.ParentHash(), func(tx kv.Tx, ibs *state.IntraBlockState, reader state.StateReader, writer state.WriterWithChangeSets) (map[types.Address]*uint256.Int, error) {
 			getHeader := func(hash types.Hash, number uint64) *block2.Header {
 				return rawdb.ReadHeader(tx, hash, number)
 			}
@@ -1017,15 +1078,19 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 			pstart := time.Now()
 			receipts, nopay, logs, usedGas, err = bc.process.Process(block.(*block2.Block), ibs, reader, writer, blockHashFunc)
 			if err != nil {
-				bc.reportBlock(block, receipts, err)
+				invalid = true
 				//atomic.StoreUint32(&followupInterrupt, 1)
 				return nil, err
 			}
 			ptime := time.Since(pstart)
 			vstart := time.Now()
 
-			if err := bc.validator.ValidateState(block, ibs, receipts, usedGas); err != nil {
-				bc.reportBlock(block, receipts, err)
+			_, validateSpan := tracing.Start(traceCtx, "validate")
+			err = bc.validator.ValidateState(block, ibs, receipts, usedGas)
+			validateSpan.RecordError(err)
+			validateSpan.End()
+			if err != nil {
+				invalid = true
 				//atomic.StoreUint32(&followupInterrupt, 1)
 				return nil, err
 			}
@@ -1035,7 +1100,16 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 			blockValidationTimer.Observe(float64(vtime))
 			return nopay, nil
 		})
+		stopPrefetch()
+		execSpan.SetAttributes("gas", usedGas)
+		execSpan.RecordError(err)
+		execSpan.EndAt(time.Now())
 		if nil != err {
+			// Reported once the state transaction is closed, as it stores the block.
+			if invalid {
+				bc.reportBlock(block, receipts, err)
+			}
+			endTrace(err)
 			return it.index, err
 		}
 		//var followupInterrupt uint32
@@ -1064,9 +1138,13 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 		//}
 		wstart := time.Now()
 		var status WriteStatus
+		_, commitSpan := tracing.Start(traceCtx, "commit")
 		status, err = bc.writeBlockWithState(block, receipts, ibs, nopay)
+		commitSpan.RecordError(err)
+		commitSpan.End()
 		//atomic.StoreUint32(&followupInterrupt, 1)
 		if err != nil {
+			endTrace(err)
 			return it.index, err
 		}
 		blockWriteTimer.Observe(float64(time.Since(wstart)))
@@ -1083,7 +1161,9 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 				"root", block.StateRoot())
 
 			if len(logs) > 0 {
+				_, span := tracing.Start(traceCtx, "broadcast", "event", "logs")
 				event.GlobalEvent.Send(common.NewLogsEvent{Logs: logs})
+				span.End()
 			}
 
 			lastCanon = block
@@ -1102,6 +1182,7 @@ func (bc *BlockChain) insertChain(chain []block2.IBlock) (int, error) {
 				"txs", len(block.Transactions()), "gas", block.GasUsed(),
 				"root", block.StateRoot())
 		}
+		endTrace(nil)
 	}
 
 	// Any blocks remaining here? The only ones we care about are the future ones
@@ -1275,6 +1356,9 @@ func (bc *BlockChain) WriteBlockWithoutState(block block2.IBlock) (err error) {
 	if bc.insertStopped() {
 		return errInsertionInterrupted
 	}
+	if err := bc.checkPaused(); err != nil {
+		return err
+	}
 	//if err := bc.state.WriteTD(block.Hash(), td); err != nil {
 	//	return err
 	//}
@@ -1286,7 +1370,49 @@ func (bc *BlockChain) WriteBlockWithoutState(block block2.IBlock) (err error) {
 	})
 }
 
+// AddChainHook registers a hook following the canonical chain. It must be
+// called before the chain starts importing blocks.
+func (bc *BlockChain) AddChainHook(hook ChainHook) {
+	bc.hooks = append(bc.hooks, hook)
+}
+
+// applyHooks runs the hooks on a block joining the canonical chain.
+func (bc *BlockChain) applyHooks(tx kv.RwTx, b block2.IBlock) error {
+	for _, hook := range bc.hooks {
+		if err := hook.Apply(tx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revertHooks runs the hooks on blocks leaving the canonical chain, given
+// from the head down.
+func (bc *BlockChain) revertHooks(tx kv.RwTx, blocks []block2.IBlock) error {
+	for _, b := range blocks {
+		for _, hook := range bc.hooks {
+			if err := hook.Revert(tx, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resetHooks runs the hooks on a head whose derived data came with its state.
+func (bc *BlockChain) resetHooks(tx kv.RwTx, b block2.IBlock) error {
+	for _, hook := range bc.hooks {
+		if err := hook.Reset(tx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (bc *BlockChain) WriteBlockWithState(block block2.IBlock, receipts []*block2.Receipt, ibs *state.IntraBlockState, nopay map[types.Address]*uint256.Int) error {
+	if err := bc.checkPaused(); err != nil {
+		return err
+	}
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
 	_, err := bc.writeBlockWithState(block, receipts, ibs, nopay)
@@ -1295,6 +1421,7 @@ func (bc *BlockChain) WriteBlockWithState(block block2.IBlock, receipts []*block
 
 // writeBlockWithState
 func (bc *BlockChain) writeBlockWithState(block block2.IBlock, receipts []*block2.Receipt, ibs *state.IntraBlockState, nopay map[types.Address]*uint256.Int) (status WriteStatus, err error) {
+	var recorder *snapshot.Recorder
 	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
 		//ptd := bc.GetTd(block.ParentHash(), block.Number64().Sub(uint256.NewInt(1)))
 		ptd, err := rawdb.ReadTd(tx, block.ParentHash(), uint256.NewInt(0).Sub(block.Number64(), uint256.NewInt(1)).Uint64())
@@ -1322,8 +1449,9 @@ func (bc *BlockChain) writeBlockWithState(block block2.IBlock, receipts []*block
 			return err
 		}
 
-		stateWriter := state.NewPlainStateWriter(tx, tx, block.Number64().Uint64())
-		if err := ibs.CommitBlock(bc.chainConfig.Rules(block.Number64().Uint64()), stateWriter); nil != err {
+		recorder = bc.snaps.Recorder(tx)
+		stateWriter := state.NewPlainStateWriter(recorder, tx, block.Number64().Uint64())
+		if err := ibs.CommitBlock(bc.chainConfig.Rules(block.Number64().Uint64(), block.Time()), stateWriter); nil != err {
 			return err
 		}
 
@@ -1340,33 +1468,57 @@ func (bc *BlockChain) writeBlockWithState(block block2.IBlock, receipts []*block
 				rawdb.PutAccountReward(tx, addr, v)
 			}
 		}
+		if preimages := ibs.Preimages(); preimages != nil {
+			if err := rawdb.WritePreimages(tx, preimages); err != nil {
+				return err
+			}
+		}
 
+		for _, hook := range bc.hooks {
+			if err := hook.WriteBlock(tx, block, receipts); err != nil {
+				return err
+			}
+		}
 		return nil
 	}); nil != err {
 		return NonStatTy, err
 	}
+	bc.snaps.Update(block.Hash(), block.ParentHash(), block.Number64().Uint64(), recorder)
 
 	reorg, err := bc.forker.ReorgNeeded(bc.CurrentBlock().Header(), block.Header())
 	if nil != err {
 		return NonStatTy, err
 	}
 	if reorg {
-		// Reorganise the chain if the parent is not the head block
-		if block.ParentHash() != bc.CurrentBlock().Hash() {
-			if err := bc.reorg(nil, bc.CurrentBlock(), block); err != nil {
-				return NonStatTy, err
-			}
-		}
 		status = CanonStatTy
 	} else {
 		status = SideStatTy
 	}
 	// Set new head.
 	if status == CanonStatTy {
-		if err := bc.writeHeadBlock(nil, block); nil != err {
-			log.Errorf("failed to save lates blocks, err: %v", err)
+		if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+			// Reorganise the chain if the parent is not the head block
+			if current := bc.CurrentBlock(); block.ParentHash() != current.Hash() {
+				if err := bc.reorg(tx, current, block); err != nil {
+					return err
+				}
+			}
+			if err := bc.writeHeadBlock(tx, block); err != nil {
+				log.Errorf("failed to save lates blocks, err: %v", err)
+				return err
+			}
+			return bc.applyHooks(tx, block)
+		}); err != nil {
 			return NonStatTy, err
 		}
+		bc.updateFinality(block.Header())
+		var logs []*block2.Log
+		for _, receipt := range receipts {
+			logs = append(logs, receipt.Logs...)
+		}
+		_, span := tracing.Start(tracing.BlockContext(block.Hash()), "broadcast", "event", "chain")
+		event.GlobalEvent.Send(common.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
+		span.End()
 	}
 	//
 	if _, ok := bc.futureBlocks.Get(block.Hash()); ok {
@@ -1410,8 +1562,143 @@ func (bc *BlockChain) writeHeadBlock(tx kv.RwTx, block block2.IBlock) error {
 	return nil
 }
 
-// reportBlock logs a bad block error.
+// SetForkChoice replaces the rule that selects the canonical chain, e.g. with
+// ExternalForkChoice once an external consensus driver takes over.
+func (bc *BlockChain) SetForkChoice(chooser ForkChooser) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	bc.forker = chooser
+}
+
+// SetCanonical makes an already imported block the head of the chain,
+// reorganising the canonical chain if needed. It is the hook external
+// consensus drivers use to apply their fork choice.
+func (bc *BlockChain) SetCanonical(hash types.Hash) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	head, err := bc.GetBlockByHash(hash)
+	if err != nil || head == nil {
+		return fmt.Errorf("unknown block %x", hash)
+	}
+	current := bc.CurrentBlock()
+	if head.Hash() == current.Hash() {
+		return nil
+	}
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		// An ancestor of the head is already applied, only its descendants
+		// are reverted
+		canonical, err := rawdb.ReadCanonicalHash(tx, head.Number64().Uint64())
+		if err != nil {
+			return err
+		}
+		rewind := canonical == head.Hash()
+		if head.ParentHash() != current.Hash() {
+			if err := bc.reorg(tx, current, head); err != nil {
+				return err
+			}
+		}
+		if err := bc.writeHeadBlock(tx, head); err != nil {
+			return err
+		}
+		if rewind {
+			return nil
+		}
+		return bc.applyHooks(tx, head)
+	}); err != nil {
+		return err
+	}
+	bc.updateFinality(head.Header())
+	log.Info("Chain head was updated", "number", head.Number64(), "hash", head.Hash())
+	event.GlobalEvent.Send(common.ChainEvent{Block: head, Hash: head.Hash()})
+	return nil
+}
+
+// SetFinalized records the finalized and safe blocks chosen by an external
+// consensus driver. A zero hash leaves the respective marker untouched.
+func (bc *BlockChain) SetFinalized(finalized, safe types.Hash) error {
+	var events []interface{}
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		events = events[:0]
+		for _, marker := range []struct {
+			hash  types.Hash
+			read  func(kv.Getter) types.Hash
+			write func(kv.Putter, types.Hash) error
+			event func(block2.IHeader) interface{}
+		}{
+			{finalized, rawdb.ReadFinalizedBlockHash, rawdb.WriteFinalizedBlockHash, func(h block2.IHeader) interface{} { return common.FinalizedHeadEvent{Header: h} }},
+			{safe, rawdb.ReadSafeBlockHash, rawdb.WriteSafeBlockHash, func(h block2.IHeader) interface{} { return common.SafeHeadEvent{Header: h} }},
+		} {
+			if marker.hash == (types.Hash{}) {
+				continue
+			}
+			header, err := rawdb.ReadHeaderByHash(tx, marker.hash)
+			if err != nil || header == nil {
+				return fmt.Errorf("unknown block %x", marker.hash)
+			}
+			if marker.read(tx) == marker.hash {
+				continue
+			}
+			if err := marker.write(tx, marker.hash); err != nil {
+				return err
+			}
+			events = append(events, marker.event(header))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, ev := range events {
+		event.GlobalEvent.Send(ev)
+	}
+	return nil
+}
+
+// updateFinality asks the consensus engine for the checkpoints reached by the
+// new head and stores them for the safe and finalized block tags. The
+// finalized checkpoint never moves backwards.
+func (bc *BlockChain) updateFinality(head block2.IHeader) {
+	tracker, ok := bc.engine.(consensus.FinalityTracker)
+	if _, external := bc.forker.(ExternalForkChoice); !ok || external {
+		return
+	}
+	justified, finalized, err := tracker.Finality(bc, head)
+	if err != nil {
+		log.Warn("Failed to compute finality", "number", head.Number64().Uint64(), "hash", head.Hash(), "err", err)
+		return
+	}
+	var safeMoved, finalizedMoved bool
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		safeMoved, finalizedMoved = false, false
+		if justified != nil && rawdb.ReadSafeBlockHash(tx) != justified.Hash() {
+			if err := rawdb.WriteSafeBlockHash(tx, justified.Hash()); err != nil {
+				return err
+			}
+			safeMoved = true
+		}
+		if finalized == nil {
+			return nil
+		}
+		if last := rawdb.ReadHeaderNumber(tx, rawdb.ReadFinalizedBlockHash(tx)); last != nil && *last >= finalized.Number64().Uint64() {
+			return nil
+		}
+		finalizedMoved = true
+		return rawdb.WriteFinalizedBlockHash(tx, finalized.Hash())
+	}); err != nil {
+		log.Warn("Failed to store finality checkpoints", "err", err)
+		return
+	}
+	if safeMoved {
+		event.GlobalEvent.Send(common.SafeHeadEvent{Header: justified})
+	}
+	if finalizedMoved {
+		event.GlobalEvent.Send(common.FinalizedHeadEvent{Header: finalized})
+	}
+}
+
+// reportBlock logs a bad block error and keeps the block for inspection.
 func (bc *BlockChain) reportBlock(block block2.IBlock, receipts []*block2.Receipt, err error) {
+	bc.markBadBlock(block, receipts, err)
 
 	var receiptString string
 	for i, receipt := range receipts {
@@ -1443,14 +1730,116 @@ func (bc *BlockChain) ReorgNeeded(current block2.IBlock, header block2.IBlock) b
 }
 
 // SetHead set new head
+// SetHead rewinds the canonical chain to the given block, see Rewind.
 func (bc *BlockChain) SetHead(head uint64) error {
-	newHeadBlock, err := bc.GetBlockByNumber(uint256.NewInt(head))
-	if err != nil {
-		return nil
+	return bc.Rewind(head, false)
+}
+
+// Rewind makes the canonical block number the head of the chain. The blocks
+// above it stay in the database as side blocks, but their canonical markers,
+// receipts and transaction index entries are dropped and the state is rolled
+// back with the changesets. A rewind is refused if the state of the block was
+// pruned, or if it would revert the finalized block, unless force is set, in
+// which case the finalized and safe markers are moved back to the new head.
+func (bc *BlockChain) Rewind(number uint64, force bool) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	var (
+		head        *block2.Block
+		oldChain    []*block2.Block
+		removedLogs []*block2.Log
+	)
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		current := rawdb.ReadCurrentBlock(tx)
+		if current == nil {
+			return fmt.Errorf("no current block")
+		}
+		if number >= current.Number64().Uint64() {
+			return fmt.Errorf("block %d is not below the head %d", number, current.Number64().Uint64())
+		}
+		hash, err := rawdb.ReadCanonicalHash(tx, number)
+		if err != nil {
+			return err
+		}
+		if head = rawdb.ReadBlock(tx, hash, number); head == nil {
+			return fmt.Errorf("canonical block %d not found", number)
+		}
+		pruned, err := rawdb.ReadStatePruneProgress(tx)
+		if err != nil {
+			return err
+		}
+		if number+1 < pruned {
+			return fmt.Errorf("state of block %d is pruned, history starts at block %d", number, pruned)
+		}
+		for _, marker := range []struct {
+			name  string
+			read  func(kv.Getter) types.Hash
+			write func(kv.Putter, types.Hash) error
+		}{
+			{"finalized", rawdb.ReadFinalizedBlockHash, rawdb.WriteFinalizedBlockHash},
+			{"safe", rawdb.ReadSafeBlockHash, rawdb.WriteSafeBlockHash},
+		} {
+			n := rawdb.ReadHeaderNumber(tx, marker.read(tx))
+			if n == nil || *n <= number {
+				continue
+			}
+			if !force {
+				return fmt.Errorf("rewind to %d would revert %s block %d", number, marker.name, *n)
+			}
+			if err := marker.write(tx, head.Hash()); err != nil {
+				return err
+			}
+		}
+
+		oldChain = oldChain[:0]
+		removedLogs = removedLogs[:0]
+		for b := current; b != nil && b.Number64().Uint64() > number; b = rawdb.ReadBlock(tx, b.ParentHash(), b.Number64().Uint64()-1) {
+			oldChain = append(oldChain, b)
+			removedLogs = append(removedLogs, collectRemovedLogs(tx, b)...)
+			for _, t := range b.Transactions() {
+				if err := rawdb.DeleteTxLookupEntry(tx, t.Hash()); err != nil {
+					return err
+				}
+			}
+		}
+		if err := rawdb.TruncateReceipts(tx, number+1); err != nil {
+			return err
+		}
+		if err := rawdb.TruncateCanonicalHash(tx, number+1, false); err != nil {
+			return err
+		}
+		if err := state.UnwindState(tx, number); err != nil {
+			return err
+		}
+		dropped := make([]block2.IBlock, len(oldChain))
+		for i, b := range oldChain {
+			dropped[i] = b
+		}
+		if err := bc.revertHooks(tx, dropped); err != nil {
+			return err
+		}
+		rawdb.WriteHeadBlockHash(tx, head.Hash())
+		return rawdb.WriteHeadHeaderHash(tx, head.Hash())
+	}); err != nil {
+		return err
 	}
-	return bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
-		return rawdb.WriteHeadHeaderHash(tx, newHeadBlock.Hash())
-	})
+
+	bc.currentBlock.Store(head)
+	headBlockGauge.Set(number)
+	bc.snaps.Reset(head.Hash())
+	if _, err := bc.rewindBloomSections(); err != nil {
+		log.Warn("Failed to rewind bloom sections", "err", err)
+	}
+	log.Info("Rewound the chain", "number", number, "hash", head.Hash(), "dropped", len(oldChain), "force", force)
+
+	for _, b := range oldChain {
+		event.GlobalEvent.Send(common.ChainSideEvent{Block: b})
+	}
+	if len(removedLogs) > 0 {
+		event.GlobalEvent.Send(common.RemovedLogsEvent{Logs: removedLogs})
+	}
+	return nil
 }
 
 // AddFutureBlock checks if the block is within the max allowed window to get
@@ -1497,6 +1886,9 @@ func (bc *BlockChain) writeKnownBlock(tx kv.RwTx, block block2.IBlock) error {
 	if err = bc.writeHeadBlock(tx, block); nil != err {
 		return err
 	}
+	if err = bc.applyHooks(tx, block); nil != err {
+		return err
+	}
 	if notExternalTx {
 		if err = tx.Commit(); nil != err {
 			return err
@@ -1531,7 +1923,7 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 		}
 	} else {
 		// New chain is longer, stash all blocks away for subsequent insertion
-		for ; newBlock != nil && newBlock.Number64() != oldBlock.Number64(); newBlock = bc.GetBlock(newBlock.ParentHash(), newBlock.Number64().Uint64()-1) {
+		for ; newBlock != nil && newBlock.Number64().Uint64() != oldBlock.Number64().Uint64(); newBlock = bc.GetBlock(newBlock.ParentHash(), newBlock.Number64().Uint64()-1) {
 			newChain = append(newChain, newBlock)
 		}
 	}
@@ -1542,7 +1934,7 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 		return fmt.Errorf("invalid new chain")
 	}
 
-	var useExternalTx bool
+	useExternalTx := tx != nil
 	var err error
 	if tx == nil {
 		tx, err = bc.ChainDB.BeginRw(bc.ctx)
@@ -1550,7 +1942,6 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 			return err
 		}
 		defer tx.Rollback()
-		useExternalTx = false
 	}
 
 	// Both sides of the reorg are at the same number, reduce both until the common
@@ -1582,11 +1973,22 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 		}
 	}
 
+	// Finalized blocks are irreversible, refuse to unwind past them
+	if finalized := rawdb.ReadHeaderNumber(tx, rawdb.ReadFinalizedBlockHash(tx)); finalized != nil && commonBlock.Number64().Uint64() < *finalized {
+		return fmt.Errorf("reorg to %v would revert finalized block %d", newChain[0].Hash(), *finalized)
+	}
+	// Gather the logs of the dropped blocks before their receipts are replaced.
+	var removedLogs []*block2.Log
+	for _, b := range oldChain {
+		removedLogs = append(removedLogs, collectRemovedLogs(tx, b)...)
+	}
+
 	// Ensure the user sees large reorgs
+	deep := uint64(len(oldChain)) >= bc.reorgAlertDepth
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Info
 		msg := "Chain reorg detected"
-		if len(oldChain) > 63 {
+		if deep {
 			msg = "Large chain reorg detected"
 			logFn = log.Warn
 		}
@@ -1598,14 +2000,23 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 		log.Info("Extend chain", "add", len(newChain), "number", newChain[0].Number64(), "hash", newChain[0].Hash())
 	} else {
 		// len(newChain) == 0 && len(oldChain) > 0
-		// rewind the canonical chain to a lower point.
-		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number64(), "oldhash", oldBlock.Hash(), "oldblocks", len(oldChain), "newnum", newBlock.Number64(), "newhash", newBlock.Hash(), "newblocks", len(newChain))
+		// rewind the canonical chain to a lower point, as SetCanonical does when
+		// an external driver picks an ancestor of the head.
+		log.Info("Rewind chain", "drop", len(oldChain), "dropfrom", oldChain[0].Hash(), "number", commonBlock.Number64(), "hash", commonBlock.Hash())
+	}
+	// Take the dropped blocks out of the derived data before the new ones
+	// go in.
+	if err := bc.revertHooks(tx, oldChain); err != nil {
+		return err
 	}
 	// Insert the new chain(except the head block(reverse order)),
 	// taking care of the proper incremental order.
 	for i := len(newChain) - 1; i >= 1; i-- {
 		// Insert the block in the canonical way, re-writing history
 		bc.writeHeadBlock(tx, newChain[i])
+		if err := bc.applyHooks(tx, newChain[i]); err != nil {
+			return err
+		}
 
 		// Collect the new added transactions.
 		for _, t := range newChain[i].Transactions() {
@@ -1642,10 +2053,52 @@ func (bc *BlockChain) reorg(tx kv.RwTx, oldBlock, newBlock block2.IBlock) error
 		}
 	}
 
+	for _, b := range oldChain {
+		event.GlobalEvent.Send(common.ChainSideEvent{Block: b})
+	}
+	if len(removedLogs) > 0 {
+		event.GlobalEvent.Send(common.RemovedLogsEvent{Logs: removedLogs})
+	}
+	if deep && len(newChain) > 0 {
+		// The new head isn't written by the reorg, its transactions are kept too.
+		kept := addedTxs
+		for _, t := range newChain[0].Transactions() {
+			kept = append(kept, t.Hash())
+		}
+		event.GlobalEvent.Send(common.ReorgEvent{
+			OldHead:      oldChain[0].Hash(),
+			OldNumber:    oldChain[0].Number64().Uint64(),
+			NewHead:      newChain[0].Hash(),
+			NewNumber:    newChain[0].Number64().Uint64(),
+			CommonHash:   commonBlock.Hash(),
+			CommonNumber: commonBlock.Number64().Uint64(),
+			Depth:        uint64(len(oldChain)),
+			DroppedTxs:   types.HashDifference(deletedTxs, kept),
+		})
+	}
 	return nil
 }
+
+// collectRemovedLogs returns the logs of a block leaving the canonical chain,
+// marked as removed.
+func collectRemovedLogs(tx kv.Tx, b block2.IBlock) []*block2.Log {
+	blk, ok := b.(*block2.Block)
+	if !ok {
+		return nil
+	}
+	var logs []*block2.Log
+	for _, receipt := range rawdb.ReadReceipts(tx, blk, nil) {
+		for _, l := range receipt.Logs {
+			l.Removed = true
+			logs = append(logs, l)
+		}
+	}
+	return logs
+}
+
 func (bc *BlockChain) Close() error {
-	bc.Quit()
+	bc.cancel()
+	bc.loops.Wait()
 	return nil
 }
 
@@ -1657,11 +2110,126 @@ func (bc *BlockChain) DB() kv.RwDB {
 	return bc.ChainDB
 }
 
+// Snapshots returns the snapshot of the state at the last executed block.
+func (bc *BlockChain) Snapshots() *snapshot.Tree {
+	return bc.snaps
+}
+
+// SetReorgAlertDepth sets the number of dropped blocks from which a reorg is
+// logged as a warning and posted as a ReorgEvent.
+func (bc *BlockChain) SetReorgAlertDepth(depth uint64) {
+	if depth > 0 {
+		bc.reorgAlertDepth = depth
+	}
+}
+
+// SetPreimageRecording makes the blocks imported from now on store the inputs
+// of the keccak256 hashes they compute, along with their hashed addresses and
+// storage keys. It must be set before the chain starts.
+func (bc *BlockChain) SetPreimageRecording(enabled bool) {
+	bc.recordPreimages = enabled
+}
+
+// SetEVMProfiler makes the executions of the blocks imported from now on
+// collect their gas and time per opcode and per contract into the profiler.
+// It must be set before the chain starts.
+func (bc *BlockChain) SetEVMProfiler(profiler *vm.Profiler) {
+	bc.profiler = profiler
+}
+
+// EVMProfiler returns the profiler of the block executions, nil unless
+// profiling is enabled.
+func (bc *BlockChain) EVMProfiler() *vm.Profiler {
+	return bc.profiler
+}
+
 func (bc *BlockChain) StateAt(tx kv.Tx, blockNr uint64) *state.IntraBlockState {
 	reader := state.NewPlainState(tx, blockNr+1)
 	return state.New(reader)
 }
 
+// ReplayBlock executes a block on ibs, which must hold the state of its
+// parent, leaving the state of the block in it. It regenerates states the
+// database doesn't keep, such as those of side chain blocks.
+func (bc *BlockChain) ReplayBlock(tx kv.Tx, ibs *state.IntraBlockState, b *block2.Block) error {
+	getHeader := func(hash types.Hash, number uint64) *block2.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	}
+	processor := NewStateProcessor(bc.chainConfig, bc, bc.engine)
+	_, _, _, _, err := processor.process(b, ibs, GetHashFn(b.Header().(*block2.Header), getHeader), true)
+	return err
+}
+
+// RecordWitness executes a canonical block again on the state of its parent
+// and returns everything the execution read, which is enough to execute the
+// block with ExecuteStateless.
+func (bc *BlockChain) RecordWitness(tx kv.Tx, b *block2.Block) (*state.Witness, error) {
+	number := b.Number64().Uint64()
+	if number == 0 {
+		return nil, errors.New("the genesis block isn't executed")
+	}
+	getHeader := func(hash types.Hash, number uint64) *block2.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	}
+	recorder := state.NewWitnessRecorder(state.NewPlainState(tx, number))
+	ibs := state.New(recorder)
+	processor := NewStateProcessor(bc.chainConfig, bc, bc.engine)
+	if _, _, _, _, err := processor.process(b, ibs, recorder.BlockHashes(GetHashFn(b.Header().(*block2.Header), getHeader)), true); err != nil {
+		return nil, err
+	}
+	if err := ibs.Error(); err != nil {
+		return nil, err
+	}
+	return recorder.Witness(), nil
+}
+
+// ExecuteStateless executes a block against a witness instead of the state
+// database and validates the outcome against the header. The consensus
+// engine still reads the chain through bc when finalizing the block.
+func (bc *BlockChain) ExecuteStateless(b *block2.Block, witness *state.Witness) (block2.Receipts, error) {
+	reader := state.NewWitnessReader(witness)
+	ibs := state.New(reader)
+	processor := NewStateProcessor(bc.chainConfig, bc, bc.engine)
+	receipts, _, _, usedGas, err := processor.process(b, ibs, reader.BlockHash, true)
+	if err == nil {
+		err = ibs.Error()
+	}
+	// A missing entry is the reason of whatever failure it caused.
+	if reader.Err() != nil {
+		return nil, reader.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := bc.validator.ValidateState(b, ibs, receipts, usedGas); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// VerifyExecution executes a canonical block again on the state of its
+// parent and validates the outcome against the header. Nothing is written, it
+// only checks that the stored state history reproduces the block.
+func (bc *BlockChain) VerifyExecution(tx kv.Tx, b *block2.Block) error {
+	number := b.Number64().Uint64()
+	if number == 0 {
+		return errors.New("the genesis block isn't executed")
+	}
+	getHeader := func(hash types.Hash, number uint64) *block2.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	}
+	ibs := state.New(state.NewPlainState(tx, number))
+	processor := NewStateProcessor(bc.chainConfig, bc, bc.engine)
+	receipts, _, _, usedGas, err := processor.process(b, ibs, GetHashFn(b.Header().(*block2.Header), getHeader), true)
+	if err == nil {
+		err = ibs.Error()
+	}
+	if err != nil {
+		return err
+	}
+	return bc.validator.ValidateState(b, ibs, receipts, usedGas)
+}
+
 func (bc *BlockChain) GetDepositInfo(address types.Address) (*uint256.Int, *uint256.Int) {
 	var info *deposit.Info
 	bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
@@ -0,0 +1,102 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/amazechain/amc/common"
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/consensus"
+	"github.com/amazechain/amc/log"
+	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// maxBadBlocks is the number of bad block hashes refused on import. Only the
+// most recent of them are kept in the database with their content.
+const maxBadBlocks = 128
+
+// loadBadBlocks refuses the bad blocks stored by earlier runs.
+func (bc *BlockChain) loadBadBlocks() {
+	if err := bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
+		bads, err := rawdb.ReadAllBadBlocks(tx)
+		for _, bad := range bads {
+			bc.badBlocks.Add(bad.Block.Hash(), struct{}{})
+		}
+		return err
+	}); err != nil {
+		log.Warn("Failed to load bad blocks", "err", err)
+	}
+}
+
+// BadBlocks returns the bad blocks kept in the database, highest first.
+func (bc *BlockChain) BadBlocks() ([]*rawdb.BadBlock, error) {
+	var bads []*rawdb.BadBlock
+	err := bc.ChainDB.View(bc.ctx, func(tx kv.Tx) (err error) {
+		bads, err = rawdb.ReadAllBadBlocks(tx)
+		return err
+	})
+	return bads, err
+}
+
+// isBadBlock reports whether the hash belongs to a block that failed validation.
+func (bc *BlockChain) isBadBlock(hash types.Hash) bool {
+	return bc.badBlocks.Contains(hash)
+}
+
+// markBadBlock stores a block that failed validation, refuses it from now on
+// and tells the subscribers of the event bus. Failures saying nothing about
+// the block itself, like a missing parent, are ignored.
+func (bc *BlockChain) markBadBlock(block block2.IBlock, receipts []*block2.Receipt, err error) {
+	if !isBlockFault(err) {
+		return
+	}
+	bc.badBlocks.Add(block.Hash(), struct{}{})
+	if blk, ok := block.(*block2.Block); ok {
+		if werr := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+			return rawdb.WriteBadBlock(tx, &rawdb.BadBlock{
+				Block:    blk,
+				Receipts: receipts,
+				Reason:   err.Error(),
+				Time:     uint64(time.Now().Unix()),
+			})
+		}); werr != nil {
+			log.Warn("Failed to store bad block", "number", block.Number64().Uint64(), "hash", block.Hash(), "err", werr)
+		}
+	}
+	event.GlobalEvent.Send(common.BadBlockEvent{Block: block, Reason: err.Error()})
+}
+
+// isBlockFault tells whether an import error proves the block invalid.
+func isBlockFault(err error) bool {
+	switch {
+	case errors.Is(err, ErrUnknownAncestor), errors.Is(err, consensus.ErrUnknownAncestor),
+		errors.Is(err, ErrPrunedAncestor), errors.Is(err, consensus.ErrPrunedAncestor),
+		errors.Is(err, ErrFutureBlock), errors.Is(err, consensus.ErrFutureBlock),
+		errors.Is(err, ErrCheckpointMismatch), errors.Is(err, ErrBannedHash),
+		errors.Is(err, errInsertionInterrupted), errors.Is(err, errChainStopped),
+		errors.Is(err, ErrImportPaused),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/common/bitutil"
+	"github.com/amazechain/amc/internal/bloombits"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/params"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// bloomIndexInterval is how often the bloom indexer catches up with the chain.
+const bloomIndexInterval = 30 * time.Second
+
+// errSectionNotIndexed is returned for the bloom bits of a section not indexed.
+var errSectionNotIndexed = errors.New("bloom section not indexed")
+
+// StartBloomIndexer rotates the header blooms of the canonical chain into the
+// BloomBits table in the background, one section of params.BloomBitsBlocks
+// blocks at a time, as soon as its last block is params.BloomConfirms deep.
+// Sections are indexed from the genesis up; a reorg replacing the head of an
+// indexed section drops it and the sections above, which are indexed again.
+func (bc *BlockChain) StartBloomIndexer() {
+	bc.loops.Add(1)
+	go bc.bloomIndexLoop()
+}
+
+func (bc *BlockChain) bloomIndexLoop() {
+	defer bc.loops.Done()
+	ticker := time.NewTicker(bloomIndexInterval)
+	defer ticker.Stop()
+	for {
+		if bc.ImportPaused() == "" {
+			if err := bc.indexBloomBits(); err != nil && bc.ctx.Err() == nil {
+				log.Warn("Failed to index bloom bits", "err", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-bc.ctx.Done():
+			return
+		}
+	}
+}
+
+// indexBloomBits indexes every confirmed section not indexed yet.
+func (bc *BlockChain) indexBloomBits() error {
+	size := params.BloomBitsBlocks
+	sections, err := bc.rewindBloomSections()
+	if err != nil {
+		return err
+	}
+	var (
+		start   = time.Now()
+		indexed uint64
+	)
+	for bc.ctx.Err() == nil {
+		if bc.CurrentBlock().Number64().Uint64()+1 < (sections+1)*size+params.BloomConfirms {
+			break
+		}
+		if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+			return indexBloomSection(tx, sections, size)
+		}); err != nil {
+			return err
+		}
+		sections++
+		indexed++
+	}
+	if indexed > 0 {
+		log.Info("Indexed bloom bits", "sections", indexed, "head", sections*size-1, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+// rewindBloomSections drops the indexed sections whose head left the canonical
+// chain and returns the number of sections left.
+func (bc *BlockChain) rewindBloomSections() (uint64, error) {
+	var sections uint64
+	err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		var err error
+		if sections, err = rawdb.ReadBloomSections(tx); err != nil {
+			return err
+		}
+		for ; sections > 0; sections-- {
+			head, _, err := rawdb.ReadBloomSectionHead(tx, sections-1)
+			if err != nil {
+				return err
+			}
+			canonical, err := rawdb.ReadCanonicalHash(tx, sections*params.BloomBitsBlocks-1)
+			if err != nil {
+				return err
+			}
+			if canonical == head {
+				return nil
+			}
+			log.Warn("Dropping reorged bloom section", "section", sections-1, "head", head)
+			if err := rawdb.DeleteBloomBits(tx, sections-1, head); err != nil {
+				return err
+			}
+			if err := rawdb.DeleteBloomSectionHead(tx, sections-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return sections, err
+}
+
+// indexBloomSection writes the bloom bits of the canonical blocks of a section.
+func indexBloomSection(tx kv.RwTx, section, size uint64) error {
+	gen, err := bloombits.NewGenerator(uint(size))
+	if err != nil {
+		return err
+	}
+	var head types.Hash
+	for i := uint64(0); i < size; i++ {
+		header := rawdb.ReadHeaderByNumber(tx, section*size+i)
+		if header == nil {
+			return fmt.Errorf("missing canonical header #%d", section*size+i)
+		}
+		if err := gen.AddBloom(uint(i), header.Bloom); err != nil {
+			return err
+		}
+		head = header.Hash()
+	}
+	for bit := uint(0); bit < block.BloomBitLength; bit++ {
+		bits, err := gen.Bitset(bit)
+		if err != nil {
+			return err
+		}
+		if err := rawdb.WriteBloomBits(tx, bit, section, head, bitutil.CompressBytes(bits)); err != nil {
+			return err
+		}
+	}
+	return rawdb.WriteBloomSectionHead(tx, section, head)
+}
+
+// BloomStatus returns the number of blocks per section of the bloom bits and
+// the number of sections indexed.
+func (bc *BlockChain) BloomStatus() (uint64, uint64) {
+	var sections uint64
+	if err := bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
+		var err error
+		sections, err = rawdb.ReadBloomSections(tx)
+		return err
+	}); err != nil {
+		log.Error("Failed to read the bloom sections", "err", err)
+	}
+	return params.BloomBitsBlocks, sections
+}
+
+// BloomBits returns the bit vector of a bloom bit over an indexed section.
+func (bc *BlockChain) BloomBits(bit uint, section uint64) ([]byte, error) {
+	var data []byte
+	if err := bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
+		head, ok, err := rawdb.ReadBloomSectionHead(tx, section)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errSectionNotIndexed
+		}
+		data, err = rawdb.ReadBloomBits(tx, bit, section, head)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return bitutil.DecompressBytes(data, int(params.BloomBitsBlocks/8))
+}
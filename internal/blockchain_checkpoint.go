@@ -0,0 +1,98 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/params"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// ErrCheckpointMismatch is returned if a block below the sync checkpoint isn't
+// on the trusted header chain leading to it.
+var ErrCheckpointMismatch = errors.New("block doesn't match the trusted checkpoint chain")
+
+// SetCheckpoint sets the checkpoint below which blocks are checked against the
+// trusted header chain instead of being verified by the consensus engine. It
+// moves up while the sync follows the heads announced by a trusted source.
+func (bc *BlockChain) SetCheckpoint(checkpoint *params.SyncCheckpoint) {
+	bc.checkpoint.Store(checkpoint)
+}
+
+// Checkpoint returns the sync checkpoint, nil if none is configured.
+func (bc *BlockChain) Checkpoint() *params.SyncCheckpoint {
+	return bc.checkpoint.Load()
+}
+
+// trustedPrefix returns how many leading blocks of the chain are on the
+// trusted header chain. Blocks up to the checkpoint that the trusted chain
+// covers but doesn't contain are rejected.
+func (bc *BlockChain) trustedPrefix(chain []block2.IBlock) (int, error) {
+	checkpoint := bc.checkpoint.Load()
+	if checkpoint == nil || chain[0].Number64().Uint64() > checkpoint.Number {
+		return 0, nil
+	}
+	trusted := 0
+	err := bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
+		for i, block := range chain {
+			number := block.Number64().Uint64()
+			if number > checkpoint.Number {
+				return nil
+			}
+			hash, _, ok, err := rawdb.ReadTrustedHeader(tx, number)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				// Not fetched yet, leave the rest to the engine.
+				return nil
+			}
+			if hash != block.Hash() {
+				return fmt.Errorf("%w: #%d is %s, want %s", ErrCheckpointMismatch, number, block.Hash(), hash)
+			}
+			trusted = i + 1
+		}
+		return nil
+	})
+	return trusted, err
+}
+
+// verifyHeaders verifies the headers of a chain of blocks. The consensus
+// checks are skipped if all the blocks are on the trusted header chain; a
+// batch crossing the checkpoint goes through the engine whole, as it needs the
+// parents of the first untrusted header.
+func (bc *BlockChain) verifyHeaders(chain []block2.IBlock) (chan<- struct{}, <-chan error) {
+	trusted, err := bc.trustedPrefix(chain)
+	if err != nil || trusted == len(chain) {
+		abort, results := make(chan struct{}), make(chan error, len(chain))
+		for range chain {
+			results <- err
+		}
+		return abort, results
+	}
+	headers := make([]block2.IHeader, len(chain))
+	seals := make([]bool, len(chain))
+	for i, block := range chain {
+		headers[i] = block.Header()
+		seals[i] = true
+	}
+	return bc.engine.VerifyHeaders(bc, headers, seals)
+}
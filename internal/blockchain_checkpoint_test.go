@@ -0,0 +1,108 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/params"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// writeTrustedBlocks stores the blocks as the trusted header chain.
+func writeTrustedBlocks(t *testing.T, bc *BlockChain, blocks []block.IBlock) {
+	t.Helper()
+	if err := bc.ChainDB.Update(context.Background(), func(tx kv.RwTx) error {
+		for _, b := range blocks {
+			if err := rawdb.WriteTrustedHeader(tx, b.Number64().Uint64(), b.Hash(), b.ParentHash()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckpointTrustedBlocks(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("checkpoint signer")))
+	other := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("checkpoint outsider")))
+	bc, genesis := newTestBlockChain(t, DeveloperGenesisBlock(0, crypto.PubkeyToAddress(key.PublicKey)))
+
+	// Blocks sealed by a key that isn't a signer only pass as trusted blocks.
+	blocks := sealedTestBlocks(t, bc, other, genesis, 4)
+	bc.SetCheckpoint(&params.SyncCheckpoint{Number: 4, Hash: blocks[3].Hash()})
+	writeTrustedBlocks(t, bc, blocks)
+	if _, err := bc.InsertBlocksWithoutState(blocks[:2]); err != nil {
+		t.Fatalf("trusted blocks rejected: %v", err)
+	}
+
+	// Below the checkpoint only the trusted chain is accepted, even if sealed
+	// by a signer.
+	forked := sealedTestBlocks(t, bc, key, blocks[1], 2)
+	if _, err := bc.InsertBlocksWithoutState(forked); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("error %v, want %v", err, ErrCheckpointMismatch)
+	}
+	if _, err := bc.InsertBlocksWithoutState(blocks[2:]); err != nil {
+		t.Fatalf("trusted blocks rejected: %v", err)
+	}
+	for _, b := range blocks {
+		if hash := bc.GetCanonicalHash(b.Number64()); hash != b.Hash() {
+			t.Errorf("canonical #%d is %s, want %s", b.Number64().Uint64(), hash, b.Hash())
+		}
+	}
+	// Past the checkpoint the engine verifies the blocks again.
+	if _, err := bc.InsertBlocksWithoutState(sealedTestBlocks(t, bc, other, blocks[3], 1)); err == nil {
+		t.Fatal("inserted a block sealed by an unauthorized key above the checkpoint")
+	}
+}
+
+func TestCheckpointTrustedPrefix(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("checkpoint signer")))
+	bc, genesis := newTestBlockChain(t, DeveloperGenesisBlock(0, crypto.PubkeyToAddress(key.PublicKey)))
+	blocks := sealedTestBlocks(t, bc, key, genesis, 6)
+
+	if trusted, err := bc.trustedPrefix(blocks); err != nil || trusted != 0 {
+		t.Errorf("trusted %d, %v without a checkpoint", trusted, err)
+	}
+	bc.SetCheckpoint(&params.SyncCheckpoint{Number: 4, Hash: blocks[3].Hash()})
+	// Only the headers down to #3 are fetched yet.
+	writeTrustedBlocks(t, bc, blocks[2:4])
+
+	tests := []struct {
+		chain   []block.IBlock
+		trusted int
+	}{
+		// Not fetched yet.
+		{blocks[:4], 0},
+		{blocks[2:4], 2},
+		// Crossing the checkpoint.
+		{blocks[2:6], 2},
+		// Above the checkpoint.
+		{blocks[4:], 0},
+	}
+	for i, tt := range tests {
+		if trusted, err := bc.trustedPrefix(tt.chain); err != nil || trusted != tt.trusted {
+			t.Errorf("test %d: trusted %d, %v, want %d", i, trusted, err, tt.trusted)
+		}
+	}
+}
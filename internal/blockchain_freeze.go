@@ -0,0 +1,146 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/freezer"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// freezeInterval is how often the freezer catches up with the chain.
+	freezeInterval = time.Minute
+	// freezeBatchBlocks is the number of blocks moved per database transaction.
+	freezeBatchBlocks = 2048
+)
+
+// StartFreezer moves the canonical blocks older than the last threshold ones
+// out of the chain database into f, in the background. Only finalized blocks
+// are frozen, and none while a snap sync is running. The readers of the
+// database serve the frozen blocks from f once it is set with
+// rawdb.SetAncients.
+func (bc *BlockChain) StartFreezer(f *freezer.Freezer, threshold uint64) {
+	log.Info("Freezing ancient blocks", "keep", threshold, "frozen", f.Ancients())
+	bc.loops.Add(1)
+	go bc.freezeLoop(f, threshold)
+}
+
+func (bc *BlockChain) freezeLoop(f *freezer.Freezer, threshold uint64) {
+	defer bc.loops.Done()
+	ticker := time.NewTicker(freezeInterval)
+	defer ticker.Stop()
+	for {
+		// The freezer files grow before the database shrinks, nothing is
+		// moved while the import is paused.
+		if bc.ImportPaused() == "" {
+			if err := bc.freeze(f, threshold); err != nil && bc.ctx.Err() == nil {
+				log.Warn("Failed to freeze ancient blocks", "err", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-bc.ctx.Done():
+			return
+		}
+	}
+}
+
+// freeze moves batch after batch until the database holds threshold blocks.
+// A batch is appended to the freezer and synced before it is deleted from the
+// database, so that an interruption leaves the blocks in both at worst; the
+// deletion then catches up on the next run.
+func (bc *BlockChain) freeze(f *freezer.Freezer, threshold uint64) error {
+	start := time.Now()
+	first := f.Ancients()
+	for bc.ctx.Err() == nil {
+		var done bool
+		if err := bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
+			target, err := PruneTarget(tx, bc.CurrentBlock().Number64().Uint64(), threshold)
+			if err != nil {
+				return err
+			}
+			number := f.Ancients()
+			for limit := number + freezeBatchBlocks; number < target && number < limit; number++ {
+				if err := freezeBlock(tx, f, number); err != nil {
+					return err
+				}
+			}
+			done = number >= target
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+			from, err := rawdb.ReadAncientProgress(tx)
+			if err != nil {
+				return err
+			}
+			frozen := f.Ancients()
+			for number := from; number < frozen; number++ {
+				if err := rawdb.DeleteFrozenBlock(tx, number); err != nil {
+					return err
+				}
+			}
+			return rawdb.WriteAncientProgress(tx, frozen)
+		}); err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+		log.Info("Freezing ancient blocks", "frozen", f.Ancients(), "elapsed", time.Since(start))
+	}
+	if frozen := f.Ancients(); frozen > first {
+		log.Debug("Froze ancient blocks", "from", first, "to", frozen, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+// freezeBlock appends the canonical block number to the freezer.
+func freezeBlock(tx kv.Tx, f *freezer.Freezer, number uint64) error {
+	hash, err := rawdb.ReadCanonicalHash(tx, number)
+	if err != nil {
+		return err
+	}
+	header, err := tx.GetOne(modules.Headers, modules.HeaderKey(number, hash))
+	if err != nil {
+		return err
+	}
+	body := rawdb.ReadCanonicalBodyWithTransactions(tx, hash, number)
+	if len(header) == 0 || body == nil {
+		return fmt.Errorf("canonical block #%d %s to freeze is missing", number, hash)
+	}
+	bodyData, err := proto.Marshal(body.ToProtoMessage())
+	if err != nil {
+		return err
+	}
+	receipts, err := tx.GetOne(modules.Receipts, modules.EncodeBlockNumber(number))
+	if err != nil {
+		return err
+	}
+	return f.Append(number, hash, header, bodyData, receipts)
+}
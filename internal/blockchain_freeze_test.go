@@ -0,0 +1,102 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/modules/ethdb/freezer"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+func TestFreeze(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("freeze test")))
+	bc, genesis := newTestBlockChain(t, DeveloperGenesisBlock(0, crypto.PubkeyToAddress(key.PublicKey)))
+	blocks := []block.IBlock{genesis}
+	for i := 0; i < 5; i++ {
+		blocks = append(blocks, writeEmptyBlock(t, bc, blocks[len(blocks)-1], 'a', 2))
+	}
+	f, err := freezer.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	finalize := func(number int) {
+		if err := bc.ChainDB.Update(context.Background(), func(tx kv.RwTx) error {
+			return rawdb.WriteFinalizedBlockHash(tx, blocks[number].Hash())
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// check reads every block of the chain, the frozen ones out of the
+	// database.
+	check := func(frozen uint64) {
+		t.Helper()
+		if have := f.Ancients(); have != frozen {
+			t.Fatalf("frozen blocks mismatch: have %d, want %d", have, frozen)
+		}
+		rawdb.SetAncients(nil)
+		defer rawdb.SetAncients(nil)
+		for pass := 0; pass < 2; pass++ {
+			if err := bc.ChainDB.View(context.Background(), func(tx kv.Tx) error {
+				if progress, err := rawdb.ReadAncientProgress(tx); err != nil || progress != frozen {
+					t.Fatalf("ancient progress %d (%v), want %d", progress, err, frozen)
+				}
+				for _, b := range blocks {
+					number := b.Number64().Uint64()
+					read, err := rawdb.ReadBlockByNumber(tx, number)
+					if err != nil {
+						return err
+					}
+					if inDB := number >= frozen; pass == 0 && (read != nil) != inDB {
+						t.Errorf("block %d in the database: %v, want %v", number, read != nil, inDB)
+					} else if pass == 1 && (read == nil || read.Hash() != b.Hash()) {
+						t.Errorf("block %d: have %v, want %x", number, read, b.Hash())
+					}
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			rawdb.SetAncients(f)
+		}
+	}
+
+	// Nothing past the finalized block is frozen, whatever the threshold.
+	finalize(2)
+	if err := bc.freeze(f, 1); err != nil {
+		t.Fatal(err)
+	}
+	check(3)
+
+	// Blocks appended without leaving the database, as when the freezer is
+	// interrupted, are deleted on the next run.
+	if err := bc.ChainDB.View(context.Background(), func(tx kv.Tx) error {
+		return freezeBlock(tx, f, 3)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	finalize(5)
+	if err := bc.freeze(f, 1); err != nil {
+		t.Fatal(err)
+	}
+	check(4)
+}
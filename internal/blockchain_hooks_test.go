@@ -0,0 +1,132 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal/consensus/apoa"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// newTestBlockChain returns a chain on an in-memory database holding the
+// genesis.
+func newTestBlockChain(t *testing.T, genesis *conf.Genesis) (*BlockChain, *block.Block) {
+	db := memdb.NewTestDB(t)
+	var genesisBlock *block.Block
+	if err := db.Update(context.Background(), func(tx kv.RwTx) (err error) {
+		genesisBlock, _, err = (&GenesisBlock{GenesisConfig: genesis}).Write(tx)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	engine := apoa.New(genesis.Config.Clique, db)
+	t.Cleanup(func() { engine.Close() })
+	chain, err := NewBlockChain(context.Background(), genesisBlock, engine, db, nil, genesis.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc := chain.(*BlockChain)
+	t.Cleanup(func() { bc.Close() })
+	return bc, genesisBlock
+}
+
+// recordingHook records the blocks the chain hooks are called with.
+type recordingHook struct {
+	calls []string
+}
+
+func (h *recordingHook) record(call string, b block.IBlock) error {
+	h.calls = append(h.calls, fmt.Sprintf("%s %d %x", call, b.Number64().Uint64(), b.Header().(*block.Header).Extra))
+	return nil
+}
+
+func (h *recordingHook) WriteBlock(tx kv.RwTx, b block.IBlock, receipts []*block.Receipt) error {
+	return h.record("write", b)
+}
+func (h *recordingHook) Apply(tx kv.RwTx, b block.IBlock) error  { return h.record("apply", b) }
+func (h *recordingHook) Revert(tx kv.RwTx, b block.IBlock) error { return h.record("revert", b) }
+func (h *recordingHook) Reset(tx kv.RwTx, b block.IBlock) error  { return h.record("reset", b) }
+
+// writeEmptyBlock writes an empty block on top of parent, the fork byte
+// telling blocks of the same height apart.
+func writeEmptyBlock(t *testing.T, bc *BlockChain, parent block.IBlock, fork byte, difficulty uint64) block.IBlock {
+	t.Helper()
+	header := &block.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(uint256.Int).AddUint64(parent.Number64(), 1),
+		GasLimit:   parent.GasLimit(),
+		Time:       parent.Time() + 1,
+		Difficulty: uint256.NewInt(difficulty),
+		Extra:      []byte{fork},
+		BaseFee:    uint256.NewInt(0),
+	}
+	b := block.NewBlock(header, nil)
+
+	tx, err := bc.ChainDB.BeginRo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := bc.WriteBlockWithState(b, nil, state.New(state.NewPlainStateReader(tx)), nil); err != nil {
+		t.Fatalf("writing block %d%c: %v", header.Number.Uint64(), fork, err)
+	}
+	return b
+}
+
+func TestChainHooksReorg(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("chain hooks test")))
+	bc, genesis := newTestBlockChain(t, DeveloperGenesisBlock(0, crypto.PubkeyToAddress(key.PublicKey)))
+	hook := new(recordingHook)
+	bc.AddChainHook(hook)
+
+	a1 := writeEmptyBlock(t, bc, genesis, 'a', 2)
+	writeEmptyBlock(t, bc, a1, 'a', 2)
+	// b2 has less difficulty than a2 and stays on the side, until b3 makes
+	// its chain the heaviest.
+	b2 := writeEmptyBlock(t, bc, a1, 'b', 1)
+	b3 := writeEmptyBlock(t, bc, b2, 'b', 2)
+	if head := bc.CurrentBlock().Hash(); head != b3.Hash() {
+		t.Fatalf("head %s, want block 3b %s", head, b3.Hash())
+	}
+	want := []string{
+		"write 1 61", "apply 1 61",
+		"write 2 61", "apply 2 61",
+		"write 2 62",
+		"write 3 62", "revert 2 61", "apply 2 62", "apply 3 62",
+	}
+	if !reflect.DeepEqual(hook.calls, want) {
+		t.Fatalf("hook calls on reorg:\n%v\nwant\n%v", hook.calls, want)
+	}
+
+	hook.calls = nil
+	if err := bc.Rewind(1, false); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"revert 3 62", "revert 2 62"}; !reflect.DeepEqual(hook.calls, want) {
+		t.Fatalf("hook calls on rewind: %v, want %v", hook.calls, want)
+	}
+}
@@ -78,6 +78,8 @@ type insertIterator struct {
 
 	index     int       // Current offset of the iterator
 	validator Validator // Validator to run if verification succeeds
+
+	verifyStart, verifyEnd time.Time // Time spent waiting for and running the verification of the last block
 }
 
 // newInsertIterator creates a new iterator based on the given blocks, which are
@@ -100,6 +102,9 @@ func (it *insertIterator) next() (block2.IBlock, error) {
 		it.index = len(it.chain)
 		return nil, nil
 	}
+	it.verifyStart = time.Now()
+	defer func() { it.verifyEnd = time.Now() }()
+
 	// Advance the iterator and wait for verification result if not yet done
 	it.index++
 	if len(it.errors) <= it.index {
@@ -0,0 +1,66 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/amazechain/amc/log"
+)
+
+// ErrImportPaused is returned by the writes refused while the import is paused.
+var ErrImportPaused = errors.New("block import is paused")
+
+// importPause holds the reason the block import is paused for, if it is.
+type importPause struct {
+	reason atomic.Pointer[string]
+}
+
+// PauseImport refuses every block written from now on with ErrImportPaused,
+// whether imported, mined or frozen, until ResumeImport is called. The blocks
+// already stored are still served. A write running when the import is paused
+// completes first.
+func (bc *BlockChain) PauseImport(reason string) {
+	if bc.pause.reason.Swap(&reason) == nil {
+		log.Warn("Paused block import", "reason", reason)
+	}
+}
+
+// ResumeImport accepts blocks again after PauseImport.
+func (bc *BlockChain) ResumeImport() {
+	if bc.pause.reason.Swap(nil) != nil {
+		log.Info("Resumed block import")
+	}
+}
+
+// ImportPaused returns why the block import is paused, or "" if it isn't.
+func (bc *BlockChain) ImportPaused() string {
+	if reason := bc.pause.reason.Load(); reason != nil {
+		return *reason
+	}
+	return ""
+}
+
+// checkPaused fails the writes refused by PauseImport.
+func (bc *BlockChain) checkPaused() error {
+	if reason := bc.ImportPaused(); reason != "" {
+		return fmt.Errorf("%w: %s", ErrImportPaused, reason)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/state"
+)
+
+// prefetch warms the state snapshot with the accounts and storage slots a
+// block is known to touch, the coinbase, the senders and recipients of its
+// transactions and their access lists, while the block executes. Execution
+// then finds them cached instead of waiting on random disk reads. The returned
+// function stops the prefetcher and waits for it.
+func (bc *BlockChain) prefetch(block block2.IBlock) func() {
+	ctx, cancel := context.WithCancel(bc.ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := bc.prefetchState(ctx, block); err != nil && ctx.Err() == nil {
+			log.Debug("State prefetch failed", "number", block.Number64().Uint64(), "err", err)
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (bc *BlockChain) prefetchState(ctx context.Context, block block2.IBlock) error {
+	tx, err := bc.ChainDB.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	reader := state.NewPlainStateReader(bc.snaps.Reader(tx, block.ParentHash()))
+
+	seen := make(map[types.Address]struct{})
+	account := func(addr types.Address) error {
+		if _, ok := seen[addr]; ok {
+			return nil
+		}
+		seen[addr] = struct{}{}
+		_, err := reader.ReadAccountData(addr)
+		return err
+	}
+	if err := account(block.Coinbase()); err != nil {
+		return err
+	}
+	for _, txn := range block.Transactions() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if from := txn.From(); from != nil {
+			if err := account(*from); err != nil {
+				return err
+			}
+		}
+		if to := txn.To(); to != nil {
+			if err := account(*to); err != nil {
+				return err
+			}
+		}
+		for _, tuple := range txn.AccessList() {
+			if err := account(tuple.Address); err != nil {
+				return err
+			}
+			if len(tuple.StorageKeys) == 0 {
+				continue
+			}
+			// Slots are keyed by the incarnation of their contract.
+			acc, err := reader.ReadAccountData(tuple.Address)
+			if err != nil {
+				return err
+			}
+			if acc == nil {
+				continue
+			}
+			for i := range tuple.StorageKeys {
+				if _, err := reader.ReadAccountStorage(tuple.Address, acc.Incarnation, &tuple.StorageKeys[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"time"
+
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+const (
+	// MinPruneDistance is the fewest recent blocks whose state history is
+	// kept, so that reorgs can be unwound and peers healing a snap sync
+	// started a while ago can still be served the changes since.
+	MinPruneDistance = 4096
+
+	// pruneInterval is how often the pruner catches up with the head.
+	pruneInterval = time.Minute
+	// pruneBatchBlocks is the number of blocks pruned in a single transaction,
+	// small enough not to hold up block imports for long.
+	pruneBatchBlocks = 256
+	// pruneBatchPause leaves room for other writers between two batches.
+	pruneBatchPause = 50 * time.Millisecond
+)
+
+// StartPruning prunes the state history older than the last distance blocks
+// in the background, while the chain keeps importing and serving. Every batch
+// commits with its progress, so an interrupted pass resumes where it stopped.
+// Once pruned, the state can't be read as of those blocks anymore.
+func (bc *BlockChain) StartPruning(distance uint64) {
+	if distance < MinPruneDistance {
+		log.Warn("State pruning distance too low, raising it", "distance", distance, "minimum", MinPruneDistance)
+		distance = MinPruneDistance
+	}
+	log.Info("Pruning state history", "keep", distance)
+	bc.loops.Add(1)
+	go bc.pruneLoop(distance)
+}
+
+func (bc *BlockChain) pruneLoop(distance uint64) {
+	defer bc.loops.Done()
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		if err := bc.pruneHistory(distance); err != nil && bc.ctx.Err() == nil {
+			log.Warn("Failed to prune state history", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-bc.ctx.Done():
+			return
+		}
+	}
+}
+
+// pruneHistory prunes batch after batch until the history is down to distance
+// blocks.
+func (bc *BlockChain) pruneHistory(distance uint64) error {
+	var (
+		start        = time.Now()
+		first, to    uint64
+		batches      int
+		finished     bool
+		lastReported = start
+	)
+	for !finished && bc.ctx.Err() == nil {
+		if batches > 0 {
+			time.Sleep(pruneBatchPause)
+		}
+		if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+			target, err := PruneTarget(tx, bc.CurrentBlock().Number64().Uint64(), distance)
+			if err != nil {
+				return err
+			}
+			from, err := rawdb.ReadStatePruneProgress(tx)
+			if err != nil {
+				return err
+			}
+			if from >= target {
+				finished = true
+				return nil
+			}
+			if batches == 0 {
+				first = from
+			}
+			to = from + pruneBatchBlocks
+			if to > target {
+				to = target
+			}
+			if err := state.PruneHistory(tx, from, to); err != nil {
+				return err
+			}
+			if err := rawdb.PruneDepositJournal(tx, to); err != nil {
+				return err
+			}
+			return rawdb.WriteStatePruneProgress(tx, to)
+		}); err != nil {
+			return err
+		}
+		if !finished {
+			batches++
+		}
+		if time.Since(lastReported) > 8*time.Second {
+			log.Info("Pruning state history", "from", first, "pruned", to, "elapsed", time.Since(start))
+			lastReported = time.Now()
+		}
+	}
+	if batches > 0 {
+		log.Debug("Pruned state history", "from", first, "to", to, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+// PruneTarget returns the block below which the state history can go. Nothing
+// is pruned while a snap sync is filling in the state, nor past the last
+// finalized block, which reorgs can't go below.
+func PruneTarget(tx kv.Tx, head, distance uint64) (uint64, error) {
+	if _, _, syncing, err := rawdb.ReadSnapSyncProgress(tx); err != nil || syncing {
+		return 0, err
+	}
+	if head <= distance {
+		return 0, nil
+	}
+	target := head - distance
+	if finalized := rawdb.ReadHeaderNumber(tx, rawdb.ReadFinalizedBlockHash(tx)); finalized != nil && *finalized+1 < target {
+		target = *finalized + 1
+	}
+	return target, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+func TestPruneTarget(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	tests := []struct {
+		head, distance, want uint64
+	}{
+		{head: 100, distance: 100, want: 0},
+		{head: 50, distance: 100, want: 0},
+		{head: 5000, distance: 4096, want: 904},
+	}
+	for _, tt := range tests {
+		if target, err := PruneTarget(tx, tt.head, tt.distance); err != nil || target != tt.want {
+			t.Errorf("head %d keeping %d: target %d, %v, want %d", tt.head, tt.distance, target, err, tt.want)
+		}
+	}
+
+	// The history above the last finalized block is kept for reorgs.
+	finalized := types.Hash{0xf1}
+	if err := rawdb.WriteHeaderNumber(tx, finalized, 500); err != nil {
+		t.Fatal(err)
+	}
+	if err := rawdb.WriteFinalizedBlockHash(tx, finalized); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := PruneTarget(tx, 5000, 4096); err != nil || target != 501 {
+		t.Errorf("target %d, %v, want the block after the finalized one", target, err)
+	}
+	if target, err := PruneTarget(tx, 4500, 4096); err != nil || target != 404 {
+		t.Errorf("target %d, %v, want 404 below the finalized block", target, err)
+	}
+
+	// A snap sync fills in the state without history, nothing is pruned.
+	if err := rawdb.WriteSnapSyncProgress(tx, 0, types.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := PruneTarget(tx, 5000, 4096); err != nil || target != 0 {
+		t.Errorf("target %d, %v during a snap sync, want 0", target, err)
+	}
+}
+
+func TestPruneHistoryProgress(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("prune test")))
+	bc, genesis := newTestBlockChain(t, DeveloperGenesisBlock(0, crypto.PubkeyToAddress(key.PublicKey)))
+	blocks := sealedTestBlocks(t, bc, key, genesis, 2*pruneBatchBlocks+10)
+	if _, err := bc.InsertBlocksWithoutState(blocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.SetPivot(blocks[len(blocks)-1].Hash()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The history is pruned in batches, up to the distance to the head.
+	progress := func() uint64 {
+		var from uint64
+		if err := bc.ChainDB.View(context.Background(), func(tx kv.Tx) (err error) {
+			from, err = rawdb.ReadStatePruneProgress(tx)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return from
+	}
+	if err := bc.pruneHistory(20); err != nil {
+		t.Fatal(err)
+	}
+	if from := progress(); from != uint64(len(blocks))-20 {
+		t.Errorf("history kept from #%d, want #%d", from, len(blocks)-20)
+	}
+	// Keeping more blocks later doesn't bring the pruned history back.
+	if err := bc.pruneHistory(100); err != nil {
+		t.Fatal(err)
+	}
+	if from := progress(); from != uint64(len(blocks))-20 {
+		t.Errorf("history kept from #%d after a second pass, want #%d", from, len(blocks)-20)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"time"
+
+	"github.com/amazechain/amc/common"
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/log"
+	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// followHeadInterval is how often a read-only chain looks for a new head.
+const followHeadInterval = time.Second
+
+// FollowHead keeps the head of a chain opened on a read-only database in line
+// with the one the node owning the database writes, in the background. Every
+// new head is posted as a ChainEvent, so that the subscriptions and filters
+// served from the read-only process keep moving.
+func (bc *BlockChain) FollowHead() {
+	bc.loops.Add(1)
+	go bc.followHeadLoop()
+}
+
+func (bc *BlockChain) followHeadLoop() {
+	defer bc.loops.Done()
+	ticker := time.NewTicker(followHeadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-bc.ctx.Done():
+			return
+		}
+		var head *block2.Block
+		if err := bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
+			head = rawdb.ReadCurrentBlock(tx)
+			return nil
+		}); err != nil {
+			if bc.ctx.Err() == nil {
+				log.Warn("Failed to read the chain head", "err", err)
+			}
+			continue
+		}
+		if head == nil || head.Hash() == bc.CurrentBlock().Hash() {
+			continue
+		}
+		bc.currentBlock.Store(head)
+		headBlockGauge.Set(head.Number64().Uint64())
+		event.GlobalEvent.Send(common.ChainEvent{Block: head, Hash: head.Hash()})
+	}
+}
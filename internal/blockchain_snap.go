@@ -0,0 +1,167 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"fmt"
+
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/consensus"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// InsertBlocksWithoutState imports a contiguous run of canonical blocks whose
+// state isn't available locally, as snap sync does below its pivot. Headers
+// and transaction roots are verified, but nothing is executed: no receipts,
+// logs or state changes are written and the head block doesn't move. The data
+// the chain hooks derive from the blocks comes with the pivot state instead.
+func (bc *BlockChain) InsertBlocksWithoutState(chain []block2.IBlock) (int, error) {
+	if len(chain) == 0 {
+		return 0, nil
+	}
+	if err := bc.checkPaused(); err != nil {
+		return 0, err
+	}
+	for i := 1; i < len(chain); i++ {
+		prev, cur := chain[i-1], chain[i]
+		if cur.Number64().Uint64() != prev.Number64().Uint64()+1 || cur.ParentHash() != prev.Hash() {
+			return 0, fmt.Errorf("non contiguous insert: item %d is #%d [%x..], item %d is #%d [%x..] (parent [%x..])",
+				i-1, prev.Number64().Uint64(), prev.Hash().Bytes()[:4], i, cur.Number64().Uint64(), cur.Hash().Bytes()[:4], cur.ParentHash().Bytes()[:4])
+		}
+	}
+
+	abort, results := bc.verifyHeaders(chain)
+	defer close(abort)
+	for i, block := range chain {
+		if err := <-results; err != nil {
+			return i, err
+		}
+		if hash := DeriveSha(transaction.Transactions(block.Transactions())); hash != block.TxHash() {
+			return i, fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, block.TxHash())
+		}
+	}
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		first := chain[0]
+		parent := first.Number64().Uint64() - 1
+		if canonical, err := rawdb.ReadCanonicalHash(tx, parent); err != nil || canonical != first.ParentHash() {
+			return consensus.ErrUnknownAncestor
+		}
+		td, err := rawdb.ReadTd(tx, first.ParentHash(), parent)
+		if err != nil {
+			return err
+		}
+		if td == nil {
+			return consensus.ErrUnknownAncestor
+		}
+		for _, block := range chain {
+			td = new(uint256.Int).Add(td, block.Difficulty())
+			if err := rawdb.WriteTd(tx, block.Hash(), block.Number64().Uint64(), td); err != nil {
+				return err
+			}
+			if err := rawdb.WriteBlock(tx, block.(*block2.Block)); err != nil {
+				return err
+			}
+			// Canonical hashes are written right away so that the engine
+			// finds the ancestors of the next batch by number.
+			if err := rawdb.WriteCanonicalHash(tx, block.Hash(), block.Number64().Uint64()); err != nil {
+				return err
+			}
+			rawdb.WriteTxLookupEntries(tx, block.(*block2.Block))
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return len(chain), nil
+}
+
+// SetPivot makes a block imported by InsertBlocksWithoutState the head of the
+// chain, once the state at that block has been written.
+func (bc *BlockChain) SetPivot(hash types.Hash) error {
+	number := bc.GetBlockNumber(hash)
+	if number == nil {
+		return fmt.Errorf("unknown pivot block %s", hash)
+	}
+	if bc.GetCanonicalHash(uint256.NewInt(*number)) != hash {
+		return fmt.Errorf("pivot block #%d %s is not canonical", *number, hash)
+	}
+	pivot := bc.GetBlock(hash, *number)
+	if pivot == nil {
+		return fmt.Errorf("unknown pivot block %s", hash)
+	}
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		if err := rawdb.WriteHeadHeaderHash(tx, hash); err != nil {
+			return err
+		}
+		if err := bc.writeHeadBlock(tx, pivot); err != nil {
+			return err
+		}
+		return bc.resetHooks(tx, pivot)
+	}); err != nil {
+		return err
+	}
+	bc.snaps.Reset(hash)
+	bc.updateFinality(pivot.Header())
+	log.Info("Moved head to snap sync pivot", "number", *number, "hash", hash)
+	return nil
+}
+
+// ResetToGenesis rewinds the chain to the genesis block, dropping the
+// canonical hashes and receipts above it. resetState runs in the same
+// transaction and must bring the state tables back to the genesis state.
+func (bc *BlockChain) ResetToGenesis(resetState func(tx kv.RwTx) error) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	genesis := bc.genesisBlock
+	defer bc.snaps.Reset(genesis.Hash())
+	return bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+		if err := rawdb.TruncateCanonicalHash(tx, 1, false); err != nil {
+			return err
+		}
+		if err := rawdb.TruncateReceipts(tx, 1); err != nil {
+			return err
+		}
+		if err := rawdb.WriteHeadHeaderHash(tx, genesis.Hash()); err != nil {
+			return err
+		}
+		if err := rawdb.WriteSafeBlockHash(tx, genesis.Hash()); err != nil {
+			return err
+		}
+		if err := rawdb.WriteFinalizedBlockHash(tx, genesis.Hash()); err != nil {
+			return err
+		}
+		if err := resetState(tx); err != nil {
+			return err
+		}
+		if err := bc.writeHeadBlock(tx, genesis); err != nil {
+			return err
+		}
+		return bc.resetHooks(tx, genesis)
+	})
+}
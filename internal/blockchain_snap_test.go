@@ -0,0 +1,185 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/consensus/misc"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// sealedTestBlocks returns n empty blocks on top of parent, sealed by key as
+// the only signer of the developer chain.
+func sealedTestBlocks(t *testing.T, bc *BlockChain, key *ecdsa.PrivateKey, parent block.IBlock, n int) []block.IBlock {
+	t.Helper()
+	var blocks []block.IBlock
+	for i := 0; i < n; i++ {
+		parentHeader := parent.Header().(*block.Header)
+		header := &block.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(uint256.Int).AddUint64(parent.Number64(), 1),
+			GasLimit:   parent.GasLimit(),
+			Time:       parent.Time() + 1,
+			Difficulty: uint256.NewInt(2),
+			Extra:      make([]byte, 32+65),
+			TxHash:     DeriveSha(transaction.Transactions(nil)),
+		}
+		baseFee, _ := uint256.FromBig(misc.CalcBaseFee(bc.Config(), parentHeader))
+		header.BaseFee = baseFee
+		sig, err := crypto.Sign(bc.engine.SealHash(header).Bytes(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(header.Extra[32:], sig)
+		parent = block.NewBlock(header, nil)
+		blocks = append(blocks, parent)
+	}
+	return blocks
+}
+
+// extraHex formats the extra-data of a block as the recording hook does.
+func extraHex(b block.IBlock) string {
+	return fmt.Sprintf("%x", b.Header().(*block.Header).Extra)
+}
+
+func TestInsertBlocksWithoutState(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("snap insert test")))
+	bc, genesis := newTestBlockChain(t, DeveloperGenesisBlock(0, crypto.PubkeyToAddress(key.PublicKey)))
+	hook := new(recordingHook)
+	bc.AddChainHook(hook)
+	blocks := sealedTestBlocks(t, bc, key, genesis, 4)
+
+	// Batches must be contiguous and follow the canonical chain.
+	if _, err := bc.InsertBlocksWithoutState([]block.IBlock{blocks[0], blocks[2]}); err == nil {
+		t.Error("inserted a gapped batch")
+	}
+	if _, err := bc.InsertBlocksWithoutState(blocks[2:]); err == nil {
+		t.Error("inserted a batch without its ancestors")
+	}
+	// Bodies must match their headers.
+	tampered := block.NewBlock(blocks[1].Header(), []*transaction.Transaction{
+		transaction.NewTransaction(0, types.Address{1}, &types.Address{2}, uint256.NewInt(1), 21000, uint256.NewInt(1), nil),
+	})
+	if n, err := bc.InsertBlocksWithoutState([]block.IBlock{blocks[0], tampered}); err == nil || n != 1 {
+		t.Errorf("inserted a block with a foreign body: %d, %v", n, err)
+	}
+
+	if n, err := bc.InsertBlocksWithoutState(blocks[:2]); err != nil || n != 2 {
+		t.Fatalf("inserted %d blocks: %v", n, err)
+	}
+	// The next batch finds its ancestors by number.
+	if n, err := bc.InsertBlocksWithoutState(blocks[2:]); err != nil || n != 2 {
+		t.Fatalf("inserted %d blocks: %v", n, err)
+	}
+	for i, b := range blocks {
+		if hash := bc.GetCanonicalHash(b.Number64()); hash != b.Hash() {
+			t.Errorf("canonical hash of block %d is %s, want %s", i+1, hash, b.Hash())
+		}
+		if td := bc.GetTd(b.Hash(), b.Number64()); td == nil || td.Uint64() != uint64(2*(i+1)) {
+			t.Errorf("td of block %d is %v, want %d", i+1, td, 2*(i+1))
+		}
+	}
+	// Nothing is executed, the head waits for the pivot state.
+	if head := bc.CurrentBlock().Hash(); head != genesis.Hash() {
+		t.Fatalf("head moved to %s", head)
+	}
+	if len(hook.calls) != 0 {
+		t.Fatalf("hooks called without state: %v", hook.calls)
+	}
+
+	if err := bc.SetPivot(types.Hash{1}); err == nil {
+		t.Error("moved the head to an unknown pivot")
+	}
+	pivot := blocks[2]
+	if err := bc.SetPivot(pivot.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if head := bc.CurrentBlock().Hash(); head != pivot.Hash() {
+		t.Fatalf("head %s, want the pivot %s", head, pivot.Hash())
+	}
+	if want := []string{"reset 3 " + extraHex(pivot)}; !reflect.DeepEqual(hook.calls, want) {
+		t.Errorf("hook calls at the pivot: %v, want %v", hook.calls, want)
+	}
+}
+
+func TestResetToGenesis(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("snap reset test")))
+	bc, genesis := newTestBlockChain(t, DeveloperGenesisBlock(0, crypto.PubkeyToAddress(key.PublicKey)))
+	blocks := sealedTestBlocks(t, bc, key, genesis, 3)
+	if _, err := bc.InsertBlocksWithoutState(blocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.SetPivot(blocks[2].Hash()); err != nil {
+		t.Fatal(err)
+	}
+	hook := new(recordingHook)
+	bc.AddChainHook(hook)
+
+	// A failing state reset leaves the chain alone.
+	errReset := errors.New("reset failed")
+	if err := bc.ResetToGenesis(func(kv.RwTx) error { return errReset }); err != errReset {
+		t.Fatalf("reset error %v, want %v", err, errReset)
+	}
+	if hash := bc.GetCanonicalHash(uint256.NewInt(3)); hash != blocks[2].Hash() {
+		t.Fatalf("canonical hash of block 3 is %s after a failed reset", hash)
+	}
+
+	var reset bool
+	if err := bc.ResetToGenesis(func(kv.RwTx) error { reset = true; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if !reset {
+		t.Error("state not reset")
+	}
+	if head := bc.CurrentBlock().Hash(); head != genesis.Hash() {
+		t.Errorf("head %s, want the genesis", head)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if hash := bc.GetCanonicalHash(uint256.NewInt(i)); hash != (types.Hash{}) {
+			t.Errorf("canonical hash of block %d left: %s", i, hash)
+		}
+	}
+	if err := bc.ChainDB.View(bc.ctx, func(tx kv.Tx) error {
+		if hash := rawdb.ReadFinalizedBlockHash(tx); hash != genesis.Hash() {
+			t.Errorf("finalized block %s, want the genesis", hash)
+		}
+		if hash := rawdb.ReadSafeBlockHash(tx); hash != genesis.Hash() {
+			t.Errorf("safe block %s, want the genesis", hash)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"reset 0 " + extraHex(genesis)}; !reflect.DeepEqual(hook.calls, want) {
+		t.Errorf("hook calls on reset: %v, want %v", hook.calls, want)
+	}
+	// The blocks can be synced again.
+	if _, err := bc.InsertBlocksWithoutState(blocks); err != nil {
+		t.Fatalf("syncing again: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"time"
+
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+const (
+	// txIndexInterval is how often the transaction index catches up with the head.
+	txIndexInterval = time.Minute
+	// txIndexBatchBlocks is the number of blocks indexed or unindexed in one
+	// transaction.
+	txIndexBatchBlocks = 1024
+	// txIndexBatchPause leaves room for the block imports between two batches.
+	txIndexBatchPause = 20 * time.Millisecond
+)
+
+// StartTxIndexer keeps the transaction lookup index, which serves the queries
+// of transactions and receipts by hash, limited to the last limit blocks in the
+// background, or to the whole chain with a limit of 0. The entries of older
+// blocks are deleted as the head moves on; raising the limit indexes the
+// blocks below the tail again. New blocks are always indexed as they are
+// imported.
+func (bc *BlockChain) StartTxIndexer(limit uint64) {
+	bc.loops.Add(1)
+	go bc.txIndexLoop(limit)
+}
+
+func (bc *BlockChain) txIndexLoop(limit uint64) {
+	defer bc.loops.Done()
+	ticker := time.NewTicker(txIndexInterval)
+	defer ticker.Stop()
+	for {
+		if bc.ImportPaused() == "" {
+			if err := bc.indexTransactions(limit); err != nil && bc.ctx.Err() == nil {
+				log.Warn("Failed to update the transaction index", "err", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-bc.ctx.Done():
+			return
+		}
+	}
+}
+
+// indexTransactions moves the tail of the transaction index, batch after
+// batch, to the first of the last limit blocks.
+func (bc *BlockChain) indexTransactions(limit uint64) error {
+	var target uint64
+	if head := bc.CurrentBlock().Number64().Uint64(); limit > 0 && head+1 > limit {
+		target = head + 1 - limit
+	}
+	var (
+		start     = time.Now()
+		from, to  uint64
+		batches   int
+		finished  bool
+		unindexed bool
+	)
+	for !finished && bc.ctx.Err() == nil {
+		if batches > 0 {
+			time.Sleep(txIndexBatchPause)
+		}
+		if err := bc.ChainDB.Update(bc.ctx, func(tx kv.RwTx) error {
+			tail, err := rawdb.ReadTxIndexTail(tx)
+			if err != nil {
+				return err
+			}
+			if batches == 0 {
+				from = tail
+			}
+			next := tail
+			switch {
+			case tail < target:
+				unindexed = true
+				next = tail + txIndexBatchBlocks
+				if next > target {
+					next = target
+				}
+				for number := tail; number < next; number++ {
+					if err := rawdb.DeleteCanonicalTxLookupEntries(tx, number); err != nil {
+						return err
+					}
+				}
+			case tail > target:
+				next = target
+				if tail-target > txIndexBatchBlocks {
+					next = tail - txIndexBatchBlocks
+				}
+				for number := next; number < tail; number++ {
+					if err := rawdb.WriteCanonicalTxLookupEntries(tx, number); err != nil {
+						return err
+					}
+				}
+			default:
+				finished = true
+				return nil
+			}
+			to = next
+			batches++
+			return rawdb.WriteTxIndexTail(tx, next)
+		}); err != nil {
+			return err
+		}
+	}
+	switch {
+	case batches == 0:
+	case unindexed:
+		log.Info("Unindexed transactions", "blocks", to-from, "tail", to, "elapsed", time.Since(start))
+	default:
+		log.Info("Indexed transactions", "blocks", from-to, "tail", to, "elapsed", time.Since(start))
+	}
+	return nil
+}
@@ -399,7 +399,7 @@ func FinalizeBlockExecution(engine consensus.Engine, header *block.Header,
 		return nil, nil, nil, err
 	}
 
-	if err := ibs.CommitBlock(cc.Rules(header.Number.Uint64()), stateWriter); err != nil {
+	if err := ibs.CommitBlock(cc.Rules(header.Number.Uint64(), header.Time), stateWriter); err != nil {
 		return nil, nil, nil, fmt.Errorf("committing block %d failed: %w", header.Number.Uint64(), err)
 	}
 
@@ -419,6 +419,6 @@ func FinalizeBlockExecution(engine consensus.Engine, header *block.Header,
 //	//	return SysCallContract(contract, data, *cc, ibs, header, engine)
 //	//})
 //	noop := state.NewNoopWriter()
-//	ibs.FinalizeTx(cc.Rules(header.Number.Uint64()), noop)
+//	ibs.FinalizeTx(cc.Rules(header.Number.Uint64(), header.Time), noop)
 //	return nil
 //}
@@ -0,0 +1,173 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package bloombits
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/internal/avm/common/bitutil"
+)
+
+// bloomIndexes represents the bit indexes inside the bloom filter that belong
+// to some key.
+type bloomIndexes [3]uint
+
+// calcBloomIndexes returns the bloom filter bit indexes belonging to the given key.
+func calcBloomIndexes(b []byte) bloomIndexes {
+	b = crypto.Keccak256(b)
+
+	var idxs bloomIndexes
+	for i := 0; i < len(idxs); i++ {
+		idxs[i] = (uint(b[2*i])<<8)&2047 + uint(b[2*i+1])
+	}
+	return idxs
+}
+
+// VectorFunc returns the bit vector of a bloom bit over a section, one bit per
+// block with the first block of the section in the highest bit.
+type VectorFunc func(bit uint, section uint64) ([]byte, error)
+
+// Matcher finds the blocks whose blooms may hold the logs a filter is after
+// from the rotated bloom bits of whole sections, reading a few bit vectors per
+// section instead of the header of every block.
+//
+// A filter is a list of rules which all have to match, each rule a list of
+// values of which any one has to. Rules with a nil value match anything and
+// are left out.
+type Matcher struct {
+	sectionSize uint64
+	filters     [][]bloomIndexes
+}
+
+// NewMatcher creates a matcher for the given filter over sections of
+// sectionSize blocks, a multiple of 8.
+func NewMatcher(sectionSize uint64, filters [][][]byte) *Matcher {
+	m := &Matcher{sectionSize: sectionSize}
+	for _, filter := range filters {
+		if len(filter) == 0 {
+			continue
+		}
+		bloomBits := make([]bloomIndexes, len(filter))
+		for i, clause := range filter {
+			if clause == nil {
+				bloomBits = nil
+				break
+			}
+			bloomBits[i] = calcBloomIndexes(clause)
+		}
+		if bloomBits != nil {
+			m.filters = append(m.filters, bloomBits)
+		}
+	}
+	return m
+}
+
+// Empty reports whether the filter matches every block, in which case the
+// bloom bits can't narrow down a search.
+func (m *Matcher) Empty() bool {
+	return len(m.filters) == 0
+}
+
+// Match returns in ascending order the numbers of the blocks from begin to end
+// whose blooms may match the filter. Every section of the range must have
+// been indexed; the candidates still have to be checked against their logs.
+func (m *Matcher) Match(ctx context.Context, begin, end uint64, vector VectorFunc) ([]uint64, error) {
+	var matches []uint64
+	for section := begin / m.sectionSize; section <= end/m.sectionSize; section++ {
+		if err := ctx.Err(); err != nil {
+			return matches, err
+		}
+		bits, err := m.matchSection(section, vector)
+		if err != nil {
+			return matches, err
+		}
+		first := section * m.sectionSize
+		for i, b := range bits {
+			if b == 0 {
+				continue
+			}
+			for j := 0; j < 8; j++ {
+				if b&(1<<(7-j)) == 0 {
+					continue
+				}
+				if number := first + uint64(8*i+j); number >= begin && number <= end {
+					matches = append(matches, number)
+				}
+			}
+		}
+	}
+	return matches, nil
+}
+
+// matchSection returns the bit vector of the blocks of a section matching
+// every rule: the OR over the values of a rule of the AND of their three bits.
+func (m *Matcher) matchSection(section uint64, vector VectorFunc) ([]byte, error) {
+	size := int(m.sectionSize / 8)
+	fetched := make(map[uint][]byte)
+	fetch := func(bit uint) ([]byte, error) {
+		if v, ok := fetched[bit]; ok {
+			return v, nil
+		}
+		v, err := vector(bit, section)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) != size {
+			return nil, fmt.Errorf("bloom bit %d of section %d has %d bytes, want %d", bit, section, len(v), size)
+		}
+		fetched[bit] = v
+		return v, nil
+	}
+
+	var result []byte
+	for _, rule := range m.filters {
+		matched := make([]byte, size)
+		for _, idxs := range rule {
+			value := make([]byte, size)
+			for i, bit := range idxs {
+				v, err := fetch(bit)
+				if err != nil {
+					return nil, err
+				}
+				if i == 0 {
+					copy(value, v)
+				} else {
+					bitutil.ANDBytes(value, value, v)
+				}
+			}
+			bitutil.ORBytes(matched, matched, value)
+		}
+		if result == nil {
+			result = matched
+		} else {
+			bitutil.ANDBytes(result, result, matched)
+		}
+		// No need to fetch the bits of the other rules if nothing is left.
+		if !bitutil.TestBytes(result) {
+			return result, nil
+		}
+	}
+	if result == nil {
+		result = make([]byte, size)
+		for i := range result {
+			result[i] = 0xff
+		}
+	}
+	return result, nil
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/amazechain/amc/internal/avm/rlp"
 	mvm_types "github.com/amazechain/amc/internal/avm/types"
 	"github.com/amazechain/amc/internal/consensus"
+	"github.com/amazechain/amc/internal/consensus/misc"
 	"github.com/amazechain/amc/log"
 	"github.com/amazechain/amc/modules/rpc/jsonrpc"
 	"github.com/amazechain/amc/modules/state"
@@ -227,21 +228,16 @@ func (c *Apoa) VerifyHeader(chain consensus.ChainHeaderReader, header block.IHea
 // method returns a quit channel to abort the operations and a results channel to
 // retrieve the async verifications (the order is that of the input slice).
 func (c *Apoa) VerifyHeaders(chain consensus.ChainHeaderReader, headers []block.IHeader, seals []bool) (chan<- struct{}, <-chan error) {
-	abort := make(chan struct{})
-	results := make(chan error, len(headers))
-
-	go func() {
-		for i, header := range headers {
-			err := c.verifyHeader(chain, header, headers[:i])
-
-			select {
-			case <-abort:
-				return
-			case results <- err:
-			}
+	// Recover the signers concurrently, the cache then serves the ordered checks
+	return consensus.VerifyPipeline(len(headers), func(i int) error {
+		if headers[i].Number64().IsZero() {
+			return nil
 		}
-	}()
-	return abort, results
+		_, err := ecrecover(headers[i], c.signatures)
+		return err
+	}, func(i int) error {
+		return c.verifyHeader(chain, headers[i], headers[:i])
+	})
 }
 
 // verifyHeader checks whether a header conforms to the consensus rules.The
@@ -343,18 +339,19 @@ func (c *Apoa) verifyCascadingFields(chain consensus.ChainHeaderReader, iHeader
 	if header.GasUsed > header.GasLimit {
 		return fmt.Errorf("invalid gasUsed: have %d, gasLimit %d", header.GasUsed, header.GasLimit)
 	}
-	//if !chain.Config().IsLondon(header.Number) {
-	//	// Verify BaseFee not present before EIP-1559 fork.
-	//	if header.BaseFee != nil {
-	//		return fmt.Errorf("invalid baseFee before fork: have %d, want <nil>", header.BaseFee)
-	//	}
-	//	if err := misc.VerifyGaslimit(parent.GasLimit, header.GasLimit); err != nil {
-	//		return err
-	//	}
-	//} else if err := misc.VerifyEip1559Header(chain.Config(), parent, header); err != nil {
-	//	// Verify the header's EIP-1559 attributes.
-	//	return err
-	//}
+	if !chain.Config().IsLondon(number) {
+		// Verify BaseFee not present before EIP-1559 fork.
+		if err := misc.VerifyPreEip1559Header(rawParent, header); err != nil {
+			return err
+		}
+	} else if err := misc.VerifyEip1559Header(chain.Config(), rawParent, header); err != nil {
+		// Verify the header's EIP-1559 attributes.
+		return err
+	}
+	// Verify the extension only holds fields of the forks already active.
+	if err := header.Extension.Verify(chain.Config().Rules(number, header.Time)); err != nil {
+		return err
+	}
 	// Retrieve the snapshot needed to verify this header and cache it
 	snap, err := c.snapshot(chain, number-1, header.ParentHash, parents)
 	if err != nil {
@@ -582,7 +579,7 @@ func (c *Apoa) Prepare(chain consensus.ChainHeaderReader, header block.IHeader)
 	rawHeader.MixDigest = types.Hash{}
 
 	// Ensure the timestamp has the correct delay
-	parent := chain.GetHeader(rawHeader.ParentHash, rawHeader.Number.Sub(rawHeader.Number, uint256.NewInt(1)))
+	parent := chain.GetHeader(rawHeader.ParentHash, new(uint256.Int).SubUint64(rawHeader.Number, 1))
 	if parent == nil {
 		return errors.New("unknown ancestor")
 	}
@@ -793,6 +790,11 @@ func encodeSigHeader(w io.Writer, iHeader block.IHeader) {
 	if header.BaseFee != nil {
 		enc = append(enc, header.BaseFee)
 	}
+	// The extension is sealed in its own encoding, appended after the
+	// fields of the legacy header.
+	if ext := iHeader.(*block.Header).Extension; len(ext) > 0 {
+		enc = append(enc, ext.Encode())
+	}
 	if err := rlp.Encode(w, enc); err != nil {
 		panic("can't encode: " + err.Error())
 	}
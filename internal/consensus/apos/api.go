@@ -36,7 +36,10 @@ import (
 	"github.com/amazechain/amc/modules/rpc/jsonrpc"
 )
 
-const maxSearchBlock = 1000
+const (
+	maxSearchBlock    = 1000
+	maxScheduleBlocks = 1024 // Upper bound of the proposer schedule returned at once
+)
 
 type MinedBlock struct {
 	BlockNumber *uint256.Int `json:"blockNumber"`
@@ -53,6 +56,51 @@ type VerifiedBlockResponse struct {
 	Total       *uint256.Int `json:"totalBlocks"`
 }
 
+// ValidatorStatus describes where a validator stands between deposit and exit.
+// Epoch boundaries are also given as block numbers for convenience.
+type ValidatorStatus struct {
+	Address         common.Address `json:"address"`
+	Status          deposit.Status `json:"status"`
+	CurrentEpoch    uint64         `json:"currentEpoch"`
+	DepositBlock    *uint64        `json:"depositBlock,omitempty"`
+	ActivationEpoch *uint64        `json:"activationEpoch,omitempty"`
+	ActivationBlock *uint64        `json:"activationBlock,omitempty"`
+	ExitEpoch       *uint64        `json:"exitEpoch,omitempty"`
+	ExitBlock       *uint64        `json:"exitBlock,omitempty"`
+	Amount          *uint256.Int   `json:"amount,omitempty"`
+}
+
+// ValidatorInfo is the public view of a member of the validator set.
+type ValidatorInfo struct {
+	Address common.Address `json:"address"`
+	Stake   *uint256.Int   `json:"stake"`
+	Status  deposit.Status `json:"status"`
+	// Pending is "join" or "leave" when a passed vote takes effect at the
+	// next epoch boundary.
+	Pending string `json:"pending,omitempty"`
+}
+
+// ProposerSlot is the in-turn proposer expected for an upcoming block.
+type ProposerSlot struct {
+	Number   uint64         `json:"number"`
+	Proposer common.Address `json:"proposer"`
+}
+
+// ProposalStats counts how a validator used its proposal slots.
+type ProposalStats struct {
+	InTurn    uint64 `json:"inTurn"`    // blocks sealed in its own slot
+	OutOfTurn uint64 `json:"outOfTurn"` // blocks sealed in another validator's slot
+	Missed    uint64 `json:"missed"`    // own slots sealed by someone else
+}
+
+// MissedProposals summarises the proposal slots of a range of blocks.
+type MissedProposals struct {
+	From       uint64                            `json:"from"`
+	To         uint64                            `json:"to"`
+	Missed     uint64                            `json:"missed"`
+	Validators map[common.Address]*ProposalStats `json:"validators"`
+}
+
 // API is a user facing jsonrpc API to allow controlling the signer and voting
 // mechanisms of the proof-of-authority scheme.
 type API struct {
@@ -491,3 +539,208 @@ func (api *API) GetAccountRewardUnpaid(address types.Address) (val *uint256.Int,
 	})
 	return
 }
+
+// ValidatorAPI is the public, read-only part of the apos namespace, meant for
+// explorers and staking dashboards.
+type ValidatorAPI struct {
+	chain consensus.ChainReader
+	apos  *APos
+}
+
+// headSnapshot returns the header and snapshot at the given block, the
+// current head if number is nil or latest.
+func (api *ValidatorAPI) headSnapshot(number *jsonrpc.BlockNumber) (block.IHeader, *Snapshot, error) {
+	var header block.IHeader
+	if number == nil || *number == jsonrpc.LatestBlockNumber {
+		header = api.chain.CurrentBlock().Header()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint256.NewInt(uint64(number.Int64())))
+	}
+	if header == nil {
+		return nil, nil, errUnknownBlock
+	}
+	snap, err := api.apos.snapshot(api.chain, header.Number64().Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, snap, nil
+}
+
+// GetValidators returns the validator set at the given block together with
+// the stake and status of every member. Validators voted in but waiting for
+// the next epoch are listed as pending joins.
+func (api *ValidatorAPI) GetValidators(number *jsonrpc.BlockNumber) ([]*ValidatorInfo, error) {
+	header, snap, err := api.headSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	addrs := snap.signers()
+	for addr, authorize := range snap.Scheduled {
+		if _, ok := snap.Signers[addr]; authorize && !ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	epoch := header.Number64().Uint64() / api.apos.config.Epoch
+
+	validators := make([]*ValidatorInfo, 0, len(addrs))
+	err = api.apos.db.View(context.Background(), func(tx kv.Tx) error {
+		for _, addr := range addrs {
+			info, err := validatorInfo(tx, addr, epoch)
+			if err != nil {
+				return err
+			}
+			if authorize, ok := snap.Scheduled[addr]; ok {
+				if authorize {
+					info.Pending = "join"
+				} else {
+					info.Pending = "leave"
+				}
+			}
+			validators = append(validators, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return validators, nil
+}
+
+// GetValidator returns the stake and status of a single validator at the head
+// of the chain.
+func (api *ValidatorAPI) GetValidator(address common.Address) (info *ValidatorInfo, err error) {
+	epoch := api.chain.CurrentBlock().Number64().Uint64() / api.apos.config.Epoch
+	err = api.apos.db.View(context.Background(), func(tx kv.Tx) error {
+		info, err = validatorInfo(tx, *mvm_types.ToAmcAddress(&address), epoch)
+		return err
+	})
+	return info, err
+}
+
+// validatorInfo collects the stake and lifecycle status of a validator.
+func validatorInfo(tx kv.Tx, addr types.Address, epoch uint64) (*ValidatorInfo, error) {
+	status, l, err := deposit.ValidatorStatus(tx, addr, epoch)
+	if err != nil {
+		return nil, err
+	}
+	info := &ValidatorInfo{Address: *mvm_types.FromAmcAddress(&addr), Status: status, Stake: uint256.NewInt(0)}
+	if l != nil {
+		info.Stake = l.Amount
+	} else if _, amount, err := rawdb.GetDeposit(tx, addr); err == nil && amount != nil {
+		info.Stake = amount
+	}
+	return info, nil
+}
+
+// GetProposerSchedule returns the in-turn proposers of the next count blocks.
+// Validator set changes already scheduled for the next epoch boundary are
+// taken into account, votes that didn't pass yet are not.
+func (api *ValidatorAPI) GetProposerSchedule(count hexutil.Uint64) ([]*ProposerSlot, error) {
+	if count == 0 || count > maxScheduleBlocks {
+		return nil, fmt.Errorf("count must be between 1 and %d", maxScheduleBlocks)
+	}
+	header, snap, err := api.headSnapshot(nil)
+	if err != nil {
+		return nil, err
+	}
+	head := header.Number64().Uint64()
+	boundary := (head/api.apos.config.Epoch + 1) * api.apos.config.Epoch
+
+	next := snap
+	if len(snap.Scheduled) > 0 {
+		next = snap.copy()
+		next.Signers = make(map[types.Address]struct{})
+		for _, signer := range snap.nextSigners() {
+			next.Signers[signer] = struct{}{}
+		}
+	}
+	slots := make([]*ProposerSlot, 0, count)
+	for number := head + 1; number <= head+uint64(count); number++ {
+		s := snap
+		if number > boundary {
+			s = next
+		}
+		proposer := s.proposer(number)
+		slots = append(slots, &ProposerSlot{Number: number, Proposer: *mvm_types.FromAmcAddress(&proposer)})
+	}
+	return slots, nil
+}
+
+// GetMissedProposals compares the in-turn proposer of each of the last count
+// blocks with the validator that actually sealed it.
+func (api *ValidatorAPI) GetMissedProposals(count hexutil.Uint64) (*MissedProposals, error) {
+	if count == 0 || count > maxSearchBlock {
+		return nil, fmt.Errorf("count must be between 1 and %d", maxSearchBlock)
+	}
+	head := api.chain.CurrentBlock().Number64().Uint64()
+	from := uint64(1)
+	if head > uint64(count) {
+		from = head - uint64(count) + 1
+	}
+	resp := &MissedProposals{From: from, To: head, Validators: make(map[common.Address]*ProposalStats)}
+	stats := func(addr types.Address) *ProposalStats {
+		key := *mvm_types.FromAmcAddress(&addr)
+		if resp.Validators[key] == nil {
+			resp.Validators[key] = new(ProposalStats)
+		}
+		return resp.Validators[key]
+	}
+	// Walk upwards so that every snapshot builds on the previous one
+	for number := from; number <= head; number++ {
+		header := api.chain.GetHeaderByNumber(uint256.NewInt(number))
+		if header == nil {
+			return nil, fmt.Errorf("missing block %d", number)
+		}
+		snap, err := api.apos.snapshot(api.chain, number-1, header.(*block.Header).ParentHash, nil)
+		if err != nil {
+			return nil, err
+		}
+		sealer, err := api.apos.Author(header)
+		if err != nil {
+			return nil, err
+		}
+		if expected := snap.proposer(number); expected == sealer {
+			stats(sealer).InTurn++
+		} else {
+			stats(sealer).OutOfTurn++
+			stats(expected).Missed++
+			resp.Missed++
+		}
+	}
+	return resp, nil
+}
+
+// GetValidatorStatus returns the lifecycle status of a validator at the head
+// of the chain: pending activation, active, exiting or exited.
+func (api *ValidatorAPI) GetValidatorStatus(address common.Address) (*ValidatorStatus, error) {
+	addr := *mvm_types.ToAmcAddress(&address)
+	epochLength := api.apos.config.Epoch
+	epoch := api.chain.CurrentBlock().Number64().Uint64() / epochLength
+
+	resp := &ValidatorStatus{Address: address, CurrentEpoch: epoch}
+	err := api.apos.db.View(context.Background(), func(tx kv.Tx) error {
+		status, l, err := deposit.ValidatorStatus(tx, addr, epoch)
+		if err != nil {
+			return err
+		}
+		resp.Status = status
+		if l == nil {
+			if status == deposit.StatusActive {
+				_, resp.Amount, err = rawdb.GetDeposit(tx, addr)
+			}
+			return err
+		}
+		activationBlock := l.ActivationEpoch * epochLength
+		resp.DepositBlock, resp.ActivationEpoch, resp.ActivationBlock = &l.DepositBlock, &l.ActivationEpoch, &activationBlock
+		if l.ExitEpoch != 0 {
+			exitBlock := l.ExitEpoch * epochLength
+			resp.ExitEpoch, resp.ExitBlock = &l.ExitEpoch, &exitBlock
+		}
+		resp.Amount = l.Amount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package apos
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/contracts/deposit"
+	"github.com/amazechain/amc/internal/avm/common"
+	mvm_types "github.com/amazechain/amc/internal/avm/types"
+	"github.com/amazechain/amc/internal/consensus"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// apiTestChain serves the headers of a chain from memory.
+type apiTestChain struct {
+	consensus.ChainReader
+	headers []*block.Header
+}
+
+func (c *apiTestChain) CurrentBlock() block.IBlock {
+	return block.NewBlock(c.headers[len(c.headers)-1], nil)
+}
+
+func (c *apiTestChain) GetHeaderByNumber(number *uint256.Int) block.IHeader {
+	if n := number.Uint64(); n < uint64(len(c.headers)) {
+		return c.headers[n]
+	}
+	return nil
+}
+
+func (c *apiTestChain) GetHeader(hash types.Hash, number *uint256.Int) block.IHeader {
+	if header := c.GetHeaderByNumber(number); header != nil && header.Hash() == hash {
+		return header
+	}
+	return nil
+}
+
+// newAPITestChain returns a chain starting with the given validators, whose
+// blocks are sealed by the keys in sealers.
+func newAPITestChain(t *testing.T, validators []types.Address, sealers []*ecdsa.PrivateKey) *apiTestChain {
+	genesis := &block.Header{
+		Difficulty: uint256.NewInt(1),
+		Number:     uint256.NewInt(0),
+		BaseFee:    uint256.NewInt(0),
+		Extra:      make([]byte, extraVanity, extraVanity+len(validators)*types.AddressLength+extraSeal),
+	}
+	for _, validator := range validators {
+		genesis.Extra = append(genesis.Extra, validator[:]...)
+	}
+	genesis.Extra = append(genesis.Extra, make([]byte, extraSeal)...)
+
+	chain := &apiTestChain{headers: []*block.Header{genesis}}
+	for i, key := range sealers {
+		header := &block.Header{
+			ParentHash: chain.headers[i].Hash(),
+			Difficulty: uint256.NewInt(diffNoTurn.Uint64()),
+			Number:     uint256.NewInt(uint64(i + 1)),
+			BaseFee:    uint256.NewInt(0),
+			Time:       uint64(i + 1),
+			Extra:      make([]byte, extraVanity+extraSeal),
+		}
+		sig, err := crypto.Sign(SealHash(header).Bytes(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(header.Extra[extraVanity:], sig)
+		chain.headers = append(chain.headers, header)
+	}
+	return chain
+}
+
+func newAPITestEngine(t *testing.T, config *params.APosConfig) *APos {
+	return New(config, memdb.NewTestDB(t), params.TestChainConfig).(*APos)
+}
+
+func TestGetMissedProposals(t *testing.T) {
+	keys, addrs := snapshotTestKeys(3)
+	a, b, c := keys[0], keys[1], keys[2]
+
+	// Block n is a's turn when n%3 is 0, b's at 1 and c's at 2. b seals block
+	// 3 in place of a, who seals block 4 in place of b.
+	chain := newAPITestChain(t, addrs, []*ecdsa.PrivateKey{b, c, b, a, c, a})
+	api := &ValidatorAPI{chain: chain, apos: newAPITestEngine(t, &params.APosConfig{Period: 1, Epoch: 100})}
+
+	key := func(addr types.Address) common.Address { return *mvm_types.FromAmcAddress(&addr) }
+	tests := []struct {
+		count    hexutil.Uint64
+		from     uint64
+		missed   uint64
+		stats    map[common.Address]ProposalStats
+		mismatch bool
+	}{
+		{
+			count: 6, from: 1, missed: 2,
+			stats: map[common.Address]ProposalStats{
+				key(addrs[0]): {InTurn: 1, OutOfTurn: 1, Missed: 1},
+				key(addrs[1]): {InTurn: 1, OutOfTurn: 1, Missed: 1},
+				key(addrs[2]): {InTurn: 2},
+			},
+		},
+		{
+			count: 3, from: 4, missed: 1,
+			stats: map[common.Address]ProposalStats{
+				key(addrs[0]): {InTurn: 1, OutOfTurn: 1},
+				key(addrs[1]): {Missed: 1},
+				key(addrs[2]): {InTurn: 1},
+			},
+		},
+		// Counts beyond the chain are cut at the first block.
+		{
+			count: 100, from: 1, missed: 2,
+			stats: map[common.Address]ProposalStats{
+				key(addrs[0]): {InTurn: 1, OutOfTurn: 1, Missed: 1},
+				key(addrs[1]): {InTurn: 1, OutOfTurn: 1, Missed: 1},
+				key(addrs[2]): {InTurn: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		resp, err := api.GetMissedProposals(tt.count)
+		if err != nil {
+			t.Fatalf("count %d: %v", tt.count, err)
+		}
+		if resp.From != tt.from || resp.To != 6 || resp.Missed != tt.missed {
+			t.Errorf("count %d: blocks %d-%d with %d missed, want %d-6 with %d", tt.count, resp.From, resp.To, resp.Missed, tt.from, tt.missed)
+		}
+		if len(resp.Validators) != len(tt.stats) {
+			t.Errorf("count %d: stats of %d validators, want %d", tt.count, len(resp.Validators), len(tt.stats))
+		}
+		for addr, want := range tt.stats {
+			if have := resp.Validators[addr]; have == nil || *have != want {
+				t.Errorf("count %d: stats of %x are %+v, want %+v", tt.count, addr, have, want)
+			}
+		}
+	}
+	for _, count := range []hexutil.Uint64{0, maxSearchBlock + 1} {
+		if _, err := api.GetMissedProposals(count); err == nil {
+			t.Errorf("count %d accepted", count)
+		}
+	}
+}
+
+// newScheduledTestAPI returns the API of a chain at block 6 of 4 block
+// epochs, run by the first three of four validators with the given changes
+// scheduled for block 8.
+func newScheduledTestAPI(t *testing.T, scheduled map[types.Address]bool) *ValidatorAPI {
+	keys, addrs := snapshotTestKeys(4)
+	config := &params.APosConfig{Period: 1, Epoch: 4, EpochRotationBlock: big.NewInt(0)}
+	chain := newAPITestChain(t, addrs[:3], []*ecdsa.PrivateKey{keys[1], keys[2], keys[0], keys[1], keys[2], keys[0]})
+	engine := newAPITestEngine(t, config)
+
+	head := chain.headers[len(chain.headers)-1]
+	snap := newSnapshot(engine.config, engine.signatures, 6, head.Hash(), addrs[:3])
+	if scheduled != nil {
+		snap.Scheduled = scheduled
+	}
+	engine.recents.Add(snap.Hash, snap)
+	return &ValidatorAPI{chain: chain, apos: engine}
+}
+
+func TestGetProposerSchedule(t *testing.T) {
+	_, addrs := snapshotTestKeys(4)
+	a, b, c, d := addrs[0], addrs[1], addrs[2], addrs[3]
+	api := newScheduledTestAPI(t, nil)
+	scheduledAPI := newScheduledTestAPI(t, map[types.Address]bool{c: false, d: true})
+
+	// Each epoch opens one validator further, the set voted for takes over
+	// after block 8.
+	tests := []struct {
+		api  *ValidatorAPI
+		want []types.Address
+	}{
+		{api: api, want: []types.Address{b, c, a, b, c, a, b}},
+		{api: scheduledAPI, want: []types.Address{b, c, a, b, d, a, b}},
+	}
+	for i, tt := range tests {
+		slots, err := tt.api.GetProposerSchedule(hexutil.Uint64(len(tt.want)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(slots) != len(tt.want) {
+			t.Fatalf("test %d: %d slots, want %d", i, len(slots), len(tt.want))
+		}
+		for j, slot := range slots {
+			if want := *mvm_types.FromAmcAddress(&tt.want[j]); slot.Number != uint64(7+j) || slot.Proposer != want {
+				t.Errorf("test %d: slot %d is %x at block %d, want %x at %d", i, j, slot.Proposer, slot.Number, want, 7+j)
+			}
+		}
+	}
+	for _, count := range []hexutil.Uint64{0, maxScheduleBlocks + 1} {
+		if _, err := api.GetProposerSchedule(count); err == nil {
+			t.Errorf("count %d accepted", count)
+		}
+	}
+}
+
+func TestGetValidators(t *testing.T) {
+	_, addrs := snapshotTestKeys(4)
+	api := newScheduledTestAPI(t, map[types.Address]bool{addrs[2]: false, addrs[3]: true})
+
+	// b exits at epoch 5 with a stake of 100.
+	lifecycle := make([]byte, 3*8+types.PublicKeyLength)
+	binary.BigEndian.PutUint64(lifecycle[16:], 5)
+	lifecycle = append(lifecycle, 100)
+	if err := api.apos.db.Update(context.Background(), func(tx kv.RwTx) error {
+		return rawdb.PutValidatorLifecycle(tx, addrs[1], lifecycle)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	validators, err := api.GetValidators(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ValidatorInfo{
+		{Address: *mvm_types.FromAmcAddress(&addrs[0]), Stake: uint256.NewInt(0), Status: deposit.StatusUnknown},
+		{Address: *mvm_types.FromAmcAddress(&addrs[1]), Stake: uint256.NewInt(100), Status: deposit.StatusExiting},
+		{Address: *mvm_types.FromAmcAddress(&addrs[2]), Stake: uint256.NewInt(0), Status: deposit.StatusUnknown, Pending: "leave"},
+		{Address: *mvm_types.FromAmcAddress(&addrs[3]), Stake: uint256.NewInt(0), Status: deposit.StatusUnknown, Pending: "join"},
+	}
+	if len(validators) != len(want) {
+		t.Fatalf("%d validators, want %d", len(validators), len(want))
+	}
+	for i, have := range validators {
+		if have.Address != want[i].Address || have.Stake.Cmp(want[i].Stake) != 0 || have.Status != want[i].Status || have.Pending != want[i].Pending {
+			t.Errorf("validator %d is %+v, want %+v", i, *have, want[i])
+		}
+	}
+
+	info, err := api.GetValidator(*mvm_types.FromAmcAddress(&addrs[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Stake.Uint64() != 100 || info.Status != deposit.StatusExiting || info.Pending != "" {
+		t.Errorf("validator %+v, want an exiting one with a stake of 100", *info)
+	}
+}
@@ -193,6 +193,7 @@ type APos struct {
 	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
 
 	proposals map[types.Address]bool // Current list of proposals we are pushing
+	evidence  *evidencePool          // Double-sign evidence waiting for inclusion
 
 	signer types.Address // Ethereum address of the signing key
 	signFn SignerFn      // Signer function to authorize hashes with
@@ -223,6 +224,7 @@ func New(config *params.APosConfig, db kv.RwDB, chainConfig *params.ChainConfig)
 		recents:     recents,
 		signatures:  signatures,
 		proposals:   make(map[types.Address]bool),
+		evidence:    newEvidencePool(),
 	}
 }
 
@@ -245,21 +247,16 @@ func (c *APos) VerifyHeader(chain consensus.ChainHeaderReader, header block.IHea
 // method returns a quit channel to abort the operations and a results channel to
 // retrieve the async verifications (the order is that of the input slice).
 func (c *APos) VerifyHeaders(chain consensus.ChainHeaderReader, headers []block.IHeader, seals []bool) (chan<- struct{}, <-chan error) {
-	abort := make(chan struct{})
-	results := make(chan error, len(headers))
-
-	go func() {
-		for i, header := range headers {
-			err := c.verifyHeader(chain, header, headers[:i])
-
-			select {
-			case <-abort:
-				return
-			case results <- err:
-			}
+	// Recover the signers concurrently, the cache then serves the ordered checks
+	return consensus.VerifyPipeline(len(headers), func(i int) error {
+		if headers[i].Number64().IsZero() {
+			return nil
 		}
-	}()
-	return abort, results
+		_, err := ecrecover(headers[i], c.signatures)
+		return err
+	}, func(i int) error {
+		return c.verifyHeader(chain, headers[i], headers[:i])
+	})
 }
 
 // verifyHeader checks whether a header conforms to the consensus rules.The
@@ -296,10 +293,16 @@ func (c *APos) verifyHeader(chain consensus.ChainHeaderReader, iHeader block.IHe
 	if len(header.Extra) < extraVanity+extraSeal {
 		return errMissingSignature
 	}
-	// Ensure that the extra-data contains a signer list on checkpoint, but none otherwise
+	// Ensure that the extra-data contains a signer list on checkpoint, but none
+	// otherwise, save for double-sign evidence once slashing is enabled
 	signersBytes := len(header.Extra) - extraVanity - extraSeal
 	if !checkpoint && signersBytes != 0 {
-		return errExtraSigners
+		if !c.config.IsSlashing(number) {
+			return errExtraSigners
+		}
+		if err := c.verifyEvidences(header); err != nil {
+			return err
+		}
 	}
 	if checkpoint && signersBytes%types.AddressLength != 0 {
 		return errInvalidCheckpointSigners
@@ -363,25 +366,28 @@ func (c *APos) verifyCascadingFields(chain consensus.ChainHeaderReader, iHeader
 	}
 	if !chain.Config().IsLondon(header.Number.Uint64()) {
 		// Verify BaseFee not present before EIP-1559 fork.
-		if header.BaseFee != nil {
-			return fmt.Errorf("invalid baseFee before fork: have %d, want <nil>", header.BaseFee)
-		}
-		if err := misc.VerifyGaslimit(parent.(*block.Header).GasLimit, header.GasLimit); err != nil {
+		if err := misc.VerifyPreEip1559Header(parent.(*block.Header), header); err != nil {
 			return err
 		}
 	} else if err := misc.VerifyEip1559Header(chain.Config(), parent.(*block.Header), header); err != nil {
 		// Verify the header's EIP-1559 attributes.
 		return err
 	}
+	// Verify the extension only holds fields of the forks already active.
+	if err := header.Extension.Verify(chain.Config().Rules(number, header.Time)); err != nil {
+		return err
+	}
 	// Retrieve the snapshot needed to verify this header and cache it
 	snap, err := c.snapshot(chain, number-1, header.ParentHash, parents)
 	if err != nil {
 		return err
 	}
-	// If the block is a checkpoint block, verify the signer list
+	// If the block is a checkpoint block, verify the signer list scheduled for
+	// the upcoming epoch
 	if number%c.config.Epoch == 0 {
-		signers := make([]byte, len(snap.Signers)*types.AddressLength)
-		for i, signer := range snap.signers() {
+		next := snap.nextSigners()
+		signers := make([]byte, len(next)*types.AddressLength)
+		for i, signer := range next {
 			copy(signers[i*types.AddressLength:], signer[:])
 		}
 		extraSuffix := len(header.Extra) - extraSeal
@@ -540,6 +546,14 @@ func (c *APos) verifySeal(snap *Snapshot, h block.IHeader, parents []block.IHead
 			return errWrongDifficulty
 		}
 	}
+	// The seal is valid, check that the signer didn't seal a sibling too
+	if c.config.IsSlashing(number) {
+		if ev := c.evidence.observe(header, signer); ev != nil {
+			if err := c.reportEvidence(ev); err != nil {
+				log.Debug("Failed to report double-sign evidence", "err", err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -591,9 +605,17 @@ func (c *APos) Prepare(chain consensus.ChainHeaderReader, header block.IHeader)
 	rawHeader.Extra = rawHeader.Extra[:extraVanity]
 
 	if number%c.config.Epoch == 0 {
-		for _, signer := range snap.signers() {
+		for _, signer := range snap.nextSigners() {
 			rawHeader.Extra = append(rawHeader.Extra, signer[:]...)
 		}
+	} else if c.config.IsSlashing(number) {
+		if evs := c.evidence.pick(number, c.config.Epoch); len(evs) > 0 {
+			data, err := encodeEvidences(evs)
+			if err != nil {
+				return err
+			}
+			rawHeader.Extra = append(rawHeader.Extra, data...)
+		}
 	}
 	rawHeader.Extra = append(rawHeader.Extra, make([]byte, extraSeal)...)
 
@@ -655,6 +677,11 @@ func (c *APos) Finalize(chain consensus.ChainHeaderReader, header block.IHeader,
 	// No block rewards in PoA, so the state remains as is and uncles are dropped
 	//chain.Config().IsEIP158(header.Number)
 
+	if c.config.IsSlashing(header.Number64().Uint64()) && header.Number64().Uint64()%c.config.Epoch != 0 {
+		if err := c.slash(header.(*block.Header), state); err != nil {
+			return nil, nil, err
+		}
+	}
 	rewards, unpayMap, err := doReward(c.chainConfig, state, header.(*block.Header), chain)
 	if err != nil {
 		return nil, nil, err
@@ -838,6 +865,9 @@ func (c *APos) APIs(chain consensus.ChainReader) []jsonrpc.API {
 		Namespace:     "apos",
 		Service:       &API{chain: chain, apos: c},
 		Authenticated: true,
+	}, {
+		Namespace: "apos",
+		Service:   &ValidatorAPI{chain: chain, apos: c},
 	}}
 }
 
@@ -881,6 +911,11 @@ func encodeSigHeader(w io.Writer, iHeader block.IHeader) {
 	if header.BaseFee != nil {
 		enc = append(enc, header.BaseFee)
 	}
+	// The extension is sealed in its own encoding, appended after the
+	// fields of the legacy header.
+	if ext := iHeader.(*block.Header).Extension; len(ext) > 0 {
+		enc = append(enc, ext.Encode())
+	}
 	if err := rlp.Encode(w, enc); err != nil {
 		panic("can't encode: " + err.Error())
 	}
@@ -0,0 +1,358 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package apos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	amcCommon "github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/log"
+	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/holiman/uint256"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	inmemoryProposals   = 4096 // Number of recent (height, signer) pairs tracked for equivocation
+	maxPendingEvidence  = 64   // Number of unincluded evidences kept in memory
+	maxEvidencePerBlock = 4    // Number of evidences a single header may carry
+)
+
+var (
+	// errInvalidEvidence is returned if a double-sign evidence doesn't prove
+	// two different headers were signed by the same signer at the same height.
+	errInvalidEvidence = errors.New("invalid double-sign evidence")
+
+	// errStaleEvidence is returned if the evidence is older than one epoch or
+	// refers to a height above the including block.
+	errStaleEvidence = errors.New("stale double-sign evidence")
+
+	// errTooManyEvidences is returned if a header carries more evidences than
+	// allowed.
+	errTooManyEvidences = errors.New("too many double-sign evidences")
+)
+
+// slashingAddress is the system account whose storage records the offenses
+// that have already been punished, so included evidence only slashes once.
+var slashingAddress = types.HexToAddress("0x000000000000000000000000000000000000f100")
+
+// Evidence proves that a signer sealed two different headers at the same
+// height. The headers are ordered by hash so that every node encodes the same
+// offense identically.
+type Evidence struct {
+	First  *block.Header
+	Second *block.Header
+}
+
+// evidenceRLP is the wire format of an Evidence, holding the marshalled headers.
+type evidenceRLP struct {
+	First  []byte
+	Second []byte
+}
+
+// newEvidence orders the two conflicting headers into an evidence.
+func newEvidence(a, b *block.Header) *Evidence {
+	ha, hb := a.Hash(), b.Hash()
+	if bytes.Compare(ha[:], hb[:]) > 0 {
+		a, b = b, a
+	}
+	return &Evidence{First: a, Second: b}
+}
+
+// Number returns the height at which the signer equivocated.
+func (e *Evidence) Number() uint64 {
+	return e.First.Number.Uint64()
+}
+
+// Hash returns the identifier of the evidence.
+func (e *Evidence) Hash() types.Hash {
+	h1, h2 := e.First.Hash(), e.Second.Hash()
+	return types.BytesToHash(crypto.Keccak256(h1[:], h2[:]))
+}
+
+// offender verifies the evidence and returns the equivocating signer.
+func (e *Evidence) offender(sigcache *lru.ARCCache) (types.Address, error) {
+	if e.First == nil || e.Second == nil || e.First.Number == nil || e.Second.Number == nil {
+		return types.Address{}, errInvalidEvidence
+	}
+	h1, h2 := e.First.Hash(), e.Second.Hash()
+	if e.First.Number.Cmp(e.Second.Number) != 0 || bytes.Compare(h1[:], h2[:]) >= 0 {
+		return types.Address{}, errInvalidEvidence
+	}
+	first, err := ecrecover(e.First, sigcache)
+	if err != nil {
+		return types.Address{}, err
+	}
+	second, err := ecrecover(e.Second, sigcache)
+	if err != nil {
+		return types.Address{}, err
+	}
+	if first != second {
+		return types.Address{}, errInvalidEvidence
+	}
+	return first, nil
+}
+
+// slashingKey is the storage slot marking the offense as punished.
+func (e *Evidence) slashingKey(offender types.Address) types.Hash {
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], e.Number())
+	return types.BytesToHash(crypto.Keccak256(offender[:], number[:]))
+}
+
+// encodeEvidences serialises a list of evidences.
+func encodeEvidences(evs []*Evidence) ([]byte, error) {
+	enc := make([]evidenceRLP, len(evs))
+	for i, ev := range evs {
+		first, err := ev.First.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		second, err := ev.Second.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		enc[i] = evidenceRLP{First: first, Second: second}
+	}
+	return rlp.EncodeToBytes(enc)
+}
+
+// decodeEvidences parses a list of evidences encoded with encodeEvidences.
+func decodeEvidences(data []byte) ([]*Evidence, error) {
+	var enc []evidenceRLP
+	if err := rlp.DecodeBytes(data, &enc); err != nil {
+		return nil, err
+	}
+	evs := make([]*Evidence, len(enc))
+	for i, e := range enc {
+		ev := &Evidence{First: new(block.Header), Second: new(block.Header)}
+		if err := ev.First.Unmarshal(e.First); err != nil {
+			return nil, err
+		}
+		if err := ev.Second.Unmarshal(e.Second); err != nil {
+			return nil, err
+		}
+		evs[i] = ev
+	}
+	return evs, nil
+}
+
+// headerEvidences returns the evidences carried in the extra-data of a
+// non-checkpoint header.
+func headerEvidences(header *block.Header) ([]*Evidence, error) {
+	if len(header.Extra) <= extraVanity+extraSeal {
+		return nil, nil
+	}
+	evs, err := decodeEvidences(header.Extra[extraVanity : len(header.Extra)-extraSeal])
+	if err != nil {
+		return nil, errInvalidEvidence
+	}
+	if len(evs) > maxEvidencePerBlock {
+		return nil, errTooManyEvidences
+	}
+	return evs, nil
+}
+
+// proposalKey identifies a sealing slot of a signer.
+type proposalKey struct {
+	number uint64
+	signer types.Address
+}
+
+// evidencePool detects equivocations among verified headers and keeps the
+// resulting evidence until a block includes it.
+type evidencePool struct {
+	proposals *lru.ARCCache // proposalKey -> first header seen for the slot
+
+	lock    sync.Mutex
+	pending map[types.Hash]*Evidence
+}
+
+func newEvidencePool() *evidencePool {
+	proposals, _ := lru.NewARC(inmemoryProposals)
+	return &evidencePool{
+		proposals: proposals,
+		pending:   make(map[types.Hash]*Evidence),
+	}
+}
+
+// observe records a verified header and signer. If the signer already sealed
+// a different header at the same height, the evidence is returned.
+func (p *evidencePool) observe(header *block.Header, signer types.Address) *Evidence {
+	key := proposalKey{number: header.Number.Uint64(), signer: signer}
+	seen, ok := p.proposals.Get(key)
+	if !ok {
+		p.proposals.Add(key, header)
+		return nil
+	}
+	if prev := seen.(*block.Header); prev.Hash() != header.Hash() {
+		return newEvidence(prev, header)
+	}
+	return nil
+}
+
+// add stores the evidence, reporting whether it wasn't known yet.
+func (p *evidencePool) add(ev *Evidence) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	hash := ev.Hash()
+	if _, ok := p.pending[hash]; ok || len(p.pending) >= maxPendingEvidence {
+		return false
+	}
+	p.pending[hash] = ev
+	return true
+}
+
+// drop removes included or punished evidence.
+func (p *evidencePool) drop(evs ...*Evidence) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, ev := range evs {
+		delete(p.pending, ev.Hash())
+	}
+}
+
+// pick returns up to maxEvidencePerBlock evidences that may be included at
+// the given height, discarding the ones that went stale.
+func (p *evidencePool) pick(number, epoch uint64) []*Evidence {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var evs []*Evidence
+	for hash, ev := range p.pending {
+		if !evidenceInWindow(ev, number, epoch) {
+			delete(p.pending, hash)
+			continue
+		}
+		if len(evs) < maxEvidencePerBlock {
+			evs = append(evs, ev)
+		}
+	}
+	return evs
+}
+
+// evidenceInWindow reports whether the evidence may be included at number.
+func evidenceInWindow(ev *Evidence, number, epoch uint64) bool {
+	return ev.Number() < number && number-ev.Number() <= epoch
+}
+
+// reportEvidence verifies a detected or received evidence, keeps it for
+// inclusion and notifies the subscribers of new offenses.
+func (c *APos) reportEvidence(ev *Evidence) error {
+	offender, err := ev.offender(c.signatures)
+	if err != nil {
+		return err
+	}
+	if !c.evidence.add(ev) {
+		return nil
+	}
+	data, err := encodeEvidences([]*Evidence{ev})
+	if err != nil {
+		return err
+	}
+	log.Warn("Detected double-signed headers", "signer", offender, "number", ev.Number(), "first", ev.First.Hash(), "second", ev.Second.Hash())
+	event.GlobalEvent.Send(amcCommon.DoubleSignEvent{
+		Signer:   offender,
+		Number:   ev.Number(),
+		Hashes:   [2]types.Hash{ev.First.Hash(), ev.Second.Hash()},
+		Evidence: data,
+	})
+	return nil
+}
+
+// AddEvidence accepts encoded double-sign evidence gossiped by other nodes.
+func (c *APos) AddEvidence(data []byte) error {
+	evs, err := decodeEvidences(data)
+	if err != nil {
+		return errInvalidEvidence
+	}
+	for _, ev := range evs {
+		if err := c.reportEvidence(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyEvidences checks the evidences carried by a header.
+func (c *APos) verifyEvidences(header *block.Header) error {
+	evs, err := headerEvidences(header)
+	if err != nil {
+		return err
+	}
+	for _, ev := range evs {
+		if _, err := ev.offender(c.signatures); err != nil {
+			return err
+		}
+		if !evidenceInWindow(ev, header.Number.Uint64(), c.config.Epoch) {
+			return errStaleEvidence
+		}
+	}
+	return nil
+}
+
+// slash applies the penalty for every offense proven in the header that
+// hasn't been punished before: up to SlashAmount of the offender's balance is
+// burned and the offense is marked in the slashing registry.
+func (c *APos) slash(header *block.Header, ibs *state.IntraBlockState) error {
+	evs, err := headerEvidences(header)
+	if err != nil || len(evs) == 0 {
+		return err
+	}
+	if !ibs.Exist(slashingAddress) {
+		ibs.CreateAccount(slashingAddress, false)
+		ibs.SetNonce(slashingAddress, 1)
+	}
+	for _, ev := range evs {
+		offender, err := ev.offender(c.signatures)
+		if err != nil {
+			return err
+		}
+		key := ev.slashingKey(offender)
+		var punished uint256.Int
+		ibs.GetState(slashingAddress, &key, &punished)
+		if !punished.IsZero() {
+			c.evidence.drop(ev)
+			continue
+		}
+		// CheckConsensus requires a slash amount fitting 256 bits, a missing
+		// one costs nothing rather than the whole balance.
+		penalty := new(uint256.Int)
+		if c.config.SlashAmount != nil {
+			penalty.SetFromBig(c.config.SlashAmount)
+		}
+		if balance := ibs.GetBalance(offender); balance.Lt(penalty) {
+			penalty = balance.Clone()
+		}
+		ibs.SubBalance(offender, penalty)
+		ibs.SetState(slashingAddress, &key, *uint256.NewInt(header.Number.Uint64()))
+		c.evidence.drop(ev)
+		log.Warn("Slashed double-signing validator", "signer", offender, "offense", ev.Number(), "penalty", penalty, "number", header.Number.Uint64())
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package apos
+
+import (
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/consensus"
+	"github.com/holiman/uint256"
+)
+
+// Finality implements consensus.FinalityTracker. A checkpoint is justified
+// once more than two thirds of the signers sealed blocks on top of it, and
+// finalized once the same supermajority built on top of a justified
+// descendant, i.e. after two consecutive rounds of confirmations.
+func (c *APos) Finality(chain consensus.ChainHeaderReader, head block.IHeader) (block.IHeader, block.IHeader, error) {
+	snap, err := c.snapshot(chain, head.Number64().Uint64(), head.Hash(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	quorum := len(snap.Signers)*2/3 + 1
+
+	justified, err := c.confirmed(chain, head, quorum)
+	if err != nil || justified == nil {
+		return nil, nil, err
+	}
+	finalized, err := c.confirmed(chain, justified, quorum)
+	if err != nil {
+		return nil, nil, err
+	}
+	return justified, finalized, nil
+}
+
+// confirmed walks back from head until quorum distinct signers were seen and
+// returns the block all of them sealed descendants of. The walk gives up
+// after an epoch, when too few signers are online to confirm anything.
+func (c *APos) confirmed(chain consensus.ChainHeaderReader, head block.IHeader, quorum int) (block.IHeader, error) {
+	seen := make(map[types.Address]struct{}, quorum)
+	header := head
+	for depth := uint64(0); depth < c.config.Epoch && header.Number64().Uint64() > 0; depth++ {
+		signer, err := ecrecover(header, c.signatures)
+		if err != nil {
+			return nil, err
+		}
+		seen[signer] = struct{}{}
+
+		parent := chain.GetHeader(header.(*block.Header).ParentHash, new(uint256.Int).SubUint64(header.Number64(), 1))
+		if parent == nil {
+			return nil, consensus.ErrUnknownAncestor
+		}
+		if len(seen) >= quorum {
+			return parent, nil
+		}
+		header = parent
+	}
+	return nil, nil
+}
@@ -180,6 +180,11 @@ func (r *Reward) buildRewards(tx kv.RwTx, number *uint256.Int, setRewards bool)
 		}
 
 		verifiers := block.Body().Verifier()
+		if economics := r.chainConfig.Rewards; economics != nil {
+			r.accumulateConfigured(tx, economics, currentNr.Uint64(), verifiers, rewardMap)
+			currentNr.SubUint64(currentNr, 1)
+			continue
+		}
 		for _, verifier := range verifiers {
 			depositInfo, ok := depositeMap[verifier.Address]
 			if !ok {
@@ -253,6 +258,33 @@ func (r *Reward) buildRewards(tx kv.RwTx, number *uint256.Int, setRewards bool)
 //	return nil
 //}
 
+// accumulateConfigured credits the verifiers of a block with the reward of
+// the configured schedule, capped per reward epoch.
+func (r *Reward) accumulateConfigured(tx kv.Tx, economics *params.RewardConfig, number uint64, verifiers []*block.Verify, rewardMap map[types.Address]*uint256.Int) {
+	reward, overflow := uint256.FromBig(economics.BlockRewardAt(number))
+	if overflow || reward.IsZero() {
+		return
+	}
+	var limit *uint256.Int
+	if economics.MaxEpochReward != nil {
+		limit, _ = uint256.FromBig(economics.MaxEpochReward)
+	}
+	for _, verifier := range verifiers {
+		if !rawdb.IsDeposit(tx, verifier.Address) {
+			continue
+		}
+		addrReward, ok := rewardMap[verifier.Address]
+		if !ok {
+			addrReward = uint256.NewInt(0)
+		}
+		addrReward.Add(addrReward, reward)
+		if limit != nil {
+			addrReward = math.Min256(addrReward, limit.Clone())
+		}
+		rewardMap[verifier.Address] = addrReward
+	}
+}
+
 func (r *Reward) getAccountRewardUnpaid(tx kv.Getter, account types.Address) (*uint256.Int, error) {
 	value, err := rawdb.GetAccountReward(tx, account)
 	if err != nil {
@@ -60,6 +60,10 @@ type Snapshot struct {
 	Recents map[uint64]types.Address   `json:"recents"` // Set of recent signers for spam protections
 	Votes   []*Vote                    `json:"votes"`   // List of votes cast in chronological order
 	Tally   map[types.Address]Tally    `json:"tally"`   // Current vote tally to avoid recalculating
+
+	// Scheduled holds the passed votes waiting for the next epoch boundary, the
+	// value telling whether the account joins or leaves the validator set.
+	Scheduled map[types.Address]bool `json:"scheduled,omitempty"`
 }
 
 // signersAscending implements the sort interface to allow sorting a list of addresses
@@ -81,6 +85,8 @@ func newSnapshot(config *params.APosConfig, sigcache *lru.ARCCache, number uint6
 		Signers:  make(map[types.Address]struct{}),
 		Recents:  make(map[uint64]types.Address),
 		Tally:    make(map[types.Address]Tally),
+
+		Scheduled: make(map[types.Address]bool),
 	}
 	for _, signer := range signers {
 		snap.Signers[signer] = struct{}{}
@@ -124,6 +130,8 @@ func (s *Snapshot) copy() *Snapshot {
 		Recents:  make(map[uint64]types.Address),
 		Votes:    make([]*Vote, len(s.Votes)),
 		Tally:    make(map[types.Address]Tally),
+
+		Scheduled: make(map[types.Address]bool, len(s.Scheduled)),
 	}
 	for signer := range s.Signers {
 		cpy.Signers[signer] = struct{}{}
@@ -134,6 +142,9 @@ func (s *Snapshot) copy() *Snapshot {
 	for address, tally := range s.Tally {
 		cpy.Tally[address] = tally
 	}
+	for address, authorize := range s.Scheduled {
+		cpy.Scheduled[address] = authorize
+	}
 	copy(cpy.Votes, s.Votes)
 
 	return cpy
@@ -143,6 +154,9 @@ func (s *Snapshot) copy() *Snapshot {
 // given snapshot context (e.g. don't try to add an already authorized signer).
 func (s *Snapshot) validVote(address types.Address, authorize bool) bool {
 	_, signer := s.Signers[address]
+	if scheduled, ok := s.Scheduled[address]; ok {
+		signer = scheduled
+	}
 	return (signer && !authorize) || (!signer && authorize)
 }
 
@@ -262,8 +276,11 @@ func (s *Snapshot) apply(headers []block.IHeader) (*Snapshot, error) {
 				Authorize: authorize,
 			})
 		}
-		// If the vote passed, update the list of signers
-		if tally := snap.Tally[header.Coinbase]; tally.Votes > len(snap.Signers)/2 {
+		// If the vote passed, update the list of signers or, once epoch rotation
+		// is active, schedule the change for the next epoch boundary
+		if tally := snap.Tally[header.Coinbase]; tally.Votes > len(snap.Signers)/2 && s.config.IsEpochRotation(number) {
+			snap.schedule(header.Coinbase, tally.Authorize)
+		} else if tally.Votes > len(snap.Signers)/2 {
 			if tally.Authorize {
 				snap.Signers[header.Coinbase] = struct{}{}
 			} else {
@@ -295,6 +312,24 @@ func (s *Snapshot) apply(headers []block.IHeader) (*Snapshot, error) {
 			}
 			delete(snap.Tally, header.Coinbase)
 		}
+		// Validators proven to have double-signed lose their seat
+		if s.config.IsSlashing(number) && number%s.config.Epoch != 0 {
+			evs, err := headerEvidences(header)
+			if err != nil {
+				return nil, err
+			}
+			for _, ev := range evs {
+				offender, err := ev.offender(s.sigcache)
+				if err != nil {
+					return nil, err
+				}
+				snap.jail(offender, number)
+			}
+		}
+		// Checkpoints hand over to the validator set committed in their extra-data
+		if number%s.config.Epoch == 0 && len(snap.Scheduled) > 0 {
+			snap.rotate(number)
+		}
 		// If we're taking too much time (ecrecover), notify the user once a while
 		if time.Since(logged) > 8*time.Second {
 			log.Info("Reconstructing voting history", "processed", i, "total", len(headers), "elapsed", common.PrettyDuration(time.Since(start)))
@@ -310,6 +345,90 @@ func (s *Snapshot) apply(headers []block.IHeader) (*Snapshot, error) {
 	return snap, nil
 }
 
+// schedule records a passed vote to take effect at the next epoch boundary and
+// discards the votes cast around the account.
+func (s *Snapshot) schedule(address types.Address, authorize bool) {
+	if _, signer := s.Signers[address]; signer == authorize {
+		delete(s.Scheduled, address)
+	} else {
+		s.Scheduled[address] = authorize
+	}
+	for i := 0; i < len(s.Votes); i++ {
+		if s.Votes[i].Address == address {
+			s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+			i--
+		}
+	}
+	delete(s.Tally, address)
+}
+
+// rotate applies the scheduled validator changes at the epoch boundary block
+// number. Departing validators lose their pending votes and the recent signer
+// window is trimmed to the new set size.
+func (s *Snapshot) rotate(number uint64) {
+	for address, authorize := range s.Scheduled {
+		if authorize {
+			s.Signers[address] = struct{}{}
+			continue
+		}
+		delete(s.Signers, address)
+		for i := 0; i < len(s.Votes); i++ {
+			if s.Votes[i].Signer == address {
+				s.uncast(s.Votes[i].Address, s.Votes[i].Authorize)
+				s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+				i--
+			}
+		}
+	}
+	s.Scheduled = make(map[types.Address]bool)
+
+	if limit := uint64(len(s.Signers)/2 + 1); number >= limit {
+		for seen := range s.Recents {
+			if seen <= number-limit {
+				delete(s.Recents, seen)
+			}
+		}
+	}
+}
+
+// jail removes a slashed validator from the signer set, at the next epoch
+// boundary once epoch rotation is active and right away otherwise.
+func (s *Snapshot) jail(offender types.Address, number uint64) {
+	if _, ok := s.Signers[offender]; !ok {
+		delete(s.Scheduled, offender)
+		return
+	}
+	if s.config.IsEpochRotation(number) {
+		s.schedule(offender, false)
+		return
+	}
+	s.Scheduled[offender] = false
+	s.rotate(number)
+}
+
+// nextSigners returns the validator set of the next epoch, i.e. the current
+// signers with the scheduled changes applied, in ascending order. This is the
+// list committed into the extra-data of epoch boundary headers.
+func (s *Snapshot) nextSigners() []types.Address {
+	if len(s.Scheduled) == 0 {
+		return s.signers()
+	}
+	next := make([]types.Address, 0, len(s.Signers)+len(s.Scheduled))
+	for sig := range s.Signers {
+		if authorize, ok := s.Scheduled[sig]; ok && !authorize {
+			continue
+		}
+		next = append(next, sig)
+	}
+	for address, authorize := range s.Scheduled {
+		if _, ok := s.Signers[address]; authorize && !ok {
+			next = append(next, address)
+		}
+	}
+	sort.Sort(signersAscending(next))
+	return next
+}
+
 // signers retrieves the list of authorized signers in ascending order.
 func (s *Snapshot) signers() []types.Address {
 	sigs := make([]types.Address, 0, len(s.Signers))
@@ -320,11 +439,23 @@ func (s *Snapshot) signers() []types.Address {
 	return sigs
 }
 
+// proposer returns the in-turn signer for the given block height. Once epoch
+// rotation is active the turn order restarts at every epoch boundary, shifted by
+// the epoch index so that the same validator doesn't always open an epoch.
+func (s *Snapshot) proposer(number uint64) types.Address {
+	signers := s.signers()
+	if len(signers) == 0 {
+		return types.Address{}
+	}
+	turn := number
+	if s.config.IsEpochRotation(number) {
+		epoch := number / s.config.Epoch
+		turn = number - epoch*s.config.Epoch + epoch
+	}
+	return signers[turn%uint64(len(signers))]
+}
+
 // inturn returns if a signer at a given block height is in-turn or not.
 func (s *Snapshot) inturn(number uint64, signer types.Address) bool {
-	signers, offset := s.signers(), 0
-	for offset < len(signers) && signers[offset] != signer {
-		offset++
-	}
-	return (number % uint64(len(signers))) == uint64(offset)
+	return s.proposer(number) == signer
 }
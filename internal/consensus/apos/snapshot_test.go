@@ -0,0 +1,207 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package apos
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/params"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/holiman/uint256"
+)
+
+// snapshotTestKeys returns n signing keys, ordered by address like the
+// signers of a snapshot.
+func snapshotTestKeys(n int) ([]*ecdsa.PrivateKey, []types.Address) {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		keys[i] = crypto.ToECDSAUnsafe(crypto.Keccak256([]byte{byte(i)}))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(crypto.PubkeyToAddress(keys[i].PublicKey).Bytes(), crypto.PubkeyToAddress(keys[j].PublicKey).Bytes()) < 0
+	})
+	addrs := make([]types.Address, n)
+	for i, key := range keys {
+		addrs[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	return keys, addrs
+}
+
+// signedTestHeader returns header number signed by key, voting on
+// candidate.
+func signedTestHeader(t *testing.T, key *ecdsa.PrivateKey, number uint64, candidate types.Address, authorize bool) *block.Header {
+	t.Helper()
+	header := &block.Header{
+		Coinbase:   candidate,
+		Difficulty: uint256.NewInt(diffNoTurn.Uint64()),
+		Number:     uint256.NewInt(number),
+		Time:       number,
+		Extra:      make([]byte, extraVanity+extraSeal),
+	}
+	if authorize {
+		copy(header.Nonce[:], nonceAuthVote)
+	} else {
+		copy(header.Nonce[:], nonceDropVote)
+	}
+	sig, err := crypto.Sign(SealHash(header).Bytes(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(header.Extra[extraVanity:], sig)
+	return header
+}
+
+func TestSnapshotSchedule(t *testing.T) {
+	_, addrs := snapshotTestKeys(5)
+	a, b, c, d, e := addrs[0], addrs[1], addrs[2], addrs[3], addrs[4]
+	snap := newSnapshot(&params.APosConfig{Epoch: 10}, nil, 0, types.Hash{}, []types.Address{a, b, c})
+	snap.Votes = []*Vote{
+		{Signer: b, Block: 1, Address: d, Authorize: true},
+		{Signer: a, Block: 2, Address: e, Authorize: true},
+		{Signer: c, Block: 3, Address: d, Authorize: true},
+	}
+	snap.Tally = map[types.Address]Tally{d: {Authorize: true, Votes: 2}, e: {Authorize: true, Votes: 1}}
+
+	// The passed vote waits for the boundary, the votes on it are done.
+	snap.schedule(d, true)
+	if want := map[types.Address]bool{d: true}; !reflect.DeepEqual(snap.Scheduled, want) {
+		t.Fatalf("scheduled %v, want %v", snap.Scheduled, want)
+	}
+	if len(snap.Votes) != 1 || snap.Votes[0].Address != e {
+		t.Fatalf("votes left %v, want the one on %x", snap.Votes, e)
+	}
+	if _, ok := snap.Tally[d]; ok || snap.Tally[e].Votes != 1 {
+		t.Fatalf("tally %v, want only the one on %x", snap.Tally, e)
+	}
+	if _, ok := snap.Signers[d]; ok {
+		t.Fatal("scheduled validator joined before the boundary")
+	}
+	// The votes are valid against the validator set to come.
+	if snap.validVote(d, true) || !snap.validVote(d, false) {
+		t.Fatal("vote validity ignores the scheduled change")
+	}
+	if want := []types.Address{a, b, c, d}; !reflect.DeepEqual(snap.nextSigners(), want) {
+		t.Fatalf("next signers %x, want %x", snap.nextSigners(), want)
+	}
+
+	// Voting the change back cancels it.
+	snap.schedule(d, false)
+	if len(snap.Scheduled) != 0 {
+		t.Fatalf("cancelled change still scheduled: %v", snap.Scheduled)
+	}
+	snap.schedule(a, false)
+	if want := []types.Address{b, c}; !reflect.DeepEqual(snap.nextSigners(), want) {
+		t.Fatalf("next signers %x, want %x", snap.nextSigners(), want)
+	}
+	if want := []types.Address{a, b, c}; !reflect.DeepEqual(snap.signers(), want) {
+		t.Fatalf("signers %x before the boundary, want %x", snap.signers(), want)
+	}
+}
+
+func TestSnapshotRotate(t *testing.T) {
+	_, addrs := snapshotTestKeys(6)
+	a, b, c, d, e, f := addrs[0], addrs[1], addrs[2], addrs[3], addrs[4], addrs[5]
+	snap := newSnapshot(&params.APosConfig{Epoch: 10}, nil, 0, types.Hash{}, []types.Address{a, b, c, d, e})
+	snap.Scheduled = map[types.Address]bool{c: false, d: false, e: false, f: true}
+	snap.Votes = []*Vote{
+		{Signer: d, Block: 6, Address: a, Authorize: false},
+		{Signer: b, Block: 7, Address: a, Authorize: false},
+	}
+	snap.Tally = map[types.Address]Tally{a: {Authorize: false, Votes: 2}}
+	snap.Recents = map[uint64]types.Address{6: d, 7: b, 8: c}
+
+	next := snap.nextSigners()
+	snap.rotate(8)
+	if want := []types.Address{a, b, f}; !reflect.DeepEqual(snap.signers(), want) || !reflect.DeepEqual(next, want) {
+		t.Fatalf("signers %x, scheduled as %x, want %x", snap.signers(), next, want)
+	}
+	if len(snap.Scheduled) != 0 {
+		t.Fatalf("changes left after the rotation: %v", snap.Scheduled)
+	}
+	// The votes of the departed validators are gone.
+	if len(snap.Votes) != 1 || snap.Votes[0].Signer != b || snap.Tally[a].Votes != 1 {
+		t.Fatalf("votes %v, tally %v, want the vote of %x alone", snap.Votes, snap.Tally, b)
+	}
+	// Three validators sign one block in two.
+	if want := map[uint64]types.Address{7: b, 8: c}; !reflect.DeepEqual(snap.Recents, want) {
+		t.Fatalf("recents %v, want %v", snap.Recents, want)
+	}
+	if next := snap.nextSigners(); !reflect.DeepEqual(next, snap.signers()) {
+		t.Fatalf("next signers %x without changes, want %x", next, snap.signers())
+	}
+}
+
+// TestSnapshotEpochRotation applies signed headers voting a validator in,
+// who joins at the next boundary, where the turn order shifts.
+func TestSnapshotEpochRotation(t *testing.T) {
+	keys, addrs := snapshotTestKeys(4)
+	config := &params.APosConfig{Epoch: 4, EpochRotationBlock: big.NewInt(0)}
+	sigcache, _ := lru.NewARC(16)
+	snap := newSnapshot(config, sigcache, 0, types.Hash{}, addrs[:3])
+
+	var headers []block.IHeader
+	for number, vote := range []struct {
+		signer    int
+		candidate types.Address
+		authorize bool
+	}{
+		1: {signer: 0, candidate: addrs[3], authorize: true},
+		2: {signer: 1, candidate: addrs[3], authorize: true},
+		3: {signer: 2},
+		4: {signer: 0},
+	} {
+		if number > 0 {
+			headers = append(headers, signedTestHeader(t, keys[vote.signer], uint64(number), vote.candidate, vote.authorize))
+		}
+	}
+
+	passed, err := snap.apply(headers[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[types.Address]bool{addrs[3]: true}; !reflect.DeepEqual(passed.Scheduled, want) {
+		t.Fatalf("scheduled %v, want %v", passed.Scheduled, want)
+	}
+	if !reflect.DeepEqual(passed.signers(), addrs[:3]) || !reflect.DeepEqual(passed.nextSigners(), addrs) {
+		t.Fatalf("signers %x, next %x", passed.signers(), passed.nextSigners())
+	}
+	if len(passed.Votes) != 0 || len(passed.Tally) != 0 {
+		t.Fatalf("votes %v, tally %v left on a passed change", passed.Votes, passed.Tally)
+	}
+
+	rotated, err := passed.apply(headers[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rotated.signers(), addrs) || len(rotated.Scheduled) != 0 {
+		t.Fatalf("signers %x, scheduled %v after the boundary", rotated.signers(), rotated.Scheduled)
+	}
+	// Every epoch opens with the next validator in turn.
+	for number, want := range map[uint64]types.Address{4: addrs[1], 5: addrs[2], 8: addrs[2], 9: addrs[3], 12: addrs[3]} {
+		if proposer := rotated.proposer(number); proposer != want {
+			t.Errorf("proposer of block %d is %x, want %x", number, proposer, want)
+		}
+	}
+}
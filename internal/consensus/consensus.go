@@ -149,6 +149,14 @@ type EngineReader interface {
 	Type() params.ConsensusType
 }
 
+// FinalityTracker is implemented by engines that can tell which blocks of a
+// chain are no longer expected to be reverted.
+type FinalityTracker interface {
+	// Finality returns the latest justified and finalized ancestors of head,
+	// nil for the ones that weren't reached yet.
+	Finality(chain ChainHeaderReader, head block.IHeader) (justified, finalized block.IHeader, err error)
+}
+
 var (
 	SystemAddress = types.HexToAddress("0xffffFFFfFFffffffffffffffFfFFFfffFFFfFFfE")
 )
@@ -45,11 +45,21 @@ func VerifyEip1559Header(config *params.ChainConfig, parent, header *block.Heade
 	expectedBaseFee := CalcBaseFee(config, parent)
 	if header.BaseFee.ToBig().Cmp(expectedBaseFee) != 0 {
 		return fmt.Errorf("invalid baseFee: have %s, want %s, parentBaseFee %s, parentGasUsed %d",
-			expectedBaseFee, header.BaseFee, parent.BaseFee, parent.GasUsed)
+			header.BaseFee, expectedBaseFee, parent.BaseFee, parent.GasUsed)
 	}
 	return nil
 }
 
+// VerifyPreEip1559Header verifies the gas limit of a header before EIP-1559
+// and that it has no base fee. The miner prepares the headers, and the network
+// decodes them, with a zero base fee, which counts as none.
+func VerifyPreEip1559Header(parent, header *block.Header) error {
+	if header.BaseFee != nil && !header.BaseFee.IsZero() {
+		return fmt.Errorf("invalid baseFee before fork: have %d, want <nil>", header.BaseFee)
+	}
+	return VerifyGaslimit(parent.GasLimit, header.GasLimit)
+}
+
 // CalcBaseFee calculates the basefee of the header.
 func CalcBaseFee(config *params.ChainConfig, parent *block.Header) *big.Int {
 	// If the current block is the first EIP-1559 block, return the InitialBaseFee.
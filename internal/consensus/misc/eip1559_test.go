@@ -0,0 +1,46 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/holiman/uint256"
+)
+
+func TestVerifyPreEip1559Header(t *testing.T) {
+	parent := &block.Header{GasLimit: 8_000_000}
+	tests := []struct {
+		name     string
+		baseFee  *uint256.Int
+		gasLimit uint64
+		valid    bool
+	}{
+		{"no base fee", nil, 8_000_000, true},
+		// The miner prepares pre-London headers with a zero base fee.
+		{"zero base fee", uint256.NewInt(0), 8_000_000, true},
+		{"base fee", uint256.NewInt(1), 8_000_000, false},
+		{"gas limit jump", nil, 16_000_000, false},
+	}
+	for _, tt := range tests {
+		header := &block.Header{BaseFee: tt.baseFee, GasLimit: tt.gasLimit}
+		if err := VerifyPreEip1559Header(parent, header); (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v, valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
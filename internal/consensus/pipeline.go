@@ -0,0 +1,91 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package consensus
+
+import "runtime"
+
+// verifyLookahead bounds how far the parallel stage may run ahead of the
+// ordered one, so that its results (e.g. cached signatures) aren't evicted
+// before they are used.
+const verifyLookahead = 1024
+
+// VerifyPipeline verifies a batch of count headers in two stages. The
+// independent checks, typically signature recovery, run in prepare across a
+// pool of workers; the checks depending on the preceding headers run in
+// verify, strictly in order and only for headers that passed prepare.
+//
+// The results are delivered in input order. Closing the returned abort channel
+// stops both stages.
+func VerifyPipeline(count int, prepare, verify func(i int) error) (chan<- struct{}, <-chan error) {
+	var (
+		abort    = make(chan struct{})
+		results  = make(chan error, count)
+		jobs     = make(chan int)
+		window   = make(chan struct{}, verifyLookahead)
+		prepared = make([]chan error, count)
+	)
+	for i := range prepared {
+		prepared[i] = make(chan error, 1)
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if workers > count {
+		workers = count
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				prepared[i] <- prepare(i)
+			}
+		}()
+	}
+	// Feed the workers, never more than the lookahead in front of verify
+	go func() {
+		defer close(jobs)
+		for i := 0; i < count; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-abort:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-abort:
+				return
+			}
+		}
+	}()
+	go func() {
+		for i := 0; i < count; i++ {
+			var err error
+			select {
+			case err = <-prepared[i]:
+			case <-abort:
+				return
+			}
+			<-window
+			if err == nil {
+				err = verify(i)
+			}
+			select {
+			case results <- err:
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, results
+}
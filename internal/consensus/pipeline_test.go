@@ -0,0 +1,165 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVerifyPipeline(t *testing.T) {
+	const count = 2000
+	var (
+		prepared [count]atomic.Bool
+		next     int // next index verify expects, verify runs on one goroutine
+		ordered  = true
+	)
+	prepare := func(i int) error {
+		prepared[i].Store(true)
+		if i%7 == 3 {
+			return fmt.Errorf("prepare %d", i)
+		}
+		return nil
+	}
+	verify := func(i int) error {
+		// Headers failing prepare are skipped, the rest come in order and
+		// after their own prepare.
+		for next%7 == 3 {
+			next++
+		}
+		if i != next || !prepared[i].Load() {
+			ordered = false
+		}
+		next++
+		if i%5 == 0 {
+			return fmt.Errorf("verify %d", i)
+		}
+		return nil
+	}
+	abort, results := VerifyPipeline(count, prepare, verify)
+	defer close(abort)
+
+	for i := 0; i < count; i++ {
+		var want error
+		switch {
+		case i%7 == 3:
+			want = fmt.Errorf("prepare %d", i)
+		case i%5 == 0:
+			want = fmt.Errorf("verify %d", i)
+		}
+		select {
+		case err := <-results:
+			if (err == nil) != (want == nil) || (err != nil && err.Error() != want.Error()) {
+				t.Fatalf("result %d: %v, want %v", i, err, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("result %d not delivered", i)
+		}
+	}
+	if !ordered {
+		t.Error("verify ran out of order or before prepare")
+	}
+}
+
+func TestVerifyPipelineLookahead(t *testing.T) {
+	const count = 3 * verifyLookahead
+	var (
+		started atomic.Int64
+		release = make(chan struct{})
+	)
+	prepare := func(i int) error {
+		started.Add(1)
+		return nil
+	}
+	verify := func(i int) error {
+		if i == 0 {
+			<-release
+		}
+		return nil
+	}
+	abort, results := VerifyPipeline(count, prepare, verify)
+	defer close(abort)
+
+	// With the first header stuck in verify, prepare stops a lookahead
+	// further on.
+	for deadline := time.Now().Add(5 * time.Second); started.Load() < verifyLookahead; {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d headers prepared", started.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := started.Load(); n > verifyLookahead+1 {
+		t.Fatalf("%d headers prepared ahead of verify, want at most %d", n, verifyLookahead+1)
+	}
+	close(release)
+	for i := 0; i < count; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("result %d: %v", i, err)
+		}
+	}
+	if n := started.Load(); n != count {
+		t.Errorf("%d headers prepared, want %d", n, count)
+	}
+}
+
+func TestVerifyPipelineAbort(t *testing.T) {
+	var verified atomic.Int64
+	verify := func(i int) error {
+		verified.Add(1)
+		return nil
+	}
+	errStop := errors.New("stop")
+	block := make(chan struct{})
+	prepare := func(i int) error {
+		if i == 10 {
+			<-block
+			return errStop
+		}
+		return nil
+	}
+	abort, results := VerifyPipeline(1000, prepare, verify)
+	for i := 0; i < 10; i++ {
+		<-results
+	}
+	// Header 10 is still being prepared when verify gets the abort.
+	close(abort)
+	defer close(block)
+
+	time.Sleep(50 * time.Millisecond)
+	if n := verified.Load(); n != 10 {
+		t.Errorf("%d headers verified after the abort, want 10", n)
+	}
+	select {
+	case err := <-results:
+		t.Errorf("result %v delivered after the abort", err)
+	default:
+	}
+}
+
+func TestVerifyPipelineEmpty(t *testing.T) {
+	abort, results := VerifyPipeline(0, func(int) error { return nil }, func(int) error { return nil })
+	defer close(abort)
+	select {
+	case err := <-results:
+		t.Fatalf("result %v for an empty batch", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
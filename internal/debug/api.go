@@ -221,6 +221,13 @@ func (*HandlerT) Stacks(filter *string) string {
 	return buf.String()
 }
 
+// Vmodule replaces the per-module log levels with a comma separated list of
+// module=level pairs, such as "internal/sync=debug,txpool=trace". An empty
+// list leaves every module at the root level.
+func (*HandlerT) Vmodule(pattern string) error {
+	return log.Vmodule(pattern)
+}
+
 // FreeOSMemory forces a garbage collection.
 func (*HandlerT) FreeOSMemory() {
 	debug.FreeOSMemory()
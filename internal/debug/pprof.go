@@ -0,0 +1,48 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"fmt"
+	"net/http"
+	_ "net/http/pprof" // registers the profile handlers on http.DefaultServeMux
+	"runtime"
+
+	"github.com/amazechain/amc/log"
+)
+
+// StartPProf serves the profiles of net/http/pprof under /debug/pprof on
+// address in the background. A failing server is logged, it doesn't stop the
+// node.
+func StartPProf(address string) {
+	log.Info("Starting pprof server", "addr", fmt.Sprintf("http://%s/debug/pprof", address))
+	go func() {
+		if err := http.ListenAndServe(address, nil); err != nil {
+			log.Error("Failure in running pprof server", "err", err)
+		}
+	}()
+}
+
+// SetProfileRates turns on the block and mutex profiles, which the runtime
+// leaves empty by default. The block rate is in nanoseconds, 1 sampling
+// every blocking event, and one in fraction mutex contention events is
+// sampled; 0 keeps a profile off. Both can be changed later through the
+// debug API.
+func SetProfileRates(blockRate, mutexFraction int) {
+	runtime.SetBlockProfileRate(blockRate)
+	runtime.SetMutexProfileFraction(mutexFraction)
+}
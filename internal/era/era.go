@@ -0,0 +1,412 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements immutable archives of the finalized chain.
+//
+// An archive holds up to MaxSize consecutive blocks with their receipts and
+// total difficulty, so that history can be distributed outside of the p2p
+// network and checked without trusting the source. The file is a sequence of
+// entries, each made of a little endian type (2 bytes), data length (4 bytes)
+// and two reserved zero bytes, followed by the data:
+//
+//	Version | (Header | Body | Receipts | TotalDifficulty)* | Accumulator | BlockIndex | Checksum
+//
+// Headers, bodies and receipts are snappy compressed protobuf messages. The
+// accumulator is the keccak hash of all the block hashes in order, and names
+// the file. The block index holds the number of the first block, the offset
+// of every header entry relative to the index entry and the block count. The
+// checksum is the sha256 hash of everything before it.
+package era
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	gohash "hash"
+	"io"
+	"os"
+
+	"github.com/amazechain/amc/api/protocol/types_pb"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/hash"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/golang/snappy"
+	"github.com/holiman/uint256"
+	"google.golang.org/protobuf/proto"
+)
+
+// Entry types of an archive.
+const (
+	TypeVersion         uint16 = 0x3265
+	TypeHeader          uint16 = 0x03
+	TypeBody            uint16 = 0x04
+	TypeReceipts        uint16 = 0x05
+	TypeTotalDifficulty uint16 = 0x06
+	TypeAccumulator     uint16 = 0x07
+	TypeChecksum        uint16 = 0x08
+	TypeBlockIndex      uint16 = 0x3266
+)
+
+const (
+	// MaxSize is the number of blocks in a full archive. Archives are aligned
+	// to multiples of it, so that every node exports identical files.
+	MaxSize = 8192
+
+	headerSize = 8
+	// maxEntrySize bounds the entries read, so a corrupt length can't
+	// exhaust memory.
+	maxEntrySize = 256 * 1024 * 1024
+)
+
+var (
+	ErrInvalidArchive   = errors.New("invalid archive")
+	ErrChecksumMismatch = errors.New("archive checksum mismatch")
+)
+
+// Filename returns the name of an archive of the given network, epoch and
+// accumulator.
+func Filename(network string, epoch uint64, root types.Hash) string {
+	return fmt.Sprintf("%s-%05d-%x.era", network, epoch, root[:4])
+}
+
+// Builder writes an archive. Blocks must be added in order, then the archive
+// is completed by Finalize.
+type Builder struct {
+	w       io.Writer
+	sum     gohash.Hash
+	written int64
+
+	start   uint64
+	offsets []int64
+	hashes  []types.Hash
+}
+
+// NewBuilder returns a builder writing an archive to w.
+func NewBuilder(w io.Writer) *Builder {
+	sum := sha256.New()
+	return &Builder{w: io.MultiWriter(w, sum), sum: sum}
+}
+
+// Add appends a block with its receipts and total difficulty.
+func (b *Builder) Add(blk *block.Block, receipts block.Receipts, td *uint256.Int) error {
+	number := blk.Number64().Uint64()
+	switch {
+	case len(b.offsets) == 0:
+		if err := b.write(TypeVersion, nil); err != nil {
+			return err
+		}
+		b.start = number
+	case len(b.offsets) >= MaxSize:
+		return fmt.Errorf("archive full, can't add block #%d", number)
+	case number != b.start+uint64(len(b.offsets)):
+		return fmt.Errorf("non contiguous block #%d, expected #%d", number, b.start+uint64(len(b.offsets)))
+	}
+	header, err := blk.Header().(*block.Header).Marshal()
+	if err != nil {
+		return err
+	}
+	body, err := proto.Marshal(blk.Body().(*block.Body).ToProtoMessage())
+	if err != nil {
+		return err
+	}
+	rs, err := receipts.Marshal()
+	if err != nil {
+		return err
+	}
+	b.offsets = append(b.offsets, b.written)
+	b.hashes = append(b.hashes, blk.Hash())
+	if err := b.write(TypeHeader, snappy.Encode(nil, header)); err != nil {
+		return err
+	}
+	if err := b.write(TypeBody, snappy.Encode(nil, body)); err != nil {
+		return err
+	}
+	if err := b.write(TypeReceipts, snappy.Encode(nil, rs)); err != nil {
+		return err
+	}
+	tdb := td.Bytes32()
+	return b.write(TypeTotalDifficulty, tdb[:])
+}
+
+// Finalize writes the accumulator, the block index and the checksum, and
+// returns the accumulator.
+func (b *Builder) Finalize() (types.Hash, error) {
+	if len(b.offsets) == 0 {
+		return types.Hash{}, errors.New("empty archive")
+	}
+	root := accumulate(b.hashes)
+	if err := b.write(TypeAccumulator, root[:]); err != nil {
+		return types.Hash{}, err
+	}
+	index := make([]byte, 16+8*len(b.offsets))
+	binary.LittleEndian.PutUint64(index, b.start)
+	for i, offset := range b.offsets {
+		binary.LittleEndian.PutUint64(index[8+8*i:], uint64(offset-b.written))
+	}
+	binary.LittleEndian.PutUint64(index[len(index)-8:], uint64(len(b.offsets)))
+	if err := b.write(TypeBlockIndex, index); err != nil {
+		return types.Hash{}, err
+	}
+	if err := b.write(TypeChecksum, b.sum.Sum(nil)); err != nil {
+		return types.Hash{}, err
+	}
+	return root, nil
+}
+
+func (b *Builder) write(typ uint16, data []byte) error {
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint16(header[:], typ)
+	binary.LittleEndian.PutUint32(header[2:], uint32(len(data)))
+	if _, err := b.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := b.w.Write(data); err != nil {
+		return err
+	}
+	b.written += int64(headerSize + len(data))
+	return nil
+}
+
+// accumulate hashes the block hashes of an archive together.
+func accumulate(hashes []types.Hash) types.Hash {
+	data := make([]byte, 0, len(hashes)*types.HashLength)
+	for _, h := range hashes {
+		data = append(data, h[:]...)
+	}
+	return crypto.Keccak256Hash(data)
+}
+
+// Era is an archive opened for reading.
+type Era struct {
+	f       *os.File
+	size    int64
+	start   uint64
+	offsets []int64 // absolute offsets of the header entries
+	root    types.Hash
+	sumAt   int64 // offset of the checksum entry
+}
+
+// Open opens an archive and reads its index. The content isn't checked
+// until Verify is called.
+func Open(path string) (*Era, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &Era{f: f}
+	if err := e.readIndex(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+// readIndex locates the trailing entries, which all have a known size but
+// the block index.
+func (e *Era) readIndex() error {
+	info, err := e.f.Stat()
+	if err != nil {
+		return err
+	}
+	e.size = info.Size()
+	e.sumAt = e.size - headerSize - sha256.Size
+	if e.sumAt < 0 {
+		return ErrInvalidArchive
+	}
+	if typ, data, err := e.entry(e.sumAt); err != nil || typ != TypeChecksum || len(data) != sha256.Size {
+		return ErrInvalidArchive
+	}
+	// The block count is the last word of the index.
+	var count [8]byte
+	if _, err := e.f.ReadAt(count[:], e.sumAt-8); err != nil {
+		return err
+	}
+	n := binary.LittleEndian.Uint64(count[:])
+	if n == 0 || n > MaxSize {
+		return ErrInvalidArchive
+	}
+	indexAt := e.sumAt - headerSize - int64(16+8*n)
+	typ, index, err := e.entry(indexAt)
+	if err != nil || typ != TypeBlockIndex || len(index) != int(16+8*n) {
+		return ErrInvalidArchive
+	}
+	e.start = binary.LittleEndian.Uint64(index)
+	e.offsets = make([]int64, n)
+	for i := range e.offsets {
+		e.offsets[i] = indexAt + int64(binary.LittleEndian.Uint64(index[8+8*i:]))
+		if e.offsets[i] < headerSize || e.offsets[i] >= indexAt {
+			return ErrInvalidArchive
+		}
+	}
+	typ, root, err := e.entry(indexAt - headerSize - types.HashLength)
+	if err != nil || typ != TypeAccumulator || len(root) != types.HashLength {
+		return ErrInvalidArchive
+	}
+	e.root = types.BytesToHash(root)
+	return nil
+}
+
+// entry reads the entry at the given offset.
+func (e *Era) entry(offset int64) (uint16, []byte, error) {
+	if offset < 0 || offset+headerSize > e.size {
+		return 0, nil, ErrInvalidArchive
+	}
+	var header [headerSize]byte
+	if _, err := e.f.ReadAt(header[:], offset); err != nil {
+		return 0, nil, err
+	}
+	length := int64(binary.LittleEndian.Uint32(header[2:]))
+	if length > maxEntrySize || offset+headerSize+length > e.size {
+		return 0, nil, ErrInvalidArchive
+	}
+	data := make([]byte, length)
+	if _, err := e.f.ReadAt(data, offset+headerSize); err != nil {
+		return 0, nil, err
+	}
+	return binary.LittleEndian.Uint16(header[:]), data, nil
+}
+
+// expect reads the entry at offset, which must be of the given type, and
+// returns the offset of the next one.
+func (e *Era) expect(offset int64, typ uint16) ([]byte, int64, error) {
+	t, data, err := e.entry(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if t != typ {
+		return nil, 0, fmt.Errorf("%w: entry type %#x at %d, expected %#x", ErrInvalidArchive, t, offset, typ)
+	}
+	return data, offset + headerSize + int64(len(data)), nil
+}
+
+// Start returns the number of the first block.
+func (e *Era) Start() uint64 { return e.start }
+
+// Count returns the number of blocks.
+func (e *Era) Count() uint64 { return uint64(len(e.offsets)) }
+
+// Accumulator returns the hash of the block hashes of the archive.
+func (e *Era) Accumulator() types.Hash { return e.root }
+
+// Close closes the archive file.
+func (e *Era) Close() error { return e.f.Close() }
+
+// GetBlockByNumber returns a block of the archive along with its receipts and
+// total difficulty.
+func (e *Era) GetBlockByNumber(number uint64) (*block.Block, block.Receipts, *uint256.Int, error) {
+	if number < e.start || number-e.start >= uint64(len(e.offsets)) {
+		return nil, nil, nil, fmt.Errorf("block #%d isn't in the archive", number)
+	}
+	offset := e.offsets[number-e.start]
+
+	data, offset, err := e.expect(offset, TypeHeader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	header := new(block.Header)
+	if data, err = snappy.Decode(nil, data); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := header.Unmarshal(data); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if data, offset, err = e.expect(offset, TypeBody); err != nil {
+		return nil, nil, nil, err
+	}
+	if data, err = snappy.Decode(nil, data); err != nil {
+		return nil, nil, nil, err
+	}
+	pbBody := new(types_pb.Body)
+	if err := proto.Unmarshal(data, pbBody); err != nil {
+		return nil, nil, nil, err
+	}
+	body := new(block.Body)
+	if err := body.FromProtoMessage(pbBody); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if data, offset, err = e.expect(offset, TypeReceipts); err != nil {
+		return nil, nil, nil, err
+	}
+	if data, err = snappy.Decode(nil, data); err != nil {
+		return nil, nil, nil, err
+	}
+	var receipts block.Receipts
+	if err := receipts.Unmarshal(data); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if data, _, err = e.expect(offset, TypeTotalDifficulty); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(data) != 32 {
+		return nil, nil, nil, ErrInvalidArchive
+	}
+	return block.NewBlockFromStorage(header.Hash(), header, body), receipts, new(uint256.Int).SetBytes32(data), nil
+}
+
+// Verify checks the checksum of the archive, that its blocks link, that
+// their transactions and receipts match the roots of their headers and that
+// the accumulator covers them. The first block's parent is left to the caller.
+func (e *Era) Verify() error {
+	sum := sha256.New()
+	if _, err := io.Copy(sum, io.NewSectionReader(e.f, 0, e.sumAt)); err != nil {
+		return err
+	}
+	_, want, err := e.entry(e.sumAt)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sum.Sum(nil), want) {
+		return ErrChecksumMismatch
+	}
+	if _, _, err := e.expect(0, TypeVersion); err != nil {
+		return err
+	}
+
+	hashes := make([]types.Hash, 0, len(e.offsets))
+	var parent types.Hash
+	for i := range e.offsets {
+		number := e.start + uint64(i)
+		blk, receipts, _, err := e.GetBlockByNumber(number)
+		if err != nil {
+			return fmt.Errorf("block #%d: %w", number, err)
+		}
+		if blk.Number64().Uint64() != number {
+			return fmt.Errorf("%w: block #%d at position of #%d", ErrInvalidArchive, blk.Number64().Uint64(), number)
+		}
+		if i > 0 && blk.ParentHash() != parent {
+			return fmt.Errorf("%w: block #%d doesn't link to its parent", ErrInvalidArchive, number)
+		}
+		if h := hash.DeriveSha(transaction.Transactions(blk.Transactions())); h != blk.TxHash() {
+			return fmt.Errorf("%w: block #%d transaction root %x, header has %x", ErrInvalidArchive, number, h, blk.TxHash())
+		}
+		if h := hash.DeriveSha(receipts); h != blk.Header().(*block.Header).ReceiptHash {
+			return fmt.Errorf("%w: block #%d receipt root %x, header has %x", ErrInvalidArchive, number, h, blk.Header().(*block.Header).ReceiptHash)
+		}
+		parent = blk.Hash()
+		hashes = append(hashes, parent)
+	}
+	if root := accumulate(hashes); root != e.root {
+		return fmt.Errorf("%w: accumulator %x, blocks hash to %x", ErrInvalidArchive, e.root, root)
+	}
+	return nil
+}
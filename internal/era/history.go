@@ -0,0 +1,280 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// ChecksumsFile lists the sha256 hash of every archive written to a directory.
+const ChecksumsFile = "checksums.txt"
+
+// importBatchSize is the number of archived blocks inserted at once.
+const importBatchSize = 2500
+
+// Finalized returns the number of the last finalized block, or the head if
+// the consensus engine doesn't track finality.
+func Finalized(chain common.IBlockChain) (uint64, error) {
+	var number *uint64
+	if err := chain.DB().View(context.Background(), func(tx kv.Tx) error {
+		number = rawdb.ReadHeaderNumber(tx, rawdb.ReadFinalizedBlockHash(tx))
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if number == nil {
+		log.Warn("No finalized block, archiving up to the head")
+		return chain.CurrentBlock().Number64().Uint64(), nil
+	}
+	return *number, nil
+}
+
+// Export writes the blocks from first to last into archives in dir, split at
+// multiples of MaxSize. Only finalized blocks may be archived, so that the
+// files never have to change.
+func Export(chain common.IBlockChain, dir string, first, last uint64) error {
+	finalized, err := Finalized(chain)
+	if err != nil {
+		return err
+	}
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if last > finalized {
+		return fmt.Errorf("export failed: last (%d) is above the finalized block (%d)", last, finalized)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	network := chain.Config().ChainName
+	if network == "" {
+		network = "amc"
+	}
+	var checksums []string
+	for start := first; start <= last; {
+		epoch := start / MaxSize
+		end := (epoch+1)*MaxSize - 1
+		if end > last {
+			end = last
+		}
+		name, sum, err := exportArchive(chain, dir, network, epoch, start, end)
+		if err != nil {
+			return err
+		}
+		log.Info("Exported archive", "file", name, "first", start, "last", end)
+		checksums = append(checksums, fmt.Sprintf("%x %s", sum, name))
+		start = end + 1
+	}
+	return os.WriteFile(filepath.Join(dir, ChecksumsFile), []byte(strings.Join(checksums, "\n")+"\n"), 0644)
+}
+
+// exportArchive writes a single archive, returning its name and checksum.
+func exportArchive(chain common.IBlockChain, dir, network string, epoch, first, last uint64) (string, []byte, error) {
+	tmp, err := os.CreateTemp(dir, ".era-*")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	builder := NewBuilder(tmp)
+	for number := first; number <= last; number++ {
+		blk, err := chain.GetBlockByNumber(uint256.NewInt(number))
+		if err != nil {
+			return "", nil, err
+		}
+		if blk == nil {
+			return "", nil, fmt.Errorf("block #%d not found", number)
+		}
+		receipts, err := chain.GetReceipts(blk.Hash())
+		if err != nil {
+			return "", nil, fmt.Errorf("receipts of block #%d: %v", number, err)
+		}
+		td := chain.GetTd(blk.Hash(), blk.Number64())
+		if td == nil {
+			return "", nil, fmt.Errorf("total difficulty of block #%d not found", number)
+		}
+		if err := builder.Add(blk.(*block.Block), receipts, td); err != nil {
+			return "", nil, err
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", nil, err
+	}
+	sum := sha256.New()
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", nil, err
+	}
+	if _, err := io.Copy(sum, tmp); err != nil {
+		return "", nil, err
+	}
+	name := Filename(network, epoch, root)
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", nil, err
+	}
+	return name, sum.Sum(nil), nil
+}
+
+// Archives returns the archives in dir, ordered by name.
+func Archives(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.era"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// VerifyDir checks every archive in dir, and that consecutive archives link.
+func VerifyDir(dir string) error {
+	paths, err := Archives(dir)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no archives in %s", dir)
+	}
+	var (
+		next   uint64
+		parent types.Hash
+	)
+	for i, path := range paths {
+		e, err := Open(path)
+		if err != nil {
+			return err
+		}
+		err = e.Verify()
+		if err == nil && i > 0 && e.Start() == next {
+			err = checkParent(e, parent)
+		}
+		if err == nil {
+			next = e.Start() + e.Count()
+			parent, err = lastHash(e)
+		}
+		e.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		log.Info("Verified archive", "file", filepath.Base(path), "first", e.Start(), "count", e.Count())
+	}
+	return nil
+}
+
+// Import verifies the archives in dir and inserts their blocks, which are
+// executed as if they came from the network. Blocks already in the chain
+// are skipped; an archive can only be imported on top of its parent.
+func Import(ctx context.Context, chain common.IBlockChain, dir string) error {
+	paths, err := Archives(dir)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no archives in %s", dir)
+	}
+	for _, path := range paths {
+		if err := importArchive(ctx, chain, path); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+	}
+	return nil
+}
+
+func importArchive(ctx context.Context, chain common.IBlockChain, path string) error {
+	e, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	if err := e.Verify(); err != nil {
+		return err
+	}
+	// The archive is self-consistent, its first block has to link to the chain.
+	if start := e.Start(); start > 0 {
+		header := chain.GetHeaderByNumber(uint256.NewInt(start - 1))
+		if header == nil {
+			return fmt.Errorf("parent of block #%d is missing", start)
+		}
+		if err := checkParent(e, header.Hash()); err != nil {
+			return err
+		}
+	}
+	blocks := make([]block.IBlock, 0, importBatchSize)
+	for number := e.Start(); number < e.Start()+e.Count(); number++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		blk, _, _, err := e.GetBlockByNumber(number)
+		if err != nil {
+			return err
+		}
+		if number > 0 && !chain.HasBlock(blk.Hash(), number) {
+			blocks = append(blocks, blk)
+		}
+		if len(blocks) == importBatchSize || number == e.Start()+e.Count()-1 {
+			if len(blocks) == 0 {
+				continue
+			}
+			if _, err := chain.InsertChain(blocks); err != nil {
+				return fmt.Errorf("invalid block #%d: %v", number, err)
+			}
+			log.Info("Imported archived blocks", "count", len(blocks), "number", blocks[len(blocks)-1].Number64().Uint64())
+			blocks = blocks[:0]
+		}
+	}
+	return nil
+}
+
+// checkParent verifies the first block of an archive links to the given hash.
+func checkParent(e *Era, parent types.Hash) error {
+	blk, _, _, err := e.GetBlockByNumber(e.Start())
+	if err != nil {
+		return err
+	}
+	if blk.ParentHash() != parent {
+		return fmt.Errorf("%w: block #%d doesn't link to %x", ErrInvalidArchive, e.Start(), parent)
+	}
+	return nil
+}
+
+// lastHash returns the hash of the last block of an archive.
+func lastHash(e *Era) (types.Hash, error) {
+	blk, _, _, err := e.GetBlockByNumber(e.Start() + e.Count() - 1)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return blk.Hash(), nil
+}
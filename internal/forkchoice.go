@@ -25,6 +25,7 @@ import (
 
 	block2 "github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/consensus"
 	"github.com/amazechain/amc/log"
 )
 
@@ -39,6 +40,20 @@ type ChainReader interface {
 	GetTd(types.Hash, *uint256.Int) *uint256.Int
 }
 
+// ForkChooser decides which chain is canonical. It is consulted whenever an
+// imported block doesn't simply extend the current head.
+type ForkChooser interface {
+	// ReorgNeeded reports whether header should replace current as the head
+	// of the canonical chain.
+	ReorgNeeded(current block2.IHeader, header block2.IHeader) (bool, error)
+}
+
+var (
+	_ ForkChooser = (*ForkChoice)(nil)
+	_ ForkChooser = (*FinalityForkChoice)(nil)
+	_ ForkChooser = ExternalForkChoice{}
+)
+
 // ForkChoice is the fork chooser based on the highest total difficulty of the
 // chain(the fork choice used in the eth1) and the external fork choice (the fork
 // choice used in the eth2). This main goal of this ForkChoice is not only for
@@ -106,3 +121,68 @@ func (f *ForkChoice) ReorgNeeded(current block2.IHeader, header block2.IHeader)
 	}
 	return reorg, nil
 }
+
+// FinalityForkChoice prefers the chain with the most recent finalized, then
+// justified, checkpoint as reported by the consensus engine, so that a chain
+// with more total difficulty can't revert what the validators confirmed.
+// Chains with equal checkpoints are left to the fallback chooser.
+type FinalityForkChoice struct {
+	chain    consensus.ChainHeaderReader
+	tracker  consensus.FinalityTracker
+	fallback ForkChooser
+}
+
+func NewFinalityForkChoice(chain consensus.ChainHeaderReader, tracker consensus.FinalityTracker, fallback ForkChooser) *FinalityForkChoice {
+	return &FinalityForkChoice{
+		chain:    chain,
+		tracker:  tracker,
+		fallback: fallback,
+	}
+}
+
+// ReorgNeeded implements ForkChooser.
+func (f *FinalityForkChoice) ReorgNeeded(current block2.IHeader, header block2.IHeader) (bool, error) {
+	// Extending the head can't revert any checkpoint
+	if header.(*block2.Header).ParentHash == current.Hash() {
+		return f.fallback.ReorgNeeded(current, header)
+	}
+	localJustified, localFinalized, err := f.tracker.Finality(f.chain, current)
+	if err != nil {
+		return false, err
+	}
+	externJustified, externFinalized, err := f.tracker.Finality(f.chain, header)
+	if err != nil {
+		return false, err
+	}
+	if c := compareCheckpoints(externFinalized, localFinalized); c != 0 {
+		return c > 0, nil
+	}
+	if c := compareCheckpoints(externJustified, localJustified); c != 0 {
+		return c > 0, nil
+	}
+	return f.fallback.ReorgNeeded(current, header)
+}
+
+// compareCheckpoints orders two checkpoints by height, a missing one being the
+// lowest.
+func compareCheckpoints(a, b block2.IHeader) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Number64().Cmp(b.Number64())
+}
+
+// ExternalForkChoice hands the choice of the canonical chain to an external
+// consensus driver. Imported blocks never become head by themselves; the
+// driver selects the head with BlockChain.SetCanonical.
+type ExternalForkChoice struct{}
+
+// ReorgNeeded implements ForkChooser.
+func (ExternalForkChoice) ReorgNeeded(current block2.IHeader, header block2.IHeader) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/consensus"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// forkChoiceTestChain serves the total difficulties of headers.
+type forkChoiceTestChain map[types.Hash]*uint256.Int
+
+func (c forkChoiceTestChain) GetTd(hash types.Hash, number *uint256.Int) *uint256.Int {
+	return c[hash]
+}
+
+// forkChoiceTestTracker serves the checkpoints of headers, by height.
+type forkChoiceTestTracker map[types.Hash][2]uint64
+
+func (f forkChoiceTestTracker) Finality(chain consensus.ChainHeaderReader, head block.IHeader) (justified, finalized block.IHeader, err error) {
+	checkpoints, ok := f[head.Hash()]
+	if !ok {
+		return nil, nil, errors.New("unknown head")
+	}
+	if checkpoints[0] > 0 {
+		justified = &block.Header{Number: uint256.NewInt(checkpoints[0])}
+	}
+	if checkpoints[1] > 0 {
+		finalized = &block.Header{Number: uint256.NewInt(checkpoints[1])}
+	}
+	return justified, finalized, nil
+}
+
+func forkChoiceTestHeader(number uint64, fork byte, parent types.Hash) *block.Header {
+	return &block.Header{
+		ParentHash: parent,
+		Number:     uint256.NewInt(number),
+		Difficulty: uint256.NewInt(1),
+		Extra:      []byte{fork},
+		BaseFee:    uint256.NewInt(0),
+	}
+}
+
+func TestForkChoiceTotalDifficulty(t *testing.T) {
+	var (
+		current = forkChoiceTestHeader(10, 'a', types.Hash{})
+		heavier = forkChoiceTestHeader(10, 'b', types.Hash{})
+		lighter = forkChoiceTestHeader(12, 'c', types.Hash{})
+		shorter = forkChoiceTestHeader(9, 'd', types.Hash{})
+		longer  = forkChoiceTestHeader(11, 'e', types.Hash{})
+		equal   = forkChoiceTestHeader(10, 'f', types.Hash{})
+	)
+	chain := forkChoiceTestChain{
+		current.Hash(): uint256.NewInt(100),
+		heavier.Hash(): uint256.NewInt(101),
+		lighter.Hash(): uint256.NewInt(99),
+		shorter.Hash(): uint256.NewInt(100),
+		longer.Hash():  uint256.NewInt(100),
+		equal.Hash():   uint256.NewInt(100),
+	}
+	tests := []struct {
+		header   *block.Header
+		preserve map[types.Hash]bool
+		reorg    bool
+	}{
+		{header: heavier, reorg: true},
+		{header: lighter, reorg: false},
+		// At equal difficulty the shorter chain wins, to reduce the
+		// vulnerability to selfish mining.
+		{header: shorter, reorg: true},
+		{header: longer, reorg: false},
+		// Preserved blocks, such as locally mined ones, win ties at the
+		// same height.
+		{header: equal, preserve: map[types.Hash]bool{current.Hash(): true}, reorg: false},
+		{header: equal, preserve: map[types.Hash]bool{equal.Hash(): true}, reorg: true},
+		{header: equal, preserve: map[types.Hash]bool{current.Hash(): true, equal.Hash(): true}, reorg: false},
+	}
+	for i, tt := range tests {
+		f := NewForkChoice(chain, func(header block.IHeader) bool { return tt.preserve[header.Hash()] })
+		reorg, err := f.ReorgNeeded(current, tt.header)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if reorg != tt.reorg {
+			t.Errorf("test %d: reorg %v, want %v", i, reorg, tt.reorg)
+		}
+	}
+
+	// Without preference the tie is broken at random.
+	f := NewForkChoice(chain, nil)
+	f.rand = rand.New(rand.NewSource(1))
+	var reorgs int
+	for i := 0; i < 100; i++ {
+		if reorg, _ := f.ReorgNeeded(current, equal); reorg {
+			reorgs++
+		}
+	}
+	if reorgs == 0 || reorgs == 100 {
+		t.Errorf("%d reorgs out of 100 ties", reorgs)
+	}
+}
+
+// forkChoiceTestChooser answers every choice with the same result.
+type forkChoiceTestChooser bool
+
+func (c forkChoiceTestChooser) ReorgNeeded(current block.IHeader, header block.IHeader) (bool, error) {
+	return bool(c), nil
+}
+
+func TestFinalityForkChoice(t *testing.T) {
+	var (
+		current          = forkChoiceTestHeader(20, 'a', types.Hash{})
+		child            = forkChoiceTestHeader(21, 'a', current.Hash())
+		finalizedHigher  = forkChoiceTestHeader(18, 'b', types.Hash{})
+		finalizedLower   = forkChoiceTestHeader(25, 'c', types.Hash{})
+		justifiedHigher  = forkChoiceTestHeader(19, 'd', types.Hash{})
+		justifiedMissing = forkChoiceTestHeader(30, 'e', types.Hash{})
+		same             = forkChoiceTestHeader(20, 'f', types.Hash{})
+		unknown          = forkChoiceTestHeader(20, 'g', types.Hash{})
+	)
+	tracker := forkChoiceTestTracker{
+		current.Hash():          {16, 12},
+		finalizedHigher.Hash():  {16, 16},
+		finalizedLower.Hash():   {24, 8},
+		justifiedHigher.Hash():  {18, 12},
+		justifiedMissing.Hash(): {0, 12},
+		same.Hash():             {16, 12},
+	}
+	tests := []struct {
+		header   *block.Header
+		fallback bool
+		reorg    bool
+		err      bool
+	}{
+		// Children of the head are left to the fallback.
+		{header: child, fallback: true, reorg: true},
+		{header: child, fallback: false, reorg: false},
+		// The most recent finalized checkpoint wins whatever the rest says.
+		{header: finalizedHigher, fallback: false, reorg: true},
+		{header: finalizedLower, fallback: true, reorg: false},
+		// Then the most recent justified one.
+		{header: justifiedHigher, fallback: false, reorg: true},
+		{header: justifiedMissing, fallback: true, reorg: false},
+		// Equal checkpoints are left to the fallback.
+		{header: same, fallback: true, reorg: true},
+		{header: same, fallback: false, reorg: false},
+		{header: unknown, fallback: true, err: true},
+	}
+	for i, tt := range tests {
+		f := NewFinalityForkChoice(nil, tracker, forkChoiceTestChooser(tt.fallback))
+		reorg, err := f.ReorgNeeded(current, tt.header)
+		if (err != nil) != tt.err {
+			t.Fatalf("test %d: error %v, want error %v", i, err, tt.err)
+		}
+		if reorg != tt.reorg {
+			t.Errorf("test %d: reorg %v, want %v", i, reorg, tt.reorg)
+		}
+	}
+}
+
+func TestCompareCheckpoints(t *testing.T) {
+	low, high := &block.Header{Number: uint256.NewInt(4)}, &block.Header{Number: uint256.NewInt(8)}
+	tests := []struct {
+		a, b block.IHeader
+		want int
+	}{
+		{nil, nil, 0},
+		{nil, low, -1},
+		{low, nil, 1},
+		{low, high, -1},
+		{high, low, 1},
+		{high, &block.Header{Number: uint256.NewInt(8)}, 0},
+	}
+	for i, tt := range tests {
+		if have := compareCheckpoints(tt.a, tt.b); have != tt.want {
+			t.Errorf("test %d: %d, want %d", i, have, tt.want)
+		}
+	}
+}
+
+// TestExternalForkChoice leaves the choice of the head to the caller of
+// SetCanonical.
+func TestExternalForkChoice(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("fork choice test")))
+	bc, genesis := newTestBlockChain(t, DeveloperGenesisBlock(0, crypto.PubkeyToAddress(key.PublicKey)))
+	bc.SetForkChoice(ExternalForkChoice{})
+	hook := new(recordingHook)
+	bc.AddChainHook(hook)
+
+	a1 := writeEmptyBlock(t, bc, genesis, 'a', 2)
+	a2 := writeEmptyBlock(t, bc, a1, 'a', 2)
+	b2 := writeEmptyBlock(t, bc, a1, 'b', 5)
+	if head := bc.CurrentBlock().Hash(); head != genesis.Hash() {
+		t.Fatalf("imported block became head %s", head)
+	}
+
+	for _, step := range []struct {
+		head  block.IBlock
+		calls []string
+	}{
+		{head: a2, calls: []string{"apply 1 61", "apply 2 61"}},
+		{head: b2, calls: []string{"revert 2 61", "apply 2 62"}},
+		{head: a1, calls: []string{"revert 2 62"}},
+		{head: a1, calls: nil},
+	} {
+		hook.calls = nil
+		if err := bc.SetCanonical(step.head.Hash()); err != nil {
+			t.Fatal(err)
+		}
+		if head := bc.CurrentBlock().Hash(); head != step.head.Hash() {
+			t.Fatalf("head %s, want %s", head, step.head.Hash())
+		}
+		if !reflect.DeepEqual(hook.calls, step.calls) {
+			t.Errorf("hook calls making %d%c the head: %v, want %v", step.head.Number64().Uint64(),
+				step.head.Header().(*block.Header).Extra[0], hook.calls, step.calls)
+		}
+	}
+	if err := bc.SetCanonical(types.Hash{1}); err == nil {
+		t.Error("unknown block made the head")
+	}
+
+	if err := bc.SetFinalized(a1.Hash(), b2.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.SetFinalized(types.Hash{}, types.Hash{1}); err == nil {
+		t.Error("unknown block marked as safe")
+	}
+	if err := bc.ChainDB.View(context.Background(), func(tx kv.Tx) error {
+		if finalized, safe := rawdb.ReadFinalizedBlockHash(tx), rawdb.ReadSafeBlockHash(tx); finalized != a1.Hash() || safe != b2.Hash() {
+			t.Errorf("finalized %s, safe %s, want %s and %s", finalized, safe, a1.Hash(), b2.Hash())
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
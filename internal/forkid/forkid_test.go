@@ -0,0 +1,139 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"hash/crc32"
+	"math/big"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/params"
+)
+
+const (
+	testGenesisTime = 1_600_000_000
+	testPragueTime  = 2_000_000_000
+)
+
+var testGenesis = types.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
+
+// testConfig schedules two block forks and a timestamp one.
+func testConfig() *params.ChainConfig {
+	return &params.ChainConfig{
+		ChainID:        big.NewInt(1),
+		HomesteadBlock: big.NewInt(0),
+		ByzantiumBlock: big.NewInt(10),
+		BerlinBlock:    big.NewInt(20),
+		LondonBlock:    big.NewInt(20),
+		PragueTime:     big.NewInt(testPragueTime),
+	}
+}
+
+// testSums returns the fork checksums of testConfig, the genesis one first.
+func testSums() [4][4]byte {
+	hash := crc32.ChecksumIEEE(testGenesis[:])
+	sums := [4][4]byte{checksumToBytes(hash)}
+	for i, fork := range []uint64{10, 20, testPragueTime} {
+		hash = checksumUpdate(hash, fork)
+		sums[i+1] = checksumToBytes(hash)
+	}
+	return sums
+}
+
+func TestChecksumUpdate(t *testing.T) {
+	blob := append(testGenesis.Bytes(), 0, 0, 0, 0, 0, 0, 0, 10)
+	want := checksumToBytes(crc32.ChecksumIEEE(blob))
+	if have := checksumToBytes(checksumUpdate(crc32.ChecksumIEEE(testGenesis[:]), 10)); have != want {
+		t.Errorf("checksum mismatch: have %x, want %x", have, want)
+	}
+}
+
+func TestCreation(t *testing.T) {
+	sums := testSums()
+	tests := []struct {
+		head, time uint64
+		want       ID
+	}{
+		{0, testGenesisTime, ID{Hash: sums[0], Next: 10}},              // Unsynced, forks at genesis are folded in
+		{9, testGenesisTime, ID{Hash: sums[0], Next: 10}},              // Last block before the first fork
+		{10, testGenesisTime, ID{Hash: sums[1], Next: 20}},             // First fork
+		{20, testGenesisTime, ID{Hash: sums[2], Next: testPragueTime}}, // Forks at the same block count once
+		{30, testPragueTime - 1, ID{Hash: sums[2], Next: testPragueTime}},
+		{31, testPragueTime, ID{Hash: sums[3], Next: 0}}, // Timestamp fork passed, none left
+	}
+	for i, tt := range tests {
+		if have := NewID(testConfig(), testGenesis, testGenesisTime, tt.head, tt.time); have != tt.want {
+			t.Errorf("test %d: fork ID mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
+
+func TestCreationDropsPassedTimeForks(t *testing.T) {
+	// A network started after the timestamp fork has it in its genesis.
+	config := testConfig()
+	have := NewID(config, testGenesis, testPragueTime+1, 30, testPragueTime+100)
+	want := NewID(&params.ChainConfig{ByzantiumBlock: big.NewInt(10), LondonBlock: big.NewInt(20)}, testGenesis, testPragueTime+1, 30, testPragueTime+100)
+	if have != want {
+		t.Errorf("fork ID mismatch: have %x, want %x", have, want)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	sums := testSums()
+	tests := []struct {
+		head, time uint64
+		id         ID
+		err        error
+	}{
+		// Local is between the first and the second fork.
+
+		// Remote is in the same state with no or a matching next fork, connect.
+		{15, testGenesisTime, ID{Hash: sums[1], Next: 0}, nil},
+		{15, testGenesisTime, ID{Hash: sums[1], Next: 20}, nil},
+		// Remote announces a fork we don't know about yet, connect.
+		{15, testGenesisTime, ID{Hash: sums[1], Next: 16}, nil},
+		// Remote announces a fork we already passed without forking, reject.
+		{15, testGenesisTime, ID{Hash: sums[1], Next: 14}, ErrLocalIncompatibleOrStale},
+		// Remote is syncing and knows the next fork, connect.
+		{15, testGenesisTime, ID{Hash: sums[0], Next: 10}, nil},
+		// Remote is syncing but doesn't know the fork we passed, reject.
+		{15, testGenesisTime, ID{Hash: sums[0], Next: 0}, ErrRemoteStale},
+		{15, testGenesisTime, ID{Hash: sums[0], Next: 11}, ErrRemoteStale},
+		// Local is syncing and remote is past forks we know about, connect.
+		{15, testGenesisTime, ID{Hash: sums[2], Next: testPragueTime}, nil},
+		{15, testGenesisTime, ID{Hash: sums[3], Next: 0}, nil},
+		// Remote is on another chain, reject.
+		{15, testGenesisTime, ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}, Next: 0}, ErrLocalIncompatibleOrStale},
+
+		// Local passed every fork, including the timestamp one.
+
+		{30, testPragueTime + 5, ID{Hash: sums[3], Next: 0}, nil},
+		{30, testPragueTime + 5, ID{Hash: sums[2], Next: testPragueTime}, nil},
+		{30, testPragueTime + 5, ID{Hash: sums[2], Next: testPragueTime + 10}, ErrRemoteStale},
+		// Remote announces a timestamp fork the local chain already passed.
+		{30, testPragueTime + 5, ID{Hash: sums[3], Next: testPragueTime + 1}, ErrLocalIncompatibleOrStale},
+		// Remote announces a future timestamp fork, connect.
+		{30, testPragueTime + 5, ID{Hash: sums[3], Next: testPragueTime + 10}, nil},
+	}
+	for i, tt := range tests {
+		filter := NewFilter(testConfig(), testGenesis, testGenesisTime, func() (uint64, uint64) { return tt.head, tt.time })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// allocLogInterval is how often the import of an allocation file reports
+// its progress.
+const allocLogInterval = 8 * time.Second
+
+// importAllocFile streams the allocation file of the genesis into the state
+// written by tx and returns the state root. With a nil tx nothing is written,
+// the file is only checked and hashed.
+//
+// The accounts end up as ToBlock would make them from an inline allocation,
+// so both give the same root. The change sets of the genesis block aren't
+// written: the state as of the genesis is the plain state the later change
+// sets go back to.
+func importAllocFile(tx kv.RwTx, genesis *conf.Genesis) (types.Hash, error) {
+	var (
+		hasher = state.NewRootHasher()
+		rules  = genesis.Config.Rules(0, genesis.Timestamp)
+		writer *state.PlainStateWriter
+
+		accounts, slots int
+		start           = time.Now()
+		logged          = start
+	)
+	if tx != nil {
+		writer = state.NewPlainStateWriterNoHistory(tx)
+	}
+	err := readAllocFile(genesis.AllocFile, func(addr types.Address, alloc *conf.GenesisAccount) error {
+		acc := allocAccount(alloc)
+		if rules.IsSpuriousDragon && acc.Nonce == 0 && acc.Balance.IsZero() && len(alloc.Code) == 0 {
+			return fmt.Errorf("empty account %s", addr)
+		}
+		if err := hasher.Add(addr, acc); err != nil {
+			return err
+		}
+		if writer != nil {
+			if err := writeAllocAccount(tx, writer, addr, alloc, acc); err != nil {
+				return fmt.Errorf("writing %s: %w", addr, err)
+			}
+		}
+		accounts, slots = accounts+1, slots+len(alloc.Storage)
+		if time.Since(logged) > allocLogInterval {
+			log.Info("Importing genesis allocation", "accounts", accounts, "slots", slots, "elapsed", time.Since(start))
+			logged = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("allocation file %s: %w", genesis.AllocFile, err)
+	}
+	root := hasher.Root()
+	if tx != nil {
+		log.Info("Imported genesis allocation", "accounts", accounts, "slots", slots, "root", root, "elapsed", time.Since(start))
+	}
+	return root, nil
+}
+
+// readAllocFile calls fn for every account of an allocation file, in file
+// order. The file is decoded as it is read rather than as a whole.
+func readAllocFile(file string, fn func(types.Address, *conf.GenesisAccount) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReaderSize(f, 1024*1024))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return errors.New("not a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var addr types.Address
+		if err := addr.UnmarshalText([]byte(tok.(string))); err != nil {
+			return fmt.Errorf("invalid address %q: %w", tok, err)
+		}
+		var alloc conf.GenesisAccount
+		if err := dec.Decode(&alloc); err != nil {
+			return fmt.Errorf("account %s: %w", addr, err)
+		}
+		if err := alloc.Validate(); err != nil {
+			return fmt.Errorf("%v of %s", err, addr)
+		}
+		if err := fn(addr, &alloc); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// allocAccount returns the state account of an allocated account. Contracts,
+// and accounts with storage, start at the first incarnation.
+func allocAccount(alloc *conf.GenesisAccount) *account.StateAccount {
+	b, _ := new(big.Int).SetString(alloc.Balance, 10)
+	balance, _ := uint256.FromBig(b)
+	acc := &account.StateAccount{
+		Initialised: true,
+		Nonce:       alloc.Nonce,
+		Balance:     *balance,
+		CodeHash:    crypto.Keccak256Hash(alloc.Code),
+	}
+	if len(alloc.Code) > 0 || len(alloc.Storage) > 0 {
+		acc.Incarnation = state.FirstContractIncarnation
+	}
+	return acc
+}
+
+// writeAllocAccount writes an account with its code and storage.
+func writeAllocAccount(tx kv.RwTx, writer *state.PlainStateWriter, addr types.Address, alloc *conf.GenesisAccount, acc *account.StateAccount) error {
+	if acc.Incarnation > 0 {
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], acc.Incarnation)
+		if err := tx.Put(modules.IncarnationMap, addr[:], b[:]); err != nil {
+			return err
+		}
+	}
+	if len(alloc.Code) > 0 {
+		if err := writer.UpdateAccountCode(addr, acc.Incarnation, acc.CodeHash, alloc.Code); err != nil {
+			return err
+		}
+	}
+	if err := writer.UpdateAccountData(addr, &account.StateAccount{}, acc); err != nil {
+		return err
+	}
+	var zero uint256.Int
+	for key, value := range alloc.Storage {
+		key := key
+		val := new(uint256.Int).SetBytes(value.Bytes())
+		if err := writer.WriteAccountStorage(addr, acc.Incarnation, &key, &zero, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"embed"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,13 +30,12 @@ import (
 	"sync"
 
 	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
 	"github.com/amazechain/amc/modules/rawdb"
 	"github.com/amazechain/amc/modules/state"
 	"github.com/amazechain/amc/params"
-	"github.com/c2h5oh/datasize"
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
-	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
 
 	block2 "github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/types"
@@ -104,7 +104,17 @@ func (g *GenesisBlock) Write(tx kv.RwTx) (*block2.Block, *state.IntraBlockState,
 	//}
 }
 
+// ToBlock builds the genesis block. The state of an allocation file isn't
+// kept in memory, no IntraBlockState is returned for it.
 func (g *GenesisBlock) ToBlock() (*block2.Block, *state.IntraBlockState, error) {
+	if g.GenesisConfig.AllocFile != "" {
+		root, err := importAllocFile(nil, g.GenesisConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		block, err := g.newBlock(root)
+		return block, nil, err
+	}
 	_ = g.GenesisConfig.Alloc //nil-check
 
 	var root types.Hash
@@ -114,7 +124,7 @@ func (g *GenesisBlock) ToBlock() (*block2.Block, *state.IntraBlockState, error)
 	go func() { // we may run inside write tx, can't open 2nd write tx in same goroutine
 		defer wg.Done()
 		//TODO
-		tmpDB := mdbx.NewMDBX(nil).InMem("").MapSize(2 * datasize.GB).MustOpen()
+		tmpDB := memdb.New()
 		defer tmpDB.Close()
 		tx, err := tmpDB.BeginRw(context.Background())
 		if err != nil {
@@ -143,13 +153,19 @@ func (g *GenesisBlock) ToBlock() (*block2.Block, *state.IntraBlockState, error)
 			}
 		}
 
-		if err := statedb.FinalizeTx(g.GenesisConfig.Config.Rules(0), w); err != nil {
+		if err := statedb.FinalizeTx(g.GenesisConfig.Config.Rules(0, g.GenesisConfig.Timestamp), w); err != nil {
 			panic(err)
 		}
 		root = statedb.GenerateRootHash()
 	}()
 	wg.Wait()
 
+	block, err := g.newBlock(root)
+	return block, statedb, err
+}
+
+// newBlock builds the genesis block on top of the given state root.
+func (g *GenesisBlock) newBlock(root types.Hash) (*block2.Block, error) {
 	var ExtraData []byte
 
 	switch g.GenesisConfig.Config.Consensus {
@@ -160,7 +176,7 @@ func (g *GenesisBlock) ToBlock() (*block2.Block, *state.IntraBlockState, error)
 		for _, miner := range g.GenesisConfig.Miners {
 			addr, err := types.HexToString(miner)
 			if err != nil {
-				return nil, nil, fmt.Errorf("invalid miner:  %s", miner)
+				return nil, fmt.Errorf("invalid miner:  %s", miner)
 			}
 			signers = append(signers, addr)
 		}
@@ -210,10 +226,22 @@ func (g *GenesisBlock) ToBlock() (*block2.Block, *state.IntraBlockState, error)
 		}
 	}
 
-	return block2.NewBlock(head, nil).(*block2.Block), statedb, nil
+	return block2.NewBlock(head, nil).(*block2.Block), nil
 }
 
 func (g *GenesisBlock) WriteGenesisState(tx kv.RwTx) (*block2.Block, *state.IntraBlockState, error) {
+	if g.GenesisConfig.AllocFile != "" {
+		if g.GenesisConfig.Number != 0 {
+			return nil, nil, fmt.Errorf("can't commit genesis block with number > 0")
+		}
+		// The file is read once, written and hashed at the same time.
+		root, err := importAllocFile(tx, g.GenesisConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		block, err := g.newBlock(root)
+		return block, nil, err
+	}
 	block, statedb, err := g.ToBlock()
 	if err != nil {
 		return nil, nil, err
@@ -282,3 +310,34 @@ func testnetGenesisBlock() *conf.Genesis {
 		Miners:    []string{"AMCA2142AB3F25EAA9985F22C3F5B1FF9FA378DAC21"},
 	}
 }
+
+// DeveloperGenesisBlock returns the genesis of a dev network: a clique chain
+// with every fork active, sealed by faucet alone, which is funded. A zero
+// period seals blocks only when there are transactions.
+func DeveloperGenesisBlock(period uint64, faucet types.Address) *conf.Genesis {
+	zero := big.NewInt(0)
+	return &conf.Genesis{
+		Config: &params.ChainConfig{
+			ChainName:             "dev",
+			ChainID:               big.NewInt(1337),
+			Consensus:             params.CliqueConsensus,
+			HomesteadBlock:        zero,
+			TangerineWhistleBlock: zero,
+			SpuriousDragonBlock:   zero,
+			ByzantiumBlock:        zero,
+			ConstantinopleBlock:   zero,
+			PetersburgBlock:       zero,
+			IstanbulBlock:         zero,
+			MuirGlacierBlock:      zero,
+			BerlinBlock:           zero,
+			LondonBlock:           zero,
+			ArrowGlacierBlock:     zero,
+			Clique:                &params.CliqueConfig{Period: period, Epoch: 30000},
+		},
+		GasLimit: 30000000,
+		Miners:   []string{"AMC" + hex.EncodeToString(faucet[:])},
+		Alloc: conf.GenesisAlloc{
+			faucet: {Balance: "1000000000000000000000000000"},
+		},
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/api"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/holiman/uint256"
+)
+
+// APIs returns the RPC services of the light client.
+func (c *Client) APIs() []jsonrpc.API {
+	return []jsonrpc.API{
+		{
+			Namespace: "eth",
+			Service:   &PublicAPI{c},
+		},
+	}
+}
+
+// PublicAPI is the part of the eth namespace a light client can answer: the
+// headers from its chain, the receipts and the state from the servers.
+type PublicAPI struct {
+	c *Client
+}
+
+// ChainId returns the chain ID.
+func (s *PublicAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(s.c.cfg.ChainConfig.ChainID)
+}
+
+// BlockNumber returns the number of the head header.
+func (s *PublicAPI) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(s.c.chain.CurrentHeader().Number.Uint64())
+}
+
+// Syncing returns false once the header chain caught up with the peers, the
+// progress of the header sync otherwise.
+func (s *PublicAPI) Syncing(ctx context.Context) (interface{}, error) {
+	progress, err := s.c.SyncProgress(ctx)
+	if err != nil || progress == nil {
+		return false, err
+	}
+	return map[string]interface{}{
+		"startingBlock": hexutil.Uint64(progress.StartingBlock),
+		"currentBlock":  hexutil.Uint64(progress.CurrentBlock),
+		"highestBlock":  hexutil.Uint64(progress.HighestBlock),
+	}, nil
+}
+
+// GetBlockByNumber returns the header of a block. The client has no bodies,
+// so the transactions are never included.
+func (s *PublicAPI) GetBlockByNumber(_ context.Context, number jsonrpc.BlockNumber, _ bool) (map[string]interface{}, error) {
+	header, err := s.header(jsonrpc.BlockNumberOrHashWithNumber(number))
+	if err != nil {
+		return nil, nil
+	}
+	return api.RPCMarshalHeader(header), nil
+}
+
+// GetBlockByHash returns the header of a block, without its transactions.
+func (s *PublicAPI) GetBlockByHash(_ context.Context, hash types.Hash, _ bool) (map[string]interface{}, error) {
+	header, err := s.header(jsonrpc.BlockNumberOrHashWithHash(hash, false))
+	if err != nil {
+		return nil, nil
+	}
+	return api.RPCMarshalHeader(header), nil
+}
+
+// GetBlockReceipts returns the receipts of a block, checked against its header.
+func (s *PublicAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash jsonrpc.BlockNumberOrHash) (block.Receipts, error) {
+	header, err := s.header(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return s.c.retriever.receipts(ctx, header)
+}
+
+// GetBalance returns the balance of an account in the state of a block.
+func (s *PublicAPI) GetBalance(ctx context.Context, address types.Address, blockNrOrHash jsonrpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	header, err := s.header(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.c.retriever.account(ctx, header, address, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(account.Balance.ToBig()), nil
+}
+
+// GetTransactionCount returns the nonce of an account in the state of a block.
+func (s *PublicAPI) GetTransactionCount(ctx context.Context, address types.Address, blockNrOrHash jsonrpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
+	header, err := s.header(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.c.retriever.account(ctx, header, address, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Uint64)(&account.Nonce), nil
+}
+
+// GetCode returns the code of an account in the state of a block.
+func (s *PublicAPI) GetCode(ctx context.Context, address types.Address, blockNrOrHash jsonrpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	header, err := s.header(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.c.retriever.account(ctx, header, address, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return account.Code, nil
+}
+
+// GetStorageAt returns a storage slot of an account in the state of a block.
+func (s *PublicAPI) GetStorageAt(ctx context.Context, address types.Address, key string, blockNrOrHash jsonrpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	header, err := s.header(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.c.retriever.account(ctx, header, address, []types.Hash{types.HexToHash(key)}, false)
+	if err != nil {
+		return nil, err
+	}
+	var value uint256.Int
+	value.SetBytes32(account.Storage[0][:])
+	return value.Bytes(), nil
+}
+
+// header resolves a block of the header chain. Pending is the head, the
+// client doesn't know of anything beyond it.
+func (s *PublicAPI) header(blockNrOrHash jsonrpc.BlockNumberOrHash) (*block.Header, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		header := s.c.chain.headerByHash(hash)
+		if header == nil {
+			return nil, fmt.Errorf("header %s not found", hash)
+		}
+		if blockNrOrHash.RequireCanonical {
+			if canonical := s.c.chain.headerByNumber(header.Number.Uint64()); canonical == nil || canonical.Hash() != hash {
+				return nil, fmt.Errorf("hash %s is not currently canonical", hash)
+			}
+		}
+		return header, nil
+	}
+	number, ok := blockNrOrHash.Number()
+	if !ok {
+		return nil, fmt.Errorf("invalid block number or hash %s", blockNrOrHash.String())
+	}
+	switch number {
+	case jsonrpc.LatestBlockNumber, jsonrpc.PendingBlockNumber, jsonrpc.SafeBlockNumber, jsonrpc.FinalizedBlockNumber:
+		return s.c.chain.CurrentHeader(), nil
+	}
+	header := s.c.chain.headerByNumber(uint64(number.Int64()))
+	if header == nil {
+		return nil, fmt.Errorf("header #%d not found", number.Int64())
+	}
+	return header, nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package light implements the light client: a node that keeps the verified
+// header chain only and retrieves everything else on demand from full nodes
+// serving light clients.
+package light
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/consensus"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var (
+	errUnknownParent = errors.New("unknown parent header")
+	errBrokenChain   = errors.New("headers don't form a chain")
+	errNoState       = errors.New("no state available to light clients")
+)
+
+// HeaderChain is the chain of a light client. Headers are verified by the
+// consensus engine, which only needs the headers themselves, and stored like
+// the headers of a full node; the heaviest chain is the canonical one.
+type HeaderChain struct {
+	ctx    context.Context
+	db     kv.RwDB
+	config *params.ChainConfig
+	engine consensus.Engine
+
+	lock sync.Mutex // serialises header inserts
+	head atomic.Pointer[block.Header]
+}
+
+// NewHeaderChain opens the header chain stored in db, starting from the
+// genesis header if none was synced yet.
+func NewHeaderChain(ctx context.Context, db kv.RwDB, config *params.ChainConfig, engine consensus.Engine, genesis block.IBlock) (*HeaderChain, error) {
+	c := &HeaderChain{ctx: ctx, db: db, config: config, engine: engine}
+	var head *block.Header
+	if err := db.View(ctx, func(tx kv.Tx) error {
+		head = rawdb.ReadCurrentHeader(tx)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if head == nil {
+		head = genesis.Header().(*block.Header)
+	}
+	c.head.Store(head)
+	return c, nil
+}
+
+// CurrentHeader returns the head of the canonical header chain.
+func (c *HeaderChain) CurrentHeader() *block.Header {
+	return c.head.Load()
+}
+
+// Config retrieves the chain configuration.
+func (c *HeaderChain) Config() *params.ChainConfig {
+	return c.config
+}
+
+// CurrentBlock returns the head header as a block without body.
+func (c *HeaderChain) CurrentBlock() block.IBlock {
+	return block.NewBlock(c.CurrentHeader(), nil)
+}
+
+// GetHeader retrieves a header by hash and number.
+func (c *HeaderChain) GetHeader(hash types.Hash, number *uint256.Int) block.IHeader {
+	var header *block.Header
+	c.db.View(c.ctx, func(tx kv.Tx) error {
+		header = rawdb.ReadHeader(tx, hash, number.Uint64())
+		return nil
+	})
+	if header == nil {
+		return nil
+	}
+	return header
+}
+
+// GetHeaderByNumber retrieves the canonical header of a number.
+func (c *HeaderChain) GetHeaderByNumber(number *uint256.Int) block.IHeader {
+	if header := c.headerByNumber(number.Uint64()); header != nil {
+		return header
+	}
+	return nil
+}
+
+func (c *HeaderChain) headerByNumber(number uint64) *block.Header {
+	var header *block.Header
+	c.db.View(c.ctx, func(tx kv.Tx) error {
+		header = rawdb.ReadHeaderByNumber(tx, number)
+		return nil
+	})
+	return header
+}
+
+// GetHeaderByHash retrieves a header by hash.
+func (c *HeaderChain) GetHeaderByHash(hash types.Hash) (block.IHeader, error) {
+	if header := c.headerByHash(hash); header != nil {
+		return header, nil
+	}
+	return nil, nil
+}
+
+func (c *HeaderChain) headerByHash(hash types.Hash) *block.Header {
+	var header *block.Header
+	c.db.View(c.ctx, func(tx kv.Tx) error {
+		if number := rawdb.ReadHeaderNumber(tx, hash); number != nil {
+			header = rawdb.ReadHeader(tx, hash, *number)
+		}
+		return nil
+	})
+	return header
+}
+
+// GetTd retrieves the total difficulty of a header.
+func (c *HeaderChain) GetTd(hash types.Hash, number *uint256.Int) *uint256.Int {
+	var td *uint256.Int
+	c.db.View(c.ctx, func(tx kv.Tx) (err error) {
+		td, err = rawdb.ReadTd(tx, hash, number.Uint64())
+		return err
+	})
+	return td
+}
+
+// GetBlockByNumber returns the canonical header of a number as a block
+// without body.
+func (c *HeaderChain) GetBlockByNumber(number *uint256.Int) (block.IBlock, error) {
+	header := c.headerByNumber(number.Uint64())
+	if header == nil {
+		return nil, fmt.Errorf("header #%d not found", number.Uint64())
+	}
+	return block.NewBlock(header, nil), nil
+}
+
+// GetDepositInfo is part of the state, which a light client doesn't have.
+func (c *HeaderChain) GetDepositInfo(types.Address) (*uint256.Int, *uint256.Int) {
+	return nil, nil
+}
+
+// GetAccountRewardUnpaid is part of the state, which a light client doesn't have.
+func (c *HeaderChain) GetAccountRewardUnpaid(types.Address) (*uint256.Int, error) {
+	return nil, errNoState
+}
+
+// InsertHeaders verifies and stores a chain of headers, returning the number
+// of headers inserted. Headers already known are skipped, the first unknown
+// one has to link to a stored header. The canonical chain moves to the new
+// headers if they make it heavier.
+func (c *HeaderChain) InsertHeaders(headers []*block.Header) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(headers) > 0 && c.headerByHash(headers[0].Hash()) != nil {
+		headers = headers[1:]
+	}
+	if len(headers) == 0 {
+		return 0, nil
+	}
+	parent := c.headerByHash(headers[0].ParentHash)
+	if parent == nil {
+		return 0, errUnknownParent
+	}
+	chain := make([]block.IHeader, len(headers))
+	seals := make([]bool, len(headers))
+	for i, header := range headers {
+		if i > 0 && header.ParentHash != headers[i-1].Hash() {
+			return 0, errBrokenChain
+		}
+		chain[i], seals[i] = header, true
+	}
+	abort, results := c.engine.VerifyHeaders(c, chain, seals)
+	defer close(abort)
+	for i := range chain {
+		if err := <-results; err != nil {
+			return 0, fmt.Errorf("header #%d %s: %w", headers[i].Number.Uint64(), headers[i].Hash(), err)
+		}
+	}
+
+	head := c.CurrentHeader()
+	err := c.db.Update(c.ctx, func(tx kv.RwTx) error {
+		td, err := rawdb.ReadTd(tx, parent.Hash(), parent.Number.Uint64())
+		if err != nil {
+			return err
+		}
+		if td == nil {
+			return fmt.Errorf("no total difficulty for header #%d", parent.Number.Uint64())
+		}
+		headTd, err := rawdb.ReadTd(tx, head.Hash(), head.Number.Uint64())
+		if err != nil {
+			return err
+		}
+		for _, header := range headers {
+			td = new(uint256.Int).Add(td, header.Difficulty)
+			rawdb.WriteHeader(tx, header)
+			if err := rawdb.WriteTd(tx, header.Hash(), header.Number.Uint64(), td); err != nil {
+				return err
+			}
+		}
+		if headTd != nil && td.Cmp(headTd) <= 0 {
+			return nil
+		}
+		head = headers[len(headers)-1]
+		return writeCanonical(tx, head)
+	})
+	if err != nil {
+		return 0, err
+	}
+	c.head.Store(head)
+	return len(headers), nil
+}
+
+// writeCanonical makes the chain ending at head the canonical one.
+func writeCanonical(tx kv.RwTx, head *block.Header) error {
+	number := head.Number.Uint64()
+	if err := rawdb.TruncateCanonicalHash(tx, number+1, false); err != nil {
+		return err
+	}
+	for hash := head.Hash(); ; number-- {
+		canonical, err := rawdb.ReadCanonicalHash(tx, number)
+		if err != nil {
+			return err
+		}
+		if canonical == hash {
+			break
+		}
+		if number == 0 {
+			return errors.New("header chain doesn't start at the genesis")
+		}
+		if err := rawdb.WriteCanonicalHash(tx, hash, number); err != nil {
+			return err
+		}
+		header := rawdb.ReadHeader(tx, hash, number)
+		if header == nil {
+			return fmt.Errorf("broken header chain at #%d", number)
+		}
+		hash = header.ParentHash
+	}
+	return rawdb.WriteHeadHeaderHash(tx, head.Hash())
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	amazechain "github.com/amazechain/amc"
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/internal/consensus"
+	"github.com/amazechain/amc/internal/p2p"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/params"
+	"github.com/amazechain/amc/utils"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// maxSyncPeers is the number of peers tried for new headers per round.
+	maxSyncPeers = 4
+	// forkStep is how far back a round looks for the parent of a fork.
+	forkStep = 64
+	// maxForkDepth is how deep a fork of a peer is followed.
+	maxForkDepth = 1024
+)
+
+// Config holds what a light client is built from.
+type Config struct {
+	DB          kv.RwDB
+	ChainConfig *params.ChainConfig
+	Engine      consensus.Engine
+	Genesis     block.IBlock
+	P2P         p2p.P2P
+	// Confirm is the number of servers that have to return the same account
+	// before it is trusted.
+	Confirm int
+}
+
+// Client is a light client. It follows the header chain of its peers and
+// answers the state and receipts questions of its RPC from the full nodes
+// serving light clients.
+type Client struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    *Config
+
+	chain     *HeaderChain
+	retriever *retriever
+
+	syncing  atomic.Bool
+	starting atomic.Uint64 // head when the current sync began
+	highest  atomic.Uint64 // highest head announced by the peers
+}
+
+// New creates a light client on the header chain stored in the database.
+func New(ctx context.Context, cfg *Config) (*Client, error) {
+	chain, err := NewHeaderChain(ctx, cfg.DB, cfg.ChainConfig, cfg.Engine, cfg.Genesis)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Confirm < 1 {
+		cfg.Confirm = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Client{
+		ctx:       ctx,
+		cancel:    cancel,
+		cfg:       cfg,
+		chain:     chain,
+		retriever: newRetriever(cfg.P2P, cfg.Confirm),
+	}, nil
+}
+
+// Chain returns the header chain of the client.
+func (c *Client) Chain() *HeaderChain {
+	return c.chain
+}
+
+// Start follows the header chain of the peers in the background, fetching
+// their new headers once per block period.
+func (c *Client) Start() {
+	log.Info("Starting light client", "head", c.chain.CurrentHeader().Number.Uint64(), "confirm", c.cfg.Confirm)
+	log.Warn("Light client account state is not proven, it is trusted once enough servers agree", "servers", c.cfg.Confirm)
+	utils.RunEvery(c.ctx, blockPeriod(c.cfg.ChainConfig), c.syncRound)
+	go c.syncRound()
+}
+
+// Stop stops following the peers.
+func (c *Client) Stop() error {
+	c.cancel()
+	return nil
+}
+
+// blockPeriod returns the block time of the chain, at least a second.
+func blockPeriod(config *params.ChainConfig) time.Duration {
+	var period uint64
+	switch {
+	case config.Apos != nil:
+		period = config.Apos.Period
+	case config.Clique != nil:
+		period = config.Clique.Period
+	}
+	if period == 0 {
+		period = 1
+	}
+	return time.Duration(period) * time.Second
+}
+
+// syncRound imports the headers of the best peers above the local head.
+func (c *Client) syncRound() {
+	if !c.syncing.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.syncing.Store(false)
+
+	head := c.chain.CurrentHeader()
+	highest, peers := c.cfg.P2P.Peers().BestPeers(maxSyncPeers, head.Number)
+	if len(peers) == 0 {
+		return
+	}
+	if highest.Uint64() > c.highest.Load() {
+		c.highest.Store(highest.Uint64())
+	}
+	c.starting.Store(head.Number.Uint64())
+	for _, pid := range peers {
+		err := c.syncFrom(pid)
+		if err == nil {
+			break
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+		log.Debug("Could not sync headers from peer", "peer", pid, "err", err)
+	}
+	if now := c.chain.CurrentHeader(); now.Number.Uint64() > head.Number.Uint64() {
+		log.Info("Imported new headers", "count", now.Number.Uint64()-head.Number.Uint64(), "number", now.Number.Uint64(), "hash", now.Hash())
+	}
+}
+
+// syncFrom imports the headers of a peer above the local head. A peer on
+// another fork is followed back until its headers link to stored ones.
+func (c *Client) syncFrom(pid peer.ID) error {
+	var (
+		from = c.chain.CurrentHeader().Number.Uint64() + 1
+		back uint64
+	)
+	for {
+		headers, err := amcsync.SendHeadersByRangeRequest(c.ctx, c.cfg.P2P, pid, &sync_pb.HeadersByRangeRequest{
+			StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(from)),
+			Count:            amcsync.MaxRequestHeaders,
+			Step:             1,
+		})
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return nil
+		}
+		if _, err := c.chain.InsertHeaders(headers); errors.Is(err, errUnknownParent) {
+			if back >= maxForkDepth || from <= 1 {
+				return err
+			}
+			step := uint64(forkStep)
+			if step >= from {
+				step = from - 1
+			}
+			from, back = from-step, back+step
+			continue
+		} else if err != nil {
+			c.cfg.P2P.Peers().Scorers().BadResponsesScorer().Increment(pid)
+			return err
+		}
+		if len(headers) < amcsync.MaxRequestHeaders {
+			return nil
+		}
+		from = headers[len(headers)-1].Number.Uint64() + 1
+	}
+}
+
+// SyncProgress returns the progress of the header sync, or nil once the head
+// caught up with the peers.
+func (c *Client) SyncProgress(_ context.Context) (*amazechain.SyncProgress, error) {
+	current, highest := c.chain.CurrentHeader().Number.Uint64(), c.highest.Load()
+	if current >= highest {
+		return nil, nil
+	}
+	return &amazechain.SyncProgress{
+		StartingBlock: c.starting.Load(),
+		CurrentBlock:  current,
+		HighestBlock:  highest,
+	}, nil
+}
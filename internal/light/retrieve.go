@@ -0,0 +1,227 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	accounts "github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/hash"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/internal/p2p"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/log"
+	"github.com/holiman/uint256"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// maxRetrieveServers is the number of servers asked for a single answer.
+	maxRetrieveServers = 8
+	// serverBackoff is how long a server that failed a request is left alone.
+	serverBackoff = time.Minute
+)
+
+var (
+	errNoServers     = errors.New("no light server answered")
+	errStateMismatch = errors.New("light servers disagree about the state")
+)
+
+// retriever asks the servers for what isn't in the header chain.
+//
+// Receipts are checked against the receipt hash of their header. The state
+// can't be checked that way: the state root of a header only commits to the
+// accounts its block changed, not to the whole state, so there is no proof a
+// server could send. An account is trusted once confirm servers returned the
+// same one; the codes are checked against their hash. The resulting trust
+// model is documented for users in docs/run/light-client.md.
+type retriever struct {
+	p2p     p2p.P2P
+	confirm int
+
+	lock    sync.Mutex
+	servers map[peer.ID]*server
+}
+
+// server is what the client knows about the flow control of a server.
+type server struct {
+	buffer  uint64    // buffer left after the last answer
+	updated time.Time // time of the last answer
+	failed  time.Time // time of the last failure
+}
+
+// estimate returns the buffer the server has recharged to by now.
+func (s *server) estimate(now time.Time) uint64 {
+	if s.updated.IsZero() {
+		return amcsync.LightBufferLimit
+	}
+	buffer := s.buffer + uint64(now.Sub(s.updated).Seconds()*amcsync.LightBufferRecharge)
+	if buffer > amcsync.LightBufferLimit {
+		buffer = amcsync.LightBufferLimit
+	}
+	return buffer
+}
+
+func newRetriever(p2p p2p.P2P, confirm int) *retriever {
+	return &retriever{p2p: p2p, confirm: confirm, servers: make(map[peer.ID]*server)}
+}
+
+// candidates returns the peers that have the block, which didn't fail a
+// request lately, with those able to afford the cost first and the fullest
+// buffers first among them.
+func (r *retriever) candidates(number, cost uint64) []peer.ID {
+	peers := r.p2p.Peers().Connected()
+	if number > 0 {
+		_, peers = r.p2p.Peers().BestPeers(len(peers), uint256.NewInt(number-1))
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var (
+		now     = time.Now()
+		buffers = make(map[peer.ID]uint64, len(peers))
+		usable  = make([]peer.ID, 0, len(peers))
+	)
+	for _, pid := range peers {
+		s := r.servers[pid]
+		if s == nil {
+			s = new(server)
+			r.servers[pid] = s
+		}
+		if now.Sub(s.failed) < serverBackoff {
+			continue
+		}
+		buffers[pid] = s.estimate(now)
+		usable = append(usable, pid)
+	}
+	sort.SliceStable(usable, func(i, j int) bool {
+		ai, aj := buffers[usable[i]] >= cost, buffers[usable[j]] >= cost
+		if ai != aj {
+			return ai
+		}
+		return buffers[usable[i]] > buffers[usable[j]]
+	})
+	if len(usable) > maxRetrieveServers {
+		usable = usable[:maxRetrieveServers]
+	}
+	return usable
+}
+
+// answered records the outcome of a request to a server.
+func (r *retriever) answered(pid peer.ID, resp *sync_pb.LightResponse, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s := r.servers[pid]
+	if s == nil {
+		s = new(server)
+		r.servers[pid] = s
+	}
+	if err != nil {
+		s.failed = time.Now()
+		return
+	}
+	s.buffer, s.updated = resp.Buffer, time.Now()
+}
+
+// invalid records an answer that failed verification.
+func (r *retriever) invalid(pid peer.ID, err error) {
+	log.Debug("Light server returned an invalid answer", "peer", pid, "err", err)
+	r.p2p.Peers().Scorers().BadResponsesScorer().Increment(pid)
+	r.answered(pid, nil, err)
+}
+
+// receipts retrieves the receipts of a block and checks them against its header.
+func (r *retriever) receipts(ctx context.Context, header *block.Header) (block.Receipts, error) {
+	req := &sync_pb.LightReceiptsRequest{BlockHash: header.Hash()}
+	for _, pid := range r.candidates(header.Number.Uint64(), amcsync.LightRequestCost(req)) {
+		resp, receipts, err := amcsync.SendLightReceiptsRequest(ctx, r.p2p, pid, req)
+		r.answered(pid, resp, err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if root := hash.DeriveSha(receipts); root != header.ReceiptHash {
+			r.invalid(pid, fmt.Errorf("receipt hash %s, header has %s", root, header.ReceiptHash))
+			continue
+		}
+		for i, receipt := range receipts {
+			receipt.BlockHash, receipt.BlockNumber, receipt.TransactionIndex = header.Hash(), header.Number.Clone(), uint(i)
+		}
+		return receipts, nil
+	}
+	return nil, errNoServers
+}
+
+// account retrieves an account in the state of a block from confirm servers,
+// which all have to return the same one.
+func (r *retriever) account(ctx context.Context, header *block.Header, addr types.Address, keys []types.Hash, code bool) (*amcsync.LightAccount, error) {
+	req := &sync_pb.LightAccountRequest{BlockHash: header.Hash(), Address: addr, Code: code}
+	for _, key := range keys {
+		req.Keys = append(req.Keys, key[:]...)
+	}
+
+	var (
+		agreed  *amcsync.LightAccount
+		encoded []byte
+		count   int
+	)
+	for _, pid := range r.candidates(header.Number.Uint64(), amcsync.LightRequestCost(req)) {
+		resp, account, err := amcsync.SendLightAccountRequest(ctx, r.p2p, pid, req)
+		r.answered(pid, resp, err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if code && !(len(account.Code) == 0 && accounts.IsEmptyCodeHash(account.CodeHash)) && crypto.Keccak256Hash(account.Code) != account.CodeHash {
+			r.invalid(pid, fmt.Errorf("code doesn't match code hash %s", account.CodeHash))
+			continue
+		}
+		enc, err := rlp.EncodeToBytes(account)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case agreed == nil:
+			agreed, encoded = account, enc
+		case !bytes.Equal(enc, encoded):
+			return nil, fmt.Errorf("%w: account %s at block #%d", errStateMismatch, addr, header.Number.Uint64())
+		}
+		if count++; count >= r.confirm {
+			return agreed, nil
+		}
+	}
+	if agreed != nil {
+		return nil, fmt.Errorf("%w: %d of the %d servers needed answered", errNoServers, count, r.confirm)
+	}
+	return nil, errNoServers
+}
@@ -13,7 +13,10 @@ var EnabledExpensive = false
 func Setup(address string, log log.Logger) *http.ServeMux {
 	prometheusMux := http.NewServeMux()
 
-	prometheusMux.Handle("/debug/metrics/prometheus", Handler(DefaultRegistry))
+	// Handler registers the collectors, it can only be created once.
+	handler := Handler(DefaultRegistry)
+	prometheusMux.Handle("/metrics", handler)
+	prometheusMux.Handle("/debug/metrics/prometheus", handler)
 
 	promServer := &http.Server{
 		Addr:    address,
@@ -26,7 +29,7 @@ func Setup(address string, log log.Logger) *http.ServeMux {
 		}
 	}()
 
-	log.Info("Enabling metrics export to prometheus", "path", fmt.Sprintf("http://%s/debug/metrics/prometheus", address))
+	log.Info("Enabling metrics export to prometheus", "path", fmt.Sprintf("http://%s/metrics", address))
 
 	return prometheusMux
 }
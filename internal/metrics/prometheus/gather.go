@@ -0,0 +1,51 @@
+package prometheus
+
+import (
+	"bytes"
+	"io"
+
+	metrics2 "github.com/VictoriaMetrics/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Gather returns the metrics served by the handler as Prometheus metric
+// families, for the exporters pushing them to monitoring stacks that can't
+// scrape the node. The Go runtime metrics are reported by both the Prometheus
+// client and VictoriaMetrics, the first family of a name wins.
+func Gather(reg Registry) ([]*dto.MetricFamily, error) {
+	registerDefaultSet()
+
+	var (
+		families []*dto.MetricFamily
+		seen     = make(map[string]bool)
+	)
+	add := func(mf *dto.MetricFamily) {
+		if !seen[mf.GetName()] {
+			seen[mf.GetName()] = true
+			families = append(families, mf)
+		}
+	}
+
+	gathered, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	for _, mf := range gathered {
+		add(mf)
+	}
+	var vm bytes.Buffer
+	metrics2.WritePrometheus(&vm, true)
+	for _, text := range []io.Reader{&vm, collectRegistry(reg).buff} {
+		var parser expfmt.TextParser
+		parsed, err := parser.TextToMetricFamilies(text)
+		if err != nil {
+			return nil, err
+		}
+		for _, mf := range parsed {
+			add(mf)
+		}
+	}
+	return families, nil
+}
@@ -0,0 +1,110 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amazechain/amc/conf"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+// influxDB writes the samples in the line protocol, to the /write endpoint of
+// InfluxDB 1.x or the /api/v2/write endpoint of InfluxDB 2.x.
+type influxDB struct {
+	client *http.Client
+	url    string
+	config conf.MetricsConfig
+	tags   map[string]string
+}
+
+func newInfluxDB(config conf.MetricsConfig, tags map[string]string) (*influxDB, error) {
+	endpoint, err := url.Parse(strings.TrimRight(config.InfluxDBEndpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid InfluxDB endpoint %q", config.InfluxDBEndpoint)
+	}
+	query := url.Values{"precision": {"ns"}}
+	if config.InfluxDBV2 {
+		if config.InfluxDBToken == "" || config.InfluxDBBucket == "" || config.InfluxDBOrganization == "" {
+			return nil, fmt.Errorf("InfluxDB v2 needs a token, a bucket and an organization")
+		}
+		endpoint.Path += "/api/v2/write"
+		query.Set("bucket", config.InfluxDBBucket)
+		query.Set("org", config.InfluxDBOrganization)
+	} else {
+		if config.InfluxDBDatabase == "" {
+			return nil, fmt.Errorf("InfluxDB needs a database")
+		}
+		endpoint.Path += "/write"
+		query.Set("db", config.InfluxDBDatabase)
+	}
+	endpoint.RawQuery = query.Encode()
+	return &influxDB{
+		client: &http.Client{Timeout: requestTimeout},
+		url:    endpoint.String(),
+		config: config,
+		tags:   tags,
+	}, nil
+}
+
+func (db *influxDB) name() string {
+	if db.config.InfluxDBV2 {
+		return "influxdbv2"
+	}
+	return "influxdb"
+}
+
+func (db *influxDB) export(ctx context.Context, samples []sample, now time.Time) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	var (
+		body      bytes.Buffer
+		timestamp = strconv.FormatInt(now.UnixNano(), 10)
+	)
+	for _, s := range samples {
+		body.WriteString(measurementEscaper.Replace(s.name))
+		tags := make(map[string]string, len(db.tags)+len(s.labels))
+		for k, v := range db.tags {
+			tags[k] = v
+		}
+		for k, v := range s.labels {
+			tags[k] = v
+		}
+		for _, k := range sortedKeys(tags) {
+			// Empty tag values aren't allowed by the line protocol.
+			if tags[k] == "" {
+				continue
+			}
+			body.WriteByte(',')
+			body.WriteString(tagEscaper.Replace(k))
+			body.WriteByte('=')
+			body.WriteString(tagEscaper.Replace(tags[k]))
+		}
+		body.WriteString(" value=")
+		body.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+		body.WriteByte(' ')
+		body.WriteString(timestamp)
+		body.WriteByte('\n')
+	}
+	req, err := http.NewRequest(http.MethodPost, db.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if db.config.InfluxDBV2 {
+		req.Header.Set("Authorization", "Token "+db.config.InfluxDBToken)
+	} else if db.config.InfluxDBUsername != "" {
+		req.SetBasicAuth(db.config.InfluxDBUsername, db.config.InfluxDBPassword)
+	}
+	return post(ctx, db.client, req)
+}
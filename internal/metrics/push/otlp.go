@@ -0,0 +1,153 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/params"
+)
+
+// otlpMetricsPath is the path collectors serve OTLP/HTTP metrics on.
+const otlpMetricsPath = "/v1/metrics"
+
+// otlp exports the samples to an OpenTelemetry collector over OTLP/HTTP, in
+// the JSON encoding of ExportMetricsServiceRequest. Counters are cumulative
+// monotonic sums, starting when the node did, everything else is a gauge.
+type otlp struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+	tags    []otlpAttribute
+	started time.Time
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+// aggregationTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const aggregationTemporalityCumulative = 2
+
+func newOTLP(config conf.MetricsConfig, tags map[string]string) (*otlp, error) {
+	endpoint, err := url.Parse(config.OTLPEndpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, want http(s)://host:port[/path]", config.OTLPEndpoint)
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = otlpMetricsPath
+	}
+	headers, err := ParseTags(config.OTLPHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP headers: %v", err)
+	}
+	resource := map[string]string{"service.name": params.ClientName, "service.version": params.VersionWithCommit(params.GitCommit, params.BuildDate)}
+	for k, v := range tags {
+		resource[k] = v
+	}
+	return &otlp{
+		client:  &http.Client{Timeout: requestTimeout},
+		url:     endpoint.String(),
+		headers: headers,
+		tags:    otlpAttributes(resource),
+		started: time.Now(),
+	}, nil
+}
+
+func (o *otlp) name() string { return "otlp" }
+
+func (o *otlp) export(ctx context.Context, samples []sample, now time.Time) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	var (
+		metrics   []*otlpMetric
+		byName    = make(map[string]*otlpMetric)
+		timestamp = strconv.FormatInt(now.UnixNano(), 10)
+		start     = strconv.FormatInt(o.started.UnixNano(), 10)
+	)
+	for _, s := range samples {
+		m := byName[s.name]
+		if m == nil {
+			m = &otlpMetric{Name: s.name}
+			if s.counter {
+				m.Sum = &otlpSum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: true}
+			} else {
+				m.Gauge = &otlpGauge{}
+			}
+			byName[s.name] = m
+			metrics = append(metrics, m)
+		}
+		point := otlpDataPoint{Attributes: otlpAttributes(s.labels), TimeUnixNano: timestamp, AsDouble: s.value}
+		if m.Sum != nil {
+			point.StartTimeUnixNano = start
+			m.Sum.DataPoints = append(m.Sum.DataPoints, point)
+		} else {
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, point)
+		}
+	}
+	request := map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": o.tags},
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope":   map[string]string{"name": params.ClientName, "version": params.VersionWithMeta},
+				"metrics": metrics,
+			}},
+		}},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+	return post(ctx, o.client, req)
+}
+
+func otlpAttributes(labels map[string]string) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(labels))
+	for _, k := range sortedKeys(labels) {
+		var attr otlpAttribute
+		attr.Key, attr.Value.StringValue = k, labels[k]
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
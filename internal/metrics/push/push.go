@@ -0,0 +1,192 @@
+// Package push reports the node metrics to InfluxDB and OTLP collectors at a
+// fixed interval, for monitoring stacks that can't scrape the node.
+package push
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal/metrics/prometheus"
+	"github.com/amazechain/amc/log"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// requestTimeout bounds a single push.
+const requestTimeout = 10 * time.Second
+
+// sample is a single value of a flattened metric family. Summaries and
+// histograms are split into their quantiles or buckets, sum and count.
+type sample struct {
+	name    string
+	labels  map[string]string
+	value   float64
+	counter bool
+}
+
+// exporter sends the samples read at the same time to a collector.
+type exporter interface {
+	name() string
+	export(ctx context.Context, samples []sample, now time.Time) error
+}
+
+// Start pushes the metrics to the exporters enabled in the config until the
+// context is cancelled. The tags are added to every sample.
+func Start(ctx context.Context, config conf.MetricsConfig, tags map[string]string) error {
+	var exporters []exporter
+	if config.InfluxDB || config.InfluxDBV2 {
+		exp, err := newInfluxDB(config, tags)
+		if err != nil {
+			return err
+		}
+		exporters = append(exporters, exp)
+	}
+	if config.OTLPEndpoint != "" {
+		exp, err := newOTLP(config, tags)
+		if err != nil {
+			return err
+		}
+		exporters = append(exporters, exp)
+	}
+	if len(exporters) == 0 {
+		return nil
+	}
+	interval := config.PushInterval
+	if interval <= 0 {
+		return fmt.Errorf("invalid metrics push interval %v", interval)
+	}
+	for _, exp := range exporters {
+		log.Info("Pushing metrics", "exporter", exp.name(), "interval", interval, "tags", tags)
+	}
+	go loop(ctx, exporters, interval)
+	return nil
+}
+
+func loop(ctx context.Context, exporters []exporter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failing := make(map[string]bool)
+	for {
+		select {
+		case now := <-ticker.C:
+			families, err := prometheus.Gather(prometheus.DefaultRegistry)
+			if err != nil {
+				log.Warn("Could not gather metrics", "err", err)
+				continue
+			}
+			samples := flatten(families)
+			for _, exp := range exporters {
+				pushCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+				err := exp.export(pushCtx, samples, now)
+				cancel()
+				// Only the first of a series of failures is logged as a warning.
+				switch {
+				case err != nil && !failing[exp.name()]:
+					log.Warn("Could not push metrics", "exporter", exp.name(), "err", err)
+				case err != nil:
+					log.Debug("Could not push metrics", "exporter", exp.name(), "err", err)
+				case failing[exp.name()]:
+					log.Info("Pushing metrics again", "exporter", exp.name())
+				}
+				failing[exp.name()] = err != nil
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flatten turns metric families into samples, dropping the values the
+// collectors can't store.
+func flatten(families []*dto.MetricFamily) []sample {
+	var samples []sample
+	add := func(name string, m *dto.Metric, extra map[string]string, value float64, counter bool) {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return
+		}
+		labels := make(map[string]string, len(m.GetLabel())+len(extra))
+		for _, pair := range m.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		for k, v := range extra {
+			labels[k] = v
+		}
+		samples = append(samples, sample{name: name, labels: labels, value: value, counter: counter})
+	}
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m, nil, m.GetCounter().GetValue(), true)
+			case dto.MetricType_GAUGE:
+				add(name, m, nil, m.GetGauge().GetValue(), false)
+			case dto.MetricType_UNTYPED:
+				add(name, m, nil, m.GetUntyped().GetValue(), false)
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, m, map[string]string{"quantile": fmt.Sprint(q.GetQuantile())}, q.GetValue(), false)
+				}
+				add(name+"_sum", m, nil, s.GetSampleSum(), true)
+				add(name+"_count", m, nil, float64(s.GetSampleCount()), true)
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", m, map[string]string{"le": fmt.Sprint(b.GetUpperBound())}, float64(b.GetCumulativeCount()), true)
+				}
+				add(name+"_sum", m, nil, h.GetSampleSum(), true)
+				add(name+"_count", m, nil, float64(h.GetSampleCount()), true)
+			}
+		}
+	}
+	return samples
+}
+
+// ParseTags parses comma separated key=value pairs.
+func ParseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid metrics tag %q, want key=value", pair)
+		}
+		tags[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return tags, nil
+}
+
+// sortedKeys returns the keys of m in order, collectors store the series of
+// every distinct order apart.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// post sends the request and fails on any status but 2xx.
+func post(ctx context.Context, client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var msg [512]byte
+		n, _ := resp.Body.Read(msg[:])
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg[:n])))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"context"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	mvm_common "github.com/amazechain/amc/internal/avm/common"
+	mvm_types "github.com/amazechain/amc/internal/avm/types"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+)
+
+// APIs returns the bundle RPC methods. They are only served behind
+// authentication, bundles are meant for the searchers the operator trusts.
+func (m *Miner) APIs() []jsonrpc.API {
+	return []jsonrpc.API{
+		{
+			Namespace:     "eth",
+			Service:       &BundleAPI{m},
+			Authenticated: true,
+		},
+	}
+}
+
+// BundleAPI takes transaction bundles for the blocks built by the node.
+type BundleAPI struct {
+	miner *Miner
+}
+
+// BundleArgs are the arguments of eth_sendBundle and eth_callBundle.
+type BundleArgs struct {
+	Txs               []hexutil.Bytes   `json:"txs"`
+	BlockNumber       hexutil.Uint64    `json:"blockNumber"`
+	MinTimestamp      hexutil.Uint64    `json:"minTimestamp"`
+	MaxTimestamp      hexutil.Uint64    `json:"maxTimestamp"`
+	RevertingTxHashes []mvm_common.Hash `json:"revertingTxHashes"`
+}
+
+// BundleTxResult is the outcome of one transaction of a simulated bundle.
+type BundleTxResult struct {
+	TxHash   mvm_common.Hash `json:"txHash"`
+	GasUsed  hexutil.Uint64  `json:"gasUsed"`
+	Reverted bool            `json:"reverted"`
+}
+
+// CallBundleResult is the outcome of a simulated bundle.
+type CallBundleResult struct {
+	BundleHash     mvm_common.Hash  `json:"bundleHash"`
+	Results        []BundleTxResult `json:"results"`
+	GasUsed        hexutil.Uint64   `json:"totalGasUsed"`
+	CoinbaseDiff   *hexutil.Big     `json:"coinbaseDiff"`
+	BundleGasPrice *hexutil.Big     `json:"bundleGasPrice"`
+}
+
+// SendBundle queues a bundle for the top of its block, the next one unless
+// blockNumber is set. The bundle is simulated on the current head first and
+// refused if a transaction fails or it doesn't pay the coinbase.
+func (api *BundleAPI) SendBundle(ctx context.Context, args BundleArgs) (mvm_common.Hash, error) {
+	bundle, err := api.toBundle(args)
+	if err != nil {
+		return mvm_common.Hash{}, err
+	}
+	hash, err := api.miner.SendBundle(ctx, bundle)
+	if err != nil {
+		return mvm_common.Hash{}, err
+	}
+	return mvm_types.FromAmcHash(hash), nil
+}
+
+// CallBundle simulates a bundle on the current head, as the first
+// transactions of the next block, and returns what each transaction used and
+// what the bundle pays the coinbase.
+func (api *BundleAPI) CallBundle(ctx context.Context, args BundleArgs) (*CallBundleResult, error) {
+	bundle, err := api.toBundle(args)
+	if err != nil {
+		return nil, err
+	}
+	result, err := api.miner.CallBundle(ctx, bundle)
+	if err != nil {
+		return nil, err
+	}
+	res := &CallBundleResult{
+		BundleHash:     mvm_types.FromAmcHash(bundle.Hash()),
+		Results:        make([]BundleTxResult, len(result.Receipts)),
+		GasUsed:        hexutil.Uint64(result.GasUsed),
+		CoinbaseDiff:   (*hexutil.Big)(result.Payment.ToBig()),
+		BundleGasPrice: (*hexutil.Big)(result.GasPrice().ToBig()),
+	}
+	for i, receipt := range result.Receipts {
+		res.Results[i] = BundleTxResult{
+			TxHash:   mvm_types.FromAmcHash(receipt.TxHash),
+			GasUsed:  hexutil.Uint64(receipt.GasUsed),
+			Reverted: receipt.Status == block.ReceiptStatusFailed,
+		}
+	}
+	return res, nil
+}
+
+func (api *BundleAPI) toBundle(args BundleArgs) (*Bundle, error) {
+	var (
+		config = api.miner.worker.chainConfig
+		number = api.miner.worker.chain.CurrentBlock().Number64().ToBig()
+		bundle = &Bundle{
+			Txs:          make([]*transaction.Transaction, len(args.Txs)),
+			BlockNumber:  uint64(args.BlockNumber),
+			MinTimestamp: uint64(args.MinTimestamp),
+			MaxTimestamp: uint64(args.MaxTimestamp),
+			RevertingTxs: make([]types.Hash, len(args.RevertingTxHashes)),
+		}
+	)
+	for i, input := range args.Txs {
+		tx := new(mvm_types.Transaction)
+		if err := tx.UnmarshalBinary(input); err != nil {
+			return nil, err
+		}
+		amcTx, err := tx.ToAmcTransaction(config, number)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Txs[i] = amcTx
+	}
+	for i, hash := range args.RevertingTxHashes {
+		bundle.RevertingTxs[i] = mvm_types.ToAmcHash(hash)
+	}
+	return bundle, nil
+}
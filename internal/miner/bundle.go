@@ -0,0 +1,318 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal"
+	vm2 "github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// maxBundles caps the bundles waiting for their block.
+	maxBundles = 1024
+	// maxBundleTxs caps the transactions of a single bundle.
+	maxBundleTxs = 64
+)
+
+var (
+	ErrEmptyBundle     = errors.New("bundle has no transactions")
+	ErrBundleTooLarge  = fmt.Errorf("bundle has more than %d transactions", maxBundleTxs)
+	ErrBundleStale     = errors.New("bundle targets a past block")
+	ErrBundlePoolFull  = errors.New("bundle pool is full")
+	ErrBundleKnown     = errors.New("bundle already known")
+	ErrBundleReverted  = errors.New("bundle transaction reverted")
+	ErrBundleUnderpaid = errors.New("bundle underpays the coinbase")
+)
+
+// Bundle is an ordered group of transactions included atomically at the top
+// of a block: either all of them go in, in order, or none does.
+type Bundle struct {
+	Txs         []*transaction.Transaction
+	BlockNumber uint64 // block the bundle is meant for
+	// MinTimestamp and MaxTimestamp bound the time of the block, zero leaves
+	// the bound open.
+	MinTimestamp uint64
+	MaxTimestamp uint64
+	// RevertingTxs are the transactions allowed to revert without voiding
+	// the bundle.
+	RevertingTxs []types.Hash
+}
+
+// Hash identifies the bundle by its transactions.
+func (b *Bundle) Hash() types.Hash {
+	hashes := make([][]byte, len(b.Txs))
+	for i, tx := range b.Txs {
+		hash := tx.Hash()
+		hashes[i] = hash.Bytes()
+	}
+	return crypto.Keccak256Hash(hashes...)
+}
+
+// eligible reports whether the bundle may go in the block of the header.
+func (b *Bundle) eligible(header *block.Header) bool {
+	if b.BlockNumber != header.Number.Uint64() {
+		return false
+	}
+	if b.MinTimestamp != 0 && header.Time < b.MinTimestamp {
+		return false
+	}
+	return b.MaxTimestamp == 0 || header.Time <= b.MaxTimestamp
+}
+
+func (b *Bundle) mayRevert(hash types.Hash) bool {
+	for _, h := range b.RevertingTxs {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// BundleResult is the outcome of executing a bundle.
+type BundleResult struct {
+	Receipts []*block.Receipt
+	GasUsed  uint64
+	Payment  *uint256.Int // coinbase balance increase, fees and direct transfers alike
+}
+
+// GasPrice returns what the bundle pays the coinbase per unit of gas.
+func (r *BundleResult) GasPrice() *uint256.Int {
+	if r.GasUsed == 0 {
+		return new(uint256.Int)
+	}
+	return new(uint256.Int).Div(r.Payment, uint256.NewInt(r.GasUsed))
+}
+
+type pooledBundle struct {
+	bundle *Bundle
+	hash   types.Hash
+	price  *uint256.Int // gas price paid when the bundle was simulated
+}
+
+// bundlePool keeps the submitted bundles until their block is past.
+type bundlePool struct {
+	lock    sync.Mutex
+	bundles map[types.Hash]*pooledBundle
+}
+
+func newBundlePool() *bundlePool {
+	return &bundlePool{bundles: make(map[types.Hash]*pooledBundle)}
+}
+
+// add queues a bundle, after dropping the stale ones if the pool is full.
+func (p *bundlePool) add(bundle *Bundle, price *uint256.Int, head uint64) (types.Hash, error) {
+	hash := bundle.Hash()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.bundles[hash]; ok {
+		return hash, ErrBundleKnown
+	}
+	if len(p.bundles) >= maxBundles {
+		p.prune(head + 1)
+		if len(p.bundles) >= maxBundles {
+			return hash, ErrBundlePoolFull
+		}
+	}
+	p.bundles[hash] = &pooledBundle{bundle: bundle, hash: hash, price: price}
+	return hash, nil
+}
+
+// pending returns the bundles eligible for the block of the header, highest
+// paying first.
+func (p *bundlePool) pending(header *block.Header) []*Bundle {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.prune(header.Number.Uint64())
+
+	var eligible []*pooledBundle
+	for _, b := range p.bundles {
+		if b.bundle.eligible(header) {
+			eligible = append(eligible, b)
+		}
+	}
+	sort.Slice(eligible, func(i, j int) bool {
+		if cmp := eligible[i].price.Cmp(eligible[j].price); cmp != 0 {
+			return cmp > 0
+		}
+		return eligible[i].hash.String() < eligible[j].hash.String()
+	})
+	bundles := make([]*Bundle, len(eligible))
+	for i, b := range eligible {
+		bundles[i] = b.bundle
+	}
+	return bundles
+}
+
+// prune drops the bundles meant for blocks before number.
+func (p *bundlePool) prune(number uint64) {
+	for hash, b := range p.bundles {
+		if b.bundle.BlockNumber < number {
+			delete(p.bundles, hash)
+		}
+	}
+}
+
+// applyBundle executes a bundle on top of the block being built. If one of
+// its transactions fails, or reverts without being allowed to, or the
+// coinbase ends up paid less than the minimum gas price, the bundle is left
+// out of the block.
+//
+// Finalised transactions can't be taken back out of the state, so the bundle
+// is first tried on a scratch state over the block state and only executed
+// on the block state once all of it went through. Both runs start from the
+// same state and come to the same result.
+func (w *worker) applyBundle(env *environment, ibs *state.IntraBlockState, bundle *Bundle, getHeader func(hash types.Hash, number uint64) *block.Header) (*BundleResult, error) {
+	trial := &environment{
+		tcount:   env.tcount,
+		gasPool:  new(common.GasPool).AddGas(env.gasPool.Gas()),
+		coinbase: env.coinbase,
+		header:   block.CopyHeader(env.header),
+	}
+	if _, err := w.executeBundle(trial, state.NewScratch(ibs), bundle, getHeader); err != nil {
+		return nil, err
+	}
+	result, err := w.executeBundle(env, ibs, bundle, getHeader)
+	if err != nil {
+		log.Error("Bundle failed on the block state after its trial", "hash", bundle.Hash(), "err", err)
+	}
+	return result, err
+}
+
+// executeBundle executes the transactions of a bundle one after the other,
+// adding them to the environment, and checks what the coinbase was paid. It
+// stops at the first failed transaction, what was executed before stays in
+// the state and the environment.
+func (w *worker) executeBundle(env *environment, ibs *state.IntraBlockState, bundle *Bundle, getHeader func(hash types.Hash, number uint64) *block.Header) (*BundleResult, error) {
+	var (
+		header   = env.header
+		gasUsed  = header.GasUsed
+		receipts = len(env.receipts)
+		balance  = ibs.GetBalance(env.coinbase).Clone()
+		noop     = state.NewNoopWriter()
+	)
+	for _, tx := range bundle.Txs {
+		ibs.Prepare(tx.Hash(), types.Hash{}, env.tcount)
+		receipt, _, err := internal.ApplyTransaction(w.chainConfig, internal.GetHashFn(header, getHeader), w.engine, &env.coinbase, env.gasPool, ibs, noop, header, tx, &header.GasUsed, vm2.Config{})
+		if err != nil {
+			return nil, fmt.Errorf("transaction %v: %w", tx.Hash(), err)
+		}
+		if receipt.Status == block.ReceiptStatusFailed && !bundle.mayRevert(tx.Hash()) {
+			return nil, fmt.Errorf("%w: %v", ErrBundleReverted, tx.Hash())
+		}
+		env.txs = append(env.txs, tx)
+		env.receipts = append(env.receipts, receipt)
+		env.tcount++
+	}
+
+	result := &BundleResult{
+		Receipts: env.receipts[receipts:],
+		GasUsed:  header.GasUsed - gasUsed,
+		Payment:  new(uint256.Int),
+	}
+	if after := ibs.GetBalance(env.coinbase); after.Gt(balance) {
+		result.Payment.Sub(after, balance)
+	}
+	if err := w.checkBundlePayment(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkBundlePayment makes sure a bundle pays the coinbase something, and at
+// least the minimum gas price of the miner if one is set.
+func (w *worker) checkBundlePayment(result *BundleResult) error {
+	if result.Payment.IsZero() {
+		return fmt.Errorf("%w: nothing paid", ErrBundleUnderpaid)
+	}
+	if w.minerConf.GasPrice == nil {
+		return nil
+	}
+	if min, overflow := uint256.FromBig(w.minerConf.GasPrice); !overflow && result.GasPrice().Lt(min) {
+		return fmt.Errorf("%w: gas price %v below the minimum %v", ErrBundleUnderpaid, result.GasPrice(), min)
+	}
+	return nil
+}
+
+// simulateBundle executes a bundle on the state of the current head, as the
+// first transactions of the next block.
+func (w *worker) simulateBundle(ctx context.Context, bundle *Bundle) (*BundleResult, error) {
+	if len(bundle.Txs) == 0 {
+		return nil, ErrEmptyBundle
+	}
+	if len(bundle.Txs) > maxBundleTxs {
+		return nil, ErrBundleTooLarge
+	}
+	w.mu.RLock()
+	coinbase := w.coinbase
+	w.mu.RUnlock()
+
+	tx, err := w.chain.DB().BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	parent := w.chain.CurrentBlock().Header().(*block.Header)
+	timestamp := uint64(time.Now().Unix())
+	if bundle.MinTimestamp > timestamp {
+		timestamp = bundle.MinTimestamp
+	}
+	header := w.makeHeader(parent, timestamp, coinbase)
+	env := &environment{
+		coinbase: coinbase,
+		header:   header,
+		gasPool:  new(common.GasPool).AddGas(header.GasLimit),
+	}
+	getHeader := func(hash types.Hash, number uint64) *block.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	}
+	return w.executeBundle(env, state.New(state.NewPlainStateReader(tx)), bundle, getHeader)
+}
+
+// addBundle checks a bundle by simulating it and queues it for its block,
+// the next one if it names none.
+func (w *worker) addBundle(ctx context.Context, bundle *Bundle) (types.Hash, error) {
+	head := w.chain.CurrentBlock().Number64().Uint64()
+	if bundle.BlockNumber == 0 {
+		bundle.BlockNumber = head + 1
+	}
+	if bundle.BlockNumber <= head {
+		return types.Hash{}, ErrBundleStale
+	}
+	result, err := w.simulateBundle(ctx, bundle)
+	if err != nil {
+		return types.Hash{}, err
+	}
+	return w.bundles.add(bundle, result.GasPrice(), head)
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/core"
+	"github.com/amazechain/amc/internal"
+	vm2 "github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	bundleCoinbase = types.HexToAddress("0xc0")
+	bundleSender   = types.HexToAddress("0x51")
+	bundleReceiver = types.HexToAddress("0x52")
+	// bundleReverter reverts every call: PUSH1 0 PUSH1 0 REVERT.
+	bundleReverter = types.HexToAddress("0x53")
+)
+
+// emptyReader is a state.StateReader over an empty state.
+type emptyReader struct{}
+
+func (emptyReader) ReadAccountData(types.Address) (*account.StateAccount, error) { return nil, nil }
+func (emptyReader) ReadAccountStorage(types.Address, uint16, *types.Hash) ([]byte, error) {
+	return nil, nil
+}
+func (emptyReader) ReadAccountCode(types.Address, uint16, types.Hash) ([]byte, error) {
+	return nil, nil
+}
+func (emptyReader) ReadAccountCodeSize(types.Address, uint16, types.Hash) (int, error) {
+	return 0, nil
+}
+func (emptyReader) ReadAccountIncarnation(types.Address) (uint16, error) { return 0, nil }
+
+// newBundleTest returns a worker and the state and environment of a block
+// being built, in which the sender already spent a transaction.
+func newBundleTest(t *testing.T, minGasPrice *big.Int) (*worker, *environment, *state.IntraBlockState) {
+	w := &worker{
+		chainConfig: params.TestChainConfig,
+		minerConf:   conf.MinerConfig{GasPrice: minGasPrice},
+	}
+	header := &block.Header{
+		Number:     uint256.NewInt(1),
+		GasLimit:   30_000_000,
+		Difficulty: uint256.NewInt(1),
+		Coinbase:   bundleCoinbase,
+	}
+	env := &environment{
+		coinbase: bundleCoinbase,
+		header:   header,
+		gasPool:  new(common.GasPool).AddGas(header.GasLimit),
+	}
+	ibs := state.New(emptyReader{})
+	ibs.AddBalance(bundleSender, uint256.NewInt(1e18))
+	ibs.SetCode(bundleReverter, []byte{0x60, 0x00, 0x60, 0x00, 0xfd})
+
+	tx := bundleTx(0, &bundleReceiver, 1)
+	ibs.Prepare(tx.Hash(), types.Hash{}, env.tcount)
+	receipt, _, err := internal.ApplyTransaction(w.chainConfig, internal.GetHashFn(header, nil), nil, &env.coinbase, env.gasPool, ibs, state.NewNoopWriter(), header, tx, &header.GasUsed, vm2.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.txs = append(env.txs, tx)
+	env.receipts = append(env.receipts, receipt)
+	env.tcount++
+	return w, env, ibs
+}
+
+func bundleTx(nonce uint64, to *types.Address, gasPrice uint64) *transaction.Transaction {
+	return transaction.NewTransaction(nonce, bundleSender, to, uint256.NewInt(1), 100_000, uint256.NewInt(gasPrice), nil)
+}
+
+// checkUntouched makes sure a rejected bundle left the block as it was.
+func checkUntouched(t *testing.T, env *environment, ibs *state.IntraBlockState, gasUsed uint64, balance *uint256.Int) {
+	t.Helper()
+	if len(env.txs) != 1 || len(env.receipts) != 1 || env.tcount != 1 {
+		t.Errorf("environment holds %d txs, %d receipts, count %d, want 1", len(env.txs), len(env.receipts), env.tcount)
+	}
+	if env.header.GasUsed != gasUsed || env.gasPool.Gas() != env.header.GasLimit-gasUsed {
+		t.Errorf("gas used %d, pool %d, want %d used", env.header.GasUsed, env.gasPool.Gas(), gasUsed)
+	}
+	if nonce := ibs.GetNonce(bundleSender); nonce != 1 {
+		t.Errorf("sender nonce = %d, want 1", nonce)
+	}
+	if got := ibs.GetBalance(bundleSender); !got.Eq(balance) {
+		t.Errorf("sender balance = %v, want %v", got, balance)
+	}
+}
+
+func TestApplyBundle(t *testing.T) {
+	w, env, ibs := newBundleTest(t, nil)
+	bundle := &Bundle{Txs: []*transaction.Transaction{
+		bundleTx(1, &bundleReceiver, 2),
+		bundleTx(2, &bundleReceiver, 2),
+	}}
+	result, err := w.applyBundle(env, ibs, bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env.txs) != 3 || len(result.Receipts) != 2 || result.GasUsed != 2*params.TxGas {
+		t.Fatalf("bundle of %d receipts using %d gas, %d txs in the block", len(result.Receipts), result.GasUsed, len(env.txs))
+	}
+	if want := uint256.NewInt(2 * 2 * params.TxGas); !result.Payment.Eq(want) {
+		t.Fatalf("payment = %v, want %v", result.Payment, want)
+	}
+	if nonce := ibs.GetNonce(bundleSender); nonce != 3 {
+		t.Fatalf("sender nonce = %d, want 3", nonce)
+	}
+}
+
+func TestApplyBundleReverting(t *testing.T) {
+	w, env, ibs := newBundleTest(t, nil)
+	var (
+		gasUsed = env.header.GasUsed
+		balance = ibs.GetBalance(bundleSender).Clone()
+		revert  = bundleTx(2, &bundleReverter, 2)
+		bundle  = &Bundle{Txs: []*transaction.Transaction{bundleTx(1, &bundleReceiver, 2), revert}}
+	)
+	if _, err := w.applyBundle(env, ibs, bundle, nil); !errors.Is(err, ErrBundleReverted) {
+		t.Fatalf("err = %v, want %v", err, ErrBundleReverted)
+	}
+	checkUntouched(t, env, ibs, gasUsed, balance)
+
+	// Allowed to revert, the bundle goes in.
+	bundle.RevertingTxs = []types.Hash{revert.Hash()}
+	result, err := w.applyBundle(env, ibs, bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := result.Receipts[1].Status; status != block.ReceiptStatusFailed {
+		t.Fatalf("reverting transaction status = %d", status)
+	}
+}
+
+func TestApplyBundleFailingTx(t *testing.T) {
+	w, env, ibs := newBundleTest(t, nil)
+	var (
+		gasUsed = env.header.GasUsed
+		balance = ibs.GetBalance(bundleSender).Clone()
+		// The second transaction skips a nonce.
+		bundle = &Bundle{Txs: []*transaction.Transaction{bundleTx(1, &bundleReceiver, 2), bundleTx(3, &bundleReceiver, 2)}}
+	)
+	if _, err := w.applyBundle(env, ibs, bundle, nil); !errors.Is(err, core.ErrNonceTooHigh) {
+		t.Fatalf("err = %v, want %v", err, core.ErrNonceTooHigh)
+	}
+	checkUntouched(t, env, ibs, gasUsed, balance)
+}
+
+func TestApplyBundleUnderpaid(t *testing.T) {
+	w, env, ibs := newBundleTest(t, big.NewInt(10))
+	var (
+		gasUsed = env.header.GasUsed
+		balance = ibs.GetBalance(bundleSender).Clone()
+		bundle  = &Bundle{Txs: []*transaction.Transaction{bundleTx(1, &bundleReceiver, 10), bundleTx(2, &bundleReceiver, 1)}}
+	)
+	// The second transaction brings the bundle down to 5 per gas, below
+	// the minimum of 10.
+	if _, err := w.applyBundle(env, ibs, bundle, nil); !errors.Is(err, ErrBundleUnderpaid) {
+		t.Fatalf("err = %v, want %v", err, ErrBundleUnderpaid)
+	}
+	checkUntouched(t, env, ibs, gasUsed, balance)
+}
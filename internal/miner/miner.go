@@ -70,13 +70,17 @@ func (m *Miner) Start() {
 
 func (m *Miner) runLoop() error {
 	defer m.cancel()
-	startCh := make(chan common.DownloaderFinishEvent)
-	doneCh := make(chan common.DownloaderStartEvent)
+	// Sealing pauses while the node syncs, a block on a stale head is wasted.
+	startCh := make(chan common.SyncFinishedEvent)
+	failCh := make(chan common.SyncFailedEvent)
+	doneCh := make(chan common.SyncStartedEvent)
 	start := event.GlobalEvent.Subscribe(startCh)
+	fail := event.GlobalEvent.Subscribe(failCh)
 	done := event.GlobalEvent.Subscribe(doneCh)
 
 	defer func() {
 		start.Unsubscribe()
+		fail.Unsubscribe()
 		done.Unsubscribe()
 	}()
 
@@ -102,14 +106,26 @@ func (m *Miner) runLoop() error {
 					m.worker.start()
 				}
 			}
+		case _, ok := <-failCh:
+			// A failed sync is retried later, keep sealing on the local head meanwhile.
+			if ok {
+				canStart = true
+				if !m.Mining() && shouldStart {
+					m.SetCoinbase(m.coinbase)
+					m.worker.start()
+				}
+			}
 		case _, ok := <-doneCh:
 			if ok {
+				canStart = false
 				if m.Mining() {
 					m.worker.stop()
 				}
 			}
 		case err := <-start.Err():
 			return err
+		case err := <-fail.Err():
+			return err
 		case err := <-done.Err():
 			return err
 		case addr, ok := <-m.startCh:
@@ -148,3 +164,14 @@ func (m *Miner) SetCoinbase(addr types.Address) {
 func (m *Miner) PendingBlockAndReceipts() (block.IBlock, block.Receipts) {
 	return m.worker.pendingBlockAndReceipts()
 }
+
+// SendBundle simulates a bundle on the current head and, if it is valid and
+// pays the coinbase, queues it for the top of its block.
+func (m *Miner) SendBundle(ctx context.Context, bundle *Bundle) (types.Hash, error) {
+	return m.worker.addBundle(ctx, bundle)
+}
+
+// CallBundle simulates a bundle on the current head without queuing it.
+func (m *Miner) CallBundle(ctx context.Context, bundle *Bundle) (*BundleResult, error) {
+	return m.worker.simulateBundle(ctx, bundle)
+}
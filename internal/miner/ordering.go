@@ -0,0 +1,127 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/holiman/uint256"
+)
+
+// txWithTip is the lowest-nonce pending transaction of an account, with the
+// tip it pays the block producer at the base fee of the block being built.
+type txWithTip struct {
+	from types.Address
+	tx   *transaction.Transaction
+	tip  *uint256.Int
+}
+
+// newTxWithTip returns false if the transaction can't pay the base fee.
+func newTxWithTip(from types.Address, tx *transaction.Transaction, baseFee *uint256.Int) (*txWithTip, bool) {
+	if baseFee != nil && tx.GasFeeCapIntCmp(baseFee) < 0 {
+		return nil, false
+	}
+	return &txWithTip{from: from, tx: tx, tip: tx.EffectiveGasTipValue(baseFee)}, true
+}
+
+// txsByTip is a heap of transactions, highest tip first. Equal tips are
+// ordered by hash to build the same block from the same pool.
+type txsByTip []*txWithTip
+
+func (s txsByTip) Len() int { return len(s) }
+func (s txsByTip) Less(i, j int) bool {
+	if cmp := s[i].tip.Cmp(s[j].tip); cmp != 0 {
+		return cmp > 0
+	}
+	hi, hj := s[i].tx.Hash(), s[j].tx.Hash()
+	return bytes.Compare(hi[:], hj[:]) < 0
+}
+func (s txsByTip) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txsByTip) Push(x interface{}) {
+	*s = append(*s, x.(*txWithTip))
+}
+
+func (s *txsByTip) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*s = old[:n-1]
+	return x
+}
+
+// transactionsByTipAndNonce hands out pending transactions by the tip they
+// pay, highest first, while keeping the transactions of every account in
+// nonce order. Transactions whose fee cap is below the base fee are skipped
+// along with the later ones of their account.
+type transactionsByTipAndNonce struct {
+	txs     map[types.Address][]*transaction.Transaction // remaining transactions of every account
+	heads   txsByTip                                     // next transaction of every account
+	baseFee *uint256.Int                                 // nil before EIP-1559
+}
+
+// newTransactionsByTipAndNonce takes the nonce-sorted transactions of every
+// account, as returned by the pool, and consumes the map.
+func newTransactionsByTipAndNonce(txs map[types.Address][]*transaction.Transaction, baseFee *uint256.Int) *transactionsByTipAndNonce {
+	heads := make(txsByTip, 0, len(txs))
+	for from, accTxs := range txs {
+		if len(accTxs) == 0 {
+			delete(txs, from)
+			continue
+		}
+		head, ok := newTxWithTip(from, accTxs[0], baseFee)
+		if !ok {
+			delete(txs, from)
+			continue
+		}
+		heads = append(heads, head)
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(&heads)
+	return &transactionsByTipAndNonce{txs: txs, heads: heads, baseFee: baseFee}
+}
+
+// Peek returns the next transaction by tip, or nil if there is none left.
+func (t *transactionsByTipAndNonce) Peek() *transaction.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0].tx
+}
+
+// Shift replaces the current transaction with the next one of its account.
+func (t *transactionsByTipAndNonce) Shift() {
+	from := t.heads[0].from
+	if txs := t.txs[from]; len(txs) > 0 {
+		if head, ok := newTxWithTip(from, txs[0], t.baseFee); ok {
+			t.heads[0], t.txs[from] = head, txs[1:]
+			heap.Fix(&t.heads, 0)
+			return
+		}
+	}
+	heap.Pop(&t.heads)
+}
+
+// Pop drops the current transaction and the later ones of its account, when
+// it can't be included and neither can the ones depending on its nonce.
+func (t *transactionsByTipAndNonce) Pop() {
+	heap.Pop(&t.heads)
+}
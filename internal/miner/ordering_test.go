@@ -0,0 +1,118 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/holiman/uint256"
+)
+
+func orderingTestTx(nonce, tipCap, feeCap uint64) *transaction.Transaction {
+	return transaction.NewTx(&transaction.DynamicFeeTx{
+		Nonce:     nonce,
+		GasTipCap: uint256.NewInt(tipCap),
+		GasFeeCap: uint256.NewInt(feeCap),
+		Gas:       21000,
+		To:        &bundleReceiver,
+		Value:     uint256.NewInt(0),
+	})
+}
+
+func orderingTestLegacyTx(nonce, price uint64) *transaction.Transaction {
+	return transaction.NewTransaction(nonce, bundleSender, &bundleReceiver, uint256.NewInt(0), 21000, uint256.NewInt(price), nil)
+}
+
+// orderingTestPool returns the pending transactions of four accounts, a
+// new map every call as the ordering consumes it.
+func orderingTestPool() (map[types.Address][]*transaction.Transaction, map[*transaction.Transaction]string) {
+	var (
+		a0, a1 = orderingTestTx(0, 3, 10), orderingTestTx(1, 10, 20)
+		b0, b1 = orderingTestLegacyTx(0, 7), orderingTestLegacyTx(1, 2)
+		c0     = orderingTestLegacyTx(0, 1)
+		d0     = orderingTestTx(0, 4, 9)
+	)
+	pool := map[types.Address][]*transaction.Transaction{
+		types.HexToAddress("0xa"): {a0, a1},
+		types.HexToAddress("0xb"): {b0, b1},
+		types.HexToAddress("0xc"): {c0},
+		types.HexToAddress("0xd"): {d0},
+	}
+	names := map[*transaction.Transaction]string{a0: "a0", a1: "a1", b0: "b0", b1: "b1", c0: "c0", d0: "d0"}
+	return pool, names
+}
+
+func TestTransactionsByTipAndNonce(t *testing.T) {
+	tests := []struct {
+		baseFee *uint256.Int
+		pop     string // transaction dropped along with its account
+		want    []string
+	}{
+		// Before London and at a zero base fee the tips are the full prices
+		// of legacy transactions and the tip caps of dynamic ones.
+		{baseFee: nil, want: []string{"b0", "d0", "a0", "a1", "b1", "c0"}},
+		{baseFee: uint256.NewInt(0), want: []string{"b0", "d0", "a0", "a1", "b1", "c0"}},
+		// The base fee comes off the tips. c0 can't pay it, b1 can't either
+		// and goes after b0.
+		{baseFee: uint256.NewInt(5), want: []string{"d0", "a0", "a1", "b0"}},
+		{baseFee: uint256.NewInt(0), pop: "b0", want: []string{"d0", "a0", "a1", "c0"}},
+		{baseFee: uint256.NewInt(30), want: nil},
+	}
+	for i, tt := range tests {
+		pool, names := orderingTestPool()
+		txs := newTransactionsByTipAndNonce(pool, tt.baseFee)
+		var have []string
+		for tx := txs.Peek(); tx != nil; tx = txs.Peek() {
+			if names[tx] == tt.pop {
+				txs.Pop()
+				continue
+			}
+			have = append(have, names[tx])
+			txs.Shift()
+		}
+		if len(have) != len(tt.want) {
+			t.Errorf("test %d: order %v, want %v", i, have, tt.want)
+			continue
+		}
+		for j := range have {
+			if have[j] != tt.want[j] {
+				t.Errorf("test %d: order %v, want %v", i, have, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestTransactionsByTipAndNonceTie(t *testing.T) {
+	x, y := orderingTestTx(0, 2, 10), orderingTestTx(1, 2, 10)
+	first := x
+	if bytes.Compare(y.Hash().Bytes(), x.Hash().Bytes()) < 0 {
+		first = y
+	}
+	for i := 0; i < 10; i++ {
+		pool := map[types.Address][]*transaction.Transaction{
+			types.HexToAddress("0xa"): {x},
+			types.HexToAddress("0xb"): {y},
+		}
+		if tx := newTransactionsByTipAndNonce(pool, uint256.NewInt(1)).Peek(); tx != first {
+			t.Fatalf("equal tips ordered %x first, want the lower hash %x", tx.Hash(), first.Hash())
+		}
+	}
+}
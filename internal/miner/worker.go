@@ -124,6 +124,9 @@ const (
 	staleThreshold         = 7
 	resubmitAdjustChanSize = 10
 
+	// txChanSize is the size of channel listening to NewTxsEvent.
+	txChanSize = 4096
+
 	// maxRecommitInterval is the maximum time interval to recreate the sealing block with
 	// any newly arrived transactions.
 	maxRecommitInterval = 12 * time.Second
@@ -150,6 +153,7 @@ type worker struct {
 	engine    consensus.Engine
 	chain     common.IBlockChain
 	txsPool   common.ITxsPool
+	bundles   *bundlePool
 
 	coinbase    types.Address
 	chainConfig *params.ChainConfig
@@ -187,6 +191,7 @@ func newWorker(ctx context.Context, group *errgroup.Group, chainConfig *params.C
 		engine:           engine,
 		chain:            bc,
 		txsPool:          txsPool,
+		bundles:          newBundlePool(),
 		chainConfig:      chainConfig,
 		mu:               sync.RWMutex{},
 		startCh:          make(chan struct{}, 1),
@@ -523,6 +528,10 @@ func (w *worker) workLoop(recommit time.Duration) error {
 	newBlockSub := event.GlobalEvent.Subscribe(newBlockCh)
 	defer newBlockSub.Unsubscribe()
 
+	txsCh := make(chan common.NewTxsEvent, txChanSize)
+	txsSub := event.GlobalEvent.Subscribe(txsCh)
+	defer txsSub.Unsubscribe()
+
 	timer := time.NewTimer(0)
 	defer timer.Stop()
 	<-timer.C // discard the initial tick
@@ -566,6 +575,15 @@ func (w *worker) workLoop(recommit time.Duration) error {
 			commit(false, commitInterruptNewHead)
 		case err := <-newBlockSub.Err():
 			return err
+		case <-txsCh:
+			// A zero period chain seals as soon as transactions come in, there
+			// is no block to wait for.
+			if w.isRunning() && w.chainConfig.Clique != nil && w.chainConfig.Clique.Period == 0 {
+				timestamp = time.Now().Unix()
+				commit(true, commitInterruptNone)
+			}
+		case err := <-txsSub.Err():
+			return err
 
 		case <-timer.C:
 			// If sealing is running resubmit a new work cycle periodically to pull in
@@ -591,15 +609,16 @@ func (w *worker) workLoop(recommit time.Duration) error {
 }
 
 func (w *worker) fillTransactions(interrupt *atomic.Int32, env *environment, ibs *state.IntraBlockState, getHeader func(hash types.Hash, number uint64) *block.Header) error {
-	// todo fillTx
 	env.txs = []*transaction.Transaction{}
-	txs, err := w.txsPool.GetTransaction()
-	if err != nil {
-		log.Warn("get transaction error", "err", err)
-		return err
-	}
-
 	header := env.header
+
+	// Transactions go in by the tip they pay on top of the base fee.
+	var baseFee *uint256.Int
+	if w.chainConfig.IsLondon(header.Number.Uint64()) {
+		baseFee = header.BaseFee
+	}
+	pending := w.txsPool.Pending(false)
+	txs := newTransactionsByTipAndNonce(pending, baseFee)
 	noop := state.NewNoopWriter()
 	var miningCommitTx = func(txn *transaction.Transaction, coinbase types.Address, vmConfig *vm2.Config, chainConfig *params.ChainConfig, ibs *state.IntraBlockState, current *environment) ([]*block.Log, error) {
 		ibs.Prepare(txn.Hash(), types.Hash{}, env.tcount)
@@ -618,8 +637,21 @@ func (w *worker) fillTransactions(interrupt *atomic.Int32, env *environment, ibs
 		return receipt.Logs, nil
 	}
 
-	log.Tracef("fillTransactions txs len:%d", len(txs))
-	for _, tx := range txs {
+	// Bundles take the top of the block, highest paying first. Their
+	// transactions come up again from the pool with a nonce too low.
+	for _, bundle := range w.bundles.pending(header) {
+		if interrupt != nil {
+			if signal := interrupt.Load(); signal != commitInterruptNone {
+				return signalToErr(signal)
+			}
+		}
+		if _, err := w.applyBundle(env, ibs, bundle, getHeader); err != nil {
+			log.Debug("Bundle left out of the block", "hash", bundle.Hash(), "number", header.Number.Uint64(), "err", err)
+		}
+	}
+
+	log.Tracef("fillTransactions accounts:%d", len(pending))
+	for {
 		// Check interruption signal and abort building if it's fired.
 		if interrupt != nil {
 			if signal := interrupt.Load(); signal != commitInterruptNone {
@@ -630,21 +662,27 @@ func (w *worker) fillTransactions(interrupt *atomic.Int32, env *environment, ibs
 			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
 			break
 		}
+		tx := txs.Peek()
+		if tx == nil {
+			break
+		}
 		// Start executing the transaction
 		_, err := miningCommitTx(tx, env.coinbase, &vm2.Config{}, w.chainConfig, ibs, env)
 
 		switch {
 		case errors.Is(err, core.ErrGasLimitReached):
-			continue
+			// The account's later transactions wouldn't fit either.
+			txs.Pop()
 		case errors.Is(err, core.ErrNonceTooHigh):
-			continue
+			txs.Pop()
 		case errors.Is(err, core.ErrNonceTooLow):
-			continue
+			txs.Shift()
 		case errors.Is(err, nil):
 			env.tcount++
-			continue
+			txs.Shift()
 		default:
 			log.Error("miningCommitTx failed ", "error", err)
+			txs.Pop()
 		}
 	}
 
@@ -655,8 +693,6 @@ func (w *worker) prepareWork(param *generateParams) (*environment, error) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
-	timestamp := param.timestamp
-
 	parent := w.chain.CurrentBlock().Header().(*block.Header)
 	if param.parentHash != (types.Hash{}) {
 		b, _ := w.chain.GetBlockByHash(param.parentHash)
@@ -666,19 +702,30 @@ func (w *worker) prepareWork(param *generateParams) (*environment, error) {
 		parent = b.Header().(*block.Header)
 	}
 
-	if parent.Time >= param.timestamp {
+	header := w.makeHeader(parent, param.timestamp, param.coinbase)
+	if err := w.engine.Prepare(w.chain, header); err != nil {
+		return nil, err
+	}
+
+	return w.makeEnv(parent, header, param.coinbase), nil
+}
+
+// makeHeader returns the header of a block on top of parent, before the
+// consensus engine prepares it.
+func (w *worker) makeHeader(parent *block.Header, timestamp uint64, coinbase types.Address) *block.Header {
+	if parent.Time >= timestamp {
 		timestamp = parent.Time + 1
 	}
 
 	header := &block.Header{
 		//Root:       parent.StateRoot(),
 		ParentHash: parent.Hash(),
-		Coinbase:   param.coinbase,
+		Coinbase:   coinbase,
 		Number:     uint256.NewInt(0).Add(parent.Number64(), uint256.NewInt(1)),
 		GasLimit:   CalcGasLimit(parent.GasLimit, w.minerConf.GasCeil),
-		Time:       uint64(timestamp),
+		Time:       timestamp,
 		Difficulty: uint256.NewInt(0),
-		// just for now
+		// Headers before EIP-1559 carry a zero base fee, as they do on the wire.
 		BaseFee: uint256.NewInt(0),
 	}
 
@@ -690,12 +737,7 @@ func (w *worker) prepareWork(param *generateParams) (*environment, error) {
 			header.GasLimit = CalcGasLimit(parentGasLimit, w.minerConf.GasCeil)
 		}
 	}
-
-	if err := w.engine.Prepare(w.chain, header); err != nil {
-		return nil, err
-	}
-
-	return w.makeEnv(parent, header, param.coinbase), nil
+	return header
 }
 
 func (w *worker) makeEnv(parent *block.Header, header *block.Header, coinbase types.Address) *environment {
@@ -0,0 +1,558 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/amazechain/amc/params"
+	"github.com/amazechain/amc/utils"
+	"github.com/holiman/uint256"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// apis returns the collection of built-in RPC APIs.
+func (n *Node) apis() []jsonrpc.API {
+	return []jsonrpc.API{
+		{
+			Namespace:     "admin",
+			Service:       &adminAPI{n},
+			Authenticated: true,
+		},
+	}
+}
+
+// adminAPI is the collection of administrative API methods exposed over
+// the IPC and authenticated transports.
+type adminAPI struct {
+	node *Node // Node interfaced by this API
+}
+
+// NodeInfo represents a short summary of the information known about the host.
+type NodeInfo struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	ENR            string   `json:"enr"`
+	ListenAddrs    []string `json:"listenAddrs"`
+	DiscoveryAddrs []string `json:"discoveryAddrs"`
+	Protocols      []string `json:"protocols"`
+	Chain          struct {
+		Genesis types.Hash   `json:"genesis"`
+		Head    types.Hash   `json:"head"`
+		Number  *uint256.Int `json:"number"`
+	} `json:"chain"`
+}
+
+// PeerInfo represents a short summary of a connected peer.
+type PeerInfo struct {
+	ID        string           `json:"id"`
+	ENR       string           `json:"enr,omitempty"`
+	Address   string           `json:"address"`
+	Direction string           `json:"direction"`
+	State     string           `json:"state"`
+	Height    *uint256.Int     `json:"height,omitempty"`
+	Score     float64          `json:"score"`
+	Trusted   bool             `json:"trusted"`
+	Static    bool             `json:"static"`
+	Traffic   *p2p.PeerTraffic `json:"traffic"`
+}
+
+// BannedPeerInfo represents a banned peer along with the expiry of its ban,
+// which is left out for permanent bans.
+type BannedPeerInfo struct {
+	ID    string     `json:"id"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// parsePeer converts an enode, enr or multiaddr url into libp2p dial info.
+func parsePeer(url string) (*peer.AddrInfo, error) {
+	addrs, err := p2p.PeersFromStringAddrs([]string{url})
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer url: %v", err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("invalid peer url: %s", url)
+	}
+	info, err := peer.AddrInfoFromP2pAddr(addrs[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer url: %v", err)
+	}
+	return info, nil
+}
+
+// parsePeerID accepts either a peer ID or a peer url.
+func parsePeerID(id string) (peer.ID, error) {
+	if pid, err := peer.Decode(id); err == nil {
+		return pid, nil
+	}
+	info, err := parsePeer(id)
+	if err != nil {
+		return "", err
+	}
+	return info.ID, nil
+}
+
+// AddPeer adds the given node to the static peers, which are dialed in the
+// background and redialed whenever the connection drops.
+func (api *adminAPI) AddPeer(url string) (bool, error) {
+	info, err := parsePeer(url)
+	if err != nil {
+		return false, err
+	}
+	api.node.p2p.AddStaticPeer(*info)
+	return true, nil
+}
+
+// RemovePeer removes a node from the static peers and disconnects from it if
+// the connection exists.
+func (api *adminAPI) RemovePeer(url string) (bool, error) {
+	info, err := parsePeer(url)
+	if err != nil {
+		return false, err
+	}
+	api.node.p2p.RemoveStaticPeer(info.ID)
+	if err := api.node.p2p.Disconnect(info.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddTrustedPeer marks the given remote node as trusted and connects to it.
+// Trusted peers are let in beyond the peer limit and never evicted to make
+// room for other inbound peers.
+func (api *adminAPI) AddTrustedPeer(url string) (bool, error) {
+	info, err := parsePeer(url)
+	if err != nil {
+		return false, err
+	}
+	api.node.p2p.Peers().SetTrusted(info.ID, true)
+	if err := api.node.p2p.Host().Connect(api.node.ctx, *info); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// RemoveTrustedPeer removes a remote node from the trusted peer set, but it
+// does not disconnect it automatically.
+func (api *adminAPI) RemoveTrustedPeer(url string) (bool, error) {
+	info, err := parsePeer(url)
+	if err != nil {
+		return false, err
+	}
+	api.node.p2p.Peers().SetTrusted(info.ID, false)
+	return true, nil
+}
+
+// BanPeer disconnects a peer and refuses its connections for the given
+// number of seconds, or for good if no duration is given. The ban is kept
+// across restarts.
+func (api *adminAPI) BanPeer(id string, seconds *uint64) (bool, error) {
+	pid, err := parsePeerID(id)
+	if err != nil {
+		return false, err
+	}
+	var duration time.Duration
+	if seconds != nil {
+		duration = time.Duration(*seconds) * time.Second
+	}
+	if err := api.node.p2p.BanPeer(pid, duration); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanPeer lifts the ban of a peer, whether it was banned by hand or for
+// its score.
+func (api *adminAPI) UnbanPeer(id string) (bool, error) {
+	pid, err := parsePeerID(id)
+	if err != nil {
+		return false, err
+	}
+	if err := api.node.p2p.UnbanPeer(pid); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BannedPeers lists the banned peers.
+func (api *adminAPI) BannedPeers() []*BannedPeerInfo {
+	banned := api.node.p2p.Peers().Banned()
+	infos := make([]*BannedPeerInfo, 0, len(banned))
+	for pid, until := range banned {
+		info := &BannedPeerInfo{ID: pid.String()}
+		if !until.IsZero() {
+			until := until
+			info.Until = &until
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Peers retrieves all the information we know about each individual connected peer.
+func (api *adminAPI) Peers() ([]*PeerInfo, error) {
+	status := api.node.p2p.Peers()
+	connected := status.Connected()
+	infos := make([]*PeerInfo, 0, len(connected))
+	for _, pid := range connected {
+		info := &PeerInfo{
+			ID:      pid.String(),
+			Score:   status.Scorers().Score(pid),
+			Trusted: status.IsTrusted(pid),
+			Static:  api.node.p2p.IsStatic(pid),
+			Traffic: api.node.p2p.PeerTraffic(pid),
+		}
+		if addr, err := status.Address(pid); err == nil && addr != nil {
+			info.Address = addr.String()
+		}
+		if dir, err := status.Direction(pid); err == nil {
+			info.Direction = dir.String()
+		}
+		if state, err := status.ConnState(pid); err == nil {
+			info.State = state.String()
+		}
+		if record, err := status.ENR(pid); err == nil && record != nil {
+			if enr, err := p2p.SerializeENR(record); err == nil {
+				info.ENR = "enr:" + enr
+			}
+		}
+		if chainState, err := status.ChainState(pid); err == nil && chainState != nil {
+			info.Height = utils.ConvertH256ToUint256Int(chainState.CurrentHeight)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// NodeInfo retrieves all the information we know about the host node.
+func (api *adminAPI) NodeInfo() (*NodeInfo, error) {
+	host := api.node.p2p.Host()
+	info := &NodeInfo{
+		ID:   host.ID().String(),
+		Name: params.ClientVersion(),
+	}
+	if record := api.node.p2p.ENR(); record != nil {
+		if enr, err := p2p.SerializeENR(record); err == nil {
+			info.ENR = "enr:" + enr
+		}
+	}
+	for _, addr := range host.Addrs() {
+		info.ListenAddrs = append(info.ListenAddrs, addr.String())
+	}
+	if addrs, err := api.node.p2p.DiscoveryAddresses(); err == nil {
+		for _, addr := range addrs {
+			info.DiscoveryAddrs = append(info.DiscoveryAddrs, addr.String())
+		}
+	}
+	for _, proto := range host.Mux().Protocols() {
+		info.Protocols = append(info.Protocols, string(proto))
+	}
+	current := api.node.blockChain.CurrentBlock()
+	info.Chain.Genesis = api.node.blockChain.GenesisBlock().Hash()
+	info.Chain.Head = current.Hash()
+	info.Chain.Number = current.Number64()
+	return info, nil
+}
+
+// Datadir retrieves the current data directory the node is using.
+func (api *adminAPI) Datadir() string {
+	return api.node.InstanceDir()
+}
+
+// SetLogLevel sets the level a module logs at, without a restart. A module is
+// a package path such as internal/sync, whose level also holds below it, or
+// one of the downloader, txpool, consensus, miner, p2p, vm, rpc and state
+// aliases. The "root" module sets the level of all the others, and the
+// "default" level makes a module log at the level of its parent again.
+func (api *adminAPI) SetLogLevel(module string, level string) (bool, error) {
+	if level == "default" {
+		if module == "" || module == "root" {
+			return false, errors.New("the root module has no parent level")
+		}
+		if err := log.ResetModuleLevel(module); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return false, err
+	}
+	if module == "" || module == "root" {
+		log.SetLevel(lvl)
+		return true, nil
+	}
+	if err := log.SetModuleLevel(module, lvl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LogLevels returns the root log level and the levels set per module.
+func (api *adminAPI) LogLevels() map[string]string {
+	levels := log.ModuleLevels()
+	levels["root"] = log.Level().String()
+	return levels
+}
+
+// StartHTTP starts the HTTP RPC API server.
+func (api *adminAPI) StartHTTP(host *string, port *int, cors *string, apis *string, vhosts *string) (bool, error) {
+	api.node.lock.Lock()
+	defer api.node.lock.Unlock()
+
+	// Determine host and port.
+	if host == nil {
+		h := api.node.config.NodeCfg.HTTPHost
+		host = &h
+	}
+	if port == nil {
+		p, _ := strconv.Atoi(api.node.config.NodeCfg.HTTPPort)
+		port = &p
+	}
+
+	// Determine config.
+	limits, err := api.node.rpcLimits()
+	if err != nil {
+		return false, err
+	}
+	access, err := api.node.rpcAccess()
+	if err != nil {
+		return false, err
+	}
+	config := httpConfig{
+		CorsAllowedOrigins: utils.SplitAndTrim(api.node.config.NodeCfg.HTTPCors),
+		Vhosts:             utils.SplitAndTrim(api.node.config.NodeCfg.HTTPVirtualHosts),
+		Modules:            utils.SplitAndTrim(api.node.config.NodeCfg.HTTPApi),
+		limits:             limits,
+		access:             access,
+	}
+	if cors != nil {
+		config.CorsAllowedOrigins = nil
+		for _, origin := range strings.Split(*cors, ",") {
+			config.CorsAllowedOrigins = append(config.CorsAllowedOrigins, strings.TrimSpace(origin))
+		}
+	}
+	if vhosts != nil {
+		config.Vhosts = nil
+		for _, vhost := range strings.Split(*vhosts, ",") {
+			config.Vhosts = append(config.Vhosts, strings.TrimSpace(vhost))
+		}
+	}
+	if apis != nil {
+		config.Modules = nil
+		for _, m := range strings.Split(*apis, ",") {
+			config.Modules = append(config.Modules, strings.TrimSpace(m))
+		}
+	}
+
+	openAPIs, allAPIs := api.node.getAPIs()
+	if err := validateModules("http", config.Modules, openAPIs, allAPIs); err != nil {
+		return false, err
+	}
+	if err := validateOrigins("http", config.CorsAllowedOrigins); err != nil {
+		return false, err
+	}
+	if err := validateVhosts("http", config.Vhosts); err != nil {
+		return false, err
+	}
+	if err := api.node.http.setListenAddr(*host, *port); err != nil {
+		return false, err
+	}
+	if err := api.node.http.enableRPC(openAPIs, config); err != nil {
+		return false, err
+	}
+	if err := api.node.http.start(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StopHTTP shuts down the HTTP server.
+func (api *adminAPI) StopHTTP() (bool, error) {
+	api.node.http.stop()
+	return true, nil
+}
+
+// StartWS starts the websocket RPC API server.
+func (api *adminAPI) StartWS(host *string, port *int, allowedOrigins *string, apis *string) (bool, error) {
+	api.node.lock.Lock()
+	defer api.node.lock.Unlock()
+
+	// Determine host and port.
+	if host == nil {
+		h := api.node.config.NodeCfg.WSHost
+		host = &h
+	}
+	if port == nil {
+		p, _ := strconv.Atoi(api.node.config.NodeCfg.WSPort)
+		port = &p
+	}
+
+	// Determine config.
+	limits, err := api.node.rpcLimits()
+	if err != nil {
+		return false, err
+	}
+	access, err := api.node.rpcAccess()
+	if err != nil {
+		return false, err
+	}
+	config := wsConfig{
+		Modules: utils.SplitAndTrim(api.node.config.NodeCfg.WSApi),
+		Origins: utils.SplitAndTrim(api.node.config.NodeCfg.WSOrigins),
+		limits:  limits,
+		access:  access,
+	}
+	if apis != nil {
+		config.Modules = nil
+		for _, m := range strings.Split(*apis, ",") {
+			config.Modules = append(config.Modules, strings.TrimSpace(m))
+		}
+	}
+	if allowedOrigins != nil {
+		config.Origins = nil
+		for _, origin := range strings.Split(*allowedOrigins, ",") {
+			config.Origins = append(config.Origins, strings.TrimSpace(origin))
+		}
+	}
+
+	// Enable WebSocket on the server.
+	openAPIs, allAPIs := api.node.getAPIs()
+	if err := validateModules("ws", config.Modules, openAPIs, allAPIs); err != nil {
+		return false, err
+	}
+	if err := validateOrigins("ws", config.Origins); err != nil {
+		return false, err
+	}
+	server := api.node.ws
+	if err := server.setListenAddr(*host, *port); err != nil {
+		return false, err
+	}
+	if err := server.enableWS(openAPIs, config); err != nil {
+		return false, err
+	}
+	if err := server.start(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StopWS terminates all WebSocket servers.
+func (api *adminAPI) StopWS() (bool, error) {
+	api.node.ws.stopWS()
+	api.node.ws.stop()
+	return true, nil
+}
+
+// APIKeys returns the settings and usage of the API keys of the public
+// endpoints, leaving out the keys themselves.
+func (api *adminAPI) APIKeys() ([]jsonrpc.APIKeyUsage, error) {
+	if api.node.apiKeys == nil {
+		return nil, errAPIKeysDisabled
+	}
+	return api.node.apiKeys.Usage(), nil
+}
+
+// AddAPIKey admits a new API key, or updates the settings of an existing one.
+// Keys added here are lost on restart unless they are also in the key file.
+func (api *adminAPI) AddAPIKey(key jsonrpc.APIKey) (bool, error) {
+	if api.node.apiKeys == nil {
+		return false, errAPIKeysDisabled
+	}
+	if err := api.node.apiKeys.AddKey(key); err != nil {
+		return false, err
+	}
+	log.Info("Added RPC API key", "name", key.Name, "namespaces", key.Namespaces, "rate", key.RequestsPerSecond)
+	return true, nil
+}
+
+// RemoveAPIKey revokes an API key.
+func (api *adminAPI) RemoveAPIKey(key string) (bool, error) {
+	if api.node.apiKeys == nil {
+		return false, errAPIKeysDisabled
+	}
+	return api.node.apiKeys.RemoveKey(key), nil
+}
+
+// ReloadAPIKeys replaces the API keys with the ones in the key file.
+func (api *adminAPI) ReloadAPIKeys() (bool, error) {
+	if api.node.apiKeys == nil {
+		return false, errAPIKeysDisabled
+	}
+	keys, err := jsonrpc.LoadAPIKeys(api.node.config.NodeCfg.RPCAPIKeys)
+	if err != nil {
+		return false, err
+	}
+	if err := api.node.apiKeys.SetKeys(keys); err != nil {
+		return false, err
+	}
+	log.Info("Reloaded RPC API keys", "path", api.node.config.NodeCfg.RPCAPIKeys, "keys", len(keys))
+	return true, nil
+}
+
+// ExportChain exports the current blockchain into a local file,
+// or a range of blocks if first and last are non-nil.
+func (api *adminAPI) ExportChain(file string, first *uint64, last *uint64) (bool, error) {
+	if first == nil && last != nil {
+		return false, fmt.Errorf("last cannot be specified without first")
+	}
+	from, to := uint64(0), api.node.blockChain.CurrentBlock().Number64().Uint64()
+	if first != nil {
+		from = *first
+	}
+	if last != nil {
+		to = *last
+	}
+	if _, err := os.Stat(file); err == nil {
+		// File already exists. Allowing overwrite could be a DoS vector,
+		// since the 'file' may point to arbitrary paths on the drive.
+		return false, fmt.Errorf("location would overwrite an existing file")
+	}
+	if err := ExportChain(api.node.blockChain, file, from, to); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImportChain imports a blockchain from a local file.
+func (api *adminAPI) ImportChain(file string) (bool, error) {
+	if err := ImportChain(api.node.ctx, api.node.blockChain, file); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Backup writes a consistent copy of the chain database, the keystore and the
+// network key to dest, a directory that must not exist yet. The node keeps
+// running while the copy is made.
+func (api *adminAPI) Backup(dest string) (bool, error) {
+	if err := api.node.Backup(api.node.ctx, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
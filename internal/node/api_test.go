@@ -0,0 +1,130 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"net"
+	"testing"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/internal/p2p/enode"
+	"github.com/amazechain/amc/log"
+	libp2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestParsePeer(t *testing.T) {
+	_, pub, err := libp2pcrypto.GenerateSecp256k1Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enodeURL := enode.NewV4(&key.PublicKey, net.IPv4(10, 0, 0, 1), 30303, 30303).URLv4()
+
+	info, err := parsePeer("/ip4/10.0.0.1/tcp/30303/p2p/" + pid.String())
+	if err != nil {
+		t.Fatalf("multiaddr: %v", err)
+	}
+	if info.ID != pid || len(info.Addrs) != 1 || info.Addrs[0].String() != "/ip4/10.0.0.1/tcp/30303" {
+		t.Errorf("multiaddr parsed to %v", info)
+	}
+	enodeInfo, err := parsePeer(enodeURL)
+	if err != nil {
+		t.Fatalf("enode: %v", err)
+	}
+	if len(enodeInfo.Addrs) != 1 || enodeInfo.Addrs[0].String() != "/ip4/10.0.0.1/tcp/30303" {
+		t.Errorf("enode parsed to %v", enodeInfo)
+	}
+	for _, url := range []string{"", "garbage", "/ip4/10.0.0.1/tcp/30303", "enode://00@10.0.0.1:30303"} {
+		if _, err := parsePeer(url); err == nil {
+			t.Errorf("parsed invalid peer url %q", url)
+		}
+	}
+
+	// Peers are named by their bare IDs as well as their urls.
+	for url, want := range map[string]peer.ID{
+		pid.String(): pid,
+		"/ip4/10.0.0.1/tcp/30303/p2p/" + pid.String(): pid,
+		enodeURL: enodeInfo.ID,
+	} {
+		have, err := parsePeerID(url)
+		if err != nil {
+			t.Errorf("%q: %v", url, err)
+		} else if have != want {
+			t.Errorf("%q: peer %s, want %s", url, have, want)
+		}
+	}
+	if _, err := parsePeerID("garbage"); err == nil {
+		t.Error("parsed an invalid peer ID")
+	}
+}
+
+func TestAdminSetLogLevel(t *testing.T) {
+	root := log.Level()
+	t.Cleanup(func() {
+		log.SetLevel(root)
+		log.Vmodule("")
+	})
+	api := &adminAPI{}
+
+	if _, err := api.SetLogLevel("txpool", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.SetLogLevel("internal/sync", "trace"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.SetLogLevel("root", "warn"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"root": "warn", "internal/txspool": "debug", "internal/sync": "trace"}
+	if have := api.LogLevels(); len(have) != len(want) {
+		t.Fatalf("levels %v, want %v", have, want)
+	} else {
+		for module, lvl := range want {
+			if have[module] != lvl {
+				t.Errorf("module %s at %q, want %q", module, have[module], lvl)
+			}
+		}
+	}
+
+	// Resetting a module drops its own level.
+	if _, err := api.SetLogLevel("txpool", "default"); err != nil {
+		t.Fatal(err)
+	}
+	if lvl, ok := api.LogLevels()["internal/txspool"]; ok {
+		t.Errorf("reset module still at %q", lvl)
+	}
+	if _, err := api.SetLogLevel("root", "default"); err == nil {
+		t.Error("reset the root level")
+	}
+	if _, err := api.SetLogLevel("", "default"); err == nil {
+		t.Error("reset the root level by its empty name")
+	}
+	if _, err := api.SetLogLevel("txpool", "loud"); err == nil {
+		t.Error("set an unknown level")
+	}
+	if log.Level().String() != "warn" {
+		t.Errorf("root level %s after failed calls, want warn", log.Level())
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/pebbledb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// backupKeyStore is the directory of the key files in a backup.
+const backupKeyStore = "keystore"
+
+var (
+	errBackupExists   = errors.New("backup destination already exists")
+	errRestoreExists  = errors.New("refusing to overwrite existing data")
+	errInMemoryBackup = errors.New("an in-memory database can't be backed up")
+)
+
+// Backup writes a consistent copy of the chain database of the running node
+// to dest, along with the keystore and the network key. Blocks keep being
+// processed meanwhile: the copy is the database as it was when it started.
+func (n *Node) Backup(ctx context.Context, dest string) error {
+	if n.config.NodeCfg.DataDir == "" {
+		return errInMemoryBackup
+	}
+	return backupDataDir(ctx, n.db, n.config, dest)
+}
+
+// BackupDatabase backs up the data directory of a stopped node, the same way
+// Node.Backup does for a running one.
+func BackupDatabase(ctx context.Context, cfg *conf.Config, dest string) error {
+	dbPath := filepath.Join(cfg.NodeCfg.DataDir, kv.ChainDB.String())
+	engine := existingEngine(dbPath)
+	if engine == "" {
+		return fmt.Errorf("no chain database in %s", dbPath)
+	}
+	db, err := openKV(dbPath, engine, nil, false, true)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", dbPath, err)
+	}
+	defer db.Close()
+	return backupDataDir(ctx, db, cfg, dest)
+}
+
+// backupDataDir writes the backup layout to dest: the chain database under its
+// usual name, the key files in backupKeyStore and the network key, if the node
+// keeps one on disk.
+func backupDataDir(ctx context.Context, db kv.RoDB, cfg *conf.Config, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%w: %s", errBackupExists, dest)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(dest, 0700); err != nil {
+		return err
+	}
+	start := time.Now()
+	log.Info("Backing up chain database", "dest", dest)
+	dbPath := filepath.Join(dest, kv.ChainDB.String())
+	if err := snapshotDatabase(ctx, db, dbPath); err != nil {
+		return fmt.Errorf("database snapshot: %w", err)
+	}
+
+	keydir, err := cfg.NodeCfg.KeyDirConfig()
+	if err != nil {
+		return err
+	}
+	if keydir != "" {
+		if err := copyFiles(keydir, filepath.Join(dest, backupKeyStore)); err != nil {
+			return fmt.Errorf("keystore: %w", err)
+		}
+	}
+	if key := nodeKeyFile(cfg); key != "" {
+		if err := copyFile(key, p2p.NodeKeyFile(dest)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("network key: %w", err)
+		}
+	}
+	size, err := dirSize(dest)
+	if err != nil {
+		return err
+	}
+	log.Info("Backup complete", "dest", dest, "size", types.StorageSize(size), "elapsed", time.Since(start))
+	return nil
+}
+
+// snapshotDatabase copies the database to dir. Pebble writes a checkpoint of
+// itself. For MDBX every table is copied from a single read transaction, which
+// sees the database as of its beginning however long the copy takes; the data
+// file may grow meanwhile, since the pages it reads can't be reused.
+func snapshotDatabase(ctx context.Context, db kv.RoDB, dir string) error {
+	if pdb, ok := db.(*pebbledb.DB); ok {
+		return pdb.Checkpoint(dir)
+	}
+	dst, err := openKV(dir, "mdbx", nil, true, false)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	srcTx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcTx.Rollback()
+	for _, table := range modules.AmcTables {
+		started := time.Now()
+		if err := copyTable(ctx, srcTx, dst, table, nil, nil); err != nil {
+			return fmt.Errorf("copying %s: %w", table, err)
+		}
+		log.Info("Copied table", "table", table, "elapsed", time.Since(started))
+	}
+	return nil
+}
+
+// RestoreDatabase restores a backup made by Node.Backup or BackupDatabase into
+// the data directory. It refuses to overwrite a chain database, key file or
+// network key already there, so the node must be stopped and its datadir
+// cleared first.
+func RestoreDatabase(cfg *conf.Config, src string) error {
+	if cfg.NodeCfg.DataDir == "" {
+		return errors.New("no data directory to restore into")
+	}
+	var (
+		srcDB  = filepath.Join(src, kv.ChainDB.String())
+		dstDB  = filepath.Join(cfg.NodeCfg.DataDir, kv.ChainDB.String())
+		srcKey = p2p.NodeKeyFile(src)
+		dstKey = nodeKeyFile(cfg)
+	)
+	if existingEngine(srcDB) == "" {
+		return fmt.Errorf("no chain database in %s", srcDB)
+	}
+	if existingEngine(dstDB) != "" {
+		return fmt.Errorf("%w: chain database in %s", errRestoreExists, dstDB)
+	}
+	keydir, err := cfg.NodeCfg.KeyDirConfig()
+	if err != nil {
+		return err
+	}
+	keys, err := os.ReadDir(filepath.Join(src, backupKeyStore))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, key := range keys {
+		if _, err := os.Stat(filepath.Join(keydir, key.Name())); err == nil {
+			return fmt.Errorf("%w: key file %s", errRestoreExists, key.Name())
+		}
+	}
+	_, err = os.Stat(srcKey)
+	hasKey := err == nil && dstKey != ""
+	if hasKey {
+		if _, err := os.Stat(dstKey); err == nil {
+			return fmt.Errorf("%w: network key %s", errRestoreExists, dstKey)
+		}
+	}
+
+	log.Info("Restoring chain database", "src", src, "datadir", cfg.NodeCfg.DataDir)
+	if err := copyFiles(srcDB, dstDB); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if err := copyFiles(filepath.Join(src, backupKeyStore), keydir); err != nil {
+			return err
+		}
+	}
+	if hasKey {
+		if err := copyFile(srcKey, dstKey); err != nil {
+			return err
+		}
+	}
+	log.Info("Restore complete", "datadir", cfg.NodeCfg.DataDir, "keys", len(keys), "nodekey", hasKey)
+	return nil
+}
+
+// nodeKeyFile returns the file the network key is read from, none if the key
+// is given in hex.
+func nodeKeyFile(cfg *conf.Config) string {
+	switch {
+	case cfg.P2PCfg.PrivateKeyHex != "":
+		return ""
+	case cfg.P2PCfg.PrivateKey != "":
+		return cfg.P2PCfg.PrivateKey
+	}
+	return p2p.NodeKeyFile(cfg.NodeCfg.DataDir)
+}
+
+// copyFiles copies the regular files of a directory into another one, leaving
+// out the lock file of MDBX. A missing source directory copies nothing.
+func copyFiles(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || entry.Name() == "mdbx.lck" {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies a file to a new one, keeping its permissions.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
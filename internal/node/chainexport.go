@@ -0,0 +1,324 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// The tables written by ExportTables. The columns are part of the output
+// format: new ones may be appended, existing ones are never renamed, removed
+// or reordered. Amounts are decimal strings, hashes, addresses and byte
+// strings are 0x prefixed hex.
+var (
+	blockColumns = []string{
+		"number", "hash", "parent_hash", "timestamp", "miner", "state_root", "transactions_root",
+		"receipts_root", "gas_limit", "gas_used", "base_fee_per_gas", "extra_data", "transaction_count",
+	}
+	transactionColumns = []string{
+		"block_number", "block_hash", "transaction_index", "hash", "type", "from", "to", "nonce",
+		"value", "gas", "gas_price", "max_fee_per_gas", "max_priority_fee_per_gas", "input",
+	}
+	receiptColumns = []string{
+		"block_number", "block_hash", "transaction_index", "transaction_hash", "status",
+		"cumulative_gas_used", "gas_used", "effective_gas_price", "contract_address", "log_count",
+	}
+	logColumns = []string{
+		"block_number", "block_hash", "transaction_index", "transaction_hash", "log_index", "address",
+		"topic0", "topic1", "topic2", "topic3", "data",
+	}
+)
+
+// tableWriter writes the rows of a table, their values in column order.
+type tableWriter interface {
+	write(values []interface{}) error
+	close() error
+}
+
+// ndjsonTable writes a JSON object per line, its keys in column order.
+type ndjsonTable struct {
+	fh      *os.File
+	w       *bufio.Writer
+	columns []string
+}
+
+func (t *ndjsonTable) write(values []interface{}) error {
+	t.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			t.w.WriteByte(',')
+		}
+		enc, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		t.w.WriteString(strconv.Quote(t.columns[i]))
+		t.w.WriteByte(':')
+		t.w.Write(enc)
+	}
+	t.w.WriteByte('}')
+	return t.w.WriteByte('\n')
+}
+
+func (t *ndjsonTable) close() error {
+	if err := t.w.Flush(); err != nil {
+		t.fh.Close()
+		return err
+	}
+	return t.fh.Close()
+}
+
+// csvTable writes a header line and a record per row, null values are left empty.
+type csvTable struct {
+	fh *os.File
+	w  *csv.Writer
+}
+
+func (t *csvTable) write(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			record[i] = v
+		case uint64:
+			record[i] = strconv.FormatUint(v, 10)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTable) close() error {
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		t.fh.Close()
+		return err
+	}
+	return t.fh.Close()
+}
+
+func createTable(dir, name, format string, columns []string) (tableWriter, error) {
+	fh, err := os.Create(filepath.Join(dir, name+"."+format))
+	if err != nil {
+		return nil, err
+	}
+	if format == "ndjson" {
+		return &ndjsonTable{fh: fh, w: bufio.NewWriter(fh), columns: columns}, nil
+	}
+	t := &csvTable{fh: fh, w: csv.NewWriter(bufio.NewWriter(fh))}
+	if err := t.w.Write(columns); err != nil {
+		fh.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// ExportTables writes the canonical blocks from first to last, with their
+// transactions, receipts and logs, to the flat files blocks, transactions,
+// receipts and logs in dir, for loading into analytical databases. The format
+// is "ndjson", a JSON object per line, or "csv", with a header line. Receipts
+// and logs need the receipts of the whole range, which fails if they were
+// pruned.
+func ExportTables(ctx context.Context, chain common.IBlockChain, dir, format string, first, last uint64) (err error) {
+	if format != "ndjson" && format != "csv" {
+		return fmt.Errorf("export failed: unknown format %q", format)
+	}
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if head := chain.CurrentBlock().Number64().Uint64(); last > head {
+		return fmt.Errorf("export failed: last (%d) is above the head (%d)", last, head)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	log.Info("Exporting chain tables", "dir", dir, "format", format, "first", first, "last", last)
+
+	var tables [4]tableWriter
+	for i, name := range []string{"blocks", "transactions", "receipts", "logs"} {
+		columns := [][]string{blockColumns, transactionColumns, receiptColumns, logColumns}[i]
+		if tables[i], err = createTable(dir, name, format, columns); err != nil {
+			break
+		}
+	}
+	defer func() {
+		for _, t := range tables {
+			if t == nil {
+				continue
+			}
+			if cerr := t.close(); err == nil {
+				err = cerr
+			}
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	return chain.DB().View(ctx, func(tx kv.Tx) error {
+		from, err := rawdb.ReceiptsAvailableFrom(tx)
+		if err != nil {
+			return err
+		}
+		if first < from {
+			return fmt.Errorf("export failed: receipts start at block %d", from)
+		}
+		var (
+			start  = time.Now()
+			logged = start
+		)
+		for nr := first; nr <= last; nr++ {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			hash, err := rawdb.ReadCanonicalHash(tx, nr)
+			if err != nil {
+				return err
+			}
+			b, senders, err := rawdb.ReadBlockWithSenders(tx, hash, nr)
+			if err != nil {
+				return err
+			}
+			if b == nil {
+				return fmt.Errorf("export failed on #%d: not found", nr)
+			}
+			receipts := rawdb.ReadReceipts(tx, b, senders)
+			if len(receipts) != len(b.Transactions()) {
+				return fmt.Errorf("export failed on #%d: receipts not found", nr)
+			}
+			if err := exportBlockRows(tables, b, receipts); err != nil {
+				return err
+			}
+			if time.Since(logged) > verifyLogInterval {
+				log.Info("Exporting chain tables", "number", nr, "last", last, "elapsed", time.Since(start))
+				logged = time.Now()
+			}
+		}
+		log.Info("Exported chain tables", "dir", dir, "elapsed", time.Since(start))
+		return nil
+	})
+}
+
+// exportBlockRows writes the rows of a block to the blocks, transactions,
+// receipts and logs tables.
+func exportBlockRows(tables [4]tableWriter, b *block.Block, receipts block.Receipts) error {
+	var (
+		header = b.Header().(*block.Header)
+		number = header.Number.Uint64()
+		hash   = b.Hash().Hex()
+		txs    = b.Transactions()
+	)
+	if err := tables[0].write([]interface{}{
+		number, hash, header.ParentHash.Hex(), header.Time, header.Coinbase.Hex(), header.Root.Hex(), header.TxHash.Hex(),
+		header.ReceiptHash.Hex(), header.GasLimit, header.GasUsed, decimal(header.BaseFee), hexutil.Encode(header.Extra), uint64(len(txs)),
+	}); err != nil {
+		return err
+	}
+	for i, tx := range txs {
+		index := uint64(i)
+		if err := tables[1].write([]interface{}{
+			number, hash, index, tx.Hash().Hex(), uint64(tx.Type()), addressValue(tx.From()), addressValue(tx.To()), tx.Nonce(),
+			decimal(tx.Value()), tx.Gas(), decimal(tx.GasPrice()), feeCap(tx), tipCap(tx), hexutil.Encode(tx.Data()),
+		}); err != nil {
+			return err
+		}
+		r := receipts[i]
+		var contract interface{}
+		if tx.To() == nil {
+			contract = r.ContractAddress.Hex()
+		}
+		if err := tables[2].write([]interface{}{
+			number, hash, index, r.TxHash.Hex(), r.Status,
+			r.CumulativeGasUsed, r.GasUsed, decimal(effectiveGasPrice(tx, header.BaseFee)), contract, uint64(len(r.Logs)),
+		}); err != nil {
+			return err
+		}
+		for _, l := range r.Logs {
+			topics := make([]interface{}, 4)
+			for j, topic := range l.Topics {
+				if j < len(topics) {
+					topics[j] = topic.Hex()
+				}
+			}
+			if err := tables[3].write([]interface{}{
+				number, hash, index, r.TxHash.Hex(), uint64(l.Index), l.Address.Hex(),
+				topics[0], topics[1], topics[2], topics[3], hexutil.Encode(l.Data),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decimal formats an amount, nil if there is none.
+func decimal(v *uint256.Int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return v.Dec()
+}
+
+func addressValue(addr *types.Address) interface{} {
+	if addr == nil {
+		return nil
+	}
+	return addr.Hex()
+}
+
+// feeCap and tipCap are only set for the transactions with a fee market.
+func feeCap(tx *transaction.Transaction) interface{} {
+	if tx.Type() == transaction.LegacyTxType {
+		return nil
+	}
+	return decimal(tx.GasFeeCap())
+}
+
+func tipCap(tx *transaction.Transaction) interface{} {
+	if tx.Type() == transaction.LegacyTxType {
+		return nil
+	}
+	return decimal(tx.GasTipCap())
+}
+
+// effectiveGasPrice is the price per gas the sender paid.
+func effectiveGasPrice(tx *transaction.Transaction, baseFee *uint256.Int) *uint256.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	return new(uint256.Int).Add(baseFee, tx.EffectiveGasTipValue(baseFee))
+}
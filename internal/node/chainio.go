@@ -0,0 +1,139 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/log"
+	"github.com/holiman/uint256"
+)
+
+// importBatchSize is the number of blocks inserted at once during an import.
+const importBatchSize = 2500
+
+// Exported chains are a stream of RLP strings, one per block, each holding
+// the protobuf encoding blocks travel in over p2p. Files ending in .gz are
+// gzip compressed.
+
+// ImportChain inserts the blocks of an exported chain file. Blocks already in
+// the chain are skipped, so a partial import can simply be run again.
+func ImportChain(ctx context.Context, chain common.IBlockChain, fn string) error {
+	log.Info("Importing blockchain", "file", fn)
+
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	stream := rlp.NewStream(reader, 0)
+
+	blocks := make([]block.IBlock, 0, importBatchSize)
+	for n, eof := 0, false; !eof; {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		blocks = blocks[:0]
+		for len(blocks) < importBatchSize {
+			data, err := stream.Bytes()
+			if errors.Is(err, io.EOF) {
+				eof = true
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("at block %d: %v", n, err)
+			}
+			b := new(block.Block)
+			if err := b.Unmarshal(data); err != nil {
+				return fmt.Errorf("at block %d: %v", n, err)
+			}
+			n++
+			// Don't import the genesis block, nor anything already known.
+			if b.Number64().IsZero() || chain.HasBlock(b.Hash(), b.Number64().Uint64()) {
+				continue
+			}
+			blocks = append(blocks, b)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if _, err := chain.InsertChain(blocks); err != nil {
+			return fmt.Errorf("invalid block %d: %v", n, err)
+		}
+		log.Info("Imported blocks", "count", len(blocks), "number", blocks[len(blocks)-1].Number64().Uint64())
+	}
+	return nil
+}
+
+// ExportChain writes the blocks from first to last into a chain file,
+// replacing the file if it exists.
+func ExportChain(chain common.IBlockChain, fn string, first, last uint64) error {
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if head := chain.CurrentBlock().Number64().Uint64(); last > head {
+		return fmt.Errorf("export failed: last (%d) is above the head (%d)", last, head)
+	}
+	log.Info("Exporting blockchain", "file", fn, "first", first, "last", last)
+
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		gz := gzip.NewWriter(writer)
+		defer gz.Close()
+		writer = gz
+	}
+	for nr := first; nr <= last; nr++ {
+		b, err := chain.GetBlockByNumber(uint256.NewInt(nr))
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return fmt.Errorf("export failed on #%d: not found", nr)
+		}
+		data, err := b.(*block.Block).Marshal()
+		if err != nil {
+			return err
+		}
+		if err := rlp.Encode(writer, data); err != nil {
+			return err
+		}
+	}
+	log.Info("Exported blockchain", "file", fn)
+	return nil
+}
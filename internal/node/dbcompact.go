@@ -0,0 +1,139 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/amazechain/amc/common/paths"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/pebbledb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var errLowDiskSpace = errors.New("not enough free disk space to compact the database")
+
+// CompactDatabase compacts the chain database of a stopped node and returns
+// its size before and after. A Pebble database is compacted in place, table
+// by table. An MDBX one reuses its free pages but never shrinks, so it is
+// copied into a new file the way PruneState does, without pruning anything.
+// Either way the disk must keep DBCompactMinFreeDisk gigabytes free, beside
+// the room for the copy.
+func CompactDatabase(ctx context.Context, cfg *conf.Config) (before, after uint64, err error) {
+	dbPath := filepath.Join(cfg.NodeCfg.DataDir, kv.ChainDB.String())
+	engine := existingEngine(dbPath)
+	if engine == "" {
+		return 0, 0, fmt.Errorf("no chain database in %s", dbPath)
+	}
+	if before, err = dirSize(dbPath); err != nil {
+		return 0, 0, err
+	}
+	need := uint64(cfg.NodeCfg.DBCompactMinFreeDisk) * 1024 * 1024 * 1024
+	if engine == "mdbx" {
+		need += before
+	}
+	if err := checkFreeSpace(dbPath, need); err != nil {
+		return 0, 0, err
+	}
+
+	db, err := openKV(dbPath, engine, nil, true, false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not open %s, is the node still running? %w", dbPath, err)
+	}
+	if pdb, ok := db.(*pebbledb.DB); ok {
+		err = compactTables(ctx, pdb)
+		db.Close()
+		if err != nil {
+			return 0, 0, err
+		}
+		after, err = dirSize(dbPath)
+		return before, after, err
+	}
+
+	var from uint64
+	if err := db.View(ctx, func(tx kv.Tx) (err error) {
+		from, err = rawdb.ReadStatePruneProgress(tx)
+		return err
+	}); err != nil {
+		db.Close()
+		return 0, 0, err
+	}
+	log.Info("Rebuilding chain database", "path", dbPath)
+	return rebuildDatabase(ctx, dbPath, engine, db, from)
+}
+
+// checkFreeSpace fails if the disk holding path has less than need bytes free.
+func checkFreeSpace(path string, need uint64) error {
+	free, err := paths.FreeDiskSpace(path)
+	if err != nil {
+		return err
+	}
+	if free < need {
+		return fmt.Errorf("%w: %s free, %s needed", errLowDiskSpace, types.StorageSize(free), types.StorageSize(need))
+	}
+	return nil
+}
+
+// compactTables compacts the tables of a Pebble database one after the other,
+// logging the progress.
+func compactTables(ctx context.Context, db *pebbledb.DB) error {
+	start := time.Now()
+	for i, table := range modules.AmcTables {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tstart := time.Now()
+		if err := db.CompactTable(table); err != nil {
+			return fmt.Errorf("compact %s: %w", table, err)
+		}
+		log.Info("Compacted table", "table", table, "progress", fmt.Sprintf("%d/%d", i+1, len(modules.AmcTables)), "elapsed", time.Since(tstart))
+	}
+	log.Info("Compacted chain database", "elapsed", time.Since(start))
+	return nil
+}
+
+// compactLoop compacts the Pebble chain database of the running node at every
+// interval, unless the disk is running out of space.
+func (n *Node) compactLoop(db *pebbledb.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	dbPath := filepath.Join(n.config.NodeCfg.DataDir, kv.ChainDB.String())
+	need := uint64(n.config.NodeCfg.DBCompactMinFreeDisk) * 1024 * 1024 * 1024
+	for {
+		select {
+		case <-ticker.C:
+			if err := checkFreeSpace(dbPath, need); err != nil {
+				log.Warn("Skipping scheduled database compaction", "err", err)
+				continue
+			}
+			if err := compactTables(n.ctx, db); err != nil && n.ctx.Err() == nil {
+				log.Warn("Scheduled database compaction failed", "err", err)
+			}
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/freezer"
+	"github.com/amazechain/amc/modules/ethdb/pebbledb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+)
+
+// ancientDir is the directory of the ancient store in the data directory.
+const ancientDir = "ancient"
+
+// tableCategories groups the tables by what they hold, for InspectDatabase.
+// The tables not listed fall into "other".
+var tableCategories = map[string]string{
+	modules.Headers:         "headers",
+	modules.HeaderTD:        "headers",
+	modules.HeaderCanonical: "headers",
+	modules.HeaderNumber:    "headers",
+	modules.TrustedHeaders:  "headers",
+
+	modules.BlockBody:       "bodies",
+	modules.BlockTx:         "bodies",
+	modules.NonCanonicalTxs: "bodies",
+	modules.Senders:         "bodies",
+
+	modules.Receipts: "receipts",
+	modules.Log:      "receipts",
+
+	modules.Account:           "state",
+	modules.Storage:           "state",
+	modules.Code:              "state",
+	modules.PlainContractCode: "state",
+	modules.IncarnationMap:    "state",
+	modules.Reward:            "state",
+	modules.Deposit:           "state",
+	modules.DepositLifecycle:  "state",
+
+	modules.AccountChangeSet: "history",
+	modules.StorageChangeSet: "history",
+	modules.AccountsHistory:  "history",
+	modules.StorageHistory:   "history",
+	modules.DepositJournal:   "history",
+
+	modules.TxLookup:        "index",
+	modules.LogTopicIndex:   "index",
+	modules.LogAddressIndex: "index",
+	modules.CallFromIndex:   "index",
+	modules.CallToIndex:     "index",
+
+	modules.Preimages: "preimages",
+}
+
+// TableStat is the size of a table of the chain database.
+type TableStat struct {
+	Name     string
+	Category string
+	Entries  uint64
+	Size     uint64 // bytes on disk, an estimate for some engines
+}
+
+// openChainDatabase opens the chain database in the data directory read-only,
+// with the engine it was created with.
+func openChainDatabase(cfg *conf.Config) (kv.RwDB, error) {
+	dbPath := filepath.Join(cfg.NodeCfg.DataDir, kv.ChainDB.String())
+	engine := existingEngine(dbPath)
+	if engine == "" {
+		return nil, fmt.Errorf("no chain database in %s", dbPath)
+	}
+	db, err := openKV(dbPath, engine, nil, false, true)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", dbPath, err)
+	}
+	return db, nil
+}
+
+// InspectDatabase counts the entries of every table of the chain database and
+// the space they take, ordered by category and name, followed by the blocks
+// in the ancient store.
+func InspectDatabase(ctx context.Context, cfg *conf.Config) ([]TableStat, error) {
+	db, err := openChainDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var stats []TableStat
+	err = db.View(ctx, func(tx kv.Tx) error {
+		for _, table := range modules.AmcTables {
+			c, err := tx.Cursor(table)
+			if err != nil {
+				return err
+			}
+			entries, err := c.Count()
+			c.Close()
+			if err != nil {
+				return fmt.Errorf("count %s: %w", table, err)
+			}
+			size, err := tx.BucketSize(table)
+			if err != nil {
+				return fmt.Errorf("size of %s: %w", table, err)
+			}
+			category, ok := tableCategories[table]
+			if !ok {
+				category = "other"
+			}
+			stats = append(stats, TableStat{Name: table, Category: category, Entries: entries, Size: size})
+		}
+		return nil
+	})
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Category != stats[j].Category {
+			return stats[i].Category < stats[j].Category
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	frozen, err := freezer.Inspect(filepath.Join(cfg.NodeCfg.DataDir, ancientDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range []string{freezer.Hashes, freezer.Headers, freezer.Bodies, freezer.Receipts} {
+		stats = append(stats, TableStat{Name: kind, Category: "ancient", Entries: frozen[kind][0], Size: frozen[kind][1]})
+	}
+	return stats, nil
+}
+
+// DatabaseStats describes the chain database as its engine sees it.
+func DatabaseStats(ctx context.Context, cfg *conf.Config) (string, error) {
+	db, err := openChainDatabase(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	switch db := db.(type) {
+	case *pebbledb.DB:
+		return db.Metrics().String(), nil
+	case *mdbx.MdbxKV:
+		var b strings.Builder
+		err := db.View(ctx, func(tx kv.Tx) error {
+			stat, err := db.Env().Stat()
+			if err != nil {
+				return err
+			}
+			info, err := db.Env().Info(nil)
+			if err != nil {
+				return err
+			}
+			free, err := tx.BucketSize("freelist")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&b, "engine:          mdbx\n")
+			fmt.Fprintf(&b, "page size:       %d\n", stat.PSize)
+			fmt.Fprintf(&b, "tree depth:      %d\n", stat.Depth)
+			fmt.Fprintf(&b, "branch pages:    %d\n", stat.BranchPages)
+			fmt.Fprintf(&b, "leaf pages:      %d\n", stat.LeafPages)
+			fmt.Fprintf(&b, "overflow pages:  %d\n", stat.OverflowPages)
+			fmt.Fprintf(&b, "file size:       %d\n", info.Geo.Current)
+			fmt.Fprintf(&b, "free list size:  %d\n", free)
+			fmt.Fprintf(&b, "last txn:        %d\n", info.LastTxnID)
+			fmt.Fprintf(&b, "readers:         %d/%d\n", info.NumReaders, info.MaxReaders)
+			return nil
+		})
+		return b.String(), err
+	}
+	return "", fmt.Errorf("no engine stats for %T", db)
+}
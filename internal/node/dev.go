@@ -0,0 +1,63 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/accounts/keystore"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/log"
+)
+
+// developerKeyStore opens the keystore of a dev node and unlocks the developer
+// account, the etherbase if one is set, the first account in the keystore
+// otherwise. A key is generated if the keystore is empty. The developer
+// account has no passphrase.
+func developerKeyStore(keyDir string, cfg *conf.Config) (*keystore.KeyStore, types.Address, error) {
+	if cfg.NodeCfg.ExternalSigner != "" {
+		return nil, types.Address{}, errors.New("a dev node seals with a local key, it can't use an external signer")
+	}
+	scryptN, scryptP, err := keystore.ScryptParams(cfg.NodeCfg.UseLightweightKDF, cfg.NodeCfg.ScryptN, cfg.NodeCfg.ScryptP)
+	if err != nil {
+		return nil, types.Address{}, err
+	}
+	ks := keystore.NewKeyStore(keyDir, scryptN, scryptP)
+
+	var account accounts.Account
+	switch {
+	case cfg.Miner.Etherbase != "":
+		if account, err = ks.Find(accounts.Account{Address: types.HexToAddress(cfg.Miner.Etherbase)}); err != nil {
+			return nil, types.Address{}, fmt.Errorf("developer account %s: %v", cfg.Miner.Etherbase, err)
+		}
+	case len(ks.Accounts()) > 0:
+		account = ks.Accounts()[0]
+	default:
+		if account, err = ks.NewAccount(""); err != nil {
+			return nil, types.Address{}, fmt.Errorf("failed to create the developer account: %v", err)
+		}
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		return nil, types.Address{}, fmt.Errorf("failed to unlock the developer account %s, it needs an empty passphrase: %v", account.Address, err)
+	}
+	log.Info("Using developer account", "address", account.Address)
+	cfg.Miner.Etherbase = account.Address.Hex()
+	return ks, account.Address, nil
+}
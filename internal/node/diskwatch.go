@@ -0,0 +1,96 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"time"
+
+	"github.com/amazechain/amc/common/paths"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal"
+	"github.com/amazechain/amc/log"
+)
+
+// diskCheckInterval is how often the free space of the data directory is read.
+const diskCheckInterval = 30 * time.Second
+
+// diskWatchdog keeps an eye on the volume of the data directory. Below the
+// soft threshold it warns, below the hard one it pauses the block import so
+// that the database isn't left half written by a full disk, while the RPC
+// keeps serving what is stored. The import resumes once the free space is
+// back above the soft threshold.
+type diskWatchdog struct {
+	path  string
+	chain *internal.BlockChain
+	soft  uint64
+	hard  uint64
+}
+
+// newDiskWatchdog takes the thresholds in GB. A soft threshold not above the
+// hard one is set to twice the hard one.
+func newDiskWatchdog(path string, chain *internal.BlockChain, soft, hard int) *diskWatchdog {
+	w := &diskWatchdog{
+		path:  path,
+		chain: chain,
+		soft:  uint64(soft) * 1024 * 1024 * 1024,
+		hard:  uint64(hard) * 1024 * 1024 * 1024,
+	}
+	if w.soft <= w.hard {
+		w.soft = 2 * w.hard
+	}
+	return w
+}
+
+func (n *Node) diskWatchLoop(w *diskWatchdog) {
+	ticker := time.NewTicker(diskCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := w.check(); err != nil {
+			log.Warn("Failed to get free disk space", "path", w.path, "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+// check reads the free space once and pauses or resumes the import.
+func (w *diskWatchdog) check() error {
+	free, err := paths.FreeDiskSpace(w.path)
+	if err != nil {
+		return err
+	}
+	paused := w.chain.ImportPaused() != ""
+	switch {
+	case free < w.hard:
+		if !paused {
+			log.Error("Disk space critically low, pausing block import to protect the database",
+				"available", types.StorageSize(free), "critical", types.StorageSize(w.hard), "path", w.path)
+		}
+		w.chain.PauseImport("low disk space")
+	case free < w.soft:
+		if !paused {
+			log.Warn("Disk space is running low, block import will pause below the critical level",
+				"available", types.StorageSize(free), "critical", types.StorageSize(w.hard), "path", w.path)
+		}
+	case paused:
+		w.chain.ResumeImport()
+	}
+	return nil
+}
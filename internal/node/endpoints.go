@@ -17,9 +17,19 @@
 package node
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/amazechain/amc/conf"
 	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/amazechain/amc/utils"
 )
 
+// allModules is the module list entry selecting every namespace available on
+// a transport.
+const allModules = "all"
+
 func checkModuleAvailability(modules []string, apis []jsonrpc.API) (bad, available []string) {
 	availableSet := make(map[string]struct{})
 	for _, api := range apis {
@@ -29,9 +39,131 @@ func checkModuleAvailability(modules []string, apis []jsonrpc.API) (bad, availab
 		}
 	}
 	for _, name := range modules {
-		if _, ok := availableSet[name]; !ok && name != jsonrpc.JSONRPCApi {
+		if _, ok := availableSet[name]; !ok && name != jsonrpc.JSONRPCApi && !strings.EqualFold(name, allModules) {
 			bad = append(bad, name)
 		}
 	}
 	return bad, available
 }
+
+// validateModules ensures every namespace configured for a transport is
+// registered and may be served by it. Namespaces marked as authenticated are
+// only ever found in the full api list, not in the open one.
+func validateModules(transport string, modules []string, apis, allAPIs []jsonrpc.API) error {
+	bad, available := checkModuleAvailability(modules, apis)
+	for _, name := range bad {
+		if missing, _ := checkModuleAvailability([]string{name}, allAPIs); len(missing) == 0 {
+			return fmt.Errorf("%s: namespace %q is only served over IPC and the authenticated endpoint", transport, name)
+		}
+		return fmt.Errorf("%s: unknown namespace %q (available: %s)", transport, name, strings.Join(available, ","))
+	}
+	return nil
+}
+
+// validateOrigins checks that every allowed CORS or websocket origin is either
+// the "*" wildcard or an origin URL like "https://example.com".
+func validateOrigins(transport string, origins []string) error {
+	for _, origin := range origins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("%s: invalid origin %q, want scheme://host[:port] or *", transport, origin)
+		}
+	}
+	return nil
+}
+
+// validateVhosts checks that the virtual host allowlist only holds bare
+// hostnames or the "*" wildcard.
+func validateVhosts(transport string, vhosts []string) error {
+	for _, vhost := range vhosts {
+		if vhost == "*" {
+			continue
+		}
+		if strings.ContainsAny(vhost, "/:*") {
+			return fmt.Errorf("%s: invalid virtual host %q, want a hostname or *", transport, vhost)
+		}
+	}
+	return nil
+}
+
+// authModules returns the namespaces served by the authenticated endpoint,
+// defaulting to every namespace that requires authentication.
+func authModules(cfg *conf.NodeConfig, apis []jsonrpc.API) []string {
+	if modules := utils.SplitAndTrim(cfg.AuthApi); len(modules) > 0 {
+		return modules
+	}
+	var modules []string
+	for _, api := range apis {
+		if api.Authenticated {
+			modules = append(modules, api.Namespace)
+		}
+	}
+	return modules
+}
+
+// filterAPIs returns the apis belonging to one of the given namespaces, or
+// all of them if no namespace is given.
+func filterAPIs(apis []jsonrpc.API, modules []string) []jsonrpc.API {
+	if len(modules) == 0 {
+		return apis
+	}
+	whitelist := make(map[string]bool)
+	for _, module := range modules {
+		if strings.EqualFold(module, allModules) {
+			return apis
+		}
+		whitelist[module] = true
+	}
+	var filtered []jsonrpc.API
+	for _, api := range apis {
+		if whitelist[api.Namespace] {
+			filtered = append(filtered, api)
+		}
+	}
+	return filtered
+}
+
+// validateRPCConfig checks the namespaces, origins and virtual hosts of all
+// enabled transports, so that a misconfiguration fails the node start instead
+// of silently exposing or hiding methods.
+func validateRPCConfig(cfg *conf.NodeConfig, openAPIs, allAPIs []jsonrpc.API) error {
+	if cfg.HTTP {
+		if err := validateModules("http", utils.SplitAndTrim(cfg.HTTPApi), openAPIs, allAPIs); err != nil {
+			return err
+		}
+		if err := validateOrigins("http", utils.SplitAndTrim(cfg.HTTPCors)); err != nil {
+			return err
+		}
+		if err := validateVhosts("http", utils.SplitAndTrim(cfg.HTTPVirtualHosts)); err != nil {
+			return err
+		}
+	}
+	if cfg.WS {
+		if err := validateModules("ws", utils.SplitAndTrim(cfg.WSApi), openAPIs, allAPIs); err != nil {
+			return err
+		}
+		if err := validateOrigins("ws", utils.SplitAndTrim(cfg.WSOrigins)); err != nil {
+			return err
+		}
+	}
+	if cfg.IPCPath != "" {
+		if err := validateModules("ipc", utils.SplitAndTrim(cfg.IPCApi), allAPIs, allAPIs); err != nil {
+			return err
+		}
+	}
+	if cfg.AuthRPC {
+		if err := validateModules("authrpc", authModules(cfg, allAPIs), allAPIs, allAPIs); err != nil {
+			return err
+		}
+		if err := validateOrigins("authrpc", utils.SplitAndTrim(cfg.AuthCors)); err != nil {
+			return err
+		}
+		if err := validateVhosts("authrpc", utils.SplitAndTrim(cfg.AuthVirtualHosts)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+)
+
+var (
+	endpointsTestOpenAPIs = []jsonrpc.API{{Namespace: "eth"}, {Namespace: "eth"}, {Namespace: "net"}, {Namespace: "web3"}}
+	endpointsTestAllAPIs  = []jsonrpc.API{
+		{Namespace: "eth"}, {Namespace: "eth"}, {Namespace: "net"}, {Namespace: "web3"},
+		{Namespace: "admin", Authenticated: true},
+		{Namespace: "personal", Authenticated: true},
+	}
+)
+
+func TestValidateModules(t *testing.T) {
+	tests := []struct {
+		modules []string
+		err     string
+	}{
+		{modules: nil},
+		{modules: []string{"eth", "net"}},
+		{modules: []string{"eth", "rpc"}},
+		{modules: []string{"ALL"}},
+		{modules: []string{"eth", "admin"}, err: `namespace "admin" is only served over IPC`},
+		{modules: []string{"debug"}, err: `unknown namespace "debug" (available: eth,net,web3)`},
+	}
+	for _, tt := range tests {
+		err := validateModules("http", tt.modules, endpointsTestOpenAPIs, endpointsTestAllAPIs)
+		if tt.err == "" && err != nil {
+			t.Errorf("%v: %v", tt.modules, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%v: error %v, want %q", tt.modules, err, tt.err)
+		}
+	}
+}
+
+func TestValidateOrigins(t *testing.T) {
+	for origin, valid := range map[string]bool{
+		"*":                        true,
+		"https://example.com":      true,
+		"http://localhost:8080":    true,
+		"https://example.com/":     true,
+		"example.com":              false,
+		"https://":                 false,
+		"https://example.com/path": false,
+		"://example.com":           false,
+	} {
+		if err := validateOrigins("http", []string{origin}); (err == nil) != valid {
+			t.Errorf("origin %q: error %v, want valid %v", origin, err, valid)
+		}
+	}
+}
+
+func TestValidateVhosts(t *testing.T) {
+	for vhost, valid := range map[string]bool{
+		"*":                    true,
+		"localhost":            true,
+		"node.example.com":     true,
+		"localhost:8545":       false,
+		"http://localhost":     false,
+		"*.example.com":        false,
+		"node.example.com/rpc": false,
+	} {
+		if err := validateVhosts("http", []string{vhost}); (err == nil) != valid {
+			t.Errorf("vhost %q: error %v, want valid %v", vhost, err, valid)
+		}
+	}
+}
+
+func TestAuthModules(t *testing.T) {
+	cfg := &conf.NodeConfig{}
+	if have := authModules(cfg, endpointsTestAllAPIs); strings.Join(have, ",") != "admin,personal" {
+		t.Errorf("default authenticated namespaces %v, want [admin personal]", have)
+	}
+	cfg.AuthApi = "eth, admin"
+	if have := authModules(cfg, endpointsTestAllAPIs); strings.Join(have, ",") != "eth,admin" {
+		t.Errorf("configured authenticated namespaces %v, want [eth admin]", have)
+	}
+}
+
+func TestFilterAPIs(t *testing.T) {
+	namespaces := func(apis []jsonrpc.API) string {
+		var names []string
+		for _, api := range apis {
+			names = append(names, api.Namespace)
+		}
+		return strings.Join(names, ",")
+	}
+	tests := []struct {
+		modules []string
+		want    string
+	}{
+		{modules: nil, want: "eth,eth,net,web3,admin,personal"},
+		{modules: []string{"eth", "admin"}, want: "eth,eth,admin"},
+		{modules: []string{"net", "all"}, want: "eth,eth,net,web3,admin,personal"},
+		{modules: []string{"debug"}, want: ""},
+	}
+	for _, tt := range tests {
+		if have := namespaces(filterAPIs(endpointsTestAllAPIs, tt.modules)); have != tt.want {
+			t.Errorf("%v: namespaces %s, want %s", tt.modules, have, tt.want)
+		}
+	}
+}
+
+func TestValidateRPCConfig(t *testing.T) {
+	tests := []struct {
+		cfg conf.NodeConfig
+		err string
+	}{
+		{cfg: conf.NodeConfig{HTTP: true, HTTPApi: "eth,net", HTTPCors: "https://dapp.example", HTTPVirtualHosts: "localhost"}},
+		// Disabled transports are not checked.
+		{cfg: conf.NodeConfig{HTTPApi: "admin", WSApi: "debug", HTTPCors: "bad"}},
+		{cfg: conf.NodeConfig{HTTP: true, HTTPApi: "admin"}, err: "http: namespace"},
+		{cfg: conf.NodeConfig{HTTP: true, HTTPCors: "dapp.example"}, err: "http: invalid origin"},
+		{cfg: conf.NodeConfig{HTTP: true, HTTPVirtualHosts: "localhost:8545"}, err: "http: invalid virtual host"},
+		{cfg: conf.NodeConfig{WS: true, WSApi: "personal"}, err: "ws: namespace"},
+		{cfg: conf.NodeConfig{WS: true, WSOrigins: "ws.example"}, err: "ws: invalid origin"},
+		// IPC and the authenticated endpoint serve every namespace.
+		{cfg: conf.NodeConfig{IPCPath: "amc.ipc", IPCApi: "admin,eth"}},
+		{cfg: conf.NodeConfig{IPCPath: "amc.ipc", IPCApi: "debug"}, err: "ipc: unknown namespace"},
+		{cfg: conf.NodeConfig{AuthRPC: true, AuthApi: "personal,eth"}},
+		{cfg: conf.NodeConfig{AuthRPC: true, AuthApi: "debug"}, err: "authrpc: unknown namespace"},
+		{cfg: conf.NodeConfig{AuthRPC: true, AuthVirtualHosts: "http://localhost"}, err: "authrpc: invalid virtual host"},
+	}
+	for i, tt := range tests {
+		err := validateRPCConfig(&tt.cfg, endpointsTestOpenAPIs, endpointsTestAllAPIs)
+		if tt.err == "" && err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("test %d: error %v, want %q", i, err, tt.err)
+		}
+	}
+}
@@ -6,4 +6,6 @@ var (
 	ErrDatadirUsed = errors.New("datadir already used by another process")
 	ErrNodeStopped = errors.New("node not started")
 	ErrNodeRunning = errors.New("node already running")
+
+	errAPIKeysDisabled = errors.New("API keys are not enabled, see --rpc.apikeys")
 )
@@ -0,0 +1,108 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/p2p/encoder"
+	"github.com/amazechain/amc/log"
+	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/utils"
+	"github.com/golang/snappy"
+	"github.com/holiman/uint256"
+)
+
+// evidenceAcceptor is implemented by consensus engines that punish
+// double-signing validators.
+type evidenceAcceptor interface {
+	AddEvidence(data []byte) error
+}
+
+// evidenceGossip relays double-sign evidence between the consensus engine and
+// the slashing gossip topic, so that whichever validator proposes next can
+// include the offense into a block.
+type evidenceGossip struct {
+	ctx    context.Context
+	p2p    p2p.P2P
+	engine evidenceAcceptor
+	topic  string
+}
+
+func newEvidenceGossip(ctx context.Context, service p2p.P2P, engine evidenceAcceptor, genesis types.Hash) (*evidenceGossip, error) {
+	digest, err := utils.CreateForkDigest(new(uint256.Int), genesis)
+	if err != nil {
+		return nil, err
+	}
+	return &evidenceGossip{
+		ctx:    ctx,
+		p2p:    service,
+		engine: engine,
+		topic:  fmt.Sprintf(p2p.SlashingTopicFormat, digest) + service.Encoding().ProtocolSuffix(),
+	}, nil
+}
+
+// start subscribes to the gossip topic and to locally detected offenses.
+func (g *evidenceGossip) start() error {
+	sub, err := g.p2p.SubscribeToTopic(g.topic)
+	if err != nil {
+		return err
+	}
+	go g.broadcastLoop()
+	go func() {
+		defer sub.Cancel()
+		for {
+			msg, err := sub.Next(g.ctx)
+			if err != nil {
+				return
+			}
+			data, err := encoder.DecodeSnappy(msg.Data, encoder.MaxGossipSize)
+			if err != nil {
+				log.Debug("Could not decode slashing evidence", "peer", msg.ReceivedFrom, "err", err)
+				continue
+			}
+			if err := g.engine.AddEvidence(data); err != nil {
+				log.Debug("Rejected slashing evidence", "peer", msg.ReceivedFrom, "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// broadcastLoop publishes every offense reported by the engine.
+func (g *evidenceGossip) broadcastLoop() {
+	offenses := make(chan common.DoubleSignEvent, 16)
+	sub := event.GlobalEvent.Subscribe(offenses)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-offenses:
+			if err := g.p2p.PublishToTopic(g.ctx, g.topic, snappy.Encode(nil, ev.Evidence)); err != nil {
+				log.Warn("Failed to gossip slashing evidence", "signer", ev.Signer, "number", ev.Number, "err", err)
+			}
+		case <-sub.Err():
+			return
+		case <-g.ctx.Done():
+			return
+		}
+	}
+}
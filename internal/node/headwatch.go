@@ -0,0 +1,150 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal"
+	"github.com/amazechain/amc/log"
+	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/params"
+)
+
+const (
+	// stallPeriods is the number of block periods without a new head after
+	// which the head is reported stalled, unless a timeout is configured.
+	stallPeriods = 10
+	// maxStallPeers caps the peers listed in the stall diagnostics.
+	maxStallPeers = 16
+)
+
+// headWatchdog reports the chain head as stalled once no block has been
+// imported for the stall timeout: it posts a StalledEvent, fails the
+// readiness check and logs what the peers and the sync are up to.
+type headWatchdog struct {
+	n       *Node
+	timeout time.Duration
+	stalled atomic.Bool
+
+	// Only touched by the loop.
+	head     types.Hash
+	imported time.Time
+}
+
+// newHeadWatchdog returns nil on chains without a block period, whose blocks
+// aren't expected at any pace.
+func newHeadWatchdog(n *Node, timeout time.Duration) *headWatchdog {
+	if timeout <= 0 {
+		period := blockPeriod(n.config.ChainCfg)
+		if period == 0 {
+			return nil
+		}
+		timeout = stallPeriods * period
+	}
+	return &headWatchdog{n: n, timeout: timeout}
+}
+
+// blockPeriod returns the time between two blocks of the sealing engine.
+func blockPeriod(cfg *params.ChainConfig) time.Duration {
+	switch {
+	case cfg == nil:
+		return 0
+	case cfg.Apos != nil:
+		return time.Duration(cfg.Apos.Period) * time.Second
+	case cfg.Clique != nil:
+		return time.Duration(cfg.Clique.Period) * time.Second
+	}
+	return 0
+}
+
+func (w *headWatchdog) loop() {
+	interval := w.timeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.head, w.imported = w.n.blockChain.CurrentBlock().Hash(), time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-w.n.ctx.Done():
+			return
+		}
+	}
+}
+
+// check compares the head with the one seen last.
+func (w *headWatchdog) check() {
+	head := w.n.blockChain.CurrentBlock()
+	if head.Hash() != w.head {
+		if w.stalled.Swap(false) {
+			log.Info("Chain head is moving again", "number", head.Number64().Uint64(), "stalled", time.Since(w.imported).Round(time.Second))
+		}
+		w.head, w.imported = head.Hash(), time.Now()
+		return
+	}
+	lag := time.Since(w.imported)
+	if lag < w.timeout || w.stalled.Swap(true) {
+		return
+	}
+	event.GlobalEvent.Send(common.StalledEvent{
+		Number:   head.Number64().Uint64(),
+		Hash:     head.Hash(),
+		Imported: w.imported,
+		Lag:      lag,
+	})
+	log.Warn("Chain head stalled", "number", head.Number64().Uint64(), "hash", head.Hash(), "lag", lag.Round(time.Second), "timeout", w.timeout)
+	w.diagnose()
+}
+
+// diagnose logs the state of the sync and of the peers.
+func (w *headWatchdog) diagnose() {
+	n := w.n
+	local := n.blockChain.CurrentBlock().Number64()
+	highest, _ := n.p2p.Peers().BestPeers(1, local)
+	var paused string
+	if chain, ok := n.blockChain.(*internal.BlockChain); ok {
+		paused = chain.ImportPaused()
+	}
+	log.Warn("Stall diagnostics", "peers", len(n.p2p.Peers().Connected()), "highest", highest.Uint64(),
+		"syncing", n.is.Syncing(), "synced", n.is.Synced(), "importPaused", paused, "miner", n.config.NodeCfg.Miner)
+
+	peers, err := (&adminAPI{n}).Peers()
+	if err != nil {
+		log.Warn("Could not list the peers", "err", err)
+		return
+	}
+	for i, p := range peers {
+		if i == maxStallPeers {
+			log.Warn("More peers not listed", "count", len(peers)-maxStallPeers)
+			break
+		}
+		log.Warn("Stall diagnostics peer", "peer", p.ID, "address", p.Address, "direction", p.Direction, "state", p.State, "height", p.Height, "score", p.Score)
+	}
+}
+
+// isStalled tells whether the watchdog, if any, reports the head stalled.
+func (w *headWatchdog) isStalled() bool {
+	return w != nil && w.stalled.Load()
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/amazechain/amc/internal"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// dbProbeTimeout is how long the readiness check waits for a write
+// transaction, which queues behind the block being committed.
+const dbProbeTimeout = 5 * time.Second
+
+// healthHandler serves /health, up as long as the process serves requests.
+type healthHandler struct{}
+
+func (healthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok\n"))
+}
+
+// readyHandler serves /ready, answering 503 with the failed checks until the
+// node is fit to serve traffic: connected to enough peers, within the
+// configured number of blocks of the network head, and with a writable
+// database. A read-only node is only checked for a readable database.
+type readyHandler struct {
+	n       *Node
+	probing atomic.Bool
+}
+
+func (h *readyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	checks := h.check(r.Context())
+	status := http.StatusOK
+	for _, err := range checks {
+		if err != "" {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	result := make(map[string]string, len(checks))
+	for name, err := range checks {
+		if err == "" {
+			err = "ok"
+		}
+		result[name] = err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// check runs the readiness checks, returning the reason each failed one did.
+func (h *readyHandler) check(ctx context.Context) map[string]string {
+	cfg := h.n.config.NodeCfg
+	checks := map[string]string{"database": h.checkDB(ctx, cfg.DBReadOnly)}
+	if cfg.DBReadOnly {
+		return checks
+	}
+
+	peers := len(h.n.p2p.Peers().Connected())
+	checks["peers"] = ""
+	if peers < cfg.ReadyMinPeers {
+		checks["peers"] = fmt.Sprintf("%d peers connected, %d required", peers, cfg.ReadyMinPeers)
+	}
+
+	checks["import"] = ""
+	if chain, ok := h.n.blockChain.(*internal.BlockChain); ok {
+		if reason := chain.ImportPaused(); reason != "" {
+			checks["import"] = "block import paused: " + reason
+		}
+	}
+
+	local := h.n.blockChain.CurrentBlock().Number64()
+	if h.n.light != nil {
+		local = h.n.light.Chain().CurrentHeader().Number
+	}
+	checks["sync"] = ""
+	if network, _ := h.n.p2p.Peers().BestPeers(1, local); network.Uint64() > local.Uint64()+cfg.ReadyMaxBlockLag {
+		checks["sync"] = fmt.Sprintf("head %d is %d blocks behind the network", local.Uint64(), network.Uint64()-local.Uint64())
+	}
+	checks["head"] = ""
+	if h.n.headWatch.isStalled() {
+		checks["head"] = fmt.Sprintf("no block imported for %v", h.n.headWatch.timeout)
+	}
+	return checks
+}
+
+// checkDB opens a write transaction, or a read one on a read-only database.
+// A probe stuck behind a long commit answers the following requests as
+// failed instead of queuing more transactions.
+func (h *readyHandler) checkDB(ctx context.Context, readOnly bool) string {
+	if !h.probing.CompareAndSwap(false, true) {
+		return "database busy"
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer h.probing.Store(false)
+		if readOnly {
+			done <- h.n.db.View(context.Background(), func(kv.Tx) error { return nil })
+		} else {
+			done <- h.n.db.Update(context.Background(), func(kv.RwTx) error { return nil })
+		}
+	}()
+	timer := time.NewTimer(dbProbeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return err.Error()
+		}
+		return ""
+	case <-timer.C:
+		return "database busy"
+	case <-ctx.Done():
+		return ctx.Err().Error()
+	}
+}
+
+// registerHealthHandlers serves /health and /ready on the HTTP RPC server.
+func (n *Node) registerHealthHandlers() {
+	n.http.registerHandler("Health check", "/health", healthHandler{})
+	n.http.registerHandler("Readiness check", "/ready", &readyHandler{n: n})
+}
@@ -27,10 +27,13 @@ import (
 	nftdeposit "github.com/amazechain/amc/contracts/deposit/NFT"
 	"github.com/amazechain/amc/internal/debug"
 	"github.com/amazechain/amc/internal/metrics/prometheus"
+	"github.com/amazechain/amc/internal/metrics/push"
 	"github.com/amazechain/amc/internal/p2p"
 	amcsync "github.com/amazechain/amc/internal/sync"
 	initialsync "github.com/amazechain/amc/internal/sync/initial-sync"
 	"github.com/amazechain/amc/internal/tracers"
+	"github.com/amazechain/amc/internal/tracing"
+	"github.com/amazechain/amc/internal/vm"
 	"github.com/gofrs/flock"
 	"github.com/ledgerwatch/erigon-lib/common/cmp"
 	"github.com/pkg/errors"
@@ -45,10 +48,13 @@ import (
 	"github.com/amazechain/amc/internal/api"
 
 	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/freezer"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/ethdb/pebbledb"
+	"github.com/amazechain/amc/modules/migrations"
 	"github.com/c2h5oh/datasize"
 	"github.com/ledgerwatch/erigon-lib/kv"
 	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
-	"github.com/ledgerwatch/erigon-lib/kv/memdb"
 	log2 "github.com/ledgerwatch/log/v3"
 	"golang.org/x/sync/semaphore"
 
@@ -58,7 +64,9 @@ import (
 	"strconv"
 
 	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/accounts/external"
 	"github.com/amazechain/amc/accounts/keystore"
+	"github.com/amazechain/amc/accounts/remote"
 	"github.com/amazechain/amc/common"
 	"github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/transaction"
@@ -69,6 +77,7 @@ import (
 	"github.com/amazechain/amc/internal/consensus"
 	"github.com/amazechain/amc/internal/consensus/apoa"
 	"github.com/amazechain/amc/internal/consensus/apos"
+	"github.com/amazechain/amc/internal/light"
 	"github.com/amazechain/amc/internal/miner"
 	"github.com/amazechain/amc/internal/txspool"
 	"github.com/amazechain/amc/modules/rawdb"
@@ -99,11 +108,14 @@ type Node struct {
 	blockChain      common.IBlockChain
 	engine          consensus.Engine
 	db              kv.RwDB
+	ancients        *freezer.Freezer
 	txspool         common.ITxsPool
 	depositContract *deposit.Deposit
 	p2p             p2p.P2P
 	sync            *amcsync.Service
 	is              *initialsync.Service
+	light           *light.Client
+	headWatch       *headWatchdog
 	accman          *accounts.Manager
 
 	api     *api.API
@@ -115,9 +127,11 @@ type Node struct {
 	httpAuth      *httpServer //
 	wsAuth        *httpServer //
 	inprocHandler *jsonrpc.Server
+	apiKeys       *jsonrpc.AccessControl // nil unless --rpc.apikeys is set
 
-	keyDir     string // key store directory
-	keyDirTemp bool   // If true, key directory will be removed by Stop
+	keyDir     string             // key store directory
+	keyDirTemp bool               // If true, key directory will be removed by Stop
+	devKeys    *keystore.KeyStore // keystore holding the unlocked developer account in dev mode
 
 }
 
@@ -148,6 +162,25 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 	if nil != err {
 		return nil, err
 	}
+	var (
+		ancients    *freezer.Freezer
+		readOnly    = cfg.NodeCfg.DBReadOnly
+		ancientPath = filepath.Join(cfg.NodeCfg.DataDir, ancientDir)
+	)
+	if cfg.NodeCfg.DataDir != "" {
+		if !readOnly {
+			ancients, err = freezer.Open(ancientPath)
+		} else if _, serr := os.Stat(ancientPath); serr == nil {
+			ancients, err = freezer.OpenReadOnly(ancientPath)
+		}
+		if err != nil {
+			chainKv.Close()
+			return nil, err
+		}
+		if ancients != nil {
+			rawdb.SetAncients(ancients)
+		}
+	}
 
 	if err := chainKv.View(ctx, func(tx kv.Tx) error {
 		//
@@ -176,6 +209,42 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		return nil, err
 	}
 
+	if readOnly && genesisHash == (types.Hash{}) {
+		return nil, errors.New("the read-only database has no genesis block")
+	}
+	keyDir, isEphem, err := getKeyStoreDir(&cfg.NodeCfg)
+	if err != nil {
+		return nil, err
+	}
+	// A dev node seals its own chain with the developer account, created on
+	// first start along with the genesis funding it.
+	var devKeys *keystore.KeyStore
+	if cfg.NodeCfg.Dev {
+		var developer types.Address
+		if devKeys, developer, err = developerKeyStore(keyDir, cfg); err != nil {
+			return nil, err
+		}
+		if genesisHash == (types.Hash{}) {
+			genesisConfig = internal.DeveloperGenesisBlock(cfg.NodeCfg.DevPeriod, developer)
+			if err := chainKv.Update(ctx, func(tx kv.RwTx) error {
+				genesisBlock, err = WriteGenesisBlock(tx, genesisConfig)
+				return err
+			}); err != nil {
+				return nil, err
+			}
+			genesisHash, chainConfig = genesisBlock.Hash(), genesisConfig.Config
+		}
+	}
+	// A network only runs on its own genesis, a custom one written by amc
+	// init only as the private chain.
+	if want := params.GenesisHashByChainName(cfg.NodeCfg.Chain); want == nil && genesisHash == (types.Hash{}) {
+		return nil, fmt.Errorf("no genesis for chain %q, initialise the data dir with amc init <genesis.json>", cfg.NodeCfg.Chain)
+	} else if want != nil && genesisHash != (types.Hash{}) && genesisHash != *want {
+		return nil, fmt.Errorf("database genesis %s is not the %s genesis %s, a custom genesis runs with --chain private", genesisHash, cfg.NodeCfg.Chain, *want)
+	}
+	if readOnly && cfg.NodeCfg.Miner {
+		return nil, errors.New("a node on a read-only database can't mine")
+	}
 	if genesisHash == (types.Hash{}) {
 		genesisHash = *params.GenesisHashByChainName(cfg.NodeCfg.Chain)
 		genesisConfig = internal.GenesisByChainName(cfg.NodeCfg.Chain)
@@ -193,7 +262,7 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 	}
 
 	// update ChainConfig everytime
-	if cfg.NodeCfg.Chain != "private" {
+	if cfg.NodeCfg.Chain != "private" && !readOnly {
 		if err := chainKv.Update(ctx, func(tx kv.RwTx) error {
 			genesisHash = *params.GenesisHashByChainName(cfg.NodeCfg.Chain)
 			genesisConfig = internal.GenesisByChainName(cfg.NodeCfg.Chain)
@@ -206,18 +275,39 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		}
 	}
 
-	// Acquire the instance directory lock.
-	if err := node.openDataDir(cfg); err != nil {
-		return nil, err
+	// Acquire the instance directory lock, unless it belongs to the node
+	// writing the database.
+	if !readOnly {
+		if err := node.openDataDir(cfg); err != nil {
+			return nil, err
+		}
 	}
 
 	cfg.ChainCfg = chainConfig
+	if len(cfg.NodeCfg.ForkOverrides) > 0 {
+		// The network configs are shared, reschedule a copy.
+		overridden := *chainConfig
+		for name, at := range cfg.NodeCfg.ForkOverrides {
+			if err := overridden.OverrideFork(name, at); err != nil {
+				return nil, err
+			}
+			log.Warn("Overriding the fork schedule", "fork", name, "at", at)
+		}
+		cfg.ChainCfg = &overridden
+	}
+	if err := cfg.ChainCfg.CheckConfigForkOrder(); err != nil {
+		return nil, err
+	}
 
 	p2p, err := p2p.NewService(ctx, genesisBlock.Hash(), cfg.P2PCfg, cfg.NodeCfg)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := cfg.ChainCfg.CheckConsensus(); err != nil {
+		return nil, err
+	}
+
 	switch cfg.ChainCfg.Consensus {
 	case params.CliqueConsensus:
 		engine = apoa.New(cfg.ChainCfg.Clique, chainKv)
@@ -228,6 +318,21 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 	}
 
 	bc, _ := internal.NewBlockChain(ctx, genesisBlock, engine, chainKv, p2p, cfg.ChainCfg)
+	if chain, ok := bc.(*internal.BlockChain); ok && cfg.NodeCfg.TrieCache > 0 {
+		chain.Snapshots().SetCacheSize(cfg.NodeCfg.TrieCache * 1024 * 1024)
+	}
+	if chain, ok := bc.(*internal.BlockChain); ok && cfg.NodeCfg.Preimages {
+		chain.SetPreimageRecording(true)
+	}
+	if chain, ok := bc.(*internal.BlockChain); ok && cfg.NodeCfg.ParallelExec {
+		chain.SetParallelExecution(true)
+	}
+	if chain, ok := bc.(*internal.BlockChain); ok && cfg.NodeCfg.EVMProfile {
+		chain.SetEVMProfiler(vm.NewProfiler())
+	}
+	if chain, ok := bc.(*internal.BlockChain); ok {
+		chain.SetReorgAlertDepth(cfg.NodeCfg.ReorgAlertDepth)
+	}
 
 	if cfg.ChainCfg.Apos != nil {
 		depositContracts := make(map[types.Address]deposit.DepositContract, 0)
@@ -254,21 +359,74 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 			depositContracts[addr] = new(fujideposit.Contract)
 		}
 		depositContract = deposit.NewDeposit(ctx, bc, chainKv, depositContracts)
+		if chain, ok := bc.(*internal.BlockChain); ok {
+			chain.AddChainHook(depositContract)
+		}
 	}
 
 	pool, _ := txspool.NewTxsPool(ctx, bc, depositContract)
 
+	switch cfg.NodeCfg.SyncMode {
+	case "", "full", "snap":
+	case "light":
+		if cfg.NodeCfg.Miner {
+			return nil, errors.New("a light client has no state, it can't mine")
+		}
+		if readOnly {
+			return nil, errors.New("a light client can't run on a read-only database")
+		}
+	default:
+		return nil, fmt.Errorf("unknown sync mode %q", cfg.NodeCfg.SyncMode)
+	}
+	if err := chainKv.View(ctx, func(tx kv.Tx) error {
+		return checkGCMode(tx, cfg.NodeCfg.GCMode, cfg.NodeCfg.SyncMode)
+	}); err != nil {
+		return nil, err
+	}
+	checkpoint := params.SyncCheckpointByGenesisHash(bc.GenesisBlock().Hash())
+	if cfg.NodeCfg.Checkpoint != "" {
+		if checkpoint, err = params.ParseSyncCheckpoint(cfg.NodeCfg.Checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	if checkpoint != nil {
+		log.Info("Using sync checkpoint", "checkpoint", checkpoint)
+		if chain, ok := bc.(*internal.BlockChain); ok {
+			chain.SetCheckpoint(checkpoint)
+		}
+	}
 	is := initialsync.NewService(ctx, &initialsync.Config{
-		Chain: bc,
-		P2P:   p2p,
+		Chain:      bc,
+		P2P:        p2p,
+		SnapSync:   cfg.NodeCfg.SyncMode == "snap",
+		Checkpoint: checkpoint,
 	})
 
-	syncServer := amcsync.NewService(
-		ctx,
+	syncOpts := []amcsync.Option{
 		amcsync.WithP2P(p2p),
 		amcsync.WithChainService(bc),
-		amcsync.WithInitialSync(is),
-	)
+	}
+	var lightClient *light.Client
+	if cfg.NodeCfg.SyncMode == "light" {
+		// A light client imports no blocks, neither synced nor gossiped.
+		syncOpts = append(syncOpts, amcsync.WithoutGossip())
+		if lightClient, err = light.New(ctx, &light.Config{
+			DB:          chainKv,
+			ChainConfig: cfg.ChainCfg,
+			Engine:      engine,
+			Genesis:     genesisBlock,
+			P2P:         p2p,
+			Confirm:     cfg.NodeCfg.LightConfirm,
+		}); err != nil {
+			return nil, err
+		}
+	} else {
+		syncOpts = append(syncOpts, amcsync.WithInitialSync(is), amcsync.WithTxPool(pool))
+		if cfg.NodeCfg.LightServe > 0 {
+			syncOpts = append(syncOpts, amcsync.WithLightServer(cfg.NodeCfg.LightServe))
+		}
+	}
+	syncServer := amcsync.NewService(ctx, syncOpts...)
 
 	//todo
 	var txs []*transaction.Transaction
@@ -287,10 +445,6 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 
 	miner := miner.NewMiner(ctx, cfg, bc, engine, pool, nil)
 
-	keyDir, isEphem, err := getKeyStoreDir(&cfg.NodeCfg)
-	if err != nil {
-		return nil, err
-	}
 	// Creates an empty AccountManager with no backends. Callers (e.g. cmd/amc)
 	// are required to add the backends later on.
 	accman := accounts.NewManager(&accounts.Config{InsecureUnlockAllowed: cfg.NodeCfg.InsecureUnlockAllowed})
@@ -306,6 +460,7 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		genesisBlock:    genesisBlock,
 		blockChain:      bc,
 		db:              chainKv,
+		ancients:        ancients,
 		shutDown:        make(chan struct{}),
 		txspool:         pool,
 		engine:          engine,
@@ -322,10 +477,12 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 		accman:     accman,
 		keyDir:     keyDir,
 		keyDirTemp: isEphem,
+		devKeys:    devKeys,
 
-		p2p:  p2p,
-		sync: syncServer,
-		is:   is,
+		p2p:   p2p,
+		sync:  syncServer,
+		is:    is,
+		light: lightClient,
 	}
 
 	// Apply flags.
@@ -351,6 +508,9 @@ func NewNode(cliCtx *cli.Context, cfg *conf.Config) (*Node, error) {
 
 	node.api = api.NewAPI(bc, chainKv, engine, pool, node.AccountManager(), cfg.ChainCfg)
 	node.api.SetGpo(api.NewOracle(bc, miner, cfg.ChainCfg, gpoParams))
+	node.api.SetExtRPCEnabled(cfg.NodeCfg.ExtRPCEnabled())
+	node.api.SetRPCCaps(cfg.NodeCfg.RPCGasCap, cfg.NodeCfg.RPCEVMTimeout)
+	node.api.SetSyncReader(is)
 	return &node, nil
 }
 
@@ -370,10 +530,42 @@ func (n *Node) Start() error {
 	n.state = runningState
 	n.lock.Unlock()
 
+	if n.config.NodeCfg.DBReadOnly {
+		return n.startReadOnly()
+	}
+	if n.light != nil {
+		return n.startLight()
+	}
 	if err := n.blockChain.Start(); err != nil {
 		log.Errorf("failed setup blockChain service, err: %v", err)
 		return err
 	}
+	if n.config.NodeCfg.GCMode != "archive" && n.config.NodeCfg.PruneHistory > 0 {
+		if chain, ok := n.blockChain.(*internal.BlockChain); ok {
+			chain.StartPruning(n.config.NodeCfg.PruneHistory)
+		}
+	}
+	if n.ancients != nil && n.config.NodeCfg.AncientThreshold > 0 {
+		if chain, ok := n.blockChain.(*internal.BlockChain); ok {
+			chain.StartFreezer(n.ancients, n.config.NodeCfg.AncientThreshold)
+		}
+	}
+	if chain, ok := n.blockChain.(*internal.BlockChain); ok {
+		chain.StartTxIndexer(n.config.NodeCfg.TxLookupLimit)
+		chain.StartBloomIndexer()
+	}
+	if n.headWatch = newHeadWatchdog(n, n.config.NodeCfg.StallTimeout); n.headWatch != nil {
+		go n.headWatch.loop()
+	}
+	if hard := n.config.NodeCfg.MinFreeDiskSpace; hard > 0 {
+		if chain, ok := n.blockChain.(*internal.BlockChain); ok {
+			go n.diskWatchLoop(newDiskWatchdog(n.config.NodeCfg.DataDir, chain, n.config.NodeCfg.WarnFreeDiskSpace, hard))
+		}
+	}
+	// MDBX reuses its freed pages, only Pebble gains from compactions.
+	if db, ok := n.db.(*pebbledb.DB); ok && n.config.NodeCfg.DBCompactInterval > 0 {
+		go n.compactLoop(db, n.config.NodeCfg.DBCompactInterval)
+	}
 
 	if n.config.NodeCfg.Miner {
 
@@ -384,20 +576,14 @@ func (n *Node) Start() error {
 			return fmt.Errorf("etherbase missing: %v", err)
 		}
 
+		signFn, err := n.sealSigner(eb)
+		if err != nil {
+			return err
+		}
 		if poa, ok := n.engine.(*apoa.Apoa); ok {
-			wallet, err := n.accman.Find(accounts.Account{Address: eb})
-			if wallet == nil || err != nil {
-				log.Error("Etherbase account unavailable locally", "err", err)
-				return fmt.Errorf("signer missing: %v", err)
-			}
-			poa.Authorize(eb, wallet.SignData)
+			poa.Authorize(eb, signFn)
 		} else if pos, ok := n.engine.(*apos.APos); ok {
-			wallet, err := n.accman.Find(accounts.Account{Address: eb})
-			if wallet == nil || err != nil {
-				log.Error("Etherbase account unavailable locally", "err", err)
-				return fmt.Errorf("signer missing: %v", err)
-			}
-			pos.Authorize(eb, wallet.SignData)
+			pos.Authorize(eb, signFn)
 		}
 
 		n.miner.SetCoinbase(eb)
@@ -408,13 +594,7 @@ func (n *Node) Start() error {
 		pos.SetBlockChain(n.blockChain)
 	}
 
-	n.rpcAPIs = append(n.rpcAPIs, n.engine.APIs(n.blockChain)...)
-	n.rpcAPIs = append(n.rpcAPIs, n.api.Apis()...)
-	n.rpcAPIs = append(n.rpcAPIs, tracers.APIs(n.api)...)
-	n.rpcAPIs = append(n.rpcAPIs, debug.APIs()...)
-
-	if err := n.startRPC(); err != nil {
-		log.Error("failed start jsonrpc service", zap.Error(err))
+	if err := n.startAPIs(); err != nil {
 		return err
 	}
 
@@ -422,12 +602,34 @@ func (n *Node) Start() error {
 	n.p2p.Start()
 	n.sync.Start()
 
-	n.SetupMetrics(n.config.MetricsCfg)
+	if acceptor, ok := n.engine.(evidenceAcceptor); ok && n.config.ChainCfg.Apos != nil && n.config.ChainCfg.Apos.SlashingBlock != nil {
+		gossip, err := newEvidenceGossip(n.ctx, n.p2p, acceptor, n.blockChain.GenesisBlock().Hash())
+		if err != nil {
+			return err
+		}
+		if err := gossip.start(); err != nil {
+			log.Error("failed to subscribe slashing evidence", "err", err)
+			return err
+		}
+	}
+
+	if url := n.config.NodeCfg.ReorgWebhook; url != "" {
+		webhook, err := newReorgWebhook(n.ctx, url, n.config.ChainCfg.ChainName)
+		if err != nil {
+			return err
+		}
+		webhook.start()
+	}
+
+	if err := n.SetupMetrics(n.config.MetricsCfg); err != nil {
+		return err
+	}
 
 	if n.depositContract != nil {
 		n.depositContract.Start()
 	}
 
+	n.api.FollowSync(n.ctx)
 	go n.is.Start()
 
 	log.Debug("node setup success!")
@@ -435,6 +637,63 @@ func (n *Node) Start() error {
 	return nil
 }
 
+// startReadOnly serves the RPC from a database written by another node.
+// Nothing is synced, mined or written: the chain only follows the head the
+// other node imports.
+func (n *Node) startReadOnly() error {
+	if chain, ok := n.blockChain.(*internal.BlockChain); ok {
+		chain.FollowHead()
+	}
+	if err := n.startAPIs(); err != nil {
+		return err
+	}
+	if err := n.SetupMetrics(n.config.MetricsCfg); err != nil {
+		return err
+	}
+	log.Info("Serving a read-only chain database", "head", n.blockChain.CurrentBlock().Number64())
+	return nil
+}
+
+// startLight runs the node as a light client: the header chain of the peers
+// is followed and the RPC answers from it and from the light servers. No
+// block is imported or mined.
+func (n *Node) startLight() error {
+	n.rpcAPIs = append(n.rpcAPIs, n.apis()...)
+	n.rpcAPIs = append(n.rpcAPIs, n.light.APIs()...)
+	for _, api := range n.api.Apis() {
+		if api.Namespace == "web3" || api.Namespace == "net" {
+			n.rpcAPIs = append(n.rpcAPIs, api)
+		}
+	}
+	n.rpcAPIs = append(n.rpcAPIs, debug.APIs()...)
+	if err := n.startRPC(); err != nil {
+		log.Error("failed start jsonrpc service", zap.Error(err))
+		return err
+	}
+
+	n.p2p.Start()
+	n.sync.Start()
+	n.light.Start()
+
+	return n.SetupMetrics(n.config.MetricsCfg)
+}
+
+// startAPIs registers the RPC services and starts the RPC endpoints.
+func (n *Node) startAPIs() error {
+	n.rpcAPIs = append(n.rpcAPIs, n.apis()...)
+	n.rpcAPIs = append(n.rpcAPIs, n.engine.APIs(n.blockChain)...)
+	n.rpcAPIs = append(n.rpcAPIs, n.api.Apis()...)
+	n.rpcAPIs = append(n.rpcAPIs, n.miner.APIs()...)
+	n.rpcAPIs = append(n.rpcAPIs, tracers.APIs(n.api)...)
+	n.rpcAPIs = append(n.rpcAPIs, debug.APIs()...)
+
+	if err := n.startRPC(); err != nil {
+		log.Error("failed start jsonrpc service", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 // getAPIs return two sets of APIs, both the ones that do not require
 // authentication, and the complete set
 func (n *Node) getAPIs() (unauthenticated, all []jsonrpc.API) {
@@ -520,34 +779,88 @@ func (n *Node) obtainJWTSecret(cliParam string) ([]byte, error) {
 	return jwtSecret, nil
 }
 
+// rpcLimits assembles the request limits applied to the public HTTP and
+// WebSocket endpoints.
+func (n *Node) rpcLimits() (jsonrpc.Limits, error) {
+	cfg := &n.config.NodeCfg
+	methods, err := cfg.MethodRateLimits()
+	if err != nil {
+		return jsonrpc.Limits{}, err
+	}
+	if cfg.RPCRateLimit < 0 || cfg.RPCRateBurst < 0 || cfg.RPCCallTimeout < 0 || cfg.RPCMaxResponseSize < 0 {
+		return jsonrpc.Limits{}, errors.New("rpc limits must not be negative")
+	}
+	return jsonrpc.Limits{
+		RequestsPerSecond: cfg.RPCRateLimit,
+		Burst:             cfg.RPCRateBurst,
+		MethodLimits:      methods,
+		ExecutionTimeout:  cfg.RPCCallTimeout,
+		MaxResponseSize:   cfg.RPCMaxResponseSize,
+	}, nil
+}
+
+// rpcAccess loads the API keys required by the public HTTP and WebSocket
+// endpoints, leaving them open if no key file is configured.
+func (n *Node) rpcAccess() (*jsonrpc.AccessControl, error) {
+	if n.apiKeys != nil || n.config.NodeCfg.RPCAPIKeys == "" {
+		return n.apiKeys, nil
+	}
+	keys, err := jsonrpc.LoadAPIKeys(n.config.NodeCfg.RPCAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+	if n.apiKeys, err = jsonrpc.NewAccessControl(keys); err != nil {
+		return nil, err
+	}
+	log.Info("Loaded RPC API keys", "path", n.config.NodeCfg.RPCAPIKeys, "keys", len(keys))
+	return n.apiKeys, nil
+}
+
 func (n *Node) startRPC() error {
 
 	openAPIs, allAPIs := n.getAPIs()
+	limits, err := n.rpcLimits()
+	if err != nil {
+		return err
+	}
+	access, err := n.rpcAccess()
+	if err != nil {
+		return err
+	}
+	if err := validateRPCConfig(&n.config.NodeCfg, openAPIs, allAPIs); err != nil {
+		return err
+	}
+	jsonrpc.SetSlowRequestThreshold(n.config.NodeCfg.RPCSlowThreshold)
 
 	if err := n.startInProc(); err != nil {
 		return err
 	}
 
-	if n.ipc.endpoint != "" {
-		//if err := n.ipc.start(n.rpcAPIs); err != nil {
-		//	return err
-		//}
+	// IPC is only reachable locally, so it serves the authenticated
+	// namespaces as well.
+	if n.config.NodeCfg.IPCPath != "" {
+		if err := n.ipc.start(filterAPIs(allAPIs, utils.SplitAndTrim(n.config.NodeCfg.IPCApi))); err != nil {
+			return err
+		}
 	}
 	if n.config.NodeCfg.HTTP {
 		//todo []string{"eth", "web3", "debug", "net", "apoa", "txpool", "apos"}
 		config := httpConfig{
 			CorsAllowedOrigins: utils.SplitAndTrim(n.config.NodeCfg.HTTPCors),
-			Vhosts:             []string{"*"},
+			Vhosts:             utils.SplitAndTrim(n.config.NodeCfg.HTTPVirtualHosts),
 			Modules:            utils.SplitAndTrim(n.config.NodeCfg.HTTPApi),
 			prefix:             "",
+			limits:             limits,
+			access:             access,
 		}
 		port, _ := strconv.Atoi(n.config.NodeCfg.HTTPPort)
 		if err := n.http.setListenAddr(n.config.NodeCfg.HTTPHost, port); err != nil {
 			return err
 		}
-		if err := n.http.enableRPC(n.rpcAPIs, config); err != nil {
+		if err := n.http.enableRPC(openAPIs, config); err != nil {
 			return err
 		}
+		n.registerHealthHandlers()
 		if err := n.http.start(); err != nil {
 			return err
 		}
@@ -565,8 +878,10 @@ func (n *Node) startRPC() error {
 			Origins:   utils.SplitAndTrim(n.config.NodeCfg.WSOrigins),
 			prefix:    "",
 			jwtSecret: []byte{},
+			limits:    limits,
+			access:    access,
 		}
-		if err := n.ws.enableWS(n.rpcAPIs, config); err != nil {
+		if err := n.ws.enableWS(openAPIs, config); err != nil {
 			return err
 		}
 		if err := n.ws.start(); err != nil {
@@ -581,9 +896,9 @@ func (n *Node) startRPC() error {
 			return err
 		}
 		config := httpConfig{
-			CorsAllowedOrigins: utils.SplitAndTrim(n.config.NodeCfg.HTTPCors),
-			Vhosts:             []string{"*"},
-			Modules:            []string{"admin", "apos"},
+			CorsAllowedOrigins: utils.SplitAndTrim(n.config.NodeCfg.AuthCors),
+			Vhosts:             utils.SplitAndTrim(n.config.NodeCfg.AuthVirtualHosts),
+			Modules:            authModules(&n.config.NodeCfg, allAPIs),
 			prefix:             "",
 			jwtSecret:          jwtSecret,
 		}
@@ -591,7 +906,7 @@ func (n *Node) startRPC() error {
 		if err := n.httpAuth.setListenAddr(n.config.NodeCfg.AuthAddr, n.config.NodeCfg.AuthPort); err != nil {
 			return err
 		}
-		if err := n.httpAuth.enableRPC(n.rpcAPIs, config); err != nil {
+		if err := n.httpAuth.enableRPC(allAPIs, config); err != nil {
 			return err
 		}
 		if err := n.httpAuth.start(); err != nil {
@@ -614,6 +929,17 @@ func (n *Node) InstanceDir() string {
 	return n.config.NodeCfg.DataDir
 }
 
+// IPCEndpoint returns the path of the IPC socket the node listens on.
+func (n *Node) IPCEndpoint() string {
+	return n.ipc.endpoint
+}
+
+// Attach creates an RPC client attached to the in-process API handler, which
+// serves every registered namespace.
+func (n *Node) Attach() *jsonrpc.Client {
+	return jsonrpc.DialInProc(n.inprocHandler)
+}
+
 func (n *Node) Close() error {
 	n.startStopLock.Lock()
 	defer n.startStopLock.Unlock()
@@ -639,45 +965,6 @@ func (n *Node) Close() error {
 	}
 }
 
-// stopServices terminates running services, RPC and p2p networking.
-// It is the inverse of Start.
-func (n *Node) stopServices() []error {
-	var errs []error
-	n.stopRPC()
-
-	n.miner.Close()
-
-	if err := n.blockChain.Close(); err != nil {
-		errs = append(errs, err)
-	}
-
-	if err := n.engine.Close(); err != nil {
-		errs = append(errs, err)
-	}
-
-	if err := n.txspool.Stop(); err != nil {
-		errs = append(errs, err)
-	}
-
-	if err := n.depositContract.Stop(); err != nil {
-		errs = append(errs, err)
-	}
-
-	if err := n.is.Stop(); err != nil {
-		errs = append(errs, err)
-	}
-
-	if err := n.p2p.Stop(); err != nil {
-		errs = append(errs, err)
-	}
-
-	if err := n.sync.Stop(); err != nil {
-		errs = append(errs, err)
-	}
-
-	return errs
-}
-
 // doClose releases resources acquired by New(), collecting errors.
 func (n *Node) doClose(errs []error) error {
 	// Close databases. This needs the lock because it needs to
@@ -685,6 +972,12 @@ func (n *Node) doClose(errs []error) error {
 	n.lock.Lock()
 	n.state = closedState
 	n.db.Close()
+	if n.ancients != nil {
+		rawdb.SetAncients(nil)
+		if err := n.ancients.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	n.lock.Unlock()
 
 	if err := n.accman.Close(); err != nil {
@@ -757,12 +1050,25 @@ func getKeyStoreDir(conf *conf.NodeConfig) (string, bool, error) {
 
 func setAccountManagerBackends(stack *Node, conf *conf.NodeConfig) error {
 	am := stack.AccountManager()
+	// With an external signer, the keys never enter the node: it lists and
+	// signs for every account, the keystore isn't opened.
+	if conf.ExternalSigner != "" {
+		log.Info("Using external signer", "url", conf.ExternalSigner)
+		extapi, err := external.NewExternalBackend(conf.ExternalSigner)
+		if err != nil {
+			return fmt.Errorf("error connecting to external signer: %v", err)
+		}
+		am.AddBackend(extapi)
+		return nil
+	}
+	if stack.devKeys != nil {
+		am.AddBackend(stack.devKeys)
+		return nil
+	}
 	keydir := stack.KeyStoreDir()
-	scryptN := keystore.StandardScryptN
-	scryptP := keystore.StandardScryptP
-	if conf.UseLightweightKDF {
-		scryptN = keystore.LightScryptN
-		scryptP = keystore.LightScryptP
+	scryptN, scryptP, err := keystore.ScryptParams(conf.UseLightweightKDF, conf.ScryptN, conf.ScryptP)
+	if err != nil {
+		return err
 	}
 
 	// For now, we're using EITHER external signer OR local signers.
@@ -779,8 +1085,13 @@ func (n *Node) KeyStoreDir() string {
 	return n.keyDir
 }
 
-func (n *Node) SetupMetrics(config conf.MetricsConfig) {
+// SetupMetrics serves the metrics on the stand-alone HTTP endpoint and starts
+// the exporters pushing them and the block import traces.
+func (n *Node) SetupMetrics(config conf.MetricsConfig) error {
 	if config.Enable {
+		// The build is exported as the labels of a constant gauge.
+		prometheus.GetOrCreateCounter(fmt.Sprintf(`amc_build_info{version="%s",commit="%s",date="%s",go="%s"}`,
+			params.VersionWithMeta, params.GitCommit, params.BuildDate, runtime.Version()), true).Set(1)
 		if config.HTTP != "" {
 			address := net.JoinHostPort(config.HTTP, fmt.Sprintf("%d", config.Port))
 			log.Info("Enabling stand-alone metrics HTTP endpoint", "address", address)
@@ -789,7 +1100,63 @@ func (n *Node) SetupMetrics(config conf.MetricsConfig) {
 			log.Warn(fmt.Sprintf("--%s specified without --%s, metrics server will not start.", "metrics.port", "metrics.addr"))
 		}
 	}
+	if !config.Enable && !config.Tracing {
+		return nil
+	}
+	tags, err := push.ParseTags(config.Tags)
+	if err != nil {
+		return err
+	}
+	if _, ok := tags["host"]; !ok {
+		if host, err := os.Hostname(); err == nil {
+			tags["host"] = host
+		}
+	}
+	if _, ok := tags["network"]; !ok && n.config.ChainCfg != nil {
+		tags["network"] = n.config.ChainCfg.ChainName
+	}
+	if config.Enable {
+		if err := push.Start(n.ctx, config, tags); err != nil {
+			return err
+		}
+	}
+	return tracing.Setup(n.ctx, config, tags)
+}
 
+// sealSigner returns the function sealing blocks for the etherbase: the
+// configured remote signer if any, the local keystore otherwise.
+func (n *Node) sealSigner(eb types.Address) (func(accounts.Account, string, []byte) ([]byte, error), error) {
+	if endpoint := n.config.Miner.Signer; endpoint != "" {
+		// The signer authenticates the node with the JWT secret of its
+		// authenticated RPC.
+		jwtSecret, err := n.obtainJWTSecret(n.config.NodeCfg.JWTSecret)
+		if err != nil {
+			return nil, err
+		}
+		client, err := remote.Dial(endpoint, jwtSecret)
+		if err != nil {
+			return nil, fmt.Errorf("remote signer unavailable: %v", err)
+		}
+		addrs, err := client.Accounts()
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("remote signer unavailable: %v", err)
+		}
+		for _, addr := range addrs {
+			if addr == eb {
+				log.Info("Sealing through remote signer", "endpoint", endpoint, "etherbase", eb)
+				return client.SignData, nil
+			}
+		}
+		client.Close()
+		return nil, fmt.Errorf("remote signer %s does not hold etherbase %s", endpoint, eb)
+	}
+	wallet, err := n.accman.Find(accounts.Account{Address: eb})
+	if wallet == nil || err != nil {
+		log.Error("Etherbase account unavailable locally", "err", err)
+		return nil, fmt.Errorf("signer missing: %v", err)
+	}
+	return wallet.SignData, nil
 }
 
 func (s *Node) Etherbase() (eb types.Address, err error) {
@@ -816,48 +1183,138 @@ func (s *Node) Etherbase() (eb types.Address, err error) {
 }
 
 func OpenDatabase(cfg *conf.Config, logger log2.Logger, name string) (kv.RwDB, error) {
-	var chainKv kv.RwDB
-	if cfg.NodeCfg.DataDir == "" {
-		chainKv = memdb.New("")
-	}
-	var err error
-
 	dbPath := filepath.Join(cfg.NodeCfg.DataDir, name)
 
-	var openFunc func(exclusive bool) (kv.RwDB, error)
-	log.Info("Opening Database", "label", name, "path", dbPath)
-	openFunc = func(exclusive bool) (kv.RwDB, error) {
-		//if config.Http.DBReadConcurrency > 0 {
-		//	roTxLimit = int64(config.Http.DBReadConcurrency)
-		//}
-		roTxsLimiter := semaphore.NewWeighted(int64(cmp.Max(32, runtime.GOMAXPROCS(-1)*8))) // 1 less than max to allow unlocking to happen
-		opts := mdbx.NewMDBX(logger).
-			WriteMergeThreshold(4 * 8192).
-			Path(dbPath).Label(kv.ChainDB).
-			DBVerbosity(kv.DBVerbosityLvl(2)).RoTxsLimiter(roTxsLimiter)
-		if exclusive {
-			opts = opts.Exclusive()
-		}
-
-		modules.AmcInit()
-		kv.ChaindataTablesCfg = modules.AmcTableCfg
-
-		opts = opts.MapSize(8 * datasize.TB)
-		return opts.Open()
+	// Without a data directory nothing is kept once the node stops.
+	engine := cfg.NodeCfg.DBEngine
+	if cfg.NodeCfg.DataDir == "" {
+		engine = "memory"
+	}
+	engine, err := databaseEngine(dbPath, engine)
+	if err != nil {
+		return nil, err
 	}
-	chainKv, err = openFunc(false)
+	readOnly := cfg.NodeCfg.DBReadOnly
+	if readOnly && existingEngine(dbPath) == "" {
+		return nil, fmt.Errorf("no database to open read-only in %s", dbPath)
+	}
+
+	log.Info("Opening Database", "label", name, "path", dbPath, "engine", engine, "readonly", readOnly)
+	chainKv, err := openKV(dbPath, engine, logger, false, readOnly)
 	if err != nil {
 		return nil, err
 	}
+	if readOnly {
+		if err := migrations.Check(chainKv); err != nil {
+			chainKv.Close()
+			return nil, err
+		}
+		return chainKv, nil
+	}
 
 	if err = chainKv.Update(context.Background(), func(tx kv.RwTx) (err error) {
 		return params.SetAmcVersion(tx, params.VersionKeyCreated)
 	}); err != nil {
 		return nil, err
 	}
+	rawdb.SetCompression(cfg.NodeCfg.DBCompress)
+	if err := migrations.Apply(context.Background(), chainKv); err != nil {
+		chainKv.Close()
+		return nil, err
+	}
 	return chainKv, nil
 }
 
+// checkGCMode checks that the database can serve the given gc mode: an
+// archive node needs the state history of every block.
+func checkGCMode(tx kv.Tx, gcMode, syncMode string) error {
+	switch gcMode {
+	case "", "full":
+		return nil
+	case "archive":
+		if syncMode == "snap" {
+			return errors.New("an archive node keeps the state of every block, it can't be snap synced")
+		}
+		pruned, err := rawdb.ReadStatePruneProgress(tx)
+		if err != nil {
+			return err
+		}
+		if pruned > 0 {
+			return fmt.Errorf("the database has no state before block %d, an archive node has to sync from genesis", pruned-1)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown gc mode %q", gcMode)
+	}
+}
+
+// openKV opens the chain database in path with the given engine. An exclusive
+// mdbx database can't be opened by any other process at the same time, while
+// a read-only one can be opened next to the node writing it.
+func openKV(dbPath, engine string, logger log2.Logger, exclusive, readOnly bool) (kv.RwDB, error) {
+	modules.AmcInit()
+	switch engine {
+	case "memory":
+		if readOnly {
+			return nil, errors.New("an in-memory database can't be opened read-only")
+		}
+		return memdb.New(), nil
+	case "pebble":
+		if readOnly {
+			return pebbledb.OpenReadOnly(dbPath, modules.AmcTableCfg)
+		}
+		return pebbledb.Open(dbPath, modules.AmcTableCfg)
+	}
+	//if config.Http.DBReadConcurrency > 0 {
+	//	roTxLimit = int64(config.Http.DBReadConcurrency)
+	//}
+	roTxsLimiter := semaphore.NewWeighted(int64(cmp.Max(32, runtime.GOMAXPROCS(-1)*8))) // 1 less than max to allow unlocking to happen
+	opts := mdbx.NewMDBX(logger).
+		WriteMergeThreshold(4 * 8192).
+		Path(dbPath).Label(kv.ChainDB).
+		DBVerbosity(kv.DBVerbosityLvl(2)).RoTxsLimiter(roTxsLimiter)
+	if exclusive {
+		opts = opts.Exclusive()
+	}
+	if readOnly {
+		opts = opts.Readonly()
+	}
+
+	kv.ChaindataTablesCfg = modules.AmcTableCfg
+
+	opts = opts.MapSize(8 * datasize.TB)
+	return opts.Open()
+}
+
+// databaseEngine checks the requested key-value store, "mdbx" by default,
+// against the one an existing database in path was created with.
+func databaseEngine(path, engine string) (string, error) {
+	switch engine {
+	case "":
+		engine = "mdbx"
+	case "memory":
+		return engine, nil
+	case "mdbx", "pebble":
+	default:
+		return "", fmt.Errorf("unknown database engine %q", engine)
+	}
+	if existing := existingEngine(path); existing != "" && existing != engine {
+		return "", fmt.Errorf("database %s was created with %s, not %s", path, existing, engine)
+	}
+	return engine, nil
+}
+
+// existingEngine returns the engine of the database in path, empty if there
+// is none.
+func existingEngine(path string) string {
+	if _, err := os.Stat(filepath.Join(path, "mdbx.dat")); err == nil {
+		return "mdbx"
+	} else if _, err := os.Stat(filepath.Join(path, "CURRENT")); err == nil {
+		return "pebble"
+	}
+	return ""
+}
+
 func WriteGenesisBlock(db kv.RwTx, genesis *conf.Genesis) (*block.Block, error) {
 	if genesis == nil {
 		return nil, internal.ErrGenesisNoConfig
@@ -0,0 +1,55 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"testing"
+
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+)
+
+func TestCheckGCMode(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	tests := []struct {
+		gcMode, syncMode string
+		valid            bool
+	}{
+		{"", "", true},
+		{"full", "snap", true},
+		{"archive", "full", true},
+		{"archive", "", true},
+		{"archive", "snap", false},
+		{"light", "full", false},
+	}
+	for _, tt := range tests {
+		if err := checkGCMode(tx, tt.gcMode, tt.syncMode); (err == nil) != tt.valid {
+			t.Errorf("gc mode %q syncing %q: %v, want valid %v", tt.gcMode, tt.syncMode, err, tt.valid)
+		}
+	}
+
+	// A pruned database can't be turned into an archive node.
+	if err := rawdb.WriteStatePruneProgress(tx, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkGCMode(tx, "archive", "full"); err == nil {
+		t.Error("archive mode accepted on a pruned database")
+	}
+	if err := checkGCMode(tx, "full", "full"); err != nil {
+		t.Errorf("full mode rejected on a pruned database: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// ExportPreimages writes the preimages recorded in the chain database to w,
+// one "<hash> <preimage>" line each in hex, and returns how many it wrote.
+func ExportPreimages(ctx context.Context, cfg *conf.Config, w io.Writer) (uint64, error) {
+	db, err := openChainDatabase(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var (
+		count uint64
+		buf   = bufio.NewWriter(w)
+	)
+	if err := db.View(ctx, func(tx kv.Tx) error {
+		return rawdb.ForEachPreimage(tx, func(hash types.Hash, preimage []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			count++
+			_, err := fmt.Fprintf(buf, "%s %s\n", hexutil.Encode(hash[:]), hexutil.Encode(preimage))
+			return err
+		})
+	}); err != nil {
+		return count, err
+	}
+	return count, buf.Flush()
+}
@@ -0,0 +1,264 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// pruneCopyBatch is the number of entries written per transaction while the
+// compact database is rebuilt.
+const pruneCopyBatch = 100_000
+
+// PruneState rebuilds the chain database of a stopped node without the state
+// history older than the last keep blocks, then swaps it in place of the old
+// one. Everything else, the flat state included, is copied as is. It returns
+// the size of the database before and after.
+//
+// The new database is built next to the old one, which is only replaced once
+// the copy is complete: an interrupted run leaves the node's data untouched.
+func PruneState(ctx context.Context, cfg *conf.Config, keep uint64) (before, after uint64, err error) {
+	dbPath := filepath.Join(cfg.NodeCfg.DataDir, kv.ChainDB.String())
+	engine := existingEngine(dbPath)
+	if engine == "" {
+		return 0, 0, fmt.Errorf("no chain database in %s", dbPath)
+	}
+	if keep < internal.MinPruneDistance {
+		keep = internal.MinPruneDistance
+	}
+
+	src, err := openKV(dbPath, engine, nil, true, false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not open %s, is the node still running? %w", dbPath, err)
+	}
+	var from, target uint64
+	if err := src.View(ctx, func(tx kv.Tx) error {
+		head := rawdb.ReadCurrentBlockNumber(tx)
+		if head == nil {
+			return errors.New("chain database has no head block")
+		}
+		if target, err = internal.PruneTarget(tx, *head, keep); err != nil {
+			return err
+		}
+		from, err = rawdb.ReadStatePruneProgress(tx)
+		return err
+	}); err != nil {
+		src.Close()
+		return 0, 0, err
+	}
+	if target <= from {
+		src.Close()
+		log.Info("State history already pruned", "kept from", from)
+		size, err := dirSize(dbPath)
+		return size, size, err
+	}
+
+	log.Info("Rebuilding chain database", "path", dbPath, "prune below", target)
+	return rebuildDatabase(ctx, dbPath, engine, src, target)
+}
+
+// rebuildDatabase copies src, the database at dbPath, into a new one without
+// the state history below target, then moves the copy in place of src, which
+// is closed. It returns the size of the database before and after.
+func rebuildDatabase(ctx context.Context, dbPath, engine string, src kv.RwDB, target uint64) (before, after uint64, err error) {
+	tmpPath := dbPath + ".pruned"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		src.Close()
+		return 0, 0, err
+	}
+	dst, err := openKV(tmpPath, engine, nil, true, false)
+	if err != nil {
+		src.Close()
+		return 0, 0, err
+	}
+	err = copyPrunedState(ctx, src, dst, target)
+	src.Close()
+	dst.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if before, err = dirSize(dbPath); err != nil {
+		return 0, 0, err
+	}
+	if after, err = dirSize(tmpPath); err != nil {
+		return 0, 0, err
+	}
+	oldPath := dbPath + ".old"
+	if err := os.Rename(dbPath, oldPath); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		return 0, 0, fmt.Errorf("could not move %s into place, the old database is in %s: %w", tmpPath, oldPath, err)
+	}
+	return before, after, os.RemoveAll(oldPath)
+}
+
+// copyPrunedState copies every table to the new database, leaving out the
+// changes of the blocks below target and those blocks in the history indices.
+func copyPrunedState(ctx context.Context, src kv.RoDB, dst kv.RwDB, target uint64) error {
+	srcTx, err := src.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcTx.Rollback()
+
+	for _, table := range modules.AmcTables {
+		var (
+			start  []byte
+			filter func(k, v []byte) ([]byte, error)
+		)
+		switch table {
+		case modules.AccountChangeSet, modules.StorageChangeSet:
+			start = modules.EncodeBlockNumber(target)
+		case modules.AccountsHistory, modules.StorageHistory:
+			filter = func(k, v []byte) ([]byte, error) {
+				return pruneShard(k, v, target)
+			}
+		}
+		started := time.Now()
+		if err := copyTable(ctx, srcTx, dst, table, start, filter); err != nil {
+			return fmt.Errorf("copying %s: %w", table, err)
+		}
+		log.Info("Copied table", "table", table, "elapsed", time.Since(started))
+	}
+	return dst.Update(ctx, func(tx kv.RwTx) error {
+		if err := rawdb.PruneDepositJournal(tx, target); err != nil {
+			return err
+		}
+		return rawdb.WriteStatePruneProgress(tx, target)
+	})
+}
+
+// copyTable copies a table from start on, through filter if any, committing
+// every pruneCopyBatch entries. A nil filtered value skips the entry.
+func copyTable(ctx context.Context, srcTx kv.Tx, dst kv.RwDB, table string, start []byte, filter func(k, v []byte) ([]byte, error)) error {
+	c, err := srcTx.Cursor(table)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	cfg := modules.AmcTableCfg[table]
+	dupSort := cfg.Flags&kv.DupSort != 0 && !cfg.AutoDupSortKeysConversion
+
+	k, v, err := c.Seek(start)
+	if err != nil {
+		return err
+	}
+	for k != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := dst.Update(ctx, func(tx kv.RwTx) error {
+			var (
+				w   kv.RwCursor
+				dup kv.RwCursorDupSort
+				err error
+			)
+			if dupSort {
+				dup, err = tx.RwCursorDupSort(table)
+				w = dup
+			} else {
+				w, err = tx.RwCursor(table)
+			}
+			if err != nil {
+				return err
+			}
+			defer w.Close()
+			for n := 0; k != nil && n < pruneCopyBatch; n++ {
+				value := v
+				if filter != nil {
+					if value, err = filter(k, v); err != nil {
+						return err
+					}
+				}
+				switch {
+				case value == nil:
+				case dupSort:
+					err = dup.AppendDup(k, value)
+				case cfg.AutoDupSortKeysConversion:
+					// The engine splits the key, appending isn't possible.
+					err = w.Put(k, value)
+				default:
+					err = w.Append(k, value)
+				}
+				if err != nil {
+					return err
+				}
+				if k, v, err = c.Next(); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneShard drops the blocks below target from a history shard, keyed by its
+// highest block. Nil is returned if none is left.
+func pruneShard(k, v []byte, target uint64) ([]byte, error) {
+	if binary.BigEndian.Uint64(k[len(k)-8:]) < target {
+		return nil, nil
+	}
+	index := roaring64.New()
+	if _, err := index.ReadFrom(bytes.NewReader(v)); err != nil {
+		return nil, err
+	}
+	index.RemoveRange(0, target)
+	if index.IsEmpty() {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if _, err := index.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dirSize returns the size of the files in a directory.
+func dirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var (
+	pruneTestAddrA = types.HexToAddress("0xa1")
+	pruneTestAddrB = types.HexToAddress("0xb1")
+)
+
+// pruneTestShard encodes a history shard holding the given blocks.
+func pruneTestShard(t *testing.T, blocks ...uint64) []byte {
+	var buf bytes.Buffer
+	if _, err := roaring64.BitmapOf(blocks...).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writePruneTestState writes the changes and history of blocks one to six,
+// under a head far enough for the history below block four to be pruned.
+func writePruneTestState(t *testing.T, tx kv.RwTx) {
+	head := types.Hash{0x01}
+	if err := rawdb.WriteHeaderNumber(tx, head, 4100); err != nil {
+		t.Fatal(err)
+	}
+	if err := rawdb.WriteHeadHeaderHash(tx, head); err != nil {
+		t.Fatal(err)
+	}
+	for number := uint64(1); number <= 6; number++ {
+		value := append(pruneTestAddrA.Bytes(), fmt.Sprintf("account as of %d", number)...)
+		if err := tx.Put(modules.AccountChangeSet, modules.EncodeBlockNumber(number), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shards := []struct {
+		key    []byte
+		blocks []uint64
+	}{
+		{modules.AccountIndexChunkKey(pruneTestAddrA[:], math.MaxUint64), []uint64{1, 2, 3, 4, 5, 6}},
+		{modules.AccountIndexChunkKey(pruneTestAddrB[:], 2), []uint64{1, 2}},
+		{modules.AccountIndexChunkKey(pruneTestAddrB[:], math.MaxUint64), []uint64{3, 6}},
+	}
+	for _, shard := range shards {
+		if err := tx.Put(modules.AccountsHistory, shard.key, pruneTestShard(t, shard.blocks...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Put(modules.Account, pruneTestAddrA[:], []byte("account")); err != nil {
+		t.Fatal(err)
+	}
+	slot := modules.PlainGenerateCompositeStorageKey(pruneTestAddrA[:], 1, types.Hash{0x01}.Bytes())
+	if err := tx.Put(modules.Storage, slot, []byte{0x2a}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dumpPruneTestState lists the rows of the tables the test state is in. The
+// history shards are decoded, a bitmap has more than one encoding.
+func dumpPruneTestState(t *testing.T, tx kv.Tx) map[string][]string {
+	dump := make(map[string][]string)
+	for _, table := range []string{modules.AccountChangeSet, modules.AccountsHistory, modules.Account, modules.Storage} {
+		if err := tx.ForEach(table, nil, func(k, v []byte) error {
+			if table == modules.AccountsHistory {
+				index := roaring64.New()
+				if _, err := index.ReadFrom(bytes.NewReader(v)); err != nil {
+					return err
+				}
+				dump[table] = append(dump[table], fmt.Sprintf("%x: %v", k, index.ToArray()))
+				return nil
+			}
+			dump[table] = append(dump[table], fmt.Sprintf("%x: %x", k, v))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dump
+}
+
+func TestPruneShard(t *testing.T) {
+	tests := []struct {
+		shard  uint64
+		blocks []uint64
+		want   []uint64
+	}{
+		{shard: 2, blocks: []uint64{1, 2}},
+		{shard: math.MaxUint64, blocks: []uint64{1, 2}},
+		{shard: math.MaxUint64, blocks: []uint64{3, 6}, want: []uint64{6}},
+		{shard: 9, blocks: []uint64{4, 9}, want: []uint64{4, 9}},
+	}
+	for _, tt := range tests {
+		v, err := pruneShard(modules.AccountIndexChunkKey(pruneTestAddrA[:], tt.shard), pruneTestShard(t, tt.blocks...), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.want == nil {
+			if v != nil {
+				t.Errorf("shard %d of %v kept", tt.shard, tt.blocks)
+			}
+			continue
+		}
+		index := roaring64.New()
+		if _, err := index.ReadFrom(bytes.NewReader(v)); err != nil {
+			t.Fatal(err)
+		}
+		if got := index.ToArray(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shard %d of %v pruned to %v, want %v", tt.shard, tt.blocks, got, tt.want)
+		}
+	}
+}
+
+func TestPruneState(t *testing.T) {
+	for _, engine := range []string{"pebble", "mdbx"} {
+		t.Run(engine, func(t *testing.T) {
+			cfg := &conf.Config{NodeCfg: conf.NodeConfig{DataDir: t.TempDir()}}
+			dbPath := filepath.Join(cfg.NodeCfg.DataDir, kv.ChainDB.String())
+			db, err := openKV(dbPath, engine, nil, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Update(context.Background(), func(tx kv.RwTx) error {
+				writePruneTestState(t, tx)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			db.Close()
+
+			before, after, err := PruneState(context.Background(), cfg, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if before == 0 || after == 0 {
+				t.Errorf("database sizes %d before and %d after", before, after)
+			}
+
+			db, err = openKV(dbPath, engine, nil, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string][]string
+			if err := db.View(context.Background(), func(tx kv.Tx) error {
+				got = dumpPruneTestState(t, tx)
+				progress, err := rawdb.ReadStatePruneProgress(tx)
+				if err != nil || progress != 4 {
+					t.Errorf("history kept from #%d, %v, want #4", progress, err)
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			db.Close()
+			want := map[string][]string{
+				modules.AccountChangeSet: {
+					fmt.Sprintf("%x: %x", modules.EncodeBlockNumber(4), append(pruneTestAddrA.Bytes(), "account as of 4"...)),
+					fmt.Sprintf("%x: %x", modules.EncodeBlockNumber(5), append(pruneTestAddrA.Bytes(), "account as of 5"...)),
+					fmt.Sprintf("%x: %x", modules.EncodeBlockNumber(6), append(pruneTestAddrA.Bytes(), "account as of 6"...)),
+				},
+				modules.AccountsHistory: {
+					fmt.Sprintf("%x: %v", modules.AccountIndexChunkKey(pruneTestAddrA[:], math.MaxUint64), []uint64{4, 5, 6}),
+					fmt.Sprintf("%x: %v", modules.AccountIndexChunkKey(pruneTestAddrB[:], math.MaxUint64), []uint64{6}),
+				},
+				modules.Account: {fmt.Sprintf("%x: %x", pruneTestAddrA.Bytes(), "account")},
+				modules.Storage: {fmt.Sprintf("%x: %x", modules.PlainGenerateCompositeStorageKey(pruneTestAddrA[:], 1, types.Hash{0x01}.Bytes()), []byte{0x2a})},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("pruned state mismatch\nhave %v\nwant %v", got, want)
+			}
+
+			// Pruning again leaves the database as it is.
+			if before, after, err := PruneState(context.Background(), cfg, 0); err != nil || before != after {
+				t.Errorf("second pass: %d bytes before, %d after, %v", before, after, err)
+			}
+		})
+	}
+}
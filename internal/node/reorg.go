@@ -0,0 +1,144 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/log"
+	event "github.com/amazechain/amc/modules/event/v2"
+)
+
+const (
+	// reorgWebhookTimeout bounds a single delivery.
+	reorgWebhookTimeout = 10 * time.Second
+	// reorgWebhookAttempts is the number of deliveries tried per reorg.
+	reorgWebhookAttempts = 3
+)
+
+// reorgReport is the JSON body posted to the reorg webhook.
+type reorgReport struct {
+	Network      string       `json:"network"`
+	OldHead      types.Hash   `json:"oldHead"`
+	OldNumber    uint64       `json:"oldNumber"`
+	NewHead      types.Hash   `json:"newHead"`
+	NewNumber    uint64       `json:"newNumber"`
+	CommonHash   types.Hash   `json:"commonHash"`
+	CommonNumber uint64       `json:"commonNumber"`
+	Depth        uint64       `json:"depth"`
+	DroppedTxs   []types.Hash `json:"droppedTxs"`
+	Time         time.Time    `json:"time"`
+}
+
+// reorgWebhook posts the deep reorgs reported by the chain to a URL, so that
+// the services following the chain hear of them right away.
+type reorgWebhook struct {
+	ctx     context.Context
+	url     string
+	network string
+	client  *http.Client
+}
+
+func newReorgWebhook(ctx context.Context, rawurl, network string) (*reorgWebhook, error) {
+	if u, err := url.Parse(rawurl); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid reorg webhook %q, want an http(s) URL", rawurl)
+	}
+	return &reorgWebhook{
+		ctx:     ctx,
+		url:     rawurl,
+		network: network,
+		client:  &http.Client{Timeout: reorgWebhookTimeout},
+	}, nil
+}
+
+func (w *reorgWebhook) start() {
+	reorgs := make(chan common.ReorgEvent, 16)
+	sub := event.GlobalEvent.Subscribe(reorgs)
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case ev := <-reorgs:
+				w.deliver(ev)
+			case <-sub.Err():
+				return
+			case <-w.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// deliver posts a reorg, retrying a failed delivery a few times.
+func (w *reorgWebhook) deliver(ev common.ReorgEvent) {
+	body, err := json.Marshal(reorgReport{
+		Network:      w.network,
+		OldHead:      ev.OldHead,
+		OldNumber:    ev.OldNumber,
+		NewHead:      ev.NewHead,
+		NewNumber:    ev.NewNumber,
+		CommonHash:   ev.CommonHash,
+		CommonNumber: ev.CommonNumber,
+		Depth:        ev.Depth,
+		DroppedTxs:   ev.DroppedTxs,
+		Time:         time.Now().UTC(),
+	})
+	if err != nil {
+		log.Error("Failed to encode reorg report", "err", err)
+		return
+	}
+	for attempt := 1; attempt <= reorgWebhookAttempts; attempt++ {
+		if err = w.post(body); err == nil {
+			log.Debug("Reported chain reorg", "depth", ev.Depth, "webhook", w.url)
+			return
+		}
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-w.ctx.Done():
+			return
+		}
+	}
+	log.Warn("Failed to report chain reorg", "depth", ev.Depth, "webhook", w.url, "err", err)
+}
+
+func (w *reorgWebhook) post(body []byte) error {
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
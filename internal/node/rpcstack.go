@@ -41,15 +41,19 @@ type httpConfig struct {
 	CorsAllowedOrigins []string
 	Vhosts             []string
 	prefix             string
-	jwtSecret          []byte // optional JWT secret
+	jwtSecret          []byte                 // optional JWT secret
+	limits             jsonrpc.Limits         // request limits, zero for none
+	access             *jsonrpc.AccessControl // API keys required by the endpoint, nil for none
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
 	Origins   []string
 	Modules   []string
-	prefix    string // path prefix on which to mount ws handler
-	jwtSecret []byte // optional JWT secret
+	prefix    string                 // path prefix on which to mount ws handler
+	jwtSecret []byte                 // optional JWT secret
+	limits    jsonrpc.Limits         // request limits, zero for none
+	access    *jsonrpc.AccessControl // API keys required by the endpoint, nil for none
 }
 
 type rpcHandler struct {
@@ -193,6 +197,15 @@ func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 }
 
+// registerHandler serves handler on path, next to the JSON-RPC endpoint. It
+// must be called before the server starts.
+func (h *httpServer) registerHandler(name, path string, handler http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mux.Handle(path, handler)
+	h.handlerNames[path] = name
+}
+
 // enableWS turns on JSON-RPC over WebSocket on the server.
 func (h *httpServer) enableWS(apis []jsonrpc.API, config wsConfig) error {
 	h.mu.Lock()
@@ -203,6 +216,10 @@ func (h *httpServer) enableWS(apis []jsonrpc.API, config wsConfig) error {
 	}
 	// Create RPC server and handler.
 	srv := jsonrpc.NewServer()
+	srv.SetLimits(config.limits)
+	if config.access != nil {
+		srv.SetAccessControl(config.access)
+	}
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
@@ -298,12 +315,19 @@ func (h *httpServer) enableRPC(apis []jsonrpc.API, config httpConfig) error {
 	}
 
 	srv := jsonrpc.NewServer()
+	srv.SetLimits(config.limits)
+	cors := config.CorsAllowedOrigins
+	if config.access != nil {
+		// The API keys carry their own CORS origins.
+		srv.SetAccessControl(config.access)
+		cors = nil
+	}
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
+		Handler: NewHTTPHandlerStack(srv, cors, config.Vhosts, config.jwtSecret),
 		server:  srv,
 	})
 	return nil
@@ -487,6 +511,9 @@ func RegisterApisFromWhitelist(apis []jsonrpc.API, modules []string, srv *jsonrp
 	}
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
+		if strings.EqualFold(module, allModules) {
+			exposeAll = true
+		}
 		whitelist[module] = true
 	}
 	for _, api := range apis {
@@ -0,0 +1,88 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/log"
+)
+
+// stopStep is a service stopped when the node shuts down.
+type stopStep struct {
+	name string
+	stop func() error
+}
+
+// stopSteps returns the running services in the order they are stopped, each
+// one before those it depends on: the RPC first so that no new request comes
+// in, then the producers of blocks and transactions, the miner and the sync,
+// then the transaction pool and the network they use, and the chain last.
+// The database is flushed and closed by doClose once they are all down, so no
+// service writes to it while it closes.
+func (n *Node) stopSteps() []stopStep {
+	steps := []stopStep{
+		{"rpc", func() error { n.stopRPC(); return nil }},
+	}
+	if n.config.NodeCfg.DBReadOnly {
+		// Only the RPC and the chain were started.
+		return append(steps, stopStep{"blockchain", n.blockChain.Close})
+	}
+	if n.light != nil {
+		// Neither the miner nor the initial sync were started.
+		return append(steps,
+			stopStep{"light client", n.light.Stop},
+			stopStep{"sync", n.sync.Stop},
+			stopStep{"txpool", n.txspool.Stop},
+			stopStep{"p2p", n.p2p.Stop},
+			stopStep{"blockchain", n.blockChain.Close},
+			stopStep{"engine", n.engine.Close},
+		)
+	}
+	steps = append(steps,
+		stopStep{"miner", func() error { n.miner.Close(); return nil }},
+		stopStep{"initial sync", n.is.Stop},
+		stopStep{"sync", n.sync.Stop},
+	)
+	if n.depositContract != nil {
+		steps = append(steps, stopStep{"deposit", n.depositContract.Stop})
+	}
+	return append(steps,
+		stopStep{"txpool", n.txspool.Stop},
+		stopStep{"p2p", n.p2p.Stop},
+		stopStep{"blockchain", n.blockChain.Close},
+		stopStep{"engine", n.engine.Close},
+	)
+}
+
+// stopServices terminates running services, RPC and p2p networking.
+// It is the inverse of Start. A service failing to stop doesn't keep the
+// following ones running.
+func (n *Node) stopServices() []error {
+	var errs []error
+	for _, step := range n.stopSteps() {
+		start := time.Now()
+		if err := step.stop(); err != nil {
+			log.Error("Failed to stop service", "service", step.name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+			continue
+		}
+		log.Debug("Stopped service", "service", step.name, "elapsed", time.Since(start))
+	}
+	return errs
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// verifyLogInterval is how often the progress of a verification is logged.
+const verifyLogInterval = 8 * time.Second
+
+// VerifyChain checks the canonical blocks from first to last as stored in the
+// database: every header must be stored under its own hash and link to the
+// previous canonical one, and the transactions and receipts must match the
+// roots and bloom of the header. Receipts pruned from the database aren't
+// checked. If execute is set, the blocks are also executed again on the state
+// history and their outcome validated, which needs the history of the whole
+// range. The first inconsistency found is returned.
+func VerifyChain(ctx context.Context, chain common.IBlockChain, first, last uint64, execute bool) error {
+	if first > last {
+		return fmt.Errorf("verify failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if head := chain.CurrentBlock().Number64().Uint64(); last > head {
+		return fmt.Errorf("verify failed: last (%d) is above the head (%d)", last, head)
+	}
+	executor, ok := chain.(interface {
+		VerifyExecution(tx kv.Tx, b *block.Block) error
+	})
+	if execute && !ok {
+		return fmt.Errorf("verify failed: the chain can't execute blocks")
+	}
+	log.Info("Verifying blockchain", "first", first, "last", last, "execute", execute)
+
+	return chain.DB().View(ctx, func(tx kv.Tx) error {
+		receiptsFrom, err := rawdb.ReceiptsAvailableFrom(tx)
+		if err != nil {
+			return err
+		}
+		if execute {
+			pruned, err := rawdb.ReadStatePruneProgress(tx)
+			if err != nil {
+				return err
+			}
+			if first < pruned {
+				return fmt.Errorf("verify failed: state history starts at block %d", pruned)
+			}
+		}
+		var (
+			start  = time.Now()
+			logged = start
+			parent types.Hash
+		)
+		if first > 0 {
+			if parent, err = rawdb.ReadCanonicalHash(tx, first-1); err != nil {
+				return err
+			}
+		}
+		for nr := first; nr <= last; nr++ {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			hash, err := rawdb.ReadCanonicalHash(tx, nr)
+			if err != nil {
+				return err
+			}
+			if hash == (types.Hash{}) {
+				return fmt.Errorf("block %d: no canonical hash", nr)
+			}
+			b, err := verifyBlock(tx, nr, hash, parent, nr >= receiptsFrom)
+			if err != nil {
+				return fmt.Errorf("block %d (%x): %w", nr, hash, err)
+			}
+			if execute && nr > 0 {
+				if err := executor.VerifyExecution(tx, b); err != nil {
+					return fmt.Errorf("block %d (%x): execution: %w", nr, hash, err)
+				}
+			}
+			parent = hash
+			if time.Since(logged) > verifyLogInterval {
+				log.Info("Verifying blockchain", "number", nr, "last", last, "elapsed", time.Since(start))
+				logged = time.Now()
+			}
+		}
+		log.Info("Verified blockchain", "first", first, "last", last, "elapsed", time.Since(start))
+		return nil
+	})
+}
+
+// verifyBlock checks a canonical block against its header and its parent and
+// returns it.
+func verifyBlock(tx kv.Tx, nr uint64, hash, parent types.Hash, receipts bool) (*block.Block, error) {
+	header := rawdb.ReadHeader(tx, hash, nr)
+	if header == nil {
+		return nil, fmt.Errorf("header not found")
+	}
+	if have := header.Hash(); have != hash {
+		return nil, fmt.Errorf("header hash mismatch: have %x", have)
+	}
+	if header.Number64().Uint64() != nr {
+		return nil, fmt.Errorf("header number mismatch: have %d", header.Number64().Uint64())
+	}
+	if nr > 0 && header.ParentHash != parent {
+		return nil, fmt.Errorf("parent hash mismatch: have %x, want %x", header.ParentHash, parent)
+	}
+	b := rawdb.ReadBlock(tx, hash, nr)
+	if b == nil {
+		return nil, fmt.Errorf("body not found")
+	}
+	if root := internal.DeriveSha(transaction.Transactions(b.Transactions())); root != header.TxHash {
+		return nil, fmt.Errorf("transaction root mismatch: have %x, want %x", root, header.TxHash)
+	}
+	if !receipts {
+		return b, nil
+	}
+	stored := rawdb.ReadRawReceipts(tx, nr)
+	if len(stored) != len(b.Transactions()) {
+		return nil, fmt.Errorf("receipt count mismatch: have %d, want %d", len(stored), len(b.Transactions()))
+	}
+	if root := internal.DeriveSha(stored); root != header.ReceiptHash {
+		return nil, fmt.Errorf("receipt root mismatch: have %x, want %x", root, header.ReceiptHash)
+	}
+	if bloom := block.CreateBloom(stored); bloom != header.Bloom {
+		return nil, fmt.Errorf("bloom mismatch")
+	}
+	return b, nil
+}
@@ -0,0 +1,130 @@
+package p2p
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/amazechain/amc/utils"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// bannedPeersFile keeps the bans of the data directory across restarts.
+	bannedPeersFile = "banned-peers.json"
+
+	// scoreBanDuration is how long a peer whose score turned bad is banned.
+	scoreBanDuration = time.Hour
+	// banCheckInterval is how often the connected peers are checked for a
+	// bad score and the expired bans are dropped.
+	banCheckInterval = 30 * time.Second
+)
+
+// bannedPeer is an entry of the ban list file.
+type bannedPeer struct {
+	ID    peer.ID    `json:"id"`
+	Until *time.Time `json:"until,omitempty"` // nil for permanent bans
+}
+
+// BanPeer bans a peer for the given duration, or for good if it is zero, and
+// disconnects it. Banned peers are neither dialed nor accepted, static and
+// trusted ones included.
+func (s *Service) BanPeer(pid peer.ID, duration time.Duration) error {
+	var until time.Time
+	if duration > 0 {
+		until = time.Now().Add(duration)
+	}
+	s.peers.Ban(pid, until)
+	if s.host.Network().Connectedness(pid) == network.Connected {
+		if err := s.Disconnect(pid); err != nil {
+			log.Debug("Could not disconnect banned peer", "peer", pid, "err", err)
+		}
+	}
+	return s.saveBans()
+}
+
+// UnbanPeer lifts the ban of a peer.
+func (s *Service) UnbanPeer(pid peer.ID) error {
+	s.peers.Unban(pid)
+	return s.saveBans()
+}
+
+// startBans restores the bans saved by an earlier run and starts banning the
+// peers whose score turns bad.
+func (s *Service) startBans() {
+	path := filepath.Join(s.cfg.DataDir, bannedPeersFile)
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		log.Error("Could not read ban list", "file", path, "err", err)
+	default:
+		var bans []bannedPeer
+		if err := json.Unmarshal(data, &bans); err != nil {
+			log.Error("Invalid ban list", "file", path, "err", err)
+			break
+		}
+		for _, ban := range bans {
+			var until time.Time
+			if ban.Until != nil {
+				until = *ban.Until
+			}
+			s.peers.Ban(ban.ID, until)
+		}
+	}
+	utils.RunEvery(s.ctx, banCheckInterval, s.banBadPeers)
+}
+
+// banBadPeers bans and disconnects the connected peers whose score turned bad.
+// Trusted and static peers are only ever banned by hand.
+func (s *Service) banBadPeers() {
+	var changed bool
+	for _, pid := range s.peers.Connected() {
+		if s.peers.IsBanned(pid) || s.peers.IsTrusted(pid) || s.IsStatic(pid) || !s.peers.Scorers().IsBadPeer(pid) {
+			continue
+		}
+		log.Debug("Banning peer with a bad score", "peer", pid, "duration", scoreBanDuration, "score", s.peers.Scorers().Score(pid))
+		s.peers.Ban(pid, time.Now().Add(scoreBanDuration))
+		if err := s.Disconnect(pid); err != nil {
+			log.Debug("Could not disconnect banned peer", "peer", pid, "err", err)
+		}
+		changed = true
+	}
+	if s.peers.ExpireBans() > 0 {
+		changed = true
+	}
+	if changed {
+		if err := s.saveBans(); err != nil {
+			log.Error("Could not save ban list", "err", err)
+		}
+	}
+}
+
+// saveBans writes the ban list to the data directory.
+func (s *Service) saveBans() error {
+	if s.cfg.DataDir == "" {
+		return nil
+	}
+	s.banLock.Lock()
+	defer s.banLock.Unlock()
+
+	banned := s.peers.Banned()
+	bans := make([]bannedPeer, 0, len(banned))
+	for pid, until := range banned {
+		ban := bannedPeer{ID: pid}
+		if !until.IsZero() {
+			until := until
+			ban.Until = &until
+		}
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].ID < bans[j].ID })
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.cfg.DataDir, bannedPeersFile), data, 0600)
+}
@@ -0,0 +1,146 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal/p2p/peers"
+	"github.com/amazechain/amc/internal/p2p/peers/scorers"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// newBanTestService returns a service keeping its ban list in dataDir, with
+// its host on a mock network.
+func newBanTestService(t *testing.T, net mocknet.Mocknet, dataDir string) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	h, err := net.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Service{
+		ctx:  ctx,
+		cfg:  &conf.P2PConfig{DataDir: dataDir},
+		host: h,
+		peers: peers.NewStatus(ctx, &peers.StatusConfig{
+			PeerLimit:    10,
+			ScorerParams: &scorers.Config{},
+		}),
+	}
+}
+
+// connectBanTestPeer connects a new peer of the mock network to the service.
+func connectBanTestPeer(t *testing.T, net mocknet.Mocknet, s *Service) peer.ID {
+	h, err := net.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := net.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := net.ConnectPeers(s.host.ID(), h.ID()); err != nil {
+		t.Fatal(err)
+	}
+	// Let identify finish on both ends, lest its streams dial the peer again
+	// once it is banned: the mock hosts have no connection gater.
+	for _, end := range [][2]host.Host{{s.host, h}, {h, s.host}} {
+		ids := end[0].(interface{ IDService() identify.IDService }).IDService()
+		for _, conn := range end[0].Network().ConnsToPeer(end[1].ID()) {
+			<-ids.IdentifyWait(conn)
+		}
+	}
+	s.peers.Add(nil, h.ID(), h.Addrs()[0], network.DirOutbound)
+	s.peers.SetConnectionState(h.ID(), peers.PeerConnected)
+	return h.ID()
+}
+
+func TestBanPeer(t *testing.T) {
+	net := mocknet.New()
+	defer net.Close()
+	dataDir := t.TempDir()
+
+	s := newBanTestService(t, net, dataDir)
+	banned, timed := connectBanTestPeer(t, net, s), connectBanTestPeer(t, net, s)
+	if err := s.BanPeer(banned, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.BanPeer(timed, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.BanPeer("unknown", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for _, pid := range []peer.ID{banned, timed} {
+		if s.host.Network().Connectedness(pid) == network.Connected {
+			t.Errorf("banned peer %s still connected", pid)
+		}
+		addr := ma.StringCast("/ip4/10.0.0.1/tcp/30303")
+		if s.InterceptAddrDial(pid, addr) {
+			t.Errorf("dial of banned peer %s allowed", pid)
+		}
+		if s.InterceptSecured(network.DirOutbound, pid, nil) {
+			t.Errorf("connection to banned peer %s allowed", pid)
+		}
+	}
+	if err := s.UnbanPeer("unknown"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart restores the bans that were saved.
+	restarted := newBanTestService(t, net, dataDir)
+	restarted.startBans()
+	bans := restarted.peers.Banned()
+	if len(bans) != 2 {
+		t.Fatalf("restored bans %v, want %s and %s", bans, banned, timed)
+	}
+	if until, ok := bans[banned]; !ok || !until.IsZero() {
+		t.Errorf("permanent ban restored until %v (%t)", until, ok)
+	}
+	if until, ok := bans[timed]; !ok || !until.Equal(s.peers.Banned()[timed]) {
+		t.Errorf("timed ban restored until %v (%t), want %v", until, ok, s.peers.Banned()[timed])
+	}
+}
+
+func TestBanBadPeers(t *testing.T) {
+	net := mocknet.New()
+	defer net.Close()
+
+	s := newBanTestService(t, net, t.TempDir())
+	good, bad, trusted := connectBanTestPeer(t, net, s), connectBanTestPeer(t, net, s), connectBanTestPeer(t, net, s)
+	s.peers.SetTrusted(trusted, true)
+	for _, pid := range []peer.ID{bad, trusted} {
+		for i := 0; i < s.peers.Scorers().BadResponsesScorer().Params().Threshold; i++ {
+			s.peers.Scorers().BadResponsesScorer().Increment(pid)
+		}
+	}
+	s.peers.Ban("expired", time.Now().Add(-time.Second))
+
+	s.banBadPeers()
+	if !s.peers.IsBanned(bad) {
+		t.Fatal("peer with a bad score not banned")
+	}
+	if s.host.Network().Connectedness(bad) == network.Connected {
+		t.Error("peer with a bad score still connected")
+	}
+	if s.peers.IsBanned(good) || s.peers.IsBanned(trusted) {
+		t.Error("good or trusted peer banned")
+	}
+	if expired := s.peers.ExpireBans(); expired != 0 {
+		t.Errorf("%d expired bans left over", expired)
+	}
+
+	// The new ban outlives a restart.
+	restarted := newBanTestService(t, net, s.cfg.DataDir)
+	restarted.startBans()
+	if bans := restarted.peers.Banned(); len(bans) != 1 || bans[bad].IsZero() {
+		t.Errorf("restored bans %v, want a timed ban of %s", bans, bad)
+	}
+}
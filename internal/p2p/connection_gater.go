@@ -33,8 +33,12 @@ func (_ *Service) InterceptPeerDial(_ peer.ID) (allow bool) {
 // InterceptAddrDial tests whether we're permitted to dial the specified
 // multiaddr for the given peer.
 func (s *Service) InterceptAddrDial(pid peer.ID, m multiaddr.Multiaddr) (allow bool) {
-	// Disallow bad peers from dialing in.
-	if s.peers.IsBad(pid) {
+	// Disallow bad peers from dialing in, unless they were configured.
+	// Banned peers are refused either way.
+	if s.peers.IsBanned(pid) || (s.peers.IsBad(pid) && !s.IsStatic(pid)) {
+		return false
+	}
+	if !s.netAllowed(m) {
 		return false
 	}
 	return filterConnections(s.addrFilter, m)
@@ -53,16 +57,29 @@ func (s *Service) InterceptAccept(n network.ConnMultiaddrs) (allow bool) {
 		log.Trace("Not accepting inbound dial from ip address", "peer", n.RemoteMultiaddr(), "reason", "exceeded dial limit")
 		return false
 	}
-	if s.isPeerAtLimit(true /* inbound */) {
-		log.Trace("Not accepting inbound dial", "peer", n.RemoteMultiaddr(), "reason", "at peer limit")
+	if !s.netAllowed(n.RemoteMultiaddr()) {
+		log.Trace("Not accepting inbound dial from ip address", "peer", n.RemoteMultiaddr(), "reason", "outside of the restricted networks")
 		return false
 	}
 	return filterConnections(s.addrFilter, n.RemoteMultiaddr())
 }
 
 // InterceptSecured tests whether a given connection, now authenticated,
-// is allowed.
-func (_ *Service) InterceptSecured(_ network.Direction, _ peer.ID, _ network.ConnMultiaddrs) (allow bool) {
+// is allowed. The peer limit is only checked once the remote peer is known,
+// trusted and static peers are let in beyond it. Banned peers are refused in
+// both directions.
+func (s *Service) InterceptSecured(dir network.Direction, pid peer.ID, n network.ConnMultiaddrs) (allow bool) {
+	if s.peers.IsBanned(pid) {
+		log.Trace("Not accepting connection", "peer", pid, "reason", "banned")
+		return false
+	}
+	if dir != network.DirInbound || s.peers.IsTrusted(pid) || s.IsStatic(pid) {
+		return true
+	}
+	if s.isPeerAtLimit(true /* inbound */) {
+		log.Trace("Not accepting inbound dial", "peer", n.RemoteMultiaddr(), "reason", "at peer limit")
+		return false
+	}
 	return true
 }
 
@@ -156,6 +173,20 @@ func configureFilter(cfg *conf.P2PConfig) (*multiaddr.Filters, error) {
 	return addrFilter, nil
 }
 
+// netAllowed checks the address against the networks given with --netrestrict.
+// Without them every address is allowed, with them only ip addresses within
+// one of the networks are.
+func (s *Service) netAllowed(a multiaddr.Multiaddr) bool {
+	if s.netRestrict == nil {
+		return true
+	}
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		return false
+	}
+	return s.netRestrict.Contains(ip)
+}
+
 // helper function to either accept or deny all private addresses
 // if a new rule for a private address is in conflict with a previous one, log a warning
 func privateCIDRFilter(addrFilter *multiaddr.Filters, action multiaddr.Action) (*multiaddr.Filters, error) {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/amazechain/amc/conf"
 	"github.com/amazechain/amc/internal/p2p/discover"
+	"github.com/amazechain/amc/internal/p2p/dnsdisc"
 	"github.com/amazechain/amc/internal/p2p/enode"
 	"github.com/amazechain/amc/internal/p2p/enr"
 	"github.com/amazechain/amc/params"
@@ -20,6 +21,10 @@ import (
 	"github.com/pkg/errors"
 )
 
+// discoveryMixTimeout is how long the discovery mix waits on a source before
+// taking a node from another one.
+const discoveryMixTimeout = 100 * time.Millisecond
+
 // Listener defines the discovery V5 network interface that is used
 // to communicate with other peers.
 type Listener interface {
@@ -50,8 +55,7 @@ func (s *Service) RefreshENR() {
 
 // listen for new nodes watches for new nodes in the network and adds them to the peerstore.
 func (s *Service) listenForNewNodes() {
-	iterator := s.dv5Listener.RandomNodes()
-	iterator = enode.Filter(iterator, s.filterPeer)
+	iterator := enode.Filter(s.discoveryIterator(), s.filterPeer)
 	defer iterator.Close()
 	for {
 		// Exit if service's context is canceled
@@ -86,6 +90,25 @@ func (s *Service) listenForNewNodes() {
 	}
 }
 
+// discoveryIterator mixes the nodes of every discovery source: random walks of
+// the DHT, the nodes advertising the network topic and the DNS node trees.
+func (s *Service) discoveryIterator() enode.Iterator {
+	mix := enode.NewFairMix(discoveryMixTimeout)
+	mix.AddSource(s.dv5Listener.RandomNodes())
+	if s.topics != nil {
+		mix.AddSource(s.topics.nodes(s.ctx))
+	}
+	if len(s.cfg.DiscoveryDNS) > 0 {
+		client := dnsdisc.NewClient(dnsdisc.Config{})
+		if it, err := client.NewIterator(s.cfg.DiscoveryDNS...); err != nil {
+			log.Error("Could not start DNS discovery", "err", err)
+		} else {
+			mix.AddSource(it)
+		}
+	}
+	return mix
+}
+
 func (s *Service) createListener(
 	ipAddr net.IP,
 	privKey *ecdsa.PrivateKey,
@@ -155,7 +178,8 @@ func (s *Service) createListener(
 		}
 	}
 	dv5Cfg := discover.Config{
-		PrivateKey: privKey,
+		PrivateKey:  privKey,
+		NetRestrict: s.netRestrict,
 	}
 	dv5Cfg.Bootnodes = []*enode.Node{}
 	for _, addr := range s.cfg.Discv5BootStrapAddr {
@@ -274,6 +298,7 @@ func (s *Service) filterPeer(node *enode.Node) bool {
 // This checks our set max peers in our config, and
 // determines whether our currently connected and
 // active peers are above our set max peer limit.
+// Outbound peers are also capped by their own limit.
 func (s *Service) isPeerAtLimit(inbound bool) bool {
 	numOfConns := len(s.host.Network().Peers())
 	maxPeers := int(s.cfg.MaxPeers)
@@ -289,7 +314,39 @@ func (s *Service) isPeerAtLimit(inbound bool) bool {
 		}
 	}
 	activePeers := len(s.Peers().Active())
-	return activePeers >= maxPeers || numOfConns >= maxPeers
+	if activePeers >= maxPeers || numOfConns >= maxPeers {
+		return true
+	}
+	return !inbound && len(s.peers.OutboundConnected()) >= s.peers.OutboundLimit()
+}
+
+// peerLimits returns the inbound and outbound peer limits of the
+// configuration. Without an explicit inbound limit, one in every DialRatio
+// peer slots is kept for the peers we dial, so that inbound peers can't
+// take all of them. Unset limits are left to the defaults of the peer
+// status, a negative inbound limit and a zero outbound one.
+func peerLimits(cfg *conf.P2PConfig) (inbound, outbound int) {
+	inbound, outbound = cfg.MaxInboundPeers, cfg.MaxOutboundPeers
+	if inbound == 0 {
+		inbound = -1
+		if cfg.DialRatio > 0 {
+			reserved := cfg.MaxPeers / cfg.DialRatio
+			if reserved == 0 {
+				reserved = 1
+			}
+			inbound = cfg.MaxPeers - reserved
+			if inbound < 0 {
+				inbound = 0
+			}
+		}
+	}
+	if inbound > cfg.MaxPeers {
+		inbound = cfg.MaxPeers
+	}
+	if outbound > cfg.MaxPeers {
+		outbound = cfg.MaxPeers
+	}
+	return inbound, outbound
 }
 
 // PeersFromStringAddrs converts peer raw ENRs into multiaddrs for p2p.
@@ -0,0 +1,318 @@
+package p2p
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/internal/p2p/discover"
+	"github.com/amazechain/amc/internal/p2p/enode"
+	"github.com/amazechain/amc/internal/p2p/enr"
+	"github.com/amazechain/amc/utils"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// topicProtocol is the discv5 talk protocol carrying topic advertisements.
+	topicProtocol = "amctopic"
+
+	// topicAdLifetime is how long a registrar keeps an advertisement.
+	topicAdLifetime = 15 * time.Minute
+	// topicAdInterval is how often a node places its advertisements again,
+	// before the previous ones expire.
+	topicAdInterval = 10 * time.Minute
+	// topicSearchInterval is the minimum time between two topic searches.
+	topicSearchInterval = 30 * time.Second
+	// topicRegistrars is the number of nodes closest to the topic an
+	// advertisement is placed at and a search asks.
+	topicRegistrars = 8
+
+	// maxTopics and maxTopicAds bound what a registrar stores for others.
+	maxTopics   = 16
+	maxTopicAds = 128
+	// maxTopicResults keeps a query response within a single discv5 packet.
+	maxTopicResults = 3
+)
+
+const (
+	topicRegisterMsg = iota
+	topicQueryMsg
+)
+
+// topicRequest is the message of a topic talk request. Registrations carry
+// the record of the advertiser, queries are answered with a list of records.
+type topicRequest struct {
+	Kind   uint
+	Topic  enode.ID
+	Record []byte
+}
+
+// amcTopic returns the topic of the nodes on the network with the given fork
+// digest. The topic is placed in the node ID space, advertisements are stored
+// at the nodes closest to it.
+func amcTopic(digest [4]byte) enode.ID {
+	return enode.ID(crypto.Keccak256Hash([]byte(topicProtocol), digest[:]))
+}
+
+// topicAd is an advertisement stored by a registrar.
+type topicAd struct {
+	node    *enode.Node
+	expires time.Time
+}
+
+// topicTable holds the advertisements a node serves as registrar.
+type topicTable struct {
+	lock sync.Mutex
+	ads  map[enode.ID]map[enode.ID]topicAd
+}
+
+// register stores the advertisement of a node for a topic, replacing its
+// previous one. It reports whether the table had room for it.
+func (t *topicTable) register(topic enode.ID, n *enode.Node, now time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	ads := t.expire(topic, now)
+	if ads == nil {
+		if len(t.ads) >= maxTopics {
+			return false
+		}
+		ads = make(map[enode.ID]topicAd)
+		t.ads[topic] = ads
+	}
+	if _, ok := ads[n.ID()]; !ok && len(ads) >= maxTopicAds {
+		return false
+	}
+	ads[n.ID()] = topicAd{node: n, expires: now.Add(topicAdLifetime)}
+	return true
+}
+
+// query returns up to limit random nodes advertising the topic.
+func (t *topicTable) query(topic enode.ID, limit int, now time.Time) []*enode.Node {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	nodes := make([]*enode.Node, 0, limit)
+	for _, ad := range t.expire(topic, now) {
+		nodes = append(nodes, ad.node)
+	}
+	rand.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+	if len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	return nodes
+}
+
+// expire drops the expired advertisements of a topic and returns the others.
+func (t *topicTable) expire(topic enode.ID, now time.Time) map[enode.ID]topicAd {
+	ads := t.ads[topic]
+	for id, ad := range ads {
+		if now.After(ad.expires) {
+			delete(ads, id)
+		}
+	}
+	if ads != nil && len(ads) == 0 {
+		delete(t.ads, topic)
+		return nil
+	}
+	return ads
+}
+
+// topicDiscovery advertises the amc topic over discv5 and looks up the nodes
+// advertising it. Random walks only find nodes of the network by chance when
+// the DHT is shared with other networks, or when few nodes are reachable;
+// the topic leads straight to them.
+type topicDiscovery struct {
+	udp   *discover.UDPv5
+	topic enode.ID
+	table *topicTable
+}
+
+func newTopicDiscovery(udp *discover.UDPv5, topic enode.ID) *topicDiscovery {
+	d := &topicDiscovery{
+		udp:   udp,
+		topic: topic,
+		table: &topicTable{ads: make(map[enode.ID]map[enode.ID]topicAd)},
+	}
+	udp.RegisterTalkHandler(topicProtocol, d.handle)
+	return d
+}
+
+// startTopicDiscovery serves as a registrar on the listener and starts
+// advertising the topic of the local network.
+func (s *Service) startTopicDiscovery(udp *discover.UDPv5) (*topicDiscovery, error) {
+	digest, err := utils.CreateForkDigest(new(uint256.Int), s.genesisHash)
+	if err != nil {
+		return nil, err
+	}
+	d := newTopicDiscovery(udp, amcTopic(digest))
+	go d.advertise(s.ctx)
+	return d, nil
+}
+
+// handle serves the topic requests of other nodes.
+func (d *topicDiscovery) handle(from enode.ID, _ *net.UDPAddr, msg []byte) []byte {
+	var req topicRequest
+	if err := rlp.DecodeBytes(msg, &req); err != nil {
+		return nil
+	}
+	switch req.Kind {
+	case topicRegisterMsg:
+		var rec enr.Record
+		if err := rlp.DecodeBytes(req.Record, &rec); err != nil {
+			return nil
+		}
+		n, err := enode.New(enode.ValidSchemes, &rec)
+		// A node only advertises itself.
+		if err != nil || n.ID() != from {
+			return nil
+		}
+		if !d.table.register(req.Topic, n, time.Now()) {
+			log.Trace("Topic table full, dropping advertisement", "topic", req.Topic, "node", from)
+		}
+		return nil
+	case topicQueryMsg:
+		nodes := d.table.query(req.Topic, maxTopicResults, time.Now())
+		records := make([]*enr.Record, len(nodes))
+		for i, n := range nodes {
+			records[i] = n.Record()
+		}
+		resp, err := rlp.EncodeToBytes(records)
+		if err != nil {
+			return nil
+		}
+		return resp
+	}
+	return nil
+}
+
+// advertise places the local record at the registrars of the topic until the
+// context is canceled.
+func (d *topicDiscovery) advertise(ctx context.Context) {
+	ticker := time.NewTicker(topicAdInterval)
+	defer ticker.Stop()
+	for {
+		d.register()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// register sends the local record to the nodes closest to the topic.
+func (d *topicDiscovery) register() {
+	rec, err := rlp.EncodeToBytes(d.udp.Self().Record())
+	if err != nil {
+		log.Error("Could not encode local record", "err", err)
+		return
+	}
+	msg, err := rlp.EncodeToBytes(&topicRequest{Kind: topicRegisterMsg, Topic: d.topic, Record: rec})
+	if err != nil {
+		log.Error("Could not encode topic registration", "err", err)
+		return
+	}
+	var placed int
+	for _, n := range d.registrars() {
+		if _, err := d.udp.TalkRequest(n, topicProtocol, msg); err != nil {
+			log.Trace("Could not register topic", "node", n.ID(), "err", err)
+			continue
+		}
+		placed++
+	}
+	log.Debug("Advertised network topic", "topic", d.topic, "registrars", placed)
+}
+
+// search asks the registrars of the topic for the nodes advertising it.
+func (d *topicDiscovery) search() []*enode.Node {
+	msg, err := rlp.EncodeToBytes(&topicRequest{Kind: topicQueryMsg, Topic: d.topic})
+	if err != nil {
+		return nil
+	}
+	var (
+		self  = d.udp.Self().ID()
+		seen  = make(map[enode.ID]struct{})
+		found []*enode.Node
+	)
+	for _, registrar := range d.registrars() {
+		resp, err := d.udp.TalkRequest(registrar, topicProtocol, msg)
+		if err != nil {
+			continue
+		}
+		var records []*enr.Record
+		if err := rlp.DecodeBytes(resp, &records); err != nil {
+			log.Trace("Invalid topic query response", "node", registrar.ID(), "err", err)
+			continue
+		}
+		for _, rec := range records {
+			n, err := enode.New(enode.ValidSchemes, rec)
+			if err != nil || n.ID() == self {
+				continue
+			}
+			if _, ok := seen[n.ID()]; ok {
+				continue
+			}
+			seen[n.ID()] = struct{}{}
+			found = append(found, n)
+		}
+	}
+	return found
+}
+
+// registrars returns the nodes closest to the topic.
+func (d *topicDiscovery) registrars() []*enode.Node {
+	nodes := d.udp.Lookup(d.topic)
+	if len(nodes) > topicRegistrars {
+		nodes = nodes[:topicRegistrars]
+	}
+	return nodes
+}
+
+// nodes returns an iterator over the nodes found by searching the topic.
+func (d *topicDiscovery) nodes(ctx context.Context) enode.Iterator {
+	ctx, cancel := context.WithCancel(ctx)
+	return &topicIterator{d: d, ctx: ctx, cancel: cancel}
+}
+
+// topicIterator searches the topic again once it has returned every node of
+// the previous search, at most once per topicSearchInterval.
+type topicIterator struct {
+	d      *topicDiscovery
+	ctx    context.Context
+	cancel context.CancelFunc
+	last   time.Time
+	buf    []*enode.Node
+	cur    *enode.Node
+}
+
+func (it *topicIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if wait := topicSearchInterval - time.Since(it.last); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-it.ctx.Done():
+				return false
+			}
+		}
+		if it.ctx.Err() != nil {
+			return false
+		}
+		it.last = time.Now()
+		it.buf = it.d.search()
+	}
+	it.cur, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+func (it *topicIterator) Node() *enode.Node {
+	return it.cur
+}
+
+func (it *topicIterator) Close() {
+	it.cancel()
+}
@@ -0,0 +1,389 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/internal/p2p/enode"
+	"github.com/amazechain/amc/internal/p2p/enr"
+	"github.com/amazechain/amc/log"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// Client discovers nodes by querying DNS servers.
+type Client struct {
+	cfg     Config
+	entries *lru.Cache[string, entry]
+
+	lock      sync.Mutex
+	lastQuery time.Time // rate limit of the DNS queries
+}
+
+// Config holds configuration options for the client.
+type Config struct {
+	Timeout         time.Duration      // timeout used for DNS lookups (default 5s)
+	RecheckInterval time.Duration      // time between tree root update checks (default 30min)
+	CacheLimit      int                // maximum number of cached records (default 1000)
+	RateLimit       float64            // maximum DNS requests / second (default 3)
+	ValidSchemes    enr.IdentityScheme // acceptable ENR identity schemes (default enode.ValidSchemes)
+	Resolver        Resolver           // the DNS resolver to use (defaults to system DNS)
+	Logger          log.Logger         // destination of client log messages (defaults to root logger)
+}
+
+// Resolver is a DNS resolver that can query TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+func (cfg Config) withDefaults() Config {
+	const (
+		defaultTimeout   = 5 * time.Second
+		defaultRecheck   = 30 * time.Minute
+		defaultRateLimit = 3
+		defaultCache     = 1000
+	)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = defaultRecheck
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = defaultCache
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = defaultRateLimit
+	}
+	if cfg.ValidSchemes == nil {
+		cfg.ValidSchemes = enode.ValidSchemes
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Root()
+	}
+	return cfg
+}
+
+// NewClient creates a client.
+func NewClient(cfg Config) *Client {
+	cfg = cfg.withDefaults()
+	cache, err := lru.New[string, entry](cfg.CacheLimit)
+	if err != nil {
+		panic(err)
+	}
+	return &Client{cfg: cfg, entries: cache}
+}
+
+// SyncTree downloads the entire node tree at the given URL.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	le, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ct := newClientTree(c, le)
+	t := &Tree{entries: make(map[string]entry)}
+	if err := ct.syncAll(t.entries); err != nil {
+		return nil, err
+	}
+	t.root = ct.root
+	return t, nil
+}
+
+// NewIterator creates an iterator that visits all nodes at the
+// given tree URLs, and of the trees they link to.
+func (c *Client) NewIterator(urls ...string) (enode.Iterator, error) {
+	it := c.newRandomIterator()
+	for _, url := range urls {
+		if err := it.addTree(url); err != nil {
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// resolveRoot retrieves a root entry via DNS.
+func (c *Client) resolveRoot(ctx context.Context, loc *linkEntry) (rootEntry, error) {
+	txts, err := c.lookupTXT(ctx, loc.domain)
+	c.cfg.Logger.Trace("Updating DNS discovery root", "tree", loc.domain, "err", err)
+	if err != nil {
+		return rootEntry{}, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			e, err := parseRoot(txt)
+			if err != nil {
+				return e, err
+			}
+			if !e.verifySignature(loc.pubkey) {
+				return e, entryError{typ: "root", err: errInvalidSig}
+			}
+			return e, nil
+		}
+	}
+	return rootEntry{}, nameError{loc.domain, errNoRoot}
+}
+
+// resolveEntry retrieves an entry from the cache or fetches it from the network
+// if it isn't cached.
+func (c *Client) resolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	cacheKey := truncateHash(hash)
+	if e, ok := c.entries.Get(cacheKey); ok {
+		return e, nil
+	}
+	e, err := c.doResolveEntry(ctx, domain, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.entries.Add(cacheKey, e)
+	return e, nil
+}
+
+// doResolveEntry fetches an entry via DNS.
+func (c *Client) doResolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	wantHash, err := b32format.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base32 hash")
+	}
+	name := hash + "." + domain
+	txts, err := c.lookupTXT(ctx, name)
+	c.cfg.Logger.Trace("DNS discovery lookup", "name", name, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		e, err := parseEntry(txt, c.cfg.ValidSchemes)
+		if errors.Is(err, errUnknownEntry) {
+			continue
+		}
+		if !bytes.HasPrefix(crypto.Keccak256([]byte(txt)), wantHash) {
+			err = nameError{name, errHashMismatch}
+		} else if err != nil {
+			err = nameError{name, err}
+		}
+		return e, err
+	}
+	return nil, nameError{name, errNoEntry}
+}
+
+// lookupTXT queries the TXT records of a name, waiting for the rate limit.
+func (c *Client) lookupTXT(ctx context.Context, name string) ([]string, error) {
+	c.lock.Lock()
+	next := c.lastQuery.Add(time.Duration(float64(time.Second) / c.cfg.RateLimit))
+	if now := time.Now(); next.Before(now) {
+		next = now
+	}
+	c.lastQuery = next
+	c.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	if wait := time.Until(next); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return c.cfg.Resolver.LookupTXT(ctx, name)
+}
+
+// randomIterator traverses a set of trees and returns nodes found in them.
+type randomIterator struct {
+	cur      *enode.Node
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	c        *Client
+
+	mu    sync.Mutex
+	roots map[string]struct{}    // trees added by the user
+	trees map[string]*clientTree // all trees, including the linked ones
+	dirty bool                   // the links of a tree changed
+}
+
+func (c *Client) newRandomIterator() *randomIterator {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &randomIterator{
+		c:        c,
+		ctx:      ctx,
+		cancelFn: cancel,
+		roots:    make(map[string]struct{}),
+		trees:    make(map[string]*clientTree),
+	}
+}
+
+// Node returns the current node.
+func (it *randomIterator) Node() *enode.Node {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.cur
+}
+
+// Close closes the iterator.
+func (it *randomIterator) Close() {
+	it.cancelFn()
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.trees = nil
+}
+
+// Next moves the iterator to the next node.
+func (it *randomIterator) Next() bool {
+	n := it.nextNode()
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.cur = n
+	return n != nil
+}
+
+// addTree adds an enrtree:// URL to the iterator.
+func (it *randomIterator) addTree(url string) error {
+	le, err := parseLink(url)
+	if err != nil {
+		return fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.roots[le.str] = struct{}{}
+	if _, ok := it.trees[le.str]; !ok {
+		it.trees[le.str] = newClientTree(it.c, le)
+	}
+	return nil
+}
+
+// nextNode syncs random tree entries until it finds a node.
+func (it *randomIterator) nextNode() *enode.Node {
+	for {
+		ct := it.pickTree()
+		if ct == nil {
+			return nil
+		}
+		n, err := ct.syncRandom(it.ctx)
+		if ct.linksChanged {
+			ct.linksChanged = false
+			it.mu.Lock()
+			it.dirty = true
+			it.mu.Unlock()
+		}
+		if err != nil {
+			if errors.Is(err, it.ctx.Err()) {
+				return nil // context canceled.
+			}
+			it.c.cfg.Logger.Debug("Error in DNS random node sync", "tree", ct.loc.domain, "err", err)
+			continue
+		}
+		if n != nil {
+			return n
+		}
+	}
+}
+
+// pickTree returns a random tree to sync from, waiting for the next root
+// check when none of them can make progress.
+func (it *randomIterator) pickTree() *clientTree {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	for {
+		if it.trees == nil {
+			return nil // Close was called.
+		}
+		if it.dirty {
+			it.rebuildTrees()
+			it.dirty = false
+		}
+		var (
+			syncable []*clientTree
+			next     time.Time
+		)
+		for _, ct := range it.trees {
+			if ct.canSyncRandom() {
+				syncable = append(syncable, ct)
+			} else if check := ct.nextScheduledRootCheck(); next.IsZero() || check.Before(next) {
+				next = check
+			}
+		}
+		if len(syncable) > 0 {
+			return syncable[rand.Intn(len(syncable))]
+		}
+		if len(it.trees) == 0 {
+			return nil
+		}
+		// No tree has anything to sync until its root is checked again.
+		it.mu.Unlock()
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-it.ctx.Done():
+		}
+		timer.Stop()
+		it.mu.Lock()
+		if it.ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// rebuildTrees adds the trees newly linked to and drops those no tree added
+// by the user links to anymore.
+func (it *randomIterator) rebuildTrees() {
+	reachable := make(map[string]struct{})
+	queue := make([]string, 0, len(it.roots))
+	for root := range it.roots {
+		queue = append(queue, root)
+	}
+	for len(queue) > 0 {
+		str := queue[0]
+		queue = queue[1:]
+		if _, ok := reachable[str]; ok {
+			continue
+		}
+		reachable[str] = struct{}{}
+		ct := it.trees[str]
+		if ct == nil {
+			le, err := parseLink(linkPrefix + str)
+			if err != nil {
+				continue
+			}
+			ct = newClientTree(it.c, le)
+			it.trees[str] = ct
+			it.c.cfg.Logger.Debug("Following DNS discovery link", "tree", le.domain)
+		}
+		for link := range ct.curLinks {
+			queue = append(queue, link)
+		}
+	}
+	for str := range it.trees {
+		if _, ok := reachable[str]; !ok {
+			delete(it.trees, str)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/internal/p2p/enode"
+)
+
+// The example tree of EIP-1459.
+const (
+	eipTreeURL = "enrtree://AKPYQIUQIL7PSIACI32J7FGZW56E5FKHEFCCOFHILBIMW3M6LWXS2@n"
+	eipRoot    = "enrtree-root:v1 e=JWXYDBPXYWG6FX3GMDIBFA6CJ4 l=C7HRFPF3BLGF3YR4DY5KX3SMBE seq=1 sig=o908WmNp7LibOfPsr4btQwatZJ5URBr2ZAuxvK4UWHlsB9sUOTJQaGAlLPVAhM__XJesCHxLISo94z5Z2a463gA"
+	eipLink    = "enrtree://AM5FCQLWIZX2QFPNJAP7VUERCCRNGRHWZG3YYHIUV7BVDQ5FDPRT2@morenodes.example.org"
+	eipBranch  = "enrtree-branch:2XS2367YHAXJFGLZHVAWLQD4ZY,H4FHT4B454P6UXFD7JCYQ5PWDY,MHTDO6TMUBRIA2XWG5LUDACK24"
+)
+
+var eipNodes = []string{
+	"enr:-HW4QOFzoVLaFJnNhbgMoDXPnOvcdVuj7pDpqRvh6BRDO68aVi5ZcjB3vzQRZH2IcLBGHzo8uUN3snqmgTiE56CH3AMBgmlkgnY0iXNlY3AyNTZrMaECC2_24YYkYHEgdzxlSNKQEnHhuNAbNlMlWJxrJxbAFvA",
+	"enr:-HW4QAggRauloj2SDLtIHN1XBkvhFZ1vtf1raYQp9TBW2RD5EEawDzbtSmlXUfnaHcvwOizhVYLtr7e6vw7NAf6mTuoCgmlkgnY0iXNlY3AyNTZrMaECjrXI8TLNXU0f8cthpAMxEshUyQlK-AM0PW2wfrnacNI",
+	"enr:-HW4QLAYqmrwllBEnzWWs7I5Ev2IAs7x_dZlbYdRdMUx5EyKHDXp7AV5CkuPGUPdvbv1_Ms1CPfhcGCvSElSosZmyoqAgmlkgnY0iXNlY3AyNTZrMaECriawHKWdDRk2xeZkrOXBQ0dfMFLHY4eENZwdufn1S1o",
+}
+
+// eipRecords returns the TXT records of the example tree, served at n.
+func eipRecords() mapResolver {
+	return mapResolver{
+		"n":                            eipRoot,
+		"C7HRFPF3BLGF3YR4DY5KX3SMBE.n": eipLink,
+		"JWXYDBPXYWG6FX3GMDIBFA6CJ4.n": eipBranch,
+		"2XS2367YHAXJFGLZHVAWLQD4ZY.n": eipNodes[0],
+		"H4FHT4B454P6UXFD7JCYQ5PWDY.n": eipNodes[1],
+		"MHTDO6TMUBRIA2XWG5LUDACK24.n": eipNodes[2],
+	}
+}
+
+func TestParseRoot(t *testing.T) {
+	tests := []struct {
+		input string
+		e     rootEntry
+		err   error
+	}{
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errSyntax},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errInvalidSig},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=TO4Q75OQ2N7DX4E seq=3 sig=o908WmNp7LibOfPsr4btQwatZJ5URBr2ZAuxvK4UWHlsB9sUOTJQaGAlLPVAhM__XJesCHxLISo94z5Z2a463gA",
+			err:   entryError{"root", errInvalidChild},
+		},
+		{
+			input: eipRoot,
+			e: rootEntry{
+				eroot: "JWXYDBPXYWG6FX3GMDIBFA6CJ4",
+				lroot: "C7HRFPF3BLGF3YR4DY5KX3SMBE",
+				seq:   1,
+				sig:   mustDecodeSig("o908WmNp7LibOfPsr4btQwatZJ5URBr2ZAuxvK4UWHlsB9sUOTJQaGAlLPVAhM__XJesCHxLISo94z5Z2a463gA"),
+			},
+		},
+	}
+	for i, test := range tests {
+		e, err := parseRoot(test.input)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %+v, want %+v", i, e, test.e)
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	testkey := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("dnsdisc test key")))
+	tests := []struct {
+		input string
+		e     entry
+		err   error
+	}{
+		// Subtrees:
+		{
+			input: "enrtree-branch:1,2",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAA",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:",
+			e:     &branchEntry{},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+		},
+		{
+			input: eipBranch,
+			e:     &branchEntry{[]string{"2XS2367YHAXJFGLZHVAWLQD4ZY", "H4FHT4B454P6UXFD7JCYQ5PWDY", "MHTDO6TMUBRIA2XWG5LUDACK24"}},
+		},
+		// Links
+		{
+			input: "enrtree://" + b32format.EncodeToString(crypto.CompressPubkey(&testkey.PublicKey)) + "@nodes.example.org",
+			e:     newLinkEntry("nodes.example.org", &testkey.PublicKey),
+		},
+		{
+			input: "enrtree://nodes.example.org",
+			err:   entryError{"link", errNoPubkey},
+		},
+		{
+			input: "enrtree://AP62DT7WOTEQZGQZOU474PP3KMEGVTTE7A7NPRXKX3DUD57@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		{
+			input: "enrtree://AP62DT7WONEQZGQZOU474PP3KMEGVTTE7A7NPRXKX3DUD57TQHGIA@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		// ENRs
+		{
+			input: eipNodes[0],
+			e:     &enrEntry{enode.MustParse(eipNodes[0])},
+		},
+		{
+			input: "enr:-HW4QLZHjM4vZXkbp-5xJoHsKSbE7W39FPC8283X-y8oHcHPTnDDlIlzL5ArvDUlHZVDPgmFASrh7cWgLOLxj4wprRkHgmlkgnY0iXNlY3AyNTZrMaEC3t2jLMhDpCDX5mbSEwDn4L3iUfyXzoO8G28XvjGRkrAg=",
+			err:   entryError{"enr", errInvalidENR},
+		},
+		// Invalid:
+		{input: "", err: errUnknownEntry},
+		{input: "foo", err: errUnknownEntry},
+		{input: "enrtree", err: errUnknownEntry},
+		{input: "enrtree-x=", err: errUnknownEntry},
+	}
+	for i, test := range tests {
+		e, err := parseEntry(test.input, enode.ValidSchemes)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %#v, want %#v", i, e, test.e)
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestEIPTreeHashes(t *testing.T) {
+	// Every record of the example lives at the hash of its content.
+	for name, record := range eipRecords() {
+		if name == "n" {
+			continue
+		}
+		e, err := parseEntry(record, enode.ValidSchemes)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if sub := subdomain(e); sub+".n" != name {
+			t.Errorf("record at %s hashes to %s", name, sub)
+		}
+	}
+}
+
+func TestRootSignature(t *testing.T) {
+	_, pubkey, err := ParseURL(eipTreeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := parseRoot(eipRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root.verifySignature(pubkey) {
+		t.Fatal("signature of the example root doesn't verify")
+	}
+	tampered := root
+	tampered.seq++
+	if tampered.verifySignature(pubkey) {
+		t.Fatal("signature verifies on a changed root")
+	}
+	other := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("dnsdisc other key")))
+	if root.verifySignature(&other.PublicKey) {
+		t.Fatal("signature verifies with another key")
+	}
+}
+
+func TestMakeTree(t *testing.T) {
+	nodes := parseNodes(eipNodes)
+	tree, err := MakeTree(2, nodes, []string{eipLink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("dnsdisc test key")))
+	url, err := tree.Sign(key, "nodes.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(url, "@nodes.example.org") {
+		t.Fatalf("wrong tree URL %s", url)
+	}
+	if !reflect.DeepEqual(sortByID(tree.Nodes()), sortByID(nodes)) {
+		t.Errorf("wrong nodes in tree")
+	}
+	if links := tree.Links(); !reflect.DeepEqual(links, []string{eipLink}) {
+		t.Errorf("wrong links %v", links)
+	}
+
+	// The records of the tree resolve back to it.
+	txt := tree.ToTXT("nodes.example.org")
+	c := newTestClient(mapResolver(txt))
+	synced, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced.Seq() != 2 || !reflect.DeepEqual(synced.ToTXT("nodes.example.org"), txt) {
+		t.Errorf("synced tree differs:\n%v\nwant\n%v", synced.ToTXT("nodes.example.org"), txt)
+	}
+}
+
+func TestClientSyncTree(t *testing.T) {
+	c := newTestClient(eipRecords())
+	tree, err := c.SyncTree(eipTreeURL)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortByID(tree.Nodes()), sortByID(parseNodes(eipNodes))) {
+		t.Errorf("wrong nodes in synced tree: %v", tree.Nodes())
+	}
+	if links := tree.Links(); !reflect.DeepEqual(links, []string{eipLink}) {
+		t.Errorf("wrong links in synced tree: %v", links)
+	}
+	if tree.Seq() != 1 {
+		t.Errorf("synced tree has wrong seq: %d", tree.Seq())
+	}
+}
+
+func TestClientSyncTreeMalformed(t *testing.T) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("dnsdisc test key")))
+	tests := []struct {
+		name   string
+		change func(r mapResolver) string // returns the URL of the changed tree
+		err    string
+	}{
+		{"no root", func(r mapResolver) string {
+			r["n"] = eipBranch
+			return eipTreeURL
+		}, errNoRoot.Error()},
+		{"changed root", func(r mapResolver) string {
+			r["n"] = strings.Replace(eipRoot, "seq=1", "seq=2", 1)
+			return eipTreeURL
+		}, entryError{"root", errInvalidSig}.Error()},
+		{"root of another key", func(r mapResolver) string {
+			return newLinkEntry("n", &key.PublicKey).String()
+		}, entryError{"root", errInvalidSig}.Error()},
+		{"missing node", func(r mapResolver) string {
+			delete(r, "H4FHT4B454P6UXFD7JCYQ5PWDY.n")
+			return eipTreeURL
+		}, "not found"},
+		{"node not at its hash", func(r mapResolver) string {
+			r["H4FHT4B454P6UXFD7JCYQ5PWDY.n"] = eipNodes[2]
+			return eipTreeURL
+		}, errHashMismatch.Error()},
+		{"rebuilt tree", func(r mapResolver) string {
+			return replaceNodes(t, r, key, eipNodes...)
+		}, ""},
+		{"malformed node", func(r mapResolver) string {
+			return replaceNodes(t, r, key, eipNodes[0], "enr:"+b64format.EncodeToString([]byte{0xc3, 0x01, 0x02}))
+		}, "invalid enr entry"},
+		{"link in the node tree", func(r mapResolver) string {
+			return replaceNodes(t, r, key, eipNodes[0], eipLink)
+		}, errLinkInENRTree.Error()},
+	}
+	for _, tt := range tests {
+		r := eipRecords()
+		url := tt.change(r)
+		_, err := newTestClient(r).SyncTree(url)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestClientLinkResolution(t *testing.T) {
+	// A tree of links pointing to a tree of nodes.
+	nodesKey := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("dnsdisc nodes key")))
+	nodesTree, err := MakeTree(1, parseNodes(eipNodes), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodesURL, err := nodesTree.Sign(nodesKey, "nodes.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	linksKey := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("dnsdisc links key")))
+	linksTree, err := MakeTree(1, nil, []string{nodesURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linksURL, err := linksTree.Sign(linksKey, "links.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mapResolver(nodesTree.ToTXT("nodes.example.org"))
+	for name, record := range linksTree.ToTXT("links.example.org") {
+		r[name] = record
+	}
+
+	it, err := newTestClient(r).NewIterator(linksURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	want := make(map[enode.ID]bool)
+	for _, n := range parseNodes(eipNodes) {
+		want[n.ID()] = true
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for len(want) > 0 && time.Now().Before(deadline) && it.Next() {
+		delete(want, it.Node().ID())
+	}
+	if len(want) > 0 {
+		t.Fatalf("%d nodes of the linked tree not found", len(want))
+	}
+}
+
+// replaceNodes replaces the node records of the example tree by the given
+// ones, under a branch of its own, and signs the root again with key. It
+// returns the URL of the tree.
+func replaceNodes(t *testing.T, r mapResolver, key *ecdsa.PrivateKey, records ...string) string {
+	root, err := parseRoot(r["n"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := make([]string, len(records))
+	for i, record := range records {
+		children[i] = b32format.EncodeToString(crypto.Keccak256([]byte(record))[:16])
+		r[children[i]+".n"] = record
+	}
+	branch := &branchEntry{children}
+	r[subdomain(branch)+".n"] = branch.String()
+
+	root.eroot = subdomain(branch)
+	if root.sig, err = crypto.Sign(root.sigHash(), key); err != nil {
+		t.Fatal(err)
+	}
+	r["n"] = root.String()
+	return newLinkEntry("n", &key.PublicKey).String()
+}
+
+// newTestClient returns a client of the records, without rate limit.
+func newTestClient(r mapResolver) *Client {
+	return NewClient(Config{Resolver: r, RateLimit: 1e6})
+}
+
+func mustDecodeSig(s string) []byte {
+	sig, err := b64format.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func parseNodes(rec []string) []*enode.Node {
+	var ns []*enode.Node
+	for _, r := range rec {
+		var n enode.Node
+		if err := n.UnmarshalText([]byte(r)); err != nil {
+			panic(err)
+		}
+		ns = append(ns, &n)
+	}
+	return ns
+}
+
+func sortByID(nodes []*enode.Node) []*enode.Node {
+	sort.Slice(nodes, func(i, j int) bool {
+		return strings.Compare(nodes[i].ID().String(), nodes[j].ID().String()) < 0
+	})
+	return nodes
+}
+
+// mapResolver serves TXT records from a map.
+type mapResolver map[string]string
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, errors.New("not found")
+}
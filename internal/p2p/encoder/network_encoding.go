@@ -13,6 +13,8 @@ type NetworkEncoding interface {
 	// DecodeWithMaxLength a bytes from a reader with a varint length prefix. The interface must be a pointer to the
 	// decoding destination. The length of the message should not be more than the provided limit.
 	DecodeWithMaxLength(io.Reader, ssz.Unmarshaler) error
+	// DecodeWithLimit is DecodeWithMaxLength with a message specific limit, which can't exceed the chunk limit.
+	DecodeWithLimit(io.Reader, ssz.Unmarshaler, uint64) error
 	// EncodeGossip an arbitrary gossip message to the provided writer. The interface must be a pointer object to encode.
 	EncodeGossip(io.Writer, ssz.Marshaler) (int, error)
 	// EncodeWithMaxLength an arbitrary message to the provided writer with a varint length prefix. The interface must be
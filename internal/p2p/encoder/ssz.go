@@ -104,15 +104,25 @@ func DecodeSnappy(msg []byte, maxSize uint64) ([]byte, error) {
 // DecodeWithMaxLength the bytes from io.Reader to the protobuf message provided.
 // This checks that the decoded message isn't larger than the provided max limit.
 func (e SszNetworkEncoder) DecodeWithMaxLength(r io.Reader, to fastssz.Unmarshaler) error {
+	return e.DecodeWithLimit(r, to, MaxChunkSize)
+}
+
+// DecodeWithLimit the bytes from io.Reader to the protobuf message provided,
+// checking that the decoded message isn't larger than limit. Limits above the
+// chunk size are lowered to it.
+func (e SszNetworkEncoder) DecodeWithLimit(r io.Reader, to fastssz.Unmarshaler, limit uint64) error {
+	if limit == 0 || limit > MaxChunkSize {
+		limit = MaxChunkSize
+	}
 	msgLen, err := readVarint(r)
 	if err != nil {
 		return err
 	}
-	if msgLen > MaxChunkSize {
+	if msgLen > limit {
 		return fmt.Errorf(
 			"remaining bytes %d goes over the provided max limit of %d",
 			msgLen,
-			MaxChunkSize,
+			limit,
 		)
 	}
 	msgMax, err := e.MaxLength(msgLen)
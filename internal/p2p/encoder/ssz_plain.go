@@ -0,0 +1,115 @@
+package encoder
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	fastssz "github.com/prysmaticlabs/fastssz"
+)
+
+var _ NetworkEncoding = (*SszPlainEncoder)(nil)
+
+// ProtocolSuffixSSZ is the last part of the topic string of uncompressed SimpleSerialize.
+const ProtocolSuffixSSZ = "ssz"
+
+// Encodings lists the req/resp encodings in order of preference. Streams are
+// opened with all of them and the first one the remote peer supports is used,
+// so that snappy is only given up with peers that can't decompress it.
+var Encodings = []NetworkEncoding{SszNetworkEncoder{}, SszPlainEncoder{}}
+
+// ForProtocol returns the encoding of a req/resp protocol ID, as set by its
+// suffix. Protocols without a known suffix are taken to be snappy compressed.
+func ForProtocol(protocol string) NetworkEncoding {
+	for _, encoding := range Encodings {
+		if strings.HasSuffix(protocol, encoding.ProtocolSuffix()) {
+			return encoding
+		}
+	}
+	return SszNetworkEncoder{}
+}
+
+// SszPlainEncoder supports p2p networking encoding using SimpleSerialize
+// without compression, for peers not speaking snappy.
+type SszPlainEncoder struct{}
+
+// EncodeGossip the proto gossip message to the io.Writer.
+func (_ SszPlainEncoder) EncodeGossip(w io.Writer, msg fastssz.Marshaler) (int, error) {
+	if msg == nil {
+		return 0, nil
+	}
+	b, err := msg.MarshalSSZ()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(len(b)) > MaxGossipSize {
+		return 0, errors.Errorf("gossip message exceeds max gossip size: %d bytes > %d bytes", len(b), MaxGossipSize)
+	}
+	return w.Write(b)
+}
+
+// EncodeWithMaxLength the proto message to the io.Writer, prefixed with the
+// message size as a protobuf varint.
+func (_ SszPlainEncoder) EncodeWithMaxLength(w io.Writer, msg fastssz.Marshaler) (int, error) {
+	if msg == nil {
+		return 0, nil
+	}
+	b, err := msg.MarshalSSZ()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(len(b)) > MaxChunkSize {
+		return 0, fmt.Errorf(
+			"size of encoded message is %d which is larger than the provided max limit of %d",
+			len(b),
+			MaxChunkSize,
+		)
+	}
+	if _, err := w.Write(EncodeVarint(uint64(len(b)))); err != nil {
+		return 0, err
+	}
+	return w.Write(b)
+}
+
+// DecodeGossip decodes the bytes to the protobuf gossip message provided.
+func (_ SszPlainEncoder) DecodeGossip(b []byte, to fastssz.Unmarshaler) error {
+	if uint64(len(b)) > MaxGossipSize {
+		return errors.Errorf("gossip message exceeds max gossip size: %d bytes > %d bytes", len(b), MaxGossipSize)
+	}
+	return doDecode(b, to)
+}
+
+// DecodeWithMaxLength the bytes from io.Reader to the protobuf message provided.
+func (e SszPlainEncoder) DecodeWithMaxLength(r io.Reader, to fastssz.Unmarshaler) error {
+	return e.DecodeWithLimit(r, to, MaxChunkSize)
+}
+
+// DecodeWithLimit the bytes from io.Reader to the protobuf message provided,
+// checking that the message isn't larger than limit.
+func (_ SszPlainEncoder) DecodeWithLimit(r io.Reader, to fastssz.Unmarshaler, limit uint64) error {
+	if limit == 0 || limit > MaxChunkSize {
+		limit = MaxChunkSize
+	}
+	msgLen, err := readVarint(r)
+	if err != nil {
+		return err
+	}
+	if msgLen > limit {
+		return fmt.Errorf(
+			"remaining bytes %d goes over the provided max limit of %d",
+			msgLen,
+			limit,
+		)
+	}
+	buf := make([]byte, msgLen)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return doDecode(buf, to)
+}
+
+// ProtocolSuffix returns the appropriate suffix for protocol IDs.
+func (_ SszPlainEncoder) ProtocolSuffix() string {
+	return "/" + ProtocolSuffixSSZ
+}
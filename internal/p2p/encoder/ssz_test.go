@@ -0,0 +1,39 @@
+package encoder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+)
+
+func TestDecodeWithLimit(t *testing.T) {
+	msg := &sync_pb.Ping{SeqNumber: 42}
+	size := uint64(msg.SizeSSZ())
+
+	for _, e := range Encodings {
+		encode := func() *bytes.Buffer {
+			var buf bytes.Buffer
+			if _, err := e.EncodeWithMaxLength(&buf, msg); err != nil {
+				t.Fatal(err)
+			}
+			return &buf
+		}
+		// Limits from the message size up, and none at all, let it through.
+		for _, limit := range []uint64{size, size + 1, 0, MaxChunkSize + 1} {
+			decoded := new(sync_pb.Ping)
+			if err := e.DecodeWithLimit(encode(), decoded, limit); err != nil {
+				t.Fatalf("%s: decoding with limit %d: %v", e.ProtocolSuffix(), limit, err)
+			}
+			if decoded.SeqNumber != msg.SeqNumber {
+				t.Fatalf("%s: decoded sequence number %d, want %d", e.ProtocolSuffix(), decoded.SeqNumber, msg.SeqNumber)
+			}
+		}
+		if err := e.DecodeWithLimit(encode(), new(sync_pb.Ping), size-1); err == nil {
+			t.Errorf("%s: message over the limit decoded", e.ProtocolSuffix())
+		}
+		if err := e.DecodeWithMaxLength(encode(), new(sync_pb.Ping)); err != nil {
+			t.Errorf("%s: decoding with the chunk limit: %v", e.ProtocolSuffix(), err)
+		}
+	}
+}
@@ -65,7 +65,7 @@ var (
 	tenBlocks          = 10 * oneBlockDuration()
 )
 
-func peerScoringParams() (*pubsub.PeerScoreParams, *pubsub.PeerScoreThresholds) {
+func (s *Service) peerScoringParams() (*pubsub.PeerScoreParams, *pubsub.PeerScoreThresholds) {
 	thresholds := &pubsub.PeerScoreThresholds{
 		GossipThreshold:             -4000,
 		PublishThreshold:            -8000,
@@ -90,13 +90,34 @@ func peerScoringParams() (*pubsub.PeerScoreParams, *pubsub.PeerScoreThresholds)
 		DecayToZero:                 decayToZero,
 		RetainScore:                 oneHundredBlocks,
 	}
+	if cfg := s.cfg.GossipScore; cfg != nil {
+		setIfNonZero(&thresholds.GossipThreshold, cfg.GossipThreshold)
+		setIfNonZero(&thresholds.PublishThreshold, cfg.PublishThreshold)
+		setIfNonZero(&thresholds.GraylistThreshold, cfg.GraylistThreshold)
+		setIfNonZero(&thresholds.AcceptPXThreshold, cfg.AcceptPXThreshold)
+		setIfNonZero(&thresholds.OpportunisticGraftThreshold, cfg.OpportunisticGraftThreshold)
+		if cfg.IPColocationFactorThreshold > 0 {
+			scoreParams.IPColocationFactorThreshold = cfg.IPColocationFactorThreshold
+		}
+	}
 	return scoreParams, thresholds
 }
 
+func setIfNonZero(param *float64, value float64) {
+	if value != 0 {
+		*param = value
+	}
+}
+
 func (s *Service) topicScoreParams(topic string) (*pubsub.TopicScoreParams, error) {
 	switch {
 	case strings.Contains(topic, GossipBlockMessage):
-		return defaultBlockTopicParams(), nil
+		params := defaultBlockTopicParams()
+		if s.cfg.GossipScore != nil && s.cfg.GossipScore.InvalidBlockWeight != 0 {
+			// A penalty is a negative weight, whichever sign it was configured with.
+			params.InvalidMessageDeliveriesWeight = -math.Abs(s.cfg.GossipScore.InvalidBlockWeight)
+		}
+		return params, nil
 	case strings.Contains(topic, GossipExitMessage):
 		return defaultVoluntaryExitTopicParams(), nil
 	default:
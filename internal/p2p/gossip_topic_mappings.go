@@ -14,6 +14,12 @@ var gossipTopicMappings = map[string]proto.Message{
 	TransactionTopicFormat: &types_pb.Transaction{},
 }
 
+// rawGossipTopics are topics whose messages aren't protobuf objects and are
+// therefore decoded by their subscribers directly.
+var rawGossipTopics = []string{
+	SlashingTopicFormat,
+}
+
 // GossipTopicMappings is a function to return the assigned data type
 // versioned by epoch.
 func GossipTopicMappings(topic string) proto.Message {
@@ -14,6 +14,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"google.golang.org/protobuf/proto"
+	"time"
 )
 
 // P2P represents the full p2p interface composed of all of the sub-interfaces.
@@ -84,6 +85,12 @@ type PeerManager interface {
 	DiscoveryAddresses() ([]multiaddr.Multiaddr, error)
 	RefreshENR()
 	AddPingMethod(reqFunc func(ctx context.Context, id peer.ID) error)
+	AddStaticPeer(info peer.AddrInfo)
+	RemoveStaticPeer(pid peer.ID)
+	IsStatic(pid peer.ID) bool
+	BanPeer(pid peer.ID, duration time.Duration) error
+	UnbanPeer(pid peer.ID) error
+	PeerTraffic(pid peer.ID) *PeerTraffic
 }
 
 // Sender abstracts the sending functionality from libp2p.
@@ -127,6 +127,8 @@ func (s *Service) updateMetrics() {
 	p2pPeerCount.WithLabelValues("Connecting").Set(float64(len(s.peers.Connecting())))
 	p2pPeerCount.WithLabelValues("Disconnecting").Set(float64(len(s.peers.Disconnecting())))
 	p2pPeerCount.WithLabelValues("Bad").Set(float64(len(s.peers.Bad())))
+	p2pPeerCount.WithLabelValues("Inbound").Set(float64(len(s.peers.InboundConnected())))
+	p2pPeerCount.WithLabelValues("Outbound").Set(float64(len(s.peers.OutboundConnected())))
 
 	store := s.Host().Peerstore()
 	numConnectedPeersByClient := make(map[string]float64)
@@ -0,0 +1,83 @@
+package p2p
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/amazechain/amc/internal/p2p/nat"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// natRefresh is how often the external address is asked to the gateway.
+const natRefresh = 5 * time.Minute
+
+// setupNAT parses the port mapping mechanism. A fixed external IP is
+// advertised like a host address given with --p2p.host-ip.
+func (s *Service) setupNAT() error {
+	m, err := nat.Parse(s.cfg.NAT)
+	if err != nil {
+		return err
+	}
+	if ext, ok := m.(nat.ExtIP); ok {
+		if s.cfg.HostAddress == "" {
+			s.cfg.HostAddress = net.IP(ext).String()
+		}
+		return nil
+	}
+	s.nat = m
+	return nil
+}
+
+// natAddrsFactory adds the external address found by the port mapping to the
+// addresses advertised by libp2p.
+func natAddrsFactory(external *atomic.Pointer[ma.Multiaddr]) func([]ma.Multiaddr) []ma.Multiaddr {
+	return func(addrs []ma.Multiaddr) []ma.Multiaddr {
+		if addr := external.Load(); addr != nil {
+			addrs = append(addrs, *addr)
+		}
+		return addrs
+	}
+}
+
+// startNAT maps the listening ports on the gateway and keeps the node record
+// and the libp2p addresses on the external IP of the gateway.
+func (s *Service) startNAT() {
+	if s.nat == nil {
+		return
+	}
+	go nat.Map(s.nat, s.ctx.Done(), "tcp", s.cfg.TCPPort, s.cfg.TCPPort, "amc libp2p")
+	if s.dv5Listener != nil {
+		go nat.Map(s.nat, s.ctx.Done(), "udp", s.cfg.UDPPort, s.cfg.UDPPort, "amc discovery")
+	}
+	go s.natLoop()
+}
+
+func (s *Service) natLoop() {
+	var (
+		current net.IP
+		ticker  = time.NewTicker(natRefresh)
+	)
+	defer ticker.Stop()
+	for {
+		ip, err := s.nat.ExternalIP()
+		switch {
+		case err != nil:
+			log.Debug("Could not get external IP", "interface", s.nat, "err", err)
+		case !ip.Equal(current):
+			current = ip
+			log.Info("External IP found through port mapping", "interface", s.nat, "ip", ip)
+			if addr, err := MultiAddressBuilder(ip.String(), uint(s.cfg.TCPPort)); err == nil {
+				s.natAddr.Store(&addr)
+			}
+			if s.dv5Listener != nil {
+				s.dv5Listener.LocalNode().SetStaticIP(ip)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
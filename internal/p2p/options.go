@@ -57,8 +57,9 @@ func (s *Service) buildOptions(ip net.IP, priKey *ecdsa.PrivateKey) []libp2p.Opt
 	options := []libp2p.Option{
 		privKeyOption(priKey),
 		libp2p.ListenAddrs(listen),
-		libp2p.UserAgent(params.Version),
+		libp2p.UserAgent(params.ClientVersion()),
 		libp2p.ConnectionGater(s),
+		libp2p.BandwidthReporter(s.bandwidth),
 		libp2p.Transport(tcp.NewTCPTransport),
 		libp2p.Muxer("/mplex/6.7.0", mplex.DefaultTransport),
 		libp2p.DefaultMuxers,
@@ -86,6 +87,9 @@ func (s *Service) buildOptions(ip net.IP, priKey *ecdsa.PrivateKey) []libp2p.Opt
 			return addrs
 		}))
 	}
+	if s.nat != nil && cfg.HostAddress == "" && cfg.HostDNS == "" && cfg.RelayNodeAddr == "" {
+		options = append(options, libp2p.AddrsFactory(natAddrsFactory(&s.natAddr)))
+	}
 	if cfg.HostDNS != "" {
 		options = append(options, libp2p.AddrsFactory(func(addrs []ma.Multiaddr) []ma.Multiaddr {
 			external, err := ma.NewMultiaddr(fmt.Sprintf("/dns4/%s/tcp/%d", cfg.HostDNS, cfg.TCPPort))
@@ -52,6 +52,12 @@ type Store struct {
 	peers  map[peer.ID]*PeerData
 }
 
+// MessageCount counts the messages exchanged with a peer over a protocol.
+type MessageCount struct {
+	Received uint64 `json:"received"`
+	Sent     uint64 `json:"sent"`
+}
+
 // PeerData aggregates protocol and application level info about a single peer.
 type PeerData struct {
 	// Network related data.
@@ -69,6 +75,14 @@ type PeerData struct {
 	BadResponses         int
 	ProcessedBlocks      uint64
 	BlockProviderUpdated time.Time
+	// Download scoring data.
+	DownloadRate     float64
+	DownloadLatency  time.Duration
+	DownloadGarbage  int
+	DownloadTimeouts int
+	DownloadUpdated  time.Time
+	// Messages exchanged, by protocol or gossip topic.
+	Messages map[string]*MessageCount
 	// Gossip Scoring data.
 	TopicScores      map[string]*msg_proto.TopicScoreSnapshot
 	GossipScore      float64
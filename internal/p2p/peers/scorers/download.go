@@ -0,0 +1,276 @@
+package scorers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/amazechain/amc/internal/p2p/peers/peerdata"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var _ Scorer = (*DownloadScorer)(nil)
+
+const (
+	// DefaultDownloadGarbageThreshold defines how many invalid deliveries to tolerate before
+	// the peer is deemed bad.
+	DefaultDownloadGarbageThreshold = 4
+	// DefaultDownloadTimeoutThreshold defines how many timed out requests to tolerate before
+	// the peer is deemed bad.
+	DefaultDownloadTimeoutThreshold = 8
+	// DefaultDownloadDecayInterval defines how often the garbage counter is decremented and
+	// measurements are checked for staleness.
+	DefaultDownloadDecayInterval = 5 * time.Minute
+	// DefaultDownloadStaleInterval defines how long measurements are kept without a new
+	// request, after which the peer is measured again as if it was new.
+	DefaultDownloadStaleInterval = 15 * time.Minute
+
+	// downloadMeasurementImpact is the weight of a new measurement in the moving averages.
+	downloadMeasurementImpact = 0.3
+)
+
+// DownloadScorer measures how fast peers serve sync requests (headers, bodies
+// or state entries) and how often they deliver data failing verification.
+// Throughput and latency order the peers a request should go to, so that a
+// slow peer is used less, while a peer delivering garbage repeatedly is
+// considered bad and dropped.
+type DownloadScorer struct {
+	config *DownloadScorerConfig
+	store  *peerdata.Store
+}
+
+// DownloadScorerConfig holds configuration parameters for download scoring service.
+type DownloadScorerConfig struct {
+	// GarbageThreshold specifies number of invalid deliveries tolerated, before peer is banned.
+	GarbageThreshold int
+	// TimeoutThreshold specifies number of timed out requests tolerated, before peer is banned.
+	TimeoutThreshold int
+	// DecayInterval specifies how often garbage stats should be decayed.
+	DecayInterval time.Duration
+	// StaleInterval specifies how long throughput and latency measurements stay valid.
+	StaleInterval time.Duration
+}
+
+// newDownloadScorer creates download scoring service.
+func newDownloadScorer(store *peerdata.Store, config *DownloadScorerConfig) *DownloadScorer {
+	if config == nil {
+		config = &DownloadScorerConfig{}
+	}
+	scorer := &DownloadScorer{
+		config: config,
+		store:  store,
+	}
+	if scorer.config.GarbageThreshold == 0 {
+		scorer.config.GarbageThreshold = DefaultDownloadGarbageThreshold
+	}
+	if scorer.config.TimeoutThreshold == 0 {
+		scorer.config.TimeoutThreshold = DefaultDownloadTimeoutThreshold
+	}
+	if scorer.config.DecayInterval == 0 {
+		scorer.config.DecayInterval = DefaultDownloadDecayInterval
+	}
+	if scorer.config.StaleInterval == 0 {
+		scorer.config.StaleInterval = DefaultDownloadStaleInterval
+	}
+	return scorer
+}
+
+// Score returns the penalty for the garbage a peer delivered and the requests
+// it let time out.
+func (s *DownloadScorer) Score(pid peer.ID) float64 {
+	s.store.RLock()
+	defer s.store.RUnlock()
+	return s.score(pid)
+}
+
+// score is a lock-free version of Score.
+func (s *DownloadScorer) score(pid peer.ID) float64 {
+	if s.isBadPeer(pid) {
+		return BadPeerScore
+	}
+	peerData, ok := s.store.PeerData(pid)
+	if !ok {
+		return 0
+	}
+	return -float64(peerData.DownloadGarbage)/float64(s.config.GarbageThreshold) -
+		float64(peerData.DownloadTimeouts)/float64(s.config.TimeoutThreshold)
+}
+
+// Params exposes scorer's parameters.
+func (s *DownloadScorer) Params() *DownloadScorerConfig {
+	return s.config
+}
+
+// Delivered records a request served with the given number of items.
+// Failed and timed out requests are recorded with zero items, which drags the
+// peer's throughput down.
+func (s *DownloadScorer) Delivered(pid peer.ID, items int, elapsed time.Duration) {
+	s.store.Lock()
+	defer s.store.Unlock()
+
+	if elapsed <= 0 {
+		elapsed = time.Millisecond
+	}
+	rate := float64(items) / elapsed.Seconds()
+	peerData := s.store.PeerDataGetOrCreate(pid)
+	if peerData.DownloadUpdated.IsZero() || time.Since(peerData.DownloadUpdated) >= s.config.StaleInterval {
+		peerData.DownloadRate, peerData.DownloadLatency = rate, elapsed
+	} else {
+		peerData.DownloadRate = (1-downloadMeasurementImpact)*peerData.DownloadRate + downloadMeasurementImpact*rate
+		peerData.DownloadLatency = time.Duration((1-downloadMeasurementImpact)*float64(peerData.DownloadLatency) + downloadMeasurementImpact*float64(elapsed))
+	}
+	peerData.DownloadUpdated = time.Now()
+}
+
+// Garbage records a delivery that failed verification.
+func (s *DownloadScorer) Garbage(pid peer.ID) {
+	s.store.Lock()
+	defer s.store.Unlock()
+	s.store.PeerDataGetOrCreate(pid).DownloadGarbage++
+}
+
+// Timeout records a request the peer didn't answer in time.
+func (s *DownloadScorer) Timeout(pid peer.ID) {
+	s.store.Lock()
+	defer s.store.Unlock()
+	s.store.PeerDataGetOrCreate(pid).DownloadTimeouts++
+}
+
+// Rate returns the measured throughput of a peer in items per second and
+// whether the peer was measured at all.
+func (s *DownloadScorer) Rate(pid peer.ID) (float64, bool) {
+	s.store.RLock()
+	defer s.store.RUnlock()
+	return s.rate(pid)
+}
+
+// rate is a lock-free version of Rate.
+func (s *DownloadScorer) rate(pid peer.ID) (float64, bool) {
+	peerData, ok := s.store.PeerData(pid)
+	if !ok || peerData.DownloadUpdated.IsZero() || time.Since(peerData.DownloadUpdated) >= s.config.StaleInterval {
+		return 0, false
+	}
+	return peerData.DownloadRate, true
+}
+
+// Relative returns a peer's throughput relative to the fastest of the given
+// peers, in the [0; 1] range. Peers that weren't measured yet get 1, so that
+// they are given a chance.
+func (s *DownloadScorer) Relative(pid peer.ID, pids []peer.ID) float64 {
+	s.store.RLock()
+	defer s.store.RUnlock()
+
+	rate, ok := s.rate(pid)
+	if !ok {
+		return 1
+	}
+	best := rate
+	for _, other := range pids {
+		if r, ok := s.rate(other); ok && r > best {
+			best = r
+		}
+	}
+	if best == 0 {
+		return 1
+	}
+	return rate / best
+}
+
+// Sorted returns the peers that aren't bad, unmeasured ones first and then by
+// decreasing throughput, with latency breaking ties.
+func (s *DownloadScorer) Sorted(pids []peer.ID) []peer.ID {
+	s.store.RLock()
+	defer s.store.RUnlock()
+
+	type measure struct {
+		rate     float64
+		latency  time.Duration
+		measured bool
+	}
+	measures := make(map[peer.ID]measure, len(pids))
+	peers := make([]peer.ID, 0, len(pids))
+	for _, pid := range pids {
+		if s.isBadPeer(pid) {
+			continue
+		}
+		m := measure{}
+		if m.rate, m.measured = s.rate(pid); m.measured {
+			peerData, _ := s.store.PeerData(pid)
+			m.latency = peerData.DownloadLatency
+		}
+		measures[pid] = m
+		peers = append(peers, pid)
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		a, b := measures[peers[i]], measures[peers[j]]
+		if a.measured != b.measured {
+			return !a.measured
+		}
+		if a.rate != b.rate {
+			return a.rate > b.rate
+		}
+		return a.latency < b.latency
+	})
+	return peers
+}
+
+// IsBadPeer states if the peer is to be considered bad.
+func (s *DownloadScorer) IsBadPeer(pid peer.ID) bool {
+	s.store.RLock()
+	defer s.store.RUnlock()
+	return s.isBadPeer(pid)
+}
+
+// isBadPeer is lock-free version of IsBadPeer.
+func (s *DownloadScorer) isBadPeer(pid peer.ID) bool {
+	if peerData, ok := s.store.PeerData(pid); ok {
+		return peerData.DownloadGarbage >= s.config.GarbageThreshold ||
+			peerData.DownloadTimeouts >= s.config.TimeoutThreshold
+	}
+	return false
+}
+
+// BadPeers returns the peers that are considered bad.
+func (s *DownloadScorer) BadPeers() []peer.ID {
+	s.store.RLock()
+	defer s.store.RUnlock()
+
+	badPeers := make([]peer.ID, 0)
+	for pid := range s.store.Peers() {
+		if s.isBadPeer(pid) {
+			badPeers = append(badPeers, pid)
+		}
+	}
+	return badPeers
+}
+
+// Decay forgives one invalid delivery and one timeout of every peer.
+func (s *DownloadScorer) Decay() {
+	s.store.Lock()
+	defer s.store.Unlock()
+
+	for _, peerData := range s.store.Peers() {
+		if peerData.DownloadGarbage > 0 {
+			peerData.DownloadGarbage--
+		}
+		if peerData.DownloadTimeouts > 0 {
+			peerData.DownloadTimeouts--
+		}
+	}
+}
+
+// FormatScorePretty returns full scoring information in a human-readable format.
+func (s *DownloadScorer) FormatScorePretty(pid peer.ID) string {
+	s.store.RLock()
+	defer s.store.RUnlock()
+
+	peerData, ok := s.store.PeerData(pid)
+	if !ok {
+		return "[unknown]"
+	}
+	return fmt.Sprintf("[rate: %0.1f/s, latency: %v, garbage: %d/%d, timeouts: %d/%d]",
+		math.Round(peerData.DownloadRate*10)/10, peerData.DownloadLatency.Round(time.Millisecond),
+		peerData.DownloadGarbage, s.config.GarbageThreshold, peerData.DownloadTimeouts, s.config.TimeoutThreshold)
+}
@@ -0,0 +1,102 @@
+package scorers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amazechain/amc/internal/p2p/peers/peerdata"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestDownloadScorer(t *testing.T, config *DownloadScorerConfig) *DownloadScorer {
+	store := peerdata.NewStore(context.Background(), &peerdata.StoreConfig{MaxPeers: 30})
+	return newDownloadScorer(store, config)
+}
+
+func TestDownloadScorerSorted(t *testing.T) {
+	scorer := newTestDownloadScorer(t, nil)
+	fast, slow, near, far, fresh := peer.ID("fast"), peer.ID("slow"), peer.ID("near"), peer.ID("far"), peer.ID("fresh")
+
+	scorer.Delivered(fast, 100, time.Second)
+	scorer.Delivered(slow, 10, time.Second)
+	// Equal throughput, the lower latency goes first.
+	scorer.Delivered(near, 20, time.Second)
+	scorer.Delivered(far, 40, 2*time.Second)
+
+	want := []peer.ID{fresh, fast, near, far, slow}
+	if got := scorer.Sorted([]peer.ID{slow, far, fast, near, fresh}); !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted %v, want %v", got, want)
+	}
+	if rate, ok := scorer.Rate(fast); !ok || rate != 100 {
+		t.Errorf("rate %v, %v, want 100", rate, ok)
+	}
+	if _, ok := scorer.Rate(fresh); ok {
+		t.Error("unmeasured peer has a rate")
+	}
+	if rel := scorer.Relative(slow, want); rel != 0.1 {
+		t.Errorf("relative throughput %v, want 0.1", rel)
+	}
+	if rel := scorer.Relative(fresh, want); rel != 1 {
+		t.Errorf("relative throughput of an unmeasured peer %v, want 1", rel)
+	}
+
+	// Failed requests drag the throughput down.
+	scorer.Delivered(fast, 0, time.Second)
+	if rate, _ := scorer.Rate(fast); rate != 70 {
+		t.Errorf("rate after a failure %v, want 70", rate)
+	}
+}
+
+func TestDownloadScorerStale(t *testing.T) {
+	scorer := newTestDownloadScorer(t, &DownloadScorerConfig{StaleInterval: 50 * time.Millisecond})
+	pid := peer.ID("peer")
+
+	scorer.Delivered(pid, 100, time.Second)
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := scorer.Rate(pid); ok {
+		t.Error("stale measurement still used")
+	}
+	// A stale measurement is replaced instead of averaged.
+	scorer.Delivered(pid, 10, time.Second)
+	if rate, _ := scorer.Rate(pid); rate != 10 {
+		t.Errorf("rate %v, want 10", rate)
+	}
+}
+
+func TestDownloadScorerBadPeers(t *testing.T) {
+	scorer := newTestDownloadScorer(t, &DownloadScorerConfig{GarbageThreshold: 2, TimeoutThreshold: 3})
+	garbage, slow, good := peer.ID("garbage"), peer.ID("slow"), peer.ID("good")
+
+	scorer.Garbage(garbage)
+	if scorer.IsBadPeer(garbage) {
+		t.Error("peer bad before reaching the threshold")
+	}
+	if score := scorer.Score(garbage); score != -0.5 {
+		t.Errorf("score %v, want -0.5", score)
+	}
+	scorer.Garbage(garbage)
+	for i := 0; i < 3; i++ {
+		scorer.Timeout(slow)
+	}
+	if !scorer.IsBadPeer(garbage) || !scorer.IsBadPeer(slow) || scorer.IsBadPeer(good) {
+		t.Error("wrong bad peers")
+	}
+	if score := scorer.Score(garbage); score != BadPeerScore {
+		t.Errorf("score %v, want %v", score, BadPeerScore)
+	}
+	if got := scorer.Sorted([]peer.ID{garbage, slow, good}); !reflect.DeepEqual(got, []peer.ID{good}) {
+		t.Errorf("sorted %v, want only the good peer", got)
+	}
+	if bad := scorer.BadPeers(); len(bad) != 2 {
+		t.Errorf("%d bad peers, want 2", len(bad))
+	}
+
+	// Decaying forgives the peers over time.
+	scorer.Decay()
+	if scorer.IsBadPeer(garbage) || scorer.IsBadPeer(slow) {
+		t.Error("peers still bad after decaying")
+	}
+}
@@ -35,6 +35,7 @@ type Service struct {
 		blockProviderScorer *BlockProviderScorer
 		peerStatusScorer    *PeerStatusScorer
 		gossipScorer        *GossipScorer
+		downloadScorer      *DownloadScorer
 	}
 	weights     map[Scorer]float64
 	totalWeight float64
@@ -46,6 +47,7 @@ type Config struct {
 	BlockProviderScorerConfig *BlockProviderScorerConfig
 	PeerStatusScorerConfig    *PeerStatusScorerConfig
 	GossipScorerConfig        *GossipScorerConfig
+	DownloadScorerConfig      *DownloadScorerConfig
 }
 
 // NewService provides fully initialized peer scoring service.
@@ -64,6 +66,8 @@ func NewService(ctx context.Context, store *peerdata.Store, config *Config) *Ser
 	s.setScorerWeight(s.scorers.peerStatusScorer, 0.3)
 	s.scorers.gossipScorer = newGossipScorer(store, config.GossipScorerConfig)
 	s.setScorerWeight(s.scorers.gossipScorer, 0.4)
+	s.scorers.downloadScorer = newDownloadScorer(store, config.DownloadScorerConfig)
+	s.setScorerWeight(s.scorers.downloadScorer, 0.0)
 
 	// Start background tasks.
 	go s.loop(ctx)
@@ -91,6 +95,11 @@ func (s *Service) GossipScorer() *GossipScorer {
 	return s.scorers.gossipScorer
 }
 
+// DownloadScorer exposes the sync download scoring service.
+func (s *Service) DownloadScorer() *DownloadScorer {
+	return s.scorers.downloadScorer
+}
+
 // ActiveScorersCount returns number of scorers that can affect score (have non-zero weight).
 func (s *Service) ActiveScorersCount() int {
 	cnt := 0
@@ -119,6 +128,7 @@ func (s *Service) ScoreNoLock(pid peer.ID) float64 {
 	score += s.scorers.blockProviderScorer.score(pid) * s.scorerWeight(s.scorers.blockProviderScorer)
 	score += s.scorers.peerStatusScorer.score(pid) * s.scorerWeight(s.scorers.peerStatusScorer)
 	score += s.scorers.gossipScorer.score(pid) * s.scorerWeight(s.scorers.gossipScorer)
+	score += s.scorers.downloadScorer.score(pid) * s.scorerWeight(s.scorers.downloadScorer)
 	return math.Round(score*ScoreRoundingFactor) / ScoreRoundingFactor
 }
 
@@ -140,6 +150,9 @@ func (s *Service) IsBadPeerNoLock(pid peer.ID) bool {
 	if s.scorers.gossipScorer.isBadPeer(pid) {
 		return true
 	}
+	if s.scorers.downloadScorer.isBadPeer(pid) {
+		return true
+	}
 	return false
 }
 
@@ -176,6 +189,8 @@ func (s *Service) loop(ctx context.Context) {
 	defer decayBadResponsesStats.Stop()
 	decayBlockProviderStats := time.NewTicker(s.scorers.blockProviderScorer.Params().DecayInterval)
 	defer decayBlockProviderStats.Stop()
+	decayDownloadStats := time.NewTicker(s.scorers.downloadScorer.Params().DecayInterval)
+	defer decayDownloadStats.Stop()
 
 	for {
 		select {
@@ -191,6 +206,12 @@ func (s *Service) loop(ctx context.Context) {
 				return
 			}
 			s.scorers.blockProviderScorer.Decay()
+		case <-decayDownloadStats.C:
+			// Exit early if context is canceled.
+			if ctx.Err() != nil {
+				return
+			}
+			s.scorers.downloadScorer.Decay()
 		case <-ctx.Done():
 			return
 		}
@@ -62,7 +62,8 @@ const (
 	// Additional buffer beyond current peer limit, from which we can store the relevant peer statuses.
 	maxLimitBuffer = 0
 
-	// InboundRatio is the proportion of our connected peer limit at which we will allow inbound peers.
+	// InboundRatio is the proportion of our connected peer limit at which we will allow inbound peers,
+	// unless an inbound limit is configured.
 	InboundRatio = float64(0.8)
 
 	// MinBackOffDuration minimum amount (in milliseconds) to wait before peer is re-dialed.
@@ -79,6 +80,10 @@ type Status struct {
 	scorers   *scorers.Service
 	store     *peerdata.Store
 	ipTracker map[string]uint64
+	trusted   map[peer.ID]struct{}
+	banned    map[peer.ID]time.Time // ban expiry, zero for permanent bans
+	inbound   int                   // configured inbound peer limit, negative for the ratio
+	outbound  int                   // configured outbound peer limit, 0 for the peer limit
 	rand      *rand.Rand
 }
 
@@ -86,6 +91,10 @@ type Status struct {
 type StatusConfig struct {
 	// PeerLimit specifies maximum amount of concurrent peers that are expected to be connect to the node.
 	PeerLimit int
+	// InboundLimit caps the inbound peers, a negative limit leaves InboundRatio of the peer limit to them.
+	InboundLimit int
+	// OutboundLimit caps the outbound peers, 0 lets them take up the whole peer limit.
+	OutboundLimit int
 	// ScorerParams holds peer scorer configuration params.
 	ScorerParams *scorers.Config
 }
@@ -100,6 +109,10 @@ func NewStatus(ctx context.Context, config *StatusConfig) *Status {
 		store:     store,
 		scorers:   scorers.NewService(ctx, store, config.ScorerParams),
 		ipTracker: map[string]uint64{},
+		trusted:   map[peer.ID]struct{}{},
+		banned:    map[peer.ID]time.Time{},
+		inbound:   config.InboundLimit,
+		outbound:  config.OutboundLimit,
 		// Random generator used to calculate dial backoff period.
 		// It is ok to use deterministic generator, no need for true entropy.
 		rand: rand.NewDeterministicGenerator(),
@@ -111,6 +124,103 @@ func (p *Status) Scorers() *scorers.Service {
 	return p.scorers
 }
 
+// SetTrusted marks or unmarks the peer as trusted. Trusted peers are never
+// selected for pruning and survive the removal of their peer data.
+func (p *Status) SetTrusted(pid peer.ID, trusted bool) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	if trusted {
+		p.trusted[pid] = struct{}{}
+	} else {
+		delete(p.trusted, pid)
+	}
+}
+
+// IsTrusted returns whether the peer has been marked as trusted.
+func (p *Status) IsTrusted(pid peer.ID) bool {
+	p.store.RLock()
+	defer p.store.RUnlock()
+	_, ok := p.trusted[pid]
+	return ok
+}
+
+// Trusted returns the peers that have been marked as trusted.
+func (p *Status) Trusted() []peer.ID {
+	p.store.RLock()
+	defer p.store.RUnlock()
+	pids := make([]peer.ID, 0, len(p.trusted))
+	for pid := range p.trusted {
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// Ban bans the peer until the given time, or for good if it is zero. A banned
+// peer is considered bad whatever its score.
+func (p *Status) Ban(pid peer.ID, until time.Time) {
+	p.store.Lock()
+	defer p.store.Unlock()
+	p.banned[pid] = until
+}
+
+// Unban lifts the ban of the peer and forgives the misbehaviour it was scored
+// for, so that it isn't banned again right away.
+func (p *Status) Unban(pid peer.ID) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	delete(p.banned, pid)
+	if peerData, ok := p.store.PeerData(pid); ok {
+		peerData.BadResponses = 0
+		peerData.DownloadGarbage = 0
+		peerData.DownloadTimeouts = 0
+	}
+}
+
+// IsBanned returns whether the peer is currently banned.
+func (p *Status) IsBanned(pid peer.ID) bool {
+	p.store.RLock()
+	defer p.store.RUnlock()
+	return p.isBanned(pid)
+}
+
+// isBanned is the lock-free version of IsBanned.
+func (p *Status) isBanned(pid peer.ID) bool {
+	until, ok := p.banned[pid]
+	return ok && (until.IsZero() || time.Now().Before(until))
+}
+
+// Banned returns the banned peers along with the expiry of their bans, zero
+// for permanent ones.
+func (p *Status) Banned() map[peer.ID]time.Time {
+	p.store.RLock()
+	defer p.store.RUnlock()
+
+	banned := make(map[peer.ID]time.Time, len(p.banned))
+	for pid, until := range p.banned {
+		if p.isBanned(pid) {
+			banned[pid] = until
+		}
+	}
+	return banned
+}
+
+// ExpireBans drops the bans that are over and returns how many there were.
+func (p *Status) ExpireBans() int {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	var expired int
+	for pid := range p.banned {
+		if !p.isBanned(pid) {
+			delete(p.banned, pid)
+			expired++
+		}
+	}
+	return expired
+}
+
 // MaxPeerLimit returns the max peer limit stored in the current peer store.
 func (p *Status) MaxPeerLimit() int {
 	return p.store.Config().MaxPeers
@@ -257,8 +367,7 @@ func (p *Status) IsAboveInboundLimit() bool {
 			totalInbound += 1
 		}
 	}
-	inboundLimit := int(float64(p.ConnectedPeerLimit()) * InboundRatio)
-	return totalInbound > inboundLimit
+	return totalInbound > p.inboundLimit()
 }
 
 // InboundLimit returns the current inbound
@@ -266,9 +375,25 @@ func (p *Status) IsAboveInboundLimit() bool {
 func (p *Status) InboundLimit() int {
 	p.store.RLock()
 	defer p.store.RUnlock()
+	return p.inboundLimit()
+}
+
+// inboundLimit is the lock-free version of InboundLimit.
+func (p *Status) inboundLimit() int {
+	if p.inbound >= 0 {
+		return p.inbound
+	}
 	return int(float64(p.ConnectedPeerLimit()) * InboundRatio)
 }
 
+// OutboundLimit returns the number of outbound peers we dial up to.
+func (p *Status) OutboundLimit() int {
+	if p.outbound > 0 {
+		return p.outbound
+	}
+	return int(p.ConnectedPeerLimit())
+}
+
 // SetMetadata
 func (p *Status) SetPing(pid peer.ID, ping *sync_pb.Ping) {
 	p.store.Lock()
@@ -326,7 +451,43 @@ func (p *Status) ChainStateLastUpdated(pid peer.ID) (time.Time, error) {
 	return time.Now(), peerdata.ErrPeerUnknown
 }
 
-// IsBad states if the peer is to be considered bad (by *any* of the registered scorers).
+// CountMessages counts the messages received from and sent to the peer over
+// a protocol or gossip topic.
+func (p *Status) CountMessages(pid peer.ID, protocol string, received, sent uint64) {
+	p.store.Lock()
+	defer p.store.Unlock()
+
+	peerData := p.store.PeerDataGetOrCreate(pid)
+	if peerData.Messages == nil {
+		peerData.Messages = make(map[string]*peerdata.MessageCount)
+	}
+	count, ok := peerData.Messages[protocol]
+	if !ok {
+		count = new(peerdata.MessageCount)
+		peerData.Messages[protocol] = count
+	}
+	count.Received += received
+	count.Sent += sent
+}
+
+// Messages returns the messages exchanged with the peer, by protocol or
+// gossip topic.
+func (p *Status) Messages(pid peer.ID) map[string]peerdata.MessageCount {
+	p.store.RLock()
+	defer p.store.RUnlock()
+
+	peerData, ok := p.store.PeerData(pid)
+	if !ok {
+		return nil
+	}
+	messages := make(map[string]peerdata.MessageCount, len(peerData.Messages))
+	for protocol, count := range peerData.Messages {
+		messages[protocol] = *count
+	}
+	return messages
+}
+
+// IsBad states if the peer is banned or to be considered bad (by *any* of the registered scorers).
 // If the peer is unknown this will return `false`, which makes using this function easier than returning an error.
 func (p *Status) IsBad(pid peer.ID) bool {
 	p.store.RLock()
@@ -336,7 +497,7 @@ func (p *Status) IsBad(pid peer.ID) bool {
 
 // isBad is the lock-free version of IsBad.
 func (p *Status) isBad(pid peer.ID) bool {
-	return p.isfromBadIP(pid) || p.scorers.IsBadPeerNoLock(pid)
+	return p.isBanned(pid) || p.isfromBadIP(pid) || p.scorers.IsBadPeerNoLock(pid)
 }
 
 // NextValidTime gets the earliest possible time it is to contact/dial
@@ -650,6 +811,9 @@ func (p *Status) PeersToPrune() []peer.ID {
 	peersToPrune := make([]*peerResp, 0)
 	// Select connected and inbound peers to prune.
 	for pid, peerData := range p.store.Peers() {
+		if _, ok := p.trusted[pid]; ok {
+			continue
+		}
 		if peerData.ConnState == PeerConnected &&
 			peerData.Direction == network.DirInbound {
 			peersToPrune = append(peersToPrune, &peerResp{
@@ -0,0 +1,130 @@
+package peers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/amazechain/amc/internal/p2p/peers/scorers"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// newTestStatus returns a peer status with n connected inbound peers.
+func newTestStatus(t *testing.T, limit, n int) (*Status, []peer.ID) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	p := NewStatus(ctx, &StatusConfig{
+		PeerLimit:    limit,
+		InboundLimit: limit,
+		ScorerParams: &scorers.Config{},
+	})
+	pids := make([]peer.ID, n)
+	for i := range pids {
+		pids[i] = peer.ID(fmt.Sprintf("peer-%d", i))
+		addr, err := ma.NewMultiaddr(fmt.Sprintf("/ip4/10.0.0.%d/tcp/30303", i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Add(nil, pids[i], addr, network.DirInbound)
+		p.SetConnectionState(pids[i], PeerConnected)
+	}
+	return p, pids
+}
+
+func TestStatusTrusted(t *testing.T) {
+	p, pids := newTestStatus(t, 3, 1)
+
+	if p.IsTrusted(pids[0]) {
+		t.Fatal("peer trusted before being marked")
+	}
+	p.SetTrusted(pids[0], true)
+	p.SetTrusted(pids[0], true)
+	if !p.IsTrusted(pids[0]) {
+		t.Fatal("peer not trusted after being marked")
+	}
+	if trusted := p.Trusted(); len(trusted) != 1 || trusted[0] != pids[0] {
+		t.Fatalf("trusted peers %v, want %v", trusted, pids[:1])
+	}
+	// Peers can be trusted before they ever connect.
+	p.SetTrusted("unknown", true)
+	if !p.IsTrusted("unknown") {
+		t.Fatal("unknown peer not trusted after being marked")
+	}
+	p.SetTrusted(pids[0], false)
+	p.SetTrusted("unknown", false)
+	if p.IsTrusted(pids[0]) || len(p.Trusted()) != 0 {
+		t.Fatalf("trusted peers %v after unmarking all", p.Trusted())
+	}
+}
+
+func TestPeersToPruneSkipsTrusted(t *testing.T) {
+	p, pids := newTestStatus(t, 3, 6)
+
+	trusted := map[peer.ID]bool{pids[0]: true, pids[2]: true, pids[4]: true}
+	for pid := range trusted {
+		p.SetTrusted(pid, true)
+	}
+	prune := p.PeersToPrune()
+	if len(prune) != 3 {
+		t.Fatalf("pruning %d peers, want 3", len(prune))
+	}
+	for _, pid := range prune {
+		if trusted[pid] {
+			t.Errorf("trusted peer %s selected for pruning", pid)
+		}
+	}
+	// With only trusted peers left over the limit, nothing is pruned.
+	for _, pid := range pids {
+		p.SetTrusted(pid, true)
+	}
+	if prune := p.PeersToPrune(); len(prune) != 0 {
+		t.Errorf("pruning trusted peers %v", prune)
+	}
+}
+
+func TestStatusBans(t *testing.T) {
+	p, pids := newTestStatus(t, 3, 3)
+
+	p.Ban(pids[0], time.Time{})
+	p.Ban(pids[1], time.Now().Add(time.Hour))
+	p.Ban(pids[2], time.Now().Add(-time.Second))
+	if !p.IsBanned(pids[0]) || !p.IsBanned(pids[1]) {
+		t.Fatal("banned peers not reported as banned")
+	}
+	if p.IsBanned(pids[2]) {
+		t.Fatal("peer with an expired ban reported as banned")
+	}
+	if !p.IsBad(pids[0]) || !p.IsBad(pids[1]) {
+		t.Error("banned peers not reported as bad")
+	}
+	banned := p.Banned()
+	if len(banned) != 2 || !banned[pids[0]].IsZero() || banned[pids[1]].IsZero() {
+		t.Fatalf("banned peers %v, want a permanent ban of %s and a timed one of %s", banned, pids[0], pids[1])
+	}
+	if expired := p.ExpireBans(); expired != 1 {
+		t.Errorf("expired %d bans, want 1", expired)
+	}
+	if expired := p.ExpireBans(); expired != 0 {
+		t.Errorf("expired %d bans twice", expired)
+	}
+
+	// Lifting a ban forgives the scores the peer was banned for.
+	for i := 0; i < p.Scorers().BadResponsesScorer().Params().Threshold; i++ {
+		p.Scorers().BadResponsesScorer().Increment(pids[0])
+	}
+	for i := 0; i < p.Scorers().DownloadScorer().Params().GarbageThreshold; i++ {
+		p.Scorers().DownloadScorer().Garbage(pids[0])
+	}
+	p.Unban(pids[0])
+	if p.IsBanned(pids[0]) || p.IsBad(pids[0]) {
+		t.Fatal("unbanned peer still reported as banned or bad")
+	}
+	if count, err := p.Scorers().BadResponsesScorer().Count(pids[0]); err != nil || count != 0 {
+		t.Errorf("bad responses %d (%v) after unbanning, want 0", count, err)
+	}
+	p.Unban("unknown")
+}
@@ -42,6 +42,11 @@ func (s *Service) CanSubscribe(topic string) bool {
 			return true
 		}
 	}
+	for _, gt := range rawGossipTopics {
+		if _, err := scanfcheck(strings.Join(parts[0:4], "/"), gt); err == nil {
+			return true
+		}
+	}
 
 	return false
 }
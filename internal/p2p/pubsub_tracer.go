@@ -13,7 +13,8 @@ var _ = pubsub.RawTracer(gossipTracer{})
 // This tracer is used to implement metrics collection for messages received
 // and broadcasted through gossipsub.
 type gossipTracer struct {
-	host host.Host
+	host    host.Host
+	service *Service // counts the messages of every peer
 }
 
 // AddPeer .
@@ -49,6 +50,7 @@ func (g gossipTracer) Prune(p peer.ID, topic string) {
 // ValidateMessage .
 func (g gossipTracer) ValidateMessage(msg *pubsub.Message) {
 	pubsubMessageValidate.WithLabelValues(*msg.Topic).Inc()
+	g.service.countMessage(msg.ReceivedFrom, *msg.Topic, 1, 0)
 }
 
 // DeliverMessage .
@@ -64,6 +66,7 @@ func (g gossipTracer) RejectMessage(msg *pubsub.Message, reason string) {
 // DuplicateMessage .
 func (g gossipTracer) DuplicateMessage(msg *pubsub.Message) {
 	pubsubMessageDuplicate.WithLabelValues(*msg.Topic).Inc()
+	g.service.countMessage(msg.ReceivedFrom, *msg.Topic, 1, 0)
 }
 
 // UndeliverableMessage .
@@ -85,6 +88,9 @@ func (g gossipTracer) RecvRPC(rpc *pubsub.RPC) {
 // SendRPC .
 func (g gossipTracer) SendRPC(rpc *pubsub.RPC, p peer.ID) {
 	setMetricFromRPC(pubsubRPCSubSent, pubsubRPCSent, rpc)
+	for _, msg := range rpc.Publish {
+		g.service.countMessage(p, msg.GetTopic(), 0, 1)
+	}
 }
 
 // DropRPC .
@@ -29,6 +29,39 @@ const BodiesByRangeMessageName = "/bodies_by_range"
 // HeadersByRangeMessageName specifies the name for the Headers by range message topic.
 const HeadersByRangeMessageName = "/headers_by_range"
 
+// StateRangeMessageName specifies the name for the state range message topic.
+const StateRangeMessageName = "/state_range"
+
+// StateChangesMessageName specifies the name for the state changes message topic.
+const StateChangesMessageName = "/state_changes"
+
+// AccountRangeMessageName specifies the name for the account range message topic.
+const AccountRangeMessageName = "/account_range"
+
+// StorageRangesMessageName specifies the name for the storage ranges message topic.
+const StorageRangesMessageName = "/storage_ranges"
+
+// ByteCodesMessageName specifies the name for the byte codes message topic.
+const ByteCodesMessageName = "/byte_codes"
+
+// LightReceiptsMessageName specifies the name for the light client receipts message topic.
+const LightReceiptsMessageName = "/light_receipts"
+
+// LightAccountMessageName specifies the name for the light client account message topic.
+const LightAccountMessageName = "/light_account"
+
+// TxAnnounceMessageName specifies the name for the transaction announcement message topic.
+const TxAnnounceMessageName = "/tx_announce"
+
+// PooledTxsMessageName specifies the name for the pooled transactions message topic.
+const PooledTxsMessageName = "/pooled_txs"
+
+// TxsMessageName specifies the name for the transaction bodies message topic.
+const TxsMessageName = "/txs"
+
+// ForkIDMessageName specifies the name for the fork ID message topic.
+const ForkIDMessageName = "/fork_id"
+
 const (
 	// V1 RPC Topics
 	// RPCStatusTopicV1 defines the v1 topic for the status rpc method.
@@ -43,6 +76,32 @@ const (
 
 	// RPCHeadersDataTopicV1 defines the v1 topic for the Headers rpc method.
 	RPCHeadersDataTopicV1 = protocolPrefix + HeadersByRangeMessageName + SchemaVersionV1
+
+	// RPCStateRangeTopicV1 defines the v1 topic for the state range rpc method.
+	RPCStateRangeTopicV1 = protocolPrefix + StateRangeMessageName + SchemaVersionV1
+	// RPCStateChangesTopicV1 defines the v1 topic for the state changes rpc method.
+	RPCStateChangesTopicV1 = protocolPrefix + StateChangesMessageName + SchemaVersionV1
+	// RPCAccountRangeTopicV1 defines the v1 topic for the account range rpc method.
+	RPCAccountRangeTopicV1 = protocolPrefix + AccountRangeMessageName + SchemaVersionV1
+	// RPCStorageRangesTopicV1 defines the v1 topic for the storage ranges rpc method.
+	RPCStorageRangesTopicV1 = protocolPrefix + StorageRangesMessageName + SchemaVersionV1
+	// RPCByteCodesTopicV1 defines the v1 topic for the byte codes rpc method.
+	RPCByteCodesTopicV1 = protocolPrefix + ByteCodesMessageName + SchemaVersionV1
+
+	// RPCLightReceiptsTopicV1 defines the v1 topic for the light client receipts rpc method.
+	RPCLightReceiptsTopicV1 = protocolPrefix + LightReceiptsMessageName + SchemaVersionV1
+	// RPCLightAccountTopicV1 defines the v1 topic for the light client account rpc method.
+	RPCLightAccountTopicV1 = protocolPrefix + LightAccountMessageName + SchemaVersionV1
+
+	// RPCTxAnnounceTopicV1 defines the v1 topic for the transaction announcement rpc method.
+	RPCTxAnnounceTopicV1 = protocolPrefix + TxAnnounceMessageName + SchemaVersionV1
+	// RPCPooledTxsTopicV1 defines the v1 topic for the pooled transactions rpc method.
+	RPCPooledTxsTopicV1 = protocolPrefix + PooledTxsMessageName + SchemaVersionV1
+	// RPCTxsTopicV1 defines the v1 topic for the transaction bodies rpc method.
+	RPCTxsTopicV1 = protocolPrefix + TxsMessageName + SchemaVersionV1
+
+	// RPCForkIDTopicV1 defines the v1 topic for the fork ID rpc method.
+	RPCForkIDTopicV1 = protocolPrefix + ForkIDMessageName + SchemaVersionV1
 )
 
 // RPC errors for topic parsing.
@@ -53,8 +112,25 @@ const (
 // RPCTopicMappings map the base message type to the rpc request.
 var RPCTopicMappings = map[string]interface{}{
 	// RPC Status Message
-	RPCStatusTopicV1:     new(sync_pb.Status),
-	RPCBodiesDataTopicV1: new(sync_pb.BodiesByRangeRequest),
+	RPCStatusTopicV1:      new(sync_pb.Status),
+	RPCBodiesDataTopicV1:  new(sync_pb.BodiesByRangeRequest),
+	RPCHeadersDataTopicV1: new(sync_pb.HeadersByRangeRequest),
+
+	RPCStateRangeTopicV1:   new(sync_pb.StateRangeRequest),
+	RPCStateChangesTopicV1: new(sync_pb.StateChangesRequest),
+
+	RPCAccountRangeTopicV1:  new(sync_pb.AccountRangeRequest),
+	RPCStorageRangesTopicV1: new(sync_pb.StorageRangesRequest),
+	RPCByteCodesTopicV1:     new(sync_pb.ByteCodesRequest),
+
+	RPCLightReceiptsTopicV1: new(sync_pb.LightReceiptsRequest),
+	RPCLightAccountTopicV1:  new(sync_pb.LightAccountRequest),
+
+	RPCTxAnnounceTopicV1: new(sync_pb.TxHashes),
+	RPCPooledTxsTopicV1:  new(sync_pb.TxHashes),
+	RPCTxsTopicV1:        new(sync_pb.Txs),
+
+	RPCForkIDTopicV1: new(sync_pb.ForkID),
 
 	RPCPingTopicV1:    new(ssztype.SSZUint64),
 	RPCGoodByeTopicV1: new(ssztype.SSZUint64),
@@ -73,6 +149,17 @@ var messageMapping = map[string]bool{
 	PingMessageName:           true,
 	BodiesByRangeMessageName:  true,
 	HeadersByRangeMessageName: true,
+	StateRangeMessageName:     true,
+	StateChangesMessageName:   true,
+	AccountRangeMessageName:   true,
+	StorageRangesMessageName:  true,
+	ByteCodesMessageName:      true,
+	LightReceiptsMessageName:  true,
+	LightAccountMessageName:   true,
+	TxAnnounceMessageName:     true,
+	PooledTxsMessageName:      true,
+	TxsMessageName:            true,
+	ForkIDMessageName:         true,
 }
 
 var versionMapping = map[string]bool{
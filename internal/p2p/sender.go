@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/amazechain/amc/internal/p2p/encoder"
 	"github.com/kr/pretty"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -14,7 +15,8 @@ import (
 )
 
 // Send a message to a specific peer. The returned stream may be used for reading, but has been
-// closed for writing.
+// closed for writing. The stream is opened with every encoding, the peer picks the first one it
+// supports and the rest of the exchange must use the encoding of stream.Protocol().
 //
 // When done, the caller must Close or Reset on the stream.
 func (s *Service) Send(ctx context.Context, message interface{}, baseTopic string, pid peer.ID) (network.Stream, error) {
@@ -23,7 +25,11 @@ func (s *Service) Send(ctx context.Context, message interface{}, baseTopic strin
 	if err := VerifyTopicMapping(baseTopic, message); err != nil {
 		return nil, err
 	}
-	topic := baseTopic + s.Encoding().ProtocolSuffix()
+	protocols := make([]protocol.ID, 0, len(encoder.Encodings))
+	for _, encoding := range encoder.Encodings {
+		protocols = append(protocols, protocol.ID(baseTopic+encoding.ProtocolSuffix()))
+	}
+	topic := string(protocols[0])
 	span.AddAttributes(trace.StringAttribute("topic", topic))
 
 	log.Trace(fmt.Sprintf("Sending RPC request to peer %s", pid.String()), "topic", topic, "request", pretty.Sprint(message))
@@ -32,16 +38,17 @@ func (s *Service) Send(ctx context.Context, message interface{}, baseTopic strin
 	ctx, cancel := context.WithTimeout(ctx, maxDialTimeout)
 	defer cancel()
 
-	stream, err := s.host.NewStream(ctx, pid, protocol.ID(topic))
+	stream, err := s.host.NewStream(ctx, pid, protocols...)
 	if err != nil {
 		//tracing.AnnotateError(span, err)
 		return nil, err
 	}
+	topic = string(stream.Protocol())
 	castedMsg, ok := message.(ssz.Marshaler)
 	if !ok {
 		return nil, errors.Errorf("%T does not support the ssz marshaller interface", message)
 	}
-	if _, err := s.Encoding().EncodeWithMaxLength(stream, castedMsg); err != nil {
+	if _, err := encoder.ForProtocol(topic).EncodeWithMaxLength(stream, castedMsg); err != nil {
 		//tracing.AnnotateError(span, err)
 		_err := stream.Reset()
 		_ = _err
@@ -55,6 +62,7 @@ func (s *Service) Send(ctx context.Context, message interface{}, baseTopic strin
 		_ = _err
 		return nil, err
 	}
+	s.countMessage(pid, topic, 0, 1)
 
 	return stream, nil
 }
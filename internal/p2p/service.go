@@ -15,16 +15,20 @@ import (
 	"github.com/amazechain/amc/internal/p2p/enode"
 	"github.com/amazechain/amc/internal/p2p/enr"
 	leakybucket "github.com/amazechain/amc/internal/p2p/leaky-bucket"
+	"github.com/amazechain/amc/internal/p2p/nat"
+	"github.com/amazechain/amc/internal/p2p/netutil"
 	"github.com/amazechain/amc/internal/p2p/peers"
 	"github.com/amazechain/amc/internal/p2p/peers/scorers"
 	"github.com/amazechain/amc/utils"
 	"google.golang.org/protobuf/proto"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/metrics"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -70,12 +74,19 @@ type Service struct {
 	cfg                   *conf.P2PConfig
 	peers                 *peers.Status
 	addrFilter            *multiaddr.Filters
+	netRestrict           *netutil.Netlist
 	ipLimiter             *leakybucket.Collector
 	privKey               *ecdsa.PrivateKey
 	pubsub                *pubsub.PubSub
 	joinedTopics          map[string]*pubsub.Topic
 	joinedTopicsLock      sync.Mutex
 	dv5Listener           Listener
+	topics                *topicDiscovery
+	nat                   nat.Interface
+	natAddr               atomic.Pointer[multiaddr.Multiaddr]
+	static                staticPeers
+	banLock               sync.Mutex // serialises writes of the ban list
+	bandwidth             *metrics.BandwidthCounter
 	startupErr            error
 	ctx                   context.Context
 	host                  host.Host
@@ -118,14 +129,25 @@ func NewService(ctx context.Context, genesisHash types.Hash, cfg *conf.P2PConfig
 		log.Error("Failed to generate p2p private key", "err", err)
 		return nil, err
 	}
+	if err := s.setupNAT(); err != nil {
+		log.Error("Invalid NAT port mapping", "nat", s.cfg.NAT, "err", err)
+		return nil, err
+	}
 	s.addrFilter, err = configureFilter(s.cfg)
 	if err != nil {
 		log.Error("Failed to create address filter", "err", err)
 		return nil, err
 	}
+	if s.cfg.NetRestrict != "" {
+		if s.netRestrict, err = netutil.ParseNetlist(s.cfg.NetRestrict); err != nil {
+			log.Error("Invalid network restriction", "netrestrict", s.cfg.NetRestrict, "err", err)
+			return nil, err
+		}
+	}
 	//todo
 	s.ipLimiter = leakybucket.NewCollector(ipLimit, ipBurst, 30*time.Second, true /* deleteEmptyBuckets */)
 
+	s.setupBandwidth()
 	opts := s.buildOptions(ipAddr, s.privKey)
 	h, err := libp2p.New(opts...)
 	if err != nil {
@@ -149,8 +171,11 @@ func NewService(ctx context.Context, genesisHash types.Hash, cfg *conf.P2PConfig
 	}
 	s.pubsub = gs
 
+	inboundLimit, outboundLimit := peerLimits(s.cfg)
 	s.peers = peers.NewStatus(ctx, &peers.StatusConfig{
-		PeerLimit: s.cfg.MaxPeers,
+		PeerLimit:     s.cfg.MaxPeers,
+		InboundLimit:  inboundLimit,
+		OutboundLimit: outboundLimit,
 		ScorerParams: &scorers.Config{
 			BadResponsesScorerConfig: &scorers.BadResponsesScorerConfig{
 				Threshold:     maxBadResponses,
@@ -217,22 +242,21 @@ func (s *Service) Start() {
 			return
 		}
 		s.dv5Listener = listener
+		if s.topics, err = s.startTopicDiscovery(listener); err != nil {
+			log.Error("Could not start topic discovery", "err", err)
+		}
 		go s.listenForNewNodes()
 		utils.RunEvery(s.ctx, reconnectBootNode, func() {
 			s.ensureBootPeerConnections(bootnodes)
 		})
 
 	}
+	s.startNAT()
 
 	s.started = true
 
-	if len(s.cfg.StaticPeers) > 0 {
-		addrs, err := PeersFromStringAddrs(s.cfg.StaticPeers)
-		if err != nil {
-			log.Error("Could not connect to static peer", "err", err)
-		}
-		s.connectWithAllPeers(addrs)
-	}
+	s.startBans()
+	s.startStaticPeers()
 	// Initialize metadata according to the
 	// current epoch.
 	s.RefreshENR()
@@ -397,7 +421,7 @@ func (s *Service) Host() host.Host {
 // SetStreamHandler sets the protocol handler on the p2p host multiplexer.
 // This method is a pass through to libp2pcore.Host.SetStreamHandler.
 func (s *Service) SetStreamHandler(topic string, handler network.StreamHandler) {
-	s.host.SetStreamHandler(protocol.ID(topic), handler)
+	s.host.SetStreamHandler(protocol.ID(topic), s.countingHandler(topic, handler))
 }
 
 // PeerID returns the Peer ID of the local peer.
@@ -0,0 +1,159 @@
+package p2p
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/utils"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// staticNodesFile and trustedNodesFile list peer URLs in the data
+	// directory, as a JSON array of enode, enr or multiaddr strings.
+	staticNodesFile  = "static-nodes.json"
+	trustedNodesFile = "trusted-nodes.json"
+
+	// staticDialInterval is how often disconnected static peers are checked.
+	staticDialInterval = time.Second
+	// minStaticBackoff and maxStaticBackoff bound the wait between two
+	// failed dials of a static peer, which doubles on every failure.
+	minStaticBackoff = 5 * time.Second
+	maxStaticBackoff = 5 * time.Minute
+)
+
+// staticPeer is a peer kept connected whatever the peer limit.
+type staticPeer struct {
+	info    peer.AddrInfo
+	backoff time.Duration
+	next    time.Time
+	dialing bool
+}
+
+// staticPeers is the set of static peers of the service.
+type staticPeers struct {
+	lock  sync.Mutex
+	peers map[peer.ID]*staticPeer
+}
+
+// AddStaticPeer adds a peer that is dialed until the connection succeeds and
+// redialed whenever it drops, until it is removed.
+func (s *Service) AddStaticPeer(info peer.AddrInfo) {
+	s.static.lock.Lock()
+	defer s.static.lock.Unlock()
+
+	if s.static.peers == nil {
+		s.static.peers = make(map[peer.ID]*staticPeer)
+	}
+	if sp, ok := s.static.peers[info.ID]; ok {
+		sp.info, sp.backoff, sp.next = info, 0, time.Time{}
+		return
+	}
+	s.static.peers[info.ID] = &staticPeer{info: info}
+}
+
+// RemoveStaticPeer stops redialing a static peer. It isn't disconnected.
+func (s *Service) RemoveStaticPeer(pid peer.ID) {
+	s.static.lock.Lock()
+	defer s.static.lock.Unlock()
+	delete(s.static.peers, pid)
+}
+
+// IsStatic reports whether the peer is a static peer.
+func (s *Service) IsStatic(pid peer.ID) bool {
+	s.static.lock.Lock()
+	defer s.static.lock.Unlock()
+	_, ok := s.static.peers[pid]
+	return ok
+}
+
+// startStaticPeers loads the configured static and trusted peers and keeps
+// the static ones connected.
+func (s *Service) startStaticPeers() {
+	static := append([]string{}, s.cfg.StaticPeers...)
+	static = append(static, s.loadNodesFile(staticNodesFile)...)
+	for _, info := range parsePeerList(static) {
+		s.AddStaticPeer(info)
+	}
+	for _, info := range parsePeerList(s.loadNodesFile(trustedNodesFile)) {
+		s.peers.SetTrusted(info.ID, true)
+	}
+	s.dialStaticPeers()
+	utils.RunEvery(s.ctx, staticDialInterval, s.dialStaticPeers)
+}
+
+// dialStaticPeers dials the disconnected static peers whose backoff is over.
+func (s *Service) dialStaticPeers() {
+	s.static.lock.Lock()
+	defer s.static.lock.Unlock()
+
+	now := time.Now()
+	for _, sp := range s.static.peers {
+		if sp.dialing || now.Before(sp.next) || s.peers.IsBanned(sp.info.ID) || s.host.Network().Connectedness(sp.info.ID) == network.Connected {
+			continue
+		}
+		sp.dialing = true
+		go func(sp *staticPeer, info peer.AddrInfo) {
+			err := connectWithTimeout(s.ctx, s.host, &info)
+
+			s.static.lock.Lock()
+			defer s.static.lock.Unlock()
+			sp.dialing = false
+			if err == nil {
+				sp.backoff = 0
+				return
+			}
+			sp.backoff *= 2
+			if sp.backoff < minStaticBackoff {
+				sp.backoff = minStaticBackoff
+			}
+			if sp.backoff > maxStaticBackoff {
+				sp.backoff = maxStaticBackoff
+			}
+			sp.next = time.Now().Add(sp.backoff)
+			log.Debug("Could not dial static peer", "peer", info.ID, "retry", sp.backoff, "err", err)
+		}(sp, sp.info)
+	}
+}
+
+// loadNodesFile reads a list of peer URLs from the data directory.
+func (s *Service) loadNodesFile(name string) []string {
+	path := filepath.Join(s.cfg.DataDir, name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		log.Error("Could not read peer list", "file", path, "err", err)
+		return nil
+	}
+	var urls []string
+	if err := json.Unmarshal(data, &urls); err != nil {
+		log.Error("Invalid peer list", "file", path, "err", err)
+		return nil
+	}
+	return urls
+}
+
+// parsePeerList converts peer URLs into dial info, skipping the invalid ones.
+func parsePeerList(urls []string) []peer.AddrInfo {
+	var infos []peer.AddrInfo
+	for _, url := range urls {
+		addrs, err := PeersFromStringAddrs([]string{url})
+		if err != nil || len(addrs) == 0 {
+			log.Error("Invalid peer URL", "url", url, "err", err)
+			continue
+		}
+		info, err := peer.AddrInfoFromP2pAddr(addrs[0])
+		if err != nil {
+			log.Error("Invalid peer URL", "url", url, "err", err)
+			continue
+		}
+		infos = append(infos, *info)
+	}
+	return infos
+}
@@ -12,6 +12,8 @@ const (
 	GossipExitMessage = "voluntary_exit"
 	// GossipTransactionMessage is the name for the transaction message type.
 	GossipTransactionMessage = "transaction"
+	// GossipSlashingMessage is the name for the double-sign evidence message type.
+	GossipSlashingMessage = "slashing_evidence"
 
 	// Topic Formats
 
@@ -22,6 +24,9 @@ const (
 
 	// TransactionTopicFormat is the topic format for the block subnet.
 	TransactionTopicFormat = GossipProtocolAndDigest + GossipTransactionMessage
+	// SlashingTopicFormat is the topic format for double-sign evidence. Its
+	// messages are snappy compressed evidence as encoded by the consensus engine.
+	SlashingTopicFormat = GossipProtocolAndDigest + GossipSlashingMessage
 	//ExitTransactionTopicFormat is the topic format for the voluntary exit.
 	//ExitTransactionTopicFormat = GossipProtocolAndDigest + GossipExitMessage
 )
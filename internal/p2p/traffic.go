@@ -0,0 +1,93 @@
+package p2p
+
+import (
+	"github.com/amazechain/amc/internal/p2p/peers/peerdata"
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	p2pMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "p2p_messages_total",
+		Help: "The number of messages received and sent per protocol or gossip topic.",
+	},
+		[]string{"protocol", "direction"})
+	bandwidthDesc = prometheus.NewDesc(
+		"p2p_bandwidth_bytes_total",
+		"The number of bytes received and sent per protocol.",
+		[]string{"protocol", "direction"}, nil,
+	)
+)
+
+// PeerTraffic is the traffic exchanged with a peer. The rates are in bytes
+// per second, averaged over the last minute.
+type PeerTraffic struct {
+	Ingress     int64                            `json:"ingress"`
+	Egress      int64                            `json:"egress"`
+	IngressRate float64                          `json:"ingressRate"`
+	EgressRate  float64                          `json:"egressRate"`
+	Messages    map[string]peerdata.MessageCount `json:"messages,omitempty"`
+}
+
+// bandwidthCollector exports the bytes counted by libp2p for every protocol.
+type bandwidthCollector struct {
+	counter *metrics.BandwidthCounter
+}
+
+// Describe implements prometheus.Collector.
+func (c *bandwidthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bandwidthDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *bandwidthCollector) Collect(ch chan<- prometheus.Metric) {
+	for protocol, stats := range c.counter.GetBandwidthByProtocol() {
+		ch <- prometheus.MustNewConstMetric(bandwidthDesc, prometheus.CounterValue, float64(stats.TotalIn), string(protocol), "in")
+		ch <- prometheus.MustNewConstMetric(bandwidthDesc, prometheus.CounterValue, float64(stats.TotalOut), string(protocol), "out")
+	}
+}
+
+// setupBandwidth creates the counter the host reports its traffic to.
+func (s *Service) setupBandwidth() {
+	s.bandwidth = metrics.NewBandwidthCounter()
+	if err := prometheus.Register(&bandwidthCollector{counter: s.bandwidth}); err != nil {
+		// Another service of the process exports its counter already.
+		log.Debug("Could not register bandwidth metrics", "err", err)
+	}
+}
+
+// countMessage counts messages exchanged with a peer over a protocol or topic.
+func (s *Service) countMessage(pid peer.ID, protocol string, received, sent uint64) {
+	if received > 0 {
+		p2pMessages.WithLabelValues(protocol, "in").Add(float64(received))
+	}
+	if sent > 0 {
+		p2pMessages.WithLabelValues(protocol, "out").Add(float64(sent))
+	}
+	if s.peers != nil {
+		s.peers.CountMessages(pid, protocol, received, sent)
+	}
+}
+
+// countingHandler counts the requests received by a stream handler.
+func (s *Service) countingHandler(topic string, handler network.StreamHandler) network.StreamHandler {
+	return func(stream network.Stream) {
+		s.countMessage(stream.Conn().RemotePeer(), topic, 1, 0)
+		handler(stream)
+	}
+}
+
+// PeerTraffic returns the bytes and messages exchanged with a peer.
+func (s *Service) PeerTraffic(pid peer.ID) *PeerTraffic {
+	stats := s.bandwidth.GetBandwidthForPeer(pid)
+	return &PeerTraffic{
+		Ingress:     stats.TotalIn,
+		Egress:      stats.TotalOut,
+		IngressRate: stats.RateIn,
+		EgressRate:  stats.RateOut,
+		Messages:    s.peers.Messages(pid),
+	}
+}
@@ -93,7 +93,10 @@ func privKey(cfg *conf.P2PConfig) (*ecdsa.PrivateKey, error) {
 	defaultKeyPath := path.Join(cfg.DataDir, keyPath)
 	privateKeyPath := cfg.PrivateKey
 
-	// PrivateKey cli flag takes highest precedence.
+	// A key given in hex takes highest precedence, then the PrivateKey cli flag.
+	if cfg.PrivateKeyHex != "" {
+		return privKeyFromHex([]byte(cfg.PrivateKeyHex))
+	}
 	if privateKeyPath != "" {
 		return privKeyFromFile(cfg.PrivateKey)
 	}
@@ -115,16 +118,10 @@ func privKey(cfg *conf.P2PConfig) (*ecdsa.PrivateKey, error) {
 	// If the StaticPeerID flag is set, save the generated key as the default
 	// key, so that it will be used by default on the next node start.
 	if cfg.StaticPeerID {
-		rawbytes, err := priv.Raw()
-		if err != nil {
+		if err := writeKeyFile(defaultKeyPath, priv); err != nil {
 			return nil, err
 		}
-		dst := make([]byte, hex.EncodedLen(len(rawbytes)))
-		hex.Encode(dst, rawbytes)
-		if err := os.WriteFile(defaultKeyPath, dst, 0600); err != nil {
-			return nil, err
-		}
-		log.Info("Wrote network key to file")
+		log.Info("Wrote network key to file", "path", defaultKeyPath)
 		// Read the key from the defaultKeyPath file just written
 		// for the strongest guarantee that the next start will be the same as this one.
 		return privKeyFromFile(defaultKeyPath)
@@ -139,8 +136,16 @@ func privKeyFromFile(path string) (*ecdsa.PrivateKey, error) {
 		log.Error("Error reading private key from file", "err", err)
 		return nil, err
 	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+		log.Warn("Network key file is accessible by other users", "path", path, "mode", info.Mode().Perm())
+	}
+	return privKeyFromHex(bytes.TrimSpace(src))
+}
+
+// Decodes a hex encoded secp256k1 private key.
+func privKeyFromHex(src []byte) (*ecdsa.PrivateKey, error) {
 	dst := make([]byte, hex.DecodedLen(len(src)))
-	_, err = hex.Decode(dst, src)
+	_, err := hex.Decode(dst, src)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to decode hex string")
 	}
@@ -151,6 +156,60 @@ func privKeyFromFile(path string) (*ecdsa.PrivateKey, error) {
 	return utils.ConvertFromInterfacePrivKey(unmarshalledKey)
 }
 
+// Writes a private key hex encoded to a file only readable by the owner. The
+// key is written next to the file first, so a crash never leaves half a key.
+func writeKeyFile(file string, priv crypto.PrivKey) error {
+	rawbytes, err := priv.Raw()
+	if err != nil {
+		return err
+	}
+	dst := make([]byte, hex.EncodedLen(len(rawbytes)))
+	hex.Encode(dst, rawbytes)
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, dst, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// NodeKey returns the network key stored in the data directory.
+func NodeKey(dataDir string) (*ecdsa.PrivateKey, error) {
+	return privKeyFromFile(NodeKeyFile(dataDir))
+}
+
+// NodeKeyFile returns the path of the network key in the data directory.
+func NodeKeyFile(dataDir string) string {
+	return path.Join(dataDir, keyPath)
+}
+
+// RotateNodeKey replaces the network key stored in the data directory with a
+// new one, which changes the peer id and the node record of the node. The
+// previous key is kept beside it, suffixed with the time of the rotation.
+// The node must be stopped, it only reads the key on startup.
+func RotateNodeKey(dataDir string) (*ecdsa.PrivateKey, error) {
+	file := path.Join(dataDir, keyPath)
+	priv, _, err := crypto.GenerateSecp256k1Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(file); err == nil {
+		backup := fmt.Sprintf("%s.%d", file, time.Now().Unix())
+		if err := os.Rename(file, backup); err != nil {
+			return nil, err
+		}
+		log.Info("Kept the previous network key", "path", backup)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := writeKeyFile(file, priv); err != nil {
+		return nil, err
+	}
+	return utils.ConvertFromInterfacePrivKey(priv)
+}
+
 // Attempt to dial an address to verify its connectivity
 func verifyConnectivity(addr string, port int, protocol string) {
 	if addr != "" {
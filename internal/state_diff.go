@@ -0,0 +1,289 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"fmt"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/account"
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	vm2 "github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// BalanceChange is how the balance of an account changed.
+type BalanceChange struct {
+	From *hexutil.Big `json:"from"`
+	To   *hexutil.Big `json:"to"`
+}
+
+// NonceChange is how the nonce of an account changed.
+type NonceChange struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// CodeChange is how the code of an account changed.
+type CodeChange struct {
+	From hexutil.Bytes `json:"from"`
+	To   hexutil.Bytes `json:"to"`
+}
+
+// StorageChange is how a storage slot changed.
+type StorageChange struct {
+	From types.Hash `json:"from"`
+	To   types.Hash `json:"to"`
+}
+
+// AccountDiff lists what changed in an account, the fields left unchanged are
+// omitted. Deleted is set for the self destructed accounts, whose cleared
+// storage isn't listed.
+type AccountDiff struct {
+	Balance *BalanceChange                `json:"balance,omitempty"`
+	Nonce   *NonceChange                  `json:"nonce,omitempty"`
+	Code    *CodeChange                   `json:"code,omitempty"`
+	Storage map[types.Hash]*StorageChange `json:"storage,omitempty"`
+	Deleted bool                          `json:"deleted,omitempty"`
+}
+
+// StateDiff is the changes made to the state, by account.
+type StateDiff map[types.Address]*AccountDiff
+
+func (d StateDiff) account(addr types.Address) *AccountDiff {
+	a, ok := d[addr]
+	if !ok {
+		a = new(AccountDiff)
+		d[addr] = a
+	}
+	return a
+}
+
+// TxStateDiff is the state diff of a transaction.
+type TxStateDiff struct {
+	TxHash    types.Hash `json:"txHash"`
+	StateDiff StateDiff  `json:"stateDiff"`
+}
+
+// BlockStateDiff is the state diff of a block, transaction by transaction,
+// followed by the changes the consensus engine made finalizing the block,
+// such as the block rewards.
+type BlockStateDiff struct {
+	Transactions []*TxStateDiff `json:"transactions"`
+	Finalize     StateDiff      `json:"finalize"`
+}
+
+type storageSlot struct {
+	addr types.Address
+	key  types.Hash
+}
+
+// diffWriter turns the writes of the intra block state into state diffs. The
+// state hands over the values from the start of the block as the originals
+// and writes again what earlier transactions changed, so the writer keeps
+// the latest values itself and records what differs from them.
+type diffWriter struct {
+	reader   state.StateReader
+	accounts map[types.Address]*account.StateAccount // nil once deleted
+	storage  map[storageSlot]uint256.Int
+	codes    map[types.Hash][]byte
+	diff     StateDiff
+}
+
+func newDiffWriter(reader state.StateReader) *diffWriter {
+	return &diffWriter{
+		reader:   reader,
+		accounts: make(map[types.Address]*account.StateAccount),
+		storage:  make(map[storageSlot]uint256.Int),
+		codes:    make(map[types.Hash][]byte),
+	}
+}
+
+// latest returns the account as last written, the original if it wasn't.
+func (w *diffWriter) latest(addr types.Address, original *account.StateAccount) *account.StateAccount {
+	if acc, ok := w.accounts[addr]; ok {
+		return acc
+	}
+	return original
+}
+
+func (w *diffWriter) code(addr types.Address, acc *account.StateAccount) ([]byte, error) {
+	if acc == nil || acc.IsEmptyCodeHash() {
+		return nil, nil
+	}
+	if code, ok := w.codes[acc.CodeHash]; ok {
+		return code, nil
+	}
+	return w.reader.ReadAccountCode(addr, acc.Incarnation, acc.CodeHash)
+}
+
+func (w *diffWriter) change(addr types.Address, prev, next *account.StateAccount) error {
+	var (
+		empty        account.StateAccount
+		before, post = prev, next
+	)
+	if before == nil {
+		before = &empty
+	}
+	if post == nil {
+		post = &empty
+	}
+	if !before.Balance.Eq(&post.Balance) {
+		w.diff.account(addr).Balance = &BalanceChange{From: (*hexutil.Big)(before.Balance.ToBig()), To: (*hexutil.Big)(post.Balance.ToBig())}
+	}
+	if before.Nonce != post.Nonce {
+		w.diff.account(addr).Nonce = &NonceChange{From: hexutil.Uint64(before.Nonce), To: hexutil.Uint64(post.Nonce)}
+	}
+	if before.IsEmptyCodeHash() != post.IsEmptyCodeHash() || (!before.IsEmptyCodeHash() && before.CodeHash != post.CodeHash) {
+		from, err := w.code(addr, prev)
+		if err != nil {
+			return err
+		}
+		to, err := w.code(addr, next)
+		if err != nil {
+			return err
+		}
+		w.diff.account(addr).Code = &CodeChange{From: from, To: to}
+	}
+	return nil
+}
+
+func (w *diffWriter) UpdateAccountData(address types.Address, original, acc *account.StateAccount) error {
+	if err := w.change(address, w.latest(address, original), acc); err != nil {
+		return err
+	}
+	w.accounts[address] = acc.SelfCopy()
+	return nil
+}
+
+func (w *diffWriter) UpdateAccountCode(address types.Address, incarnation uint16, codeHash types.Hash, code []byte) error {
+	w.codes[codeHash] = code
+	return nil
+}
+
+func (w *diffWriter) DeleteAccount(address types.Address, original *account.StateAccount) error {
+	prev := w.latest(address, original)
+	w.accounts[address] = nil
+	// Removing an empty account changes nothing.
+	if prev == nil || (prev.Nonce == 0 && prev.Balance.IsZero() && prev.IsEmptyCodeHash()) {
+		return nil
+	}
+	if err := w.change(address, prev, nil); err != nil {
+		return err
+	}
+	w.diff.account(address).Deleted = true
+	return nil
+}
+
+func (w *diffWriter) WriteAccountStorage(address types.Address, incarnation uint16, key *types.Hash, original, value *uint256.Int) error {
+	slot := storageSlot{addr: address, key: *key}
+	prev, ok := w.storage[slot]
+	if !ok {
+		prev = *original
+	}
+	if !prev.Eq(value) {
+		a := w.diff.account(address)
+		if a.Storage == nil {
+			a.Storage = make(map[types.Hash]*StorageChange)
+		}
+		a.Storage[*key] = &StorageChange{From: prev.Bytes32(), To: value.Bytes32()}
+	}
+	w.storage[slot] = *value
+	return nil
+}
+
+func (w *diffWriter) CreateContract(address types.Address) error {
+	return nil
+}
+
+// BlockStateDiff executes a block again on the state of its parent, as stored
+// in the state history, and returns the state diff of every transaction and
+// of the finalization of the block.
+func (bc *BlockChain) BlockStateDiff(tx kv.Tx, b *block2.Block) (*BlockStateDiff, error) {
+	return bc.stateDiff(tx, b, len(b.Transactions()))
+}
+
+// TransactionStateDiff returns the state diff of the transaction at index in
+// the block, executing the transactions before it first.
+func (bc *BlockChain) TransactionStateDiff(tx kv.Tx, b *block2.Block, index int) (StateDiff, error) {
+	if index < 0 || index >= len(b.Transactions()) {
+		return nil, fmt.Errorf("transaction index %d out of range", index)
+	}
+	diff, err := bc.stateDiff(tx, b, index)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Transactions[index].StateDiff, nil
+}
+
+// stateDiff executes the transactions of the block up to last included, and
+// the finalization of the block if last is past the transactions.
+func (bc *BlockChain) stateDiff(tx kv.Tx, b *block2.Block, last int) (*BlockStateDiff, error) {
+	number := b.Number64().Uint64()
+	if number == 0 {
+		return nil, fmt.Errorf("the genesis block isn't executed")
+	}
+	pruned, err := rawdb.ReadStatePruneProgress(tx)
+	if err != nil {
+		return nil, err
+	}
+	if number < pruned {
+		return nil, fmt.Errorf("the state history of block %d is pruned", number)
+	}
+	var (
+		header    = b.Header().(*block2.Header)
+		reader    = state.NewPlainState(tx, number)
+		ibs       = state.New(reader)
+		writer    = newDiffWriter(reader)
+		rules     = bc.chainConfig.Rules(number, b.Time())
+		gp        = new(common.GasPool).AddGas(b.GasLimit())
+		usedGas   = new(uint64)
+		getHeader = func(hash types.Hash, number uint64) *block2.Header {
+			return rawdb.ReadHeader(tx, hash, number)
+		}
+		blockHashFunc = GetHashFn(header, getHeader)
+		result        = new(BlockStateDiff)
+	)
+	for i, t := range b.Transactions() {
+		if i > last {
+			break
+		}
+		writer.diff = make(StateDiff)
+		ibs.Prepare(t.Hash(), b.Hash(), i)
+		if _, _, err := ApplyTransaction(bc.chainConfig, blockHashFunc, bc.engine, nil, gp, ibs, writer, header, t, usedGas, vm2.Config{}); err != nil {
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, t.Hash(), err)
+		}
+		result.Transactions = append(result.Transactions, &TxStateDiff{TxHash: t.Hash(), StateDiff: writer.diff})
+	}
+	if last < len(b.Transactions()) {
+		return result, nil
+	}
+	writer.diff = make(StateDiff)
+	if _, _, err := bc.engine.Finalize(bc, header, ibs, b.Transactions(), nil); err != nil {
+		return nil, err
+	}
+	if err := ibs.FinalizeTx(rules, writer); err != nil {
+		return nil, err
+	}
+	result.Finalize = writer.diff
+	return result, ibs.Error()
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/amazechain/amc/params"
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
+	"sync/atomic"
 )
 
 // StateProcessor is a basic Processor, which takes care of transitioning
@@ -42,6 +43,8 @@ type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
 	bc     *BlockChain         // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
+
+	serialBlocks atomic.Int32 // blocks left to execute serially after too many conflicts
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -61,6 +64,14 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
 func (p *StateProcessor) Process(b *block.Block, ibs *state.IntraBlockState, stateReader state.StateReader, stateWriter state.WriterWithChangeSets, blockHashFunc func(n uint64) types.Hash) (block.Receipts, map[types.Address]*uint256.Int, []*block.Log, uint64, error) {
+	return p.process(b, ibs, blockHashFunc, false)
+}
+
+// process executes a block imported into the chain or, if replay is set,
+// one replayed on a state that isn't in the database. Replays are neither
+// profiled nor executed in parallel, the speculative executions read the
+// state of the parent from the database.
+func (p *StateProcessor) process(b *block.Block, ibs *state.IntraBlockState, blockHashFunc func(n uint64) types.Hash, replay bool) (block.Receipts, map[types.Address]*uint256.Int, []*block.Log, uint64, error) {
 	header := b.Header()
 	usedGas := new(uint64)
 	gp := new(common.GasPool)
@@ -73,27 +84,38 @@ func (p *StateProcessor) Process(b *block.Block, ibs *state.IntraBlockState, sta
 	)
 
 	chainReader := p.bc
-	cfg := vm2.Config{}
+	cfg := vm2.Config{EnablePreimageRecording: p.bc.recordPreimages, Profiler: p.bc.profiler}
+	if replay {
+		cfg.Profiler = nil
+	}
 
 	chainConfig := p.config
-	if chainConfig.DAOForkSupport && chainConfig.DAOForkBlock != nil && chainConfig.DAOForkBlock.Cmp(b.Number64().ToBig()) == 0 {
+	dao := chainConfig.DAOForkSupport && chainConfig.DAOForkBlock != nil && chainConfig.DAOForkBlock.Cmp(b.Number64().ToBig()) == 0
+	if dao {
 		misc.ApplyDAOHardFork(ibs)
 	}
 	noop := state.NewNoopWriter()
 
-	//posa, isPoSA := p.engine.(*apoa.Apoa)
-	for i, tx := range b.Transactions() {
-		ibs.Prepare(tx.Hash(), b.Hash(), i)
-		receipt, _, err := ApplyTransaction(chainConfig, blockHashFunc, p.engine, nil, gp, ibs, noop, header.(*block.Header), tx, usedGas, cfg)
-		if err != nil {
-			if !cfg.StatelessExec {
-				return nil, nil, nil, 0, fmt.Errorf("could not apply tx %d from block %d [%v]: %w", i, b.Number64(), tx.Hash().String(), err)
-			}
-			rejectedTxs = append(rejectedTxs, &RejectedTx{i, err.Error()})
-		} else {
-			includedTxs = append(includedTxs, tx)
-			if !cfg.NoReceipts {
-				receipts = append(receipts, receipt)
+	if !replay && p.parallel(b, ibs, dao) {
+		var err error
+		if receipts, err = p.applyParallel(b, ibs, gp, usedGas, blockHashFunc, cfg); err != nil {
+			return nil, nil, nil, 0, err
+		}
+	} else {
+		//posa, isPoSA := p.engine.(*apoa.Apoa)
+		for i, tx := range b.Transactions() {
+			ibs.Prepare(tx.Hash(), b.Hash(), i)
+			receipt, _, err := ApplyTransaction(chainConfig, blockHashFunc, p.engine, nil, gp, ibs, noop, header.(*block.Header), tx, usedGas, cfg)
+			if err != nil {
+				if !cfg.StatelessExec {
+					return nil, nil, nil, 0, fmt.Errorf("could not apply tx %d from block %d [%v]: %w", i, b.Number64(), tx.Hash().String(), err)
+				}
+				rejectedTxs = append(rejectedTxs, &RejectedTx{i, err.Error()})
+			} else {
+				includedTxs = append(includedTxs, tx)
+				if !cfg.NoReceipts {
+					receipts = append(receipts, receipt)
+				}
 			}
 		}
 	}
@@ -115,6 +137,9 @@ func (p *StateProcessor) Process(b *block.Block, ibs *state.IntraBlockState, sta
 		}
 	}
 	allLogs := ibs.Logs()
+	if cfg.Profiler != nil {
+		cfg.Profiler.CountBlock()
+	}
 
 	//if err := ibs.CommitBlock(chainConfig.Rules(header.Number64().Uint64()), stateWriter); err != nil {
 	//	return nil, nil, 0, fmt.Errorf("committing block %d failed: %w", header.Number64().Uint64(), err)
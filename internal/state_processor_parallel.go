@@ -0,0 +1,239 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/metrics/prometheus"
+	vm2 "github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/state"
+)
+
+const (
+	// minParallelTxs is the number of transactions below which a block is
+	// executed serially, the speculation wouldn't pay for itself.
+	minParallelTxs = 8
+	// maxExecWorkers caps the goroutines executing transactions ahead.
+	maxExecWorkers = 16
+	// serialBlocksAfterConflicts is the number of blocks executed serially
+	// after one whose transactions mostly had to be executed again.
+	serialBlocksAfterConflicts = 32
+)
+
+var (
+	parallelTxsCounter    = prometheus.GetOrCreateCounter("chain_execution_parallel_txs")
+	reexecutedTxsCounter  = prometheus.GetOrCreateCounter("chain_execution_reexecuted_txs")
+	errSpeculationStopped = errors.New("speculative execution stopped")
+)
+
+// speculativeResult is the outcome of a transaction executed ahead of its
+// position in the block, on the state before the block.
+type speculativeResult struct {
+	receipt *block.Receipt
+	gasUsed uint64
+	reads   *state.AccessSet
+	writes  *state.WriteSet
+	profile *vm2.Profiler // what the execution spent, kept if it is
+	err     error
+	done    chan struct{}
+}
+
+// SetParallelExecution makes the blocks imported from now on execute their
+// transactions in parallel. It must be set before the chain starts.
+func (bc *BlockChain) SetParallelExecution(enabled bool) {
+	bc.parallelExec = enabled
+}
+
+// parallel reports whether the transactions of a block are executed in
+// parallel on the block state.
+func (p *StateProcessor) parallel(b *block.Block, ibs *state.IntraBlockState, dao bool) bool {
+	if !p.bc.parallelExec || dao || len(b.Transactions()) < minParallelTxs || !ibs.Speculatable() {
+		return false
+	}
+	for {
+		n := p.serialBlocks.Load()
+		if n == 0 {
+			return true
+		}
+		if p.serialBlocks.CompareAndSwap(n, n-1) {
+			return false
+		}
+	}
+}
+
+// applyParallel executes the transactions of a block optimistically in
+// parallel, Block-STM style, and commits them in order.
+//
+// Workers execute every transaction on its own state over the state before the
+// block, recording the accounts and slots it reads and the changes it makes.
+// The transactions are then committed one after the other on the block state:
+// a transaction that read nothing written by the ones committed before it
+// would have run exactly the same at its position, so its changes are applied
+// as they are; any other one is executed again on the block state, as it would
+// be serially. The outcome is therefore always that of the serial execution.
+//
+// Once more than half of the transactions had to be executed again, the
+// speculation stops for the rest of the block and for the next blocks.
+func (p *StateProcessor) applyParallel(b *block.Block, ibs *state.IntraBlockState, gp *common.GasPool, usedGas *uint64, blockHashFunc func(n uint64) types.Hash, cfg vm2.Config) (block.Receipts, error) {
+	txs := b.Transactions()
+	header := b.Header().(*block.Header)
+	rules := p.config.Rules(header.Number.Uint64(), header.Time)
+
+	results := make([]*speculativeResult, len(txs))
+	tasks := make(chan int, len(txs))
+	for i := range txs {
+		results[i] = &speculativeResult{done: make(chan struct{})}
+		tasks <- i
+	}
+	close(tasks)
+
+	workers := runtime.NumCPU()
+	if workers > maxExecWorkers {
+		workers = maxExecWorkers
+	}
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	var (
+		stop atomic.Bool
+		wg   sync.WaitGroup
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			p.speculate(b, tasks, results, &stop, cfg)
+		}()
+	}
+	defer func() {
+		stop.Store(true)
+		wg.Wait()
+	}()
+
+	written := state.NewAccessSet()
+	ibs.TrackWrites(written)
+	defer ibs.TrackWrites(nil)
+
+	noop := state.NewNoopWriter()
+	receipts := make(block.Receipts, 0, len(txs))
+	var reexecuted int
+	for i, tx := range txs {
+		res := results[i]
+		<-res.done
+
+		ibs.Prepare(tx.Hash(), b.Hash(), i)
+		if res.err != nil || res.reads.Intersects(written) || gp.Gas() < tx.Gas() {
+			receipt, _, err := ApplyTransaction(p.config, blockHashFunc, p.engine, nil, gp, ibs, noop, header, tx, usedGas, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("could not apply tx %d from block %d [%v]: %w", i, b.Number64(), tx.Hash().String(), err)
+			}
+			receipts = append(receipts, receipt)
+			if reexecuted++; reexecuted > len(txs)/2 && !stop.Load() {
+				log.Debug("Too many conflicting transactions, executing serially", "block", b.Number64().Uint64(), "txs", len(txs), "conflicts", reexecuted)
+				stop.Store(true)
+				p.serialBlocks.Store(serialBlocksAfterConflicts)
+			}
+			continue
+		}
+		if err := gp.SubGas(res.gasUsed); err != nil {
+			return nil, fmt.Errorf("could not apply tx %d from block %d [%v]: %w", i, b.Number64(), tx.Hash().String(), err)
+		}
+		ibs.ApplyWriteSet(res.writes)
+		if res.profile != nil {
+			cfg.Profiler.Merge(res.profile)
+		}
+		if err := ibs.FinalizeTx(rules, noop); err != nil {
+			return nil, err
+		}
+		*usedGas += res.gasUsed
+
+		receipt := res.receipt
+		receipt.CumulativeGasUsed = *usedGas
+		receipt.Logs = ibs.GetLogs(tx.Hash())
+		receipts = append(receipts, receipt)
+	}
+	parallelTxsCounter.Add(len(txs) - reexecuted)
+	reexecutedTxsCounter.Add(reexecuted)
+	return receipts, nil
+}
+
+// speculate executes the transactions handed out by tasks on the state before
+// the block, each on its own state, until the speculation is stopped.
+func (p *StateProcessor) speculate(b *block.Block, tasks <-chan int, results []*speculativeResult, stop *atomic.Bool, cfg vm2.Config) {
+	tx, err := p.bc.ChainDB.BeginRo(p.bc.ctx)
+	if err != nil {
+		for i := range tasks {
+			results[i].err = err
+			close(results[i].done)
+		}
+		return
+	}
+	defer tx.Rollback()
+
+	header := b.Header().(*block.Header)
+	reader := state.NewPlainStateReader(p.bc.snaps.Reader(tx, b.ParentHash()))
+	// The block hashes are read through this worker's own transaction.
+	blockHashFunc := GetHashFn(header, func(hash types.Hash, number uint64) *block.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	})
+	txs := b.Transactions()
+	for i := range tasks {
+		if stop.Load() {
+			results[i].err = errSpeculationStopped
+		} else {
+			results[i].err = p.speculateTx(b, header, reader, blockHashFunc, i, txs[i], results[i], cfg)
+		}
+		close(results[i].done)
+	}
+}
+
+// speculateTx executes a single transaction ahead of its position.
+func (p *StateProcessor) speculateTx(b *block.Block, header *block.Header, reader state.StateReader, blockHashFunc func(n uint64) types.Hash, i int, tx *transaction.Transaction, res *speculativeResult, cfg vm2.Config) (err error) {
+	// The state the transaction runs on may not be one it would ever see.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("speculative execution panicked: %v", r)
+		}
+	}()
+	if cfg.Profiler != nil {
+		// Only the executions whose outcome is kept are profiled.
+		cfg.Profiler = vm2.NewProfiler()
+		res.profile = cfg.Profiler
+	}
+	ibs := state.NewSpeculative(reader)
+	ibs.Prepare(tx.Hash(), b.Hash(), i)
+	gp := new(common.GasPool).AddGas(header.GasLimit)
+	var usedGas uint64
+	receipt, _, err := ApplyTransaction(p.config, blockHashFunc, p.engine, nil, gp, ibs, state.NewNoopWriter(), header, tx, &usedGas, cfg)
+	if err != nil {
+		return err
+	}
+	res.receipt, res.gasUsed = receipt, usedGas
+	res.reads, res.writes = ibs.ReadSet(), ibs.WriteSet()
+	return nil
+}
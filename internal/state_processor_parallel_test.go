@@ -0,0 +1,249 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal/consensus/apoa"
+	"github.com/amazechain/amc/internal/consensus/misc"
+	vm2 "github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var (
+	parallelSenders = []types.Address{
+		types.HexToAddress("0xa1"), types.HexToAddress("0xa2"),
+		types.HexToAddress("0xa3"), types.HexToAddress("0xa4"),
+	}
+	// counter increments its slot 0.
+	parallelCounter = types.HexToAddress("0xc1")
+	// coinbaseReader stores the balance of the coinbase in its slot 0.
+	parallelCoinbaseReader = types.HexToAddress("0xc2")
+	// logger emits a log with the topic 0x2a.
+	parallelLogger = types.HexToAddress("0xc3")
+	// reverter reverts every call.
+	parallelReverter = types.HexToAddress("0xc4")
+	// destructor selfdestructs to its caller.
+	parallelDestructor = types.HexToAddress("0xc5")
+	// parallelInitCode deploys a contract made of a single STOP.
+	parallelInitCode = []byte{0x60, 0x01, 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, 0x01, 0x60, 0x00, 0xf3, 0x00}
+)
+
+// newParallelTestChain returns a chain whose genesis funds the senders and
+// holds the test contracts, and the sealed header of a block on top of it.
+func newParallelTestChain(t *testing.T) (*BlockChain, *block.Header, types.Address) {
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("parallel execution test")))
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	genesis := DeveloperGenesisBlock(0, signer)
+	for _, sender := range parallelSenders {
+		genesis.Alloc[sender] = conf.GenesisAccount{Balance: "1000000000000000000000"}
+	}
+	genesis.Alloc[parallelCounter] = conf.GenesisAccount{Balance: "0", Code: []byte{0x60, 0x00, 0x54, 0x60, 0x01, 0x01, 0x60, 0x00, 0x55, 0x00}}
+	genesis.Alloc[parallelCoinbaseReader] = conf.GenesisAccount{Balance: "0", Code: []byte{0x41, 0x31, 0x60, 0x00, 0x55, 0x00}}
+	genesis.Alloc[parallelLogger] = conf.GenesisAccount{Balance: "0", Code: []byte{0x60, 0x2a, 0x60, 0x00, 0x60, 0x00, 0xa1, 0x00}}
+	genesis.Alloc[parallelReverter] = conf.GenesisAccount{Balance: "0", Code: []byte{0x60, 0x00, 0x60, 0x00, 0xfd}}
+	genesis.Alloc[parallelDestructor] = conf.GenesisAccount{Balance: "1000000000000000000", Code: []byte{0x33, 0xff}}
+
+	db := memdb.NewTestDB(t)
+	var genesisBlock *block.Block
+	if err := db.Update(context.Background(), func(tx kv.RwTx) (err error) {
+		genesisBlock, _, err = (&GenesisBlock{GenesisConfig: genesis}).Write(tx)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	engine := apoa.New(genesis.Config.Clique, db)
+	t.Cleanup(func() { engine.Close() })
+	chain, err := NewBlockChain(context.Background(), genesisBlock, engine, db, nil, genesis.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc := chain.(*BlockChain)
+	t.Cleanup(func() { bc.Close() })
+
+	// The block is sealed, for its author to be the coinbase.
+	parent := genesisBlock.Header().(*block.Header)
+	header := &block.Header{
+		ParentHash: parent.Hash(),
+		Number:     uint256.NewInt(1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + 1,
+		Difficulty: uint256.NewInt(2),
+		Extra:      make([]byte, 32+crypto.SignatureLength),
+	}
+	header.BaseFee, _ = uint256.FromBig(misc.CalcBaseFee(genesis.Config, parent))
+	sig, err := crypto.Sign(crypto.Keccak256(apoa.ApoaProto(header)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(header.Extra[32:], sig)
+	return bc, header, signer
+}
+
+func parallelTx(sender int, nonce uint64, to *types.Address, value uint64, data []byte) *transaction.Transaction {
+	gasPrice := uint256.NewInt(100_000_000_000)
+	return transaction.NewTransaction(nonce, parallelSenders[sender], to, uint256.NewInt(value), 200_000, gasPrice, data)
+}
+
+// parallelTestBlock holds transactions depending on each other in all the
+// ways the speculation has to catch.
+func parallelTestBlock(header *block.Header) *block.Block {
+	txs := []*transaction.Transaction{
+		// Same sender nonce chain, on a shared storage slot.
+		parallelTx(0, 0, &parallelCounter, 0, nil),
+		parallelTx(0, 1, &parallelCounter, 0, nil),
+		parallelTx(1, 0, &parallelCounter, 0, nil),
+		parallelTx(2, 0, &parallelLogger, 0, nil),
+		parallelTx(1, 1, &parallelReverter, 0, nil),
+		// CREATE.
+		parallelTx(2, 1, nil, 0, parallelInitCode),
+		// SELFDESTRUCT, then a transfer bringing the account back.
+		parallelTx(3, 0, &parallelDestructor, 0, nil),
+		parallelTx(0, 2, &parallelDestructor, 5, nil),
+		// Reads the coinbase, paid by every transaction before.
+		parallelTx(3, 1, &parallelCoinbaseReader, 0, nil),
+		parallelTx(2, 2, &parallelSenders[1], 1, nil),
+		parallelTx(1, 2, &parallelLogger, 0, nil),
+	}
+	return block.NewBlock(header, txs).(*block.Block)
+}
+
+// executeBlock runs the transactions of the block on the state of its
+// parent, in parallel or serially.
+func executeBlock(t *testing.T, bc *BlockChain, b *block.Block, parallel bool) (block.Receipts, uint64, *state.IntraBlockState) {
+	tx, err := bc.ChainDB.BeginRo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tx.Rollback)
+
+	p := bc.process.(*StateProcessor)
+	header := b.Header().(*block.Header)
+	ibs := state.New(state.NewPlainStateReader(tx))
+	gp := new(common.GasPool).AddGas(b.GasLimit())
+	blockHashFunc := GetHashFn(header, func(hash types.Hash, number uint64) *block.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	})
+	var usedGas uint64
+	if parallel {
+		receipts, err := p.applyParallel(b, ibs, gp, &usedGas, blockHashFunc, vm2.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receipts, usedGas, ibs
+	}
+	var receipts block.Receipts
+	for i, txn := range b.Transactions() {
+		ibs.Prepare(txn.Hash(), b.Hash(), i)
+		receipt, _, err := ApplyTransaction(p.config, blockHashFunc, p.engine, nil, gp, ibs, state.NewNoopWriter(), header, txn, &usedGas, vm2.Config{})
+		if err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, usedGas, ibs
+}
+
+// sortedLogs returns the logs of the state in block order.
+func sortedLogs(ibs *state.IntraBlockState) []*block.Log {
+	logs := ibs.Logs()
+	sort.Slice(logs, func(i, j int) bool { return logs[i].Index < logs[j].Index })
+	return logs
+}
+
+func TestParallelExecutionMatchesSerial(t *testing.T) {
+	bc, header, coinbase := newParallelTestChain(t)
+	b := parallelTestBlock(header)
+
+	serial, serialGas, serialState := executeBlock(t, bc, b, false)
+	parallel, parallelGas, parallelState := executeBlock(t, bc, b, true)
+
+	if parallelGas != serialGas {
+		t.Fatalf("gas used = %d, serially %d", parallelGas, serialGas)
+	}
+	if len(parallel) != len(serial) {
+		t.Fatalf("%d receipts, serially %d", len(parallel), len(serial))
+	}
+	for i := range serial {
+		if !reflect.DeepEqual(parallel[i], serial[i]) {
+			t.Errorf("receipt %d differs:\nparallel %+v\nserial   %+v", i, parallel[i], serial[i])
+		}
+	}
+	// The state keeps the logs by transaction, in no particular order.
+	if logs, want := sortedLogs(parallelState), sortedLogs(serialState); !reflect.DeepEqual(logs, want) {
+		t.Errorf("logs differ:\nparallel %v\nserial   %v", logs, want)
+	}
+	if root, want := parallelState.IntermediateRoot(), serialState.IntermediateRoot(); root != want {
+		t.Errorf("state root = %v, serially %v", root, want)
+	}
+
+	// The block does what it is meant to, for the comparison to mean
+	// something.
+	if status := serial[4].Status; status != block.ReceiptStatusFailed {
+		t.Errorf("reverting transaction status = %d", status)
+	}
+	if serial[5].ContractAddress == (types.Address{}) || len(serialState.GetCode(serial[5].ContractAddress)) != 1 {
+		t.Error("contract not created")
+	}
+	if len(serial[3].Logs) != 1 || len(serial[10].Logs) != 1 || serial[10].Logs[0].Index != 1 {
+		t.Errorf("unexpected logs %v, %v", serial[3].Logs, serial[10].Logs)
+	}
+	var value uint256.Int
+	key := types.Hash{}
+	serialState.GetState(parallelCounter, &key, &value)
+	if value.Uint64() != 3 {
+		t.Errorf("counter = %v, want 3", &value)
+	}
+	serialState.GetState(parallelCoinbaseReader, &key, &value)
+	if value.IsZero() || value.Cmp(serialState.GetBalance(coinbase)) >= 0 {
+		t.Errorf("coinbase balance read %v, final balance %v", &value, serialState.GetBalance(coinbase))
+	}
+	if balance := serialState.GetBalance(parallelDestructor); balance.Uint64() != 5 {
+		t.Errorf("destructed account balance = %v, want 5", balance)
+	}
+}
+
+func TestParallelExecutionOffByDefault(t *testing.T) {
+	bc, header, _ := newParallelTestChain(t)
+	b := parallelTestBlock(header)
+	p := bc.process.(*StateProcessor)
+	ibs := state.New(nil)
+	if p.parallel(b, ibs, false) {
+		t.Fatal("parallel execution enabled by default")
+	}
+	bc.SetParallelExecution(true)
+	if !p.parallel(b, ibs, false) {
+		t.Fatal("parallel execution not enabled")
+	}
+	if p.parallel(b, ibs, true) {
+		t.Fatal("DAO fork block executed in parallel")
+	}
+}
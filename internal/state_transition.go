@@ -24,6 +24,7 @@ import (
 	vm2 "github.com/amazechain/amc/internal/vm"
 	"github.com/amazechain/amc/internal/vm/evmtypes"
 	"math"
+	"math/big"
 
 	"github.com/holiman/uint256"
 
@@ -442,12 +443,25 @@ func (st *StateTransition) TransitionDb(refunds bool, gasBailout bool) (*Executi
 	}
 	amount := new(uint256.Int).SetUint64(st.gasUsed())
 	amount.Mul(amount, effectiveTip) // gasUsed * effectiveTip = how much goes to the block producer (miner, validator)
-	if st.isParlia {
+	if economics := st.evm.ChainConfig().Rewards; !st.isParlia && economics.HasFeeSplit() {
+		// Distribute the whole fee, base fee included, by the configured shares
+		fee := amount.ToBig()
+		if !msg.IsFree() && rules.IsLondon {
+			fee.Add(fee, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.evm.Context().BaseFee.ToBig()))
+		}
+		proposerFee, treasuryFee := economics.SplitFee(fee)
+		proposer, _ := uint256.FromBig(proposerFee)
+		st.state.AddBalance(st.evm.Context().Coinbase, proposer)
+		if treasuryFee.Sign() > 0 {
+			treasury, _ := uint256.FromBig(treasuryFee)
+			st.state.AddBalance(*economics.Treasury, treasury)
+		}
+	} else if st.isParlia {
 		st.state.AddBalance(consensus.SystemAddress, amount)
 	} else {
 		st.state.AddBalance(st.evm.Context().Coinbase, amount)
 	}
-	if !msg.IsFree() && rules.IsLondon && rules.IsEip1559FeeCollector {
+	if !msg.IsFree() && rules.IsLondon && rules.IsEip1559FeeCollector && !st.evm.ChainConfig().Rewards.HasFeeSplit() {
 		burntContractAddress := *st.evm.ChainConfig().Eip1559FeeCollector
 		burnAmount := new(uint256.Int).Mul(new(uint256.Int).SetUint64(st.gasUsed()), st.evm.Context().BaseFee)
 		st.state.AddBalance(burntContractAddress, burnAmount)
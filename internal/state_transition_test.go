@@ -0,0 +1,87 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	vm2 "github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+)
+
+func TestTransitionFeeSplit(t *testing.T) {
+	var (
+		sender   = types.HexToAddress("0xa1")
+		receiver = types.HexToAddress("0xa2")
+		coinbase = types.HexToAddress("0xc0")
+		treasury = types.HexToAddress("0xfe")
+	)
+	tests := []struct {
+		name               string
+		rewards            *params.RewardConfig
+		proposer, treasury uint64
+	}{
+		// The fee of 21000 gas at a base fee of 10 and a tip of 5, split
+		// whole, base fee included.
+		{"split", &params.RewardConfig{BlockReward: new(big.Int), FeeProposerPercent: 50, FeeTreasuryPercent: 30, FeeBurnPercent: 20, Treasury: &treasury}, 157500, 94500},
+		// Without a split the proposer earns the tips only.
+		{"tips", nil, 105000, 0},
+	}
+	for _, tt := range tests {
+		config := *DeveloperGenesisBlock(0, coinbase).Config
+		config.Rewards = tt.rewards
+
+		tx, err := memdb.NewTestDB(t).BeginRo(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		ibs := state.New(state.NewPlainStateReader(tx))
+		ibs.AddBalance(sender, uint256.NewInt(1e18))
+
+		header := &block.Header{
+			Number:     uint256.NewInt(1),
+			GasLimit:   30_000_000,
+			Difficulty: uint256.NewInt(2),
+			BaseFee:    uint256.NewInt(10),
+		}
+		msg := transaction.NewTransaction(0, sender, &receiver, uint256.NewInt(1), params.TxGas, uint256.NewInt(15), nil)
+		var usedGas uint64
+		gp := new(common.GasPool).AddGas(header.GasLimit)
+		if _, _, err := ApplyTransaction(&config, GetHashFn(header, nil), nil, &coinbase, gp, ibs, state.NewNoopWriter(), header, msg, &usedGas, vm2.Config{}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := ibs.GetBalance(coinbase); got.Uint64() != tt.proposer {
+			t.Errorf("%s: proposer got %v, want %d", tt.name, got, tt.proposer)
+		}
+		if got := ibs.GetBalance(treasury); got.Uint64() != tt.treasury {
+			t.Errorf("%s: treasury got %v, want %d", tt.name, got, tt.treasury)
+		}
+		if want := uint64(1e18 - 1 - 15*params.TxGas); ibs.GetBalance(sender).Uint64() != want {
+			t.Errorf("%s: sender left with %v, want %d", tt.name, ibs.GetBalance(sender), want)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package sync
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"github.com/amazechain/amc/internal/p2p"
+	"net"
+	"os"
+
 	"github.com/amazechain/amc/internal/p2p/encoder"
 	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
 	"github.com/amazechain/amc/log"
@@ -17,8 +20,9 @@ var responseCodeSuccess = byte(0x00)
 var responseCodeInvalidRequest = byte(0x01)
 var responseCodeServerError = byte(0x02)
 
-func (s *Service) generateErrorResponse(code byte, reason string) ([]byte, error) {
-	return createErrorResponse(code, reason, s.cfg.p2p)
+// streamEncoding returns the encoding negotiated for a req/resp stream.
+func streamEncoding(stream network.Stream) encoder.NetworkEncoding {
+	return encoder.ForProtocol(string(stream.Protocol()))
 }
 
 // ReadStatusCode response from a RPC stream.
@@ -48,8 +52,8 @@ func ReadStatusCode(stream network.Stream, encoding encoder.NetworkEncoding) (ui
 	return b[0], string(*msg), nil
 }
 
-func writeErrorResponseToStream(responseCode byte, reason string, stream libp2pcore.Stream, encoder p2p.EncodingProvider) {
-	resp, err := createErrorResponse(responseCode, reason, encoder)
+func writeErrorResponseToStream(responseCode byte, reason string, stream libp2pcore.Stream) {
+	resp, err := createErrorResponse(responseCode, reason, streamEncoding(stream))
 	if err != nil {
 		log.Debug("Could not generate a response error", "err", err)
 	} else if _, err := stream.Write(resp); err != nil {
@@ -60,10 +64,10 @@ func writeErrorResponseToStream(responseCode byte, reason string, stream libp2pc
 	}
 }
 
-func createErrorResponse(code byte, reason string, encoder p2p.EncodingProvider) ([]byte, error) {
+func createErrorResponse(code byte, reason string, encoding encoder.NetworkEncoding) ([]byte, error) {
 	buf := bytes.NewBuffer([]byte{code})
 	errMsg := p2ptypes.ErrorMessage(reason)
-	if _, err := encoder.Encoding().EncodeWithMaxLength(buf, &errMsg); err != nil {
+	if _, err := encoding.EncodeWithMaxLength(buf, &errMsg); err != nil {
 		return nil, err
 	}
 
@@ -126,3 +130,13 @@ func closeStreamAndWait(stream network.Stream) {
 	_err = stream.Close()
 	_ = _err
 }
+
+// IsTimeout reports whether a request failed because the peer didn't answer
+// within the stream deadlines.
+func IsTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -266,7 +266,14 @@ func (f *blocksFetcher) fetchBlocksFromPeer(ctx context.Context, start *uint256.
 		Step:             1,
 	}
 	for i := 0; i < len(peers); i++ {
+		started := time.Now()
 		blocks, err := f.requestBlocks(ctx, req, peers[i])
+		f.p2p.Peers().Scorers().DownloadScorer().Delivered(peers[i], len(blocks), time.Since(started))
+		if errors.Is(err, amcsync.ErrInvalidFetchedData) {
+			f.p2p.Peers().Scorers().DownloadScorer().Garbage(peers[i])
+		} else if amcsync.IsTimeout(err) {
+			f.p2p.Peers().Scorers().DownloadScorer().Timeout(peers[i])
+		}
 		if err == nil {
 			f.p2p.Peers().Scorers().BlockProviderScorer().Touch(peers[i])
 			return blocks, peers[i], err
@@ -86,6 +86,14 @@ func (f *blocksFetcher) filterPeers(ctx context.Context, peers []peer.ID, peersP
 	// scores).
 	// Scores produced are used as weights, so peers are ordered probabilistically i.e. peer with
 	// a higher score has higher chance to end up higher in the list.
+	// Peers delivering garbage are left out, slow ones get a proportionally lower weight.
+	// The weights are taken up front, the scorer below holds the peer store lock.
+	downloads := f.p2p.Peers().Scorers().DownloadScorer()
+	peers = downloads.Sorted(peers)
+	relative := make(map[peer.ID]float64, len(peers))
+	for _, pid := range peers {
+		relative[pid] = downloads.Relative(pid, peers)
+	}
 	scorer := f.p2p.Peers().Scorers().BlockProviderScorer()
 	peers = scorer.WeightSorted(f.rand, peers, func(peerID peer.ID, blockProviderScore float64) float64 {
 		remaining, capacity := float64(f.rateLimiter.Remaining(peerID.String())), float64(f.rateLimiter.Capacity())
@@ -96,6 +104,7 @@ func (f *blocksFetcher) filterPeers(ctx context.Context, peers []peer.ID, peersP
 		}
 		capScore := remaining / capacity
 		overallScore := blockProviderScore*(1.0-f.capacityWeight) + capScore*f.capacityWeight
+		overallScore *= relative[peerID]
 		return math.Round(overallScore*scorers.ScoreRoundingFactor) / scorers.ScoreRoundingFactor
 	})
 
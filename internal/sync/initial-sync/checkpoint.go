@@ -0,0 +1,283 @@
+package initialsync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/params"
+	"github.com/amazechain/amc/utils"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pkg/errors"
+)
+
+// maxCheckpointAttempts is the number of peers tried for a batch of trusted headers.
+const maxCheckpointAttempts = 8
+
+var errCheckpointFork = errors.New("local chain isn't on the checkpoint chain")
+
+// trustedChain is implemented by block chains checking blocks against the
+// trusted header chain.
+type trustedChain interface {
+	SetCheckpoint(checkpoint *params.SyncCheckpoint)
+}
+
+// trustedAnchor returns the block the trusted header chain hangs from: the
+// latest announced trusted head, or else the configured checkpoint.
+func (s *Service) trustedAnchor() *params.SyncCheckpoint {
+	if head := s.trustedHead.Load(); head != nil {
+		return head
+	}
+	return s.cfg.Checkpoint
+}
+
+// fetchTrustedHeaders walks the header chain down from the trusted anchor to
+// the local head, storing every hash on the way. The anchor hash is trusted,
+// so each batch only has to link to the one above it; the blocks matching the
+// stored hashes are then imported without consensus checks.
+// The walk resumes from the lowest stored header after a restart, and a new
+// anchor only walks down until it joins the headers already stored.
+func (s *Service) fetchTrustedHeaders() error {
+	s.trustedLock.Lock()
+	defer s.trustedLock.Unlock()
+
+	cp := s.trustedAnchor()
+	head := s.cfg.Chain.CurrentBlock()
+	if cp == nil {
+		return nil
+	}
+	if head.Number64().Uint64() >= cp.Number {
+		// Past the checkpoint the headers aren't needed anymore.
+		return s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+			return tx.ClearBucket(modules.TrustedHeaders)
+		})
+	}
+	number, expected, err := s.trustedHeadersProgress(cp)
+	if err != nil {
+		return err
+	}
+	if chain, ok := s.cfg.Chain.(trustedChain); ok {
+		chain.SetCheckpoint(cp)
+	}
+	s.progress.target(cp.Number)
+	if number <= head.Number64().Uint64() {
+		return s.checkTrustedLink(number, expected)
+	}
+	_, peers := s.cfg.P2P.Peers().BestPeers(len(s.cfg.P2P.Peers().Connected()), uint256.NewInt(number-1))
+	if len(peers) == 0 {
+		log.Warn("No peer has reached the sync checkpoint yet", "checkpoint", cp)
+		return nil
+	}
+	pool := newPeerPool(s.cfg.P2P.Peers().Scorers().DownloadScorer(), peers)
+	if number == cp.Number {
+		log.Info("Fetching the header chain of the sync checkpoint", "checkpoint", cp, "head", head.Number64().Uint64())
+	}
+
+	for number > head.Number64().Uint64() {
+		count := number - head.Number64().Uint64()
+		if count > amcsync.MaxRequestHeaders {
+			count = amcsync.MaxRequestHeaders
+		}
+		headers, err := s.fetchTrustedBatch(pool, number-count+1, count, expected)
+		if err != nil {
+			return err
+		}
+		var joined bool
+		if err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+			for _, header := range headers {
+				if err := rawdb.WriteTrustedHeader(tx, header.Number64().Uint64(), header.Hash(), header.ParentHash); err != nil {
+					return err
+				}
+			}
+			// Below a header stored for an earlier anchor, the chain is known.
+			below, _, ok, err := rawdb.ReadTrustedHeader(tx, number-count)
+			joined = ok && below == headers[0].ParentHash
+			return err
+		}); err != nil {
+			return err
+		}
+		number, expected = number-count, headers[0].ParentHash
+		log.Debug("Fetched trusted headers", "from", number+1, "count", count)
+		if joined {
+			if number, expected, err = s.trustedHeadersProgress(cp); err != nil {
+				return err
+			}
+			log.Debug("Joined the stored trusted headers", "lowest", number+1)
+		}
+	}
+	if err := s.checkTrustedLink(number, expected); err != nil {
+		return err
+	}
+	log.Info("Fetched the header chain of the sync checkpoint", "checkpoint", cp)
+	return nil
+}
+
+// checkTrustedLink verifies the trusted chain ends on the local one.
+func (s *Service) checkTrustedLink(number uint64, expected types.Hash) error {
+	// The walk may have stopped below the head on an earlier run.
+	if local := s.cfg.Chain.GetHeaderByNumber(uint256.NewInt(number)); local == nil || local.Hash() != expected {
+		return fmt.Errorf("%w at #%d, checkpoint chain has %s", errCheckpointFork, number, expected)
+	}
+	return nil
+}
+
+// trustedHeadersProgress returns the number and hash of the highest header
+// still to be fetched below the anchor. Headers above the anchor are dropped;
+// those below it are kept, a new anchor may join them.
+func (s *Service) trustedHeadersProgress(cp *params.SyncCheckpoint) (number uint64, hash types.Hash, err error) {
+	err = s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+		c, err := tx.RwCursor(modules.TrustedHeaders)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		for k, _, err := c.Seek(modules.EncodeBlockNumber(cp.Number + 1)); k != nil; k, _, err = c.Next() {
+			if err != nil {
+				return err
+			}
+			if err := c.DeleteCurrent(); err != nil {
+				return err
+			}
+		}
+		top, _, ok, err := rawdb.ReadTrustedHeader(tx, cp.Number)
+		if err != nil {
+			return err
+		}
+		if !ok || top != cp.Hash {
+			number, hash = cp.Number, cp.Hash
+			return nil
+		}
+		lowest, _, err := rawdb.LowestTrustedHeader(tx)
+		if err != nil {
+			return err
+		}
+		_, parent, _, err := rawdb.ReadTrustedHeader(tx, lowest)
+		number, hash = lowest-1, parent
+		return err
+	})
+	return number, hash, err
+}
+
+// TrustHead announces a head from a trusted source, such as an external
+// consensus driver, which the sync follows optimistically: the header chain
+// is fetched backwards from it, and the blocks below it are then imported
+// against that chain while the bodies and state are filled in. A head
+// extending the previous one is stored right away, any other one starts a
+// backwards walk in the background.
+func (s *Service) TrustHead(header *block2.Header) {
+	cp := &params.SyncCheckpoint{Number: header.Number64().Uint64(), Hash: header.Hash()}
+	if prev := s.trustedAnchor(); prev != nil && prev.Hash == cp.Hash {
+		return
+	}
+	s.trustedHead.Store(cp)
+	s.progress.target(cp.Number)
+
+	if s.trustedLock.TryLock() {
+		extended, err := s.extendTrustedHeaders(header)
+		s.trustedLock.Unlock()
+		if err != nil {
+			log.Warn("Could not store trusted head", "number", cp.Number, "hash", cp.Hash, "err", err)
+		}
+		if extended {
+			return
+		}
+	}
+	select {
+	case s.trustedHeads <- struct{}{}:
+	default:
+	}
+}
+
+// extendTrustedHeaders stores a head on top of the stored trusted chain.
+func (s *Service) extendTrustedHeaders(header *block2.Header) (bool, error) {
+	number := header.Number64().Uint64()
+	if number == 0 || number <= s.cfg.Chain.CurrentBlock().Number64().Uint64() {
+		return false, nil
+	}
+	var extended bool
+	err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+		parent, _, ok, err := rawdb.ReadTrustedHeader(tx, number-1)
+		if err != nil || !ok || parent != header.ParentHash {
+			return err
+		}
+		if _, _, above, err := rawdb.ReadTrustedHeader(tx, number); err != nil || above {
+			return err
+		}
+		extended = true
+		return rawdb.WriteTrustedHeader(tx, number, header.Hash(), header.ParentHash)
+	})
+	if extended {
+		if chain, ok := s.cfg.Chain.(trustedChain); ok {
+			chain.SetCheckpoint(s.trustedHead.Load())
+		}
+	}
+	return extended, err
+}
+
+// followTrustedHeads walks the header chain down from every announced head
+// that doesn't extend the stored one.
+func (s *Service) followTrustedHeads() {
+	for {
+		select {
+		case <-s.trustedHeads:
+			if err := s.fetchTrustedHeaders(); err != nil && s.ctx.Err() == nil {
+				log.Warn("Could not fetch the header chain of the trusted head", "head", s.trustedAnchor(), "err", err)
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// fetchTrustedBatch requests count headers from start, which must link up to
+// the hash expected at the last one.
+func (s *Service) fetchTrustedBatch(pool *peerPool, start, count uint64, expected types.Hash) ([]*block2.Header, error) {
+	var (
+		failed peer.ID
+		err    error
+	)
+	for attempt := 0; attempt < maxCheckpointAttempts; attempt++ {
+		pid, perr := pool.acquire(s.ctx, failed)
+		if perr != nil {
+			return nil, perr
+		}
+		started := time.Now()
+		var headers []*block2.Header
+		headers, err = amcsync.SendHeadersByRangeRequest(s.ctx, s.cfg.P2P, pid, &sync_pb.HeadersByRangeRequest{
+			StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(start)),
+			Count:            count,
+			Step:             1,
+		})
+		if err == nil && !linksTo(headers, count, expected) {
+			err = amcsync.ErrInvalidFetchedData
+		}
+		pool.release(pid, len(headers), started, err)
+		if err == nil {
+			return headers, nil
+		}
+		log.Debug("Could not fetch trusted headers", "peer", pid, "start", start, "err", err)
+		failed = pid
+	}
+	return nil, err
+}
+
+// linksTo reports whether the headers are a chain of count ending at hash.
+func linksTo(headers []*block2.Header, count uint64, hash types.Hash) bool {
+	if uint64(len(headers)) != count {
+		return false
+	}
+	for i := len(headers) - 1; i >= 0; i-- {
+		if headers[i].Hash() != hash {
+			return false
+		}
+		hash = headers[i].ParentHash
+	}
+	return true
+}
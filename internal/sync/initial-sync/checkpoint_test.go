@@ -0,0 +1,144 @@
+package initialsync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amazechain/amc/common"
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// checkpointTestChain is the part of the block chain the trusted header chain
+// is written with.
+type checkpointTestChain struct {
+	common.IBlockChain
+	db         kv.RwDB
+	head       block2.IBlock
+	checkpoint *params.SyncCheckpoint
+}
+
+func (c *checkpointTestChain) DB() kv.RwDB                 { return c.db }
+func (c *checkpointTestChain) CurrentBlock() block2.IBlock { return c.head }
+func (c *checkpointTestChain) SetCheckpoint(checkpoint *params.SyncCheckpoint) {
+	c.checkpoint = checkpoint
+}
+
+// newCheckpointTestService returns a sync service at the genesis block of a
+// chain storing the given trusted headers.
+func newCheckpointTestService(t *testing.T, trusted []*block2.Header) (*Service, *checkpointTestChain) {
+	genesis := &block2.Header{Number: uint256.NewInt(0), Difficulty: uint256.NewInt(0), BaseFee: uint256.NewInt(0)}
+	chain := &checkpointTestChain{db: memdb.NewTestDB(t), head: block2.NewBlock(genesis, nil)}
+	writeTrustedHeaders(t, chain.db, trusted)
+	s := NewService(context.Background(), &Config{Chain: chain})
+	t.Cleanup(s.cancel)
+	return s, chain
+}
+
+func writeTrustedHeaders(t *testing.T, db kv.RwDB, headers []*block2.Header) {
+	if err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		for _, header := range headers {
+			if err := rawdb.WriteTrustedHeader(tx, header.Number64().Uint64(), header.Hash(), header.ParentHash); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinksTo(t *testing.T) {
+	chain := skeletonTestChain(types.Hash{0x01}, 4)
+	tests := []struct {
+		headers []*block2.Header
+		count   uint64
+		hash    types.Hash
+		want    bool
+	}{
+		{chain, 4, chain[3].Hash(), true},
+		{chain[1:3], 2, chain[2].Hash(), true},
+		{chain, 4, chain[2].Hash(), false},
+		{chain[:3], 4, chain[2].Hash(), false},
+		{[]*block2.Header{chain[0], chain[2]}, 2, chain[2].Hash(), false},
+	}
+	for i, tt := range tests {
+		if got := linksTo(tt.headers, tt.count, tt.hash); got != tt.want {
+			t.Errorf("test %d: links %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestTrustedHeadersProgress(t *testing.T) {
+	chain := skeletonTestChain(types.Hash{0x01}, 12)
+	cp := &params.SyncCheckpoint{Number: 10, Hash: chain[9].Hash()}
+
+	// Nothing stored, the walk starts at the checkpoint.
+	s, _ := newCheckpointTestService(t, nil)
+	if number, hash, err := s.trustedHeadersProgress(cp); err != nil || number != 10 || hash != cp.Hash {
+		t.Errorf("progress #%d %s, %v, want the checkpoint", number, hash, err)
+	}
+
+	// A walk down to #8 resumes below it, dropping the headers above the
+	// checkpoint.
+	s, bc := newCheckpointTestService(t, chain[7:12])
+	if number, hash, err := s.trustedHeadersProgress(cp); err != nil || number != 7 || hash != chain[6].Hash() {
+		t.Errorf("progress #%d %s, %v, want #7 %s", number, hash, err, chain[6].Hash())
+	}
+	if err := bc.db.View(context.Background(), func(tx kv.Tx) error {
+		for _, number := range []uint64{11, 12} {
+			if _, _, ok, err := rawdb.ReadTrustedHeader(tx, number); err != nil || ok {
+				t.Errorf("trusted header #%d above the checkpoint kept", number)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another anchor at the same height walks again from the top.
+	other := &params.SyncCheckpoint{Number: 10, Hash: types.Hash{0xff}}
+	if number, hash, err := s.trustedHeadersProgress(other); err != nil || number != 10 || hash != other.Hash {
+		t.Errorf("progress #%d %s, %v, want the new anchor", number, hash, err)
+	}
+}
+
+func TestTrustHead(t *testing.T) {
+	chain := skeletonTestChain(types.Hash{0x01}, 12)
+	s, bc := newCheckpointTestService(t, chain[7:10])
+
+	// A head on top of the stored chain is stored right away.
+	s.TrustHead(chain[10])
+	if bc.checkpoint == nil || bc.checkpoint.Hash != chain[10].Hash() {
+		t.Fatalf("checkpoint %v, want #11", bc.checkpoint)
+	}
+	select {
+	case <-s.trustedHeads:
+		t.Fatal("walk started for a head extending the stored chain")
+	default:
+	}
+	if err := bc.db.View(context.Background(), func(tx kv.Tx) error {
+		if hash, _, ok, err := rawdb.ReadTrustedHeader(tx, 11); err != nil || !ok || hash != chain[10].Hash() {
+			t.Errorf("head not stored: %s, %v, %v", hash, ok, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Any other head is walked back from in the background.
+	s.TrustHead(skeletonTestChain(types.Hash{0x02}, 12)[11])
+	select {
+	case <-s.trustedHeads:
+	default:
+		t.Fatal("no walk started for a head not extending the stored chain")
+	}
+	if head := s.trustedAnchor(); head.Number != 12 {
+		t.Errorf("trusted anchor #%d, want #12", head.Number)
+	}
+}
@@ -0,0 +1,101 @@
+package initialsync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/types"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// repairState walks the healed flat state and fetches what it is missing.
+//
+// The ranges and the changes were read by the peer at different heads, so
+// the downloaded tables may not agree with each other: a contract deployed
+// while the code table was being downloaded points to a code that came
+// neither with the code ranges nor, if its account was back to its original
+// value, with the changes. Codes are checked against their hash, those that
+// don't match are dropped, and every missing one is requested by hash.
+func (s *Service) repairState(pid peer.ID) error {
+	missing, err := s.missingCodes()
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	log.Info("Repairing downloaded state", "codes", len(missing))
+
+	scorer := s.cfg.P2P.Peers().Scorers().DownloadScorer()
+	for i, hash := range missing {
+		s.progress.repairing(uint64(len(missing) - i))
+		started := time.Now()
+		_, entries, err := amcsync.SendStateRangeRequest(s.ctx, s.cfg.P2P, pid, &sync_pb.StateRangeRequest{
+			Table:  amcsync.StateCodeTable,
+			Count:  1,
+			Origin: hash[:],
+		})
+		scorer.Delivered(pid, len(entries), time.Since(started))
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 || types.BytesToHash(entries[0].Key) != hash || crypto.Keccak256Hash(entries[0].Value) != hash {
+			scorer.Garbage(pid)
+			return fmt.Errorf("peer has no code %s", hash)
+		}
+		if err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+			return putStateEntries(tx, entries[:1])
+		}); err != nil {
+			return err
+		}
+		s.progress.healed(entries[:1], 0)
+	}
+	s.progress.repairing(0)
+	return nil
+}
+
+// missingCodes drops the codes not matching their hash and returns the hashes
+// of the contract codes that aren't in the code table.
+func (s *Service) missingCodes() ([]types.Hash, error) {
+	var missing []types.Hash
+	err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+		var invalid [][]byte
+		if err := tx.ForEach(modules.Code, nil, func(k, v []byte) error {
+			if crypto.Keccak256Hash(v) != types.BytesToHash(k) {
+				invalid = append(invalid, types.CopyBytes(k))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range invalid {
+			if err := tx.Delete(modules.Code, k); err != nil {
+				return err
+			}
+		}
+
+		seen := make(map[types.Hash]struct{})
+		return tx.ForEach(modules.PlainContractCode, nil, func(_, v []byte) error {
+			hash := types.BytesToHash(v)
+			if _, ok := seen[hash]; ok || account.IsEmptyCodeHash(hash) {
+				return nil
+			}
+			seen[hash] = struct{}{}
+			code, err := tx.GetOne(modules.Code, v)
+			if err != nil {
+				return err
+			}
+			if code == nil {
+				missing = append(missing, hash)
+			}
+			return nil
+		})
+	})
+	return missing, err
+}
@@ -0,0 +1,63 @@
+package initialsync
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+func TestMissingCodes(t *testing.T) {
+	s, chain := newCheckpointTestService(t, nil)
+	stored, missing, corrupted := []byte("stored code"), []byte("missing code"), []byte("corrupted code")
+	storedHash, missingHash, corruptedHash := crypto.Keccak256Hash(stored), crypto.Keccak256Hash(missing), crypto.Keccak256Hash(corrupted)
+
+	if err := chain.db.Update(context.Background(), func(tx kv.RwTx) error {
+		if err := tx.Put(modules.Code, storedHash[:], stored); err != nil {
+			return err
+		}
+		// A code served at another head than the contract pointing to it.
+		if err := tx.Put(modules.Code, corruptedHash[:], []byte("tampered code")); err != nil {
+			return err
+		}
+		contracts := map[byte]types.Hash{
+			0x01: storedHash,
+			0x02: missingHash,
+			0x03: missingHash,
+			0x04: corruptedHash,
+			0x05: crypto.Keccak256Hash(nil),
+		}
+		for addr, hash := range contracts {
+			if err := tx.Put(modules.PlainContractCode, []byte{addr}, hash[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.missingCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every code is requested once, in the order of the contracts.
+	if want := []types.Hash{missingHash, corruptedHash}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missing codes %v, want %v", got, want)
+	}
+	if err := chain.db.View(context.Background(), func(tx kv.Tx) error {
+		if code, err := tx.GetOne(modules.Code, corruptedHash[:]); err != nil || code != nil {
+			t.Errorf("code not matching its hash kept: %q, %v", code, err)
+		}
+		if n := countEntries(t, tx, modules.Code); n != 1 {
+			t.Errorf("%d codes after the walk, want 1", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
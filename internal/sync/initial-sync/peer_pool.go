@@ -0,0 +1,91 @@
+package initialsync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/internal/p2p/peers/scorers"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/pkg/errors"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// peerPool hands out idle peers to concurrent downloads, fastest first, and
+// measures every request made through it. A slow peer only ever holds a single
+// request, while the fast ones keep being handed the next ones.
+type peerPool struct {
+	scorer *scorers.DownloadScorer
+
+	lock sync.Mutex
+	idle map[peer.ID]struct{}
+	busy int
+	wake chan struct{} // closed when a peer is released
+}
+
+func newPeerPool(scorer *scorers.DownloadScorer, peers []peer.ID) *peerPool {
+	pool := &peerPool{
+		scorer: scorer,
+		idle:   make(map[peer.ID]struct{}, len(peers)),
+		wake:   make(chan struct{}),
+	}
+	for _, pid := range peers {
+		pool.idle[pid] = struct{}{}
+	}
+	return pool
+}
+
+// acquire waits for the best idle peer other than the excluded one.
+func (p *peerPool) acquire(ctx context.Context, exclude peer.ID) (peer.ID, error) {
+	for {
+		p.lock.Lock()
+		candidates := make([]peer.ID, 0, len(p.idle))
+		for pid := range p.idle {
+			if pid != exclude {
+				candidates = append(candidates, pid)
+			}
+		}
+		if sorted := p.scorer.Sorted(candidates); len(sorted) > 0 {
+			delete(p.idle, sorted[0])
+			p.busy++
+			p.lock.Unlock()
+			return sorted[0], nil
+		}
+		if p.busy == 0 {
+			p.lock.Unlock()
+			return "", errNoPeersAvailable
+		}
+		wake := p.wake
+		p.lock.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// release returns a peer to the pool, recording how many items it delivered
+// since it was acquired and why the request failed, if it did. Peers that
+// delivered garbage or timed out too often are dropped.
+func (p *peerPool) release(pid peer.ID, items int, started time.Time, err error) {
+	switch {
+	case errors.Is(err, amcsync.ErrInvalidFetchedData) || errors.Is(err, errSegmentInvalid):
+		p.scorer.Garbage(pid)
+	case amcsync.IsTimeout(err):
+		p.scorer.Timeout(pid)
+	}
+	p.scorer.Delivered(pid, items, time.Since(started))
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.busy--
+	if !p.scorer.IsBadPeer(pid) {
+		p.idle[pid] = struct{}{}
+	}
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
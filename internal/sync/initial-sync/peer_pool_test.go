@@ -0,0 +1,109 @@
+package initialsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amazechain/amc/internal/p2p/peers/peerdata"
+	"github.com/amazechain/amc/internal/p2p/peers/scorers"
+	amcsync "github.com/amazechain/amc/internal/sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestPeerPool(t *testing.T, peers ...peer.ID) *peerPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store := peerdata.NewStore(ctx, &peerdata.StoreConfig{MaxPeers: 30})
+	return newPeerPool(scorers.NewService(ctx, store, &scorers.Config{}).DownloadScorer(), peers)
+}
+
+func TestPeerPoolFastestFirst(t *testing.T) {
+	fast, slow := peer.ID("fast"), peer.ID("slow")
+	pool := newTestPeerPool(t, fast, slow)
+	pool.scorer.Delivered(fast, 100, time.Second)
+	pool.scorer.Delivered(slow, 10, time.Second)
+
+	ctx := context.Background()
+	if pid, err := pool.acquire(ctx, ""); err != nil || pid != fast {
+		t.Fatalf("acquired %v, %v, want the fast peer", pid, err)
+	}
+	if pid, err := pool.acquire(ctx, ""); err != nil || pid != slow {
+		t.Fatalf("acquired %v, %v, want the slow peer", pid, err)
+	}
+	pool.release(slow, 10, time.Now(), nil)
+	pool.release(fast, 100, time.Now(), nil)
+
+	// The peer a request just failed on is skipped.
+	if pid, err := pool.acquire(ctx, fast); err != nil || pid != slow {
+		t.Fatalf("acquired %v, %v, want the slow peer", pid, err)
+	}
+	pool.release(slow, 10, time.Now(), nil)
+
+	// With no other peer to try, nothing can be handed out.
+	single := newTestPeerPool(t, fast)
+	if _, err := single.acquire(ctx, fast); !errors.Is(err, errNoPeersAvailable) {
+		t.Fatalf("error %v, want %v", err, errNoPeersAvailable)
+	}
+}
+
+func TestPeerPoolWait(t *testing.T) {
+	pid := peer.ID("peer")
+	pool := newTestPeerPool(t, pid)
+	if _, err := pool.acquire(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests wait for a busy peer to be released.
+	acquired := make(chan peer.ID)
+	go func() {
+		got, err := pool.acquire(context.Background(), "")
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- got
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a busy peer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	pool.release(pid, 1, time.Now(), nil)
+	select {
+	case got := <-acquired:
+		if got != pid {
+			t.Fatalf("acquired %v, want %v", got, pid)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("released peer not handed out")
+	}
+
+	// Waiting stops with the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.acquire(ctx, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPeerPoolDropsBadPeers(t *testing.T) {
+	bad, good := peer.ID("bad"), peer.ID("good")
+	pool := newTestPeerPool(t, bad, good)
+
+	ctx := context.Background()
+	for i := 0; i < scorers.DefaultDownloadGarbageThreshold; i++ {
+		if pid, err := pool.acquire(ctx, good); err != nil || pid != bad {
+			t.Fatalf("acquired %v, %v, want the bad peer", pid, err)
+		}
+		pool.release(bad, 0, time.Now(), amcsync.ErrInvalidFetchedData)
+	}
+	// The peer delivered garbage too often to be used again.
+	if _, err := pool.acquire(ctx, good); !errors.Is(err, errNoPeersAvailable) {
+		t.Fatalf("error %v, want %v", err, errNoPeersAvailable)
+	}
+	if pid, err := pool.acquire(ctx, ""); err != nil || pid != good {
+		t.Fatalf("acquired %v, %v, want the good peer", pid, err)
+	}
+}
@@ -0,0 +1,169 @@
+package initialsync
+
+import (
+	"context"
+	"sync"
+
+	amazechain "github.com/amazechain/amc"
+	"github.com/amazechain/amc/common"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/modules"
+	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// syncProgress counts what the running sync downloaded, for eth_syncing.
+// The state counters only move during snap sync; a resumed snap sync starts
+// them from the entries already in the database.
+type syncProgress struct {
+	lock     sync.RWMutex
+	progress amazechain.SyncProgress
+	imported uint64 // last block imported below the snap sync pivot
+}
+
+// start records the block a sync begins at and the highest block known.
+func (p *syncProgress) start(number, highest uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.progress = amazechain.SyncProgress{StartingBlock: number, HighestBlock: highest}
+	p.imported = 0
+}
+
+// target records a block announced by a peer. The highest block never moves
+// back during a sync.
+func (p *syncProgress) target(number uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if number > p.progress.HighestBlock {
+		p.progress.HighestBlock = number
+	}
+}
+
+// reached records the blocks imported without state, which don't move the head.
+func (p *syncProgress) reached(number uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.imported = number
+}
+
+// restore counts the state entries downloaded before a restart.
+func (p *syncProgress) restore(tx kv.Tx) error {
+	count := func(table string) (uint64, uint64, error) {
+		c, err := tx.Cursor(table)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer c.Close()
+		n, err := c.Count()
+		if err != nil {
+			return 0, 0, err
+		}
+		size, err := tx.BucketSize(table)
+		return n, size, err
+	}
+	accounts, accountBytes, err := count(modules.Account)
+	if err != nil {
+		return err
+	}
+	storage, storageBytes, err := count(modules.Storage)
+	if err != nil {
+		return err
+	}
+	codes, codeBytes, err := count(modules.Code)
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.progress.SyncedAccounts, p.progress.SyncedAccountBytes = accounts, accountBytes
+	p.progress.SyncedStorage, p.progress.SyncedStorageBytes = storage, storageBytes
+	p.progress.SyncedBytecodes, p.progress.SyncedBytecodeBytes = codes, codeBytes
+	return nil
+}
+
+// synced counts the entries of a downloaded state range.
+func (p *syncProgress) synced(entries []amcsync.StateEntry) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, entry := range entries {
+		size := uint64(len(entry.Key) + len(entry.Value))
+		switch amcsync.StateTables[entry.Table] {
+		case modules.Account:
+			p.progress.SyncedAccounts++
+			p.progress.SyncedAccountBytes += size
+		case modules.Storage:
+			p.progress.SyncedStorage++
+			p.progress.SyncedStorageBytes += size
+		case modules.Code:
+			p.progress.SyncedBytecodes++
+			p.progress.SyncedBytecodeBytes += size
+		}
+	}
+}
+
+// healed counts the entries of a heal round along with the number of blocks
+// still to be healed. There is no state trie, so the trie node fields carry
+// the flat entries.
+func (p *syncProgress) healed(entries []amcsync.StateEntry, pending uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, entry := range entries {
+		size := uint64(len(entry.Key) + len(entry.Value))
+		if entry.Table == amcsync.StateCodeTable {
+			p.progress.HealedBytecodes++
+			p.progress.HealedBytecodeBytes += size
+		} else {
+			p.progress.HealedTrienodes++
+			p.progress.HealedTrienodeBytes += size
+		}
+	}
+	p.progress.HealingTrienodes = pending
+}
+
+// repairing records the number of codes the state walk is still missing.
+func (p *syncProgress) repairing(pending uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.progress.HealingBytecode = pending
+}
+
+// SyncProgress returns the progress of the running sync, or nil once the node
+// is synced.
+func (s *Service) SyncProgress(_ context.Context) (*amazechain.SyncProgress, error) {
+	if s.Synced() && !s.Syncing() {
+		return nil, nil
+	}
+	s.progress.lock.RLock()
+	progress, imported := s.progress.progress, s.progress.imported
+	s.progress.lock.RUnlock()
+
+	progress.CurrentBlock = s.cfg.Chain.CurrentBlock().Number64().Uint64()
+	if imported > progress.CurrentBlock {
+		progress.CurrentBlock = imported
+	}
+	if progress.HighestBlock < progress.CurrentBlock {
+		progress.HighestBlock = progress.CurrentBlock
+	}
+	return &progress, nil
+}
+
+// syncStarted tells the miner, the transaction pool and the RPC that the
+// local head is behind until the sync finishes or fails.
+func (s *Service) syncStarted() {
+	event.GlobalEvent.Send(common.SyncStartedEvent{StartingBlock: s.cfg.Chain.CurrentBlock().Number64().Uint64()})
+}
+
+// publishProgress posts the current progress, as returned by SyncProgress.
+func (s *Service) publishProgress() {
+	if progress, _ := s.SyncProgress(s.ctx); progress != nil {
+		event.GlobalEvent.Send(common.SyncProgressEvent{Progress: *progress})
+	}
+}
+
+func (s *Service) syncFinished() {
+	event.GlobalEvent.Send(common.SyncFinishedEvent{Head: s.cfg.Chain.CurrentBlock().Number64().Uint64()})
+}
+
+func (s *Service) syncFailed(err error) {
+	event.GlobalEvent.Send(common.SyncFailedEvent{Head: s.cfg.Chain.CurrentBlock().Number64().Uint64(), Err: err})
+}
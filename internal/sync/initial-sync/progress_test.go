@@ -0,0 +1,87 @@
+package initialsync
+
+import (
+	"context"
+	"testing"
+
+	block2 "github.com/amazechain/amc/common/block"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/modules"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+func TestSyncProgress(t *testing.T) {
+	s, chain := newCheckpointTestService(t, nil)
+	s.progress.start(0, 100)
+	s.progress.target(120)
+	// Peers announcing lower blocks don't move the target back.
+	s.progress.target(110)
+
+	s.progress.synced([]amcsync.StateEntry{
+		{Table: accountsTable, Key: []byte{0x01}, Value: []byte{0xac}},
+		{Table: accountsTable, Key: []byte{0x02}, Value: []byte{0xac}},
+		{Table: amcsync.StateCodeTable, Key: []byte{0x03}, Value: []byte("code")},
+	})
+	s.progress.healed([]amcsync.StateEntry{
+		{Table: accountsTable, Key: []byte{0x01}, Value: []byte{0xad}},
+		{Table: amcsync.StateCodeTable, Key: []byte{0x04}, Value: []byte("code")},
+	}, 7)
+	s.progress.repairing(3)
+	s.progress.reached(90)
+
+	progress, err := s.SyncProgress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.CurrentBlock != 90 || progress.HighestBlock != 120 {
+		t.Errorf("blocks %d of %d, want 90 of 120", progress.CurrentBlock, progress.HighestBlock)
+	}
+	if progress.SyncedAccounts != 2 || progress.SyncedAccountBytes != 4 || progress.SyncedBytecodes != 1 || progress.SyncedBytecodeBytes != 5 {
+		t.Errorf("synced %d accounts (%d bytes), %d codes (%d bytes)",
+			progress.SyncedAccounts, progress.SyncedAccountBytes, progress.SyncedBytecodes, progress.SyncedBytecodeBytes)
+	}
+	if progress.HealedTrienodes != 1 || progress.HealedBytecodes != 1 || progress.HealingTrienodes != 7 || progress.HealingBytecode != 3 {
+		t.Errorf("healed %d entries and %d codes, healing %d blocks and %d codes",
+			progress.HealedTrienodes, progress.HealedBytecodes, progress.HealingTrienodes, progress.HealingBytecode)
+	}
+
+	// Once past the blocks imported without state, the head is the current block.
+	chain.head = block2.NewBlock(&block2.Header{Number: uint256.NewInt(130), Difficulty: uint256.NewInt(2), BaseFee: uint256.NewInt(0)}, nil)
+	if progress, _ = s.SyncProgress(context.Background()); progress.CurrentBlock != 130 || progress.HighestBlock != 130 {
+		t.Errorf("blocks %d of %d, want 130 of 130", progress.CurrentBlock, progress.HighestBlock)
+	}
+
+	// A synced node reports no progress.
+	s.synced.Store(true)
+	if progress, err := s.SyncProgress(context.Background()); err != nil || progress != nil {
+		t.Errorf("progress of a synced node %+v, %v", progress, err)
+	}
+}
+
+func TestSyncProgressRestore(t *testing.T) {
+	s, chain := newCheckpointTestService(t, nil)
+	if err := chain.db.Update(context.Background(), func(tx kv.RwTx) error {
+		for i := 0; i < 3; i++ {
+			if err := tx.Put(modules.Account, []byte{byte(i)}, []byte{0xac}); err != nil {
+				return err
+			}
+		}
+		return tx.Put(modules.Code, []byte{0x01}, []byte("code"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A resumed snap sync counts the entries downloaded before the restart.
+	if err := chain.db.View(context.Background(), s.progress.restore); err != nil {
+		t.Fatal(err)
+	}
+	progress, err := s.SyncProgress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.SyncedAccounts != 3 || progress.SyncedStorage != 0 || progress.SyncedBytecodes != 1 {
+		t.Errorf("restored %d accounts, %d slots and %d codes, want 3, 0 and 1",
+			progress.SyncedAccounts, progress.SyncedStorage, progress.SyncedBytecodes)
+	}
+}
@@ -32,6 +32,7 @@ func (s *Service) roundRobinSync(highestExpectedBlockNr *uint256.Int) error {
 
 	s.counter = ratecounter.NewRateCounter(counterSeconds * time.Second)
 	s.highestExpectedBlockNr = highestExpectedBlockNr.Clone()
+	s.progress.target(highestExpectedBlockNr.Uint64())
 	// Step 1 - Sync to end of finalized BlockNr.
 	if err := s.syncToFinalizedBlockNr(ctx, highestExpectedBlockNr); err != nil {
 		return err
@@ -47,6 +48,12 @@ func (s *Service) syncToFinalizedBlockNr(ctx context.Context, highestExpectedBlo
 		log.Debug("Already synced to finalized block number")
 		return nil
 	}
+	if err := s.skeletonSync(ctx, highestExpectedBlockNr); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warn("Skeleton sync stopped, continuing block by block", "err", err)
+	}
 	queue := newBlocksQueue(ctx, &blocksQueueConfig{
 		p2p:                    s.cfg.P2P,
 		chain:                  s.cfg.Chain,
@@ -146,4 +153,5 @@ func (s *Service) logBatchSyncStatus(blks []*types_pb.Block) {
 		"blocksPerSecond", fmt.Sprintf("%.1f", rate),
 		"highestExpectedBlockNr", s.highestExpectedBlockNr.Uint64(),
 	)
+	s.publishProgress()
 }
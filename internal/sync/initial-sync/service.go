@@ -8,10 +8,11 @@ import (
 	"fmt"
 	"github.com/amazechain/amc/common"
 	"github.com/amazechain/amc/internal/p2p"
-	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/params"
 	"github.com/holiman/uint256"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/paulbellamy/ratecounter"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -20,8 +21,10 @@ import (
 
 // Config to set up the initial sync service.
 type Config struct {
-	P2P   p2p.P2P
-	Chain common.IBlockChain
+	P2P        p2p.P2P
+	Chain      common.IBlockChain
+	SnapSync   bool                   // download the state of a recent block instead of executing the chain
+	Checkpoint *params.SyncCheckpoint // trusted block whose header chain is fetched first
 }
 
 // Service service.
@@ -33,6 +36,11 @@ type Service struct {
 	syncing                atomic.Bool
 	counter                *ratecounter.RateCounter
 	highestExpectedBlockNr *uint256.Int
+	progress               syncProgress
+
+	trustedLock  sync.Mutex                            // held while the trusted header chain is written
+	trustedHead  atomic.Pointer[params.SyncCheckpoint] // latest head announced by TrustHead
+	trustedHeads chan struct{}                         // signals a head to walk back from
 }
 
 // NewService configures the initial sync service responsible for bringing the node up to the
@@ -40,10 +48,11 @@ type Service struct {
 func NewService(ctx context.Context, cfg *Config) *Service {
 	ctx, cancel := context.WithCancel(ctx)
 	s := &Service{
-		cfg:     cfg,
-		ctx:     ctx,
-		cancel:  cancel,
-		counter: ratecounter.NewRateCounter(counterSeconds * time.Second),
+		cfg:          cfg,
+		ctx:          ctx,
+		cancel:       cancel,
+		counter:      ratecounter.NewRateCounter(counterSeconds * time.Second),
+		trustedHeads: make(chan struct{}, 1),
 	}
 
 	return s
@@ -51,13 +60,12 @@ func NewService(ctx context.Context, cfg *Config) *Service {
 
 // Start the initial sync service.
 func (s *Service) Start() {
-
-	event.GlobalEvent.Send(common.DownloaderStartEvent{})
-	defer event.GlobalEvent.Send(common.DownloaderFinishEvent{})
-
 	log.Info("Starting initial chain sync...")
-	highestExpectedBlockNr := s.waitForMinimumPeers()
-	if err := s.roundRobinSync(highestExpectedBlockNr); err != nil {
+	go s.followTrustedHeads()
+
+	s.syncStarted()
+	if err := s.initialSync(); err != nil {
+		s.syncFailed(err)
 		if errors.Is(s.ctx.Err(), context.Canceled) {
 			return
 		}
@@ -65,6 +73,57 @@ func (s *Service) Start() {
 	}
 	log.Info(fmt.Sprintf("Synced up to blockNr: %d", s.cfg.Chain.CurrentBlock().Number64().Uint64()))
 	s.markSynced()
+	s.syncFinished()
+}
+
+// initialSync brings the node from its local head up to the network head.
+func (s *Service) initialSync() error {
+	highestExpectedBlockNr := s.waitForMinimumPeers()
+	s.progress.start(s.cfg.Chain.CurrentBlock().Number64().Uint64(), highestExpectedBlockNr.Uint64())
+	if err := s.fetchTrustedHeaders(); err != nil {
+		if errors.Is(s.ctx.Err(), context.Canceled) {
+			return s.ctx.Err()
+		}
+		log.Warn("Could not fetch the checkpoint header chain, verifying every header", "checkpoint", s.trustedAnchor(), "err", err)
+	}
+	// An interrupted snap sync resumes where it stopped, unless the node was
+	// switched to full sync meanwhile. Once the head was moved to the pivot,
+	// only the execution of the next block is left to check.
+	pivot, interrupted, err := s.snapSyncProgress()
+	if err != nil {
+		return err
+	}
+	moved := interrupted && pivot > 0 && s.cfg.Chain.CurrentBlock().Number64().Uint64() >= pivot
+	if interrupted && !moved && !s.cfg.SnapSync {
+		log.Warn("Discarding interrupted snap sync")
+		if err := s.abortSnapSync(); err != nil {
+			return err
+		}
+	}
+	if s.cfg.SnapSync && s.cfg.Chain.CurrentBlock().Number64().IsZero() {
+		if err := s.snapSync(); err != nil {
+			if errors.Is(s.ctx.Err(), context.Canceled) {
+				return s.ctx.Err()
+			}
+			log.Warn("Snap sync failed, falling back to full sync", "err", err)
+			if err := s.abortSnapSync(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.roundRobinSync(highestExpectedBlockNr); err != nil {
+		return err
+	}
+	if rejected, err := s.finishSnapSync(); err != nil {
+		return err
+	} else if rejected {
+		log.Warn("Pivot state rejected by the next block, falling back to full sync")
+		if err := s.abortSnapSync(); err != nil {
+			return err
+		}
+		return s.roundRobinSync(highestExpectedBlockNr)
+	}
+	return nil
 }
 
 // Stop initial sync.
@@ -97,18 +156,25 @@ func (s *Service) Synced() bool {
 func (s *Service) Resync() error {
 	// Set it to false since we are syncing again.
 	s.markSyncing()
-	event.GlobalEvent.Send(common.DownloaderStartEvent{})
-	defer func() {
-		s.markSynced()
-		event.GlobalEvent.Send(common.DownloaderFinishEvent{})
-	}() // Reset it at the end of the method.
+	s.syncStarted()
+	defer s.markSynced() // Reset it at the end of the method.
 	//
 	beforeBlockNr := s.cfg.Chain.CurrentBlock().Number64()
 	highestExpectedBlockNr := s.waitForMinimumPeers()
+	s.progress.start(beforeBlockNr.Uint64(), highestExpectedBlockNr.Uint64())
+	if err := s.fetchTrustedHeaders(); err != nil {
+		if errors.Is(s.ctx.Err(), context.Canceled) {
+			s.syncFailed(s.ctx.Err())
+			return s.ctx.Err()
+		}
+		log.Warn("Could not fetch the checkpoint header chain, verifying every header", "checkpoint", s.trustedAnchor(), "err", err)
+	}
 	if err := s.roundRobinSync(highestExpectedBlockNr); err != nil {
 		log.Error("Resync fail", "err", err, "highestExpectedBlockNr", highestExpectedBlockNr, "currentNr", s.cfg.Chain.CurrentBlock().Number64(), "beforeResyncBlockNr", beforeBlockNr)
+		s.syncFailed(err)
 		return err
 	}
+	s.syncFinished()
 	//
 	log.Info("Resync attempt complete", "highestExpectedBlockNr", highestExpectedBlockNr, "currentNr", s.cfg.Chain.CurrentBlock().Number64(), "beforeResyncBlockNr", beforeBlockNr)
 	return nil
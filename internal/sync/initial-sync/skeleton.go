@@ -0,0 +1,277 @@
+package initialsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/api/protocol/types_pb"
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/utils"
+	"github.com/holiman/uint256"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pkg/errors"
+)
+
+const (
+	// skeletonSegmentSize is the number of headers between two skeleton headers.
+	skeletonSegmentSize = 256
+	// skeletonSegments is the number of skeleton headers requested per round.
+	skeletonSegments = 64
+	// maxSkeletonWorkers caps the segments downloaded at the same time.
+	maxSkeletonWorkers = 8
+	// maxSegmentAttempts is the number of peers tried for a segment.
+	maxSegmentAttempts = 4
+)
+
+var (
+	errEmptySkeleton  = errors.New("pivot peer returned no skeleton headers")
+	errSegmentInvalid = errors.New("segment doesn't match the skeleton")
+)
+
+// skeletonSegment is the part of the chain between two skeleton headers.
+type skeletonSegment struct {
+	index  int
+	from   uint64
+	parent types.Hash     // hash the first header of the segment links to
+	last   *block2.Header // skeleton header closing the segment
+	blocks []*types_pb.Block
+	err    error
+}
+
+// skeletonSync downloads whole segments of the chain in parallel.
+//
+// A round asks the pivot peer, the highest one, for every skeletonSegmentSize-th
+// header. The segments between them are then filled by all the peers at once:
+// each worker fetches the headers of a segment, checks that they link to the
+// skeleton on both ends and streams the matching bodies, possibly from other
+// peers. Segments are inserted in order as soon as they are complete, while
+// the following ones are still downloading. Peers are handed out by a pool
+// ordered by their measured throughput, so a slow peer holds up one request
+// instead of the whole round. Receipts aren't downloaded, they are produced by
+// executing the blocks.
+//
+// Only whole segments are synced, the blocks past the last skeleton header are
+// left to the blocks queue, which also takes over if the peers don't serve
+// headers.
+func (s *Service) skeletonSync(ctx context.Context, target *uint256.Int) error {
+	for {
+		head := s.cfg.Chain.CurrentBlock()
+		number := head.Number64().Uint64()
+		if number+skeletonSegmentSize > target.Uint64() {
+			return nil
+		}
+		_, peers := s.cfg.P2P.Peers().BestPeers(len(s.cfg.P2P.Peers().Connected()), head.Number64())
+		if len(peers) == 0 {
+			return errNoPeersAvailable
+		}
+		count := (target.Uint64() - number) / skeletonSegmentSize
+		if count > skeletonSegments {
+			count = skeletonSegments
+		}
+		skeleton, err := amcsync.SendHeadersByRangeRequest(ctx, s.cfg.P2P, peers[0], &sync_pb.HeadersByRangeRequest{
+			StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(number + skeletonSegmentSize)),
+			Count:            count,
+			Step:             skeletonSegmentSize,
+		})
+		if err != nil {
+			return err
+		}
+		if len(skeleton) == 0 {
+			return errEmptySkeleton
+		}
+		// Fill with the peers able to serve the whole skeleton.
+		_, peers = s.cfg.P2P.Peers().BestPeers(len(peers), uint256.NewInt(skeleton[len(skeleton)-1].Number64().Uint64()-1))
+		if len(peers) == 0 {
+			return errNoPeersAvailable
+		}
+		if err := s.fillSkeleton(ctx, head.Hash(), number+1, skeleton, peers); err != nil {
+			return err
+		}
+	}
+}
+
+// fillSkeleton downloads and inserts the segments closed by the skeleton headers.
+func (s *Service) fillSkeleton(ctx context.Context, parent types.Hash, from uint64, skeleton []*block2.Header, peers []peer.ID) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segments := newSkeletonSegments(parent, from, skeleton)
+	tasks := make(chan *skeletonSegment, len(segments))
+	for _, segment := range segments {
+		tasks <- segment
+	}
+	close(tasks)
+
+	workers := maxSkeletonWorkers
+	if len(peers) < workers {
+		workers = len(peers)
+	}
+	pool := newPeerPool(s.cfg.P2P.Peers().Scorers().DownloadScorer(), peers)
+	results := make(chan *skeletonSegment, len(segments))
+	for i := 0; i < workers; i++ {
+		go func() {
+			for segment := range tasks {
+				if ctx.Err() != nil {
+					return
+				}
+				segment.blocks, segment.err = s.fillSegment(ctx, segment, pool)
+				results <- segment
+			}
+		}()
+	}
+
+	done := make(map[int]*skeletonSegment)
+	for next := 0; next < len(segments); {
+		select {
+		case segment := <-results:
+			done[segment.index] = segment
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		for ; next < len(segments) && done[next] != nil; next++ {
+			segment := done[next]
+			delete(done, next)
+			if segment.err != nil {
+				return fmt.Errorf("segment #%d-#%d: %w", segment.from, segment.last.Number64().Uint64(), segment.err)
+			}
+			if _, err := s.processBatchedBlocks(ctx, segment.blocks, s.cfg.Chain.InsertChain); err != nil && !errors.Is(err, errBlockAlreadyProcessed) {
+				return err
+			}
+			if s.cfg.Chain.CurrentBlock().Number64().Uint64() < segment.last.Number64().Uint64() {
+				return fmt.Errorf("segment #%d-#%d wasn't fully inserted", segment.from, segment.last.Number64().Uint64())
+			}
+		}
+	}
+	return nil
+}
+
+// newSkeletonSegments splits the chain from the given block on into the
+// segments closed by the skeleton headers.
+func newSkeletonSegments(parent types.Hash, from uint64, skeleton []*block2.Header) []*skeletonSegment {
+	segments := make([]*skeletonSegment, len(skeleton))
+	for i, last := range skeleton {
+		segments[i] = &skeletonSegment{index: i, from: from, parent: parent, last: last}
+		from, parent = last.Number64().Uint64()+1, last.Hash()
+	}
+	return segments
+}
+
+// fillSegment fetches the headers of a segment from the best idle peer and the
+// bodies from whichever peers are idle next, moving on to other peers on failures.
+func (s *Service) fillSegment(ctx context.Context, segment *skeletonSegment, pool *peerPool) ([]*types_pb.Block, error) {
+	var (
+		failed peer.ID
+		err    error
+	)
+	for attempt := 0; attempt < maxSegmentAttempts; attempt++ {
+		pid, perr := pool.acquire(ctx, failed)
+		if perr != nil {
+			return nil, perr
+		}
+		started := time.Now()
+		var headers []*block2.Header
+		headers, err = s.fetchSegmentHeaders(ctx, segment, pid)
+		pool.release(pid, len(headers), started, err)
+		if err != nil {
+			log.Debug("Could not fetch segment headers", "peer", pid, "from", segment.from, "err", err)
+			failed = pid
+			continue
+		}
+		var blocks []*types_pb.Block
+		if blocks, err = s.fetchSegmentBodies(ctx, segment, headers, pool); err == nil {
+			return blocks, nil
+		}
+		log.Debug("Could not fetch segment bodies", "from", segment.from, "err", err)
+	}
+	return nil, err
+}
+
+// fetchSegmentHeaders requests the headers of a segment and checks they link
+// the previous skeleton header to the closing one.
+func (s *Service) fetchSegmentHeaders(ctx context.Context, segment *skeletonSegment, pid peer.ID) ([]*block2.Header, error) {
+	count := segment.last.Number64().Uint64() - segment.from + 1
+	headers, err := amcsync.SendHeadersByRangeRequest(ctx, s.cfg.P2P, pid, &sync_pb.HeadersByRangeRequest{
+		StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(segment.from)),
+		Count:            count,
+		Step:             1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSegmentHeaders(segment, headers); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// checkSegmentHeaders checks that the headers fill the whole segment, linking
+// the previous skeleton header to the closing one.
+func checkSegmentHeaders(segment *skeletonSegment, headers []*block2.Header) error {
+	if uint64(len(headers)) != segment.last.Number64().Uint64()-segment.from+1 {
+		return errSegmentInvalid
+	}
+	parent := segment.parent
+	for _, header := range headers {
+		if header.ParentHash != parent {
+			return errSegmentInvalid
+		}
+		parent = header.Hash()
+	}
+	if parent != segment.last.Hash() {
+		return errSegmentInvalid
+	}
+	return nil
+}
+
+// fetchSegmentBodies downloads the blocks of a segment in batches spread over
+// the idle peers, checking every block against its verified header.
+func (s *Service) fetchSegmentBodies(ctx context.Context, segment *skeletonSegment, headers []*block2.Header, pool *peerPool) ([]*types_pb.Block, error) {
+	var failed peer.ID
+
+	batch := s.cfg.P2P.GetConfig().P2PLimit.BlockBatchLimit
+	blocks := make([]*types_pb.Block, 0, len(headers))
+	for n := 0; len(blocks) < len(headers); n++ {
+		if n >= len(headers)/batch+maxSegmentAttempts {
+			return nil, fmt.Errorf("too many failed body requests from #%d", segment.from+uint64(len(blocks)))
+		}
+		pid, err := pool.acquire(ctx, failed)
+		if err != nil {
+			return nil, err
+		}
+		count := len(headers) - len(blocks)
+		if count > batch {
+			count = batch
+		}
+		started := time.Now()
+		blks, err := amcsync.SendBodiesByRangeRequest(ctx, s.cfg.Chain, s.cfg.P2P, pid, &sync_pb.BodiesByRangeRequest{
+			StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(segment.from + uint64(len(blocks)))),
+			Count:            uint64(count),
+			Step:             1,
+		}, nil)
+		if err != nil {
+			pool.release(pid, 0, started, err)
+			log.Debug("Could not request segment bodies", "peer", pid, "err", err)
+			failed = pid
+			continue
+		}
+		delivered := 0
+		for _, blk := range blks {
+			header := new(block2.Header)
+			if blk.Header == nil || header.FromProtoMessage(blk.Header) != nil || header.Hash() != headers[len(blocks)].Hash() {
+				err = errSegmentInvalid
+				break
+			}
+			blocks = append(blocks, blk)
+			delivered++
+		}
+		pool.release(pid, delivered, started, err)
+		if err != nil || delivered == 0 {
+			failed = pid
+		}
+	}
+	return blocks, nil
+}
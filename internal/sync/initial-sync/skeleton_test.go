@@ -0,0 +1,65 @@
+package initialsync
+
+import (
+	"errors"
+	"testing"
+
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/holiman/uint256"
+)
+
+// skeletonTestChain returns the linked headers from one up to the given number.
+func skeletonTestChain(genesis types.Hash, length int) []*block2.Header {
+	headers := make([]*block2.Header, length)
+	parent := genesis
+	for i := range headers {
+		headers[i] = &block2.Header{
+			ParentHash: parent,
+			Number:     uint256.NewInt(uint64(i + 1)),
+			Difficulty: uint256.NewInt(2),
+			BaseFee:    uint256.NewInt(0),
+		}
+		parent = headers[i].Hash()
+	}
+	return headers
+}
+
+func TestSkeletonSegments(t *testing.T) {
+	genesis := types.Hash{0x01}
+	chain := skeletonTestChain(genesis, 12)
+	skeleton := []*block2.Header{chain[3], chain[7], chain[11]}
+
+	segments := newSkeletonSegments(genesis, 1, skeleton)
+	if len(segments) != len(skeleton) {
+		t.Fatalf("%d segments, want %d", len(segments), len(skeleton))
+	}
+	for i, segment := range segments {
+		from := uint64(4*i + 1)
+		if segment.index != i || segment.from != from || segment.last != skeleton[i] {
+			t.Errorf("segment %d: index %d from %d", i, segment.index, segment.from)
+		}
+		if err := checkSegmentHeaders(segment, chain[from-1:from+3]); err != nil {
+			t.Errorf("segment %d rejected: %v", i, err)
+		}
+	}
+	if segments[0].parent != genesis || segments[1].parent != chain[3].Hash() || segments[2].parent != chain[7].Hash() {
+		t.Error("segments don't link to the previous skeleton header")
+	}
+
+	// Headers not filling the segment between the skeleton headers.
+	forked := skeletonTestChain(types.Hash{0x02}, 12)
+	broken := append(append([]*block2.Header{}, chain[4:5]...), forked[5], chain[6], chain[7])
+	tests := map[string][]*block2.Header{
+		"short":         chain[4:7],
+		"long":          chain[4:9],
+		"other segment": chain[0:4],
+		"broken link":   broken,
+		"other chain":   forked[4:8],
+	}
+	for name, headers := range tests {
+		if err := checkSegmentHeaders(segments[1], headers); !errors.Is(err, errSegmentInvalid) {
+			t.Errorf("%s: error %v, want %v", name, err, errSegmentInvalid)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package initialsync
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/changeset"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/utils"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pkg/errors"
+)
+
+const (
+	// stateRangeCount is the number of entries requested per state range.
+	stateRangeCount = 16384
+	// maxHealRounds bounds the changes requests needed to reach a single head.
+	maxHealRounds = 1024
+	// maxBlockRetries is the number of failed block requests tolerated in a row.
+	maxBlockRetries = 8
+)
+
+var errSnapUnsupported = errors.New("chain doesn't support snap sync")
+
+// snapChain is implemented by block chains able to import blocks below a
+// downloaded state.
+type snapChain interface {
+	InsertBlocksWithoutState(chain []block2.IBlock) (int, error)
+	SetPivot(hash types.Hash) error
+	ResetToGenesis(resetState func(tx kv.RwTx) error) error
+}
+
+// Snap sync brings an empty node to a recent block without executing the chain:
+//
+//  1. The state tables are downloaded range by range from a single peer. Its
+//     head moves on while the ranges are served, so they are read at different
+//     blocks.
+//  2. The state is healed: the peer returns the current value of every entry
+//     changed since the oldest of those blocks, round after round, until one
+//     response reaches its head. That head becomes the pivot.
+//  3. The blocks up to the pivot are downloaded and their headers verified,
+//     then the head is moved to the pivot and full sync takes over.
+//
+// State roots only commit to the accounts touched by a block, so the state
+// itself can't be proven against the pivot header. It is trusted until a
+// block on top of the pivot executes against it; if none does, or anything
+// else fails, the node restores the genesis state and syncs in full.
+
+// snapSync runs the state download and pivot import. Every step records its
+// progress in the snap sync table along with the data, so that a restarted
+// node resumes the download where it stopped.
+func (s *Service) snapSync() error {
+	chain, ok := s.cfg.Chain.(snapChain)
+	if !ok {
+		return errSnapUnsupported
+	}
+	var (
+		number  uint64
+		hash    types.Hash
+		started bool
+	)
+	if err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) (err error) {
+		if number, hash, started, err = rawdb.ReadSnapSyncProgress(tx); err != nil || started {
+			return err
+		}
+		if err := keepGenesisState(tx); err != nil {
+			return err
+		}
+		if err := rawdb.WriteSnapSyncRange(tx, 0, nil, math.MaxUint64); err != nil {
+			return err
+		}
+		return rawdb.WriteSnapSyncProgress(tx, 0, types.Hash{})
+	}); err != nil {
+		return err
+	}
+
+	if number == 0 {
+		// A single peer serves the whole state, take the fastest of the highest ones.
+		_, peers := s.cfg.P2P.Peers().BestPeers(s.cfg.P2P.GetConfig().MinSyncPeers, s.cfg.Chain.CurrentBlock().Number64())
+		peers = s.cfg.P2P.Peers().Scorers().DownloadScorer().Sorted(peers)
+		if len(peers) == 0 {
+			return errors.New("no peer to download state from")
+		}
+		pid := peers[0]
+		if started {
+			log.Info("Resuming snap sync", "peer", pid)
+			if err := s.cfg.Chain.DB().View(s.ctx, s.progress.restore); err != nil {
+				return err
+			}
+		} else {
+			log.Info("Starting snap sync", "peer", pid)
+		}
+		if err := s.downloadState(pid); err != nil {
+			return err
+		}
+		var err error
+		if number, hash, err = s.healState(pid); err != nil {
+			return err
+		}
+		if err := s.repairState(pid); err != nil {
+			return err
+		}
+		// The pivot block is checked against the trusted header chain on import.
+		if cp := s.cfg.Checkpoint; cp != nil && number < cp.Number {
+			return fmt.Errorf("pivot #%d is below the sync checkpoint %s", number, cp)
+		}
+		if err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+			return rawdb.WriteSnapSyncProgress(tx, number, hash)
+		}); err != nil {
+			return err
+		}
+		log.Info("Downloaded pivot state", "number", number, "hash", hash)
+	}
+
+	if err := s.downloadPivotBlocks(chain, number, hash); err != nil {
+		return err
+	}
+	return chain.SetPivot(hash)
+}
+
+// downloadState fetches the range tables from where the download stopped,
+// then hands over to healing from the oldest head a range was read at.
+func (s *Service) downloadState(pid peer.ID) error {
+	var (
+		table, from uint64
+		origin      []byte
+		ranging     bool
+	)
+	if err := s.cfg.Chain.DB().View(s.ctx, func(tx kv.Tx) (err error) {
+		table, origin, from, ranging, err = rawdb.ReadSnapSyncRange(tx)
+		return err
+	}); err != nil || !ranging {
+		return err
+	}
+	scorer := s.cfg.P2P.Peers().Scorers().DownloadScorer()
+	for table < amcsync.RangeTables {
+		started := time.Now()
+		resp, entries, err := amcsync.SendStateRangeRequest(s.ctx, s.cfg.P2P, pid, &sync_pb.StateRangeRequest{
+			Table:  table,
+			Count:  stateRangeCount,
+			Origin: origin,
+		})
+		scorer.Delivered(pid, len(entries), time.Since(started))
+		if err != nil {
+			if amcsync.IsTimeout(err) {
+				scorer.Timeout(pid)
+			}
+			return err
+		}
+		if err := checkStateRange(table, origin, entries); err != nil {
+			scorer.Garbage(pid)
+			return err
+		}
+		if !resp.Complete && len(entries) == 0 {
+			return amcsync.ErrInvalidFetchedData
+		}
+		if resp.Head < from {
+			from = resp.Head
+		}
+		s.progress.target(resp.Head)
+		if resp.Complete {
+			log.Info("Downloaded state table", "table", amcsync.StateTables[table])
+			table, origin = table+1, nil
+		} else {
+			origin = append(types.CopyBytes(entries[len(entries)-1].Key), 0)
+			log.Debug("Downloaded state range", "table", amcsync.StateTables[table], "entries", len(entries), "head", resp.Head)
+		}
+		if err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+			if err := putStateEntries(tx, entries); err != nil {
+				return err
+			}
+			s.progress.synced(entries)
+			if table == amcsync.RangeTables {
+				if err := rawdb.DeleteSnapSyncRange(tx); err != nil {
+					return err
+				}
+				return rawdb.WriteSnapSyncHeal(tx, from)
+			}
+			return rawdb.WriteSnapSyncRange(tx, table, origin, from)
+		}); err != nil {
+			return err
+		}
+		s.publishProgress()
+	}
+	return nil
+}
+
+// healState applies the changes made since the recorded heal block until a
+// single response covers the peer's head, returning that head.
+func (s *Service) healState(pid peer.ID) (uint64, types.Hash, error) {
+	var from uint64
+	if err := s.cfg.Chain.DB().View(s.ctx, func(tx kv.Tx) (err error) {
+		from, _, err = rawdb.ReadSnapSyncHeal(tx)
+		return err
+	}); err != nil {
+		return 0, types.Hash{}, err
+	}
+	scorer := s.cfg.P2P.Peers().Scorers().DownloadScorer()
+	for round := 0; round < maxHealRounds; round++ {
+		started := time.Now()
+		resp, entries, err := amcsync.SendStateChangesRequest(s.ctx, s.cfg.P2P, pid, &sync_pb.StateChangesRequest{From: from})
+		scorer.Delivered(pid, len(entries), time.Since(started))
+		if err != nil {
+			if amcsync.IsTimeout(err) {
+				scorer.Timeout(pid)
+			}
+			return 0, types.Hash{}, err
+		}
+		if resp.Covered < from || resp.Covered > resp.Head || (resp.Covered == from && resp.Head > from) {
+			scorer.Garbage(pid)
+			return 0, types.Hash{}, amcsync.ErrInvalidFetchedData
+		}
+		s.progress.target(resp.Head)
+		if !resp.Complete {
+			if err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+				if err := putStateEntries(tx, entries); err != nil {
+					return err
+				}
+				return rawdb.WriteSnapSyncHeal(tx, resp.Covered)
+			}); err != nil {
+				return 0, types.Hash{}, err
+			}
+			s.progress.healed(entries, resp.Head-resp.Covered)
+			log.Info("Healing state", "changes", len(entries), "covered", resp.Covered, "head", resp.Head)
+			from = resp.Covered
+			continue
+		}
+		// The small tables come whole with the final response.
+		if err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+			for _, table := range amcsync.StateTables[amcsync.RangeTables:] {
+				if err := tx.ClearBucket(table); err != nil {
+					return err
+				}
+			}
+			return putStateEntries(tx, entries)
+		}); err != nil {
+			return 0, types.Hash{}, err
+		}
+		s.progress.healed(entries, 0)
+		return resp.Head, resp.HeadHash, nil
+	}
+	return 0, types.Hash{}, errors.New("state heal didn't reach the peer's head")
+}
+
+// downloadPivotBlocks imports the blocks up to the pivot without executing them,
+// starting after the last batch imported before a restart.
+func (s *Service) downloadPivotBlocks(chain snapChain, number uint64, hash types.Hash) error {
+	var imported uint64
+	if err := s.cfg.Chain.DB().View(s.ctx, func(tx kv.Tx) (err error) {
+		imported, _, err = rawdb.ReadSnapSyncBlocks(tx)
+		return err
+	}); err != nil {
+		return err
+	}
+	scorer := s.cfg.P2P.Peers().Scorers().DownloadScorer()
+	batch := uint64(s.cfg.P2P.GetConfig().P2PLimit.BlockBatchLimit)
+	s.highestExpectedBlockNr = uint256.NewInt(number)
+	s.progress.target(number)
+
+	for start, failures := imported+1, 0; start <= number; {
+		count := batch
+		if remaining := number - start + 1; remaining < count {
+			count = remaining
+		}
+		_, peers := s.cfg.P2P.Peers().BestPeers(s.cfg.P2P.GetConfig().MinSyncPeers, uint256.NewInt(number-1))
+		peers = scorer.Sorted(peers)
+		if len(peers) == 0 {
+			return errors.New("no peer to download pivot blocks from")
+		}
+		// Stick to the fastest peer, moving down the list on failures.
+		pid := peers[failures%len(peers)]
+		started := time.Now()
+		blks, err := amcsync.SendBodiesByRangeRequest(s.ctx, s.cfg.Chain, s.cfg.P2P, pid, &sync_pb.BodiesByRangeRequest{
+			StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(start)),
+			Count:            count,
+			Step:             1,
+		}, nil)
+		scorer.Delivered(pid, len(blks), time.Since(started))
+		if err != nil || len(blks) == 0 {
+			if failures++; failures > maxBlockRetries {
+				return fmt.Errorf("failed to download block #%d: %v", start, err)
+			}
+			continue
+		}
+		failures = 0
+
+		blocks := make([]block2.IBlock, 0, len(blks))
+		for _, blk := range blks {
+			block := new(block2.Block)
+			if err := block.FromProtoMessage(blk); err != nil {
+				return err
+			}
+			blocks = append(blocks, block)
+		}
+		if blocks[0].Number64().Uint64() != start {
+			return amcsync.ErrInvalidFetchedData
+		}
+		s.logBatchSyncStatus(blks)
+		n, err := chain.InsertBlocksWithoutState(blocks)
+		if err != nil {
+			scorer.Garbage(pid)
+			return err
+		}
+		start += uint64(n)
+		if err := s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+			return rawdb.WriteSnapSyncBlocks(tx, start-1)
+		}); err != nil {
+			return err
+		}
+		s.progress.reached(start - 1)
+	}
+	if header := s.cfg.Chain.GetHeaderByNumber(uint256.NewInt(number)); header == nil || header.Hash() != hash {
+		return fmt.Errorf("pivot block #%d is not %s", number, hash)
+	}
+	return nil
+}
+
+// finishSnapSync drops the snap sync records once a block was executed on top
+// of the pivot. It reports whether the pivot state has to be given up.
+func (s *Service) finishSnapSync() (bool, error) {
+	var (
+		pivot uint64
+		ok    bool
+	)
+	if err := s.cfg.Chain.DB().View(s.ctx, func(tx kv.Tx) (err error) {
+		pivot, _, ok, err = rawdb.ReadSnapSyncProgress(tx)
+		return err
+	}); err != nil || !ok {
+		return false, err
+	}
+	current := s.cfg.Chain.CurrentBlock().Number64().Uint64()
+	if current > pivot {
+		log.Info("Snap sync finished", "pivot", pivot, "head", current)
+		return false, s.cfg.Chain.DB().Update(s.ctx, func(tx kv.RwTx) error {
+			// No state history was recorded up to the pivot.
+			if err := rawdb.WriteStatePruneProgress(tx, pivot+1); err != nil {
+				return err
+			}
+			return tx.ClearBucket(modules.SnapSync)
+		})
+	}
+	best, _ := s.cfg.P2P.Peers().BestPeers(1, uint256.NewInt(current))
+	return best.Uint64() > pivot, nil
+}
+
+// snapSyncProgress returns the pivot of an unfinished snap sync, zero while
+// the state is still being downloaded.
+func (s *Service) snapSyncProgress() (pivot uint64, ok bool, err error) {
+	err = s.cfg.Chain.DB().View(s.ctx, func(tx kv.Tx) (err error) {
+		pivot, _, ok, err = rawdb.ReadSnapSyncProgress(tx)
+		return err
+	})
+	return pivot, ok, err
+}
+
+// abortSnapSync rewinds the chain to the genesis state. It does nothing unless
+// the genesis state was kept, so that a failure before the download can't
+// wipe it.
+func (s *Service) abortSnapSync() error {
+	chain, ok := s.cfg.Chain.(snapChain)
+	if !ok {
+		return errSnapUnsupported
+	}
+	if _, kept, err := s.snapSyncProgress(); err != nil || !kept {
+		return err
+	}
+	return chain.ResetToGenesis(restoreGenesisState)
+}
+
+func putStateEntries(tx kv.RwTx, entries []amcsync.StateEntry) error {
+	for _, entry := range entries {
+		table := amcsync.StateTables[entry.Table]
+		if len(entry.Value) == 0 {
+			if err := tx.Delete(table, entry.Key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Put(table, entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStateRange verifies a range response is ordered and starts at origin.
+func checkStateRange(table uint64, origin []byte, entries []amcsync.StateEntry) error {
+	prev := origin
+	for i, entry := range entries {
+		if entry.Table != table || len(entry.Value) == 0 {
+			return amcsync.ErrInvalidFetchedData
+		}
+		if cmp := bytes.Compare(entry.Key, prev); cmp < 0 || (cmp == 0 && i > 0) {
+			return amcsync.ErrInvalidFetchedData
+		}
+		prev = entry.Key
+	}
+	return nil
+}
+
+// keepGenesisState copies the genesis state into the snap sync table and
+// empties the state tables for the download.
+func keepGenesisState(tx kv.RwTx) error {
+	if err := tx.ClearBucket(modules.SnapSync); err != nil {
+		return err
+	}
+	for id, table := range amcsync.StateTables {
+		if err := tx.ForEach(table, nil, func(k, v []byte) error {
+			return rawdb.WriteSnapSyncGenesis(tx, uint8(id), k, v)
+		}); err != nil {
+			return err
+		}
+		if err := tx.ClearBucket(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreGenesisState puts back the genesis state kept by keepGenesisState and
+// drops the changesets of the blocks executed on top of a rejected pivot.
+func restoreGenesisState(tx kv.RwTx) error {
+	for _, table := range amcsync.StateTables {
+		if err := tx.ClearBucket(table); err != nil {
+			return err
+		}
+	}
+	if err := rawdb.ForEachSnapSyncGenesis(tx, func(id uint8, key, value []byte) error {
+		if int(id) >= len(amcsync.StateTables) {
+			return fmt.Errorf("invalid kept genesis table %d", id)
+		}
+		return tx.Put(amcsync.StateTables[id], key, value)
+	}); err != nil {
+		return err
+	}
+	if err := changeset.Truncate(tx, 1); err != nil {
+		return err
+	}
+	return tx.ClearBucket(modules.SnapSync)
+}
@@ -0,0 +1,302 @@
+package initialsync
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	amcsync "github.com/amazechain/amc/internal/sync"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// accountsTable is the id of the account table in amcsync.StateTables.
+const accountsTable = 0
+
+// serveRange returns up to count entries of the account table from origin
+// on, as a peer serves them.
+func serveRange(t *testing.T, tx kv.Tx, origin []byte, count int) []amcsync.StateEntry {
+	c, err := tx.Cursor(modules.Account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var entries []amcsync.StateEntry
+	for k, v, err := c.Seek(origin); k != nil && len(entries) < count; k, v, err = c.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, amcsync.StateEntry{Table: accountsTable, Key: types.CopyBytes(k), Value: types.CopyBytes(v)})
+	}
+	return entries
+}
+
+func TestDownloadStateRanges(t *testing.T) {
+	_, source := memdb.NewTestTx(t)
+	for i := 0; i < 10; i++ {
+		addr := types.Address{byte(i + 1)}
+		if err := source.Put(modules.Account, addr[:], []byte{0xac, byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Download the table in ranges, checking every one of them.
+	_, dest := memdb.NewTestTx(t)
+	var origin []byte
+	for {
+		entries := serveRange(t, source, origin, 3)
+		if len(entries) == 0 {
+			break
+		}
+		if err := checkStateRange(accountsTable, origin, entries); err != nil {
+			t.Fatalf("range from %x rejected: %v", origin, err)
+		}
+		if err := putStateEntries(dest, entries); err != nil {
+			t.Fatal(err)
+		}
+		origin = append(types.CopyBytes(entries[len(entries)-1].Key), 0)
+	}
+	if err := source.ForEach(modules.Account, nil, func(k, v []byte) error {
+		got, err := dest.GetOne(modules.Account, k)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, v) {
+			t.Errorf("account %x: %x, want %x", k, got, v)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Healing deletes the entries served without a value.
+	deleted := types.Address{0x01}
+	if err := putStateEntries(dest, []amcsync.StateEntry{{Table: accountsTable, Key: deleted[:]}}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := dest.Has(modules.Account, deleted[:]); err != nil || ok {
+		t.Errorf("deleted account still stored: %v, %v", ok, err)
+	}
+}
+
+func TestCheckStateRangeTampered(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	for i := 0; i < 5; i++ {
+		addr := types.Address{byte(i + 1)}
+		if err := tx.Put(modules.Account, addr[:], []byte{0xac, byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	origin := types.Address{0x02}
+	tests := []struct {
+		name   string
+		tamper func(entries []amcsync.StateEntry) []amcsync.StateEntry
+		valid  bool
+	}{
+		{"served range", func(entries []amcsync.StateEntry) []amcsync.StateEntry {
+			return entries
+		}, true},
+		{"truncated range", func(entries []amcsync.StateEntry) []amcsync.StateEntry {
+			return entries[:1]
+		}, true},
+		{"reordered", func(entries []amcsync.StateEntry) []amcsync.StateEntry {
+			entries[0], entries[1] = entries[1], entries[0]
+			return entries
+		}, false},
+		{"duplicate key", func(entries []amcsync.StateEntry) []amcsync.StateEntry {
+			entries[1].Key = entries[0].Key
+			return entries
+		}, false},
+		{"before the origin", func(entries []amcsync.StateEntry) []amcsync.StateEntry {
+			addr := types.Address{0x01}
+			return append([]amcsync.StateEntry{{Table: accountsTable, Key: addr[:], Value: []byte{0xac}}}, entries...)
+		}, false},
+		{"other table", func(entries []amcsync.StateEntry) []amcsync.StateEntry {
+			entries[1].Table = accountsTable + 1
+			return entries
+		}, false},
+		{"deletion", func(entries []amcsync.StateEntry) []amcsync.StateEntry {
+			entries[2].Value = nil
+			return entries
+		}, false},
+	}
+	for _, tt := range tests {
+		entries := tt.tamper(serveRange(t, tx, origin[:], 3))
+		err := checkStateRange(accountsTable, origin[:], entries)
+		if tt.valid && err != nil {
+			t.Errorf("%s: rejected: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
+
+func countEntries(t *testing.T, tx kv.Tx, table string) int {
+	var n int
+	if err := tx.ForEach(table, nil, func(k, v []byte) error {
+		n++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestKeepGenesisState(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	genesis := map[string]map[string]string{
+		modules.Account: {"\x01": "genesis account", "\x02": "faucet"},
+		modules.Code:    {"\x03": "genesis code"},
+	}
+	for table, entries := range genesis {
+		for k, v := range entries {
+			if err := tx.Put(table, []byte(k), []byte(v)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := keepGenesisState(tx); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range amcsync.StateTables {
+		if n := countEntries(t, tx, table); n != 0 {
+			t.Fatalf("%s holds %d entries for the download", table, n)
+		}
+	}
+	// A rejected pivot leaves downloaded state behind.
+	downloaded := []amcsync.StateEntry{
+		{Table: accountsTable, Key: []byte{0x01}, Value: []byte("synced account")},
+		{Table: accountsTable, Key: []byte{0x04}, Value: []byte("other account")},
+		{Table: 1, Key: []byte{0x05}, Value: []byte("synced slot")},
+	}
+	if err := putStateEntries(tx, downloaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restoreGenesisState(tx); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range amcsync.StateTables {
+		var n int
+		if err := tx.ForEach(table, nil, func(k, v []byte) error {
+			if want, ok := genesis[table][string(k)]; !ok || want != string(v) {
+				t.Errorf("%s: entry %x = %q after the restore, want %q", table, k, v, want)
+			}
+			n++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if n != len(genesis[table]) {
+			t.Errorf("%s: %d entries after the restore, want %d", table, n, len(genesis[table]))
+		}
+	}
+	if n := countEntries(t, tx, modules.SnapSync); n != 0 {
+		t.Errorf("snap sync table holds %d entries after the restore", n)
+	}
+}
+
+// snapTestChain records the resets of a chain importing blocks below a
+// downloaded state.
+type snapTestChain struct {
+	*checkpointTestChain
+	resets int
+}
+
+func (c *snapTestChain) InsertBlocksWithoutState(chain []block2.IBlock) (int, error) {
+	return 0, errSnapUnsupported
+}
+
+func (c *snapTestChain) SetPivot(hash types.Hash) error {
+	return errSnapUnsupported
+}
+
+func (c *snapTestChain) ResetToGenesis(resetState func(tx kv.RwTx) error) error {
+	c.resets++
+	return c.db.Update(context.Background(), resetState)
+}
+
+func TestFinishSnapSync(t *testing.T) {
+	s, chain := newCheckpointTestService(t, nil)
+
+	// Nothing to finish without a snap sync.
+	if abort, err := s.finishSnapSync(); err != nil || abort {
+		t.Fatalf("finished a sync that didn't run: %v, %v", abort, err)
+	}
+	if err := chain.db.Update(context.Background(), func(tx kv.RwTx) error {
+		if err := rawdb.WriteSnapSyncProgress(tx, 100, types.Hash{0x01}); err != nil {
+			return err
+		}
+		return rawdb.WriteSnapSyncBlocks(tx, 100)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A block executed on top of the pivot finishes the sync.
+	chain.head = block2.NewBlock(&block2.Header{Number: uint256.NewInt(101), Difficulty: uint256.NewInt(2), BaseFee: uint256.NewInt(0)}, nil)
+	if abort, err := s.finishSnapSync(); err != nil || abort {
+		t.Fatalf("finishing: %v, %v", abort, err)
+	}
+	if err := chain.db.View(context.Background(), func(tx kv.Tx) error {
+		if n := countEntries(t, tx, modules.SnapSync); n != 0 {
+			t.Errorf("snap sync table holds %d entries once finished", n)
+		}
+		if progress, err := rawdb.ReadStatePruneProgress(tx); err != nil || progress != 101 {
+			t.Errorf("state history from #%d, %v, want #101", progress, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAbortSnapSync(t *testing.T) {
+	s, checkpointChain := newCheckpointTestService(t, nil)
+	chain := &snapTestChain{checkpointTestChain: checkpointChain}
+	s.cfg.Chain = chain
+
+	// Without the genesis state kept, there is nothing to restore.
+	if err := s.abortSnapSync(); err != nil || chain.resets != 0 {
+		t.Fatalf("aborted a sync that didn't run: %v, %d resets", err, chain.resets)
+	}
+	genesis := []byte("genesis account")
+	if err := chain.db.Update(context.Background(), func(tx kv.RwTx) error {
+		if err := tx.Put(modules.Account, []byte{0x01}, genesis); err != nil {
+			return err
+		}
+		if err := keepGenesisState(tx); err != nil {
+			return err
+		}
+		if err := rawdb.WriteSnapSyncProgress(tx, 0, types.Hash{}); err != nil {
+			return err
+		}
+		return putStateEntries(tx, []amcsync.StateEntry{{Table: accountsTable, Key: []byte{0x02}, Value: []byte("synced account")}})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.abortSnapSync(); err != nil || chain.resets != 1 {
+		t.Fatalf("aborting: %v, %d resets", err, chain.resets)
+	}
+	if err := chain.db.View(context.Background(), func(tx kv.Tx) error {
+		if v, err := tx.GetOne(modules.Account, []byte{0x01}); err != nil || !bytes.Equal(v, genesis) {
+			t.Errorf("genesis account %q, %v", v, err)
+		}
+		if n := countEntries(t, tx, modules.Account); n != 1 {
+			t.Errorf("%d accounts after the abort, want 1", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, running, err := s.snapSyncProgress(); err != nil || running {
+		t.Errorf("snap sync progress kept after the abort: %v", err)
+	}
+}
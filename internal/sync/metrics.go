@@ -51,6 +51,20 @@ var (
 		},
 		[]string{"topic"},
 	)
+	peerLimitViolationCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "p2p_peer_limit_violation_total",
+			Help: "Count of messages dropped for exceeding a per-peer rate, size or concurrency limit.",
+		},
+		[]string{"topic", "limit"},
+	)
+	invalidGossipCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "p2p_gossip_invalid_message_total",
+			Help: "Count of gossip messages rejected as invalid, by reason.",
+		},
+		[]string{"topic", "reason"},
+	)
 	numberOfTimesResyncedCounter = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "number_of_times_resynced",
@@ -27,3 +27,29 @@ func WithInitialSync(initialSync Checker) Option {
 		return nil
 	}
 }
+
+// WithLightServer serves light clients, up to maxClients of them at a time.
+func WithLightServer(maxClients int) Option {
+	return func(s *Service) error {
+		s.cfg.lightClients = maxClients
+		return nil
+	}
+}
+
+// WithTxPool propagates the transactions of the pool to the peers and takes
+// theirs in.
+func WithTxPool(pool common.ITxsPool) Option {
+	return func(s *Service) error {
+		s.cfg.txPool = pool
+		return nil
+	}
+}
+
+// WithoutGossip leaves the gossip topics alone, for nodes that don't import
+// blocks such as light clients.
+func WithoutGossip() Option {
+	return func(s *Service) error {
+		s.cfg.noGossip = true
+		return nil
+	}
+}
@@ -2,7 +2,9 @@ package sync
 
 import (
 	"fmt"
+	"github.com/amazechain/amc/api/protocol/sync_pb"
 	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/p2p/encoder"
 	leakybucket "github.com/amazechain/amc/internal/p2p/leaky-bucket"
 	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
 	"github.com/amazechain/amc/log"
@@ -11,56 +13,200 @@ import (
 	"sync"
 	"time"
 
+	"github.com/golang/snappy"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/pkg/errors"
 )
 
 const defaultBurstLimit = 5
 
+// headersPerBlock is how many headers a peer may request for the cost of a block.
+const headersPerBlock = 16
+
+// stateRequestsPerSecond is the rate a peer may request state ranges at.
+const stateRequestsPerSecond = 4
+
+// txMessagesPerSecond is the rate a peer may send transaction announcements,
+// bodies and requests at, each on their own.
+const txMessagesPerSecond = 4
+
 const leakyBucketPeriod = 1 * time.Second
 
 // Dummy topic to validate all incoming rpc requests.
 const rpcLimiterTopic = "rpc-limiter-topic"
 
+// maxPeerStreams is the number of rpc requests of a single peer handled at
+// the same time, so that one peer can't hold all the handler goroutines.
+const maxPeerStreams = 4
+
+// rpcRequestSizeLimits caps the encoded size of the request of every rpc
+// topic. The requests are all small messages of bounded size, anything
+// bigger is garbage.
+var rpcRequestSizeLimits = map[string]uint64{
+	p2p.RPCStatusTopicV1:       256,
+	p2p.RPCGoodByeTopicV1:      8,
+	p2p.RPCPingTopicV1:         8,
+	p2p.RPCBodiesDataTopicV1:   128,
+	p2p.RPCHeadersDataTopicV1:  128,
+	p2p.RPCStateRangeTopicV1:   256,
+	p2p.RPCStateChangesTopicV1: 8,
+	p2p.RPCForkIDTopicV1:       12,
+
+	p2p.RPCAccountRangeTopicV1:  48,
+	p2p.RPCStorageRangesTopicV1: 46 + sync_pb.MaxStorageRangeAccounts*20,
+	p2p.RPCByteCodesTopicV1:     12 + sync_pb.MaxByteCodesHashes*32,
+
+	p2p.RPCLightReceiptsTopicV1: 32,
+	p2p.RPCLightAccountTopicV1:  57 + sync_pb.MaxLightStorageKeys*32,
+
+	p2p.RPCTxAnnounceTopicV1: 4 + sync_pb.MaxTxAnnounceHashes*32,
+	p2p.RPCPooledTxsTopicV1:  4 + sync_pb.MaxPooledTxsHashes*32,
+	p2p.RPCTxsTopicV1:        4 + sync_pb.MaxTxsDataSize,
+}
+
+// gossipLimit is the rate a single peer may gossip the messages of a topic
+// at, and the largest message it may send.
+type gossipLimit struct {
+	rate  float64
+	burst int64
+	size  uint64
+}
+
+// gossipLimits holds the limits of the gossip topics, by topic format.
+var gossipLimits = map[string]gossipLimit{
+	p2p.BlockTopicFormat:       {rate: 1, burst: 16, size: encoder.MaxGossipSize},
+	p2p.TransactionTopicFormat: {rate: 200, burst: 1000, size: 128 * 1024},
+}
+
+var errGossipTooLarge = errors.New("gossip message exceeds the topic size limit")
+
 type limiter struct {
 	limiterMap map[string]*leakybucket.Collector
+	gossipMap  map[string]*leakybucket.Collector
 	p2p        p2p.P2P
 	sync.RWMutex
+
+	streamLock sync.Mutex
+	streams    map[peer.ID]int // rpc requests being handled, by peer
 }
 
 // Instantiates a multi-rpc protocol rate limiter, providing
 // separate collectors for each topic.
 func newRateLimiter(p2pProvider p2p.P2P) *limiter {
-	// add encoding suffix
-	addEncoding := func(topic string) string {
-		return topic + p2pProvider.Encoding().ProtocolSuffix()
-	}
-
 	// Initialize block limits.
 	allowedBlocksPerSecond := float64(p2pProvider.GetConfig().P2PLimit.BlockBatchLimit)
 	allowedBlocksBurst := int64(p2pProvider.GetConfig().P2PLimit.BlockBatchLimitBurstFactor * p2pProvider.GetConfig().P2PLimit.BlockBatchLimit)
 
 	blockLimiterPeriod := time.Duration(p2pProvider.GetConfig().P2PLimit.BlockBatchLimiterPeriod) * time.Second
 
-	// Set topic map for all rpc topics.
-	topicMap := make(map[string]*leakybucket.Collector, len(p2p.RPCTopicMappings))
+	// Set topic map for all rpc topics. A topic shares its collector
+	// between the encodings it can be requested with.
+	topicMap := make(map[string]*leakybucket.Collector, len(p2p.RPCTopicMappings)*len(encoder.Encodings))
+	setCollector := func(topic string, collector *leakybucket.Collector) {
+		for _, encoding := range encoder.Encodings {
+			topicMap[topic+encoding.ProtocolSuffix()] = collector
+		}
+	}
 	// Goodbye Message
-	topicMap[addEncoding(p2p.RPCGoodByeTopicV1)] = leakybucket.NewCollector(1, 1, leakyBucketPeriod, false /* deleteEmptyBuckets */)
+	setCollector(p2p.RPCGoodByeTopicV1, leakybucket.NewCollector(1, 1, leakyBucketPeriod, false /* deleteEmptyBuckets */))
 	// Ping Message
-	topicMap[addEncoding(p2p.RPCPingTopicV1)] = leakybucket.NewCollector(1, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */)
+	setCollector(p2p.RPCPingTopicV1, leakybucket.NewCollector(1, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
 	// Status Message
-	topicMap[addEncoding(p2p.RPCStatusTopicV1)] = leakybucket.NewCollector(1, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */)
+	setCollector(p2p.RPCStatusTopicV1, leakybucket.NewCollector(1, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
+	// Fork ID Message
+	setCollector(p2p.RPCForkIDTopicV1, leakybucket.NewCollector(1, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
 
 	// Bodies Message
-	topicMap[addEncoding(p2p.RPCBodiesDataTopicV1)] = leakybucket.NewCollector(allowedBlocksPerSecond, allowedBlocksBurst, blockLimiterPeriod, false /* deleteEmptyBuckets */)
+	setCollector(p2p.RPCBodiesDataTopicV1, leakybucket.NewCollector(allowedBlocksPerSecond, allowedBlocksBurst, blockLimiterPeriod, false /* deleteEmptyBuckets */))
 
 	// Headers Message
-	topicMap[addEncoding(p2p.RPCHeadersDataTopicV1)] = leakybucket.NewCollector(allowedBlocksPerSecond, allowedBlocksBurst, blockLimiterPeriod, false /* deleteEmptyBuckets */)
+	setCollector(p2p.RPCHeadersDataTopicV1, leakybucket.NewCollector(allowedBlocksPerSecond*headersPerBlock, allowedBlocksBurst*headersPerBlock, blockLimiterPeriod, false /* deleteEmptyBuckets */))
+
+	// State sync Messages
+	setCollector(p2p.RPCStateRangeTopicV1, leakybucket.NewCollector(stateRequestsPerSecond, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
+	setCollector(p2p.RPCStateChangesTopicV1, leakybucket.NewCollector(stateRequestsPerSecond, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
+	setCollector(p2p.RPCAccountRangeTopicV1, leakybucket.NewCollector(stateRequestsPerSecond, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
+	setCollector(p2p.RPCStorageRangesTopicV1, leakybucket.NewCollector(stateRequestsPerSecond, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
+	setCollector(p2p.RPCByteCodesTopicV1, leakybucket.NewCollector(stateRequestsPerSecond, defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
+
+	// Light client Messages, charged by request cost out of a buffer shared
+	// between them.
+	light := leakybucket.NewCollector(LightBufferRecharge, LightBufferLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */)
+	setCollector(p2p.RPCLightReceiptsTopicV1, light)
+	setCollector(p2p.RPCLightAccountTopicV1, light)
 
-	// General topic for all rpc requests.
-	topicMap[rpcLimiterTopic] = leakybucket.NewCollector(5, defaultBurstLimit*2, leakyBucketPeriod, false /* deleteEmptyBuckets */)
+	// Transaction propagation Messages
+	setCollector(p2p.RPCTxAnnounceTopicV1, leakybucket.NewCollector(txMessagesPerSecond, txMessagesPerSecond*defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
+	setCollector(p2p.RPCPooledTxsTopicV1, leakybucket.NewCollector(txMessagesPerSecond, txMessagesPerSecond*defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
+	setCollector(p2p.RPCTxsTopicV1, leakybucket.NewCollector(txMessagesPerSecond, txMessagesPerSecond*defaultBurstLimit, leakyBucketPeriod, false /* deleteEmptyBuckets */))
 
-	return &limiter{limiterMap: topicMap, p2p: p2pProvider}
+	// General topic for all rpc requests, with room for the transaction
+	// propagation messages on top of the others.
+	topicMap[rpcLimiterTopic] = leakybucket.NewCollector(5+3*txMessagesPerSecond, defaultBurstLimit*2, leakyBucketPeriod, false /* deleteEmptyBuckets */)
+
+	gossipMap := make(map[string]*leakybucket.Collector, len(gossipLimits))
+	for topic, limit := range gossipLimits {
+		gossipMap[topic] = leakybucket.NewCollector(limit.rate, limit.burst, leakyBucketPeriod, false /* deleteEmptyBuckets */)
+	}
+
+	return &limiter{limiterMap: topicMap, gossipMap: gossipMap, p2p: p2pProvider, streams: make(map[peer.ID]int)}
+}
+
+// violation records a message of the peer dropped for exceeding a limit,
+// which counts as a bad response.
+func (l *limiter) violation(pid peer.ID, topic, limit string) {
+	peerLimitViolationCounter.WithLabelValues(topic, limit).Inc()
+	l.p2p.Peers().Scorers().BadResponsesScorer().Increment(pid)
+}
+
+// acquireStream reserves one of the concurrent requests of a peer, returning
+// false if the peer already has too many in flight.
+func (l *limiter) acquireStream(pid peer.ID) bool {
+	l.streamLock.Lock()
+	defer l.streamLock.Unlock()
+	if l.streams[pid] >= maxPeerStreams {
+		return false
+	}
+	l.streams[pid]++
+	return true
+}
+
+// releaseStream gives back a request reserved by acquireStream.
+func (l *limiter) releaseStream(pid peer.ID) {
+	l.streamLock.Lock()
+	defer l.streamLock.Unlock()
+	if l.streams[pid]--; l.streams[pid] <= 0 {
+		delete(l.streams, pid)
+	}
+}
+
+// validateGossip checks a gossip message of the given topic format against the
+// size and rate limits of its topic, adding it to the peer's bucket if it is
+// within them. Oversized messages are rejected, those beyond the rate ignored.
+func (l *limiter) validateGossip(topic string, pid peer.ID, data []byte) (pubsub.ValidationResult, error) {
+	limit, ok := gossipLimits[topic]
+	if !ok {
+		return pubsub.ValidationAccept, nil
+	}
+	if size, err := snappy.DecodedLen(data); err != nil || uint64(size) > limit.size {
+		l.violation(pid, topic, "size")
+		return pubsub.ValidationReject, errGossipTooLarge
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	collector, ok := l.gossipMap[topic]
+	if !ok {
+		return pubsub.ValidationAccept, nil
+	}
+	if collector.Remaining(pid.String()) < 1 {
+		l.violation(pid, topic, "rate")
+		return pubsub.ValidationIgnore, p2ptypes.ErrRateLimited
+	}
+	collector.Add(pid.String(), 1)
+	return pubsub.ValidationAccept, nil
 }
 
 // Returns the current topic collector for the provided topic.
@@ -95,8 +241,8 @@ func (l *limiter) validateRequest(stream network.Stream, amt uint64) error {
 			"remaining", remaining,
 		)
 
-		l.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
-		writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrRateLimited.Error(), stream, l.p2p)
+		l.violation(stream.Conn().RemotePeer(), topic, "rate")
+		writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrRateLimited.Error(), stream)
 		return p2ptypes.ErrRateLimited
 	}
 	return nil
@@ -118,8 +264,8 @@ func (l *limiter) validateRawRpcRequest(stream network.Stream) error {
 	// Treat each request as a minimum of 1.
 	amt := int64(1)
 	if amt > remaining {
-		l.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
-		writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrRateLimited.Error(), stream, l.p2p)
+		l.violation(stream.Conn().RemotePeer(), topic, "rate")
+		writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrRateLimited.Error(), stream)
 		return p2ptypes.ErrRateLimited
 	}
 	return nil
@@ -177,6 +323,10 @@ func (l *limiter) free() {
 		delete(l.limiterMap, t)
 		tempMap[ptr] = true
 	}
+	for t, collector := range l.gossipMap {
+		collector.Free()
+		delete(l.gossipMap, t)
+	}
 }
 
 // not to be used outside the rate limiter file as it is unsafe for concurrent usage
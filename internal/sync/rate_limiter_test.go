@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/p2p/encoder"
+	"github.com/amazechain/amc/internal/p2p/peers"
+	"github.com/amazechain/amc/internal/p2p/peers/scorers"
+	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
+	"github.com/golang/snappy"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// limiterTestP2P is the part of the p2p service the rate limiter uses.
+type limiterTestP2P struct {
+	p2p.P2P
+	peers *peers.Status
+}
+
+func (p *limiterTestP2P) Peers() *peers.Status { return p.peers }
+
+func (p *limiterTestP2P) GetConfig() *conf.P2PConfig {
+	return &conf.P2PConfig{P2PLimit: &conf.P2PLimit{
+		BlockBatchLimit:            64,
+		BlockBatchLimitBurstFactor: 2,
+		BlockBatchLimiterPeriod:    5,
+	}}
+}
+
+func newTestRateLimiter(t *testing.T) *limiter {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	l := newRateLimiter(&limiterTestP2P{peers: peers.NewStatus(ctx, &peers.StatusConfig{
+		PeerLimit:    10,
+		ScorerParams: &scorers.Config{},
+	})})
+	t.Cleanup(l.free)
+	return l
+}
+
+func TestRPCRequestSizeLimits(t *testing.T) {
+	for topic, msg := range p2p.RPCTopicMappings {
+		limit, ok := rpcRequestSizeLimits[topic]
+		if !ok {
+			t.Errorf("no request size limit for %s", topic)
+			continue
+		}
+		if limit > encoder.MaxChunkSize {
+			t.Errorf("request size limit %d of %s over the chunk size", limit, topic)
+		}
+		if size := msg.(interface{ SizeSSZ() int }).SizeSSZ(); uint64(size) > limit {
+			t.Errorf("smallest request of %s is %d bytes, over its limit of %d", topic, size, limit)
+		}
+	}
+}
+
+func TestLimiterStreams(t *testing.T) {
+	l := newTestRateLimiter(t)
+	busy, other := peer.ID("busy"), peer.ID("other")
+
+	for i := 0; i < maxPeerStreams; i++ {
+		if !l.acquireStream(busy) {
+			t.Fatalf("request %d of the peer refused", i)
+		}
+	}
+	if l.acquireStream(busy) {
+		t.Fatal("request beyond the stream limit accepted")
+	}
+	if !l.acquireStream(other) {
+		t.Fatal("request of another peer refused")
+	}
+	l.releaseStream(busy)
+	if !l.acquireStream(busy) {
+		t.Fatal("request refused after one was released")
+	}
+	for i := 0; i < maxPeerStreams; i++ {
+		l.releaseStream(busy)
+	}
+	l.releaseStream(other)
+	if len(l.streams) != 0 {
+		t.Errorf("streams %v left over after releasing all", l.streams)
+	}
+}
+
+func TestValidateGossip(t *testing.T) {
+	l := newTestRateLimiter(t)
+	scorer := l.p2p.Peers().Scorers().BadResponsesScorer()
+	pid := peer.ID("gossiper")
+
+	// Oversized messages are rejected and count against the peer.
+	limit := gossipLimits[p2p.TransactionTopicFormat]
+	large := snappy.Encode(nil, make([]byte, limit.size+1))
+	if res, err := l.validateGossip(p2p.TransactionTopicFormat, pid, large); res != pubsub.ValidationReject || err != errGossipTooLarge {
+		t.Fatalf("oversized message validated as %v (%v)", res, err)
+	}
+	if res, _ := l.validateGossip(p2p.TransactionTopicFormat, pid, []byte{0xff}); res != pubsub.ValidationReject {
+		t.Errorf("undecodable message validated as %v", res)
+	}
+	if count, _ := scorer.Count(pid); count != 2 {
+		t.Errorf("%d bad responses after two oversized messages, want 2", count)
+	}
+
+	// Messages beyond the burst of the topic are ignored.
+	limit = gossipLimits[p2p.BlockTopicFormat]
+	small := snappy.Encode(nil, make([]byte, 64))
+	for i := int64(0); i < limit.burst; i++ {
+		if res, err := l.validateGossip(p2p.BlockTopicFormat, pid, small); res != pubsub.ValidationAccept {
+			t.Fatalf("message %d within the burst validated as %v (%v)", i, res, err)
+		}
+	}
+	if res, err := l.validateGossip(p2p.BlockTopicFormat, pid, small); res != pubsub.ValidationIgnore || err != p2ptypes.ErrRateLimited {
+		t.Fatalf("message beyond the burst validated as %v (%v)", res, err)
+	}
+	if res, _ := l.validateGossip(p2p.BlockTopicFormat, "other", small); res != pubsub.ValidationAccept {
+		t.Errorf("message of another peer validated as %v", res)
+	}
+	// Topics without limits are let through.
+	if res, _ := l.validateGossip("/amc/unlimited", pid, large); res != pubsub.ValidationAccept {
+		t.Errorf("message of a topic without limits validated as %v", res)
+	}
+}
@@ -3,6 +3,7 @@ package sync
 import (
 	"context"
 	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/p2p/encoder"
 	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
 	"github.com/amazechain/amc/log"
 	"reflect"
@@ -35,6 +36,10 @@ func (s *Service) registerRPCHandlers() {
 		p2p.RPCStatusTopicV1,
 		s.statusRPCHandler,
 	)
+	s.registerRPC(
+		p2p.RPCForkIDTopicV1,
+		s.forkIDRPCHandler,
+	)
 	s.registerRPC(
 		p2p.RPCGoodByeTopicV1,
 		s.goodbyeRPCHandler,
@@ -47,24 +52,106 @@ func (s *Service) registerRPCHandlers() {
 		p2p.RPCBodiesDataTopicV1,
 		s.bodiesByRangeRPCHandler,
 	)
+	s.registerRPC(
+		p2p.RPCHeadersDataTopicV1,
+		s.headersByRangeRPCHandler,
+	)
+	s.registerRPC(
+		p2p.RPCStateRangeTopicV1,
+		s.stateRangeRPCHandler,
+	)
+	s.registerRPC(
+		p2p.RPCStateChangesTopicV1,
+		s.stateChangesRPCHandler,
+	)
+	s.registerRPC(
+		p2p.RPCAccountRangeTopicV1,
+		s.accountRangeRPCHandler,
+	)
+	s.registerRPC(
+		p2p.RPCStorageRangesTopicV1,
+		s.storageRangesRPCHandler,
+	)
+	s.registerRPC(
+		p2p.RPCByteCodesTopicV1,
+		s.byteCodesRPCHandler,
+	)
+	if s.light != nil {
+		s.registerRPC(
+			p2p.RPCLightReceiptsTopicV1,
+			s.lightReceiptsRPCHandler,
+		)
+		s.registerRPC(
+			p2p.RPCLightAccountTopicV1,
+			s.lightAccountRPCHandler,
+		)
+	}
+	if s.cfg.txPool != nil {
+		s.registerRPC(
+			p2p.RPCTxAnnounceTopicV1,
+			s.txAnnounceRPCHandler,
+		)
+		s.registerRPC(
+			p2p.RPCPooledTxsTopicV1,
+			s.pooledTxsRPCHandler,
+		)
+		s.registerRPC(
+			p2p.RPCTxsTopicV1,
+			s.txsRPCHandler,
+		)
+	}
 }
 
 // Remove all Stream handlers
 func (s *Service) unregisterHandlers() {
-	fullBodiesRangeTopic := p2p.RPCBodiesDataTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
-	fullStatusTopic := p2p.RPCStatusTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
-	fullGoodByeTopic := p2p.RPCGoodByeTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
-	fullPingTopic := p2p.RPCPingTopicV1 + s.cfg.p2p.Encoding().ProtocolSuffix()
-
-	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullBodiesRangeTopic))
-	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullStatusTopic))
-	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullGoodByeTopic))
-	s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullPingTopic))
+	for _, encoding := range encoder.Encodings {
+		fullBodiesRangeTopic := p2p.RPCBodiesDataTopicV1 + encoding.ProtocolSuffix()
+		fullHeadersRangeTopic := p2p.RPCHeadersDataTopicV1 + encoding.ProtocolSuffix()
+		fullStatusTopic := p2p.RPCStatusTopicV1 + encoding.ProtocolSuffix()
+		fullForkIDTopic := p2p.RPCForkIDTopicV1 + encoding.ProtocolSuffix()
+		fullGoodByeTopic := p2p.RPCGoodByeTopicV1 + encoding.ProtocolSuffix()
+		fullPingTopic := p2p.RPCPingTopicV1 + encoding.ProtocolSuffix()
+		fullStateRangeTopic := p2p.RPCStateRangeTopicV1 + encoding.ProtocolSuffix()
+		fullStateChangesTopic := p2p.RPCStateChangesTopicV1 + encoding.ProtocolSuffix()
+		fullAccountRangeTopic := p2p.RPCAccountRangeTopicV1 + encoding.ProtocolSuffix()
+		fullStorageRangesTopic := p2p.RPCStorageRangesTopicV1 + encoding.ProtocolSuffix()
+		fullByteCodesTopic := p2p.RPCByteCodesTopicV1 + encoding.ProtocolSuffix()
+		fullLightReceiptsTopic := p2p.RPCLightReceiptsTopicV1 + encoding.ProtocolSuffix()
+		fullLightAccountTopic := p2p.RPCLightAccountTopicV1 + encoding.ProtocolSuffix()
+		fullTxAnnounceTopic := p2p.RPCTxAnnounceTopicV1 + encoding.ProtocolSuffix()
+		fullPooledTxsTopic := p2p.RPCPooledTxsTopicV1 + encoding.ProtocolSuffix()
+		fullTxsTopic := p2p.RPCTxsTopicV1 + encoding.ProtocolSuffix()
+
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullBodiesRangeTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullHeadersRangeTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullStatusTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullForkIDTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullGoodByeTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullPingTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullStateRangeTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullStateChangesTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullAccountRangeTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullStorageRangesTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullByteCodesTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullLightReceiptsTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullLightAccountTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullTxAnnounceTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullPooledTxsTopic))
+		s.cfg.p2p.Host().RemoveStreamHandler(protocol.ID(fullTxsTopic))
+	}
 }
 
-// registerRPC for a given topic with an expected protobuf message type.
+// registerRPC for a given topic with an expected protobuf message type. The
+// handler is registered with every encoding, the peer opening the stream
+// negotiates the one the request and the response use.
 func (s *Service) registerRPC(baseTopic string, handle rpcHandler) {
-	topic := baseTopic + s.cfg.p2p.Encoding().ProtocolSuffix()
+	for _, encoding := range encoder.Encodings {
+		s.registerRPCWithEncoding(baseTopic, baseTopic+encoding.ProtocolSuffix(), handle)
+	}
+}
+
+// registerRPCWithEncoding registers the handler of a topic for one encoding.
+func (s *Service) registerRPCWithEncoding(baseTopic, topic string, handle rpcHandler) {
 	s.cfg.p2p.SetStreamHandler(topic, func(stream network.Stream) {
 		defer func() {
 			if r := recover(); r != nil {
@@ -104,6 +191,14 @@ func (s *Service) registerRPC(baseTopic string, handle rpcHandler) {
 			return
 		}
 		s.rateLimiter.addRawStream(stream)
+		// Keep a single peer from taking up all the handlers.
+		if !s.rateLimiter.acquireStream(stream.Conn().RemotePeer()) {
+			s.rateLimiter.violation(stream.Conn().RemotePeer(), topic, "streams")
+			writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrRateLimited.Error(), stream)
+			log.Debug("Too many concurrent rpc requests from peer", "peer", stream.Conn().RemotePeer().Pretty(), "topic", stream.Protocol())
+			return
+		}
+		defer s.rateLimiter.releaseStream(stream.Conn().RemotePeer())
 
 		if err := stream.SetReadDeadline(time.Now().Add(ttfbTimeout)); err != nil {
 			log.Debug("Could not set stream read deadline", "peer", stream.Conn().RemotePeer().Pretty(), "topic", stream.Protocol(), "err", err)
@@ -131,10 +226,10 @@ func (s *Service) registerRPC(baseTopic string, handle rpcHandler) {
 				log.Errorf("message of %T does not support marshaller interface", msg)
 				return
 			}
-			if err := s.cfg.p2p.Encoding().DecodeWithMaxLength(stream, msg); err != nil {
+			if err := streamEncoding(stream).DecodeWithLimit(stream, msg, rpcRequestSizeLimits[baseTopic]); err != nil {
 				log.Debug("Could not decode stream message", "topic", topic, "err", err)
 				//tracing.AnnotateError(span, err)
-				s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
+				s.rateLimiter.violation(stream.Conn().RemotePeer(), topic, "decode")
 				return
 			}
 			if err := handle(ctx, msg, stream); err != nil {
@@ -151,10 +246,10 @@ func (s *Service) registerRPC(baseTopic string, handle rpcHandler) {
 				log.Errorf("message of %T does not support marshaller interface", msg)
 				return
 			}
-			if err := s.cfg.p2p.Encoding().DecodeWithMaxLength(stream, msg); err != nil {
+			if err := streamEncoding(stream).DecodeWithLimit(stream, msg, rpcRequestSizeLimits[baseTopic]); err != nil {
 				log.Debug("Could not decode stream message", "topic", topic, "err", err)
 				//tracing.AnnotateError(span, err)
-				s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
+				s.rateLimiter.violation(stream.Conn().RemotePeer(), topic, "decode")
 				return
 			}
 			if err := handle(ctx, nTyp.Elem().Interface(), stream); err != nil {
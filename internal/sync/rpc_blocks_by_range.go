@@ -185,5 +185,5 @@ func (s *Service) validateRangeRequest(r *sync_pb.BodiesByRangeRequest) error {
 }
 
 func (s *Service) writeErrorResponseToStream(responseCode byte, reason string, stream libp2pcore.Stream) {
-	writeErrorResponseToStream(responseCode, reason, stream, s.cfg.p2p)
+	writeErrorResponseToStream(responseCode, reason, stream)
 }
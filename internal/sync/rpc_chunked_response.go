@@ -17,7 +17,7 @@ import (
 // response_chunk  ::= <result> | <context-bytes> | <encoding-dependent-header> | <encoded-payload>
 func (s *Service) chunkBlockWriter(stream libp2pcore.Stream, blk types.IBlock) error {
 	SetStreamWriteDeadline(stream, defaultWriteDuration)
-	return WriteBlockChunk(stream, s.cfg.chain, s.cfg.p2p.Encoding(), blk)
+	return WriteBlockChunk(stream, s.cfg.chain, streamEncoding(stream), blk)
 }
 
 // WriteBlockChunk writes block chunk object to stream.
@@ -54,7 +54,7 @@ func ReadChunkedBlock(stream libp2pcore.Stream, p2p p2p.EncodingProvider, isFirs
 // readFirstChunkedBlock reads the first chunked block and applies the appropriate deadlines to
 // it.
 func readFirstChunkedBlock(stream libp2pcore.Stream, p2p p2p.EncodingProvider) (*types_pb.Block, error) {
-	code, errMsg, err := ReadStatusCode(stream, p2p.Encoding())
+	code, errMsg, err := ReadStatusCode(stream, streamEncoding(stream))
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +66,7 @@ func readFirstChunkedBlock(stream libp2pcore.Stream, p2p p2p.EncodingProvider) (
 		return nil, err
 	}
 	blk := &types_pb.Block{}
-	err = p2p.Encoding().DecodeWithMaxLength(stream, blk)
+	err = streamEncoding(stream).DecodeWithMaxLength(stream, blk)
 	return blk, err
 }
 
@@ -74,7 +74,7 @@ func readFirstChunkedBlock(stream libp2pcore.Stream, p2p p2p.EncodingProvider) (
 // provided message type.
 func readResponseChunk(stream libp2pcore.Stream, p2p p2p.EncodingProvider) (*types_pb.Block, error) {
 	SetStreamReadDeadline(stream, respTimeout)
-	code, errMsg, err := readStatusCodeNoDeadline(stream, p2p.Encoding())
+	code, errMsg, err := readStatusCodeNoDeadline(stream, streamEncoding(stream))
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +88,6 @@ func readResponseChunk(stream libp2pcore.Stream, p2p p2p.EncodingProvider) (*typ
 	}
 
 	blk := &types_pb.Block{}
-	err = p2p.Encoding().DecodeWithMaxLength(stream, blk)
+	err = streamEncoding(stream).DecodeWithMaxLength(stream, blk)
 	return blk, err
 }
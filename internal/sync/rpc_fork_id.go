@@ -0,0 +1,147 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/internal/forkid"
+	"github.com/amazechain/amc/internal/p2p"
+	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
+	"github.com/amazechain/amc/log"
+	libp2pcore "github.com/libp2p/go-libp2p/core"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multistream"
+	"github.com/pkg/errors"
+)
+
+// errIncompatibleForks is returned when the peer schedules the forks of the
+// chain differently, so that it is or will be on another chain.
+var errIncompatibleForks = errors.New("incompatible fork schedule")
+
+// newForkFilter creates the filter checking the fork IDs of the peers against
+// the local schedule, as of the current head.
+func (s *Service) newForkFilter() forkid.Filter {
+	genesis := s.cfg.chain.GenesisBlock()
+	return forkid.NewFilter(s.cfg.chain.Config(), genesis.Hash(), genesis.Time(), func() (uint64, uint64) {
+		head := s.cfg.chain.CurrentBlock()
+		return head.Number64().Uint64(), head.Time()
+	})
+}
+
+// localForkID returns the fork ID of the local chain at the current head.
+func (s *Service) localForkID() *sync_pb.ForkID {
+	genesis, head := s.cfg.chain.GenesisBlock(), s.cfg.chain.CurrentBlock()
+	id := forkid.NewID(s.cfg.chain.Config(), genesis.Hash(), genesis.Time(), head.Number64().Uint64(), head.Time())
+	return &sync_pb.ForkID{Hash: id.Hash, Next: id.Next}
+}
+
+func (s *Service) validateForkID(id *sync_pb.ForkID) error {
+	if err := s.forkFilter(forkid.ID{Hash: id.Hash, Next: id.Next}); err != nil {
+		return fmt.Errorf("%w: %v (remote %x, next %d)", errIncompatibleForks, err, id.Hash, id.Next)
+	}
+	return nil
+}
+
+// forkIDRPCHandler checks the fork ID a peer sends after its status and
+// answers with the local one. Peers on another fork schedule are told why and
+// disconnected.
+func (s *Service) forkIDRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, cancel := context.WithTimeout(ctx, ttfbTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+	m, ok := msg.(*sync_pb.ForkID)
+	if !ok {
+		return errors.New("message is not type *pb.ForkID")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+
+	remotePeer := stream.Conn().RemotePeer()
+	if err := s.validateForkID(m); err != nil {
+		log.Debug("Peer is on another fork schedule", "peer", remotePeer, "err", err)
+		s.writeErrorResponseToStream(responseCodeInvalidRequest, err.Error(), stream)
+		closeStreamAndWait(stream)
+		if err := s.sendGoodByeAndDisconnect(ctx, p2ptypes.GoodbyeCodeWrongNetwork, remotePeer); err != nil {
+			return err
+		}
+		return nil
+	}
+	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+		log.Debug("Could not write to stream", "err", err)
+	}
+	if _, err := streamEncoding(stream).EncodeWithMaxLength(stream, s.localForkID()); err != nil {
+		return err
+	}
+	closeStream(stream)
+	return nil
+}
+
+// sendForkIDRequest sends the local fork ID to a peer and checks the one it
+// answers with. Peers that don't support the exchange yet are taken as
+// compatible, their status already matched.
+func (s *Service) sendForkIDRequest(ctx context.Context, id peer.ID) error {
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+
+	topic, err := p2p.TopicFromMessage(p2p.ForkIDMessageName)
+	if err != nil {
+		return err
+	}
+	stream, err := s.cfg.p2p.Send(ctx, s.localForkID(), topic, id)
+	if err != nil {
+		if errors.Is(err, multistream.ErrNotSupported[protocol.ID]{}) {
+			log.Trace("Peer does not support fork ID exchange", "peer", id)
+			return nil
+		}
+		return err
+	}
+	defer closeStream(stream)
+
+	code, errMsg, err := ReadStatusCode(stream, streamEncoding(stream))
+	if err != nil {
+		return err
+	}
+	if err := forkIDResponseError(code, errMsg); err != nil {
+		return err
+	}
+	msg := new(sync_pb.ForkID)
+	if err := streamEncoding(stream).DecodeWithMaxLength(stream, msg); err != nil {
+		s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(id)
+		return err
+	}
+	return s.validateForkID(msg)
+}
+
+// forkIDResponseError returns the error for a fork ID response code. Only an
+// invalid request that isn't rate limiting means the peer rejected our fork
+// ID and is disconnecting.
+func forkIDResponseError(code byte, errMsg string) error {
+	switch {
+	case code == responseCodeSuccess:
+		return nil
+	case code == responseCodeInvalidRequest && errMsg != p2ptypes.ErrRateLimited.Error():
+		return fmt.Errorf("%w: rejected by peer: %s", errIncompatibleForks, errMsg)
+	default:
+		return errors.New(errMsg)
+	}
+}
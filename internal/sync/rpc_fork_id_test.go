@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+
+	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
+)
+
+func TestForkIDResponseError(t *testing.T) {
+	tests := []struct {
+		code         byte
+		msg          string
+		err          bool
+		incompatible bool
+	}{
+		{responseCodeSuccess, "", false, false},
+		{responseCodeInvalidRequest, "incompatible fork schedule: local incompatible or needs update", true, true},
+		// Rate limiting and server errors don't say anything of the peer's chain.
+		{responseCodeInvalidRequest, p2ptypes.ErrRateLimited.Error(), true, false},
+		{responseCodeServerError, "internal error", true, false},
+	}
+	for i, tt := range tests {
+		err := forkIDResponseError(tt.code, tt.msg)
+		if (err != nil) != tt.err {
+			t.Errorf("test %d: error %v, want error %v", i, err, tt.err)
+		}
+		if errors.Is(err, errIncompatibleForks) != tt.incompatible {
+			t.Errorf("test %d: error %v, want incompatible %v", i, err, tt.incompatible)
+		}
+	}
+}
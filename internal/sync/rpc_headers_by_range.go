@@ -0,0 +1,159 @@
+package sync
+
+import (
+	"context"
+	"io"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/api/protocol/types_pb"
+	"github.com/amazechain/amc/common"
+	types "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/p2p/encoder"
+	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/utils"
+	"github.com/holiman/uint256"
+
+	libp2pcore "github.com/libp2p/go-libp2p/core"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// MaxRequestHeaders is the most headers a single headers by range request may ask for.
+const MaxRequestHeaders = 1024
+
+// headersByRangeRPCHandler looks up the requested headers from the database.
+// Unlike bodies, headers may be requested with a step above one, which lets a
+// syncing node fetch a sparse skeleton of the chain from a single peer.
+func (s *Service) headersByRangeRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.HeadersByRangeHandler")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.HeadersByRangeRequest)
+	if !ok {
+		return errors.New("message is not type *sync_pb.HeadersByRangeRequest")
+	}
+	if !validHeadersByRangeRequest(m) {
+		s.writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrInvalidRequest.Error(), stream)
+		s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
+		return p2ptypes.ErrInvalidRequest
+	}
+	if err := s.rateLimiter.validateRequest(stream, m.Count); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, int64(m.Count))
+
+	number := utils.ConvertH256ToUint256Int(m.StartBlockNumber)
+	for i := uint64(0); i < m.Count; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Serve what is known, a short response tells the peer where our chain ends.
+		header := s.cfg.chain.GetHeaderByNumber(number)
+		if header == nil {
+			break
+		}
+		SetStreamWriteDeadline(stream, defaultWriteDuration)
+		if err := WriteHeaderChunk(stream, s.cfg.chain, streamEncoding(stream), header.(*types.Header)); err != nil {
+			log.Debug("Could not send a chunked response", "err", err)
+			s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+			return err
+		}
+		number = new(uint256.Int).AddUint64(number, m.Step)
+	}
+	closeStream(stream)
+	return nil
+}
+
+// validHeadersByRangeRequest reports whether the request asks for a servable
+// range of headers.
+func validHeadersByRangeRequest(m *sync_pb.HeadersByRangeRequest) bool {
+	return m.StartBlockNumber != nil && m.Count > 0 && m.Count <= MaxRequestHeaders && m.Step > 0
+}
+
+// WriteHeaderChunk writes header chunk object to stream.
+// response_chunk  ::= <result> | <context-bytes> | <encoding-dependent-header> | <encoded-payload>
+func WriteHeaderChunk(stream libp2pcore.Stream, chain common.IBlockChain, encoding encoder.NetworkEncoding, header *types.Header) error {
+	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+		return err
+	}
+	digest, err := utils.CreateForkDigest(header.Number64(), chain.GenesisBlock().Hash())
+	if err != nil {
+		return err
+	}
+	if err = writeContextToStream(digest[:], stream, chain); err != nil {
+		return err
+	}
+	_, err = encoding.EncodeWithMaxLength(stream, header.ToProtoMessage().(*types_pb.Header))
+	return err
+}
+
+// readHeaderChunk reads a single header from the response stream.
+func readHeaderChunk(stream libp2pcore.Stream, p2p p2p.EncodingProvider, isFirstChunk bool) (*types.Header, error) {
+	var (
+		code   uint8
+		errMsg string
+		err    error
+	)
+	if isFirstChunk {
+		code, errMsg, err = ReadStatusCode(stream, streamEncoding(stream))
+	} else {
+		SetStreamReadDeadline(stream, respTimeout)
+		code, errMsg, err = readStatusCodeNoDeadline(stream, streamEncoding(stream))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, errors.New(errMsg)
+	}
+	if _, err := readContextFromStream(stream); err != nil {
+		return nil, err
+	}
+	msg := new(types_pb.Header)
+	if err := streamEncoding(stream).DecodeWithMaxLength(stream, msg); err != nil {
+		return nil, err
+	}
+	header := new(types.Header)
+	if err := header.FromProtoMessage(msg); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// SendHeadersByRangeRequest sends HeadersByRange and returns the fetched headers.
+// The headers are checked to be at the requested numbers, but not whether they
+// link, which only holds for a step of one.
+func SendHeadersByRangeRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.HeadersByRangeRequest) ([]*types.Header, error) {
+	topic, err := p2p.TopicFromMessage(p2p.HeadersByRangeMessageName)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := p2pProvider.Send(ctx, req, topic, pid)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStream(stream)
+
+	start := utils.ConvertH256ToUint256Int(req.StartBlockNumber).Uint64()
+	headers := make([]*types.Header, 0, req.Count)
+	for i := uint64(0); ; i++ {
+		header, err := readHeaderChunk(stream, p2pProvider, i == 0)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if i >= req.Count || header.Number64().Uint64() != start+i*req.Step {
+			return nil, ErrInvalidFetchedData
+		}
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
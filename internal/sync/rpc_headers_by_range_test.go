@@ -0,0 +1,189 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common"
+	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/p2p/encoder"
+	"github.com/amazechain/amc/utils"
+	"github.com/holiman/uint256"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ssz "github.com/prysmaticlabs/fastssz"
+)
+
+// headersTestChain is the part of the chain the header chunks are written with.
+type headersTestChain struct {
+	common.IBlockChain
+}
+
+func (headersTestChain) GenesisBlock() block2.IBlock {
+	return block2.NewBlock(testHeader(0), nil)
+}
+
+// headersTestSender sends requests over a mock network, as the p2p service does.
+type headersTestSender struct {
+	host host.Host
+}
+
+func (s *headersTestSender) Encoding() encoder.NetworkEncoding {
+	return &encoder.SszNetworkEncoder{}
+}
+
+func (s *headersTestSender) Send(ctx context.Context, msg interface{}, topic string, pid peer.ID) (network.Stream, error) {
+	stream, err := s.host.NewStream(ctx, pid, protocol.ID(topic+s.Encoding().ProtocolSuffix()))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Encoding().EncodeWithMaxLength(stream, msg.(ssz.Marshaler)); err != nil {
+		return nil, err
+	}
+	return stream, stream.CloseWrite()
+}
+
+func testHeader(number uint64) *block2.Header {
+	return &block2.Header{
+		Number:     uint256.NewInt(number),
+		Difficulty: uint256.NewInt(2),
+		BaseFee:    uint256.NewInt(0),
+	}
+}
+
+// newHeadersTestPeer connects a sender to a peer answering headers by range
+// requests with the headers returned by serve.
+func newHeadersTestPeer(t *testing.T, serve func(req *sync_pb.HeadersByRangeRequest) []*block2.Header) (*headersTestSender, peer.ID) {
+	net := mocknet.New()
+	t.Cleanup(func() { net.Close() })
+	local, err := net.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := net.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := net.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := net.ConnectAllButSelf(); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := &headersTestSender{host: local}
+	topic, err := p2p.TopicFromMessage(p2p.HeadersByRangeMessageName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.SetStreamHandler(protocol.ID(topic+sender.Encoding().ProtocolSuffix()), func(stream network.Stream) {
+		defer stream.Close()
+		req := new(sync_pb.HeadersByRangeRequest)
+		if err := sender.Encoding().DecodeWithMaxLength(stream, req); err != nil {
+			t.Error(err)
+			return
+		}
+		for _, header := range serve(req) {
+			if err := WriteHeaderChunk(stream, headersTestChain{}, sender.Encoding(), header); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	return sender, remote.ID()
+}
+
+func TestValidHeadersByRangeRequest(t *testing.T) {
+	start := utils.ConvertUint256IntToH256(uint256.NewInt(1))
+	tests := []struct {
+		req   *sync_pb.HeadersByRangeRequest
+		valid bool
+	}{
+		{&sync_pb.HeadersByRangeRequest{StartBlockNumber: start, Count: 1, Step: 1}, true},
+		{&sync_pb.HeadersByRangeRequest{StartBlockNumber: start, Count: MaxRequestHeaders, Step: 256}, true},
+		{&sync_pb.HeadersByRangeRequest{Count: 1, Step: 1}, false},
+		{&sync_pb.HeadersByRangeRequest{StartBlockNumber: start, Step: 1}, false},
+		{&sync_pb.HeadersByRangeRequest{StartBlockNumber: start, Count: MaxRequestHeaders + 1, Step: 1}, false},
+		{&sync_pb.HeadersByRangeRequest{StartBlockNumber: start, Count: 1}, false},
+	}
+	for i, tt := range tests {
+		if valid := validHeadersByRangeRequest(tt.req); valid != tt.valid {
+			t.Errorf("test %d: valid %v, want %v", i, valid, tt.valid)
+		}
+	}
+}
+
+func TestSendHeadersByRangeRequest(t *testing.T) {
+	// serveChain answers with the headers of a chain of the given length.
+	serveChain := func(length uint64) func(req *sync_pb.HeadersByRangeRequest) []*block2.Header {
+		return func(req *sync_pb.HeadersByRangeRequest) []*block2.Header {
+			var headers []*block2.Header
+			number := utils.ConvertH256ToUint256Int(req.StartBlockNumber).Uint64()
+			for i := uint64(0); i < req.Count && number <= length; i++ {
+				headers = append(headers, testHeader(number))
+				number += req.Step
+			}
+			return headers
+		}
+	}
+	tests := []struct {
+		serve  func(req *sync_pb.HeadersByRangeRequest) []*block2.Header
+		start  uint64
+		count  uint64
+		step   uint64
+		want   []uint64
+		failed bool
+	}{
+		{serve: serveChain(100), start: 1, count: 4, step: 1, want: []uint64{1, 2, 3, 4}},
+		{serve: serveChain(100), start: 10, count: 4, step: 20, want: []uint64{10, 30, 50, 70}},
+		// A short response is where the peer's chain ends.
+		{serve: serveChain(50), start: 10, count: 4, step: 20, want: []uint64{10, 30, 50}},
+		{serve: serveChain(5), start: 10, count: 4, step: 1},
+		// Headers at other numbers than requested.
+		{serve: func(req *sync_pb.HeadersByRangeRequest) []*block2.Header {
+			return []*block2.Header{testHeader(10), testHeader(11)}
+		}, start: 10, count: 4, step: 2, failed: true},
+		// More headers than requested.
+		{serve: func(req *sync_pb.HeadersByRangeRequest) []*block2.Header {
+			return []*block2.Header{testHeader(1), testHeader(2), testHeader(3)}
+		}, start: 1, count: 2, step: 1, failed: true},
+	}
+	for i, tt := range tests {
+		sender, pid := newHeadersTestPeer(t, tt.serve)
+		headers, err := SendHeadersByRangeRequest(context.Background(), sender, pid, &sync_pb.HeadersByRangeRequest{
+			StartBlockNumber: utils.ConvertUint256IntToH256(uint256.NewInt(tt.start)),
+			Count:            tt.count,
+			Step:             tt.step,
+		})
+		if tt.failed {
+			if !errors.Is(err, ErrInvalidFetchedData) {
+				t.Errorf("test %d: error %v, want %v", i, err, ErrInvalidFetchedData)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		var numbers []uint64
+		for _, header := range headers {
+			numbers = append(numbers, header.Number64().Uint64())
+		}
+		if len(numbers) != len(tt.want) {
+			t.Errorf("test %d: headers %v, want %v", i, numbers, tt.want)
+			continue
+		}
+		for j := range numbers {
+			if numbers[j] != tt.want[j] {
+				t.Errorf("test %d: headers %v, want %v", i, numbers, tt.want)
+				break
+			}
+		}
+	}
+}
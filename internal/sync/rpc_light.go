@@ -0,0 +1,293 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/internal/p2p"
+	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+
+	libp2pcore "github.com/libp2p/go-libp2p/core"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// Flow control of the light client requests. Every client has a buffer of
+// LightBufferLimit cost units at the server, which recharges by
+// LightBufferRecharge units a second; a request is refused if its cost is more
+// than the buffer left, and every response tells the client what is left.
+const (
+	LightBufferLimit    = 300
+	LightBufferRecharge = 30
+
+	lightReceiptsCost = 20
+	lightAccountCost  = 5
+	lightStorageCost  = 1
+	lightCodeCost     = 10
+)
+
+// lightClientIdle is how long a light client keeps its slot without requests.
+const lightClientIdle = 5 * time.Minute
+
+var (
+	errTooManyLightClients = errors.New("too many light clients")
+	errUnknownLightBlock   = errors.New("unknown or non-canonical block")
+)
+
+// LightAccount is an account in the state of a block as served to light
+// clients, with the values of the requested storage slots in request order.
+type LightAccount struct {
+	Balance  *uint256.Int
+	Nonce    uint64
+	CodeHash types.Hash
+	Code     []byte
+	Storage  []types.Hash
+}
+
+// LightRequestCost returns the flow control cost of a light request.
+func LightRequestCost(msg interface{}) uint64 {
+	switch m := msg.(type) {
+	case *sync_pb.LightReceiptsRequest:
+		return lightReceiptsCost
+	case *sync_pb.LightAccountRequest:
+		cost := uint64(lightAccountCost + lightStorageCost*len(m.Keys)/32)
+		if m.Code {
+			cost += lightCodeCost
+		}
+		return cost
+	}
+	return LightBufferLimit
+}
+
+// lightClients keeps the slots of the light clients being served.
+type lightClients struct {
+	lock sync.Mutex
+	max  int
+	seen map[peer.ID]time.Time
+}
+
+func newLightClients(max int) *lightClients {
+	return &lightClients{max: max, seen: make(map[peer.ID]time.Time)}
+}
+
+// admit reports whether the peer has, or can take, a client slot. Slots idle
+// for longer than lightClientIdle are given up first.
+func (c *lightClients) admit(pid peer.ID) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	if _, ok := c.seen[pid]; !ok && len(c.seen) >= c.max {
+		for id, last := range c.seen {
+			if now.Sub(last) > lightClientIdle {
+				delete(c.seen, id)
+			}
+		}
+		if len(c.seen) >= c.max {
+			return false
+		}
+	}
+	c.seen[pid] = now
+	return true
+}
+
+// admitLightRequest charges the cost of a light request to the client's
+// buffer, refusing it if the server is full or the buffer is exhausted.
+func (s *Service) admitLightRequest(stream libp2pcore.Stream, cost uint64) error {
+	if !s.light.admit(stream.Conn().RemotePeer()) {
+		s.writeErrorResponseToStream(responseCodeServerError, errTooManyLightClients.Error(), stream)
+		return errTooManyLightClients
+	}
+	if err := s.rateLimiter.validateRequest(stream, cost); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, int64(cost))
+	return nil
+}
+
+// lightReceiptsRPCHandler serves the receipts of a block to a light client.
+func (s *Service) lightReceiptsRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	_, span := trace.StartSpan(ctx, "sync.LightReceiptsHandler")
+	defer span.End()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.LightReceiptsRequest)
+	if !ok {
+		return errors.New("message is not type *sync_pb.LightReceiptsRequest")
+	}
+	if err := s.admitLightRequest(stream, LightRequestCost(m)); err != nil {
+		return err
+	}
+	if header, _ := s.cfg.chain.GetHeaderByHash(m.BlockHash); header == nil {
+		s.writeErrorResponseToStream(responseCodeInvalidRequest, errUnknownLightBlock.Error(), stream)
+		return errUnknownLightBlock
+	}
+	receipts, err := s.cfg.chain.GetReceipts(m.BlockHash)
+	if err != nil {
+		s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		return err
+	}
+	data, err := receipts.Marshal()
+	if err != nil {
+		s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		return err
+	}
+	return s.writeLightResponse(stream, data)
+}
+
+// lightAccountRPCHandler serves an account in the state of a canonical block
+// to a light client.
+func (s *Service) lightAccountRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.LightAccountHandler")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.LightAccountRequest)
+	if !ok {
+		return errors.New("message is not type *sync_pb.LightAccountRequest")
+	}
+	if err := s.admitLightRequest(stream, LightRequestCost(m)); err != nil {
+		return err
+	}
+
+	var data []byte
+	if err := s.cfg.chain.DB().View(ctx, func(tx kv.Tx) error {
+		number := rawdb.ReadHeaderNumber(tx, m.BlockHash)
+		if number == nil {
+			return errUnknownLightBlock
+		}
+		if canonical, err := rawdb.ReadCanonicalHash(tx, *number); err != nil || canonical != m.BlockHash {
+			return errUnknownLightBlock
+		}
+		pruned, err := rawdb.ReadStatePruneProgress(tx)
+		if err != nil {
+			return err
+		}
+		if *number+1 < pruned {
+			return fmt.Errorf("%w: state below block %d is pruned", p2ptypes.ErrInvalidRequest, pruned)
+		}
+		var (
+			ibs  = s.cfg.chain.StateAt(tx, *number)
+			addr = types.Address(m.Address)
+		)
+		account := &LightAccount{
+			Balance:  ibs.GetBalance(addr),
+			Nonce:    ibs.GetNonce(addr),
+			CodeHash: ibs.GetCodeHash(addr),
+		}
+		if m.Code {
+			account.Code = ibs.GetCode(addr)
+		}
+		for i := 0; i < len(m.Keys); i += 32 {
+			var (
+				key   = types.BytesToHash(m.Keys[i : i+32])
+				value uint256.Int
+			)
+			ibs.GetState(addr, &key, &value)
+			account.Storage = append(account.Storage, value.Bytes32())
+		}
+		data, err = rlp.EncodeToBytes(account)
+		return err
+	}); err != nil {
+		if errors.Is(err, errUnknownLightBlock) || errors.Is(err, p2ptypes.ErrInvalidRequest) {
+			s.writeErrorResponseToStream(responseCodeInvalidRequest, err.Error(), stream)
+		} else {
+			s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		}
+		return err
+	}
+	return s.writeLightResponse(stream, data)
+}
+
+// writeLightResponse writes an answer along with the buffer the client has left.
+func (s *Service) writeLightResponse(stream libp2pcore.Stream, data []byte) error {
+	collector, err := s.rateLimiter.topicCollector(string(stream.Protocol()))
+	if err != nil {
+		return err
+	}
+	resp := &sync_pb.LightResponse{Data: data}
+	if remaining := collector.Remaining(stream.Conn().RemotePeer().String()); remaining > 0 {
+		resp.Buffer = uint64(remaining)
+	}
+	SetStreamWriteDeadline(stream, defaultWriteDuration)
+	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+		return err
+	}
+	if _, err := streamEncoding(stream).EncodeWithMaxLength(stream, resp); err != nil {
+		return err
+	}
+	closeStream(stream)
+	return nil
+}
+
+// SendLightReceiptsRequest requests the receipts of a block from the peer.
+// The receipts still have to be checked against the block header.
+func SendLightReceiptsRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.LightReceiptsRequest) (*sync_pb.LightResponse, block.Receipts, error) {
+	resp, err := sendLightRequest(ctx, p2pProvider, pid, p2p.LightReceiptsMessageName, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	var receipts block.Receipts
+	if err := receipts.Unmarshal(resp.Data); err != nil {
+		return nil, nil, err
+	}
+	return resp, receipts, nil
+}
+
+// SendLightAccountRequest requests an account in the state of a block from the peer.
+func SendLightAccountRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.LightAccountRequest) (*sync_pb.LightResponse, *LightAccount, error) {
+	resp, err := sendLightRequest(ctx, p2pProvider, pid, p2p.LightAccountMessageName, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	account := new(LightAccount)
+	if err := rlp.DecodeBytes(resp.Data, account); err != nil {
+		return nil, nil, err
+	}
+	if len(account.Storage) != len(req.Keys)/32 || account.Balance == nil {
+		return nil, nil, ErrInvalidFetchedData
+	}
+	return resp, account, nil
+}
+
+func sendLightRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, name string, req interface{}) (*sync_pb.LightResponse, error) {
+	topic, err := p2p.TopicFromMessage(name)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := p2pProvider.Send(ctx, req, topic, pid)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStream(stream)
+	return readLightResponse(stream)
+}
+
+func readLightResponse(stream network.Stream) (*sync_pb.LightResponse, error) {
+	code, errMsg, err := ReadStatusCode(stream, streamEncoding(stream))
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, errors.New(errMsg)
+	}
+	SetStreamReadDeadline(stream, respTimeout)
+	resp := new(sync_pb.LightResponse)
+	if err := streamEncoding(stream).DecodeWithMaxLength(stream, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
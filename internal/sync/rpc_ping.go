@@ -39,7 +39,7 @@ func (s *Service) pingHandler(_ context.Context, msg interface{}, stream libp2pc
 		return err
 	}
 	sq := s.cfg.p2p.GetPing()
-	if _, err := s.cfg.p2p.Encoding().EncodeWithMaxLength(stream, sq); err != nil {
+	if _, err := streamEncoding(stream).EncodeWithMaxLength(stream, sq); err != nil {
 		return err
 	}
 
@@ -70,7 +70,7 @@ func (s *Service) sendPingRequest(ctx context.Context, id peer.ID) error {
 	currentTime := time.Now()
 	defer closeStream(stream)
 
-	code, errMsg, err := ReadStatusCode(stream, s.cfg.p2p.Encoding())
+	code, errMsg, err := ReadStatusCode(stream, streamEncoding(stream))
 	if err != nil {
 		return err
 	}
@@ -82,7 +82,7 @@ func (s *Service) sendPingRequest(ctx context.Context, id peer.ID) error {
 		return errors.New(errMsg)
 	}
 	pingResponse := new(ssztype.SSZUint64)
-	if err := s.cfg.p2p.Encoding().DecodeWithMaxLength(stream, pingResponse); err != nil {
+	if err := streamEncoding(stream).DecodeWithMaxLength(stream, pingResponse); err != nil {
 		return err
 	}
 	valid, err := s.validateSequenceNum(*pingResponse, stream.Conn().RemotePeer())
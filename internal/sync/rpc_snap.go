@@ -0,0 +1,228 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/internal/p2p"
+	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+
+	libp2pcore "github.com/libp2p/go-libp2p/core"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// snapBudget returns the size limit of an answer, the requested one unless it
+// is unset or above the server's.
+func snapBudget(requested uint64) int {
+	if requested == 0 || requested > stateResponseBudget {
+		return stateResponseBudget
+	}
+	return int(requested)
+}
+
+// accountRangeRPCHandler serves the accounts between two addresses at the
+// current head.
+func (s *Service) accountRangeRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.AccountRangeHandler")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.AccountRangeRequest)
+	if !ok {
+		return errors.New("message is not type *sync_pb.AccountRangeRequest")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+
+	var limit []byte
+	if m.Limit != (types.Address{}) {
+		if bytes.Compare(m.Limit[:], m.Origin[:]) < 0 {
+			s.writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrInvalidRequest.Error(), stream)
+			s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
+			return p2ptypes.ErrInvalidRequest
+		}
+		limit = m.Limit[:]
+	}
+	resp := new(sync_pb.StateResponse)
+	if err := s.cfg.chain.DB().View(ctx, func(tx kv.Tx) error {
+		if err := readStateHead(tx, resp); err != nil {
+			return err
+		}
+		return readAccountRange(tx, m.Origin[:], limit, m.Bytes, resp)
+	}); err != nil {
+		s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		return err
+	}
+	return s.writeStateResponse(stream, resp)
+}
+
+// storageRangesRPCHandler serves the storage of a list of accounts at the
+// current head, in order, until the answer is full. The last account may be
+// cut short, the requester resumes it from its last slot.
+func (s *Service) storageRangesRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.StorageRangesHandler")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.StorageRangesRequest)
+	if !ok {
+		return errors.New("message is not type *sync_pb.StorageRangesRequest")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+
+	resp := new(sync_pb.StateResponse)
+	if err := s.cfg.chain.DB().View(ctx, func(tx kv.Tx) error {
+		if err := readStateHead(tx, resp); err != nil {
+			return err
+		}
+		return readStorageRanges(tx, m, resp)
+	}); err != nil {
+		s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		return err
+	}
+	return s.writeStateResponse(stream, resp)
+}
+
+// byteCodesRPCHandler serves contract codes by hash. Unknown codes are left
+// out, and so are the ones past the size limit, in which case the answer
+// isn't complete.
+func (s *Service) byteCodesRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.ByteCodesHandler")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.ByteCodesRequest)
+	if !ok {
+		return errors.New("message is not type *sync_pb.ByteCodesRequest")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+
+	resp := new(sync_pb.StateResponse)
+	if err := s.cfg.chain.DB().View(ctx, func(tx kv.Tx) error {
+		if err := readStateHead(tx, resp); err != nil {
+			return err
+		}
+		return readByteCodes(tx, m, resp)
+	}); err != nil {
+		s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		return err
+	}
+	return s.writeStateResponse(stream, resp)
+}
+
+// readAccountRange answers an account range request at the head of resp.
+func readAccountRange(tx kv.Tx, origin, limit []byte, budget uint64, resp *sync_pb.StateResponse) error {
+	entries, complete, err := readStateRange(tx, stateAccounts, origin, limit, maxStateRangeCount, snapBudget(budget))
+	if err != nil {
+		return err
+	}
+	resp.Covered, resp.Complete = resp.Head, complete
+	resp.Entries, err = rlp.EncodeToBytes(entries)
+	return err
+}
+
+// readStorageRanges answers a storage ranges request, counting the accounts
+// served in full in resp.Covered.
+func readStorageRanges(tx kv.Tx, m *sync_pb.StorageRangesRequest, resp *sync_pb.StateResponse) error {
+	var (
+		entries []StateEntry
+		size    int
+		budget  = snapBudget(m.Bytes)
+	)
+	resp.Complete = true
+	for i := 0; i+types.AddressLength <= len(m.Accounts); i += types.AddressLength {
+		prefix := m.Accounts[i : i+types.AddressLength]
+		origin := prefix
+		if i == 0 {
+			origin = append(types.CopyBytes(prefix), m.Origin[:]...)
+		}
+		// The prefix ends the range right after the storage of the account.
+		limit := append(types.CopyBytes(prefix), bytes.Repeat([]byte{0xff}, sync_pb.StorageOriginSize)...)
+		slots, complete, err := readStateRange(tx, stateStorage, origin, limit, maxStateRangeCount-uint64(len(entries)), budget-size)
+		if err != nil {
+			return err
+		}
+		for _, slot := range slots {
+			size += len(slot.Key) + len(slot.Value)
+		}
+		entries = append(entries, slots...)
+		if !complete {
+			resp.Complete = false
+			break
+		}
+		resp.Covered++
+		if len(entries) >= maxStateRangeCount || size >= budget {
+			resp.Complete = i+types.AddressLength == len(m.Accounts)
+			break
+		}
+	}
+	var err error
+	resp.Entries, err = rlp.EncodeToBytes(entries)
+	return err
+}
+
+// readByteCodes answers a byte codes request.
+func readByteCodes(tx kv.Tx, m *sync_pb.ByteCodesRequest, resp *sync_pb.StateResponse) error {
+	var (
+		entries []StateEntry
+		size    int
+		budget  = snapBudget(m.Bytes)
+	)
+	resp.Complete = true
+	for i := 0; i+types.HashLength <= len(m.Hashes); i += types.HashLength {
+		if size >= budget {
+			resp.Complete = false
+			break
+		}
+		hash := m.Hashes[i : i+types.HashLength]
+		code, err := tx.GetOne(modules.Code, hash)
+		if err != nil {
+			return err
+		}
+		if len(code) == 0 {
+			continue
+		}
+		entries = append(entries, StateEntry{Table: stateCode, Key: types.CopyBytes(hash), Value: types.CopyBytes(code)})
+		size += len(hash) + len(code)
+	}
+	resp.Covered = resp.Head
+	var err error
+	resp.Entries, err = rlp.EncodeToBytes(entries)
+	return err
+}
+
+// SendAccountRangeRequest requests a range of accounts from the peer.
+func SendAccountRangeRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.AccountRangeRequest) (*sync_pb.StateResponse, []StateEntry, error) {
+	return sendStateRequest(ctx, p2pProvider, pid, p2p.AccountRangeMessageName, req)
+}
+
+// SendStorageRangesRequest requests the storage of some accounts from the peer.
+func SendStorageRangesRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.StorageRangesRequest) (*sync_pb.StateResponse, []StateEntry, error) {
+	return sendStateRequest(ctx, p2pProvider, pid, p2p.StorageRangesMessageName, req)
+}
+
+// SendByteCodesRequest requests contract codes by hash from the peer.
+func SendByteCodesRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.ByteCodesRequest) (*sync_pb.StateResponse, []StateEntry, error) {
+	return sendStateRequest(ctx, p2pProvider, pid, p2p.ByteCodesMessageName, req)
+}
@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// readSnapEntries decodes the entries of a state response.
+func readSnapEntries(t *testing.T, resp *sync_pb.StateResponse) []StateEntry {
+	var entries []StateEntry
+	if err := rlp.DecodeBytes(resp.Entries, &entries); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// writeTestStorage gives each account count storage slots, returning the
+// composite keys in order.
+func writeTestStorage(t *testing.T, tx kv.RwTx, accounts [][]byte, count int) [][]byte {
+	var keys [][]byte
+	for _, addr := range accounts {
+		for i := 0; i < count; i++ {
+			key := modules.PlainGenerateCompositeStorageKey(addr, 1, types.Hash{byte(i + 1)}.Bytes())
+			if err := tx.Put(modules.Storage, key, []byte{0x5e, byte(i)}); err != nil {
+				t.Fatal(err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestSnapBudget(t *testing.T) {
+	for requested, want := range map[uint64]int{0: stateResponseBudget, 100: 100, stateResponseBudget + 1: stateResponseBudget} {
+		if got := snapBudget(requested); got != want {
+			t.Errorf("budget of %d bytes requested is %d, want %d", requested, got, want)
+		}
+	}
+}
+
+func TestReadAccountRange(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	keys := writeTestAccounts(t, tx, 10)
+
+	resp := &sync_pb.StateResponse{Head: 7}
+	if err := readAccountRange(tx, keys[2], keys[5], 0, resp); err != nil {
+		t.Fatal(err)
+	}
+	entries := readSnapEntries(t, resp)
+	if len(entries) != 4 || !resp.Complete || resp.Covered != resp.Head {
+		t.Fatalf("%d accounts, complete %t, covered %d, want 4 accounts of a complete range at the head", len(entries), resp.Complete, resp.Covered)
+	}
+	for i, entry := range entries {
+		if !bytes.Equal(entry.Key, keys[i+2]) {
+			t.Errorf("account %d is %x, want %x", i, entry.Key, keys[i+2])
+		}
+	}
+
+	// A small budget cuts the range short.
+	resp = &sync_pb.StateResponse{Head: 7}
+	if err := readAccountRange(tx, keys[0], nil, 1, resp); err != nil {
+		t.Fatal(err)
+	}
+	if entries := readSnapEntries(t, resp); len(entries) != 1 || resp.Complete {
+		t.Fatalf("%d accounts, complete %t within a budget of a byte", len(entries), resp.Complete)
+	}
+}
+
+func TestReadStorageRanges(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	accounts := writeTestAccounts(t, tx, 3)
+	keys := writeTestStorage(t, tx, accounts, 3)
+	other := writeTestStorage(t, tx, [][]byte{types.Address{0xee}.Bytes()}, 1)
+
+	req := &sync_pb.StorageRangesRequest{Accounts: bytes.Join(accounts, nil)}
+	resp := new(sync_pb.StateResponse)
+	if err := readStorageRanges(tx, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	entries := readSnapEntries(t, resp)
+	if len(entries) != len(keys) || !resp.Complete || resp.Covered != 3 {
+		t.Fatalf("%d slots, complete %t, covered %d, want %d slots of 3 accounts", len(entries), resp.Complete, resp.Covered, len(keys))
+	}
+	for i, entry := range entries {
+		if entry.Table != stateStorage || !bytes.Equal(entry.Key, keys[i]) {
+			t.Errorf("slot %d is %x of table %d, want %x", i, entry.Key, entry.Table, keys[i])
+		}
+		if bytes.Equal(entry.Key, other[0]) {
+			t.Error("slot of an account that wasn't requested served")
+		}
+	}
+
+	// A cut short answer is resumed from the origin of the first account.
+	req = &sync_pb.StorageRangesRequest{Bytes: 1, Accounts: bytes.Join(accounts, nil)}
+	resp = new(sync_pb.StateResponse)
+	if err := readStorageRanges(tx, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if entries := readSnapEntries(t, resp); len(entries) != 1 || resp.Complete || resp.Covered != 0 {
+		t.Fatalf("%d slots, complete %t, covered %d within a budget of a byte", len(entries), resp.Complete, resp.Covered)
+	}
+	req = &sync_pb.StorageRangesRequest{Accounts: accounts[0]}
+	copy(req.Origin[:], keys[1][types.AddressLength:])
+	resp = new(sync_pb.StateResponse)
+	if err := readStorageRanges(tx, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if entries := readSnapEntries(t, resp); len(entries) != 2 || !bytes.Equal(entries[0].Key, keys[1]) || !resp.Complete || resp.Covered != 1 {
+		t.Fatalf("resumed with %d slots, complete %t, covered %d, want the last 2 slots of the account", len(entries), resp.Complete, resp.Covered)
+	}
+}
+
+func TestReadByteCodes(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	codes := map[types.Hash][]byte{{1}: {0x60, 0x01}, {2}: {0x60, 0x02, 0x00}}
+	for hash, code := range codes {
+		if err := tx.Put(modules.Code, hash.Bytes(), code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hashes := bytes.Join([][]byte{types.Hash{1}.Bytes(), types.Hash{3}.Bytes(), types.Hash{2}.Bytes()}, nil)
+
+	// Unknown codes are left out.
+	resp := &sync_pb.StateResponse{Head: 7}
+	if err := readByteCodes(tx, &sync_pb.ByteCodesRequest{Hashes: hashes}, resp); err != nil {
+		t.Fatal(err)
+	}
+	entries := readSnapEntries(t, resp)
+	if len(entries) != 2 || !resp.Complete || resp.Covered != resp.Head {
+		t.Fatalf("%d codes, complete %t, covered %d, want the 2 known codes", len(entries), resp.Complete, resp.Covered)
+	}
+	for _, entry := range entries {
+		if entry.Table != stateCode || !bytes.Equal(entry.Value, codes[types.BytesToHash(entry.Key)]) {
+			t.Errorf("code %x is %x of table %d", entry.Key, entry.Value, entry.Table)
+		}
+	}
+
+	// Codes past the budget are left out too, and the answer isn't complete.
+	resp = new(sync_pb.StateResponse)
+	if err := readByteCodes(tx, &sync_pb.ByteCodesRequest{Bytes: 1, Hashes: hashes}, resp); err != nil {
+		t.Fatal(err)
+	}
+	if entries := readSnapEntries(t, resp); len(entries) != 1 || resp.Complete {
+		t.Fatalf("%d codes, complete %t within a budget of a byte", len(entries), resp.Complete)
+	}
+}
@@ -0,0 +1,400 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/internal/p2p"
+	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/changeset"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/state/snapshot"
+	"github.com/ledgerwatch/erigon-lib/kv"
+
+	libp2pcore "github.com/libp2p/go-libp2p/core"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// StateTables are the tables transferred by snap sync, indexed by their id on
+// the wire. The first RangeTables of them are downloaded range by range and
+// healed from the changesets, the remaining ones are small and shipped whole
+// with the final changes response.
+var StateTables = []string{
+	modules.Account,
+	modules.Storage,
+	modules.Code,
+	modules.PlainContractCode,
+	modules.IncarnationMap,
+	modules.Reward,
+	modules.Deposit,
+	modules.DepositLifecycle,
+}
+
+// RangeTables is the number of StateTables served by state range requests.
+const RangeTables = 5
+
+// Ids of the StateTables the changes response refers to.
+const (
+	stateAccounts uint64 = iota
+	stateStorage
+	stateCode
+	stateContractCodes
+	stateIncarnations
+)
+
+// StateCodeTable is the id of the contract code table, which is keyed by code
+// hash, so that a single code can be requested through a range starting at it.
+const StateCodeTable = stateCode
+
+const (
+	// maxStateRangeCount is the maximum number of entries in a range response.
+	maxStateRangeCount = 16384
+	// stateResponseBudget is the soft size limit of a state response.
+	stateResponseBudget = 512 * 1024
+	// maxStateChangeBlocks is the maximum number of blocks a changes response covers.
+	maxStateChangeBlocks = 4096
+	// stateChangeEstimate is the estimated encoded size of a changed entry.
+	stateChangeEstimate = 128
+)
+
+// errStateTooLarge is returned if the small state tables outgrow a response.
+var errStateTooLarge = errors.New("state tables exceed response size")
+
+// StateEntry is a single database entry of a state table. An empty value
+// means the key doesn't exist (anymore) at the serving peer's head.
+type StateEntry struct {
+	Table uint64
+	Key   []byte
+	Value []byte
+}
+
+// stateRangeRPCHandler serves a range of a state table at the current head.
+func (s *Service) stateRangeRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.StateRangeHandler")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.StateRangeRequest)
+	if !ok {
+		return errors.New("message is not type *sync_pb.StateRangeRequest")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+
+	if m.Table >= RangeTables || m.Count == 0 {
+		s.writeErrorResponseToStream(responseCodeInvalidRequest, p2ptypes.ErrInvalidRequest.Error(), stream)
+		s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
+		return p2ptypes.ErrInvalidRequest
+	}
+	count := m.Count
+	if count > maxStateRangeCount {
+		count = maxStateRangeCount
+	}
+
+	resp := new(sync_pb.StateResponse)
+	if err := s.cfg.chain.DB().View(ctx, func(tx kv.Tx) error {
+		if err := readStateHead(tx, resp); err != nil {
+			return err
+		}
+		entries, complete, err := readStateRange(tx, m.Table, m.Origin, nil, count, stateResponseBudget)
+		if err != nil {
+			return err
+		}
+		resp.Covered, resp.Complete = resp.Head, complete
+		resp.Entries, err = rlp.EncodeToBytes(entries)
+		return err
+	}); err != nil {
+		s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		return err
+	}
+	return s.writeStateResponse(stream, resp)
+}
+
+// stateChangesRPCHandler serves the current value of the state entries that
+// changed after the requested block, so that a range downloaded over several
+// heads can be brought to a single one.
+func (s *Service) stateChangesRPCHandler(ctx context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	ctx, span := trace.StartSpan(ctx, "sync.StateChangesHandler")
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, respTimeout)
+	defer cancel()
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.StateChangesRequest)
+	if !ok {
+		return errors.New("message is not type *sync_pb.StateChangesRequest")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+
+	resp := new(sync_pb.StateResponse)
+	if err := s.cfg.chain.DB().View(ctx, func(tx kv.Tx) error {
+		if err := readStateHead(tx, resp); err != nil {
+			return err
+		}
+		if m.From > resp.Head {
+			return p2ptypes.ErrInvalidRequest
+		}
+		// The changes of pruned blocks are gone, serving the others would
+		// leave the requester with a state it can't tell is incomplete.
+		pruned, err := rawdb.ReadStatePruneProgress(tx)
+		if err != nil {
+			return err
+		}
+		if m.From+1 < pruned {
+			return fmt.Errorf("%w: state changes below block %d are pruned", p2ptypes.ErrInvalidRequest, pruned)
+		}
+		var diff stateDiff
+		if chain, ok := s.cfg.chain.(snapshotChain); ok {
+			snaps, head, number := chain.Snapshots(), resp.HeadHash, resp.Head
+			diff = func(block uint64) ([][]byte, [][]byte, bool) {
+				return snaps.Diff(block, head, number)
+			}
+		}
+		entries, covered, err := readStateChanges(tx, m.From, resp.Head, diff)
+		if err != nil {
+			return err
+		}
+		resp.Covered = covered
+		if covered == resp.Head {
+			budget := sync_pb.MaxStateEntriesSize / 2
+			for table := uint64(RangeTables); table < uint64(len(StateTables)); table++ {
+				whole, complete, err := readStateRange(tx, table, nil, nil, ^uint64(0), budget)
+				if err != nil {
+					return err
+				}
+				if !complete {
+					return errStateTooLarge
+				}
+				entries = append(entries, whole...)
+			}
+			resp.Complete = true
+		}
+		resp.Entries, err = rlp.EncodeToBytes(entries)
+		return err
+	}); err != nil {
+		if errors.Is(err, p2ptypes.ErrInvalidRequest) {
+			s.writeErrorResponseToStream(responseCodeInvalidRequest, err.Error(), stream)
+		} else {
+			s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		}
+		return err
+	}
+	return s.writeStateResponse(stream, resp)
+}
+
+func (s *Service) writeStateResponse(stream libp2pcore.Stream, resp *sync_pb.StateResponse) error {
+	SetStreamWriteDeadline(stream, defaultWriteDuration)
+	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+		return err
+	}
+	if _, err := streamEncoding(stream).EncodeWithMaxLength(stream, resp); err != nil {
+		return err
+	}
+	closeStream(stream)
+	return nil
+}
+
+// readStateHead fills in the head block the state is read at.
+func readStateHead(tx kv.Tx, resp *sync_pb.StateResponse) error {
+	hash := rawdb.ReadHeadBlockHash(tx)
+	number := rawdb.ReadHeaderNumber(tx, hash)
+	if number == nil {
+		return fmt.Errorf("head block %s not found", hash)
+	}
+	resp.Head, resp.HeadHash = *number, hash
+	return nil
+}
+
+// readStateRange reads up to count entries of a state table from origin on,
+// reporting whether the end of the table, or the limit key if there is one,
+// was reached.
+func readStateRange(tx kv.Tx, table uint64, origin, limit []byte, count uint64, budget int) ([]StateEntry, bool, error) {
+	c, err := tx.Cursor(StateTables[table])
+	if err != nil {
+		return nil, false, err
+	}
+	defer c.Close()
+
+	var (
+		entries []StateEntry
+		size    int
+	)
+	for k, v, err := c.Seek(origin); ; k, v, err = c.Next() {
+		if err != nil {
+			return nil, false, err
+		}
+		if k == nil || (limit != nil && bytes.Compare(k, limit) > 0) {
+			return entries, true, nil
+		}
+		if uint64(len(entries)) >= count || size >= budget {
+			return entries, false, nil
+		}
+		entries = append(entries, StateEntry{Table: table, Key: types.CopyBytes(k), Value: types.CopyBytes(v)})
+		size += len(k) + len(v)
+	}
+}
+
+// snapshotChain is implemented by block chains keeping a state snapshot.
+type snapshotChain interface {
+	Snapshots() *snapshot.Tree
+}
+
+// stateDiff returns the keys of the accounts and storage slots a block wrote,
+// false if they aren't known.
+type stateDiff func(number uint64) (accounts, storage [][]byte, ok bool)
+
+// readStateChanges collects the current value of the accounts and storage
+// slots changed in the blocks after from, returning the last block covered.
+// The keys changed by a block are taken from diff when it knows them, from
+// the changesets otherwise.
+func readStateChanges(tx kv.Tx, from, head uint64, diff stateDiff) ([]StateEntry, uint64, error) {
+	var (
+		accounts = make(map[types.Address]struct{})
+		slots    = make(map[string]struct{})
+		covered  = from
+	)
+	for number := from + 1; number <= head && number-from <= maxStateChangeBlocks; number++ {
+		if changed, storage, ok := diff.keys(number); ok {
+			for _, key := range changed {
+				accounts[types.BytesToAddress(key)] = struct{}{}
+			}
+			for _, key := range storage {
+				slots[string(key)] = struct{}{}
+			}
+		} else if err := readChangeSets(tx, number, accounts, slots); err != nil {
+			return nil, 0, err
+		}
+		covered = number
+		if (len(accounts)+len(slots))*stateChangeEstimate >= stateResponseBudget {
+			break
+		}
+	}
+
+	var entries []StateEntry
+	get := func(table uint64, key []byte) error {
+		v, err := tx.GetOne(StateTables[table], key)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, StateEntry{Table: table, Key: types.CopyBytes(key), Value: types.CopyBytes(v)})
+		return nil
+	}
+	for addr := range accounts {
+		if err := get(stateAccounts, addr[:]); err != nil {
+			return nil, 0, err
+		}
+		if err := get(stateIncarnations, addr[:]); err != nil {
+			return nil, 0, err
+		}
+		// The code of a (re)created contract is keyed by its incarnation.
+		if err := tx.ForPrefix(modules.PlainContractCode, addr[:], func(k, v []byte) error {
+			entries = append(entries, StateEntry{Table: stateContractCodes, Key: types.CopyBytes(k), Value: types.CopyBytes(v)})
+			return get(stateCode, v)
+		}); err != nil {
+			return nil, 0, err
+		}
+	}
+	for key := range slots {
+		if err := get(stateStorage, []byte(key)); err != nil {
+			return nil, 0, err
+		}
+	}
+	return entries, covered, nil
+}
+
+// keys calls the diff if there is one.
+func (diff stateDiff) keys(number uint64) ([][]byte, [][]byte, bool) {
+	if diff == nil {
+		return nil, nil, false
+	}
+	return diff(number)
+}
+
+// readChangeSets adds the keys in the changesets of a block.
+func readChangeSets(tx kv.Tx, number uint64, accounts map[types.Address]struct{}, slots map[string]struct{}) error {
+	prefix := modules.EncodeBlockNumber(number)
+	if err := tx.ForPrefix(modules.AccountChangeSet, prefix, func(k, v []byte) error {
+		_, key, _, err := changeset.DecodeAccounts(k, v)
+		if err != nil {
+			return err
+		}
+		accounts[types.BytesToAddress(key)] = struct{}{}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := tx.ForPrefix(modules.StorageChangeSet, prefix, func(k, v []byte) error {
+		_, key, _, err := changeset.DecodeStorage(k, v)
+		if err != nil {
+			return err
+		}
+		slots[string(key)] = struct{}{}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SendStateRangeRequest requests a range of a state table from the peer.
+func SendStateRangeRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.StateRangeRequest) (*sync_pb.StateResponse, []StateEntry, error) {
+	return sendStateRequest(ctx, p2pProvider, pid, p2p.StateRangeMessageName, req)
+}
+
+// SendStateChangesRequest requests the state changed after a block from the peer.
+func SendStateChangesRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.StateChangesRequest) (*sync_pb.StateResponse, []StateEntry, error) {
+	return sendStateRequest(ctx, p2pProvider, pid, p2p.StateChangesMessageName, req)
+}
+
+func sendStateRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, name string, req interface{}) (*sync_pb.StateResponse, []StateEntry, error) {
+	topic, err := p2p.TopicFromMessage(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	stream, err := p2pProvider.Send(ctx, req, topic, pid)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeStream(stream)
+	return readStateResponse(stream, p2pProvider)
+}
+
+func readStateResponse(stream network.Stream, p2pProvider p2p.EncodingProvider) (*sync_pb.StateResponse, []StateEntry, error) {
+	code, errMsg, err := ReadStatusCode(stream, streamEncoding(stream))
+	if err != nil {
+		return nil, nil, err
+	}
+	if code != 0 {
+		return nil, nil, errors.New(errMsg)
+	}
+	SetStreamReadDeadline(stream, respTimeout)
+	resp := new(sync_pb.StateResponse)
+	if err := streamEncoding(stream).DecodeWithMaxLength(stream, resp); err != nil {
+		return nil, nil, err
+	}
+	var entries []StateEntry
+	if err := rlp.DecodeBytes(resp.Entries, &entries); err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if entry.Table >= uint64(len(StateTables)) || len(entry.Key) == 0 {
+			return nil, nil, ErrInvalidFetchedData
+		}
+	}
+	return resp, entries, nil
+}
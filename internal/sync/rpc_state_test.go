@@ -0,0 +1,180 @@
+package sync
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// writeTestAccounts fills the account table with count entries, returning
+// their keys in order.
+func writeTestAccounts(t *testing.T, tx kv.RwTx, count int) [][]byte {
+	keys := make([][]byte, count)
+	for i := range keys {
+		addr := types.Address{byte(i + 1)}
+		keys[i] = addr[:]
+		if err := tx.Put(modules.Account, keys[i], []byte{0xac, byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+func TestReadStateRange(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	keys := writeTestAccounts(t, tx, 10)
+
+	// Paging through the table as the downloader does yields all of it.
+	var (
+		served [][]byte
+		origin []byte
+	)
+	for pages := 0; ; pages++ {
+		if pages > len(keys) {
+			t.Fatal("range requests don't reach the end of the table")
+		}
+		entries, complete, err := readStateRange(tx, stateAccounts, origin, nil, 3, stateResponseBudget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.Table != stateAccounts || len(entry.Value) == 0 {
+				t.Fatalf("wrong entry %+v", entry)
+			}
+			served = append(served, entry.Key)
+		}
+		if complete {
+			break
+		}
+		if len(entries) != 3 {
+			t.Fatalf("incomplete range of %d entries", len(entries))
+		}
+		origin = append(types.CopyBytes(entries[len(entries)-1].Key), 0)
+	}
+	if !reflect.DeepEqual(served, keys) {
+		t.Fatalf("served keys %x, want %x", served, keys)
+	}
+
+	tests := []struct {
+		name          string
+		origin, limit []byte
+		count         uint64
+		budget        int
+		want          [][]byte
+		complete      bool
+	}{
+		{"whole table", nil, nil, 100, stateResponseBudget, keys, true},
+		{"from a key", keys[7], nil, 100, stateResponseBudget, keys[7:], true},
+		{"between keys", append(types.CopyBytes(keys[7]), 0), nil, 100, stateResponseBudget, keys[8:], true},
+		{"up to a limit", keys[2], keys[5], 100, stateResponseBudget, keys[2:6], true},
+		{"count", nil, nil, 4, stateResponseBudget, keys[:4], false},
+		{"budget", nil, nil, 100, 1, keys[:1], false},
+		{"past the end", types.Address{0xff}.Bytes(), nil, 100, stateResponseBudget, nil, true},
+	}
+	for _, tt := range tests {
+		entries, complete, err := readStateRange(tx, stateAccounts, tt.origin, tt.limit, tt.count, tt.budget)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got [][]byte
+		for _, entry := range entries {
+			got = append(got, entry.Key)
+		}
+		if !reflect.DeepEqual(got, tt.want) || complete != tt.complete {
+			t.Errorf("%s: keys %x complete %v, want %x %v", tt.name, got, complete, tt.want, tt.complete)
+		}
+	}
+}
+
+func TestStateEntriesEncoding(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	writeTestAccounts(t, tx, 3)
+	entries, _, err := readStateRange(tx, stateAccounts, nil, nil, 100, stateResponseBudget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append(entries, StateEntry{Table: stateStorage, Key: []byte{0x01}})
+
+	enc, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []StateEntry
+	if err := rlp.DecodeBytes(enc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(entries) {
+		t.Fatalf("decoded %d entries, want %d", len(decoded), len(entries))
+	}
+	for i, entry := range decoded {
+		want := entries[i]
+		if entry.Table != want.Table || !bytes.Equal(entry.Key, want.Key) || !bytes.Equal(entry.Value, want.Value) {
+			t.Errorf("entry %d: %+v, want %+v", i, entry, want)
+		}
+	}
+}
+
+func TestReadStateChanges(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	keys := writeTestAccounts(t, tx, 2)
+	deleted := types.Address{0xde}
+	slot := append(types.CopyBytes(keys[0]), 0x01)
+	if err := tx.Put(modules.Storage, slot, []byte{0x5e}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Block 2 changed the first account and a slot of it, block 3 deleted
+	// an account. The second account didn't change after block 1.
+	diff := func(number uint64) ([][]byte, [][]byte, bool) {
+		switch number {
+		case 2:
+			return [][]byte{keys[0]}, [][]byte{slot}, true
+		case 3:
+			return [][]byte{deleted[:]}, nil, true
+		}
+		return nil, nil, true
+	}
+	entries, covered, err := readStateChanges(tx, 1, 3, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if covered != 3 {
+		t.Errorf("covered block %d, want 3", covered)
+	}
+	got := make(map[uint64]map[string][]byte)
+	for _, entry := range entries {
+		if got[entry.Table] == nil {
+			got[entry.Table] = make(map[string][]byte)
+		}
+		got[entry.Table][string(entry.Key)] = entry.Value
+	}
+	want := map[uint64]map[string][]byte{
+		stateAccounts:     {string(keys[0]): {0xac, 0x00}, string(deleted[:]): nil},
+		stateIncarnations: {string(keys[0]): nil, string(deleted[:]): nil},
+		stateStorage:      {string(slot): {0x5e}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("changes of %d tables, want %d: %v", len(got), len(want), got)
+	}
+	for table, values := range want {
+		if len(got[table]) != len(values) {
+			t.Errorf("table %d: %d changes, want %d", table, len(got[table]), len(values))
+		}
+		for key, value := range values {
+			if v, ok := got[table][key]; !ok || !bytes.Equal(v, value) {
+				t.Errorf("table %d key %x: value %x (present %v), want %x", table, key, v, ok, value)
+			}
+		}
+	}
+
+	// The changes of the blocks after from only.
+	if entries, _, err = readStateChanges(tx, 3, 3, diff); err != nil || len(entries) != 0 {
+		t.Errorf("changes up to the head: %v, %v", entries, err)
+	}
+}
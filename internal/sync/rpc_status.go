@@ -115,7 +115,7 @@ func (s *Service) sendRPCStatusRequest(ctx context.Context, id peer.ID) error {
 	}
 	defer closeStream(stream)
 
-	code, errMsg, err := ReadStatusCode(stream, s.cfg.p2p.Encoding())
+	code, errMsg, err := ReadStatusCode(stream, streamEncoding(stream))
 	if err != nil {
 		s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
 		return err
@@ -126,7 +126,7 @@ func (s *Service) sendRPCStatusRequest(ctx context.Context, id peer.ID) error {
 		return errors.New(errMsg)
 	}
 	msg := &sync_pb.Status{}
-	if err := s.cfg.p2p.Encoding().DecodeWithMaxLength(stream, msg); err != nil {
+	if err := streamEncoding(stream).DecodeWithMaxLength(stream, msg); err != nil {
 		s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(stream.Conn().RemotePeer())
 		return err
 	}
@@ -145,6 +145,14 @@ func (s *Service) reValidatePeer(ctx context.Context, id peer.ID) error {
 	if err := s.sendRPCStatusRequest(ctx, id); err != nil {
 		return err
 	}
+	if err := s.sendForkIDRequest(ctx, id); err != nil {
+		if errors.Is(err, errIncompatibleForks) {
+			if err := s.sendGoodByeAndDisconnect(ctx, p2ptypes.GoodbyeCodeWrongNetwork, id); err != nil {
+				log.Debug("Could not disconnect with peer", "peer", id, "err", err)
+			}
+		}
+		return err
+	}
 	// Do not return an error for ping requests.
 	if err := s.sendPingRequest(ctx, id); err != nil {
 		log.Debug("Could not ping peer", "err", err)
@@ -193,7 +201,7 @@ func (s *Service) statusRPCHandler(ctx context.Context, msg interface{}, stream
 		}
 
 		originalErr := err
-		resp, err := s.generateErrorResponse(respCode, err.Error())
+		resp, err := createErrorResponse(respCode, err.Error(), streamEncoding(stream))
 		if err != nil {
 			log.Debug("Could not generate a response error", "err", err)
 		} else if _, err := stream.Write(resp); err != nil {
@@ -229,7 +237,7 @@ func (s *Service) respondWithStatus(ctx context.Context, stream network.Stream)
 	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
 		log.Debug("Could not write to stream", "err", err)
 	}
-	_, err := s.cfg.p2p.Encoding().EncodeWithMaxLength(stream, resp)
+	_, err := streamEncoding(stream).EncodeWithMaxLength(stream, resp)
 	return err
 }
 
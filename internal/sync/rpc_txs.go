@@ -0,0 +1,408 @@
+package sync
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/crypto/rand"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/internal/p2p"
+	p2ptypes "github.com/amazechain/amc/internal/p2p/types"
+	"github.com/amazechain/amc/log"
+	event "github.com/amazechain/amc/modules/event/v2"
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	libp2pcore "github.com/libp2p/go-libp2p/core"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/pkg/errors"
+)
+
+const (
+	// txBroadcastInterval is how often the transactions queued for the peers
+	// are sent out, keeping every peer at a couple of messages a second.
+	txBroadcastInterval = 500 * time.Millisecond
+	// maxKnownTxs is the number of transaction hashes remembered per peer.
+	maxKnownTxs = 32768
+	// maxQueuedTxs caps the bodies and the hashes waiting for a slow peer,
+	// the oldest ones are dropped beyond it.
+	maxQueuedTxs = 4096
+	// txListOverhead is the most RLP adds to a list of encoded transactions,
+	// on top of a few bytes per transaction.
+	txListOverhead = 9
+	txItemOverhead = 5
+)
+
+// txPeer is what the node knows of the transactions of a peer, and what is
+// waiting to be sent to it.
+type txPeer struct {
+	known  *lru.Cache[types.Hash, struct{}]
+	txs    []*transaction.Transaction // bodies to push
+	hashes []types.Hash               // transactions to announce
+	busy   bool                       // a send is running
+}
+
+// txPeerLocked returns the state of a peer, creating it if needed. The caller
+// holds txPeersLock.
+func (s *Service) txPeerLocked(pid peer.ID) *txPeer {
+	p, ok := s.txPeers[pid]
+	if !ok {
+		known, _ := lru.New[types.Hash, struct{}](maxKnownTxs)
+		p = &txPeer{known: known}
+		s.txPeers[pid] = p
+	}
+	return p
+}
+
+// markKnownTxs records transactions the peer has, which aren't sent back to it.
+func (s *Service) markKnownTxs(pid peer.ID, hashes []types.Hash) {
+	s.txPeersLock.Lock()
+	defer s.txPeersLock.Unlock()
+	p := s.txPeerLocked(pid)
+	for _, hash := range hashes {
+		p.known.Add(hash, struct{}{})
+	}
+}
+
+// broadcastTxs propagates the transactions entering the pool. A random square
+// root of the peers gets the bodies pushed, the others are only announced the
+// hashes and fetch the transactions they miss, so the bandwidth spent on a
+// transaction hardly grows with the number of peers.
+func (s *Service) broadcastTxs() {
+	txsCh := make(chan common.NewTxsEvent, 16)
+	sub := event.GlobalEvent.Subscribe(txsCh)
+	defer sub.Unsubscribe()
+
+	ticker := time.NewTicker(txBroadcastInterval)
+	defer ticker.Stop()
+
+	random := rand.NewGenerator()
+	for {
+		select {
+		case ev := <-txsCh:
+			s.queueTxs(ev.Txs, random)
+		case <-ticker.C:
+			s.flushTxs()
+		case err := <-sub.Err():
+			log.Error("Transaction broadcast subscription failed", "err", err)
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// queueTxs queues new transactions for the connected peers that don't have them.
+func (s *Service) queueTxs(txs []*transaction.Transaction, random *rand.Rand) {
+	peers := s.cfg.p2p.Peers().Connected()
+	random.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	direct := int(math.Sqrt(float64(len(peers))))
+
+	s.txPeersLock.Lock()
+	defer s.txPeersLock.Unlock()
+	for i, pid := range peers {
+		p := s.txPeerLocked(pid)
+		for _, tx := range txs {
+			hash := tx.Hash()
+			if p.known.Contains(hash) {
+				continue
+			}
+			p.known.Add(hash, struct{}{})
+			if i < direct {
+				p.txs = append(p.txs, tx)
+			} else {
+				p.hashes = append(p.hashes, hash)
+			}
+		}
+		if len(p.txs) > maxQueuedTxs {
+			p.txs = p.txs[len(p.txs)-maxQueuedTxs:]
+		}
+		if len(p.hashes) > maxQueuedTxs {
+			p.hashes = p.hashes[len(p.hashes)-maxQueuedTxs:]
+		}
+	}
+}
+
+// flushTxs sends out what is queued for the peers not busy with an earlier
+// send, and forgets the peers that left.
+func (s *Service) flushTxs() {
+	connected := make(map[peer.ID]struct{})
+	for _, pid := range s.cfg.p2p.Peers().Connected() {
+		connected[pid] = struct{}{}
+	}
+	s.txPeersLock.Lock()
+	defer s.txPeersLock.Unlock()
+	for pid, p := range s.txPeers {
+		if _, ok := connected[pid]; !ok {
+			delete(s.txPeers, pid)
+			continue
+		}
+		if p.busy || (len(p.txs) == 0 && len(p.hashes) == 0) {
+			continue
+		}
+		p.busy = true
+		go s.sendQueuedTxs(pid, p.txs, p.hashes)
+		p.txs, p.hashes = nil, nil
+	}
+}
+
+func (s *Service) sendQueuedTxs(pid peer.ID, txs []*transaction.Transaction, hashes []types.Hash) {
+	defer func() {
+		s.txPeersLock.Lock()
+		if p, ok := s.txPeers[pid]; ok {
+			p.busy = false
+		}
+		s.txPeersLock.Unlock()
+	}()
+	ctx, cancel := context.WithTimeout(s.ctx, respTimeout)
+	defer cancel()
+
+	for len(txs) > 0 {
+		data, n, err := encodeTxs(txs)
+		if err != nil {
+			log.Debug("Could not encode transactions", "err", err)
+			return
+		}
+		txs = txs[n:]
+		if err := SendTxs(ctx, s.cfg.p2p, pid, &sync_pb.Txs{Data: data}); err != nil {
+			log.Trace("Could not send transactions", "peer", pid, "err", err)
+			return
+		}
+	}
+	for len(hashes) > 0 {
+		n := len(hashes)
+		if n > sync_pb.MaxTxAnnounceHashes {
+			n = sync_pb.MaxTxAnnounceHashes
+		}
+		if err := SendTxAnnouncement(ctx, s.cfg.p2p, pid, hashes[:n]); err != nil {
+			log.Trace("Could not announce transactions", "peer", pid, "err", err)
+			return
+		}
+		hashes = hashes[n:]
+	}
+}
+
+// txAnnounceRPCHandler takes the hashes of the transactions a peer has, the
+// missing ones are left to the fetcher.
+func (s *Service) txAnnounceRPCHandler(_ context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.TxHashes)
+	if !ok {
+		return errors.New("message is not type *sync_pb.TxHashes")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+		return err
+	}
+	closeStream(stream)
+
+	pid := stream.Conn().RemotePeer()
+	hashes := splitTxHashes(m.Hashes)
+	s.markKnownTxs(pid, hashes)
+	if !s.txsSyncing() {
+		s.txFetcher.notify(pid, hashes)
+	}
+	return nil
+}
+
+// pooledTxsRPCHandler serves the requested transactions found in the pool.
+func (s *Service) pooledTxsRPCHandler(_ context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.TxHashes)
+	if !ok {
+		return errors.New("message is not type *sync_pb.TxHashes")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+
+	var txs []*transaction.Transaction
+	for _, hash := range splitTxHashes(m.Hashes) {
+		if tx := s.cfg.txPool.GetTx(hash); tx != nil {
+			txs = append(txs, tx)
+		}
+	}
+	// Whatever doesn't fit in a message is left out, as if it wasn't pooled.
+	data, _, err := encodeTxs(txs)
+	if err != nil {
+		s.writeErrorResponseToStream(responseCodeServerError, p2ptypes.ErrGeneric.Error(), stream)
+		return err
+	}
+	SetStreamWriteDeadline(stream, defaultWriteDuration)
+	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+		return err
+	}
+	if _, err := streamEncoding(stream).EncodeWithMaxLength(stream, &sync_pb.Txs{Data: data}); err != nil {
+		return err
+	}
+	closeStream(stream)
+	return nil
+}
+
+// txsRPCHandler adds the transactions pushed by a peer to the pool.
+func (s *Service) txsRPCHandler(_ context.Context, msg interface{}, stream libp2pcore.Stream) error {
+	SetRPCStreamDeadlines(stream)
+
+	m, ok := msg.(*sync_pb.Txs)
+	if !ok {
+		return errors.New("message is not type *sync_pb.Txs")
+	}
+	if err := s.rateLimiter.validateRequest(stream, 1); err != nil {
+		return err
+	}
+	s.rateLimiter.add(stream, 1)
+
+	pid := stream.Conn().RemotePeer()
+	txs, err := decodeTxs(m.Data)
+	if err != nil {
+		s.cfg.p2p.Peers().Scorers().BadResponsesScorer().Increment(pid)
+		s.writeErrorResponseToStream(responseCodeInvalidRequest, err.Error(), stream)
+		return err
+	}
+	if _, err := stream.Write([]byte{responseCodeSuccess}); err != nil {
+		return err
+	}
+	closeStream(stream)
+
+	hashes := make([]types.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	s.markKnownTxs(pid, hashes)
+	s.txFetcher.delivered(hashes)
+	if !s.txsSyncing() && len(txs) > 0 {
+		s.cfg.txPool.AddRemotes(txs)
+	}
+	return nil
+}
+
+// txsSyncing reports whether the chain is still syncing, when the pool can't
+// validate the transactions of the peers.
+func (s *Service) txsSyncing() bool {
+	return s.cfg.initialSync != nil && s.cfg.initialSync.Syncing()
+}
+
+// SendTxAnnouncement tells the peer the hashes of transactions the node has.
+func SendTxAnnouncement(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, hashes []types.Hash) error {
+	req := &sync_pb.TxHashes{Hashes: joinTxHashes(hashes)}
+	return sendTxMessage(ctx, p2pProvider, pid, p2p.TxAnnounceMessageName, req)
+}
+
+// SendTxs pushes transaction bodies to the peer.
+func SendTxs(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, req *sync_pb.Txs) error {
+	return sendTxMessage(ctx, p2pProvider, pid, p2p.TxsMessageName, req)
+}
+
+// SendPooledTxsRequest asks the peer for the transactions of the hashes it
+// announced. The peer leaves out the ones it no longer has.
+func SendPooledTxsRequest(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, hashes []types.Hash) ([]*transaction.Transaction, error) {
+	topic, err := p2p.TopicFromMessage(p2p.PooledTxsMessageName)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := p2pProvider.Send(ctx, &sync_pb.TxHashes{Hashes: joinTxHashes(hashes)}, topic, pid)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStream(stream)
+
+	code, errMsg, err := ReadStatusCode(stream, streamEncoding(stream))
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, errors.New(errMsg)
+	}
+	SetStreamReadDeadline(stream, respTimeout)
+	resp := new(sync_pb.Txs)
+	if err := streamEncoding(stream).DecodeWithMaxLength(stream, resp); err != nil {
+		return nil, err
+	}
+	return decodeTxs(resp.Data)
+}
+
+func sendTxMessage(ctx context.Context, p2pProvider p2p.SenderEncoder, pid peer.ID, name string, req interface{}) error {
+	topic, err := p2p.TopicFromMessage(name)
+	if err != nil {
+		return err
+	}
+	stream, err := p2pProvider.Send(ctx, req, topic, pid)
+	if err != nil {
+		return err
+	}
+	defer closeStream(stream)
+
+	code, errMsg, err := ReadStatusCode(stream, streamEncoding(stream))
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+// encodeTxs encodes as many of the transactions as fit in a message, returning
+// how many it took. The pool keeps transactions well below the message size,
+// so there is always room for at least one.
+func encodeTxs(txs []*transaction.Transaction) ([]byte, int, error) {
+	var (
+		encoded [][]byte
+		size    = txListOverhead
+		n       int
+	)
+	for ; n < len(txs); n++ {
+		data, err := txs[n].Marshal()
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(encoded) > 0 && size+len(data)+txItemOverhead > sync_pb.MaxTxsDataSize {
+			break
+		}
+		encoded = append(encoded, data)
+		size += len(data) + txItemOverhead
+	}
+	data, err := rlp.EncodeToBytes(encoded)
+	return data, n, err
+}
+
+func decodeTxs(data []byte) ([]*transaction.Transaction, error) {
+	var encoded [][]byte
+	if err := rlp.DecodeBytes(data, &encoded); err != nil {
+		return nil, err
+	}
+	txs := make([]*transaction.Transaction, len(encoded))
+	for i, data := range encoded {
+		txs[i] = new(transaction.Transaction)
+		if err := txs[i].Unmarshal(data); err != nil {
+			return nil, err
+		}
+	}
+	return txs, nil
+}
+
+func splitTxHashes(data []byte) []types.Hash {
+	hashes := make([]types.Hash, 0, len(data)/32)
+	for i := 0; i+32 <= len(data); i += 32 {
+		hashes = append(hashes, types.BytesToHash(data[i:i+32]))
+	}
+	return hashes
+}
+
+func joinTxHashes(hashes []types.Hash) []byte {
+	data := make([]byte, 0, len(hashes)*32)
+	for _, hash := range hashes {
+		data = append(data, hash.Bytes()...)
+	}
+	return data
+}
@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common/crypto/rand"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/p2p/peers"
+	"github.com/amazechain/amc/internal/p2p/peers/scorers"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestEncodeTxs(t *testing.T) {
+	// Transactions that don't fit in one message are left to the next ones.
+	big := sync_pb.MaxTxsDataSize / 3
+	txs := []*transaction.Transaction{testTx(1, 0), testTx(2, big), testTx(3, big), testTx(4, big)}
+
+	var decoded []*transaction.Transaction
+	for rest := txs; len(rest) > 0; {
+		data, n, err := encodeTxs(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 || len(data) > sync_pb.MaxTxsDataSize {
+			t.Fatalf("encoded %d transactions in %d bytes", n, len(data))
+		}
+		got, err := decodeTxs(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != n {
+			t.Fatalf("decoded %d transactions, encoded %d", len(got), n)
+		}
+		decoded = append(decoded, got...)
+		rest = rest[n:]
+	}
+	if len(decoded) != len(txs) {
+		t.Fatalf("decoded %d transactions, want %d", len(decoded), len(txs))
+	}
+	for i, tx := range decoded {
+		if tx.Hash() != txs[i].Hash() {
+			t.Errorf("transaction %d decoded as %v, want %v", i, tx.Hash(), txs[i].Hash())
+		}
+	}
+	if _, err := decodeTxs([]byte{0xc1, 0x81, 0x00}); err == nil {
+		t.Error("invalid transaction decoded")
+	}
+}
+
+func TestTxHashesPacking(t *testing.T) {
+	hashes := []types.Hash{{1}, {2}, {3}}
+	data := joinTxHashes(hashes)
+	if len(data) != len(hashes)*32 {
+		t.Fatalf("%d hashes packed in %d bytes", len(hashes), len(data))
+	}
+	// Trailing bytes short of a hash are ignored.
+	got := splitTxHashes(append(data, 0xff))
+	if len(got) != len(hashes) {
+		t.Fatalf("unpacked %d hashes, want %d", len(got), len(hashes))
+	}
+	for i := range got {
+		if got[i] != hashes[i] {
+			t.Errorf("hash %d unpacked as %v, want %v", i, got[i], hashes[i])
+		}
+	}
+}
+
+func TestQueueTxs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	status := peers.NewStatus(ctx, &peers.StatusConfig{PeerLimit: 16, ScorerParams: &scorers.Config{}})
+	pids := make([]peer.ID, 9)
+	for i := range pids {
+		pids[i] = peer.ID(fmt.Sprintf("peer-%d", i))
+		status.Add(nil, pids[i], ma.StringCast(fmt.Sprintf("/ip4/10.0.0.%d/tcp/30303", i+1)), network.DirOutbound)
+		status.SetConnectionState(pids[i], peers.PeerConnected)
+	}
+	s := &Service{
+		ctx:     ctx,
+		cfg:     &config{p2p: &limiterTestP2P{peers: status}},
+		txPeers: make(map[peer.ID]*txPeer),
+	}
+	txs := []*transaction.Transaction{testTx(1, 0), testTx(2, 0)}
+	s.markKnownTxs(pids[0], []types.Hash{txs[0].Hash()})
+
+	// A square root of the peers gets the bodies, the others the hashes, and
+	// nobody what it already has.
+	s.queueTxs(txs, rand.NewGenerator())
+	var pushed, announced int
+	for _, pid := range pids {
+		p := s.txPeers[pid]
+		if len(p.txs) > 0 && len(p.hashes) > 0 {
+			t.Errorf("peer %s both pushed and announced transactions", pid)
+		}
+		want := len(txs)
+		if pid == pids[0] {
+			want--
+		}
+		switch {
+		case len(p.txs) == want:
+			pushed++
+		case len(p.hashes) == want:
+			announced++
+		default:
+			t.Errorf("peer %s queued %d bodies and %d hashes, want %d transactions", pid, len(p.txs), len(p.hashes), want)
+		}
+	}
+	if pushed != 3 || announced != 6 {
+		t.Errorf("%d peers pushed and %d announced the transactions, want 3 and 6", pushed, announced)
+	}
+
+	// Transactions are queued for a peer once.
+	s.queueTxs(txs, rand.NewGenerator())
+	for _, pid := range pids {
+		if p := s.txPeers[pid]; len(p.txs)+len(p.hashes) > len(txs) {
+			t.Errorf("peer %s queued the transactions again", pid)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/amazechain/amc/common"
 	block2 "github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/forkid"
 	"github.com/amazechain/amc/internal/p2p"
 	"github.com/amazechain/amc/utils"
 	lru "github.com/hashicorp/golang-lru/v2"
@@ -54,6 +55,10 @@ type config struct {
 	p2p         p2p.P2P
 	chain       common.IBlockChain
 	initialSync Checker
+	txPool      common.ITxsPool
+
+	lightClients int  // light clients served at a time, none if 0
+	noGossip     bool // don't subscribe to the gossip topics
 }
 
 // This defines the interface for interacting with block chain service
@@ -69,6 +74,12 @@ type Service struct {
 
 	subHandler  *subTopicHandler
 	rateLimiter *limiter
+	forkFilter  forkid.Filter
+	light       *lightClients
+	txFetcher   *txFetcher
+
+	txPeers     map[peer.ID]*txPeer
+	txPeersLock sync.Mutex
 
 	seenBlockCache *lru.Cache[types.Hash, *block2.Block]
 	seenBlockLock  sync.RWMutex
@@ -99,7 +110,15 @@ func NewService(ctx context.Context, opts ...Option) *Service {
 
 	r.subHandler = newSubTopicHandler()
 	r.rateLimiter = newRateLimiter(r.cfg.p2p)
+	r.forkFilter = r.newForkFilter()
 	r.initCaches()
+	if r.cfg.lightClients > 0 {
+		r.light = newLightClients(r.cfg.lightClients)
+	}
+	if r.cfg.txPool != nil {
+		r.txFetcher = newTxFetcher(r.ctx, r.cfg.p2p, r.cfg.txPool)
+		r.txPeers = make(map[peer.ID]*txPeer)
+	}
 
 	r.registerRPCHandlers()
 
@@ -107,7 +126,9 @@ func NewService(ctx context.Context, opts ...Option) *Service {
 	if err != nil {
 		panic("Could not retrieve current fork digest")
 	}
-	r.registerSubscribers(digest)
+	if !r.cfg.noGossip {
+		r.registerSubscribers(digest)
+	}
 	//go r.forkWatcher()
 
 	return r
@@ -128,6 +149,10 @@ func (s *Service) Start() {
 	s.cfg.p2p.AddPingMethod(s.sendPingRequest)
 	s.maintainPeerStatuses()
 	s.resyncIfBehind()
+	if s.cfg.txPool != nil {
+		go s.broadcastTxs()
+		go s.txFetcher.loop()
+	}
 
 	// Update sync metrics.
 	utils.RunEvery(s.ctx, syncMetricsInterval, s.updateMetrics)
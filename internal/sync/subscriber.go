@@ -162,6 +162,10 @@ func (s *Service) subscribeWithBase(topic string, validator wrappedVal, handle s
 // Wrap the pubsub validator with a metric monitoring function. This function increments the
 // appropriate counter if the particular message fails to validate.
 func (s *Service) wrapAndReportValidation(topic string, v wrappedVal) (string, pubsub.ValidatorEx) {
+	format, err := s.replaceForkDigest(strings.TrimSuffix(topic, s.cfg.p2p.Encoding().ProtocolSuffix()))
+	if err != nil {
+		log.Error("Invalid format of pubsub topic", "topic", topic, "err", err)
+	}
 	return topic, func(ctx context.Context, pid peer.ID, msg *pubsub.Message) (res pubsub.ValidationResult) {
 		defer s.handlePanic(ctx, msg)
 		res = pubsub.ValidationIgnore // Default: ignore any message that panics.
@@ -186,7 +190,10 @@ func (s *Service) wrapAndReportValidation(topic string, v wrappedVal) (string, p
 			log.Debug(fmt.Sprintf("Received message from outdated fork digest %#x", retDigest), "topic", topic)
 			return pubsub.ValidationIgnore
 		}
-		b, err := v(ctx, pid, msg)
+		b, err := s.rateLimiter.validateGossip(format, pid, msg.Data)
+		if b == pubsub.ValidationAccept {
+			b, err = v(ctx, pid, msg)
+		}
 
 		var fields = make([]interface{}, 0)
 		fields = append(fields, "topic", topic)
@@ -3,6 +3,7 @@ package sync
 import (
 	"context"
 	block2 "github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/internal/tracing"
 	"github.com/amazechain/amc/log"
 	"google.golang.org/protobuf/proto"
 )
@@ -19,15 +20,19 @@ func (s *Service) blockSubscriber(ctx context.Context, msg proto.Message) error
 
 	log.Info("Subscriber new Block", "hash", iBlock.Header().Hash(), "blockNr", iBlock.Header().Number64().Uint64())
 
+	// The trace of the block starts on its arrival, the import joins it.
+	_, endTrace := tracing.BeginBlock(ctx, iBlock.Hash(), iBlock.Number64().Uint64(), "gossip")
 	if iBlock.Number64().Uint64() > s.cfg.chain.CurrentBlock().Number64().Uint64()+1 {
-		if err := s.cfg.chain.AddFutureBlock(iBlock); err != nil {
-			return err
-		}
+		err := s.cfg.chain.AddFutureBlock(iBlock)
+		endTrace(err)
+		return err
 	} else if _, err := s.cfg.chain.InsertChain(blocks); err != nil {
+		endTrace(err)
 		// todo bad block
 		//if errors.Is(err, Badblock) {
 		s.setBadBlock(ctx, iBlock.Hash())
 		return err
 	}
+	endTrace(nil)
 	return nil
 }
@@ -0,0 +1,227 @@
+package sync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/log"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// txArriveTimeout is how long an announced transaction is given to come in
+	// pushed by some other peer before it is fetched.
+	txArriveTimeout = 500 * time.Millisecond
+	// txFetchInterval is how often the announced transactions are scheduled.
+	txFetchInterval = 250 * time.Millisecond
+	// txFetchTimeout bounds a request for announced transactions.
+	txFetchTimeout = 5 * time.Second
+	// txAnnounceExpiry is how long an announcement is kept if it can't be fetched.
+	txAnnounceExpiry = time.Minute
+	// maxTxAnnouncers is the number of peers remembered to have a transaction.
+	maxTxAnnouncers = 8
+	// maxTxAnnounced caps the transactions waiting to be fetched.
+	maxTxAnnounced = 32768
+	// maxTxFetchInflight caps the transactions requested from a peer and not
+	// delivered yet.
+	maxTxFetchInflight = 2 * sync_pb.MaxPooledTxsHashes
+)
+
+// txAnnounce is a transaction announced by peers and not in the pool yet.
+type txAnnounce struct {
+	announced time.Time
+	peers     []peer.ID // announcers that haven't failed to deliver it
+	fetching  peer.ID   // peer the transaction is requested from, if any
+}
+
+// txFetcher fetches the announced transactions missing from the pool. Every
+// round it batches the transactions waiting for longer than txArriveTimeout
+// into one request per announcer, as long as the peer has room under its
+// in-flight limit. Transactions a peer fails to deliver are asked from the
+// next announcer, those nobody delivers are given up.
+type txFetcher struct {
+	ctx  context.Context
+	p2p  p2p.P2P
+	pool common.ITxsPool
+
+	lock     sync.Mutex
+	waiting  map[types.Hash]*txAnnounce
+	inflight map[peer.ID]int
+}
+
+func newTxFetcher(ctx context.Context, p2p p2p.P2P, pool common.ITxsPool) *txFetcher {
+	return &txFetcher{
+		ctx:      ctx,
+		p2p:      p2p,
+		pool:     pool,
+		waiting:  make(map[types.Hash]*txAnnounce),
+		inflight: make(map[peer.ID]int),
+	}
+}
+
+// notify records transactions announced by a peer.
+func (f *txFetcher) notify(pid peer.ID, hashes []types.Hash) {
+	now := time.Now()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, hash := range hashes {
+		if f.pool.Has(hash) {
+			continue
+		}
+		a, ok := f.waiting[hash]
+		if !ok {
+			if len(f.waiting) >= maxTxAnnounced {
+				continue
+			}
+			a = &txAnnounce{announced: now}
+			f.waiting[hash] = a
+		}
+		if len(a.peers) < maxTxAnnouncers && !containsPeer(a.peers, pid) {
+			a.peers = append(a.peers, pid)
+		}
+	}
+}
+
+// delivered drops the announcements of transactions that came in.
+func (f *txFetcher) delivered(hashes []types.Hash) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, hash := range hashes {
+		delete(f.waiting, hash)
+	}
+}
+
+func (f *txFetcher) loop() {
+	ticker := time.NewTicker(txFetchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.schedule()
+		case <-f.ctx.Done():
+			return
+		}
+	}
+}
+
+// schedule requests the transactions due for fetching.
+func (f *txFetcher) schedule() {
+	for pid, hashes := range f.due(time.Now()) {
+		go f.fetch(pid, hashes)
+	}
+}
+
+// due picks the transactions to request from every announcer and counts them
+// in flight, dropping the announcements that can't be fetched any more.
+func (f *txFetcher) due(now time.Time) map[peer.ID][]types.Hash {
+	requests := make(map[peer.ID][]types.Hash)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for hash, a := range f.waiting {
+		if a.fetching != "" || now.Sub(a.announced) < txArriveTimeout {
+			continue
+		}
+		if len(a.peers) == 0 || now.Sub(a.announced) > txAnnounceExpiry || f.pool.Has(hash) {
+			delete(f.waiting, hash)
+			continue
+		}
+		for _, pid := range a.peers {
+			if len(requests[pid]) < sync_pb.MaxPooledTxsHashes && f.inflight[pid]+len(requests[pid]) < maxTxFetchInflight {
+				requests[pid] = append(requests[pid], hash)
+				a.fetching = pid
+				break
+			}
+		}
+	}
+	for pid, hashes := range requests {
+		f.inflight[pid] += len(hashes)
+	}
+	return requests
+}
+
+// fetch requests transactions from a peer and hands them to the pool.
+func (f *txFetcher) fetch(pid peer.ID, hashes []types.Hash) {
+	ctx, cancel := context.WithTimeout(f.ctx, txFetchTimeout)
+	defer cancel()
+
+	txs, err := SendPooledTxsRequest(ctx, f.p2p, pid, hashes)
+	if err == nil {
+		requested := make(map[types.Hash]struct{}, len(hashes))
+		for _, hash := range hashes {
+			requested[hash] = struct{}{}
+		}
+		for _, tx := range txs {
+			if _, ok := requested[tx.Hash()]; !ok {
+				err = ErrInvalidFetchedData
+				break
+			}
+		}
+	}
+	if err != nil {
+		if err == ErrInvalidFetchedData {
+			f.p2p.Peers().Scorers().BadResponsesScorer().Increment(pid)
+		}
+		log.Trace("Could not fetch announced transactions", "peer", pid, "count", len(hashes), "err", err)
+		txs = nil
+	}
+	f.done(pid, hashes, txs)
+	if len(txs) > 0 {
+		f.pool.AddRemotes(txs)
+	}
+}
+
+// done settles a request: the delivered transactions are dropped, the others
+// are left to the remaining announcers.
+func (f *txFetcher) done(pid peer.ID, hashes []types.Hash, txs []*transaction.Transaction) {
+	got := make(map[types.Hash]struct{}, len(txs))
+	for _, tx := range txs {
+		got[tx.Hash()] = struct{}{}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.inflight[pid] -= len(hashes); f.inflight[pid] <= 0 {
+		delete(f.inflight, pid)
+	}
+	for _, hash := range hashes {
+		a, ok := f.waiting[hash]
+		if !ok {
+			continue
+		}
+		if _, ok := got[hash]; ok {
+			delete(f.waiting, hash)
+			continue
+		}
+		if a.fetching == pid {
+			a.fetching = ""
+		}
+		a.peers = removePeer(a.peers, pid)
+	}
+}
+
+func containsPeer(peers []peer.ID, pid peer.ID) bool {
+	for _, p := range peers {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func removePeer(peers []peer.ID, pid peer.ID) []peer.ID {
+	for i, p := range peers {
+		if p == pid {
+			return append(peers[:i], peers[i+1:]...)
+		}
+	}
+	return peers
+}
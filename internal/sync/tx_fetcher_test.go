@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/holiman/uint256"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// txTestPool is a transaction pool holding the given transactions.
+type txTestPool struct {
+	common.ITxsPool
+	txs map[types.Hash]*transaction.Transaction
+}
+
+func newTxTestPool(txs ...*transaction.Transaction) *txTestPool {
+	p := &txTestPool{txs: make(map[types.Hash]*transaction.Transaction)}
+	for _, tx := range txs {
+		p.txs[tx.Hash()] = tx
+	}
+	return p
+}
+
+func (p *txTestPool) Has(hash types.Hash) bool { return p.txs[hash] != nil }
+
+func (p *txTestPool) GetTx(hash types.Hash) *transaction.Transaction { return p.txs[hash] }
+
+// testTx returns a distinct transaction carrying size bytes of data.
+func testTx(nonce uint64, size int) *transaction.Transaction {
+	from, to := types.Address{0xf}, types.Address{0x7}
+	return transaction.NewTx(&transaction.LegacyTx{
+		Nonce:    nonce,
+		From:     &from,
+		To:       &to,
+		Value:    uint256.NewInt(1),
+		Gas:      21000,
+		GasPrice: uint256.NewInt(1),
+		Data:     make([]byte, size),
+	})
+}
+
+func newTestTxFetcher(t *testing.T, pool common.ITxsPool) *txFetcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return newTxFetcher(ctx, nil, pool)
+}
+
+func TestTxFetcherSchedule(t *testing.T) {
+	pooled := testTx(0, 0)
+	txs := []*transaction.Transaction{testTx(1, 0), testTx(2, 0), testTx(3, 0)}
+	hashes := []types.Hash{txs[0].Hash(), txs[1].Hash(), txs[2].Hash()}
+	f := newTestTxFetcher(t, newTxTestPool(pooled))
+	first, second := peer.ID("first"), peer.ID("second")
+
+	f.notify(first, append(hashes, pooled.Hash()))
+	f.notify(second, hashes[1:])
+	f.notify(first, hashes)
+	announced := time.Now()
+	if len(f.waiting) != len(hashes) {
+		t.Fatalf("%d transactions waiting, want the %d missing from the pool", len(f.waiting), len(hashes))
+	}
+	if requests := f.due(announced); len(requests) != 0 {
+		t.Fatalf("requests %v before the transactions had time to arrive", requests)
+	}
+
+	// Everything is asked from the first announcer, once.
+	due := announced.Add(txArriveTimeout)
+	requests := f.due(due)
+	if len(requests) != 1 || !sameHashes(requests[first], hashes) {
+		t.Fatalf("requests %v, want %x from %s", requests, hashes, first)
+	}
+	if f.inflight[first] != len(hashes) {
+		t.Errorf("%d transactions in flight, want %d", f.inflight[first], len(hashes))
+	}
+	if requests := f.due(due); len(requests) != 0 {
+		t.Fatalf("requests %v while the transactions are being fetched", requests)
+	}
+
+	// What the first peer didn't deliver is asked from the second one.
+	f.done(first, requests[first], txs[:1])
+	requests = f.due(due)
+	if len(requests) != 1 || !sameHashes(requests[second], hashes[1:]) {
+		t.Fatalf("requests %v, want %x from %s", requests, hashes[1:], second)
+	}
+	// Transactions nobody delivers are given up.
+	f.done(second, requests[second], nil)
+	if requests := f.due(due); len(requests) != 0 || len(f.waiting) != 0 || len(f.inflight) != 0 {
+		t.Fatalf("requests %v, %d waiting and %v in flight after all announcers failed", requests, len(f.waiting), f.inflight)
+	}
+}
+
+func TestTxFetcherLimits(t *testing.T) {
+	f := newTestTxFetcher(t, newTxTestPool())
+	pid := peer.ID("announcer")
+
+	hashes := make([]types.Hash, maxTxFetchInflight+1)
+	for i := range hashes {
+		hashes[i] = types.Hash{byte(i >> 8), byte(i)}
+	}
+	f.notify(pid, hashes)
+	now := time.Now()
+
+	// The requests of a peer are capped in size and in flight.
+	due := now.Add(txArriveTimeout)
+	var requested []types.Hash
+	for {
+		requests := f.due(due)
+		if len(requests) == 0 {
+			break
+		}
+		if len(requests[pid]) > sync_pb.MaxPooledTxsHashes {
+			t.Fatalf("request of %d transactions", len(requests[pid]))
+		}
+		requested = append(requested, requests[pid]...)
+	}
+	if len(requested) > maxTxFetchInflight || f.inflight[pid] != len(requested) {
+		t.Fatalf("%d transactions requested and %d in flight, limit %d", len(requested), f.inflight[pid], maxTxFetchInflight)
+	}
+
+	// Delivered transactions and stale announcements are dropped.
+	f.delivered(requested[:1])
+	if _, ok := f.waiting[requested[0]]; ok {
+		t.Error("delivered transaction still waiting")
+	}
+	f.done(pid, requested, nil)
+	if len(f.inflight) != 0 {
+		t.Errorf("%v in flight after the requests are done", f.inflight)
+	}
+	f.notify(pid, requested[1:2])
+	f.due(now.Add(txAnnounceExpiry + time.Second))
+	if len(f.waiting) != 0 {
+		t.Errorf("%d expired announcements left", len(f.waiting))
+	}
+}
+
+func TestTxFetcherAnnouncers(t *testing.T) {
+	f := newTestTxFetcher(t, newTxTestPool())
+	hash := types.Hash{1}
+	for i := 0; i < maxTxAnnouncers+2; i++ {
+		f.notify(peer.ID(rune('a'+i)), []types.Hash{hash})
+	}
+	if n := len(f.waiting[hash].peers); n != maxTxAnnouncers {
+		t.Errorf("%d announcers remembered, want %d", n, maxTxAnnouncers)
+	}
+}
+
+func sameHashes(a, b []types.Hash) bool {
+	set := make(map[types.Hash]struct{}, len(a))
+	for _, hash := range a {
+		set[hash] = struct{}{}
+	}
+	want := make(map[types.Hash]struct{}, len(b))
+	for _, hash := range b {
+		want[hash] = struct{}{}
+	}
+	return len(a) == len(b) && reflect.DeepEqual(set, want)
+}
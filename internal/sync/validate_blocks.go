@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/amazechain/amc/api/protocol/types_pb"
 	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/hash"
+	"github.com/amazechain/amc/common/transaction"
 	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/p2p"
 	"github.com/amazechain/amc/log"
 	"go.opencensus.io/trace"
 	"time"
@@ -15,10 +18,28 @@ import (
 	"github.com/pkg/errors"
 )
 
+// defaultMaxClockDisparity is how far in the future a gossiped block may be
+// stamped, to allow for clock drift between the nodes.
+const defaultMaxClockDisparity = 15 * time.Second
+
 var (
 	ErrOptimisticParent = errors.New("parent of the block is optimistic")
 )
 
+// rejectBlock counts a block rejected by validateBlockPubSub.
+func rejectBlock(reason string, err error) (pubsub.ValidationResult, error) {
+	invalidGossipCounter.WithLabelValues(p2p.BlockTopicFormat, reason).Inc()
+	return pubsub.ValidationReject, err
+}
+
+// maxClockDisparity returns the configured clock disparity, or the default.
+func (s *Service) maxClockDisparity() time.Duration {
+	if d := s.cfg.p2p.GetConfig().MaxClockDisparity; d > 0 {
+		return time.Duration(d) * time.Second
+	}
+	return defaultMaxClockDisparity
+}
+
 // validateBlockPubSub checks that the incoming block has a valid BLS signature.
 // Blocks that have already been seen are ignored. If the BLS signature is any valid signature,
 // this method rebroadcasts the message.
@@ -41,7 +62,7 @@ func (s *Service) validateBlockPubSub(ctx context.Context, pid peer.ID, msg *pub
 	m, err := s.decodePubsubMessage(msg)
 	if err != nil {
 		//tracing.AnnotateError(span, err)
-		return pubsub.ValidationReject, errors.Wrap(err, "Could not decode message")
+		return rejectBlock("decode", errors.Wrap(err, "Could not decode message"))
 	}
 
 	s.validateBlockLock.Lock()
@@ -49,22 +70,35 @@ func (s *Service) validateBlockPubSub(ctx context.Context, pid peer.ID, msg *pub
 
 	blk, ok := m.(*types_pb.Block)
 	if !ok {
-		return pubsub.ValidationReject, errors.New("msg is not types_pb.Block")
+		return rejectBlock("malformed", errors.New("msg is not types_pb.Block"))
 	}
 
 	iBlock := new(block.Block)
 	if err = iBlock.FromProtoMessage(blk); err != nil {
-		return pubsub.ValidationReject, errors.New("block.Block is nil")
+		return rejectBlock("malformed", errors.New("block.Block is nil"))
 	}
 
 	iHeader, iBody := iBlock.Header(), iBlock.Body()
 	header, ok := iHeader.(*block.Header)
 	if !ok {
-		return pubsub.ValidationReject, errors.New("msg.header is not block.Header")
+		return rejectBlock("malformed", errors.New("msg.header is not block.Header"))
 	}
 	_, ok = iBody.(*block.Body)
 	if !ok {
-		return pubsub.ValidationReject, errors.New("msg.body is not types_pb.Block")
+		return rejectBlock("malformed", errors.New("msg.body is not types_pb.Block"))
+	}
+	// Checks that need no state, so junk is dropped before it is relayed.
+	if header.Number == nil || header.Number.IsZero() {
+		return rejectBlock("malformed", errors.New("block has no number"))
+	}
+	if header.GasUsed > header.GasLimit {
+		return rejectBlock("malformed", fmt.Errorf("block #%d uses %d gas over its limit of %d", header.Number.Uint64(), header.GasUsed, header.GasLimit))
+	}
+	if root := hash.DeriveSha(transaction.Transactions(iBlock.Transactions())); root != header.TxHash {
+		return rejectBlock("malformed", fmt.Errorf("block #%d has transaction root %s, header has %s", header.Number.Uint64(), root, header.TxHash))
+	}
+	if ahead := time.Unix(int64(header.Time), 0).Sub(receivedTime); ahead > s.maxClockDisparity() {
+		return rejectBlock("future", fmt.Errorf("block #%d is %v in the future", header.Number.Uint64(), ahead))
 	}
 
 	//todo
@@ -80,7 +114,7 @@ func (s *Service) validateBlockPubSub(ctx context.Context, pid peer.ID, msg *pub
 		s.setBadBlock(ctx, header.Root)
 		err := fmt.Errorf("received block with root %#x that has an invalid parent %#x", header.Root, header.ParentHash)
 		log.Debug("Received block with an invalid parent", "err", err)
-		return pubsub.ValidationReject, err
+		return rejectBlock("bad_parent", err)
 	}
 
 	// Be lenient in handling early blocks. Instead of discarding blocks arriving later than
@@ -93,10 +127,7 @@ func (s *Service) validateBlockPubSub(ctx context.Context, pid peer.ID, msg *pub
 	//}
 
 	// Add metrics for block arrival time subtracts slot start time.
-	if err := captureArrivalTimeMetric(header.Time); err != nil {
-		log.Debug("Ignored block: could not capture arrival time metric", "err", err)
-		return pubsub.ValidationIgnore, nil
-	}
+	captureArrivalTimeMetric(header.Time)
 
 	// Handle block when the parent is unknown.
 	if !s.cfg.chain.HasBlock(header.ParentHash, header.Number.Uint64()-1) {
@@ -130,18 +161,16 @@ func (s *Service) setBadBlock(ctx context.Context, root types.Hash) {
 	s.badBlockCache.Add(root, true)
 }
 
-// This captures metrics for block arrival time.
-func captureArrivalTimeMetric(headerTime uint64) error {
+// This captures metrics for block arrival time. Blocks within the clock
+// disparity ahead of the local time count as arrived right away.
+func captureArrivalTimeMetric(headerTime uint64) {
 	startTime := time.Unix(int64(headerTime), 0)
-	//todo future block
-	if time.Now().Sub(startTime) < 0 {
-		return fmt.Errorf("the block is future block time is %s", startTime.Format(time.RFC3339))
-	}
 	ms := time.Now().Sub(startTime) / time.Millisecond
+	if ms < 0 {
+		ms = 0
+	}
 	arrivalBlockPropagationHistogram.Observe(float64(ms))
 	arrivalBlockPropagationGauge.Set(float64(ms))
-
-	return nil
 }
 
 // isBlockQueueable checks if the slot_time in the block is greater than
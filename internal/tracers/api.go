@@ -83,8 +83,8 @@ type Backend interface {
 	ChainConfig() *params.ChainConfig
 	Engine() consensus.Engine
 	ChainDb() kv.RwDB
-	StateAtBlock(ctx context.Context, tx kv.Tx, block *types.Block /*, reexec uint64, base *state.IntraBlockState, readOnly bool, preferDisk bool*/) (*state.IntraBlockState, error)
-	StateAtTransaction(ctx context.Context, tx kv.Tx, block *types.Block, txIndex int /*, reexec uint64*/) (*transaction.Message, evmtypes.BlockContext, *state.IntraBlockState, error)
+	StateAtBlock(ctx context.Context, tx kv.Tx, block *types.Block, reexec uint64) (*state.IntraBlockState, error)
+	StateAtTransaction(ctx context.Context, tx kv.Tx, block *types.Block, txIndex int, reexec uint64) (*transaction.Message, evmtypes.BlockContext, *state.IntraBlockState, error)
 }
 
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
@@ -596,10 +596,10 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	if err != nil {
 		return nil, err
 	}
-	//reexec := defaultTraceReexec
-	//if config != nil && config.Reexec != nil {
-	//	reexec = *config.Reexec
-	//}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
 
 	rtx, err := api.backend.ChainDb().BeginRo(ctx)
 	if nil != err {
@@ -607,7 +607,7 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	}
 	defer rtx.Rollback()
 
-	statedb, err := api.backend.StateAtBlock(ctx, rtx, parent /*, reexec, nil, true, false*/)
+	statedb, err := api.backend.StateAtBlock(ctx, rtx, parent, reexec)
 	if err != nil {
 		return nil, err
 	}
@@ -648,7 +648,7 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 		// Finalize the state so any modifications are written to the trie
 		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
 		//statedb.SoftFinalise(is158)
-		statedb.FinalizeTx(api.backend.ChainConfig().Rules(block.Number64().Uint64()), state.NewNoopWriter())
+		statedb.FinalizeTx(api.backend.ChainConfig().Rules(block.Number64().Uint64(), block.Time()), state.NewNoopWriter())
 	}
 	return results, nil
 }
@@ -874,10 +874,10 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	if blockNumber == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
-	//reexec := defaultTraceReexec
-	//if config != nil && config.Reexec != nil {
-	//	reexec = *config.Reexec
-	//}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
 	block, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(blockNumber), blockHash)
 	if err != nil {
 		return nil, err
@@ -889,7 +889,7 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	}
 	defer dbTx.Rollback()
 
-	msg, vmctx, statedb, err := api.backend.StateAtTransaction(ctx, dbTx, block, int(index))
+	msg, vmctx, statedb, err := api.backend.StateAtTransaction(ctx, dbTx, block, int(index), reexec)
 	if err != nil {
 		return nil, err
 	}
@@ -932,10 +932,10 @@ func (api *API) TraceCall(ctx context.Context, args api.TransactionArgs, blockNr
 		return nil, err
 	}
 	// try to recompute the state
-	//reexec := defaultTraceReexec
-	//if config != nil && config.Reexec != nil {
-	//	reexec = *config.Reexec
-	//}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
 
 	rtx, err := api.backend.ChainDb().BeginRo(ctx)
 	if nil != err {
@@ -943,7 +943,7 @@ func (api *API) TraceCall(ctx context.Context, args api.TransactionArgs, blockNr
 	}
 	defer rtx.Rollback()
 
-	statedb, err := api.backend.StateAtBlock(ctx, rtx, block /*, reexec, nil, true, false*/)
+	statedb, err := api.backend.StateAtBlock(ctx, rtx, block, reexec)
 	if err != nil {
 		return nil, err
 	}
@@ -956,8 +956,8 @@ func (api *API) TraceCall(ctx context.Context, args api.TransactionArgs, blockNr
 		}
 		config.BlockOverrides.Apply(&vmctx)
 	}
-	// Execute the trace
-	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee64().ToBig())
+	// Execute the trace, pricing the message against the (possibly overridden) base fee
+	msg, err := args.ToMessage(api.backend.RPCGasCap(), vmctx.BaseFee.ToBig())
 	if err != nil {
 		return nil, err
 	}
@@ -19,17 +19,19 @@ package logger
 import (
 	"encoding/json"
 	"io"
-	"math/big"
 
 	"github.com/amazechain/amc/common/math"
 	common "github.com/amazechain/amc/common/types"
 	"github.com/amazechain/amc/internal/vm"
+	"github.com/holiman/uint256"
 )
 
+// JSONLogger writes an EIP-3155 trace of the execution, one JSON object per
+// executed opcode followed by a summary line.
 type JSONLogger struct {
 	encoder *json.Encoder
 	cfg     *Config
-	env     *vm.EVM
+	env     vm.VMInterface
 }
 
 // NewJSONLogger creates a new EVM tracer that prints execution steps as JSON objects
@@ -42,7 +44,7 @@ func NewJSONLogger(cfg *Config, writer io.Writer) *JSONLogger {
 	return l
 }
 
-func (l *JSONLogger) CaptureStart(env *vm.EVM, from, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+func (l *JSONLogger) CaptureStart(env vm.VMInterface, from, to common.Address, create bool, input []byte, gas uint64, value *uint256.Int) {
 	l.env = env
 }
 
@@ -92,7 +94,7 @@ func (l *JSONLogger) CaptureEnd(output []byte, gasUsed uint64, err error) {
 	l.encoder.Encode(endLog{common.Bytes2Hex(output), math.HexOrDecimal64(gasUsed), errMsg})
 }
 
-func (l *JSONLogger) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+func (l *JSONLogger) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *uint256.Int) {
 }
 
 func (l *JSONLogger) CaptureExit(output []byte, gasUsed uint64, err error) {}
@@ -0,0 +1,246 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/params"
+)
+
+const (
+	// otlpTracesPath is the path collectors serve OTLP/HTTP traces on.
+	otlpTracesPath = "/v1/traces"
+
+	maxQueuedSpans = 4096
+	maxBatchSpans  = 512
+	flushInterval  = 5 * time.Second
+	requestTimeout = 10 * time.Second
+
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// exporter batches the ended spans and posts them to the collector in the
+// JSON encoding of ExportTraceServiceRequest. Spans ending while the queue is
+// full are dropped.
+type exporter struct {
+	client   *http.Client
+	url      string
+	headers  map[string]string
+	resource []attribute
+	queue    chan *Span
+}
+
+type attribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes,omitempty"`
+	Status            *otlpStatus `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Setup starts exporting the spans to the configured collector until ctx is
+// cancelled. The tags describe the node in every exported batch.
+func Setup(ctx context.Context, config conf.MetricsConfig, tags map[string]string) error {
+	if !config.Tracing {
+		return nil
+	}
+	if config.TracingSampleRatio <= 0 || config.TracingSampleRatio > 1 {
+		return fmt.Errorf("invalid tracing sample ratio %v, want (0, 1]", config.TracingSampleRatio)
+	}
+	endpoint, err := url.Parse(config.TracingEndpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return fmt.Errorf("invalid tracing endpoint %q, want http(s)://host:port[/path]", config.TracingEndpoint)
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = otlpTracesPath
+	}
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(config.TracingHeaders, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid tracing header %q, want key=value", pair)
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	resource := map[string]interface{}{
+		"service.name":    params.ClientName,
+		"service.version": params.VersionWithCommit(params.GitCommit, params.BuildDate),
+	}
+	for k, v := range tags {
+		resource[k] = v
+	}
+	e := &exporter{
+		client:   &http.Client{Timeout: requestTimeout},
+		url:      endpoint.String(),
+		headers:  headers,
+		resource: attributes(resource),
+		queue:    make(chan *Span, maxQueuedSpans),
+	}
+	ratio = config.TracingSampleRatio
+	exp.Store(e)
+	go e.loop(ctx)
+
+	log.Info("Exporting block import traces", "endpoint", e.url, "sample", ratio)
+	return nil
+}
+
+func (e *exporter) enqueue(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+	}
+}
+
+func (e *exporter) loop(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var (
+		batch   []*Span
+		failing bool
+	)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := e.export(ctx, batch)
+		switch {
+		case err != nil && !failing:
+			log.Warn("Could not export traces", "spans", len(batch), "err", err)
+		case err == nil && failing:
+			log.Info("Exporting traces again")
+		}
+		failing = err != nil
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case span := <-e.queue:
+			if batch = append(batch, span); len(batch) >= maxBatchSpans {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			exp.Store(nil)
+			return
+		}
+	}
+}
+
+func (e *exporter) export(ctx context.Context, batch []*Span) error {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			spans[i].ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		pairs := make(map[string]interface{}, len(s.attrs)/2)
+		for j := 0; j+1 < len(s.attrs); j += 2 {
+			pairs[fmt.Sprint(s.attrs[j])] = s.attrs[j+1]
+		}
+		spans[i].Attributes = attributes(pairs)
+		if s.err != nil {
+			spans[i].Status = &otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": e.resource},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": params.ClientName, "version": params.VersionWithMeta},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// attributes encodes key/value pairs as OTLP attributes, sorted by key.
+func attributes(pairs map[string]interface{}) []attribute {
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]attribute, 0, len(keys))
+	for _, k := range keys {
+		var value map[string]interface{}
+		switch v := pairs[k].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case uint64:
+			value = map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		attrs = append(attrs, attribute{Key: k, Value: value})
+	}
+	return attrs
+}
@@ -0,0 +1,188 @@
+// Package tracing records the stages of the block import pipeline as spans
+// and exports them to an OpenTelemetry collector over OTLP/HTTP.
+//
+// Every imported block is a trace: its root span starts when the block
+// arrives and the verification, execution, commit and broadcast stages are
+// its children. The trace context of a block is kept by hash, so the stages
+// running in other parts of the node join the trace of the block without it
+// being passed along. Tracing is off until Setup is called, all the
+// functions are then no-ops.
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/amazechain/amc/common/types"
+)
+
+// maxTracedBlocks bounds the blocks whose trace is kept open, a leaked trace
+// is dropped by the next ones.
+const maxTracedBlocks = 256
+
+var (
+	exp   atomic.Pointer[exporter]
+	ratio float64
+
+	blocksLock sync.Mutex
+	blocks     = make(map[types.Hash]context.Context)
+	blockOrder []types.Hash
+)
+
+type spanKey struct{}
+
+// Span is a timed stage of a trace. A nil span, as returned when tracing is
+// disabled or the trace not sampled, ignores every call.
+type Span struct {
+	traceID [16]byte
+	spanID  [8]byte
+	parent  [8]byte
+	name    string
+	start   time.Time
+	end     time.Time
+	attrs   []interface{}
+	err     error
+	ended   atomic.Bool
+}
+
+// Enabled reports whether spans are recorded.
+func Enabled() bool {
+	return exp.Load() != nil
+}
+
+// StartRoot begins a new trace, subject to the sampling ratio.
+func StartRoot(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	if !Enabled() || rand.Float64() >= ratio {
+		return ctx, nil
+	}
+	span := &Span{name: name, start: time.Now(), attrs: attrs}
+	crand.Read(span.traceID[:])
+	crand.Read(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Start begins a stage of the trace carried by ctx. Without one nothing is
+// recorded, stages never start traces of their own.
+func Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	return StartAt(ctx, name, time.Now(), attrs...)
+}
+
+// StartAt is Start for a stage that began earlier.
+func StartAt(ctx context.Context, name string, start time.Time, attrs ...interface{}) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &Span{traceID: parent.traceID, parent: parent.spanID, name: name, start: start, attrs: attrs}
+	crand.Read(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span carried by ctx, if any.
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttributes adds key/value pairs to the span, like the logger arguments.
+func (s *Span) SetAttributes(attrs ...interface{}) {
+	if s != nil {
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// RecordError marks the span as failed.
+func (s *Span) RecordError(err error) {
+	if s != nil && err != nil {
+		s.err = err
+	}
+}
+
+// End finishes the span and queues it for export.
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt finishes the span at the given time. Only the first end counts.
+func (s *Span) EndAt(end time.Time) {
+	if s == nil || !s.ended.CompareAndSwap(false, true) {
+		return
+	}
+	s.end = end
+	if e := exp.Load(); e != nil {
+		e.enqueue(s)
+	}
+}
+
+// TraceParent returns the W3C traceparent header of the span, for correlating
+// the logs or other systems with the trace.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// BeginBlock returns the trace context of a block, starting its trace if it
+// has none yet. The returned function ends the trace, when this call started
+// it, recording err as the outcome of the import.
+func BeginBlock(ctx context.Context, hash types.Hash, number uint64, source string) (context.Context, func(err error)) {
+	if !Enabled() {
+		return ctx, func(error) {}
+	}
+	blocksLock.Lock()
+	if traced, ok := blocks[hash]; ok {
+		blocksLock.Unlock()
+		return traced, func(error) {}
+	}
+	blocksLock.Unlock()
+
+	ctx, span := StartRoot(ctx, "block", "number", number, "hash", hash.Hex(), "source", source)
+	if span == nil {
+		return ctx, func(error) {}
+	}
+	blocksLock.Lock()
+	blocks[hash] = ctx
+	blockOrder = append(blockOrder, hash)
+	for len(blockOrder) > maxTracedBlocks {
+		delete(blocks, blockOrder[0])
+		blockOrder = blockOrder[1:]
+	}
+	blocksLock.Unlock()
+
+	return ctx, func(err error) {
+		blocksLock.Lock()
+		if blocks[hash] == ctx {
+			delete(blocks, hash)
+			for i, h := range blockOrder {
+				if h == hash {
+					blockOrder = append(blockOrder[:i], blockOrder[i+1:]...)
+					break
+				}
+			}
+		}
+		blocksLock.Unlock()
+		span.RecordError(err)
+		span.End()
+	}
+}
+
+// BlockContext returns the trace context of a block being imported, or a
+// context without trace.
+func BlockContext(hash types.Hash) context.Context {
+	blocksLock.Lock()
+	defer blocksLock.Unlock()
+	if ctx, ok := blocks[hash]; ok {
+		return ctx
+	}
+	return context.Background()
+}
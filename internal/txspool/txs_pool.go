@@ -30,6 +30,7 @@ import (
 	"math/big"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -40,6 +41,7 @@ import (
 	"github.com/amazechain/amc/common/types"
 	"github.com/amazechain/amc/log"
 	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/modules/rawdb"
 )
 
 const (
@@ -65,6 +67,7 @@ var (
 	ErrUnderpriced        = fmt.Errorf("transaction underpriced")
 	ErrTxPoolOverflow     = fmt.Errorf("txpool is full")
 	ErrReplaceUnderpriced = fmt.Errorf("replacement transaction underpriced")
+	ErrSyncing            = fmt.Errorf("node is syncing")
 
 	ErrFeeCapVeryHigh = fmt.Errorf("max fee per gas higher than 2^256-1")
 
@@ -152,8 +155,10 @@ type TxsPool struct {
 	reorgShutdownCh chan struct{}
 
 	changesSinceReorg int
+	dropped           []common.DroppedTx // dropped since the last DroppedTxsEvent, guarded by mu
 
-	isRun uint32
+	isRun   uint32
+	syncing uint32 // set while the node syncs, remote transactions are refused meanwhile
 
 	deposit *deposit.Deposit
 }
@@ -232,6 +237,7 @@ func (pool *TxsPool) promoteTx(addr types.Address, hash types.Hash, tx *transact
 		// An older transaction was better, discard this
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
+		pool.drop(tx, common.TxReplaced, list.txs.Get(tx.Nonce()).Hash())
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -239,6 +245,7 @@ func (pool *TxsPool) promoteTx(addr types.Address, hash types.Hash, tx *transact
 		hash := old.Hash()
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
+		pool.drop(old, common.TxReplaced, tx.Hash())
 	} else {
 		// Nothing was replaced, bump the pending counter
 		pendingGauge.Inc()
@@ -264,6 +271,15 @@ func (pool *TxsPool) AddLocal(tx *transaction.Transaction) error {
 
 // AddRemotes
 func (pool *TxsPool) AddRemotes(txs []*transaction.Transaction) []error {
+	// Until the sync is over the pool state is stale and the gossiped
+	// transactions can't be validated.
+	if atomic.LoadUint32(&pool.syncing) == 1 {
+		errs := make([]error, len(txs))
+		for i := range errs {
+			errs[i] = ErrSyncing
+		}
+		return errs
+	}
 	return pool.addTxs(txs, false, false)
 }
 
@@ -296,7 +312,9 @@ func (pool *TxsPool) addTxs(txs []*transaction.Transaction, local, sync bool) []
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
 	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	dropped := pool.takeDropped()
 	pool.mu.Unlock()
+	pool.announceDropped(dropped)
 
 	var nilSlot = 0
 	for _, err := range newErrs {
@@ -450,6 +468,7 @@ func (pool *TxsPool) add(tx *transaction.Transaction, local bool) (replaced bool
 			log.Debug("Discarding freshly underpriced transaction", "hash", hash, "gasTipCap", gasPrice, "gasFeeCap", gasPrice)
 			hash := tx.Hash()
 			pool.removeTx(hash, false)
+			pool.drop(tx, common.TxUnderpriced, types.Hash{})
 		}
 	}
 	// Try to replace an existing transaction in the pending pool
@@ -465,6 +484,7 @@ func (pool *TxsPool) add(tx *transaction.Transaction, local bool) (replaced bool
 			hash := old.Hash()
 			pool.all.Remove(hash)
 			pool.priced.Removed(1)
+			pool.drop(old, common.TxReplaced, tx.Hash())
 		}
 		pool.all.Add(tx, isLocal)
 		pool.priced.Put(tx, isLocal)
@@ -494,6 +514,48 @@ func (pool *TxsPool) add(tx *transaction.Transaction, local bool) (replaced bool
 	return replaced, nil
 }
 
+// drop records a transaction leaving the pool for good, to be announced once
+// the pool lock is released. The pool lock must be held.
+func (pool *TxsPool) drop(tx *transaction.Transaction, reason common.TxDropReason, replacement types.Hash) {
+	pool.dropped = append(pool.dropped, common.DroppedTx{Tx: tx, Reason: reason, Replacement: replacement})
+}
+
+// takeDropped returns the transactions dropped since the last call. The pool
+// lock must be held.
+func (pool *TxsPool) takeDropped() []common.DroppedTx {
+	dropped := pool.dropped
+	pool.dropped = nil
+	return dropped
+}
+
+// announceDropped posts the dropped transactions. The stale ones are mostly
+// the transactions a new block included, those aren't dropped but confirmed
+// and are left out.
+func (pool *TxsPool) announceDropped(dropped []common.DroppedTx) {
+	if len(dropped) == 0 {
+		return
+	}
+	if err := pool.bc.DB().View(pool.ctx, func(tx kv.Tx) error {
+		kept := dropped[:0]
+		for _, d := range dropped {
+			if d.Reason == common.TxStale {
+				if number, _ := rawdb.ReadTxLookupEntry(tx, d.Tx.Hash()); number != nil {
+					continue
+				}
+			}
+			kept = append(kept, d)
+		}
+		dropped = kept
+		return nil
+	}); err != nil {
+		log.Warn("Could not check dropped transactions", "err", err)
+		return
+	}
+	if len(dropped) > 0 {
+		event.GlobalEvent.Send(common.DroppedTxsEvent{Txs: dropped})
+	}
+}
+
 // enqueueTx inserts a new transaction into the non-executable transaction queue.
 //
 // Note, this method assumes the pool lock is held!
@@ -513,6 +575,7 @@ func (pool *TxsPool) enqueueTx(hash types.Hash, tx *transaction.Transaction, loc
 		hash := old.Hash()
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
+		pool.drop(old, common.TxReplaced, tx.Hash())
 	} else {
 		queuedGauge.Inc()
 	}
@@ -769,6 +832,7 @@ func (pool *TxsPool) promoteExecutables(accounts []types.Address) []*transaction
 		for _, tx := range forwards {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.drop(tx, common.TxStale, types.Hash{})
 		}
 		//log.Debug("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
@@ -777,6 +841,7 @@ func (pool *TxsPool) promoteExecutables(accounts []types.Address) []*transaction
 		for _, tx := range drops {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.drop(tx, common.TxUnpayable, types.Hash{})
 		}
 		//log.Debug("Removed unpayable queued transactions", "count", len(drops))
 
@@ -798,6 +863,7 @@ func (pool *TxsPool) promoteExecutables(accounts []types.Address) []*transaction
 			for _, tx := range caps {
 				hash := tx.Hash()
 				pool.all.Remove(hash)
+				pool.drop(tx, common.TxOverflow, types.Hash{})
 				//log.Debug("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 		}
@@ -859,6 +925,7 @@ func (pool *TxsPool) truncatePending() {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
 						pool.all.Remove(hash)
+						pool.drop(tx, common.TxOverflow, types.Hash{})
 
 						// Update the account nonce to the dropped transaction
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
@@ -886,6 +953,7 @@ func (pool *TxsPool) truncatePending() {
 					// Drop the transaction from the global pools too
 					hash := tx.Hash()
 					pool.all.Remove(hash)
+					pool.drop(tx, common.TxOverflow, types.Hash{})
 
 					// Update the account nonce to the dropped transaction
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
@@ -933,6 +1001,7 @@ func (pool *TxsPool) truncateQueue() {
 			for _, tx := range list.Flatten() {
 				hash := tx.Hash()
 				pool.removeTx(hash, true)
+				pool.drop(tx, common.TxOverflow, types.Hash{})
 			}
 			drop -= size
 			continue
@@ -942,6 +1011,7 @@ func (pool *TxsPool) truncateQueue() {
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 			hash := txs[i].Hash()
 			pool.removeTx(hash, true)
+			pool.drop(txs[i], common.TxOverflow, types.Hash{})
 			drop--
 		}
 	}
@@ -964,6 +1034,7 @@ func (pool *TxsPool) demoteUnexecutables() {
 		for _, tx := range olds {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.drop(tx, common.TxStale, types.Hash{})
 			//log.Debug("Removed old pending transaction", "hash", hash)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
@@ -972,6 +1043,7 @@ func (pool *TxsPool) demoteUnexecutables() {
 			hash := tx.Hash()
 			//log.Debug("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
+			pool.drop(tx, common.TxUnpayable, types.Hash{})
 		}
 
 		for _, tx := range invalids {
@@ -1061,7 +1133,9 @@ func (pool *TxsPool) runReorg(done chan struct{}, reset *txspoolResetRequest, di
 	pool.truncateQueue()
 
 	pool.changesSinceReorg = 0 // Reset change counter
+	dropped := pool.takeDropped()
 	pool.mu.Unlock()
+	pool.announceDropped(dropped)
 
 	// Notify subsystems for newly added transactions
 	for _, tx := range promoted {
@@ -1168,6 +1242,16 @@ func (pool *TxsPool) blockChangeLoop() {
 	highestSub := event.GlobalEvent.Subscribe(highestBlockCh)
 	defer highestSub.Unsubscribe()
 
+	syncStartedCh := make(chan common.SyncStartedEvent)
+	syncStartedSub := event.GlobalEvent.Subscribe(syncStartedCh)
+	defer syncStartedSub.Unsubscribe()
+	syncFinishedCh := make(chan common.SyncFinishedEvent)
+	syncFinishedSub := event.GlobalEvent.Subscribe(syncFinishedCh)
+	defer syncFinishedSub.Unsubscribe()
+	syncFailedCh := make(chan common.SyncFailedEvent)
+	syncFailedSub := event.GlobalEvent.Subscribe(syncFailedCh)
+	defer syncFailedSub.Unsubscribe()
+
 	oldBlock := pool.bc.CurrentBlock()
 
 	for {
@@ -1181,6 +1265,12 @@ func (pool *TxsPool) blockChangeLoop() {
 				pool.requestReset(oldBlock, pool.bc.CurrentBlock())
 				oldBlock = pool.bc.CurrentBlock()
 			}
+		case <-syncStartedCh:
+			atomic.StoreUint32(&pool.syncing, 1)
+		case <-syncFinishedCh:
+			atomic.StoreUint32(&pool.syncing, 0)
+		case <-syncFailedCh:
+			atomic.StoreUint32(&pool.syncing, 0)
 		}
 	}
 }
@@ -187,8 +187,13 @@ func init() {
 	}
 }
 
-// ActivePrecompiles returns the precompiles enabled with the current configuration.
+// ActivePrecompiles returns the precompiles enabled with the current configuration,
+// including the registered ones.
 func ActivePrecompiles(rules *params.Rules) []types.Address {
+	return activeCustomPrecompiles(rules, builtinPrecompiles(rules))
+}
+
+func builtinPrecompiles(rules *params.Rules) []types.Address {
 	switch {
 	case rules.IsMoran:
 		return PrecompiledAddressesMoran
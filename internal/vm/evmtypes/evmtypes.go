@@ -98,4 +98,6 @@ type IntraBlockState interface {
 	Snapshot() int
 
 	AddLog(*block.Log)
+	// AddPreimage records the input of a hash computed by KECCAK256.
+	AddPreimage(libcommon.Hash, []byte)
 }
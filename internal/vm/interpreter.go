@@ -38,6 +38,10 @@ type Config struct {
 	StatelessExec bool      // true is certain conditions (like state trie root hash matching) need to be relaxed for stateless EVM execution
 	RestoreState  bool      // Revert all changes made to the state (useful for constant system calls)
 
+	EnablePreimageRecording bool // Enables recording of the KECCAK256 preimages
+
+	Profiler *Profiler // Aggregates the gas and time spent per opcode and contract
+
 	ExtraEips []int // Additional EIPS that are to be enabled
 }
 
@@ -90,6 +94,7 @@ type EVMInterpreter struct {
 	*VM
 	jt    *JumpTable // EVM instruction table
 	depth int
+	prof  *profile // collected during the running top level call, while profiling
 }
 
 // structcheck doesn't see embedding
@@ -184,6 +189,27 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	in.depth++
 	defer in.decrementDepth()
 
+	var (
+		prof  *profile
+		stats *contractStats
+	)
+	if in.cfg.Profiler != nil {
+		if in.prof == nil {
+			in.prof = newProfile()
+			defer func() {
+				in.cfg.Profiler.add(in.prof)
+				in.prof = nil
+			}()
+		}
+		prof = in.prof
+		addr := contract.Address()
+		if contract.CodeAddr != nil {
+			addr = *contract.CodeAddr
+		}
+		stats = prof.contract(addr)
+		stats.calls++
+	}
+
 	// Make sure the readOnly is only set if we aren't in readOnly yet.
 	// This makes also sure that the readOnly flag isn't removed for child calls.
 	if readOnly && !in.readOnly {
@@ -298,7 +324,14 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			logged = true
 		}
 		// execute the operation
+		var frame opFrame
+		if prof != nil {
+			frame = prof.begin(contract.Gas + cost)
+		}
 		res, err = operation.execute(pc, in, callContext)
+		if prof != nil {
+			prof.end(frame, op, stats, contract.Gas)
+		}
 
 		if err != nil {
 			break
@@ -0,0 +1,171 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/params"
+)
+
+// PrecompileGasFunc returns the gas a call to a precompiled contract costs.
+type PrecompileGasFunc func(input []byte) uint64
+
+// PrecompileRunFunc executes a precompiled contract on its input.
+type PrecompileRunFunc func(input []byte) ([]byte, error)
+
+// CustomPrecompile is a native contract added by an application built on
+// amc, next to the ones the forks enable. Like those, it must be
+// deterministic: every node of the chain has to register the same contracts.
+type CustomPrecompile struct {
+	Address types.Address
+	// ChainID restricts the contract to the chain with this id, nil
+	// registers it on every chain.
+	ChainID *big.Int
+	// Active reports whether the contract is enabled under the rules of a
+	// block, typically from a fork on. Nil enables it from genesis.
+	Active func(rules *params.Rules) bool
+
+	RequiredGas PrecompileGasFunc
+	Run         PrecompileRunFunc
+}
+
+// customContract adapts a CustomPrecompile to PrecompiledContract.
+type customContract struct {
+	p *CustomPrecompile
+}
+
+func (c customContract) RequiredGas(input []byte) uint64 {
+	return c.p.RequiredGas(input)
+}
+
+func (c customContract) Run(input []byte) ([]byte, error) {
+	return c.p.Run(input)
+}
+
+var (
+	customLock sync.Mutex // serializes registrations
+	// customPrecompiles maps addresses to the contracts registered there,
+	// replaced as a whole on every registration so lookups need no lock.
+	customPrecompiles atomic.Pointer[map[types.Address][]*CustomPrecompile]
+
+	errPrecompileIncomplete = errors.New("precompile needs a gas and a run function")
+)
+
+// RegisterPrecompile adds a precompiled contract. It must be called before the
+// chain starts, usually from an init function. Addresses of the contracts
+// defined by the forks can't be taken, nor can an address be registered twice
+// on the same chain.
+func RegisterPrecompile(p CustomPrecompile) error {
+	if p.RequiredGas == nil || p.Run == nil {
+		return errPrecompileIncomplete
+	}
+	if isBuiltinPrecompile(p.Address) {
+		return fmt.Errorf("address %s is a built-in precompile", p.Address)
+	}
+	customLock.Lock()
+	defer customLock.Unlock()
+
+	registered := make(map[types.Address][]*CustomPrecompile)
+	if current := customPrecompiles.Load(); current != nil {
+		for addr, list := range *current {
+			registered[addr] = list
+		}
+	}
+	for _, other := range registered[p.Address] {
+		if other.ChainID == nil || p.ChainID == nil || other.ChainID.Cmp(p.ChainID) == 0 {
+			return fmt.Errorf("precompile %s already registered", p.Address)
+		}
+	}
+	if p.ChainID != nil {
+		p.ChainID = new(big.Int).Set(p.ChainID)
+	}
+	list := append([]*CustomPrecompile(nil), registered[p.Address]...)
+	registered[p.Address] = append(list, &p)
+	customPrecompiles.Store(&registered)
+	return nil
+}
+
+// MustRegisterPrecompile is like RegisterPrecompile but panics on error.
+func MustRegisterPrecompile(p CustomPrecompile) {
+	if err := RegisterPrecompile(p); err != nil {
+		panic(err)
+	}
+}
+
+func isBuiltinPrecompile(addr types.Address) bool {
+	for _, set := range []map[types.Address]PrecompiledContract{
+		PrecompiledContractsHomestead, PrecompiledContractsByzantium, PrecompiledContractsIstanbul,
+		PrecompiledContractsIstanbulForBSC, PrecompiledContractsNano, PrecompiledContractsIsMoran,
+		PrecompiledContractsBerlin, PrecompiledContractsBLS,
+	} {
+		if _, ok := set[addr]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether the contract is active under the rules.
+func (p *CustomPrecompile) enabled(rules *params.Rules) bool {
+	if p.ChainID != nil && (rules.ChainID == nil || p.ChainID.Cmp(rules.ChainID) != 0) {
+		return false
+	}
+	return p.Active == nil || p.Active(rules)
+}
+
+// customPrecompile returns the registered contract active at addr.
+func customPrecompile(rules *params.Rules, addr types.Address) (PrecompiledContract, bool) {
+	registered := customPrecompiles.Load()
+	if registered == nil {
+		return nil, false
+	}
+	for _, p := range (*registered)[addr] {
+		if p.enabled(rules) {
+			return customContract{p}, true
+		}
+	}
+	return nil, false
+}
+
+// activeCustomPrecompiles appends the addresses of the registered contracts
+// active under the rules to addrs, copying it first if there are any.
+func activeCustomPrecompiles(rules *params.Rules, addrs []types.Address) []types.Address {
+	registered := customPrecompiles.Load()
+	if registered == nil {
+		return addrs
+	}
+	copied := false
+	for addr, list := range *registered {
+		for _, p := range list {
+			if !p.enabled(rules) {
+				continue
+			}
+			if !copied {
+				addrs, copied = append([]types.Address(nil), addrs...), true
+			}
+			addrs = append(addrs, addr)
+			break
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,222 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/common/types"
+)
+
+// OpcodeProfile is what the executions spent on an opcode. Calls and creates
+// only count their own gas and time, not those of the frames they start.
+type OpcodeProfile struct {
+	Op    string        `json:"op"`
+	Count uint64        `json:"count"`
+	Gas   uint64        `json:"gas"`
+	Time  time.Duration `json:"time"` // nanoseconds
+}
+
+// ContractProfile is what the executions spent in the code of a contract.
+type ContractProfile struct {
+	Address types.Address `json:"address"`
+	Calls   uint64        `json:"calls"`
+	Steps   uint64        `json:"steps"`
+	Gas     uint64        `json:"gas"`
+	Time    time.Duration `json:"time"` // nanoseconds
+}
+
+// ProfileReport is a dump of a Profiler, opcodes and contracts most time
+// consuming first.
+type ProfileReport struct {
+	Since     time.Time         `json:"since"`
+	Blocks    uint64            `json:"blocks"`
+	Opcodes   []OpcodeProfile   `json:"opcodes"`
+	Contracts []ContractProfile `json:"contracts"`
+}
+
+type opStats struct {
+	count, gas uint64
+	time       time.Duration
+}
+
+type contractStats struct {
+	calls uint64
+	opStats
+}
+
+// profile is collected by an interpreter during a single top level call,
+// without locking, and added to the profiler when it returns.
+type profile struct {
+	ops       [256]opStats
+	contracts map[types.Address]*contractStats
+
+	// Gas and time of the frames started by the running opcode.
+	childGas  uint64
+	childTime time.Duration
+}
+
+func newProfile() *profile {
+	return &profile{contracts: make(map[types.Address]*contractStats)}
+}
+
+func (p *profile) contract(addr types.Address) *contractStats {
+	stats, ok := p.contracts[addr]
+	if !ok {
+		stats = new(contractStats)
+		p.contracts[addr] = stats
+	}
+	return stats
+}
+
+// opFrame is the state of the profile when an opcode starts.
+type opFrame struct {
+	start     time.Time
+	gas       uint64
+	childGas  uint64
+	childTime time.Duration
+}
+
+func (p *profile) begin(gas uint64) opFrame {
+	f := opFrame{start: time.Now(), gas: gas, childGas: p.childGas, childTime: p.childTime}
+	p.childGas, p.childTime = 0, 0
+	return f
+}
+
+func (p *profile) end(f opFrame, op OpCode, stats *contractStats, gas uint64) {
+	elapsed := time.Since(f.start)
+	var used uint64
+	if gas < f.gas {
+		used = f.gas - gas
+	}
+	ownGas, ownTime := used, elapsed
+	if p.childGas < ownGas {
+		ownGas -= p.childGas
+	} else {
+		ownGas = 0
+	}
+	if p.childTime < ownTime {
+		ownTime -= p.childTime
+	} else {
+		ownTime = 0
+	}
+	s := &p.ops[op]
+	s.count++
+	s.gas += ownGas
+	s.time += ownTime
+	stats.count++
+	stats.gas += ownGas
+	stats.time += ownTime
+	p.childGas, p.childTime = f.childGas+used, f.childTime+elapsed
+}
+
+// Profiler aggregates the gas and the wall time spent per opcode and per
+// contract by every execution it is configured on. It is safe for
+// concurrent use.
+type Profiler struct {
+	lock      sync.Mutex
+	since     time.Time
+	blocks    uint64
+	ops       [256]opStats
+	contracts map[types.Address]*contractStats
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{since: time.Now(), contracts: make(map[types.Address]*contractStats)}
+}
+
+func (p *Profiler) add(prof *profile) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.addLocked(&prof.ops, prof.contracts)
+}
+
+func (p *Profiler) addLocked(ops *[256]opStats, contracts map[types.Address]*contractStats) {
+	for i := range ops {
+		s := &p.ops[i]
+		s.count += ops[i].count
+		s.gas += ops[i].gas
+		s.time += ops[i].time
+	}
+	for addr, c := range contracts {
+		s, ok := p.contracts[addr]
+		if !ok {
+			s = new(contractStats)
+			p.contracts[addr] = s
+		}
+		s.calls += c.calls
+		s.count += c.count
+		s.gas += c.gas
+		s.time += c.time
+	}
+}
+
+// Merge adds what another profiler collected, such as one set on a
+// speculative execution once its outcome is kept.
+func (p *Profiler) Merge(o *Profiler) {
+	o.lock.Lock()
+	ops, contracts := o.ops, o.contracts
+	o.contracts = make(map[types.Address]*contractStats)
+	o.ops = [256]opStats{}
+	o.lock.Unlock()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.addLocked(&ops, contracts)
+}
+
+// CountBlock records that a block was executed.
+func (p *Profiler) CountBlock() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.blocks++
+}
+
+// Reset drops everything collected so far.
+func (p *Profiler) Reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.since, p.blocks = time.Now(), 0
+	p.ops = [256]opStats{}
+	p.contracts = make(map[types.Address]*contractStats)
+}
+
+// Report returns the opcodes executed and the limit contracts that took the
+// most time, all of them if limit isn't positive.
+func (p *Profiler) Report(limit int) *ProfileReport {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	report := &ProfileReport{Since: p.since, Blocks: p.blocks}
+	for i, s := range p.ops {
+		if s.count > 0 {
+			report.Opcodes = append(report.Opcodes, OpcodeProfile{Op: OpCode(i).String(), Count: s.count, Gas: s.gas, Time: s.time})
+		}
+	}
+	sort.Slice(report.Opcodes, func(i, j int) bool { return report.Opcodes[i].Time > report.Opcodes[j].Time })
+
+	for addr, s := range p.contracts {
+		report.Contracts = append(report.Contracts, ContractProfile{Address: addr, Calls: s.calls, Steps: s.count, Gas: s.gas, Time: s.time})
+	}
+	sort.Slice(report.Contracts, func(i, j int) bool { return report.Contracts[i].Time > report.Contracts[j].Time })
+	if limit > 0 && len(report.Contracts) > limit {
+		report.Contracts = report.Contracts[:limit]
+	}
+	return report
+}
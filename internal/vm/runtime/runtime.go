@@ -123,7 +123,7 @@ func Execute(code, input []byte, cfg *Config, bn uint64) ([]byte, *state.IntraBl
 		vmenv   = NewEnv(cfg)
 		sender  = vm2.AccountRef(cfg.Origin)
 	)
-	if rules := cfg.ChainConfig.Rules(vmenv.Context().BlockNumber); rules.IsBerlin {
+	if rules := cfg.ChainConfig.Rules(vmenv.Context().BlockNumber, vmenv.Context().Time); rules.IsBerlin {
 		cfg.State.PrepareAccessList(cfg.Origin, &address, vm2.ActivePrecompiles(rules), nil)
 	}
 	cfg.State.CreateAccount(address, true)
@@ -161,7 +161,7 @@ func Create(input []byte, cfg *Config, blockNr uint64) ([]byte, types.Address, u
 		vmenv  = NewEnv(cfg)
 		sender = vm2.AccountRef(cfg.Origin)
 	)
-	if rules := cfg.ChainConfig.Rules(vmenv.Context().BlockNumber); rules.IsBerlin {
+	if rules := cfg.ChainConfig.Rules(vmenv.Context().BlockNumber, vmenv.Context().Time); rules.IsBerlin {
 		cfg.State.PrepareAccessList(cfg.Origin, nil, vm2.ActivePrecompiles(rules), nil)
 	}
 
@@ -187,7 +187,7 @@ func Call(address types.Address, input []byte, cfg *Config) ([]byte, uint64, err
 
 	sender := cfg.State.GetOrNewStateObject(cfg.Origin)
 	statedb := cfg.State
-	if rules := cfg.ChainConfig.Rules(vmenv.Context().BlockNumber); rules.IsBerlin {
+	if rules := cfg.ChainConfig.Rules(vmenv.Context().BlockNumber, vmenv.Context().Time); rules.IsBerlin {
 		statedb.PrepareAccessList(cfg.Origin, &address, vm2.ActivePrecompiles(rules), nil)
 	}
 
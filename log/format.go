@@ -0,0 +1,57 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import "github.com/sirupsen/logrus"
+
+// jsonAliases renames, in the JSON output, the keys some modules use for the
+// fields most others log under another name, so that aggregators index the
+// calling module, the block number, the peer id and the error under a single
+// key each.
+var jsonAliases = map[string]string{
+	"prefix":      "module",
+	"blockNr":     "number",
+	"blockNumber": "number",
+	"PeerID":      "peer",
+	"pid":         "peer",
+	"error":       "err",
+}
+
+// jsonFormatter writes an entry per line as a JSON object.
+type jsonFormatter struct {
+	logrus.JSONFormatter
+}
+
+func newJSONFormatter() *jsonFormatter {
+	f := new(jsonFormatter)
+	f.TimestampFormat = "2006-01-02 15:04:05"
+	return f
+}
+
+func (f *jsonFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	for from, to := range jsonAliases {
+		v, ok := entry.Data[from]
+		if !ok {
+			continue
+		}
+		if _, taken := entry.Data[to]; !taken {
+			entry.Data[to] = v
+			delete(entry.Data, from)
+		}
+	}
+	return f.JSONFormatter.Format(entry)
+}
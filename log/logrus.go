@@ -67,12 +67,19 @@ func (l *logger) write(msg string, lvl Lvl, ctx []interface{}, skip int) {
 		}
 	}
 
-	if terminal.IsLevelEnabled(logrus.Level(lvl)) {
+	stdLvl, terminalLvl := Lvl(stdLevel.Load()), Lvl(terminalLevel.Load())
+	if rules := moduleLevels.rules.Load(); rules != nil {
+		if modLvl, ok := rules.level(skip - 1); ok {
+			stdLvl, terminalLvl = modLvl, modLvl
+		}
+	}
+
+	if lvl <= terminalLvl {
 		prepareFields()
 		terminal.WithFields(field).Log(logrus.Level(lvl), msg)
 	}
 
-	if std.IsLevelEnabled(logrus.Level(lvl)) {
+	if lvl <= stdLvl {
 		prepareFields()
 		std.WithFields(field).Log(logrus.Level(lvl), msg)
 	}
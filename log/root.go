@@ -52,25 +52,37 @@ const (
 )
 
 func Init(nodeConfig conf.NodeConfig, config conf.LoggerConfig) {
-	formatter := new(prefixed.TextFormatter)
-	formatter.TimestampFormat = "2006-01-02 15:04:05"
-	formatter.FullTimestamp = true
-	formatter.DisableColors = false
-	logrus.SetFormatter(formatter)
+	switch config.Format {
+	case "json":
+		logrus.SetFormatter(newJSONFormatter())
+	default:
+		formatter := new(prefixed.TextFormatter)
+		formatter.TimestampFormat = "2006-01-02 15:04:05"
+		formatter.FullTimestamp = true
+		formatter.DisableColors = false
+		logrus.SetFormatter(formatter)
+	}
 	lvl, _ := logrus.ParseLevel(config.Level)
-	logrus.SetLevel(lvl)
+	SetLevel(Lvl(lvl))
 
-	jsonFormatter := new(logrus.JSONFormatter)
-	jsonFormatter.TimestampFormat = "2006-01-02 15:04:05"
-	terminal.SetFormatter(jsonFormatter)
-	terminal.SetLevel(lvl)
+	filename := config.File
+	if filename == "" {
+		filename = fmt.Sprintf("%s/log/%s", nodeConfig.DataDir, config.LogFile)
+	}
+	terminal.SetFormatter(newJSONFormatter())
 	terminal.SetOutput(&lumberjack.Logger{
-		Filename:   fmt.Sprintf("%s/log/%s", nodeConfig.DataDir, config.LogFile),
+		Filename:   filename,
 		MaxSize:    config.MaxSize,
 		MaxBackups: config.MaxBackups,
 		MaxAge:     config.MaxAge,
 		Compress:   config.Compress,
 	})
+	if config.Format != "" && config.Format != "text" && config.Format != "json" {
+		Warn("Unknown log format, logging text", "format", config.Format)
+	}
+	if err := Vmodule(config.Vmodule); err != nil {
+		Warn("Ignoring the module log levels", "vmodule", config.Vmodule, "err", err)
+	}
 }
 
 func InitMobileLogger(filepath string, isDebug bool) {
@@ -83,9 +95,9 @@ func InitMobileLogger(filepath string, isDebug bool) {
 	formatter.DisableColors = false
 	logrus.SetFormatter(formatter)
 	if isDebug {
-		terminal.SetLevel(logrus.DebugLevel)
+		terminalLevel.Store(int32(LvlDebug))
 	} else {
-		terminal.SetLevel(logrus.InfoLevel)
+		terminalLevel.Store(int32(LvlInfo))
 	}
 	terminal.SetOutput(&lumberjack.Logger{
 		Filename:   filepath,
@@ -0,0 +1,238 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// modulePath is the import path the module names are relative to.
+const modulePath = "github.com/amazechain/amc"
+
+// moduleAliases names the packages of the subsystems most often debugged.
+var moduleAliases = map[string]string{
+	"downloader": "internal/sync",
+	"sync":       "internal/sync",
+	"txpool":     "internal/txspool",
+	"consensus":  "internal/consensus",
+	"miner":      "internal/miner",
+	"p2p":        "internal/p2p",
+	"vm":         "internal/vm",
+	"rpc":        "modules/rpc",
+	"state":      "modules/state",
+}
+
+var lvlNames = [...]string{"crit", "fatal", "error", "warn", "info", "debug", "trace"}
+
+func (l Lvl) String() string {
+	if l >= 0 && int(l) < len(lvlNames) {
+		return lvlNames[l]
+	}
+	return fmt.Sprintf("lvl(%d)", int(l))
+}
+
+// ParseLevel parses a level name: crit, error, warn, info, debug or trace.
+func ParseLevel(s string) (Lvl, error) {
+	for i, name := range lvlNames {
+		if strings.EqualFold(s, name) {
+			return Lvl(i), nil
+		}
+	}
+	lvl, err := logrus.ParseLevel(s)
+	if err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return Lvl(lvl), nil
+}
+
+// The levels of the console and the file output, which a module level
+// replaces for the records logged from that module. The logrus loggers
+// themselves log everything they are handed.
+var (
+	stdLevel      atomic.Int32
+	terminalLevel atomic.Int32
+)
+
+func init() {
+	std.SetLevel(logrus.TraceLevel)
+	terminal.SetLevel(logrus.TraceLevel)
+	stdLevel.Store(int32(LvlInfo))
+	terminalLevel.Store(int32(LvlInfo))
+}
+
+// SetLevel sets the level of the modules without a level of their own.
+func SetLevel(lvl Lvl) {
+	stdLevel.Store(int32(lvl))
+	terminalLevel.Store(int32(lvl))
+}
+
+// Level returns the level of the modules without a level of their own.
+func Level() Lvl {
+	return Lvl(stdLevel.Load())
+}
+
+// moduleLevels is the registry of the levels set per module. A module is a
+// package path relative to the repository, such as internal/sync, and its
+// level holds for the packages below it too unless they have their own.
+var moduleLevels = struct {
+	lock   sync.Mutex
+	levels map[string]Lvl
+	rules  atomic.Pointer[moduleRules]
+}{levels: make(map[string]Lvl)}
+
+// moduleRules is a snapshot of the registry, looked up on every record.
+type moduleRules struct {
+	modules []string // longest first, so the closest ancestor matches
+	levels  map[string]Lvl
+	callers sync.Map // program counter -> Lvl, or -1 for no module level
+}
+
+// level returns the level set for the module of the function skip frames up.
+func (r *moduleRules) level(skip int) (Lvl, bool) {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return 0, false
+	}
+	if lvl, ok := r.callers.Load(pc); ok {
+		return lvl.(Lvl), lvl.(Lvl) >= 0
+	}
+	lvl := Lvl(-1)
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		pkg := callerPackage(fn.Name())
+		for _, module := range r.modules {
+			if pkg == module || strings.HasPrefix(pkg, module+"/") {
+				lvl = r.levels[module]
+				break
+			}
+		}
+	}
+	r.callers.Store(pc, lvl)
+	return lvl, lvl >= 0
+}
+
+// callerPackage returns the package of a function, relative to the repository.
+func callerPackage(fn string) string {
+	slash := strings.LastIndexByte(fn, '/')
+	if dot := strings.IndexByte(fn[slash+1:], '.'); dot >= 0 {
+		fn = fn[:slash+1+dot]
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(fn, modulePath), "/")
+}
+
+// resolveModule turns a module name, alias or full package path into the
+// path the registry keys on.
+func resolveModule(module string) (string, error) {
+	module = strings.Trim(strings.TrimSpace(module), "/")
+	if alias, ok := moduleAliases[module]; ok {
+		return alias, nil
+	}
+	module = strings.TrimPrefix(strings.TrimPrefix(module, modulePath), "/")
+	if module == "" {
+		return "", fmt.Errorf("empty log module")
+	}
+	return module, nil
+}
+
+// SetModuleLevel sets the level of the records logged from a module and the
+// packages below it, whatever the global level is.
+func SetModuleLevel(module string, lvl Lvl) error {
+	path, err := resolveModule(module)
+	if err != nil {
+		return err
+	}
+	moduleLevels.lock.Lock()
+	defer moduleLevels.lock.Unlock()
+	moduleLevels.levels[path] = lvl
+	publishModuleLevels()
+	return nil
+}
+
+// ResetModuleLevel makes a module log at the level of its parent again.
+func ResetModuleLevel(module string) error {
+	path, err := resolveModule(module)
+	if err != nil {
+		return err
+	}
+	moduleLevels.lock.Lock()
+	defer moduleLevels.lock.Unlock()
+	delete(moduleLevels.levels, path)
+	publishModuleLevels()
+	return nil
+}
+
+// Vmodule replaces all the module levels with those of a comma separated
+// list of module=level pairs, such as "internal/sync=debug,txpool=trace".
+// An empty list clears them.
+func Vmodule(spec string) error {
+	levels := make(map[string]Lvl)
+	for _, rule := range strings.Split(spec, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		module, level, ok := strings.Cut(rule, "=")
+		if !ok {
+			return fmt.Errorf("invalid vmodule rule %q, want module=level", rule)
+		}
+		path, err := resolveModule(module)
+		if err != nil {
+			return err
+		}
+		lvl, err := ParseLevel(strings.TrimSpace(level))
+		if err != nil {
+			return err
+		}
+		levels[path] = lvl
+	}
+	moduleLevels.lock.Lock()
+	defer moduleLevels.lock.Unlock()
+	moduleLevels.levels = levels
+	publishModuleLevels()
+	return nil
+}
+
+// ModuleLevels returns the levels set per module.
+func ModuleLevels() map[string]string {
+	moduleLevels.lock.Lock()
+	defer moduleLevels.lock.Unlock()
+	levels := make(map[string]string, len(moduleLevels.levels))
+	for module, lvl := range moduleLevels.levels {
+		levels[module] = lvl.String()
+	}
+	return levels
+}
+
+// publishModuleLevels swaps in the rules of the registry, with an empty cache.
+func publishModuleLevels() {
+	if len(moduleLevels.levels) == 0 {
+		moduleLevels.rules.Store(nil)
+		return
+	}
+	r := &moduleRules{levels: make(map[string]Lvl, len(moduleLevels.levels))}
+	for module, lvl := range moduleLevels.levels {
+		r.modules = append(r.modules, module)
+		r.levels[module] = lvl
+	}
+	sort.Slice(r.modules, func(i, j int) bool { return len(r.modules[i]) > len(r.modules[j]) })
+	moduleLevels.rules.Store(r)
+}
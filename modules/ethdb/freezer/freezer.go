@@ -0,0 +1,262 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package freezer stores the old blocks of the chain in append-only flat
+// files, out of the key-value store that keeps the recent ones.
+//
+// Frozen blocks are canonical and final: the freezer only ever grows at its
+// end, block after block from the genesis on, and is never rewritten. Its
+// files can be copied away at any time as a backup of the cold chain data.
+package freezer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/log"
+	"github.com/gofrs/flock"
+)
+
+// The kinds of block data kept for every frozen block.
+const (
+	Hashes   = "hashes"   // block hash
+	Headers  = "headers"  // header, as stored in the header table
+	Bodies   = "bodies"   // body with its transactions, verifiers and rewards
+	Receipts = "receipts" // receipts, as stored in the receipt table
+)
+
+var kinds = []string{Hashes, Headers, Bodies, Receipts}
+
+var (
+	// ErrUnknownKind is returned for data the freezer doesn't keep.
+	ErrUnknownKind = errors.New("unknown ancient kind")
+
+	errClosed   = errors.New("freezer closed")
+	errReadOnly = errors.New("freezer opened read-only")
+)
+
+// Freezer is a set of tables holding the data of the same blocks.
+type Freezer struct {
+	lock   sync.RWMutex // held for writing while a block is appended
+	tables map[string]*table
+	frozen uint64 // number of blocks frozen
+	flock  *flock.Flock
+
+	readOnly bool // opened next to the process appending, see OpenReadOnly
+}
+
+// Open opens or creates the freezer in dir. An append interrupted by a crash
+// is rolled back to the last block complete in every table.
+func Open(dir string) (*Freezer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	lock := flock.New(filepath.Join(dir, "FLOCK"))
+	if locked, err := lock.TryLock(); err != nil {
+		return nil, err
+	} else if !locked {
+		return nil, fmt.Errorf("freezer %s is in use", dir)
+	}
+	f := &Freezer{tables: make(map[string]*table, len(kinds)), flock: lock}
+	for _, kind := range kinds {
+		t, err := openTable(dir, kind)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.tables[kind] = t
+	}
+	f.frozen = f.tables[Hashes].items
+	for _, t := range f.tables {
+		if t.items < f.frozen {
+			f.frozen = t.items
+		}
+	}
+	for _, t := range f.tables {
+		if err := t.truncate(f.frozen); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// OpenReadOnly opens the freezer in dir for reading only. It takes no lock,
+// so the node owning the freezer may keep appending to it: the blocks it
+// completes are picked up on the next lookup. Nothing is repaired, an
+// interrupted append is only skipped.
+func OpenReadOnly(dir string) (*Freezer, error) {
+	f := &Freezer{tables: make(map[string]*table, len(kinds)), readOnly: true}
+	for _, kind := range kinds {
+		t, err := openTableReadOnly(dir, kind)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.tables[kind] = t
+	}
+	if err := f.refresh(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// refresh counts the blocks the owning process completed in every table.
+func (f *Freezer) refresh() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.tables == nil {
+		return errClosed
+	}
+	frozen := uint64(0)
+	for i, kind := range kinds {
+		items, err := f.tables[kind].reload()
+		if err != nil {
+			return err
+		}
+		if i == 0 || items < frozen {
+			frozen = items
+		}
+	}
+	f.frozen = frozen
+	return nil
+}
+
+// Ancients returns the number of frozen blocks, which are the blocks below it.
+func (f *Freezer) Ancients() uint64 {
+	if f.readOnly {
+		if err := f.refresh(); err != nil && !errors.Is(err, errClosed) {
+			log.Warn("Failed to reload the freezer", "err", err)
+		}
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.frozen
+}
+
+// Ancient returns a kind of data of a frozen block.
+func (f *Freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.tables == nil {
+		return nil, errClosed
+	}
+	t, ok := f.tables[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKind, kind)
+	}
+	if number >= f.frozen {
+		return nil, fmt.Errorf("block %d not frozen: %w", number, errOutOfBounds)
+	}
+	return t.retrieve(number)
+}
+
+// Append freezes the next block. Nothing is durable before Sync.
+func (f *Freezer) Append(number uint64, hash types.Hash, header, body, receipts []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.tables == nil {
+		return errClosed
+	}
+	if f.readOnly {
+		return errReadOnly
+	}
+	if number != f.frozen {
+		return fmt.Errorf("freezing block %d, expected %d", number, f.frozen)
+	}
+	blobs := map[string][]byte{Hashes: hash[:], Headers: header, Bodies: body, Receipts: receipts}
+	for _, kind := range kinds {
+		if err := f.tables[kind].append(number, blobs[kind]); err != nil {
+			// Leave the tables in lockstep.
+			for _, t := range f.tables {
+				t.truncate(number)
+			}
+			return fmt.Errorf("freeze %s of block %d: %w", kind, number, err)
+		}
+	}
+	f.frozen++
+	return nil
+}
+
+// Sync flushes the appended blocks to disk.
+func (f *Freezer) Sync() error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.tables == nil {
+		return errClosed
+	}
+	for _, t := range f.tables {
+		if err := t.sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close syncs and closes the files.
+func (f *Freezer) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.tables == nil {
+		return errClosed
+	}
+	var errs []error
+	for _, t := range f.tables {
+		if !f.readOnly {
+			if err := t.sync(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := t.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	f.tables = nil
+	if f.flock != nil {
+		if err := f.flock.Unlock(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("close freezer: %v", errs)
+	}
+	return nil
+}
+
+// Inspect returns the number of items and the disk space of every kind of
+// data in the freezer in dir, without opening it.
+func Inspect(dir string) (map[string][2]uint64, error) {
+	stats := make(map[string][2]uint64, len(kinds))
+	for _, kind := range kinds {
+		var sizes [2]int64
+		for i, ext := range []string{".idx", ".dat"} {
+			stat, err := os.Stat(filepath.Join(dir, kind+ext))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			sizes[i] = stat.Size()
+		}
+		stats[kind] = [2]uint64{uint64(sizes[0]) / indexEntrySize, uint64(sizes[0] + sizes[1])}
+	}
+	return stats, nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package freezer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+)
+
+// testBlob is the data of kind for block number, the same on every run.
+func testBlob(kind string, number uint64) []byte {
+	return []byte(fmt.Sprintf("%s of block %d", kind, number))
+}
+
+func testHash(number uint64) types.Hash {
+	return types.BytesToHash([]byte{byte(number + 1)})
+}
+
+func appendTestBlocks(t *testing.T, f *Freezer, from, to uint64) {
+	t.Helper()
+	for number := from; number < to; number++ {
+		if err := f.Append(number, testHash(number), testBlob(Headers, number), testBlob(Bodies, number), testBlob(Receipts, number)); err != nil {
+			t.Fatalf("appending block %d: %v", number, err)
+		}
+	}
+}
+
+func checkTestBlocks(t *testing.T, f *Freezer, frozen uint64) {
+	t.Helper()
+	if have := f.Ancients(); have != frozen {
+		t.Fatalf("frozen blocks mismatch: have %d, want %d", have, frozen)
+	}
+	for number := uint64(0); number < frozen; number++ {
+		if hash, err := f.Ancient(Hashes, number); err != nil || !bytes.Equal(hash, testHash(number).Bytes()) {
+			t.Errorf("block %d: hash %x (%v), want %x", number, hash, err, testHash(number))
+		}
+		for _, kind := range []string{Headers, Bodies, Receipts} {
+			if data, err := f.Ancient(kind, number); err != nil || !bytes.Equal(data, testBlob(kind, number)) {
+				t.Errorf("block %d: %s %q (%v), want %q", number, kind, data, err, testBlob(kind, number))
+			}
+		}
+	}
+	if _, err := f.Ancient(Headers, frozen); !errors.Is(err, errOutOfBounds) {
+		t.Errorf("block %d past the frozen ones: have %v, want %v", frozen, err, errOutOfBounds)
+	}
+}
+
+func TestFreezerReopen(t *testing.T) {
+	dir := t.TempDir()
+	f, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestBlocks(t, f, 0, 3)
+	if _, err := Open(dir); err == nil {
+		t.Fatal("opened a freezer in use")
+	}
+	if err := f.Append(4, testHash(4), nil, nil, nil); err == nil {
+		t.Fatal("appended a block out of order")
+	}
+	if _, err := f.Ancient("states", 0); !errors.Is(err, ErrUnknownKind) {
+		t.Fatalf("unknown kind: have %v, want %v", err, ErrUnknownKind)
+	}
+	checkTestBlocks(t, f, 3)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Ancient(Headers, 0); !errors.Is(err, errClosed) {
+		t.Fatalf("closed freezer: have %v, want %v", err, errClosed)
+	}
+
+	if f, err = Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	checkTestBlocks(t, f, 3)
+	appendTestBlocks(t, f, 3, 5)
+	checkTestBlocks(t, f, 5)
+}
+
+// TestFreezerTornWrite reopens the freezer after the files of a table were
+// left behind by an interrupted append, the blocks past the last complete
+// one in every table are dropped.
+func TestFreezerTornWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		tear   func(dir string) error
+		frozen uint64
+	}{
+		{
+			name: "data cut short",
+			tear: func(dir string) error {
+				path := filepath.Join(dir, Bodies+".dat")
+				stat, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				return os.Truncate(path, stat.Size()-1)
+			},
+			frozen: 3,
+		},
+		{
+			name: "partial index entry",
+			tear: func(dir string) error {
+				index, err := os.OpenFile(filepath.Join(dir, Headers+".idx"), os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					return err
+				}
+				defer index.Close()
+				_, err = index.Write([]byte{0, 0, 1})
+				return err
+			},
+			frozen: 4,
+		},
+		{
+			name: "index ahead of the data",
+			tear: func(dir string) error {
+				index, err := os.OpenFile(filepath.Join(dir, Receipts+".idx"), os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					return err
+				}
+				defer index.Close()
+				_, err = index.Write([]byte{0, 0, 0, 0, 0, 0, 1, 0})
+				return err
+			},
+			frozen: 4,
+		},
+		{
+			name: "table behind the others",
+			tear: func(dir string) error {
+				return os.Truncate(filepath.Join(dir, Hashes+".idx"), 2*indexEntrySize)
+			},
+			frozen: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := Open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			appendTestBlocks(t, f, 0, 4)
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.tear(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			if f, err = Open(dir); err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			checkTestBlocks(t, f, tt.frozen)
+			for _, kind := range kinds {
+				if items := f.tables[kind].items; items != tt.frozen {
+					t.Errorf("%s table not truncated: have %d items, want %d", kind, items, tt.frozen)
+				}
+			}
+			appendTestBlocks(t, f, tt.frozen, 5)
+			checkTestBlocks(t, f, 5)
+		})
+	}
+}
+
+func TestFreezerReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	f, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	appendTestBlocks(t, f, 0, 2)
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := OpenReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	checkTestBlocks(t, reader, 2)
+	if err := reader.Append(2, testHash(2), nil, nil, nil); !errors.Is(err, errReadOnly) {
+		t.Fatalf("appending read-only: have %v, want %v", err, errReadOnly)
+	}
+
+	// The reader follows the freezer appending.
+	appendTestBlocks(t, f, 2, 4)
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	checkTestBlocks(t, reader, 4)
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	f, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestBlocks(t, f, 0, 3)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := Inspect(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range kinds {
+		size := uint64(3 * indexEntrySize)
+		for number := uint64(0); number < 3; number++ {
+			if kind == Hashes {
+				size += types.HashLength
+			} else {
+				size += uint64(len(testBlob(kind, number)))
+			}
+		}
+		if have, want := stats[kind], [2]uint64{3, size}; have != want {
+			t.Errorf("%s: have %v, want %v", kind, have, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package freezer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// indexEntrySize is the size of an index entry, the end offset of an item.
+const indexEntrySize = 8
+
+var errOutOfBounds = errors.New("out of bounds")
+
+// table is an append-only store of items numbered from 0. The items follow
+// each other in a data file, and an index file holds the offset each one ends
+// at, so that any of them is read with two positioned reads.
+type table struct {
+	lock  sync.RWMutex
+	data  *os.File
+	index *os.File
+	items uint64 // number of items stored
+	size  uint64 // size of the data file
+}
+
+// openTable opens or creates the files of a table in dir, dropping what an
+// interrupted append left past the last complete item.
+func openTable(dir, name string) (*table, error) {
+	data, err := os.OpenFile(filepath.Join(dir, name+".dat"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(filepath.Join(dir, name+".idx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	t := &table{data: data, index: index}
+	if err := t.repair(); err != nil {
+		t.close()
+		return nil, fmt.Errorf("repair %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// openTableReadOnly opens the files of a table without changing them.
+func openTableReadOnly(dir, name string) (*table, error) {
+	data, err := os.Open(filepath.Join(dir, name+".dat"))
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.Open(filepath.Join(dir, name+".idx"))
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	return &table{data: data, index: index}, nil
+}
+
+// complete returns the number of items whose data is in the data file, and
+// the offset the last of them ends at.
+func (t *table) complete() (items, end uint64, err error) {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	items = uint64(stat.Size()) / indexEntrySize
+	if stat, err = t.data.Stat(); err != nil {
+		return 0, 0, err
+	}
+	size := uint64(stat.Size())
+	for ; items > 0; items-- {
+		if end, err = t.offset(items - 1); err != nil {
+			return 0, 0, err
+		}
+		if end <= size {
+			return items, end, nil
+		}
+	}
+	return 0, 0, nil
+}
+
+// reload catches up with the items appended by another process.
+func (t *table) reload() (uint64, error) {
+	items, end, err := t.complete()
+	if err != nil {
+		return 0, err
+	}
+	t.lock.Lock()
+	t.items, t.size = items, end
+	t.lock.Unlock()
+	return items, nil
+}
+
+// repair brings the index and the data file back in line with each other.
+func (t *table) repair() error {
+	// Drop the items whose data didn't make it to disk.
+	items, end, err := t.complete()
+	if err != nil {
+		return err
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(end)); err != nil {
+		return err
+	}
+	t.items, t.size = items, end
+	return nil
+}
+
+// offset reads the end offset of an item from the index.
+func (t *table) offset(item uint64) (uint64, error) {
+	var buf [indexEntrySize]byte
+	if _, err := t.index.ReadAt(buf[:], int64(item*indexEntrySize)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// append adds the next item.
+func (t *table) append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if item != t.items {
+		return fmt.Errorf("appending item %d, expected %d", item, t.items)
+	}
+	if _, err := t.data.WriteAt(blob, int64(t.size)); err != nil {
+		return err
+	}
+	var buf [indexEntrySize]byte
+	binary.BigEndian.PutUint64(buf[:], t.size+uint64(len(blob)))
+	if _, err := t.index.WriteAt(buf[:], int64(item*indexEntrySize)); err != nil {
+		return err
+	}
+	t.items++
+	t.size += uint64(len(blob))
+	return nil
+}
+
+// retrieve reads an item.
+func (t *table) retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if item >= t.items {
+		return nil, errOutOfBounds
+	}
+	start := uint64(0)
+	if item > 0 {
+		var err error
+		if start, err = t.offset(item - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, err := t.offset(item)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, end-start)
+	if _, err := t.data.ReadAt(blob, int64(start)); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// truncate drops the items from the given one on.
+func (t *table) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if items >= t.items {
+		return nil
+	}
+	end := uint64(0)
+	if items > 0 {
+		var err error
+		if end, err = t.offset(items - 1); err != nil {
+			return err
+		}
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(end)); err != nil {
+		return err
+	}
+	t.items, t.size = items, end
+	return nil
+}
+
+func (t *table) sync() error {
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+func (t *table) close() error {
+	err := t.data.Close()
+	if ierr := t.index.Close(); err == nil {
+		err = ierr
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package freezer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTableTruncate(t *testing.T) {
+	dir := t.TempDir()
+	tab, err := openTable(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { tab.close() }()
+	blobs := [][]byte{[]byte("zero"), {}, []byte("two"), []byte("three")}
+	for i, blob := range blobs {
+		if err := tab.append(uint64(i), blob); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tab.append(0, blobs[0]); err == nil {
+		t.Fatal("appended an item over another")
+	}
+
+	if err := tab.truncate(uint64(len(blobs))); err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.truncate(2); err != nil {
+		t.Fatal(err)
+	}
+	if tab.items != 2 || tab.size != uint64(len(blobs[0])) {
+		t.Fatalf("truncated to %d items of %d bytes, want 2 of %d", tab.items, tab.size, len(blobs[0]))
+	}
+	if _, err := tab.retrieve(2); !errors.Is(err, errOutOfBounds) {
+		t.Fatalf("truncated item: have %v, want %v", err, errOutOfBounds)
+	}
+	if err := tab.append(2, []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]byte{blobs[0], blobs[1], []byte("again")} {
+		if have, err := tab.retrieve(uint64(i)); err != nil || !bytes.Equal(have, want) {
+			t.Errorf("item %d: have %q (%v), want %q", i, have, err, want)
+		}
+	}
+
+	// The files were cut, reopening finds the same items.
+	if err := tab.close(); err != nil {
+		t.Fatal(err)
+	}
+	if tab, err = openTable(dir, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if have, err := tab.retrieve(2); tab.items != 3 || err != nil || !bytes.Equal(have, []byte("again")) {
+		t.Fatalf("reopened %d items, last %q (%v)", tab.items, have, err)
+	}
+	if err := tab.truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if tab.items != 0 || tab.size != 0 {
+		t.Fatalf("truncated to %d items of %d bytes, want none", tab.items, tab.size)
+	}
+}
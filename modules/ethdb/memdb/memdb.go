@@ -0,0 +1,63 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package memdb provides chain databases held in memory, for tests and
+// throwaway nodes. Unlike an in-memory MDBX they need no temporary directory.
+package memdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/pebbledb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var initTables sync.Once
+
+// New returns an empty in-memory database with the chain tables.
+func New() kv.RwDB {
+	initTables.Do(modules.AmcInit)
+	db, err := pebbledb.OpenInMemory(modules.AmcTableCfg)
+	if err != nil {
+		// Nothing touches the disk, only a broken configuration fails.
+		panic(err)
+	}
+	return db
+}
+
+// NewTestDB returns an in-memory database closed at the end of the test.
+func NewTestDB(tb testing.TB) kv.RwDB {
+	tb.Helper()
+	db := New()
+	tb.Cleanup(db.Close)
+	return db
+}
+
+// NewTestTx returns an in-memory database and a write transaction on it, both
+// released at the end of the test.
+func NewTestTx(tb testing.TB) (kv.RwDB, kv.RwTx) {
+	tb.Helper()
+	db := NewTestDB(tb)
+	tx, err := db.BeginRw(context.Background())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(tx.Rollback)
+	return db, tx
+}
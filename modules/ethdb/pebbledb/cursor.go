@@ -0,0 +1,426 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pebbledb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/cockroachdb/pebble"
+)
+
+// cursor walks a table. It remembers the Pebble key of its current item rather
+// than relying on the iterator: an iterator doesn't see the writes made to the
+// batch after it was opened, so after a write the next move reopens it and
+// seeks back to the current item first.
+type cursor struct {
+	tx *tx
+	t  *table
+
+	it     *pebble.Iterator
+	writes uint64 // writes of the transaction when it was opened
+	cur    []byte // Pebble key of the current item, nil before the first move
+	at     bool   // it is positioned on cur
+	closed bool
+}
+
+// iter returns an iterator over the table that sees all the writes so far.
+func (c *cursor) iter() (*pebble.Iterator, error) {
+	if c.closed || c.tx.done {
+		return nil, errTxDone
+	}
+	if c.it != nil && c.writes == c.tx.writes {
+		return c.it, nil
+	}
+	if c.it != nil {
+		c.it.Close()
+	}
+	c.it = c.tx.reader().NewIter(&pebble.IterOptions{LowerBound: c.t.prefix, UpperBound: c.t.upper})
+	c.writes, c.at = c.tx.writes, false
+	return c.it, nil
+}
+
+// land makes the item the iterator moved to the current one. A failed move
+// leaves the current item as it was.
+func (c *cursor) land(ok bool) ([]byte, []byte, error) {
+	if !ok {
+		c.at = false
+		return nil, nil, c.it.Error()
+	}
+	raw := types.CopyBytes(c.it.Key())
+	k, v, valid := c.t.decode(raw)
+	if !valid {
+		c.at = false
+		return nil, nil, fmt.Errorf("pebbledb: malformed key %x in table %s", raw, c.t.name)
+	}
+	c.cur, c.at = raw, true
+	if !c.t.dup {
+		v = types.CopyBytes(c.it.Value())
+	}
+	return k, v, nil
+}
+
+// within moves to the first item from start on, if it is below end.
+func (c *cursor) within(start, end []byte) ([]byte, []byte, error) {
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !it.SeekGE(start) || bytes.Compare(it.Key(), end) >= 0 {
+		c.at = false
+		return nil, nil, it.Error()
+	}
+	return c.land(true)
+}
+
+func (c *cursor) First() ([]byte, []byte, error) {
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.land(it.First())
+}
+
+func (c *cursor) Last() ([]byte, []byte, error) {
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.land(it.Last())
+}
+
+// Seek moves to the first key not below seek.
+func (c *cursor) Seek(seek []byte) ([]byte, []byte, error) {
+	if len(seek) == 0 {
+		return c.First()
+	}
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.land(it.SeekGE(c.t.seekKey(seek)))
+}
+
+// seekBelow moves to the last key below seek.
+func (c *cursor) seekBelow(seek []byte) ([]byte, []byte, error) {
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.land(it.SeekLT(c.t.seekKey(seek)))
+}
+
+// SeekExact moves to key, to its first value in a DupSort table.
+func (c *cursor) SeekExact(key []byte) ([]byte, []byte, error) {
+	if c.t.dup {
+		return c.within(c.t.dupRange(key))
+	}
+	raw := c.t.key(key)
+	v, ok, err := c.tx.get(raw)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+	c.cur, c.at = raw, false
+	return types.CopyBytes(key), v, nil
+}
+
+func (c *cursor) Next() ([]byte, []byte, error) {
+	if c.cur == nil {
+		return c.First()
+	}
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.at {
+		return c.land(it.Next())
+	}
+	// The current item may be gone, then the next one is the first above it.
+	ok := it.SeekGE(c.cur)
+	if ok && bytes.Equal(it.Key(), c.cur) {
+		ok = it.Next()
+	}
+	return c.land(ok)
+}
+
+func (c *cursor) Prev() ([]byte, []byte, error) {
+	if c.cur == nil {
+		return c.Last()
+	}
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.at {
+		return c.land(it.Prev())
+	}
+	return c.land(it.SeekLT(c.cur))
+}
+
+func (c *cursor) Current() ([]byte, []byte, error) {
+	if c.cur == nil {
+		return nil, nil, nil
+	}
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.at {
+		return c.land(true)
+	}
+	if !it.SeekGE(c.cur) || !bytes.Equal(it.Key(), c.cur) {
+		c.at = false
+		return nil, nil, it.Error()
+	}
+	return c.land(true)
+}
+
+// Count returns the number of items of the table, every value of a DupSort
+// table counting as one. Pebble keeps no counts, the table is walked.
+func (c *cursor) Count() (uint64, error) {
+	return c.count(c.t.prefix, c.t.upper)
+}
+
+func (c *cursor) count(start, end []byte) (uint64, error) {
+	if c.closed || c.tx.done {
+		return 0, errTxDone
+	}
+	it := c.tx.reader().NewIter(&pebble.IterOptions{LowerBound: start, UpperBound: end})
+	defer it.Close()
+	var n uint64
+	for ok := it.First(); ok; ok = it.Next() {
+		n++
+	}
+	return n, it.Error()
+}
+
+// Put stores k and v and makes them the current item.
+func (c *cursor) Put(k, v []byte) error {
+	raw, val := c.t.key(k), v
+	if c.t.dup {
+		raw, val = c.t.dupKey(k, v), nil
+	}
+	if err := c.tx.set(raw, val); err != nil {
+		return err
+	}
+	c.cur, c.at = raw, false
+	return nil
+}
+
+func (c *cursor) Append(k, v []byte) error { return c.Put(k, v) }
+
+// Delete removes k, with all its values in a DupSort table.
+func (c *cursor) Delete(k []byte) error {
+	if c.t.dup {
+		start, end := c.t.dupRange(k)
+		return c.tx.delRange(start, end)
+	}
+	return c.tx.del(c.t.key(k))
+}
+
+// DeleteCurrent removes the current item; Next then moves to the item after it.
+func (c *cursor) DeleteCurrent() error {
+	if c.cur == nil {
+		return nil
+	}
+	return c.tx.del(c.cur)
+}
+
+// currentKey returns the table key of the current item.
+func (c *cursor) currentKey() ([]byte, bool) {
+	if c.cur == nil {
+		return nil, false
+	}
+	k, _, ok := c.t.decode(c.cur)
+	return k, ok
+}
+
+func (c *cursor) SeekBothExact(key, value []byte) ([]byte, []byte, error) {
+	if !c.t.dup {
+		k, v, err := c.SeekExact(key)
+		if err != nil || k == nil || !bytes.Equal(v, value) {
+			return nil, nil, err
+		}
+		return k, v, nil
+	}
+	raw := c.t.dupKey(key, value)
+	_, ok, err := c.tx.get(raw)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+	c.cur, c.at = raw, false
+	return types.CopyBytes(key), types.CopyBytes(value), nil
+}
+
+// SeekBothRange moves to the first value of key not below value.
+func (c *cursor) SeekBothRange(key, value []byte) ([]byte, error) {
+	if !c.t.dup {
+		_, v, err := c.SeekExact(key)
+		if err != nil || bytes.Compare(v, value) < 0 {
+			return nil, err
+		}
+		return v, nil
+	}
+	_, end := c.t.dupRange(key)
+	_, v, err := c.within(c.t.dupKey(key, value), end)
+	return v, err
+}
+
+func (c *cursor) FirstDup() ([]byte, error) {
+	k, ok := c.currentKey()
+	if !ok {
+		return nil, nil
+	}
+	if !c.t.dup {
+		_, v, err := c.Current()
+		return v, err
+	}
+	_, v, err := c.within(c.t.dupRange(k))
+	return v, err
+}
+
+func (c *cursor) LastDup() ([]byte, error) {
+	k, ok := c.currentKey()
+	if !ok {
+		return nil, nil
+	}
+	if !c.t.dup {
+		_, v, err := c.Current()
+		return v, err
+	}
+	start, end := c.t.dupRange(k)
+	it, err := c.iter()
+	if err != nil {
+		return nil, err
+	}
+	if !it.SeekLT(end) || bytes.Compare(it.Key(), start) < 0 {
+		c.at = false
+		return nil, it.Error()
+	}
+	_, v, err := c.land(true)
+	return v, err
+}
+
+// NextDup moves to the next value of the current key, if there is one.
+func (c *cursor) NextDup() ([]byte, []byte, error) {
+	return c.stepDup(c.Next)
+}
+
+// PrevDup moves to the previous value of the current key, if there is one.
+func (c *cursor) PrevDup() ([]byte, []byte, error) {
+	return c.stepDup(c.Prev)
+}
+
+// stepDup moves by one item, staying in place past the values of the key.
+func (c *cursor) stepDup(step func() ([]byte, []byte, error)) ([]byte, []byte, error) {
+	key, ok := c.currentKey()
+	if !ok || !c.t.dup {
+		return nil, nil, nil
+	}
+	cur := c.cur
+	k, v, err := step()
+	if err != nil || k == nil || !bytes.Equal(k, key) {
+		c.cur, c.at = cur, false
+		return nil, nil, err
+	}
+	return k, v, nil
+}
+
+// NextNoDup moves to the first value of the next key.
+func (c *cursor) NextNoDup() ([]byte, []byte, error) {
+	k, ok := c.currentKey()
+	if !ok || !c.t.dup {
+		return c.Next()
+	}
+	_, end := c.t.dupRange(k)
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.land(it.SeekGE(end))
+}
+
+// PrevNoDup moves to the last value of the previous key.
+func (c *cursor) PrevNoDup() ([]byte, []byte, error) {
+	k, ok := c.currentKey()
+	if !ok || !c.t.dup {
+		return c.Prev()
+	}
+	start, _ := c.t.dupRange(k)
+	it, err := c.iter()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.land(it.SeekLT(start))
+}
+
+func (c *cursor) CountDuplicates() (uint64, error) {
+	k, ok := c.currentKey()
+	if !ok {
+		return 0, nil
+	}
+	if !c.t.dup {
+		return 1, nil
+	}
+	return c.count(c.t.dupRange(k))
+}
+
+func (c *cursor) PutNoDupData(key, value []byte) error { return c.Put(key, value) }
+func (c *cursor) AppendDup(key, value []byte) error    { return c.Put(key, value) }
+
+// DeleteCurrentDuplicates removes every value of the current key.
+func (c *cursor) DeleteCurrentDuplicates() error {
+	k, ok := c.currentKey()
+	if !ok {
+		return nil
+	}
+	if !c.t.dup {
+		return c.DeleteCurrent()
+	}
+	start, end := c.t.dupRange(k)
+	return c.tx.delRange(start, end)
+}
+
+// DeleteExact removes the value k2 of the key k1.
+func (c *cursor) DeleteExact(k1, k2 []byte) error {
+	if !c.t.dup {
+		v, ok, err := c.tx.get(c.t.key(k1))
+		if err != nil || !ok || !bytes.Equal(v, k2) {
+			return err
+		}
+		return c.tx.del(c.t.key(k1))
+	}
+	return c.tx.del(c.t.dupKey(k1, k2))
+}
+
+func (c *cursor) Close() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if c.it != nil {
+		c.it.Close()
+		c.it = nil
+	}
+	for i, open := range c.tx.cursors {
+		if open == c {
+			c.tx.cursors = append(c.tx.cursors[:i], c.tx.cursors[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pebbledb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/mdbx"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const (
+	testTable    = "Plain"
+	testDupTable = "Dup"
+)
+
+var testTables = kv.TableCfg{
+	testTable:    {},
+	testDupTable: {Flags: kv.DupSort},
+}
+
+// forEachBackend runs the test against MDBX and Pebble, for Pebble to be
+// held to the behaviour of MDBX.
+func forEachBackend(t *testing.T, test func(t *testing.T, db kv.RwDB)) {
+	t.Run("mdbx", func(t *testing.T) {
+		db := mdbx.NewMDBX(log.New()).InMem(t.TempDir()).WithTableCfg(func(kv.TableCfg) kv.TableCfg { return testTables }).MustOpen()
+		t.Cleanup(db.Close)
+		test(t, db)
+	})
+	t.Run("pebble", func(t *testing.T) {
+		db, err := OpenInMemory(testTables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(db.Close)
+		test(t, db)
+	})
+}
+
+func update(t *testing.T, db kv.RwDB, f func(tx kv.RwTx) error) {
+	t.Helper()
+	if err := db.Update(context.Background(), f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func view(t *testing.T, db kv.RwDB, f func(tx kv.Tx) error) {
+	t.Helper()
+	if err := db.View(context.Background(), f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pairs renders key/value pairs for comparisons.
+func pairs(kvs ...[]byte) string {
+	var b bytes.Buffer
+	for i := 0; i+1 < len(kvs); i += 2 {
+		fmt.Fprintf(&b, "%s=%s ", kvs[i], kvs[i+1])
+	}
+	return b.String()
+}
+
+func fill(t *testing.T, db kv.RwDB, table string, kvs ...string) {
+	t.Helper()
+	update(t, db, func(tx kv.RwTx) error {
+		for i := 0; i < len(kvs); i += 2 {
+			if err := tx.Put(table, []byte(kvs[i]), []byte(kvs[i+1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestPutGetDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db kv.RwDB) {
+		fill(t, db, testTable, "a", "1", "b", "2", "c", "3")
+		update(t, db, func(tx kv.RwTx) error {
+			if err := tx.Put(testTable, []byte("b"), []byte("20")); err != nil {
+				return err
+			}
+			if err := tx.Delete(testTable, []byte("c")); err != nil {
+				return err
+			}
+			// Deleting a missing key is no error.
+			return tx.Delete(testTable, []byte("z"))
+		})
+		view(t, db, func(tx kv.Tx) error {
+			for key, want := range map[string]string{"a": "1", "b": "20", "c": "", "z": ""} {
+				v, err := tx.GetOne(testTable, []byte(key))
+				if err != nil {
+					return err
+				}
+				if string(v) != want {
+					t.Errorf("%s = %q, want %q", key, v, want)
+				}
+				if has, err := tx.Has(testTable, []byte(key)); err != nil || has != (want != "") {
+					t.Errorf("has %s = %v, %v", key, has, err)
+				}
+			}
+			return nil
+		})
+
+		// A rolled back transaction leaves nothing behind.
+		tx, err := db.BeginRw(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put(testTable, []byte("d"), []byte("4")); err != nil {
+			t.Fatal(err)
+		}
+		tx.Rollback()
+		view(t, db, func(tx kv.Tx) error {
+			if has, _ := tx.Has(testTable, []byte("d")); has {
+				t.Error("rolled back put is visible")
+			}
+			return nil
+		})
+	})
+}
+
+func TestCursor(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db kv.RwDB) {
+		fill(t, db, testTable, "b", "1", "d", "2", "f", "3")
+		view(t, db, func(tx kv.Tx) error {
+			c, err := tx.Cursor(testTable)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			steps := []struct {
+				name string
+				move func() ([]byte, []byte, error)
+				want string
+			}{
+				{"first", c.First, "b=1 "},
+				{"next", c.Next, "d=2 "},
+				{"next", c.Next, "f=3 "},
+				{"next past the end", c.Next, "="},
+				{"last", c.Last, "f=3 "},
+				{"prev", c.Prev, "d=2 "},
+				{"prev", c.Prev, "b=1 "},
+				{"prev before the start", c.Prev, "="},
+				{"seek between keys", func() ([]byte, []byte, error) { return c.Seek([]byte("c")) }, "d=2 "},
+				{"current", c.Current, "d=2 "},
+				{"seek a key", func() ([]byte, []byte, error) { return c.Seek([]byte("f")) }, "f=3 "},
+				{"seek past the end", func() ([]byte, []byte, error) { return c.Seek([]byte("g")) }, "="},
+				{"seek exact missing", func() ([]byte, []byte, error) { return c.SeekExact([]byte("c")) }, "="},
+				{"seek exact", func() ([]byte, []byte, error) { return c.SeekExact([]byte("d")) }, "d=2 "},
+			}
+			for _, step := range steps {
+				k, v, err := step.move()
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				got := pairs(k, v)
+				if k == nil {
+					got = "="
+				}
+				if got != step.want {
+					t.Errorf("%s: got %q, want %q", step.name, got, step.want)
+				}
+			}
+			if n, err := c.Count(); err != nil || n != 3 {
+				t.Errorf("count = %d, %v", n, err)
+			}
+			return nil
+		})
+
+		update(t, db, func(tx kv.RwTx) error {
+			c, err := tx.RwCursor(testTable)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			if err := c.Put([]byte("a"), []byte("0")); err != nil {
+				return err
+			}
+			if _, _, err := c.SeekExact([]byte("d")); err != nil {
+				return err
+			}
+			if err := c.DeleteCurrent(); err != nil {
+				return err
+			}
+			return c.Delete([]byte("f"))
+		})
+		view(t, db, func(tx kv.Tx) error {
+			var got []byte
+			err := tx.ForEach(testTable, nil, func(k, v []byte) error {
+				got = append(got, pairs(k, v)...)
+				return nil
+			})
+			if want := "a=0 b=1 "; string(got) != want {
+				t.Errorf("table holds %q, want %q", got, want)
+			}
+			return err
+		})
+	})
+}
+
+func TestRange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db kv.RwDB) {
+		fill(t, db, testTable, "a1", "1", "a2", "2", "b1", "3", "b2", "4", "c1", "5")
+		view(t, db, func(tx kv.Tx) error {
+			collect := func(name string, walk func(func(k, v []byte) error) error, want string) {
+				var got []byte
+				if err := walk(func(k, v []byte) error {
+					got = append(got, pairs(k, v)...)
+					return nil
+				}); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if string(got) != want {
+					t.Errorf("%s: got %q, want %q", name, got, want)
+				}
+			}
+			collect("for each from", func(w func(k, v []byte) error) error {
+				return tx.ForEach(testTable, []byte("b"), w)
+			}, "b1=3 b2=4 c1=5 ")
+			collect("for prefix", func(w func(k, v []byte) error) error {
+				return tx.ForPrefix(testTable, []byte("b"), w)
+			}, "b1=3 b2=4 ")
+			collect("for amount", func(w func(k, v []byte) error) error {
+				return tx.ForAmount(testTable, []byte("a2"), 2, w)
+			}, "a2=2 b1=3 ")
+			collect("range", func(w func(k, v []byte) error) error {
+				it, err := tx.Range(testTable, []byte("a2"), []byte("c1"))
+				if err != nil {
+					return err
+				}
+				for it.HasNext() {
+					k, v, err := it.Next()
+					if err != nil {
+						return err
+					}
+					w(k, v)
+				}
+				return nil
+			}, "a2=2 b1=3 b2=4 ")
+			collect("range descending", func(w func(k, v []byte) error) error {
+				it, err := tx.RangeDescend(testTable, []byte("b2"), []byte("a1"), -1)
+				if err != nil {
+					return err
+				}
+				for it.HasNext() {
+					k, v, err := it.Next()
+					if err != nil {
+						return err
+					}
+					w(k, v)
+				}
+				return nil
+			}, "b2=4 b1=3 a2=2 ")
+			return nil
+		})
+	})
+}
+
+func TestDupSortCursor(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db kv.RwDB) {
+		fill(t, db, testDupTable, "a", "3", "a", "1", "a", "2", "b", "1", "c", "9", "c", "5")
+		view(t, db, func(tx kv.Tx) error {
+			c, err := tx.CursorDupSort(testDupTable)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			steps := []struct {
+				name string
+				move func() ([]byte, []byte, error)
+				want string
+			}{
+				{"first", c.First, "a=1 "},
+				{"next dup", c.NextDup, "a=2 "},
+				{"next", c.Next, "a=3 "},
+				{"next dup past the key", c.NextDup, "="},
+				{"seek both exact", func() ([]byte, []byte, error) { return c.SeekBothExact([]byte("a"), []byte("2")) }, "a=2 "},
+				{"next no dup", c.NextNoDup, "b=1 "},
+				{"next no dup", c.NextNoDup, "c=5 "},
+				{"next no dup past the end", c.NextNoDup, "="},
+				{"last", c.Last, "c=9 "},
+				{"prev dup", c.PrevDup, "c=5 "},
+				{"prev no dup", c.PrevNoDup, "b=1 "},
+				{"prev", c.Prev, "a=3 "},
+				{"seek both range", func() ([]byte, []byte, error) {
+					v, err := c.SeekBothRange([]byte("c"), []byte("6"))
+					return []byte("c"), v, err
+				}, "c=9 "},
+			}
+			for _, step := range steps {
+				k, v, err := step.move()
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				got := pairs(k, v)
+				if k == nil {
+					got = "="
+				}
+				if got != step.want {
+					t.Errorf("%s: got %q, want %q", step.name, got, step.want)
+				}
+			}
+
+			if _, _, err := c.SeekExact([]byte("a")); err != nil {
+				return err
+			}
+			if n, err := c.CountDuplicates(); err != nil || n != 3 {
+				t.Errorf("duplicates of a = %d, %v", n, err)
+			}
+			if v, err := c.LastDup(); err != nil || string(v) != "3" {
+				t.Errorf("last dup of a = %q, %v", v, err)
+			}
+			if v, err := c.FirstDup(); err != nil || string(v) != "1" {
+				t.Errorf("first dup of a = %q, %v", v, err)
+			}
+			if v, err := c.SeekBothRange([]byte("b"), []byte("2")); err != nil || v != nil {
+				t.Errorf("seek both range past the values of b = %q, %v", v, err)
+			}
+			return nil
+		})
+
+		update(t, db, func(tx kv.RwTx) error {
+			c, err := tx.RwCursorDupSort(testDupTable)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			if err := c.DeleteExact([]byte("a"), []byte("2")); err != nil {
+				return err
+			}
+			if _, _, err := c.SeekExact([]byte("c")); err != nil {
+				return err
+			}
+			return c.DeleteCurrentDuplicates()
+		})
+		view(t, db, func(tx kv.Tx) error {
+			var got []byte
+			err := tx.ForEach(testDupTable, nil, func(k, v []byte) error {
+				got = append(got, pairs(k, v)...)
+				return nil
+			})
+			if want := "a=1 a=3 b=1 "; string(got) != want {
+				t.Errorf("table holds %q, want %q", got, want)
+			}
+			return err
+		})
+	})
+}
@@ -0,0 +1,294 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pebbledb stores the chain database in Pebble, the LSM key-value
+// store of CockroachDB, as an alternative to MDBX on filesystems where a large
+// memory mapped file performs poorly.
+//
+// Pebble has a single flat keyspace, so every table is a key prefix: the table
+// name followed by a zero byte. DupSort tables keep each key/value pair as a
+// key of its own, the key being escaped so that the pairs sort by key first
+// and by value next, as they do in MDBX. Tables converting their long keys for
+// MDBX (AutoDupSortKeysConversion) are plain tables here.
+package pebbledb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/amazechain/amc/internal/metrics/prometheus"
+	"github.com/amazechain/amc/log"
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/bloom"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+const (
+	// cacheSize is the size of the block cache, memCacheSize that of an
+	// in-memory database, whose tables are already in memory.
+	cacheSize    = 512 * 1024 * 1024
+	memCacheSize = 8 * 1024 * 1024
+	// memTableSize is the size of a memtable, writes stall once
+	// memTableStopWrites of them wait to be flushed.
+	memTableSize       = 64 * 1024 * 1024
+	memTableStopWrites = 4
+	// maxOpenFiles bounds the file descriptors kept open by the table cache.
+	maxOpenFiles = 4096
+	// pageSize is reported as the database page size, Pebble has none.
+	pageSize = 4096
+)
+
+var (
+	errTxDone       = errors.New("pebbledb: transaction already committed or rolled back")
+	errReadOnly     = errors.New("pebbledb: write in a read-only transaction")
+	errReadOnlyDB   = errors.New("pebbledb: database opened read-only")
+	errUnknownTable = errors.New("pebbledb: unknown table")
+)
+
+var (
+	_ kv.RwDB            = (*DB)(nil)
+	_ kv.RwTx            = (*tx)(nil)
+	_ kv.RwCursorDupSort = (*cursor)(nil)
+)
+
+var (
+	commitTimer  = prometheus.GetOrCreateSummary("db_pebble_commit_seconds")
+	writtenBytes = prometheus.GetOrCreateCounter("db_pebble_written_bytes")
+)
+
+// DB is a kv.RwDB backed by Pebble. Read transactions run on snapshots; a
+// single write transaction at a time collects its changes in an indexed
+// batch, which reads through to the database, and commits them atomically.
+type DB struct {
+	db     *pebble.DB
+	path   string
+	tables map[string]*table
+
+	writeLock  sync.Mutex // held by the open write transaction
+	tablesLock sync.RWMutex
+	views      uint64 // counts the transactions opened, for ViewID
+	readOnly   bool
+}
+
+// Open opens or creates the Pebble database in path, with the given tables.
+func Open(path string, tables kv.TableCfg) (*DB, error) {
+	return openDisk(path, tables, false)
+}
+
+// OpenReadOnly opens the existing Pebble database in path without writing to
+// it: write transactions fail and nothing is compacted. Pebble still locks the
+// directory, so the database can't be opened while a node runs on it.
+func OpenReadOnly(path string, tables kv.TableCfg) (*DB, error) {
+	return openDisk(path, tables, true)
+}
+
+func openDisk(path string, tables kv.TableCfg, readOnly bool) (*DB, error) {
+	opts := &pebble.Options{
+		ReadOnly:                    readOnly,
+		Cache:                       pebble.NewCache(cacheSize),
+		MaxOpenFiles:                maxOpenFiles,
+		MemTableSize:                memTableSize,
+		MemTableStopWritesThreshold: memTableStopWrites,
+		MaxConcurrentCompactions:    func() int { return runtime.NumCPU() },
+		Levels: []pebble.LevelOptions{
+			{TargetFileSize: 2 * 1024 * 1024, FilterPolicy: bloom.FilterPolicy(10)},
+			{TargetFileSize: 4 * 1024 * 1024, FilterPolicy: bloom.FilterPolicy(10)},
+			{TargetFileSize: 8 * 1024 * 1024, FilterPolicy: bloom.FilterPolicy(10)},
+			{TargetFileSize: 16 * 1024 * 1024, FilterPolicy: bloom.FilterPolicy(10)},
+			{TargetFileSize: 32 * 1024 * 1024, FilterPolicy: bloom.FilterPolicy(10)},
+			{TargetFileSize: 64 * 1024 * 1024, FilterPolicy: bloom.FilterPolicy(10)},
+			{TargetFileSize: 128 * 1024 * 1024, FilterPolicy: bloom.FilterPolicy(10)},
+		},
+	}
+	d, err := open(path, opts, tables)
+	if err != nil {
+		return nil, err
+	}
+	d.readOnly = readOnly
+	d.registerMetrics()
+	log.Info("Opened pebble database", "path", path, "tables", len(d.tables), "readonly", readOnly)
+	return d, nil
+}
+
+// OpenInMemory creates an empty database kept in memory only, with the given
+// tables. It has the same transactions and cursors as an on-disk one and is
+// gone once closed. It exports no metrics.
+func OpenInMemory(tables kv.TableCfg) (*DB, error) {
+	opts := &pebble.Options{
+		FS:                          vfs.NewMem(),
+		Cache:                       pebble.NewCache(memCacheSize),
+		MemTableSize:                memTableSize,
+		MemTableStopWritesThreshold: memTableStopWrites,
+	}
+	return open("", opts, tables)
+}
+
+func open(path string, opts *pebble.Options, tables kv.TableCfg) (*DB, error) {
+	db, err := pebble.Open(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("open pebble database %s: %w", path, err)
+	}
+	d := &DB{db: db, path: path, tables: make(map[string]*table, len(tables)+1)}
+	for name, cfg := range tables {
+		d.tables[name] = newTable(name, cfg)
+	}
+	if _, ok := d.tables[kv.Sequence]; !ok {
+		d.tables[kv.Sequence] = newTable(kv.Sequence, kv.TableCfgItem{})
+	}
+	return d, nil
+}
+
+// registerMetrics exports the state of the LSM tree, read on every scrape.
+func (d *DB) registerMetrics() {
+	gauge := func(name string, f func(m *pebble.Metrics) float64) {
+		prometheus.GetOrCreateGaugeFunc(name, func() float64 { return f(d.db.Metrics()) })
+	}
+	gauge("db_pebble_disk_size", func(m *pebble.Metrics) float64 { return float64(m.DiskSpaceUsage()) })
+	gauge("db_pebble_read_amp", func(m *pebble.Metrics) float64 { return float64(m.ReadAmp()) })
+	gauge("db_pebble_memtable_size", func(m *pebble.Metrics) float64 { return float64(m.MemTable.Size) })
+	gauge("db_pebble_compactions", func(m *pebble.Metrics) float64 { return float64(m.Compact.Count) })
+	gauge("db_pebble_compaction_debt", func(m *pebble.Metrics) float64 { return float64(m.Compact.EstimatedDebt) })
+	gauge("db_pebble_flushes", func(m *pebble.Metrics) float64 { return float64(m.Flush.Count) })
+}
+
+// Metrics returns the current state of the LSM tree.
+func (d *DB) Metrics() *pebble.Metrics {
+	return d.db.Metrics()
+}
+
+// CompactTable compacts the key range of a table down the LSM tree, dropping
+// the deleted and overwritten entries it still holds.
+func (d *DB) CompactTable(name string) error {
+	tbl, err := d.table(name)
+	if err != nil {
+		return err
+	}
+	return d.db.Compact(tbl.prefix, tbl.upper, true)
+}
+
+// Checkpoint writes a consistent copy of the database to dir, which must not
+// exist yet. The sstables are hard linked when dir is on the same filesystem,
+// so the copy is quick and takes little room until they are compacted away.
+func (d *DB) Checkpoint(dir string) error {
+	if d.readOnly {
+		return d.db.Checkpoint(dir)
+	}
+	return d.db.Checkpoint(dir, pebble.WithFlushedWAL())
+}
+
+// table returns the layout of a table.
+func (d *DB) table(name string) (*table, error) {
+	d.tablesLock.RLock()
+	defer d.tablesLock.RUnlock()
+	t, ok := d.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownTable, name)
+	}
+	return t, nil
+}
+
+// Close closes the database. Open transactions must be finished first.
+func (d *DB) Close() {
+	if err := d.db.Close(); err != nil {
+		log.Warn("Failed to close pebble database", "path", d.path, "err", err)
+	}
+}
+
+// ReadOnly reports whether the database was opened with OpenReadOnly.
+func (d *DB) ReadOnly() bool { return d.readOnly }
+
+// AllTables returns the configuration of the tables the database holds.
+func (d *DB) AllTables() kv.TableCfg {
+	d.tablesLock.RLock()
+	defer d.tablesLock.RUnlock()
+	cfg := make(kv.TableCfg, len(d.tables))
+	for name, t := range d.tables {
+		cfg[name] = t.cfg
+	}
+	return cfg
+}
+
+// PageSize returns a nominal page size, Pebble doesn't store pages.
+func (d *DB) PageSize() uint64 { return pageSize }
+
+func (d *DB) View(ctx context.Context, f func(tx kv.Tx) error) error {
+	tx, err := d.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return f(tx)
+}
+
+func (d *DB) Update(ctx context.Context, f func(tx kv.RwTx) error) error {
+	return d.update(ctx, pebble.Sync, f)
+}
+
+func (d *DB) UpdateNosync(ctx context.Context, f func(tx kv.RwTx) error) error {
+	return d.update(ctx, pebble.NoSync, f)
+}
+
+func (d *DB) update(ctx context.Context, sync *pebble.WriteOptions, f func(tx kv.RwTx) error) error {
+	tx, err := d.beginRw(ctx, sync)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// BeginRo opens a read transaction on a snapshot of the database.
+func (d *DB) BeginRo(ctx context.Context) (kv.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.tablesLock.Lock()
+	d.views++
+	id := d.views
+	d.tablesLock.Unlock()
+	return &tx{db: d, ctx: ctx, id: id, snap: d.db.NewSnapshot()}, nil
+}
+
+func (d *DB) BeginRw(ctx context.Context) (kv.RwTx, error) {
+	return d.beginRw(ctx, pebble.Sync)
+}
+
+func (d *DB) BeginRwNosync(ctx context.Context) (kv.RwTx, error) {
+	return d.beginRw(ctx, pebble.NoSync)
+}
+
+// beginRw waits for the running write transaction to finish and opens the next.
+func (d *DB) beginRw(ctx context.Context, sync *pebble.WriteOptions) (*tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if d.readOnly {
+		return nil, errReadOnlyDB
+	}
+	d.writeLock.Lock()
+	d.tablesLock.Lock()
+	d.views++
+	id := d.views
+	d.tablesLock.Unlock()
+	return &tx{db: d, ctx: ctx, id: id, batch: d.db.NewIndexedBatch(), sync: sync}, nil
+}
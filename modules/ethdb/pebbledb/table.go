@@ -0,0 +1,113 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pebbledb
+
+import (
+	"bytes"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// table lays out the keys of a table in the Pebble keyspace.
+type table struct {
+	name   string
+	cfg    kv.TableCfgItem
+	prefix []byte // first key of the table
+	upper  []byte // first key past the table
+	dup    bool   // keys hold several ordered values
+}
+
+func newTable(name string, cfg kv.TableCfgItem) *table {
+	prefix := append([]byte(name), 0)
+	upper := append([]byte(name), 1)
+	return &table{
+		name:   name,
+		cfg:    cfg,
+		prefix: prefix,
+		upper:  upper,
+		dup:    cfg.Flags&kv.DupSort != 0 && !cfg.AutoDupSortKeysConversion,
+	}
+}
+
+// key returns the Pebble key under which a plain table stores k.
+func (t *table) key(k []byte) []byte {
+	raw := make([]byte, 0, len(t.prefix)+len(k))
+	return append(append(raw, t.prefix...), k...)
+}
+
+// dupKey returns the Pebble key of the pair k, v of a DupSort table. Zero bytes
+// of k are escaped and k is terminated by 0x00 0x01, which sorts the pairs by
+// key, then by value, shorter keys first.
+func (t *table) dupKey(k, v []byte) []byte {
+	raw := make([]byte, 0, len(t.prefix)+len(k)+len(v)+4)
+	raw = append(raw, t.prefix...)
+	for _, b := range k {
+		if b == 0 {
+			raw = append(raw, 0, 0xff)
+		} else {
+			raw = append(raw, b)
+		}
+	}
+	return append(append(raw, 0, 1), v...)
+}
+
+// seekKey returns the Pebble key to seek to for the first key not below k.
+func (t *table) seekKey(k []byte) []byte {
+	if !t.dup {
+		return t.key(k)
+	}
+	raw := t.dupKey(k, nil)
+	return raw[:len(raw)-2]
+}
+
+// dupRange returns the bounds of the values of k in a DupSort table.
+func (t *table) dupRange(k []byte) (start, end []byte) {
+	start = t.dupKey(k, nil)
+	end = types.CopyBytes(start)
+	end[len(end)-1] = 2
+	return start, end
+}
+
+// decode splits a Pebble key of the table into the table key and, for a
+// DupSort table, the value.
+func (t *table) decode(raw []byte) (k, v []byte, ok bool) {
+	if !bytes.HasPrefix(raw, t.prefix) {
+		return nil, nil, false
+	}
+	raw = raw[len(t.prefix):]
+	if !t.dup {
+		return raw, nil, true
+	}
+	k = make([]byte, 0, len(raw))
+	for i := 0; i+1 < len(raw); i++ {
+		if raw[i] != 0 {
+			k = append(k, raw[i])
+			continue
+		}
+		switch raw[i+1] {
+		case 0xff:
+			k = append(k, 0)
+			i++
+		case 1:
+			return k, raw[i+2:], true
+		default:
+			return nil, nil, false
+		}
+	}
+	return nil, nil, false
+}
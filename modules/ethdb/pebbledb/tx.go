@@ -0,0 +1,564 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pebbledb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"github.com/ledgerwatch/erigon-lib/kv/iter"
+	"github.com/ledgerwatch/erigon-lib/kv/order"
+)
+
+// reader is implemented by the snapshots and the indexed batches.
+type reader interface {
+	Get(key []byte) ([]byte, io.Closer, error)
+	NewIter(o *pebble.IterOptions) *pebble.Iterator
+}
+
+// tx is a read transaction when snap is set and a write transaction when
+// batch is.
+type tx struct {
+	db  *DB
+	ctx context.Context
+	id  uint64
+
+	snap  *pebble.Snapshot
+	batch *pebble.Batch
+	sync  *pebble.WriteOptions
+
+	writes  uint64 // bumped by every write, makes the open iterators stale
+	cursors []*cursor
+	done    bool
+}
+
+func (t *tx) reader() reader {
+	if t.batch != nil {
+		return t.batch
+	}
+	return t.snap
+}
+
+// get returns a copy of the value stored under raw.
+func (t *tx) get(raw []byte) ([]byte, bool, error) {
+	if t.done {
+		return nil, false, errTxDone
+	}
+	val, closer, err := t.reader().Get(raw)
+	if err == pebble.ErrNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	defer closer.Close()
+	return append(make([]byte, 0, len(val)), val...), true, nil
+}
+
+func (t *tx) writable() error {
+	if t.done {
+		return errTxDone
+	}
+	if t.batch == nil {
+		return errReadOnly
+	}
+	return nil
+}
+
+func (t *tx) set(raw, val []byte) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	t.writes++
+	writtenBytes.Add(len(raw) + len(val))
+	return t.batch.Set(raw, val, nil)
+}
+
+func (t *tx) del(raw []byte) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	t.writes++
+	return t.batch.Delete(raw, nil)
+}
+
+func (t *tx) delRange(start, end []byte) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	t.writes++
+	return t.batch.DeleteRange(start, end, nil)
+}
+
+func (t *tx) ViewID() uint64 { return t.id }
+
+// CollectMetrics does nothing, the gauges read the database metrics directly.
+func (t *tx) CollectMetrics() {}
+
+func (t *tx) Commit() error {
+	if t.done {
+		return errTxDone
+	}
+	t.finish()
+	if t.batch == nil {
+		return t.snap.Close()
+	}
+	defer t.db.writeLock.Unlock()
+	defer t.batch.Close()
+	start := time.Now()
+	if err := t.batch.Commit(t.sync); err != nil {
+		return err
+	}
+	commitTimer.UpdateDuration(start)
+	return nil
+}
+
+func (t *tx) Rollback() {
+	if t.done {
+		return
+	}
+	t.finish()
+	if t.batch == nil {
+		t.snap.Close()
+		return
+	}
+	t.batch.Close()
+	t.db.writeLock.Unlock()
+}
+
+// finish closes the cursors left open.
+func (t *tx) finish() {
+	t.done = true
+	cursors := t.cursors
+	t.cursors = nil
+	for _, c := range cursors {
+		c.Close()
+	}
+}
+
+func (t *tx) GetOne(name string, k []byte) ([]byte, error) {
+	tbl, err := t.db.table(name)
+	if err != nil {
+		return nil, err
+	}
+	if tbl.dup {
+		c, err := t.newCursor(tbl)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		_, v, err := c.SeekExact(k)
+		return v, err
+	}
+	v, _, err := t.get(tbl.key(k))
+	return v, err
+}
+
+func (t *tx) Has(name string, k []byte) (bool, error) {
+	tbl, err := t.db.table(name)
+	if err != nil {
+		return false, err
+	}
+	if tbl.dup {
+		c, err := t.newCursor(tbl)
+		if err != nil {
+			return false, err
+		}
+		defer c.Close()
+		found, _, err := c.SeekExact(k)
+		return found != nil, err
+	}
+	_, ok, err := t.get(tbl.key(k))
+	return ok, err
+}
+
+// Put stores the value of k, or adds it to the values of k in a DupSort table.
+func (t *tx) Put(name string, k, v []byte) error {
+	tbl, err := t.db.table(name)
+	if err != nil {
+		return err
+	}
+	if tbl.dup {
+		return t.set(tbl.dupKey(k, v), nil)
+	}
+	return t.set(tbl.key(k), v)
+}
+
+// Delete removes k, with all its values in a DupSort table.
+func (t *tx) Delete(name string, k []byte) error {
+	tbl, err := t.db.table(name)
+	if err != nil {
+		return err
+	}
+	if tbl.dup {
+		start, end := tbl.dupRange(k)
+		return t.delRange(start, end)
+	}
+	return t.del(tbl.key(k))
+}
+
+// Append and AppendDup are plain puts, Pebble has no faster path for sorted writes.
+func (t *tx) Append(name string, k, v []byte) error    { return t.Put(name, k, v) }
+func (t *tx) AppendDup(name string, k, v []byte) error { return t.Put(name, k, v) }
+
+func (t *tx) ReadSequence(name string) (uint64, error) {
+	v, err := t.GetOne(kv.Sequence, []byte(name))
+	if err != nil || len(v) == 0 {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func (t *tx) IncrementSequence(name string, amount uint64) (uint64, error) {
+	current, err := t.ReadSequence(name)
+	if err != nil {
+		return 0, err
+	}
+	next := make([]byte, 8)
+	binary.BigEndian.PutUint64(next, current+amount)
+	return current, t.Put(kv.Sequence, []byte(name), next)
+}
+
+// BucketSize estimates the disk space taken by a table. Writes still in the
+// memtables aren't counted.
+func (t *tx) BucketSize(name string) (uint64, error) {
+	tbl, err := t.db.table(name)
+	if err != nil {
+		return 0, err
+	}
+	return t.db.db.EstimateDiskUsage(tbl.prefix, tbl.upper)
+}
+
+func (t *tx) DBSize() (uint64, error) {
+	return t.db.db.Metrics().DiskSpaceUsage(), nil
+}
+
+func (t *tx) ListBuckets() ([]string, error) {
+	t.db.tablesLock.RLock()
+	defer t.db.tablesLock.RUnlock()
+	names := make([]string, 0, len(t.db.tables))
+	for name := range t.db.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (t *tx) ExistsBucket(name string) (bool, error) {
+	t.db.tablesLock.RLock()
+	defer t.db.tablesLock.RUnlock()
+	_, ok := t.db.tables[name]
+	return ok, nil
+}
+
+// CreateBucket adds a plain table. Tables only exist as key prefixes, so there
+// is nothing to write.
+func (t *tx) CreateBucket(name string) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	t.db.tablesLock.Lock()
+	defer t.db.tablesLock.Unlock()
+	if _, ok := t.db.tables[name]; !ok {
+		t.db.tables[name] = newTable(name, kv.TableCfgItem{})
+	}
+	return nil
+}
+
+func (t *tx) ClearBucket(name string) error {
+	tbl, err := t.db.table(name)
+	if err != nil {
+		return err
+	}
+	return t.delRange(tbl.prefix, tbl.upper)
+}
+
+func (t *tx) DropBucket(name string) error {
+	if err := t.ClearBucket(name); err != nil {
+		return err
+	}
+	t.db.tablesLock.Lock()
+	defer t.db.tablesLock.Unlock()
+	delete(t.db.tables, name)
+	return nil
+}
+
+func (t *tx) Cursor(name string) (kv.Cursor, error)     { return t.RwCursorDupSort(name) }
+func (t *tx) RwCursor(name string) (kv.RwCursor, error) { return t.RwCursorDupSort(name) }
+func (t *tx) CursorDupSort(name string) (kv.CursorDupSort, error) {
+	return t.RwCursorDupSort(name)
+}
+
+// RwCursorDupSort opens a cursor on the table. The DupSort methods only move
+// between the values of a key on DupSort tables.
+func (t *tx) RwCursorDupSort(name string) (kv.RwCursorDupSort, error) {
+	tbl, err := t.db.table(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.newCursor(tbl)
+}
+
+func (t *tx) newCursor(tbl *table) (*cursor, error) {
+	if t.done {
+		return nil, errTxDone
+	}
+	c := &cursor{tx: t, t: tbl}
+	t.cursors = append(t.cursors, c)
+	return c, nil
+}
+
+func (t *tx) ForEach(name string, fromPrefix []byte, walker func(k, v []byte) error) error {
+	c, err := t.Cursor(name)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for k, v, err := c.Seek(fromPrefix); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if err := walker(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) ForPrefix(name string, prefix []byte, walker func(k, v []byte) error) error {
+	c, err := t.Cursor(name)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for k, v, err := c.Seek(prefix); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(k, prefix) {
+			break
+		}
+		if err := walker(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tx) ForAmount(name string, fromPrefix []byte, amount uint32, walker func(k, v []byte) error) error {
+	if amount == 0 {
+		return nil
+	}
+	c, err := t.Cursor(name)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for k, v, err := c.Seek(fromPrefix); k != nil && amount > 0; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if err := walker(k, v); err != nil {
+			return err
+		}
+		amount--
+	}
+	return nil
+}
+
+func (t *tx) Prefix(name string, prefix []byte) (iter.KV, error) {
+	next, ok := kv.NextSubtree(prefix)
+	if !ok {
+		return t.Range(name, prefix, nil)
+	}
+	return t.Range(name, prefix, next)
+}
+
+func (t *tx) Range(name string, fromPrefix, toPrefix []byte) (iter.KV, error) {
+	return t.RangeAscend(name, fromPrefix, toPrefix, -1)
+}
+
+func (t *tx) RangeAscend(name string, fromPrefix, toPrefix []byte, limit int) (iter.KV, error) {
+	return t.rangeIter(name, fromPrefix, toPrefix, order.Asc, limit)
+}
+
+func (t *tx) RangeDescend(name string, fromPrefix, toPrefix []byte, limit int) (iter.KV, error) {
+	return t.rangeIter(name, fromPrefix, toPrefix, order.Desc, limit)
+}
+
+// rangeIter walks the keys from fromPrefix up to, but excluding, toPrefix, in
+// the given order. Descending, fromPrefix is the highest key.
+func (t *tx) rangeIter(name string, fromPrefix, toPrefix []byte, asc order.By, limit int) (*rangeIter, error) {
+	if fromPrefix != nil && toPrefix != nil {
+		if cmp := bytes.Compare(fromPrefix, toPrefix); (bool(asc) && cmp >= 0) || (!bool(asc) && cmp <= 0) {
+			return nil, fmt.Errorf("pebbledb: range %x-%x is empty in this order", fromPrefix, toPrefix)
+		}
+	}
+	tbl, err := t.db.table(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := t.newCursor(tbl)
+	if err != nil {
+		return nil, err
+	}
+	it := &rangeIter{ctx: t.ctx, c: c, to: toPrefix, asc: asc, limit: int64(limit)}
+	switch {
+	case fromPrefix == nil && bool(asc):
+		it.k, it.v, it.err = c.First()
+	case fromPrefix == nil:
+		it.k, it.v, it.err = c.Last()
+	case bool(asc):
+		it.k, it.v, it.err = c.Seek(fromPrefix)
+	default:
+		// Start from the last value of the key, or from the key below it.
+		if it.k, it.v, it.err = c.SeekExact(fromPrefix); it.err == nil && it.k != nil {
+			if tbl.dup {
+				it.v, it.err = c.LastDup()
+			}
+		} else if it.err == nil {
+			it.k, it.v, it.err = c.seekBelow(fromPrefix)
+		}
+	}
+	return it, nil
+}
+
+type rangeIter struct {
+	ctx   context.Context
+	c     *cursor
+	k, v  []byte
+	to    []byte
+	asc   order.By
+	limit int64
+	err   error
+}
+
+func (it *rangeIter) HasNext() bool {
+	if it.err != nil {
+		return true
+	}
+	if it.limit == 0 || it.k == nil {
+		return false
+	}
+	if it.to == nil {
+		return true
+	}
+	cmp := bytes.Compare(it.k, it.to)
+	return (bool(it.asc) && cmp < 0) || (!bool(it.asc) && cmp > 0)
+}
+
+func (it *rangeIter) Next() ([]byte, []byte, error) {
+	if err := it.ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	it.limit--
+	k, v, err := it.k, it.v, it.err
+	if bool(it.asc) {
+		it.k, it.v, it.err = it.c.Next()
+	} else {
+		it.k, it.v, it.err = it.c.Prev()
+	}
+	return k, v, err
+}
+
+func (it *rangeIter) Close() { it.c.Close() }
+
+// RangeDupSort walks the values of a key of a DupSort table from fromPrefix
+// up to, but excluding, toPrefix.
+func (t *tx) RangeDupSort(name string, key []byte, fromPrefix, toPrefix []byte, asc order.By, limit int) (iter.KV, error) {
+	if fromPrefix != nil && toPrefix != nil {
+		if cmp := bytes.Compare(fromPrefix, toPrefix); (bool(asc) && cmp >= 0) || (!bool(asc) && cmp <= 0) {
+			return nil, fmt.Errorf("pebbledb: range %x-%x is empty in this order", fromPrefix, toPrefix)
+		}
+	}
+	c, err := t.RwCursorDupSort(name)
+	if err != nil {
+		return nil, err
+	}
+	it := &dupRangeIter{ctx: t.ctx, c: c, key: key, to: toPrefix, asc: asc, limit: int64(limit)}
+	k, _, err := c.SeekExact(key)
+	if err != nil || k == nil {
+		it.err = err
+		return it, nil
+	}
+	switch {
+	case fromPrefix == nil && bool(asc):
+		it.v, it.err = c.FirstDup()
+	case fromPrefix == nil:
+		it.v, it.err = c.LastDup()
+	case bool(asc):
+		it.v, it.err = c.SeekBothRange(key, fromPrefix)
+	default:
+		if _, it.v, it.err = c.SeekBothExact(key, fromPrefix); it.err == nil && it.v == nil {
+			if it.v, it.err = c.SeekBothRange(key, fromPrefix); it.err == nil {
+				if it.v == nil {
+					it.v, it.err = c.LastDup()
+				} else {
+					_, it.v, it.err = c.PrevDup()
+				}
+			}
+		}
+	}
+	return it, nil
+}
+
+type dupRangeIter struct {
+	ctx   context.Context
+	c     kv.RwCursorDupSort
+	key   []byte
+	v     []byte
+	to    []byte
+	asc   order.By
+	limit int64
+	err   error
+}
+
+func (it *dupRangeIter) HasNext() bool {
+	if it.err != nil {
+		return true
+	}
+	if it.limit == 0 || it.v == nil {
+		return false
+	}
+	if it.to == nil {
+		return true
+	}
+	cmp := bytes.Compare(it.v, it.to)
+	return (bool(it.asc) && cmp < 0) || (!bool(it.asc) && cmp > 0)
+}
+
+func (it *dupRangeIter) Next() ([]byte, []byte, error) {
+	if err := it.ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	it.limit--
+	v, err := it.v, it.err
+	if bool(it.asc) {
+		_, it.v, it.err = it.c.NextDup()
+	} else {
+		_, it.v, it.err = it.c.PrevDup()
+	}
+	return it.key, v, err
+}
+
+func (it *dupRangeIter) Close() { it.c.Close() }
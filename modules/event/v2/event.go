@@ -17,9 +17,14 @@
 package v2
 
 import (
+	"fmt"
+	"github.com/amazechain/amc/internal/metrics/prometheus"
 	"github.com/amazechain/amc/log"
 	"reflect"
 	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
 )
 
 var GlobalEvent Event
@@ -27,15 +32,31 @@ var GlobalEvent Event
 type Event struct {
 	once sync.Once
 
-	feeds      map[string]*Feed
-	feedsLock  sync.RWMutex
-	feedsScope map[string]*SubscriptionScope
+	feeds       map[string]*Feed
+	feedsLock   sync.RWMutex
+	feedsScope  map[string]*SubscriptionScope
+	feedMetrics map[string]*feedMetrics
+}
+
+// feedMetrics measures the backpressure on the senders of an event type. A
+// feed hands the event to every subscriber before Send returns, so the time
+// spent sending is the time a slow consumer holds its producer up.
+type feedMetrics struct {
+	sent    prometheus.Counter
+	blocked prom.Histogram
+}
+
+func newFeedMetrics(key string) *feedMetrics {
+	return &feedMetrics{
+		sent:    prometheus.GetOrCreateCounter(fmt.Sprintf(`event_sent_total{event="%s"}`, key)),
+		blocked: prometheus.GetOrCreateHistogram(fmt.Sprintf(`event_send_duration_seconds{event="%s"}`, key)),
+	}
 }
 
 func (e *Event) init() {
 	e.feeds = make(map[string]*Feed)
 	e.feedsScope = make(map[string]*SubscriptionScope)
-
+	e.feedMetrics = make(map[string]*feedMetrics)
 }
 
 func (e *Event) initKey(key string) {
@@ -44,6 +65,7 @@ func (e *Event) initKey(key string) {
 	if _, ok := e.feeds[key]; !ok {
 		e.feeds[key] = new(Feed)
 		e.feedsScope[key] = new(SubscriptionScope)
+		e.feedMetrics[key] = newFeedMetrics(key)
 	}
 }
 
@@ -76,7 +98,11 @@ func (e *Event) Send(value interface{}) int {
 	if e.feedsScope[key].Count() == 0 {
 		return nsent
 	}
+	start := time.Now()
 	nsent = e.feeds[key].Send(value)
+	m := e.feedMetrics[key]
+	m.blocked.Observe(time.Since(start).Seconds())
+	m.sent.Inc()
 
 	return nsent
 }
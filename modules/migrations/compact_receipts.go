@@ -0,0 +1,102 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package migrations
+
+import (
+	"context"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// compactReceiptsBatch is the number of blocks whose receipts are rewritten
+// per transaction.
+const compactReceiptsBatch = 10_000
+
+// compactReceipts rewrites the receipts stored in the legacy protobuf encoding
+// in the compact form, and drops the copies of the logs kept next to them,
+// which nothing reads. The progress is the key of the next block to rewrite.
+var compactReceipts = Migration{
+	Version: 1,
+	Name:    "compact receipts",
+	Up: func(ctx context.Context, db kv.RwDB, progress []byte, save func(tx kv.RwTx, progress []byte) error) error {
+		var rewritten uint64
+		for next := progress; ; {
+			var done bool
+			if err := db.Update(ctx, func(tx kv.RwTx) error {
+				keys, values, last, err := legacyReceipts(tx, next)
+				if err != nil {
+					return err
+				}
+				for i, k := range keys {
+					if err := tx.Put(modules.Receipts, k, values[i]); err != nil {
+						return err
+					}
+				}
+				rewritten += uint64(len(keys))
+				if last == nil {
+					done = true
+					return tx.ClearBucket(modules.Log)
+				}
+				next = last
+				return save(tx, next)
+			}); err != nil {
+				return err
+			}
+			if done {
+				break
+			}
+			if number, err := modules.DecodeBlockNumber(next); err == nil {
+				log.Info("Compacting receipts", "block", number, "rewritten", rewritten)
+			}
+		}
+		return nil
+	},
+}
+
+// legacyReceipts converts the legacy receipts of a batch of blocks from the
+// given key, returning the key following the batch, nil at the end.
+func legacyReceipts(tx kv.Tx, from []byte) (keys, values [][]byte, next []byte, err error) {
+	c, err := tx.Cursor(modules.Receipts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer c.Close()
+	var (
+		k, v    []byte
+		scanned int
+	)
+	for k, v, err = c.Seek(from); k != nil && err == nil; k, v, err = c.Next() {
+		if scanned == compactReceiptsBatch {
+			return keys, values, types.CopyBytes(k), nil
+		}
+		scanned++
+		if rawdb.IsCompactReceipts(v) {
+			continue
+		}
+		compact, err := rawdb.CompactReceipts(v)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		keys = append(keys, types.CopyBytes(k))
+		values = append(values, compact)
+	}
+	return keys, values, nil, err
+}
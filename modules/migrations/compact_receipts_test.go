@@ -0,0 +1,186 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// testReceipts returns the receipts of block number, with two transactions
+// emitting logs and one emitting none.
+func testReceipts(number uint64) block.Receipts {
+	contract := types.HexToAddress("0x2000000000000000000000000000000000000002")
+	receipts := block.Receipts{
+		{Status: block.ReceiptStatusSuccessful, CumulativeGasUsed: 30000, Logs: []*block.Log{
+			{Address: contract, Topics: []types.Hash{{byte(number)}}, Data: []byte{0xaa}},
+		}},
+		{Status: block.ReceiptStatusFailed, CumulativeGasUsed: 55000},
+		{Status: block.ReceiptStatusSuccessful, CumulativeGasUsed: 90000, Logs: []*block.Log{
+			{Address: contract, Topics: []types.Hash{{0x01}, {0x02}}},
+			{Address: contract, Data: []byte{byte(number)}},
+		}},
+	}
+	for i, r := range receipts {
+		r.BlockNumber = uint256.NewInt(number)
+		r.TransactionIndex = uint(i)
+		r.TxHash = types.Hash{byte(number), byte(i)}
+		for _, l := range r.Logs {
+			l.BlockNumber = r.BlockNumber
+			l.TxHash = r.TxHash
+			l.TxIndex = uint(i)
+		}
+		r.Bloom = block.CreateBloom(block.Receipts{r})
+	}
+	return receipts
+}
+
+// writeLegacyReceipts stores the receipts of the blocks up to count in the
+// legacy encoding, with the copies of their logs, as the releases before the
+// migration did.
+func writeLegacyReceipts(t *testing.T, db kv.RwDB, count uint64) {
+	if err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		for number := uint64(0); number < count; number++ {
+			if err := rawdb.WriteCanonicalHash(tx, types.Hash{byte(number)}, number); err != nil {
+				return err
+			}
+			receipts := testReceipts(number)
+			for txID, r := range receipts {
+				if len(r.Logs) == 0 {
+					continue
+				}
+				logs := block.Logs(r.Logs)
+				v, err := logs.Marshal()
+				if err != nil {
+					return err
+				}
+				if err := tx.Put(modules.Log, modules.LogKey(number, uint32(txID)), v); err != nil {
+					return err
+				}
+			}
+			v, err := receipts.Marshal()
+			if err != nil {
+				return err
+			}
+			if err := tx.Put(modules.Receipts, modules.EncodeBlockNumber(number), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkCompactReceipts checks that the receipts of the blocks from start up
+// to count are compact and hold what was stored in the legacy encoding, and
+// that the blocks before start are left as they were.
+func checkCompactReceipts(t *testing.T, db kv.RoDB, start, count uint64) {
+	if err := db.View(context.Background(), func(tx kv.Tx) error {
+		for number := uint64(0); number < count; number++ {
+			v, err := tx.GetOne(modules.Receipts, modules.EncodeBlockNumber(number))
+			if err != nil {
+				return err
+			}
+			if compact := rawdb.IsCompactReceipts(v); compact != (number >= start) {
+				t.Errorf("block %d: receipts compact = %v", number, compact)
+			}
+			got, want := rawdb.ReadRawReceipts(tx, number), testReceipts(number)
+			if len(got) != len(want) {
+				t.Fatalf("block %d: %d receipts, want %d", number, len(got), len(want))
+			}
+			for i, r := range got {
+				w := want[i]
+				if r.Status != w.Status || r.CumulativeGasUsed != w.CumulativeGasUsed || len(r.Logs) != len(w.Logs) {
+					t.Errorf("block %d receipt %d: status %d, gas %d, %d logs, want %d, %d, %d", number, i, r.Status, r.CumulativeGasUsed, len(r.Logs), w.Status, w.CumulativeGasUsed, len(w.Logs))
+					continue
+				}
+				for k, l := range r.Logs {
+					wl := w.Logs[k]
+					if l.Address != wl.Address || len(l.Topics) != len(wl.Topics) || (len(l.Topics) > 0 && !reflect.DeepEqual(l.Topics, wl.Topics)) || !bytes.Equal(l.Data, wl.Data) {
+						t.Errorf("block %d receipt %d log %d: %+v, want %+v", number, i, k, l, wl)
+					}
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompactReceipts(t *testing.T) {
+	const count = 5
+	db := memdb.NewTestDB(t)
+	writeLegacyReceipts(t, db, count)
+
+	if err := Apply(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	checkCompactReceipts(t, db, 0, count)
+	if err := db.View(context.Background(), func(tx kv.Tx) error {
+		version, ok, err := rawdb.ReadSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if !ok || version != SchemaVersion {
+			t.Errorf("schema version %d (stored %v), want %d", version, ok, SchemaVersion)
+		}
+		kept, err := tx.Has(modules.Log, modules.LogKey(0, 0))
+		if err != nil {
+			return err
+		}
+		if kept {
+			t.Error("logs kept next to the compact receipts")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running it again, as after an interruption, leaves the compact
+	// receipts as they are.
+	if err := compactReceipts.Up(context.Background(), db, nil, noProgress); err != nil {
+		t.Fatal(err)
+	}
+	checkCompactReceipts(t, db, 0, count)
+}
+
+func TestCompactReceiptsResume(t *testing.T) {
+	const count, start = 5, 3
+	db := memdb.NewTestDB(t)
+	writeLegacyReceipts(t, db, count)
+
+	if err := compactReceipts.Up(context.Background(), db, modules.EncodeBlockNumber(start), noProgress); err != nil {
+		t.Fatal(err)
+	}
+	checkCompactReceipts(t, db, start, count)
+}
+
+func noProgress(tx kv.RwTx, progress []byte) error {
+	return nil
+}
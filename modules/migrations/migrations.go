@@ -0,0 +1,174 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package migrations upgrades the layout of an existing chain database.
+//
+// The database stores the version of its layout. Every change to the layout
+// comes with a migration to the next version, appended to the registry below,
+// which runs once when the node starts on a database at an older version.
+// Databases created by this release start at the latest version.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// Migration upgrades the database to Version from the version before it.
+//
+// Up may work across several transactions, handing what it got through to
+// save in each of them: after an interruption it runs again, with the last
+// progress saved. Whatever it did after that point must be safe to redo.
+// The version is only raised once Up returns.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      func(ctx context.Context, db kv.RwDB, progress []byte, save func(tx kv.RwTx, progress []byte) error) error
+}
+
+// migrations is the ordered registry, the migration at index i upgrades the
+// database to version i+1.
+var migrations = []Migration{
+	compactReceipts,
+}
+
+// SchemaVersion is the layout version of the databases of this release.
+var SchemaVersion = uint64(len(migrations))
+
+func init() {
+	for i, m := range migrations {
+		if m.Version != uint64(i+1) {
+			panic(fmt.Sprintf("migration %q is registered at version %d, not %d", m.Name, i+1, m.Version))
+		}
+	}
+}
+
+// Apply runs the migrations the database is missing, in order. A database
+// without a version is either new, and gets the latest one, or predates the
+// versioning and is at version 0. A database from a later release isn't
+// touched.
+func Apply(ctx context.Context, db kv.RwDB) error {
+	version, err := prepare(ctx, db)
+	if err != nil {
+		return err
+	}
+	if version == SchemaVersion {
+		return nil
+	}
+	log.Info("Migrating the database", "from", version, "to", SchemaVersion)
+	for _, m := range migrations[version:] {
+		if err := apply(ctx, db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Check fails unless the database is at the latest version, for the databases
+// that are opened read-only and can't be migrated.
+func Check(db kv.RoDB) error {
+	return db.View(context.Background(), func(tx kv.Tx) error {
+		version, ok, err := rawdb.ReadSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if version, err = unversioned(tx); err != nil {
+				return err
+			}
+		}
+		if version != SchemaVersion {
+			return fmt.Errorf("database is at schema version %d, this release reads version %d", version, SchemaVersion)
+		}
+		return nil
+	})
+}
+
+// prepare returns the version of the database, storing the latest one in a
+// new database.
+func prepare(ctx context.Context, db kv.RwDB) (version uint64, err error) {
+	err = db.Update(ctx, func(tx kv.RwTx) error {
+		var ok bool
+		if version, ok, err = rawdb.ReadSchemaVersion(tx); err != nil || ok {
+			return err
+		}
+		if version, err = unversioned(tx); err != nil {
+			return err
+		}
+		return rawdb.WriteSchemaVersion(tx, version)
+	})
+	if err == nil && version > SchemaVersion {
+		err = fmt.Errorf("database is at schema version %d, newer than the version %d of this release", version, SchemaVersion)
+	}
+	return version, err
+}
+
+// unversioned returns the version of a database storing none: the latest if
+// it holds no chain yet, 0 otherwise.
+func unversioned(tx kv.Tx) (uint64, error) {
+	c, err := tx.Cursor(modules.HeaderCanonical)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	k, _, err := c.First()
+	if err != nil {
+		return 0, err
+	}
+	if k == nil {
+		return SchemaVersion, nil
+	}
+	return 0, nil
+}
+
+// apply runs a migration from its saved progress and raises the version.
+func apply(ctx context.Context, db kv.RwDB, m Migration) error {
+	var progress []byte
+	if err := db.View(ctx, func(tx kv.Tx) (err error) {
+		progress, err = rawdb.ReadMigrationProgress(tx, m.Version)
+		return err
+	}); err != nil {
+		return err
+	}
+	if progress != nil {
+		log.Info("Resuming database migration", "version", m.Version, "name", m.Name)
+	} else {
+		log.Info("Applying database migration", "version", m.Version, "name", m.Name)
+	}
+	start := time.Now()
+	save := func(tx kv.RwTx, progress []byte) error {
+		return rawdb.WriteMigrationProgress(tx, m.Version, progress)
+	}
+	if err := m.Up(ctx, db, progress, save); err != nil {
+		return err
+	}
+	if err := db.Update(ctx, func(tx kv.RwTx) error {
+		if err := rawdb.DeleteMigrationProgress(tx); err != nil {
+			return err
+		}
+		return rawdb.WriteSchemaVersion(tx, m.Version)
+	}); err != nil {
+		return err
+	}
+	log.Info("Applied database migration", "version", m.Version, "name", m.Name, "elapsed", time.Since(start))
+	return nil
+}
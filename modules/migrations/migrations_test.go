@@ -0,0 +1,194 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// useTestMigrations replaces the registry for the duration of a test.
+func useTestMigrations(t *testing.T, ms ...Migration) {
+	registered, version := migrations, SchemaVersion
+	t.Cleanup(func() { migrations, SchemaVersion = registered, version })
+	migrations, SchemaVersion = ms, uint64(len(ms))
+}
+
+// recordingMigration returns a migration appending its name to ran.
+func recordingMigration(version uint64, name string, ran *[]string) Migration {
+	return Migration{Version: version, Name: name, Up: func(ctx context.Context, db kv.RwDB, progress []byte, save func(tx kv.RwTx, progress []byte) error) error {
+		*ran = append(*ran, name)
+		return nil
+	}}
+}
+
+func readSchemaVersion(t *testing.T, db kv.RoDB) uint64 {
+	var version uint64
+	if err := db.View(context.Background(), func(tx kv.Tx) (err error) {
+		var ok bool
+		if version, ok, err = rawdb.ReadSchemaVersion(tx); err == nil && !ok {
+			t.Error("no schema version stored")
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+// writeTestChain makes the database hold a chain, as a database predating the
+// versioning does.
+func writeTestChain(t *testing.T, db kv.RwDB) {
+	if err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		return rawdb.WriteCanonicalHash(tx, types.Hash{0x01}, 0)
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyNewDatabase(t *testing.T) {
+	var ran []string
+	useTestMigrations(t, recordingMigration(1, "first", &ran), recordingMigration(2, "second", &ran))
+	db := memdb.NewTestDB(t)
+
+	// A new database starts at the latest version, without migrating.
+	if err := Check(db); err != nil {
+		t.Errorf("new database rejected: %v", err)
+	}
+	if err := Apply(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 0 {
+		t.Errorf("migrated a new database: %v", ran)
+	}
+	if version := readSchemaVersion(t, db); version != 2 {
+		t.Errorf("schema version %d, want 2", version)
+	}
+}
+
+func TestApplyUnversionedDatabase(t *testing.T) {
+	var ran []string
+	useTestMigrations(t, recordingMigration(1, "first", &ran), recordingMigration(2, "second", &ran))
+	db := memdb.NewTestDB(t)
+	writeTestChain(t, db)
+
+	// A chain without a version predates the versioning.
+	if err := Check(db); err == nil {
+		t.Error("unmigrated database accepted")
+	}
+	if err := Apply(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if version := readSchemaVersion(t, db); version != 2 {
+		t.Errorf("schema version %d, want 2", version)
+	}
+	if err := Check(db); err != nil {
+		t.Errorf("migrated database rejected: %v", err)
+	}
+
+	// Migrations only ever run once.
+	if err := Apply(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 {
+		t.Errorf("ran %v on a migrated database", ran)
+	}
+
+	// A release adding a migration runs that one only.
+	useTestMigrations(t, recordingMigration(1, "first", &ran), recordingMigration(2, "second", &ran), recordingMigration(3, "third", &ran))
+	if err := Apply(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", "second", "third"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
+
+func TestApplyResumes(t *testing.T) {
+	errInterrupted := errors.New("interrupted")
+	var progresses [][]byte
+	useTestMigrations(t, Migration{Version: 1, Name: "resumable", Up: func(ctx context.Context, db kv.RwDB, progress []byte, save func(tx kv.RwTx, progress []byte) error) error {
+		progresses = append(progresses, progress)
+		if progress == nil {
+			// Save a step, then get interrupted.
+			if err := db.Update(ctx, func(tx kv.RwTx) error { return save(tx, []byte("step 1")) }); err != nil {
+				return err
+			}
+			return errInterrupted
+		}
+		return nil
+	}})
+	db := memdb.NewTestDB(t)
+	writeTestChain(t, db)
+
+	if err := Apply(context.Background(), db); !errors.Is(err, errInterrupted) {
+		t.Fatalf("error %v, want %v", err, errInterrupted)
+	}
+	if version := readSchemaVersion(t, db); version != 0 {
+		t.Errorf("schema version %d raised by an interrupted migration", version)
+	}
+	if err := Apply(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]byte{nil, []byte("step 1")}; !reflect.DeepEqual(progresses, want) {
+		t.Errorf("migration ran from %q, want %q", progresses, want)
+	}
+	if version := readSchemaVersion(t, db); version != 1 {
+		t.Errorf("schema version %d, want 1", version)
+	}
+	if err := db.View(context.Background(), func(tx kv.Tx) error {
+		progress, err := rawdb.ReadMigrationProgress(tx, 1)
+		if err != nil || progress != nil {
+			t.Errorf("progress %q kept after the migration, %v", progress, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyNewerDatabase(t *testing.T) {
+	var ran []string
+	useTestMigrations(t, recordingMigration(1, "first", &ran))
+	db := memdb.NewTestDB(t)
+	if err := db.Update(context.Background(), func(tx kv.RwTx) error {
+		return rawdb.WriteSchemaVersion(tx, 5)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A database from a later release isn't touched.
+	if err := Apply(context.Background(), db); err == nil {
+		t.Error("migrated a database from a later release")
+	}
+	if err := Check(db); err == nil {
+		t.Error("database from a later release accepted")
+	}
+	if version := readSchemaVersion(t, db); version != 5 || len(ran) != 0 {
+		t.Errorf("schema version %d after running %v", version, ran)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/amazechain/amc/api/protocol/types_pb"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/log"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/freezer"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"google.golang.org/protobuf/proto"
+)
+
+var ancientKey = []byte("ancient")
+
+// ancients holds the blocks moved out of the chain database. The readers of
+// headers, bodies and receipts fall back to it for the blocks not found in
+// the database, so that frozen blocks are served like any other.
+var ancients atomic.Pointer[freezer.Freezer]
+
+// SetAncients makes the readers serve the frozen blocks of f.
+func SetAncients(f *freezer.Freezer) {
+	ancients.Store(f)
+}
+
+// Ancients returns the number of frozen blocks.
+func Ancients() uint64 {
+	if f := ancients.Load(); f != nil {
+		return f.Ancients()
+	}
+	return 0
+}
+
+// readAncient reads a kind of data of a frozen block, nil if the block isn't
+// frozen or has another hash.
+func readAncient(kind string, hash types.Hash, number uint64) []byte {
+	f := ancients.Load()
+	if f == nil || number >= f.Ancients() {
+		return nil
+	}
+	frozen, err := f.Ancient(freezer.Hashes, number)
+	if err != nil {
+		log.Error("Failed to read ancient hash", "number", number, "err", err)
+		return nil
+	}
+	if types.BytesToHash(frozen) != hash {
+		return nil
+	}
+	data, err := f.Ancient(kind, number)
+	if err != nil {
+		log.Error("Failed to read ancient data", "kind", kind, "number", number, "err", err)
+		return nil
+	}
+	return data
+}
+
+// readAncientBody decodes the body of a frozen block.
+func readAncientBody(hash types.Hash, number uint64) *block.Body {
+	// An empty body encodes to no bytes.
+	data := readAncient(freezer.Bodies, hash, number)
+	if data == nil {
+		return nil
+	}
+	pbBody := new(types_pb.Body)
+	if err := proto.Unmarshal(data, pbBody); err != nil {
+		log.Error("Invalid ancient block body", "number", number, "err", err)
+		return nil
+	}
+	body := new(block.Body)
+	if err := body.FromProtoMessage(pbBody); err != nil {
+		log.Error("Invalid ancient block body", "number", number, "err", err)
+		return nil
+	}
+	return body
+}
+
+// readAncientReceipts returns the stored receipts of a frozen block.
+func readAncientReceipts(number uint64) []byte {
+	f := ancients.Load()
+	if f == nil || number >= f.Ancients() {
+		return nil
+	}
+	data, err := f.Ancient(freezer.Receipts, number)
+	if err != nil {
+		log.Error("Failed to read ancient receipts", "number", number, "err", err)
+		return nil
+	}
+	return data
+}
+
+// ReadAncientProgress returns the first block whose data is still in the
+// database. The blocks below it are only in the freezer; those between it and
+// the number of frozen blocks are in both, until they are deleted.
+func ReadAncientProgress(db kv.Getter) (uint64, error) {
+	data, err := db.GetOne(modules.StatePrune, ancientKey)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid ancient progress length %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// WriteAncientProgress records that the blocks below number were deleted from
+// the database once frozen.
+func WriteAncientProgress(db kv.Putter, number uint64) error {
+	return db.Put(modules.StatePrune, ancientKey, modules.EncodeBlockNumber(number))
+}
+
+// DeleteFrozenBlock deletes the headers, bodies and receipts stored for a
+// block number once its canonical block is frozen, side chain blocks
+// included.
+func DeleteFrozenBlock(tx kv.RwTx, number uint64) error {
+	prefix := modules.EncodeBlockNumber(number)
+	var hashes []types.Hash
+	if err := tx.ForPrefix(modules.Headers, prefix, func(k, _ []byte) error {
+		hashes = append(hashes, types.BytesToHash(k[8:]))
+		return nil
+	}); err != nil {
+		return err
+	}
+	canonical, err := ReadCanonicalHash(tx, number)
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if body, err := ReadStorageBody(tx, hash, number); err == nil {
+			for id := body.BaseTxId; id < body.BaseTxId+uint64(body.TxAmount); id++ {
+				if err := tx.Delete(modules.BlockTx, modules.EncodeBlockNumber(id)); err != nil {
+					return err
+				}
+			}
+		}
+		// The canonical hash keeps its number, the frozen block is looked
+		// up by hash.
+		if hash != canonical {
+			DeleteHeaderNumber(tx, hash)
+		}
+		deleteBody(tx, hash, number)
+		if err := tx.Delete(modules.Headers, modules.HeaderKey(number, hash)); err != nil {
+			return err
+		}
+	}
+	return tx.Delete(modules.Receipts, prefix)
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/freezer"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"google.golang.org/protobuf/proto"
+)
+
+func writeAncientTestBlock(t *testing.T, tx kv.RwTx, number uint64, extra string) *block.Block {
+	t.Helper()
+	header := &block.Header{
+		Difficulty: uint256.NewInt(1),
+		Number:     uint256.NewInt(number),
+		GasLimit:   8_000_000,
+		Time:       number,
+		Extra:      []byte(extra),
+		BaseFee:    uint256.NewInt(0),
+	}
+	b := block.NewBlock(header, nil).(*block.Block)
+	if err := WriteBlock(tx, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteReceipts(tx, number, block.Receipts{{Status: 1, CumulativeGasUsed: number + 1}}); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// freezeAncientTestBlock moves a canonical block to f the way the chain
+// freezer does.
+func freezeAncientTestBlock(t *testing.T, tx kv.RwTx, f *freezer.Freezer, b *block.Block) {
+	t.Helper()
+	number := b.Number64().Uint64()
+	header, err := tx.GetOne(modules.Headers, modules.HeaderKey(number, b.Hash()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := proto.Marshal(ReadCanonicalBodyWithTransactions(tx, b.Hash(), number).ToProtoMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipts, err := tx.GetOne(modules.Receipts, modules.EncodeBlockNumber(number))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Append(number, b.Hash(), header, body, receipts); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteFrozenBlock(tx, number); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that the frozen blocks are read back through the chain accessors
+// once they left the database.
+func TestAncientBlocks(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	f, err := freezer.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var blocks []*block.Block
+	for number := uint64(0); number < 3; number++ {
+		b := writeAncientTestBlock(t, tx, number, "canonical")
+		if err := WriteCanonicalHash(tx, b.Hash(), number); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, b)
+	}
+	side := writeAncientTestBlock(t, tx, 1, "side")
+	for _, b := range blocks[:2] {
+		freezeAncientTestBlock(t, tx, f, b)
+	}
+	if HasHeader(tx, side.Hash(), 1) || ReadBlock(tx, side.Hash(), 1) != nil {
+		t.Fatal("side block of a frozen one left in the database")
+	}
+	for _, b := range blocks[:2] {
+		if ReadBlock(tx, b.Hash(), b.Number64().Uint64()) != nil {
+			t.Fatalf("frozen block %d read without the freezer", b.Number64().Uint64())
+		}
+	}
+
+	SetAncients(f)
+	defer SetAncients(nil)
+	if frozen := Ancients(); frozen != 2 {
+		t.Fatalf("frozen blocks mismatch: have %d, want 2", frozen)
+	}
+	for _, b := range blocks {
+		number := b.Number64().Uint64()
+		if !HasHeader(tx, b.Hash(), number) || !HasBlock(tx, b.Hash(), number) || !HasReceipts(tx, number) {
+			t.Errorf("block %d missing", number)
+		}
+		if read := ReadBlock(tx, b.Hash(), number); read == nil || read.Hash() != b.Hash() {
+			t.Errorf("block %d: have %v, want %x", number, read, b.Hash())
+		}
+		if read, err := ReadBlockByNumber(tx, number); err != nil || read == nil || read.Hash() != b.Hash() {
+			t.Errorf("block %d by number: have %v (%v), want %x", number, read, err, b.Hash())
+		}
+		if headers, err := ReadHeadersByNumber(tx, number); err != nil || len(headers) == 0 || headers[0].Hash() != b.Hash() {
+			t.Errorf("headers of block %d: have %v (%v)", number, headers, err)
+		}
+		if receipts := ReadRawReceipts(tx, number); len(receipts) != 1 || receipts[0].CumulativeGasUsed != number+1 {
+			t.Errorf("receipts of block %d: have %v", number, receipts)
+		}
+	}
+	// The freezer serves the canonical block of the number, not another one.
+	if ReadHeader(tx, side.Hash(), 1) != nil {
+		t.Fatal("side block served by the freezer")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"sort"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// badBlocksToKeep is the maximum number of bad blocks kept in the database.
+const badBlocksToKeep = 10
+
+// BadBlock is a block that failed validation, with what is known about why.
+type BadBlock struct {
+	Block    *block.Block
+	Receipts block.Receipts // receipts produced before the failure, if the block was executed
+	Reason   string
+	Time     uint64 // unix time the block was rejected at
+}
+
+// badBlockRecord is the stored form of a BadBlock. Blocks and receipts keep
+// their protobuf encoding.
+type badBlockRecord struct {
+	Number   uint64
+	Block    []byte
+	Receipts []byte
+	Reason   string
+	Time     uint64
+}
+
+// WriteBadBlock stores a bad block, dropping the lowest ones beyond
+// badBlocksToKeep.
+func WriteBadBlock(db kv.RwTx, bad *BadBlock) error {
+	blk, err := bad.Block.Marshal()
+	if err != nil {
+		return err
+	}
+	receipts, err := bad.Receipts.Marshal()
+	if err != nil {
+		return err
+	}
+	data, err := rlp.EncodeToBytes(&badBlockRecord{
+		Number:   bad.Block.Number64().Uint64(),
+		Block:    blk,
+		Receipts: receipts,
+		Reason:   bad.Reason,
+		Time:     bad.Time,
+	})
+	if err != nil {
+		return err
+	}
+	hash := bad.Block.Hash()
+	if err := db.Put(modules.BadBlocks, hash[:], data); err != nil {
+		return err
+	}
+
+	type stored struct {
+		key    []byte
+		number uint64
+	}
+	var all []stored
+	if err := db.ForEach(modules.BadBlocks, nil, func(k, v []byte) error {
+		var record badBlockRecord
+		if err := rlp.DecodeBytes(v, &record); err != nil {
+			return err
+		}
+		all = append(all, stored{types.CopyBytes(k), record.Number})
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(all) <= badBlocksToKeep {
+		return nil
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].number > all[j].number })
+	for _, s := range all[badBlocksToKeep:] {
+		if err := db.Delete(modules.BadBlocks, s.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadBadBlock returns the bad block with the given hash, or nil.
+func ReadBadBlock(db kv.Getter, hash types.Hash) (*BadBlock, error) {
+	data, err := db.GetOne(modules.BadBlocks, hash[:])
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return decodeBadBlock(data)
+}
+
+// ReadAllBadBlocks returns the stored bad blocks, highest first.
+func ReadAllBadBlocks(db kv.Tx) ([]*BadBlock, error) {
+	var bads []*BadBlock
+	if err := db.ForEach(modules.BadBlocks, nil, func(_, v []byte) error {
+		bad, err := decodeBadBlock(v)
+		if err != nil {
+			return err
+		}
+		bads = append(bads, bad)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(bads, func(i, j int) bool {
+		return bads[i].Block.Number64().Uint64() > bads[j].Block.Number64().Uint64()
+	})
+	return bads, nil
+}
+
+func decodeBadBlock(data []byte) (*BadBlock, error) {
+	var record badBlockRecord
+	if err := rlp.DecodeBytes(data, &record); err != nil {
+		return nil, err
+	}
+	bad := &BadBlock{Block: new(block.Block), Reason: record.Reason, Time: record.Time}
+	if err := bad.Block.Unmarshal(record.Block); err != nil {
+		return nil, err
+	}
+	if err := bad.Receipts.Unmarshal(record.Receipts); err != nil {
+		return nil, err
+	}
+	return bad, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// bloomBitsKey = bit (uint16 big endian) + section (uint64 big endian) + head hash
+func bloomBitsKey(bit uint, section uint64, head types.Hash) []byte {
+	key := make([]byte, 2+8+types.HashLength)
+	binary.BigEndian.PutUint16(key, uint16(bit))
+	binary.BigEndian.PutUint64(key[2:], section)
+	copy(key[10:], head[:])
+	return key
+}
+
+// ReadBloomBits returns the compressed bit vector of a bloom bit over a
+// section with the given head.
+func ReadBloomBits(db kv.Getter, bit uint, section uint64, head types.Hash) ([]byte, error) {
+	return db.GetOne(modules.BloomBits, bloomBitsKey(bit, section, head))
+}
+
+// WriteBloomBits stores the compressed bit vector of a bloom bit over a section.
+func WriteBloomBits(db kv.Putter, bit uint, section uint64, head types.Hash, bits []byte) error {
+	return db.Put(modules.BloomBits, bloomBitsKey(bit, section, head), bits)
+}
+
+// DeleteBloomBits deletes the bit vectors of every bloom bit over a section.
+func DeleteBloomBits(db kv.Deleter, section uint64, head types.Hash) error {
+	for bit := uint(0); bit < block.BloomBitLength; bit++ {
+		if err := db.Delete(modules.BloomBits, bloomBitsKey(bit, section, head)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadBloomSectionHead returns the hash of the last block of an indexed section.
+func ReadBloomSectionHead(db kv.Getter, section uint64) (types.Hash, bool, error) {
+	data, err := db.GetOne(modules.BloomBitsIndex, modules.EncodeBlockNumber(section))
+	if err != nil || data == nil {
+		return types.Hash{}, false, err
+	}
+	if len(data) != types.HashLength {
+		return types.Hash{}, false, fmt.Errorf("invalid bloom section head length %d", len(data))
+	}
+	return types.BytesToHash(data), true, nil
+}
+
+// WriteBloomSectionHead records a section as indexed up to the given head.
+func WriteBloomSectionHead(db kv.Putter, section uint64, head types.Hash) error {
+	return db.Put(modules.BloomBitsIndex, modules.EncodeBlockNumber(section), head.Bytes())
+}
+
+// DeleteBloomSectionHead marks a section as no longer indexed.
+func DeleteBloomSectionHead(db kv.Deleter, section uint64) error {
+	return db.Delete(modules.BloomBitsIndex, modules.EncodeBlockNumber(section))
+}
+
+// ReadBloomSections returns the number of indexed sections. Sections are
+// indexed in order, so that it is one past the last one recorded.
+func ReadBloomSections(tx kv.Tx) (uint64, error) {
+	c, err := tx.Cursor(modules.BloomBitsIndex)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	k, _, err := c.Last()
+	if err != nil || k == nil {
+		return 0, err
+	}
+	section, err := modules.DecodeBlockNumber(k)
+	if err != nil {
+		return 0, err
+	}
+	return section + 1, nil
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/amazechain/amc/common/types"
 	"github.com/amazechain/amc/log"
 	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/freezer"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/holiman/uint256"
@@ -121,13 +122,16 @@ func ReadHeaderRAW(db kv.Getter, hash types.Hash, number uint64) []byte {
 	if err != nil {
 		log.Error("ReadHeaderRAW failed", "err", err)
 	}
+	if len(data) == 0 {
+		return readAncient(freezer.Headers, hash, number)
+	}
 	return data
 }
 
 // HasHeader verifies the existence of a block header corresponding to the hash.
 func HasHeader(db kv.Has, hash types.Hash, number uint64) bool {
 	if has, err := db.Has(modules.Headers, modules.HeaderKey(number, hash)); !has || err != nil {
-		return false
+		return len(readAncient(freezer.Hashes, hash, number)) > 0
 	}
 	return true
 }
@@ -215,6 +219,15 @@ func ReadHeadersByNumber(db kv.Tx, number uint64) ([]*block.Header, error) {
 		}
 		res = append(res, header)
 	}
+	if len(res) == 0 && number < Ancients() {
+		hash, err := ReadCanonicalHash(db, number)
+		if err != nil {
+			return nil, err
+		}
+		if header := ReadHeader(db, hash, number); header != nil {
+			res = append(res, header)
+		}
+	}
 	return res, nil
 }
 
@@ -291,6 +304,9 @@ func CanonicalTxnByID(db kv.Getter, id uint64) (*transaction.Transaction, error)
 	if err != nil {
 		return nil, err
 	}
+	if v, _, err = decodeStored(v); err != nil {
+		return nil, err
+	}
 
 	tx := new(transaction.Transaction)
 	if err := tx.Unmarshal(v); nil != err {
@@ -310,7 +326,10 @@ func CanonicalTransactions(db kv.Getter, baseTxId uint64, amount uint32) ([]*tra
 	i := uint32(0)
 
 	if err := db.ForAmount(modules.BlockTx, txIdKey, amount, func(k, v []byte) error {
-		var decodeErr error
+		v, _, decodeErr := decodeStored(v)
+		if decodeErr != nil {
+			return decodeErr
+		}
 		tx := new(transaction.Transaction)
 		if decodeErr = tx.Unmarshal(v); nil != decodeErr {
 			return decodeErr
@@ -343,7 +362,7 @@ func WriteTransactions(db kv.RwTx, txs []*transaction.Transaction, baseTxId uint
 		//}
 
 		// If next Append returns KeyExists error - it means you need to open transaction in App code before calling this func. Batch is also fine.
-		if err := db.Append(modules.BlockTx, txIdKey, types.CopyBytes(data)); err != nil {
+		if err := db.Append(modules.BlockTx, txIdKey, encodeStored(data, false)); err != nil {
 			return err
 		}
 	}
@@ -356,7 +375,7 @@ func WriteRawTransactions(tx kv.RwTx, txs [][]byte, baseTxId uint64) error {
 		txIdKey := make([]byte, 8)
 		binary.BigEndian.PutUint64(txIdKey, txId)
 		// If next Append returns KeyExists error - it means you need to open transaction in App code before calling this func. Batch is also fine.
-		if err := tx.Append(modules.BlockTx, txIdKey, txn); err != nil {
+		if err := tx.Append(modules.BlockTx, txIdKey, encodeStored(txn, false)); err != nil {
 			return fmt.Errorf("txId=%d, baseTxId=%d, %w", txId, baseTxId, err)
 		}
 		txId++
@@ -397,7 +416,7 @@ func ReadBodyWithTransactions(db kv.Getter, hash types.Hash, number uint64) (*bl
 func ReadCanonicalBodyWithTransactions(db kv.Getter, hash types.Hash, number uint64) *block.Body {
 	body, baseTxId, txAmount := ReadBody(db, hash, number)
 	if body == nil {
-		return nil
+		return readAncientBody(hash, number)
 	}
 	var err error
 	body.Txs, err = CanonicalTransactions(db, baseTxId, txAmount)
@@ -450,7 +469,11 @@ func RawTransactionsRange(db kv.Getter, from, to uint64) (res [][]byte, err erro
 
 		binary.BigEndian.PutUint64(encNum, baseTxId)
 		if err = db.ForAmount(modules.BlockTx, encNum, txAmount, func(k, v []byte) error {
-			res = append(res, v)
+			v, _, err := decodeStored(v)
+			if err != nil {
+				return err
+			}
+			res = append(res, types.CopyBytes(v))
 			return nil
 		}); err != nil {
 			return nil, err
@@ -675,7 +698,7 @@ func TruncateTd(tx kv.RwTx, blockFrom uint64) error {
 // to a block.
 func HasReceipts(db kv.Has, number uint64) bool {
 	if has, err := db.Has(modules.Receipts, modules.EncodeBlockNumber(number)); !has || err != nil {
-		return false
+		return number < Ancients()
 	}
 	return true
 }
@@ -689,12 +712,14 @@ func ReadRawReceipts(db kv.Tx, blockNum uint64) block.Receipts {
 	if err != nil {
 		log.Error("ReadRawReceipts failed", "err", err)
 	}
+	if len(data) == 0 {
+		data = readAncientReceipts(blockNum)
+	}
 	if len(data) == 0 {
 		return nil
 	}
-	var receipts block.Receipts
-
-	if err := receipts.Unmarshal(data); nil != err {
+	receipts, err := decodeReceipts(data)
+	if err != nil {
 		log.Error("ReadRawReceipts failed", "err", err)
 		return nil
 	}
@@ -736,10 +761,10 @@ func ReadReceipts(db kv.Tx, block *block.Block, senders []types.Address) block.R
 	if len(senders) > 0 {
 		block.SendersToTxs(senders)
 	}
-	//if err := receipts.DeriveFields(block.Hash(), block.NumberU64(), block.Transactions(), senders); err != nil {
-	//	log.Error("Failed to derive block receipts fields", "hash", block.Hash(), "number", block.NumberU64(), "err", err, "stack", dbg.Stack())
-	//	return nil
-	//}
+	if err := receipts.DeriveFields(block.Hash(), block.Number64().Uint64(), block.Transactions()); err != nil {
+		log.Error("Failed to derive block receipts fields", "hash", block.Hash(), "number", block.Number64().Uint64(), "err", err)
+		return nil
+	}
 	return receipts
 }
 
@@ -766,25 +791,10 @@ func ReadReceiptsByHash(db kv.Tx, hash types.Hash) (block.Receipts, error) {
 	return receipts, nil
 }
 
-// WriteReceipts stores all the transaction receipts belonging to a block.
+// WriteReceipts stores all the transaction receipts belonging to a block, in
+// the compact form: the fields derived from the block are dropped.
 func WriteReceipts(tx kv.Putter, number uint64, receipts block.Receipts) error {
-	for txId, r := range receipts {
-		if len(r.Logs) == 0 {
-			continue
-		}
-		var logs block.Logs
-		logs = r.Logs
-		v, err := logs.Marshal()
-		if err != nil {
-			return fmt.Errorf("encode block logs for block %d: %w", number, err)
-		}
-
-		if err = tx.Put(modules.Log, modules.LogKey(number, uint32(txId)), v); err != nil {
-			return fmt.Errorf("writing logs for block %d: %w", number, err)
-		}
-	}
-
-	v, err := receipts.Marshal()
+	v, err := encodeReceipts(receipts)
 	if err != nil {
 		return fmt.Errorf("encode block receipts for block %d: %w", number, err)
 	}
@@ -797,24 +807,7 @@ func WriteReceipts(tx kv.Putter, number uint64, receipts block.Receipts) error {
 
 // AppendReceipts stores all the transaction receipts belonging to a block.
 func AppendReceipts(tx kv.StatelessWriteTx, blockNumber uint64, receipts block.Receipts) error {
-	for txId, r := range receipts {
-		if len(r.Logs) == 0 {
-			continue
-		}
-
-		var logs block.Logs
-		logs = r.Logs
-		v, err := logs.Marshal()
-		if nil != err {
-			return err
-		}
-
-		if err = tx.Append(modules.Log, modules.LogKey(blockNumber, uint32(txId)), v); err != nil {
-			return fmt.Errorf("writing receipts for block %d: %w", blockNumber, err)
-		}
-	}
-
-	rv, err := receipts.Marshal()
+	rv, err := encodeReceipts(receipts)
 	if err != nil {
 		return fmt.Errorf("encode block receipts for block %d: %w", blockNumber, err)
 	}
@@ -844,6 +837,9 @@ func TruncateReceipts(db kv.RwTx, number uint64) error {
 }
 
 func ReceiptsAvailableFrom(tx kv.Tx) (uint64, error) {
+	if Ancients() > 0 {
+		return 0, nil
+	}
 	c, err := tx.Cursor(modules.Receipts)
 	if err != nil {
 		return math.MaxUint64, err
@@ -881,7 +877,7 @@ func ReadBlock(tx kv.Getter, hash types.Hash, number uint64) *block.Block {
 // It's is not equivalent of HasHeader because headers and bodies written by different stages
 func HasBlock(db kv.Getter, hash types.Hash, number uint64) bool {
 	body := ReadStorageBodyRAW(db, hash, number)
-	return len(body) > 0
+	return len(body) > 0 || len(readAncient(freezer.Hashes, hash, number)) > 0
 }
 
 func ReadBlockWithSenders(db kv.Getter, hash types.Hash, number uint64) (*block.Block, []types.Address, error) {
@@ -1339,6 +1335,46 @@ func WriteHeadHeaderHash(db kv.Putter, hash types.Hash) error {
 	return nil
 }
 
+// ReadSafeBlockHash retrieves the hash of the latest justified block.
+func ReadSafeBlockHash(db kv.Getter) types.Hash {
+	data, err := db.GetOne(modules.SafeBlockKey, []byte(modules.SafeBlockKey))
+	if err != nil {
+		log.Error("ReadSafeBlockHash failed", "err", err)
+	}
+	if len(data) == 0 {
+		return types.Hash{}
+	}
+	return types.BytesToHash(data)
+}
+
+// WriteSafeBlockHash stores the hash of the latest justified block.
+func WriteSafeBlockHash(db kv.Putter, hash types.Hash) error {
+	if err := db.Put(modules.SafeBlockKey, []byte(modules.SafeBlockKey), hash.Bytes()); err != nil {
+		return fmt.Errorf("failed to store safe block's hash: %w", err)
+	}
+	return nil
+}
+
+// ReadFinalizedBlockHash retrieves the hash of the latest finalized block.
+func ReadFinalizedBlockHash(db kv.Getter) types.Hash {
+	data, err := db.GetOne(modules.FinalizedBlockKey, []byte(modules.FinalizedBlockKey))
+	if err != nil {
+		log.Error("ReadFinalizedBlockHash failed", "err", err)
+	}
+	if len(data) == 0 {
+		return types.Hash{}
+	}
+	return types.BytesToHash(data)
+}
+
+// WriteFinalizedBlockHash stores the hash of the latest finalized block.
+func WriteFinalizedBlockHash(db kv.Putter, hash types.Hash) error {
+	if err := db.Put(modules.FinalizedBlockKey, []byte(modules.FinalizedBlockKey), hash.Bytes()); err != nil {
+		return fmt.Errorf("failed to store finalized block's hash: %w", err)
+	}
+	return nil
+}
+
 func GetPoaSnapshot(db kv.Getter, hash types.Hash) ([]byte, error) {
 
 	return db.GetOne(modules.PoaSnapshot, hash.Bytes())
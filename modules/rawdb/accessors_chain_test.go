@@ -18,8 +18,8 @@ package rawdb
 
 import (
 	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
 	"github.com/holiman/uint256"
-	"github.com/ledgerwatch/erigon-lib/kv/memdb"
 	"testing"
 )
 
@@ -0,0 +1,58 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// ReadTrustedHeader returns the hash and parent hash of the trusted header at
+// the given number.
+func ReadTrustedHeader(db kv.Getter, number uint64) (types.Hash, types.Hash, bool, error) {
+	data, err := db.GetOne(modules.TrustedHeaders, modules.EncodeBlockNumber(number))
+	if err != nil || data == nil {
+		return types.Hash{}, types.Hash{}, false, err
+	}
+	if len(data) != 2*types.HashLength {
+		return types.Hash{}, types.Hash{}, false, fmt.Errorf("invalid trusted header length %d", len(data))
+	}
+	return types.BytesToHash(data[:types.HashLength]), types.BytesToHash(data[types.HashLength:]), true, nil
+}
+
+// WriteTrustedHeader stores the hash and parent hash of a trusted header.
+func WriteTrustedHeader(db kv.Putter, number uint64, hash, parent types.Hash) error {
+	return db.Put(modules.TrustedHeaders, modules.EncodeBlockNumber(number), append(hash.Bytes(), parent[:]...))
+}
+
+// LowestTrustedHeader returns the number of the lowest trusted header.
+func LowestTrustedHeader(tx kv.Tx) (uint64, bool, error) {
+	c, err := tx.Cursor(modules.TrustedHeaders)
+	if err != nil {
+		return 0, false, err
+	}
+	defer c.Close()
+	k, _, err := c.First()
+	if err != nil || k == nil {
+		return 0, false, err
+	}
+	number, err := modules.DecodeBlockNumber(k)
+	return number, err == nil, err
+}
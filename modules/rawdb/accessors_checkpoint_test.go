@@ -0,0 +1,53 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+)
+
+// Tests trusted header storage and retrieval operations.
+func TestTrustedHeaderStorage(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+
+	if _, ok, err := LowestTrustedHeader(tx); err != nil || ok {
+		t.Fatalf("lowest trusted header of an empty table: %v, %v", ok, err)
+	}
+	if _, _, ok, err := ReadTrustedHeader(tx, 10); err != nil || ok {
+		t.Fatalf("non existent trusted header returned: %v, %v", ok, err)
+	}
+	for _, number := range []uint64{12, 10, 11} {
+		if err := WriteTrustedHeader(tx, number, types.Hash{byte(number)}, types.Hash{byte(number - 1)}); err != nil {
+			t.Fatalf("WriteTrustedHeader failed: %v", err)
+		}
+	}
+	for _, number := range []uint64{10, 11, 12} {
+		hash, parent, ok, err := ReadTrustedHeader(tx, number)
+		if err != nil || !ok {
+			t.Fatalf("trusted header #%d not found: %v", number, err)
+		}
+		if hash != (types.Hash{byte(number)}) || parent != (types.Hash{byte(number - 1)}) {
+			t.Errorf("trusted header #%d: hash %s parent %s", number, hash, parent)
+		}
+	}
+	if lowest, ok, err := LowestTrustedHeader(tx); err != nil || !ok || lowest != 10 {
+		t.Errorf("lowest trusted header %d, %v, %v, want 10", lowest, ok, err)
+	}
+}
@@ -17,6 +17,8 @@
 package rawdb
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"github.com/amazechain/amc/common/crypto/bls"
 	"github.com/amazechain/amc/common/types"
@@ -83,3 +85,167 @@ func DepositNum(tx kv.Tx) (uint64, error) {
 	defer cur.Close()
 	return cur.Count()
 }
+
+// Prefixes of the activation and exit queue counters in the lifecycle table.
+var (
+	activationQueuePrefix = []byte("a")
+	exitQueuePrefix       = []byte("e")
+)
+
+// PutValidatorLifecycle stores the encoded lifecycle of a validator.
+func PutValidatorLifecycle(db kv.Putter, addr types.Address, data []byte) error {
+	if err := db.Put(modules.DepositLifecycle, addr[:], data); err != nil {
+		return fmt.Errorf("failed to store validator lifecycle: %w", err)
+	}
+	return nil
+}
+
+// GetValidatorLifecycle retrieves the encoded lifecycle of a validator, nil if
+// the address never deposited through the lifecycle.
+func GetValidatorLifecycle(db kv.Getter, addr types.Address) ([]byte, error) {
+	return db.GetOne(modules.DepositLifecycle, addr[:])
+}
+
+// ForEachValidatorLifecycle iterates over all the stored validator lifecycles.
+func ForEachValidatorLifecycle(tx kv.Tx, f func(addr types.Address, data []byte) error) error {
+	return tx.ForEach(modules.DepositLifecycle, nil, func(k, v []byte) error {
+		if len(k) != types.AddressLength {
+			return nil
+		}
+		return f(types.BytesToAddress(k), v)
+	})
+}
+
+func queueKey(prefix []byte, epoch uint64) []byte {
+	key := make([]byte, len(prefix)+8)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], epoch)
+	return key
+}
+
+func readQueue(db kv.Getter, prefix []byte, epoch uint64) (uint64, error) {
+	v, err := db.GetOne(modules.DepositLifecycle, queueKey(prefix, epoch))
+	if err != nil || len(v) != 8 {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func writeQueue(db kv.Putter, prefix []byte, epoch, size uint64) error {
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], size)
+	return db.Put(modules.DepositLifecycle, queueKey(prefix, epoch), v[:])
+}
+
+// ReadActivationQueue returns the number of validators activating at epoch.
+func ReadActivationQueue(db kv.Getter, epoch uint64) (uint64, error) {
+	return readQueue(db, activationQueuePrefix, epoch)
+}
+
+// WriteActivationQueue stores the number of validators activating at epoch.
+func WriteActivationQueue(db kv.Putter, epoch, size uint64) error {
+	return writeQueue(db, activationQueuePrefix, epoch, size)
+}
+
+// ReadExitQueue returns the number of validators exiting at epoch.
+func ReadExitQueue(db kv.Getter, epoch uint64) (uint64, error) {
+	return readQueue(db, exitQueuePrefix, epoch)
+}
+
+// WriteExitQueue stores the number of validators exiting at epoch.
+func WriteExitQueue(db kv.Putter, epoch, size uint64) error {
+	return writeQueue(db, exitQueuePrefix, epoch, size)
+}
+
+// Keys of the deposit journal, which follows the validator lifecycle along
+// the canonical chain.
+var (
+	depositHeadKey      = []byte("head")
+	depositEventsPrefix = []byte("e")
+	depositUndoPrefix   = []byte("u")
+)
+
+func depositEventsKey(number uint64, hash types.Hash) []byte {
+	return append(append(types.CopyBytes(depositEventsPrefix), modules.EncodeBlockNumber(number)...), hash[:]...)
+}
+
+func depositUndoKey(number uint64) []byte {
+	return append(types.CopyBytes(depositUndoPrefix), modules.EncodeBlockNumber(number)...)
+}
+
+// WriteDepositHead records the block the validator lifecycle was brought to.
+func WriteDepositHead(db kv.Putter, number uint64, hash types.Hash) error {
+	return db.Put(modules.DepositJournal, depositHeadKey, append(modules.EncodeBlockNumber(number), hash[:]...))
+}
+
+// ReadDepositHead returns the block the validator lifecycle was brought to,
+// false if none was recorded.
+func ReadDepositHead(db kv.Getter) (uint64, types.Hash, bool, error) {
+	v, err := db.GetOne(modules.DepositJournal, depositHeadKey)
+	if err != nil || len(v) == 0 {
+		return 0, types.Hash{}, false, err
+	}
+	if len(v) != 8+types.HashLength {
+		return 0, types.Hash{}, false, fmt.Errorf("invalid deposit head length %d", len(v))
+	}
+	return binary.BigEndian.Uint64(v), types.BytesToHash(v[8:]), true, nil
+}
+
+// WriteDepositEvents stores the encoded deposit events of a block.
+func WriteDepositEvents(db kv.Putter, number uint64, hash types.Hash, data []byte) error {
+	return db.Put(modules.DepositJournal, depositEventsKey(number, hash), data)
+}
+
+// ReadDepositEvents returns the encoded deposit events of a block, nil if it
+// had none.
+func ReadDepositEvents(db kv.Getter, number uint64, hash types.Hash) ([]byte, error) {
+	return db.GetOne(modules.DepositJournal, depositEventsKey(number, hash))
+}
+
+// WriteDepositUndo stores the encoded lifecycle entries the canonical block at
+// number overwrote, so that they can be put back if it is reverted.
+func WriteDepositUndo(db kv.Putter, number uint64, hash types.Hash, data []byte) error {
+	return db.Put(modules.DepositJournal, depositUndoKey(number), append(types.CopyBytes(hash[:]), data...))
+}
+
+// ReadDepositUndo returns the block that wrote the undo record at number and
+// the record, nil if there is none.
+func ReadDepositUndo(db kv.Getter, number uint64) (types.Hash, []byte, error) {
+	v, err := db.GetOne(modules.DepositJournal, depositUndoKey(number))
+	if err != nil || len(v) == 0 {
+		return types.Hash{}, nil, err
+	}
+	if len(v) < types.HashLength {
+		return types.Hash{}, nil, fmt.Errorf("invalid deposit undo record length %d", len(v))
+	}
+	return types.BytesToHash(v[:types.HashLength]), v[types.HashLength:], nil
+}
+
+// DeleteDepositUndo removes the undo record at number.
+func DeleteDepositUndo(db kv.Deleter, number uint64) error {
+	return db.Delete(modules.DepositJournal, depositUndoKey(number))
+}
+
+// PruneDepositJournal drops the events and undo records of the blocks below
+// to, which can't be reverted anymore.
+func PruneDepositJournal(tx kv.RwTx, to uint64) error {
+	c, err := tx.RwCursor(modules.DepositJournal)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for _, prefix := range [][]byte{depositEventsPrefix, depositUndoPrefix} {
+		for k, _, err := c.Seek(prefix); k != nil; k, _, err = c.Next() {
+			if err != nil {
+				return err
+			}
+			if !bytes.HasPrefix(k, prefix) || len(k) < len(prefix)+8 || binary.BigEndian.Uint64(k[len(prefix):]) >= to {
+				break
+			}
+			if err := c.DeleteCurrent(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/amazechain/amc/common/block"
+	"github.com/golang/snappy"
+)
+
+// The receipts, and the transactions once compression is on, are stored
+// behind a byte telling how they are encoded. Values without the byte are in
+// the legacy protobuf encoding: no protobuf message starts with a field of
+// wire type 7, which the byte always has.
+const (
+	storedMarker     byte = 0x07
+	storedCompressed byte = 0x08 // the rest is compressed with snappy
+)
+
+// compression makes the writers of transactions and receipts compress them.
+var compression atomic.Bool
+
+// SetCompression turns the snappy compression of the transactions and
+// receipts written from now on on or off. Both forms are always read.
+func SetCompression(enabled bool) {
+	compression.Store(enabled)
+}
+
+// encodeStored prefixes data with the encoding byte, compressing it if
+// compression is on and it pays off. Unless marked is set, data is left
+// as is when it isn't compressed.
+func encodeStored(data []byte, marked bool) []byte {
+	if compression.Load() {
+		if compressed := snappy.Encode(nil, data); len(compressed)+1 < len(data) {
+			return append([]byte{storedMarker | storedCompressed}, compressed...)
+		}
+	}
+	if !marked {
+		return data
+	}
+	return append([]byte{storedMarker}, data...)
+}
+
+// decodeStored undoes encodeStored, reporting whether data had the encoding
+// byte at all.
+func decodeStored(data []byte) ([]byte, bool, error) {
+	if len(data) == 0 || data[0]&storedMarker != storedMarker {
+		return data, false, nil
+	}
+	if data[0]&storedCompressed == 0 {
+		return data[1:], true, nil
+	}
+	decoded, err := snappy.Decode(nil, data[1:])
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid compressed value: %w", err)
+	}
+	return decoded, true, nil
+}
+
+// encodeReceipts returns the stored form of the receipts of a block.
+func encodeReceipts(receipts block.Receipts) ([]byte, error) {
+	data, err := receipts.MarshalForStorage()
+	if err != nil {
+		return nil, err
+	}
+	return encodeStored(data, true), nil
+}
+
+// decodeReceipts decodes stored receipts, compact or legacy. The fields of
+// compact receipts derived from the block are left empty.
+func decodeReceipts(data []byte) (block.Receipts, error) {
+	payload, marked, err := decodeStored(data)
+	if err != nil {
+		return nil, err
+	}
+	var receipts block.Receipts
+	if marked {
+		err = receipts.UnmarshalForStorage(payload)
+	} else {
+		err = receipts.Unmarshal(payload)
+	}
+	return receipts, err
+}
+
+// IsCompactReceipts reports whether stored receipts are in the compact form.
+func IsCompactReceipts(data []byte) bool {
+	return len(data) > 0 && data[0]&storedMarker == storedMarker
+}
+
+// CompactReceipts converts stored receipts in the legacy encoding to the
+// compact one.
+func CompactReceipts(data []byte) ([]byte, error) {
+	receipts, err := decodeReceipts(data)
+	if err != nil {
+		return nil, err
+	}
+	return encodeReceipts(receipts)
+}
@@ -17,6 +17,9 @@
 package rawdb
 
 import (
+	"encoding/binary"
+	"fmt"
+
 	"github.com/amazechain/amc/common/block"
 	"github.com/amazechain/amc/common/transaction"
 	"github.com/amazechain/amc/common/types"
@@ -66,6 +69,69 @@ func DeleteTxLookupEntry(db kv.Deleter, hash types.Hash) error {
 	return db.Delete(modules.TxLookup, hash.Bytes())
 }
 
+var txIndexTailKey = []byte("txindex")
+
+// ReadTxIndexTail returns the first block whose transactions are indexed.
+func ReadTxIndexTail(db kv.Getter) (uint64, error) {
+	data, err := db.GetOne(modules.StatePrune, txIndexTailKey)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid tx index tail length %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// WriteTxIndexTail records the first block whose transactions are indexed.
+func WriteTxIndexTail(db kv.Putter, number uint64) error {
+	return db.Put(modules.StatePrune, txIndexTailKey, modules.EncodeBlockNumber(number))
+}
+
+// WriteCanonicalTxLookupEntries indexes the transactions of the canonical
+// block at the given number.
+func WriteCanonicalTxLookupEntries(db kv.RwTx, number uint64) error {
+	body, err := readCanonicalTxs(db, number)
+	if err != nil || body == nil {
+		return err
+	}
+	data := uint256.NewInt(number).Bytes()
+	for _, tx := range body.Txs {
+		h := tx.Hash()
+		if err := db.Put(modules.TxLookup, h.Bytes(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCanonicalTxLookupEntries removes the index of the transactions of the
+// canonical block at the given number.
+func DeleteCanonicalTxLookupEntries(db kv.RwTx, number uint64) error {
+	body, err := readCanonicalTxs(db, number)
+	if err != nil || body == nil {
+		return err
+	}
+	for _, tx := range body.Txs {
+		if err := DeleteTxLookupEntry(db, tx.Hash()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readCanonicalTxs(db kv.Getter, number uint64) (*block.Body, error) {
+	hash, err := ReadCanonicalHash(db, number)
+	if err != nil || hash == (types.Hash{}) {
+		return nil, err
+	}
+	body := ReadCanonicalBodyWithTransactions(db, hash, number)
+	if body == nil {
+		return nil, fmt.Errorf("missing body of canonical block #%d", number)
+	}
+	return body, nil
+}
+
 // ReadTransactionByHash retrieves a specific transaction from the database, along with
 // its added positional metadata.
 func ReadTransactionByHash(db kv.Tx, hash types.Hash) (*transaction.Transaction, types.Hash, uint64, uint64, error) {
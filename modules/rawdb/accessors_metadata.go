@@ -17,6 +17,7 @@
 package rawdb
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"github.com/amazechain/amc/common/types"
@@ -59,3 +60,46 @@ func WriteChainConfig(db kv.RwTx, hash types.Hash, cfg *params.ChainConfig) erro
 	}
 	return nil
 }
+
+var (
+	schemaVersionKey     = []byte("SchemaVersion")
+	migrationProgressKey = []byte("MigrationProgress")
+)
+
+// ReadSchemaVersion retrieves the layout version of the database, and
+// whether one was stored at all.
+func ReadSchemaVersion(db kv.Getter) (uint64, bool, error) {
+	data, err := db.GetOne(modules.DatabaseInfo, schemaVersionKey)
+	if err != nil || len(data) == 0 {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, fmt.Errorf("invalid schema version %x", data)
+	}
+	return binary.BigEndian.Uint64(data), true, nil
+}
+
+// WriteSchemaVersion stores the layout version of the database.
+func WriteSchemaVersion(db kv.Putter, version uint64) error {
+	return db.Put(modules.DatabaseInfo, schemaVersionKey, modules.EncodeBlockNumber(version))
+}
+
+// ReadMigrationProgress retrieves what the migration to the given version
+// saved before it was interrupted, nil if it saved nothing.
+func ReadMigrationProgress(db kv.Getter, version uint64) ([]byte, error) {
+	data, err := db.GetOne(modules.DatabaseInfo, migrationProgressKey)
+	if err != nil || len(data) < 8 || binary.BigEndian.Uint64(data) != version {
+		return nil, err
+	}
+	return types.CopyBytes(data[8:]), nil
+}
+
+// WriteMigrationProgress stores the progress of the running migration.
+func WriteMigrationProgress(db kv.Putter, version uint64, progress []byte) error {
+	return db.Put(modules.DatabaseInfo, migrationProgressKey, append(modules.EncodeBlockNumber(version), progress...))
+}
+
+// DeleteMigrationProgress drops the progress of a finished migration.
+func DeleteMigrationProgress(db kv.Deleter) error {
+	return db.Delete(modules.DatabaseInfo, migrationProgressKey)
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// ReadPreimage retrieves the input of a recorded keccak256 hash, nil if the
+// hash wasn't recorded.
+func ReadPreimage(db kv.Getter, hash types.Hash) ([]byte, error) {
+	return db.GetOne(modules.Preimages, hash[:])
+}
+
+// WritePreimages stores preimages, keeping those already known.
+func WritePreimages(db kv.RwTx, preimages map[types.Hash][]byte) error {
+	for hash, preimage := range preimages {
+		if ok, err := db.Has(modules.Preimages, hash[:]); err != nil {
+			return err
+		} else if ok {
+			continue
+		}
+		if err := db.Put(modules.Preimages, hash[:], preimage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachPreimage calls f with every recorded preimage, in hash order.
+func ForEachPreimage(db kv.Tx, f func(hash types.Hash, preimage []byte) error) error {
+	return db.ForEach(modules.Preimages, nil, func(k, v []byte) error {
+		return f(types.BytesToHash(k), v)
+	})
+}
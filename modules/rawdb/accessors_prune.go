@@ -0,0 +1,46 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var statePruneHistoryKey = []byte("history")
+
+// ReadStatePruneProgress returns the first block whose state changes are
+// still in the database. The state can't be read as of an earlier block.
+func ReadStatePruneProgress(db kv.Getter) (uint64, error) {
+	data, err := db.GetOne(modules.StatePrune, statePruneHistoryKey)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid state prune progress length %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// WriteStatePruneProgress records that the state changes of the blocks below
+// number have been pruned.
+func WriteStatePruneProgress(db kv.Putter, number uint64) error {
+	return db.Put(modules.StatePrune, statePruneHistoryKey, modules.EncodeBlockNumber(number))
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var (
+	snapSyncProgressKey = []byte("progress")
+	snapSyncRangeKey    = []byte("range")
+	snapSyncHealKey     = []byte("heal")
+	snapSyncBlocksKey   = []byte("blocks")
+)
+
+// isSnapSyncMarker reports whether the key is one of the progress records
+// rather than a kept genesis entry.
+func isSnapSyncMarker(k []byte) bool {
+	switch string(k) {
+	case string(snapSyncProgressKey), string(snapSyncRangeKey), string(snapSyncHealKey), string(snapSyncBlocksKey):
+		return true
+	}
+	return false
+}
+
+// ReadSnapSyncProgress returns the pivot of an unfinished snap sync. The pivot
+// is zero while the state is being downloaded.
+func ReadSnapSyncProgress(db kv.Getter) (uint64, types.Hash, bool, error) {
+	data, err := db.GetOne(modules.SnapSync, snapSyncProgressKey)
+	if err != nil || data == nil {
+		return 0, types.Hash{}, false, err
+	}
+	if len(data) != 8+types.HashLength {
+		return 0, types.Hash{}, false, fmt.Errorf("invalid snap sync progress length %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data), types.BytesToHash(data[8:]), true, nil
+}
+
+// WriteSnapSyncProgress records the pivot of a running snap sync.
+func WriteSnapSyncProgress(db kv.Putter, number uint64, hash types.Hash) error {
+	data := make([]byte, 8+types.HashLength)
+	binary.BigEndian.PutUint64(data, number)
+	copy(data[8:], hash[:])
+	return db.Put(modules.SnapSync, snapSyncProgressKey, data)
+}
+
+// DeleteSnapSyncProgress marks snap sync as finished.
+func DeleteSnapSyncProgress(db kv.Deleter) error {
+	return db.Delete(modules.SnapSync, snapSyncProgressKey)
+}
+
+// ReadSnapSyncRange returns where the state download stopped: the next table
+// and key to fetch and the oldest head the fetched ranges were read at.
+func ReadSnapSyncRange(db kv.Getter) (table uint64, origin []byte, from uint64, ok bool, err error) {
+	data, err := db.GetOne(modules.SnapSync, snapSyncRangeKey)
+	if err != nil || data == nil {
+		return 0, nil, 0, false, err
+	}
+	if len(data) < 16 {
+		return 0, nil, 0, false, fmt.Errorf("invalid snap sync range length %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data), types.CopyBytes(data[16:]), binary.BigEndian.Uint64(data[8:]), true, nil
+}
+
+// WriteSnapSyncRange records the position of the state download.
+func WriteSnapSyncRange(db kv.Putter, table uint64, origin []byte, from uint64) error {
+	data := make([]byte, 16, 16+len(origin))
+	binary.BigEndian.PutUint64(data, table)
+	binary.BigEndian.PutUint64(data[8:], from)
+	return db.Put(modules.SnapSync, snapSyncRangeKey, append(data, origin...))
+}
+
+// DeleteSnapSyncRange marks the range download as finished.
+func DeleteSnapSyncRange(db kv.Deleter) error {
+	return db.Delete(modules.SnapSync, snapSyncRangeKey)
+}
+
+// ReadSnapSyncHeal returns the block the state is healed up to, once all the
+// ranges were downloaded.
+func ReadSnapSyncHeal(db kv.Getter) (uint64, bool, error) {
+	return readSnapSyncNumber(db, snapSyncHealKey)
+}
+
+// WriteSnapSyncHeal records the block the state is healed up to.
+func WriteSnapSyncHeal(db kv.Putter, covered uint64) error {
+	return db.Put(modules.SnapSync, snapSyncHealKey, modules.EncodeBlockNumber(covered))
+}
+
+// ReadSnapSyncBlocks returns the last block imported below the pivot.
+func ReadSnapSyncBlocks(db kv.Getter) (uint64, bool, error) {
+	return readSnapSyncNumber(db, snapSyncBlocksKey)
+}
+
+// WriteSnapSyncBlocks records the last block imported below the pivot.
+func WriteSnapSyncBlocks(db kv.Putter, number uint64) error {
+	return db.Put(modules.SnapSync, snapSyncBlocksKey, modules.EncodeBlockNumber(number))
+}
+
+func readSnapSyncNumber(db kv.Getter, key []byte) (uint64, bool, error) {
+	data, err := db.GetOne(modules.SnapSync, key)
+	if err != nil || data == nil {
+		return 0, false, err
+	}
+	if len(data) != 8 {
+		return 0, false, fmt.Errorf("invalid snap sync %s length %d", key, len(data))
+	}
+	return binary.BigEndian.Uint64(data), true, nil
+}
+
+// WriteSnapSyncGenesis keeps an entry of the genesis state of a table.
+func WriteSnapSyncGenesis(db kv.Putter, table uint8, key, value []byte) error {
+	return db.Put(modules.SnapSync, append([]byte{table}, key...), value)
+}
+
+// ForEachSnapSyncGenesis iterates over the kept genesis state.
+func ForEachSnapSyncGenesis(tx kv.Tx, walker func(table uint8, key, value []byte) error) error {
+	return tx.ForEach(modules.SnapSync, nil, func(k, v []byte) error {
+		if len(k) < 2 || isSnapSyncMarker(k) {
+			return nil
+		}
+		return walker(k[0], k[1:], v)
+	})
+}
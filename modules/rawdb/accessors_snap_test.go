@@ -0,0 +1,102 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+)
+
+// Tests that the snap sync progress records round trip and are kept apart
+// from the genesis state entries.
+func TestSnapSyncProgressStorage(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+
+	if _, _, ok, err := ReadSnapSyncProgress(tx); err != nil || ok {
+		t.Fatalf("non existent progress returned: %v, %v", ok, err)
+	}
+	if _, _, _, ok, err := ReadSnapSyncRange(tx); err != nil || ok {
+		t.Fatalf("non existent range returned: %v, %v", ok, err)
+	}
+	if _, ok, err := ReadSnapSyncHeal(tx); err != nil || ok {
+		t.Fatalf("non existent heal block returned: %v, %v", ok, err)
+	}
+	if _, ok, err := ReadSnapSyncBlocks(tx); err != nil || ok {
+		t.Fatalf("non existent imported block returned: %v, %v", ok, err)
+	}
+
+	hash := types.Hash{0xab}
+	origin := []byte{0x01, 0x02, 0x00}
+	if err := WriteSnapSyncProgress(tx, 1000, hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSnapSyncRange(tx, 2, origin, math.MaxUint64); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSnapSyncHeal(tx, 990); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSnapSyncBlocks(tx, 512); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSnapSyncGenesis(tx, 1, []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	if number, h, ok, err := ReadSnapSyncProgress(tx); err != nil || !ok || number != 1000 || h != hash {
+		t.Errorf("progress #%d %s, %v, %v, want #1000 %s", number, h, ok, err, hash)
+	}
+	if table, o, from, ok, err := ReadSnapSyncRange(tx); err != nil || !ok || table != 2 || !bytes.Equal(o, origin) || from != math.MaxUint64 {
+		t.Errorf("range table %d origin %x from %d, %v, %v", table, o, from, ok, err)
+	}
+	if covered, ok, err := ReadSnapSyncHeal(tx); err != nil || !ok || covered != 990 {
+		t.Errorf("heal block %d, %v, %v, want 990", covered, ok, err)
+	}
+	if number, ok, err := ReadSnapSyncBlocks(tx); err != nil || !ok || number != 512 {
+		t.Errorf("imported block %d, %v, %v, want 512", number, ok, err)
+	}
+	var kept int
+	if err := ForEachSnapSyncGenesis(tx, func(table uint8, key, value []byte) error {
+		if table != 1 || string(key) != "key" || string(value) != "value" {
+			t.Errorf("genesis entry %d %q = %q", table, key, value)
+		}
+		kept++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if kept != 1 {
+		t.Errorf("%d genesis entries, want 1", kept)
+	}
+
+	if err := DeleteSnapSyncRange(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteSnapSyncProgress(tx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, ok, _ := ReadSnapSyncRange(tx); ok {
+		t.Error("range kept after deletion")
+	}
+	if _, _, ok, _ := ReadSnapSyncProgress(tx); ok {
+		t.Error("progress kept after deletion")
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/amazechain/amc/internal/metrics/prometheus"
+)
+
+// APIKey grants a downstream client access to the public endpoints of a
+// server. Requests carry the key in the X-Api-Key header or, for clients that
+// can't set headers such as browsers opening a websocket, in the apikey query
+// parameter.
+type APIKey struct {
+	Key  string `json:"key"`
+	Name string `json:"name"` // label of the key in logs and metrics
+
+	// Namespaces lists the API namespaces the key may call, all of the
+	// served ones if empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// RequestsPerSecond is the sustained request rate of the key across all
+	// its connections, Burst the requests it may issue at once. A zero rate
+	// leaves the key unlimited.
+	RequestsPerSecond float64 `json:"rateLimit,omitempty"`
+	Burst             int     `json:"rateBurst,omitempty"`
+
+	// Origins lists the origins allowed to use the key from a browser, "*"
+	// allowing any. Without origins, cross origin requests are refused.
+	Origins []string `json:"origins,omitempty"`
+}
+
+// APIKeyUsage reports the settings and usage of a key, without the key itself.
+type APIKeyUsage struct {
+	Name              string   `json:"name"`
+	Namespaces        []string `json:"namespaces"`
+	RequestsPerSecond float64  `json:"rateLimit"`
+	Origins           []string `json:"origins"`
+	Requests          uint64   `json:"requests"`
+	Rejected          uint64   `json:"rejected"`
+}
+
+const (
+	apiKeyHeader     = "X-Api-Key"
+	apiKeyQueryParam = "apikey"
+)
+
+var (
+	errMissingAPIKey = errors.New("missing API key")
+	errInvalidAPIKey = errors.New("invalid API key")
+)
+
+// accessKeyContextKey carries the key of a connection in its context.
+type accessKeyContextKey struct{}
+
+// accessKey is an API key along with its rate limiter and usage counters.
+type accessKey struct {
+	APIKey
+	namespaces map[string]bool // nil if every namespace is allowed
+	origins    map[string]bool
+	anyOrigin  bool
+
+	mu      sync.Mutex
+	bucket  *tokenBucket // nil if unlimited
+	revoked atomic.Bool  // set once the key is removed or replaced
+
+	requests prometheus.Counter
+	rejected prometheus.Counter
+}
+
+func newAccessKey(key APIKey) (*accessKey, error) {
+	if key.Key == "" {
+		return nil, errors.New("API key is empty")
+	}
+	if key.Name == "" {
+		return nil, errors.New("API key has no name")
+	}
+	if key.RequestsPerSecond < 0 || key.Burst < 0 {
+		return nil, fmt.Errorf("API key %s has a negative rate limit", key.Name)
+	}
+	k := &accessKey{
+		APIKey:   key,
+		origins:  make(map[string]bool),
+		requests: prometheus.GetOrCreateCounter(fmt.Sprintf(`rpc_apikey_requests_total{key="%s"}`, key.Name)),
+		rejected: prometheus.GetOrCreateCounter(fmt.Sprintf(`rpc_apikey_rejected_total{key="%s"}`, key.Name)),
+	}
+	for _, ns := range key.Namespaces {
+		if ns == "*" {
+			k.namespaces = nil
+			break
+		}
+		if k.namespaces == nil {
+			k.namespaces = make(map[string]bool)
+		}
+		k.namespaces[ns] = true
+	}
+	for _, origin := range key.Origins {
+		if origin == "*" {
+			k.anyOrigin = true
+		}
+		k.origins[strings.ToLower(origin)] = true
+	}
+	if key.RequestsPerSecond > 0 {
+		k.bucket = newTokenBucket(key.RequestsPerSecond, key.Burst, time.Now())
+	}
+	return k, nil
+}
+
+// allowNamespace reports whether the key may call methods of the namespace.
+// The rpc namespace describing the server is always allowed.
+func (k *accessKey) allowNamespace(namespace string) bool {
+	return k.namespaces == nil || namespace == JSONRPCApi || k.namespaces[namespace]
+}
+
+// allowOrigin reports whether a request from the origin may use the key.
+// Requests without an origin don't come from a browser.
+func (k *accessKey) allowOrigin(origin string) bool {
+	return origin == "" || k.anyOrigin || k.origins[strings.ToLower(origin)]
+}
+
+// allow takes a request from the rate limit of the key.
+func (k *accessKey) allow() bool {
+	if k.bucket == nil {
+		return true
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.bucket.allow(time.Now())
+}
+
+// writeCORS answers a cross origin request with the origin, which allowOrigin
+// has accepted already.
+func writeCORS(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join([]string{http.MethodPost, http.MethodGet}, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+		w.Header().Set("Access-Control-Max-Age", "600")
+	}
+}
+
+// AccessControl restricts the clients of a server to a set of API keys, each
+// limited to its own namespaces, request rate and browser origins. The keys
+// can be changed while the server runs.
+type AccessControl struct {
+	mu   sync.RWMutex
+	keys map[string]*accessKey
+}
+
+// NewAccessControl creates an access control admitting the given keys.
+func NewAccessControl(keys []APIKey) (*AccessControl, error) {
+	a := &AccessControl{keys: make(map[string]*accessKey)}
+	if err := a.SetKeys(keys); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadAPIKeys reads a JSON array of API keys from a file.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid API key file %s: %v", path, err)
+	}
+	return keys, nil
+}
+
+// SetKeys replaces all the keys. Usage is counted by key name, so a reloaded
+// key keeps its counters.
+func (a *AccessControl) SetKeys(keys []APIKey) error {
+	entries := make(map[string]*accessKey, len(keys))
+	for _, key := range keys {
+		if _, ok := entries[key.Key]; ok {
+			return fmt.Errorf("duplicate API key %s", key.Name)
+		}
+		entry, err := newAccessKey(key)
+		if err != nil {
+			return err
+		}
+		entries[key.Key] = entry
+	}
+	a.mu.Lock()
+	for _, old := range a.keys {
+		old.revoked.Store(true)
+	}
+	a.keys = entries
+	a.mu.Unlock()
+	return nil
+}
+
+// AddKey admits a key, replacing the settings of the key if it exists already.
+func (a *AccessControl) AddKey(key APIKey) error {
+	entry, err := newAccessKey(key)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	if old, ok := a.keys[key.Key]; ok {
+		old.revoked.Store(true)
+	}
+	a.keys[key.Key] = entry
+	a.mu.Unlock()
+	return nil
+}
+
+// RemoveKey revokes a key, reporting whether it existed. Connections opened
+// with the key are not closed, but their calls are refused.
+func (a *AccessControl) RemoveKey(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old, ok := a.keys[key]
+	if ok {
+		old.revoked.Store(true)
+		delete(a.keys, key)
+	}
+	return ok
+}
+
+// Usage returns the settings and usage of every key, sorted by name.
+func (a *AccessControl) Usage() []APIKeyUsage {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	usage := make([]APIKeyUsage, 0, len(a.keys))
+	for _, k := range a.keys {
+		usage = append(usage, APIKeyUsage{
+			Name:              k.Name,
+			Namespaces:        k.Namespaces,
+			RequestsPerSecond: k.RequestsPerSecond,
+			Origins:           k.Origins,
+			Requests:          k.requests.Get(),
+			Rejected:          k.rejected.Get(),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage
+}
+
+// preflight answers a CORS preflight request. Browsers send preflights
+// without the API key, so the origin is accepted if any key allows it and the
+// request that follows is authorized as usual.
+func (a *AccessControl) preflight(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		a.mu.RLock()
+		for _, k := range a.keys {
+			if k.allowOrigin(origin) {
+				writeCORS(w, r)
+				break
+			}
+		}
+		a.mu.RUnlock()
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorize looks up the key of an HTTP or websocket request and checks its
+// origin, returning the status code to refuse the request with on failure.
+func (a *AccessControl) authorize(r *http.Request) (*accessKey, int, error) {
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get(apiKeyQueryParam)
+	}
+	if key == "" {
+		return nil, http.StatusUnauthorized, errMissingAPIKey
+	}
+	a.mu.RLock()
+	k := a.keys[key]
+	a.mu.RUnlock()
+	if k == nil {
+		return nil, http.StatusUnauthorized, errInvalidAPIKey
+	}
+	if origin := r.Header.Get("Origin"); !k.allowOrigin(origin) {
+		k.rejected.Inc()
+		return nil, http.StatusForbidden, fmt.Errorf("origin %s not allowed for API key", origin)
+	}
+	return k, 0, nil
+}
+
+// admit checks a call made with the key, applying its rate limit if
+// rateLimited is set. Replaced keys are revoked too, a websocket opened with
+// the old settings has to reconnect.
+func (k *accessKey) admit(msg *jsonrpcMessage, rateLimited bool) Error {
+	switch {
+	case k.revoked.Load():
+		k.rejected.Inc()
+		return &accessDeniedError{errInvalidAPIKey.Error()}
+	case !k.allowNamespace(msg.namespace()):
+		k.rejected.Inc()
+		return &accessDeniedError{fmt.Sprintf("API key not allowed to call %s", msg.Method)}
+	case rateLimited && !k.allow():
+		k.rejected.Inc()
+		return &limitExceededError{"API key request rate limit exceeded"}
+	}
+	k.requests.Inc()
+	return nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type accessTestService struct{}
+
+func (accessTestService) Echo(s string) string { return s }
+
+func newAccessTestServer(t *testing.T, keys ...APIKey) (*Server, *AccessControl) {
+	access, err := NewAccessControl(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer()
+	if err := server.RegisterName("test", accessTestService{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("other", accessTestService{}); err != nil {
+		t.Fatal(err)
+	}
+	server.SetAccessControl(access)
+	return server, access
+}
+
+// serveAccessTest posts a call to the server, returning the response.
+func serveAccessTest(server *Server, method string, header http.Header) *httptest.ResponseRecorder {
+	body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":["hi"]}`
+	r := httptest.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader(body))
+	r.Header.Set("content-type", contentType)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+// accessTestError returns the JSON-RPC error code of a response, 0 if it
+// succeeded.
+func accessTestError(t *testing.T, w *httptest.ResponseRecorder) int {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp jsonrpcMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil {
+		return resp.Error.Code
+	}
+	return 0
+}
+
+func TestAccessAuthorize(t *testing.T) {
+	_, access := newAccessTestServer(t, APIKey{Key: "secret", Name: "dapp", Origins: []string{"https://dapp.example"}})
+
+	tests := []struct {
+		url    string
+		header map[string]string
+		code   int
+	}{
+		{"http://localhost/", nil, http.StatusUnauthorized},
+		{"http://localhost/", map[string]string{apiKeyHeader: "wrong"}, http.StatusUnauthorized},
+		{"http://localhost/", map[string]string{apiKeyHeader: "secret"}, 0},
+		{"http://localhost/?apikey=secret", nil, 0},
+		{"http://localhost/", map[string]string{apiKeyHeader: "secret", "Origin": "https://DAPP.example"}, 0},
+		{"http://localhost/", map[string]string{apiKeyHeader: "secret", "Origin": "https://evil.example"}, http.StatusForbidden},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.url, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		key, code, err := access.authorize(r)
+		if code != tt.code {
+			t.Errorf("test %d: status %d (%v), want %d", i, code, err, tt.code)
+		}
+		if (key != nil) != (tt.code == 0) {
+			t.Errorf("test %d: key %v with status %d", i, key, code)
+		}
+	}
+}
+
+func TestAccessAdmit(t *testing.T) {
+	_, access := newAccessTestServer(t,
+		APIKey{Key: "limited", Name: "limited", Namespaces: []string{"test"}},
+		APIKey{Key: "open", Name: "open"},
+	)
+	authorize := func(key string) *accessKey {
+		r := httptest.NewRequest(http.MethodPost, "http://localhost/", nil)
+		r.Header.Set(apiKeyHeader, key)
+		k, _, err := access.authorize(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	limited, open := authorize("limited"), authorize("open")
+
+	tests := []struct {
+		key    *accessKey
+		method string
+		err    int
+	}{
+		{limited, "test_echo", 0},
+		{limited, "rpc_modules", 0}, // Always allowed
+		{limited, "other_echo", -32006},
+		{open, "other_echo", 0},
+	}
+	for i, tt := range tests {
+		err := tt.key.admit(&jsonrpcMessage{Method: tt.method}, false)
+		if code := errorCode(err); code != tt.err {
+			t.Errorf("test %d: %s error %v, want code %d", i, tt.method, err, tt.err)
+		}
+	}
+	requests := open.requests.Get()
+
+	// Revoked and replaced keys refuse the calls of the connections authorized
+	// with them.
+	if !access.RemoveKey("open") {
+		t.Fatal("key not removed")
+	}
+	if access.RemoveKey("open") {
+		t.Fatal("key removed twice")
+	}
+	if err := open.admit(&jsonrpcMessage{Method: "test_echo"}, false); errorCode(err) != -32006 {
+		t.Errorf("revoked key admitted: %v", err)
+	}
+	if err := access.AddKey(APIKey{Key: "limited", Name: "limited"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := limited.admit(&jsonrpcMessage{Method: "test_echo"}, false); errorCode(err) != -32006 {
+		t.Errorf("replaced key admitted: %v", err)
+	}
+	if err := authorize("limited").admit(&jsonrpcMessage{Method: "other_echo"}, false); err != nil {
+		t.Errorf("replacement key refused: %v", err)
+	}
+	if have := open.requests.Get(); have != requests {
+		t.Errorf("refused calls counted as requests: %d, want %d", have, requests)
+	}
+}
+
+func TestAccessRateLimit(t *testing.T) {
+	server, access := newAccessTestServer(t, APIKey{Key: "secret", Name: "ratelimited", RequestsPerSecond: 0.001, Burst: 2})
+	header := http.Header{apiKeyHeader: {"secret"}}
+	for i := 0; i < 2; i++ {
+		if code := accessTestError(t, serveAccessTest(server, "test_echo", header)); code != 0 {
+			t.Fatalf("request %d within burst failed with %d", i, code)
+		}
+	}
+	w := serveAccessTest(server, "test_echo", header)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	usage := access.Usage()
+	if len(usage) != 1 || usage[0].Requests < 2 || usage[0].Rejected < 1 {
+		t.Errorf("usage %+v, want 2 requests and 1 rejected", usage)
+	}
+
+	// Websocket connections take calls from the same bucket.
+	r := httptest.NewRequest(http.MethodPost, "http://localhost/", nil)
+	r.Header.Set(apiKeyHeader, "secret")
+	key, _, err := access.authorize(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.admit(&jsonrpcMessage{Method: "test_echo"}, true); errorCode(err) != -32005 {
+		t.Errorf("call over the limit admitted: %v", err)
+	}
+}
+
+func TestAccessHTTP(t *testing.T) {
+	server, _ := newAccessTestServer(t, APIKey{Key: "secret", Name: "dapp", Namespaces: []string{"test"}, Origins: []string{"https://dapp.example"}})
+
+	if w := serveAccessTest(server, "test_echo", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("call without key: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if code := accessTestError(t, serveAccessTest(server, "test_echo", http.Header{apiKeyHeader: {"secret"}})); code != 0 {
+		t.Errorf("call with key failed with %d", code)
+	}
+	if code := accessTestError(t, serveAccessTest(server, "other_echo", http.Header{apiKeyHeader: {"secret"}})); code != -32006 {
+		t.Errorf("call outside the namespaces of the key: code %d, want -32006", code)
+	}
+	w := serveAccessTest(server, "test_echo", http.Header{apiKeyHeader: {"secret"}, "Origin": {"https://dapp.example"}})
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://dapp.example" {
+		t.Errorf("allowed origin answered with %q", origin)
+	}
+}
+
+func TestAccessPreflight(t *testing.T) {
+	server, _ := newAccessTestServer(t, APIKey{Key: "secret", Name: "dapp", Origins: []string{"https://dapp.example"}})
+
+	// Browsers send preflights without the API key.
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "http://localhost/", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		r.Header.Set("Access-Control-Request-Headers", apiKeyHeader)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return w
+	}
+	w := preflight("https://dapp.example")
+	if w.Code != http.StatusOK {
+		t.Fatalf("preflight: status %d, want %d", w.Code, http.StatusOK)
+	}
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://dapp.example" {
+		t.Errorf("preflight answered with origin %q", origin)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("preflight answered with a body: %s", w.Body)
+	}
+	w = preflight("https://evil.example")
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("preflight of an unknown origin answered with origin %q", origin)
+	}
+}
+
+func TestSanitizeParams(t *testing.T) {
+	tests := []struct {
+		method, params, want string
+	}{
+		{"eth_getBalance", `["0x01","latest"]`, `["0x01","latest"]`},
+		{"personal_unlockAccount", `["0x01","password"]`, "<redacted>"},
+		{"admin_addAPIKey", `[{"key":"secret","name":"dapp"}]`, "<redacted>"},
+		{"admin_removeAPIKey", `["secret"]`, "<redacted>"},
+		{"admin_apiKeys", `[]`, `[]`},
+		{"eth_call", `["` + strings.Repeat("a", maxLoggedParams) + `"]`, `["` + strings.Repeat("a", maxLoggedParams-2) + "..."},
+	}
+	for _, tt := range tests {
+		if have := sanitizeParams(&jsonrpcMessage{Method: tt.method, Params: json.RawMessage(tt.params)}); have != tt.want {
+			t.Errorf("%s: logged params %q, want %q", tt.method, have, tt.want)
+		}
+	}
+}
+
+// errorCode returns the code of an RPC error, 0 if there is none.
+func errorCode(err Error) int {
+	if err == nil {
+		return 0
+	}
+	return err.ErrorCode()
+}
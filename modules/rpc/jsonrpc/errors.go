@@ -16,7 +16,10 @@
 
 package jsonrpc
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type HTTPError struct {
 	StatusCode int
@@ -48,6 +51,10 @@ var (
 	_ Error = new(invalidRequestError)
 	_ Error = new(invalidMessageError)
 	_ Error = new(invalidParamsError)
+	_ Error = new(limitExceededError)
+	_ Error = new(timeoutError)
+	_ Error = new(responseTooLargeError)
+	_ Error = new(accessDeniedError)
 )
 
 const defaultErrorCode = -32000
@@ -91,3 +98,33 @@ type invalidParamsError struct{ message string }
 func (e *invalidParamsError) ErrorCode() int { return -32602 }
 
 func (e *invalidParamsError) Error() string { return e.message }
+
+// limitExceededError is returned when a client or method exceeds its request rate.
+type limitExceededError struct{ message string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
+
+type timeoutError struct{ timeout time.Duration }
+
+func (e *timeoutError) ErrorCode() int { return -32002 }
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("request timed out (timeout = %v)", e.timeout)
+}
+
+type responseTooLargeError struct{ limit int }
+
+func (e *responseTooLargeError) ErrorCode() int { return -32003 }
+
+func (e *responseTooLargeError) Error() string {
+	return fmt.Sprintf("response exceeds size limit (%d bytes)", e.limit)
+}
+
+// accessDeniedError is returned when the API key of a client doesn't allow a call.
+type accessDeniedError struct{ message string }
+
+func (e *accessDeniedError) ErrorCode() int { return -32006 }
+
+func (e *accessDeniedError) Error() string { return e.message }
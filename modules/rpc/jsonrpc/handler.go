@@ -19,10 +19,12 @@ package jsonrpc
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/amazechain/amc/log"
@@ -38,6 +40,9 @@ type handler struct {
 	cancelRoot     func()                // cancel function for rootCtx
 	conn           jsonWriter            // where responses will be sent
 	allowSubscribe bool
+	limits         *limiter   // request limits, nil if unlimited
+	limitClient    bool       // whether to apply the per-client rate limit
+	access         *accessKey // API key of the connection, nil without access control
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -51,7 +56,7 @@ type callProc struct {
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, limits *limiter) *handler {
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
 		reg:            reg,
@@ -61,6 +66,8 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 		rootCtx:        rootCtx,
 		cancelRoot:     cancelRoot,
 		allowSubscribe: true,
+		limits:         limits,
+		limitClient:    true,
 		serverSubs:     make(map[ID]*Subscription),
 		clientSubs:     make(map[string]*ClientSubscription),
 		log:            log.Root(),
@@ -68,6 +75,7 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
 	}
+	h.access, _ = connCtx.Value(accessKeyContextKey{}).(*accessKey)
 	h.unsubscribeCb = newCallback(reflect.Value{}, reflect.ValueOf(h.unsubscribe))
 	return h
 }
@@ -94,9 +102,21 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
+		var (
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			maxSize = h.limits.maxResponseSize()
+			size    int
+		)
 		for _, msg := range calls {
+			if maxSize > 0 && size > maxSize {
+				answers = append(answers, msg.errorResponse(&responseTooLargeError{maxSize}))
+				continue
+			}
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
+				size += len(answer.Result)
+				if maxSize > 0 && size > maxSize {
+					answer = msg.errorResponse(&responseTooLargeError{maxSize})
+				}
 				answers = append(answers, answer)
 			}
 		}
@@ -237,18 +257,22 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	switch {
 	//case msg.isNotification():
 	case msg.isCall():
-		h.log.Trace("begin "+msg.Method, "p", string(msg.Params))
+		params := sanitizeParams(msg)
+		h.log.Trace("begin "+msg.Method, "p", params)
 		resp := h.handleCall(ctx, msg)
+		elapsed := time.Since(start)
 		var ctx []interface{}
-		ctx = append(ctx, "reqid", idForLog{msg.ID}, "t", time.Since(start), "p", string(msg.Params), "r", string(resp.Result))
+		ctx = append(ctx, "reqid", idForLog{msg.ID}, "t", elapsed, "p", params)
 		if resp.Error != nil {
 			ctx = append(ctx, "err", resp.Error.Message)
 			if resp.Error.Data != nil {
 				ctx = append(ctx, "errdata", resp.Error.Data)
 			}
 			h.log.Warn("Served "+msg.Method, ctx...)
+		} else if threshold := time.Duration(slowRequestThreshold.Load()); threshold > 0 && elapsed >= threshold {
+			h.log.Warn("Served slow "+msg.Method, ctx...)
 		} else {
-			h.log.Trace("Served "+msg.Method, ctx...)
+			h.log.Trace("Served "+msg.Method, append(ctx, "r", string(resp.Result))...)
 		}
 		return resp
 	case msg.hasValidID():
@@ -259,6 +283,11 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 }
 
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if h.access != nil {
+		if err := h.access.admit(msg, h.limitClient); err != nil {
+			return msg.errorResponse(err)
+		}
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -271,21 +300,59 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if callb == nil {
 		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
 	}
+	if h.limitClient && !h.limits.allowClient(h.conn.remoteAddr()) {
+		return h.rejectCall(msg, callb, &limitExceededError{"request rate limit exceeded"})
+	}
+	if !h.limits.allowMethod(msg.Method) {
+		return h.rejectCall(msg, callb, &limitExceededError{fmt.Sprintf("rate limit exceeded for method %s", msg.Method)})
+	}
 	args, err := parsePositionalArguments(msg.Params, callb.argTypes)
 	if err != nil {
-		return msg.errorResponse(&invalidParamsError{err.Error()})
+		return h.rejectCall(msg, callb, &invalidParamsError{err.Error()})
+	}
+	ctx := cp.ctx
+	timeout := h.limits.executionTimeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	start := time.Now()
-	answer := h.runMethod(cp.ctx, msg, callb, args)
-
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
+	var metrics *methodMetrics
 	if callb != h.unsubscribeCb {
+		metrics = getMethodMetrics(msg.Method)
+		metrics.requests.Inc()
+		metrics.inflight.Inc()
+	}
+	start := time.Now()
+	answer := h.runMethod(ctx, msg, callb, args)
+	if answer.Error != nil && timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		answer = msg.errorResponse(&timeoutError{timeout})
+	}
+
+	if metrics != nil {
+		metrics.inflight.Dec()
+		metrics.duration.Observe(time.Since(start).Seconds())
 		rpcRequestGauge.Inc()
-		if answer != nil && answer.Error != nil {
+		if answer.Error != nil {
 			failedReqeustGauge.Inc()
+			rpcErrorCounter(msg.Method, answer.Error.Code).Inc()
 		}
-		newRPCServingTimerMS(msg.Method, answer == nil || answer.Error == nil).UpdateDuration(start)
+		newRPCServingTimerMS(msg.Method, answer.Error == nil).UpdateDuration(start)
+	}
+	return answer
+}
+
+// rejectCall answers a call to a registered method that is refused before
+// running, accounting for it in the method metrics.
+func (h *handler) rejectCall(msg *jsonrpcMessage, callb *callback, err error) *jsonrpcMessage {
+	answer := msg.errorResponse(err)
+	if callb != h.unsubscribeCb {
+		getMethodMetrics(msg.Method).requests.Inc()
+		rpcRequestGauge.Inc()
+		failedReqeustGauge.Inc()
+		rpcErrorCounter(msg.Method, answer.Error.Code).Inc()
 	}
 	return answer
 }
@@ -328,7 +395,11 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 	if err != nil {
 		return msg.errorResponse(err)
 	}
-	return msg.response(result)
+	resp := msg.response(result)
+	if max := h.limits.maxResponseSize(); max > 0 && len(resp.Result) > max {
+		return msg.errorResponse(&responseTooLargeError{max})
+	}
+	return resp
 }
 
 // unsubscribe is the callback function for all *_unsubscribe calls.
@@ -345,6 +416,39 @@ func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	return true, nil
 }
 
+// maxLoggedParams is the number of bytes of the call parameters included in
+// log messages.
+const maxLoggedParams = 256
+
+// slowRequestThreshold is the duration in nanoseconds above which successful
+// calls are logged as slow. Zero disables the slow request log.
+var slowRequestThreshold atomic.Int64
+
+// SetSlowRequestThreshold sets the duration above which served calls are
+// logged at warning level, zero disables logging of slow calls.
+func SetSlowRequestThreshold(threshold time.Duration) {
+	slowRequestThreshold.Store(int64(threshold))
+}
+
+// redactedMethods carry secrets outside of the personal namespace.
+var redactedMethods = map[string]bool{
+	"admin_addAPIKey":    true,
+	"admin_removeAPIKey": true,
+}
+
+// sanitizeParams renders the parameters of a call for logging. Parameters of
+// the personal namespace carry passwords and those of the API key methods the
+// keys, they are never logged. All others are truncated.
+func sanitizeParams(msg *jsonrpcMessage) string {
+	if msg.namespace() == "personal" || redactedMethods[msg.Method] {
+		return "<redacted>"
+	}
+	if len(msg.Params) > maxLoggedParams {
+		return string(msg.Params[:maxLoggedParams]) + "..."
+	}
+	return string(msg.Params)
+}
+
 type idForLog struct{ json.RawMessage }
 
 func (id idForLog) String() string {
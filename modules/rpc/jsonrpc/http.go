@@ -190,7 +190,31 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), code)
 		return
 	}
+	if !s.limits.allowClient(r.RemoteAddr) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
 	ctx := r.Context()
+	if s.access != nil {
+		if r.Method == http.MethodOptions {
+			s.access.preflight(w, r)
+			return
+		}
+		key, code, err := s.access.authorize(r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+		writeCORS(w, r)
+		if !key.allow() {
+			key.rejected.Inc()
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "API key rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		ctx = context.WithValue(ctx, accessKeyContextKey{}, key)
+	}
 	ctx = context.WithValue(ctx, "remote", r.RemoteAddr)
 	ctx = context.WithValue(ctx, "scheme", r.Proto)
 	ctx = context.WithValue(ctx, "local", r.Host)
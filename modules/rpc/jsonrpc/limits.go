@@ -0,0 +1,167 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxTrackedClients bounds the number of per-client buckets kept in memory.
+// Idle buckets are dropped once the limit is reached.
+const maxTrackedClients = 4096
+
+// Limits configures the resource caps a Server enforces on its clients. The
+// zero value of every field disables the corresponding limit.
+type Limits struct {
+	// RequestsPerSecond is the sustained request rate allowed for a single
+	// remote host. Burst is the number of requests it may issue at once and
+	// defaults to one second worth of requests.
+	RequestsPerSecond float64
+	Burst             int
+
+	// MethodLimits caps the rate of individual methods, shared by all clients.
+	MethodLimits map[string]float64
+
+	// ExecutionTimeout aborts method calls running longer than this.
+	ExecutionTimeout time.Duration
+
+	// MaxResponseSize is the largest response, or sum of responses in a
+	// batch, in bytes.
+	MaxResponseSize int
+}
+
+// tokenBucket is a minimal token bucket rate limiter.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: now}
+}
+
+// refill credits the tokens accumulated since the last update.
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// limiter tracks the request buckets of a server. A nil limiter allows
+// everything.
+type limiter struct {
+	cfg Limits
+
+	mu      sync.Mutex
+	clients map[string]*tokenBucket
+	methods map[string]*tokenBucket
+}
+
+func newLimiter(cfg Limits) *limiter {
+	l := &limiter{
+		cfg:     cfg,
+		clients: make(map[string]*tokenBucket),
+		methods: make(map[string]*tokenBucket),
+	}
+	now := time.Now()
+	for method, rate := range cfg.MethodLimits {
+		if rate > 0 {
+			l.methods[method] = newTokenBucket(rate, 0, now)
+		}
+	}
+	return l
+}
+
+// allowClient reports whether the remote host may issue another request.
+func (l *limiter) allowClient(remote string) bool {
+	if l == nil || l.cfg.RequestsPerSecond <= 0 {
+		return true
+	}
+	host := remote
+	if h, _, err := net.SplitHostPort(remote); err == nil {
+		host = h
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.clients[host]
+	if !ok {
+		if len(l.clients) >= maxTrackedClients {
+			l.pruneClients(now)
+		}
+		b = newTokenBucket(l.cfg.RequestsPerSecond, l.cfg.Burst, now)
+		l.clients[host] = b
+	}
+	return b.allow(now)
+}
+
+// pruneClients drops the buckets of clients that have been idle long enough
+// to refill completely. The caller must hold l.mu.
+func (l *limiter) pruneClients(now time.Time) {
+	for host, b := range l.clients {
+		if b.refill(now); b.tokens >= b.burst {
+			delete(l.clients, host)
+		}
+	}
+}
+
+// allowMethod reports whether the method may be invoked once more.
+func (l *limiter) allowMethod(method string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.methods[method]; ok {
+		return b.allow(time.Now())
+	}
+	return true
+}
+
+func (l *limiter) executionTimeout() time.Duration {
+	if l == nil {
+		return 0
+	}
+	return l.cfg.ExecutionTimeout
+}
+
+func (l *limiter) maxResponseSize() int {
+	if l == nil {
+		return 0
+	}
+	return l.cfg.MaxResponseSize
+}
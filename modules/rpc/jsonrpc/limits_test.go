@@ -0,0 +1,231 @@
+// Copyright 2022 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type limitsTestService struct{}
+
+func (limitsTestService) Echo(s string) string { return s }
+
+// Wait blocks until the call is cancelled.
+func (limitsTestService) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newLimitsTestServer(t *testing.T, limits Limits) *Server {
+	server := NewServer()
+	if err := server.RegisterName("test", limitsTestService{}); err != nil {
+		t.Fatal(err)
+	}
+	server.SetLimits(limits)
+	t.Cleanup(server.Stop)
+	return server
+}
+
+// postLimitsTest posts a request body to the server from the given address.
+func postLimitsTest(server *Server, remote, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader(body))
+	r.Header.Set("content-type", contentType)
+	r.RemoteAddr = remote
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(2, 3, now)
+	for i := 0; i < 3; i++ {
+		if !b.allow(now) {
+			t.Fatalf("request %d of the burst denied", i)
+		}
+	}
+	if b.allow(now) {
+		t.Fatal("request beyond the burst allowed")
+	}
+	// Two tokens a second come back, never more than the burst.
+	if !b.allow(now.Add(500*time.Millisecond)) || b.allow(now.Add(500*time.Millisecond)) {
+		t.Fatal("wrong refill after half a second")
+	}
+	later := now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		if !b.allow(later) {
+			t.Fatalf("request %d after refilling denied", i)
+		}
+	}
+	if b.allow(later) {
+		t.Fatal("refilled beyond the burst")
+	}
+
+	// The burst defaults to a second worth of requests, at least one.
+	if b := newTokenBucket(2.5, 0, now); b.burst != 3 {
+		t.Errorf("default burst %v at 2.5 requests a second, want 3", b.burst)
+	}
+	if b := newTokenBucket(0.1, 0, now); b.burst != 1 {
+		t.Errorf("default burst %v at 0.1 requests a second, want 1", b.burst)
+	}
+}
+
+func TestLimiterClients(t *testing.T) {
+	l := newLimiter(Limits{RequestsPerSecond: 0.001, Burst: 1})
+	if !l.allowClient("10.0.0.1:1000") {
+		t.Fatal("first request denied")
+	}
+	// The limit is per host, whatever the port.
+	if l.allowClient("10.0.0.1:2000") {
+		t.Error("request from another port of the same host allowed")
+	}
+	if !l.allowClient("10.0.0.2:1000") {
+		t.Error("request from another host denied")
+	}
+
+	// Without a rate, and for a nil limiter, everything is allowed.
+	var unlimited *limiter
+	for _, l := range []*limiter{newLimiter(Limits{}), unlimited} {
+		for i := 0; i < 10; i++ {
+			if !l.allowClient("10.0.0.1:1000") || !l.allowMethod("test_echo") {
+				t.Fatal("request denied without limits")
+			}
+		}
+	}
+}
+
+func TestLimiterPruneClients(t *testing.T) {
+	l := newLimiter(Limits{RequestsPerSecond: 1000, Burst: 1})
+	l.allowClient("10.0.0.1:1000")
+	time.Sleep(5 * time.Millisecond)
+	l.mu.Lock()
+	l.pruneClients(time.Now())
+	_, tracked := l.clients["10.0.0.1"]
+	l.mu.Unlock()
+	if tracked {
+		t.Error("refilled bucket of an idle client still tracked")
+	}
+
+	// Busy clients are kept.
+	l = newLimiter(Limits{RequestsPerSecond: 0.001, Burst: 1})
+	l.allowClient("10.0.0.1:1000")
+	l.mu.Lock()
+	l.pruneClients(time.Now())
+	_, tracked = l.clients["10.0.0.1"]
+	l.mu.Unlock()
+	if !tracked {
+		t.Error("bucket of a client at its limit dropped")
+	}
+}
+
+func TestServerClientRateLimit(t *testing.T) {
+	server := newLimitsTestServer(t, Limits{RequestsPerSecond: 0.001, Burst: 2})
+	body := `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["hi"]}`
+
+	for i := 0; i < 2; i++ {
+		if code := accessTestError(t, postLimitsTest(server, "10.0.0.1:1000", body)); code != 0 {
+			t.Fatalf("request %d failed with code %d", i, code)
+		}
+	}
+	w := postLimitsTest(server, "10.0.0.1:1000", body)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d beyond the limit, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After header on a rate limited request")
+	}
+	if code := accessTestError(t, postLimitsTest(server, "10.0.0.2:1000", body)); code != 0 {
+		t.Errorf("request from another host failed with code %d", code)
+	}
+}
+
+func TestServerMethodRateLimit(t *testing.T) {
+	server := newLimitsTestServer(t, Limits{MethodLimits: map[string]float64{"test_echo": 0.001}})
+	echo := `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["hi"]}`
+
+	if code := accessTestError(t, postLimitsTest(server, "10.0.0.1:1000", echo)); code != 0 {
+		t.Fatalf("first call failed with code %d", code)
+	}
+	// The method limit is shared by all clients.
+	if code := accessTestError(t, postLimitsTest(server, "10.0.0.2:1000", echo)); code != -32005 {
+		t.Fatalf("call beyond the method limit answered code %d, want -32005", code)
+	}
+	// Other methods are not limited.
+	for i := 0; i < 3; i++ {
+		missing := `{"jsonrpc":"2.0","id":1,"method":"test_missing","params":[]}`
+		if code := accessTestError(t, postLimitsTest(server, "10.0.0.1:1000", missing)); code != -32601 {
+			t.Fatalf("unlimited method answered code %d, want -32601", code)
+		}
+	}
+}
+
+func TestServerExecutionTimeout(t *testing.T) {
+	server := newLimitsTestServer(t, Limits{ExecutionTimeout: 50 * time.Millisecond})
+	body := `{"jsonrpc":"2.0","id":1,"method":"test_wait","params":[]}`
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- postLimitsTest(server, "10.0.0.1:1000", body) }()
+	select {
+	case w := <-done:
+		if code := accessTestError(t, w); code != -32002 {
+			t.Errorf("timed out call answered code %d, want -32002", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("call not aborted")
+	}
+}
+
+func TestServerMaxResponseSize(t *testing.T) {
+	server := newLimitsTestServer(t, Limits{MaxResponseSize: 32})
+	call := func(s string) string {
+		return `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["` + s + `"]}`
+	}
+	if code := accessTestError(t, postLimitsTest(server, "10.0.0.1:1000", call("short"))); code != 0 {
+		t.Fatalf("short response failed with code %d", code)
+	}
+	if code := accessTestError(t, postLimitsTest(server, "10.0.0.1:1000", call(strings.Repeat("x", 40)))); code != -32003 {
+		t.Fatalf("long response answered code %d, want -32003", code)
+	}
+
+	// The limit holds for the sum of the responses in a batch: the third
+	// one takes it over and the rest are not run.
+	batch := "[" + strings.Join([]string{call("0123456789"), call("0123456789"), call("0123456789"), call("x")}, ",") + "]"
+	w := postLimitsTest(server, "10.0.0.1:1000", batch)
+	var answers []jsonrpcMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &answers); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	want := []int{0, 0, -32003, -32003}
+	if len(answers) != len(want) {
+		t.Fatalf("%d answers, want %d", len(answers), len(want))
+	}
+	for i, answer := range answers {
+		code := 0
+		if answer.Error != nil {
+			code = answer.Error.Code
+		}
+		if code != want[i] {
+			t.Errorf("answer %d has code %d, want %d", i, code, want[i])
+		}
+	}
+}
@@ -2,7 +2,10 @@ package jsonrpc
 
 import (
 	"fmt"
+	"sync"
+
 	"github.com/amazechain/amc/internal/metrics/prometheus"
+	promclient "github.com/prometheus/client_golang/prometheus"
 )
 
 var (
@@ -29,3 +32,32 @@ func newRPCServingTimerMS(method string, valid bool) prometheus.Summary {
 
 	return prometheus.GetOrCreateSummary(label)
 }
+
+// methodMetrics is the set of per-method metrics of served calls. Metrics are
+// only created for registered methods, so clients cannot inflate the number
+// of series by calling random method names.
+type methodMetrics struct {
+	requests prometheus.Counter
+	inflight prometheus.Counter
+	duration promclient.Histogram
+}
+
+var rpcMethodMetrics sync.Map // method name -> *methodMetrics
+
+func getMethodMetrics(method string) *methodMetrics {
+	if m, ok := rpcMethodMetrics.Load(method); ok {
+		return m.(*methodMetrics)
+	}
+	m := &methodMetrics{
+		requests: prometheus.GetOrCreateCounter(fmt.Sprintf(`rpc_requests_total{method="%s"}`, method)),
+		inflight: prometheus.GetOrCreateCounter(fmt.Sprintf(`rpc_requests_inflight{method="%s"}`, method), true),
+		duration: prometheus.GetOrCreateHistogram(fmt.Sprintf(`rpc_request_duration_seconds{method="%s"}`, method)),
+	}
+	actual, _ := rpcMethodMetrics.LoadOrStore(method, m)
+	return actual.(*methodMetrics)
+}
+
+// rpcErrorCounter counts the failed calls of a method by JSON-RPC error code.
+func rpcErrorCounter(method string, code int) prometheus.Counter {
+	return prometheus.GetOrCreateCounter(fmt.Sprintf(`rpc_errors_total{method="%s",code="%d"}`, method, code))
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/amazechain/amc/log"
 	mapset "github.com/deckarep/golang-set"
 	"io"
+	"sort"
 	"sync/atomic"
 )
 
@@ -33,6 +34,8 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	limits   *limiter
+	access   *AccessControl
 }
 
 func NewServer() *Server {
@@ -46,6 +49,19 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// SetLimits configures the rate, execution time and response size limits
+// enforced on requests. It must be called before the server starts serving.
+func (s *Server) SetLimits(limits Limits) {
+	s.limits = newLimiter(limits)
+}
+
+// SetAccessControl restricts the HTTP and websocket clients of the server to
+// the API keys of the access control. It must be called before the server
+// starts serving.
+func (s *Server) SetAccessControl(access *AccessControl) {
+	s.access = access
+}
+
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	defer codec.close()
 
@@ -56,7 +72,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.limits)
 	<-codec.closed()
 	c.Close()
 }
@@ -66,8 +82,11 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services)
+	h := newHandler(ctx, codec, s.idgen, &s.services, s.limits)
 	h.allowSubscribe = false
+	// The per-client rate of HTTP requests is enforced by ServeHTTP, which
+	// can still answer with a proper status code.
+	h.limitClient = false
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()
@@ -108,3 +127,20 @@ func (s *RPCService) Modules() map[string]string {
 	}
 	return modules
 }
+
+// Methods returns the sorted method names of every registered namespace.
+func (s *RPCService) Methods() map[string][]string {
+	s.server.services.mu.Lock()
+	defer s.server.services.mu.Unlock()
+
+	methods := make(map[string][]string)
+	for name, svc := range s.server.services.services {
+		list := make([]string, 0, len(svc.callbacks))
+		for method := range svc.callbacks {
+			list = append(list, method)
+		}
+		sort.Strings(list)
+		methods[name] = list
+	}
+	return methods
+}
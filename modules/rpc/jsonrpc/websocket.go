@@ -55,12 +55,24 @@ func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 		CheckOrigin:     wsHandshakeValidator(allowedOrigins),
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var key *accessKey
+		if s.access != nil {
+			var (
+				code int
+				err  error
+			)
+			if key, code, err = s.access.authorize(r); err != nil {
+				http.Error(w, err.Error(), code)
+				return
+			}
+		}
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Debug("WebSocket upgrade failed", "err", err)
 			return
 		}
 		codec := newWebsocketCodec(conn, r.Host, r.Header)
+		codec.(*websocketCodec).access = key
 		s.ServeCodec(codec, 0)
 	})
 }
@@ -234,7 +246,8 @@ func wsClientHeaders(endpoint, origin string) (string, http.Header, error) {
 
 type websocketCodec struct {
 	*jsonCodec
-	conn *websocket.Conn
+	conn   *websocket.Conn
+	access *accessKey // API key the connection was opened with
 	//info PeerInfo
 
 	wg        sync.WaitGroup
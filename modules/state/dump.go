@@ -0,0 +1,111 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math"
+
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// DumpConfig selects what a state dump contains.
+type DumpConfig struct {
+	SkipCode    bool
+	SkipStorage bool
+	Start       []byte // first address to dump
+	Max         uint64 // maximum number of accounts, 0 for all
+}
+
+// DumpAccount represents an account in the state.
+type DumpAccount struct {
+	Balance  string                `json:"balance"`
+	Nonce    uint64                `json:"nonce"`
+	Root     hexutil.Bytes         `json:"root"`
+	CodeHash hexutil.Bytes         `json:"codeHash"`
+	Code     hexutil.Bytes         `json:"code,omitempty"`
+	Storage  map[types.Hash]string `json:"storage,omitempty"`
+	Address  *types.Address        `json:"address,omitempty"`
+}
+
+// Dump represents the full dump of a state.
+type Dump struct {
+	Root     string                        `json:"root"`
+	Accounts map[types.Address]DumpAccount `json:"accounts"`
+}
+
+// IteratorDump is a page of a state dump. Next is the address the next page
+// starts at, empty once the walk reached the last account.
+type IteratorDump struct {
+	Root     string                        `json:"root"`
+	Accounts map[types.Address]DumpAccount `json:"accounts"`
+	Next     hexutil.Bytes                 `json:"next,omitempty"`
+}
+
+// DumpState walks the accounts of the state as of the start of block blockNr
+// in address order. The flat state keeps no tries, so the account roots are
+// the ones stored with the accounts.
+func DumpState(tx kv.Tx, blockNr uint64, conf *DumpConfig) (*IteratorDump, error) {
+	if conf == nil {
+		conf = new(DumpConfig)
+	}
+	dump := &IteratorDump{Accounts: make(map[types.Address]DumpAccount)}
+	reader := NewPlainState(tx, blockNr)
+
+	err := WalkAsOfAccounts(tx, types.BytesToAddress(conf.Start), blockNr, func(k, v []byte) (bool, error) {
+		if conf.Max > 0 && uint64(len(dump.Accounts)) >= conf.Max {
+			dump.Next = types.CopyBytes(k)
+			return false, nil
+		}
+		var acc account.StateAccount
+		if err := acc.DecodeForStorage(v); err != nil {
+			return false, err
+		}
+		addr := types.BytesToAddress(k)
+		entry := DumpAccount{
+			Balance:  acc.Balance.ToBig().String(),
+			Nonce:    acc.Nonce,
+			Root:     types.CopyBytes(acc.Root[:]),
+			CodeHash: types.CopyBytes(acc.CodeHash[:]),
+			Address:  &addr,
+		}
+		if !conf.SkipCode {
+			var err error
+			if entry.Code, err = reader.ReadAccountCode(addr, acc.Incarnation, acc.CodeHash); err != nil {
+				return false, err
+			}
+		}
+		if !conf.SkipStorage {
+			entry.Storage = make(map[types.Hash]string)
+			if err := reader.ForEachStorage(addr, types.Hash{}, func(key, _ types.Hash, value uint256.Int) bool {
+				entry.Storage[key] = value.Hex()
+				return true
+			}, math.MaxInt32); err != nil {
+				return false, err
+			}
+		}
+		dump.Accounts[addr] = entry
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
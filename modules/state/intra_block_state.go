@@ -98,6 +98,11 @@ type IntraBlockState struct {
 	snap    *Snapshot
 	codeMap map[types.Hash][]byte
 	height  uint64
+
+	preimages map[types.Hash][]byte // keccak256 inputs, while recording
+
+	speculation *speculation // set on states executing a transaction ahead of its position
+	written     *AccessSet   // accounts and slots written by the finalized transactions, while tracked
 }
 
 // Create a new state from a given trie
@@ -122,6 +127,45 @@ func (sdb *IntraBlockState) BeginWriteCodes() {
 	sdb.codeMap = make(map[types.Hash][]byte, 0)
 }
 
+// BeginRecordPreimages keeps the inputs the EVM hashes from now on.
+func (sdb *IntraBlockState) BeginRecordPreimages() {
+	sdb.preimages = make(map[types.Hash][]byte)
+}
+
+// AddPreimage records the input of a keccak256 hash, if preimages are being
+// recorded. A preimage stays recorded when its call is reverted.
+func (sdb *IntraBlockState) AddPreimage(hash types.Hash, preimage []byte) {
+	if sdb.preimages == nil {
+		return
+	}
+	if _, ok := sdb.preimages[hash]; !ok {
+		sdb.preimages[hash] = types.CopyBytes(preimage)
+	}
+}
+
+// Preimages returns the recorded preimages, nil unless BeginRecordPreimages
+// was called. They cover the hashes computed by the EVM along with the hashes
+// of the addresses and storage keys the block touched, which the flat state
+// keeps unhashed.
+func (sdb *IntraBlockState) Preimages() map[types.Hash][]byte {
+	if sdb.preimages == nil {
+		return nil
+	}
+	preimages := make(map[types.Hash][]byte, len(sdb.preimages)+len(sdb.stateObjects))
+	for hash, preimage := range sdb.preimages {
+		preimages[hash] = preimage
+	}
+	for addr, so := range sdb.stateObjects {
+		preimages[crypto.Keccak256Hash(addr[:])] = types.CopyBytes(addr[:])
+		for _, storage := range []Storage{so.blockOriginStorage, so.dirtyStorage} {
+			for key := range storage {
+				preimages[crypto.Keccak256Hash(key[:])] = types.CopyBytes(key[:])
+			}
+		}
+	}
+	return preimages
+}
+
 func (sdb *IntraBlockState) CodeHashes() map[types.Hash][]byte {
 	// it should be called end of block execute
 	for addr, stateObject := range sdb.stateObjects {
@@ -822,6 +866,14 @@ func printAccount(addr types.Address, stateObject *stateObject, isDirty bool) {
 
 // FinalizeTx should be called after every transaction.
 func (sdb *IntraBlockState) FinalizeTx(chainRules *params.Rules, stateWriter StateWriter) error {
+	if sdb.written != nil {
+		sdb.journal.collectWrites(sdb.written)
+	}
+	if sdb.speculation != nil {
+		sdb.journal.collectWrites(sdb.speculation.writes)
+		sdb.SoftFinalise()
+		return nil
+	}
 	for addr, bi := range sdb.balanceInc {
 		if !bi.transferred {
 			sdb.getStateObject(addr)
@@ -1007,16 +1059,7 @@ func (s *IntraBlockState) GenerateRootHash() types.Hash {
 	sha.Reset()
 
 	for _, address := range sortAds {
-		stateObject := s.getStateObject(address).data
-		err := rlp.Encode(sha, []interface{}{
-			stateObject.Incarnation,
-			stateObject.Balance,
-			stateObject.Nonce,
-			stateObject.Initialised,
-			stateObject.CodeHash,
-			stateObject.Root,
-		})
-		if err != nil {
+		if err := hashAccount(sha, &s.getStateObject(address).data); err != nil {
 			panic("can't encode: " + err.Error())
 		}
 		//log.Info("GenerateRootHash", "address", address)
@@ -32,8 +32,8 @@ import (
 	"testing/quick"
 
 	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
 	"github.com/holiman/uint256"
-	"github.com/ledgerwatch/erigon-lib/kv/memdb"
 )
 
 func TestSnapshotRandom(t *testing.T) {
@@ -0,0 +1,134 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/changeset"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// PruneHistory deletes the account and storage changes of the blocks in
+// [from, to) and drops those blocks from the history indices. A lookup as of
+// a later block only ever reads the changes of the blocks after it, so the
+// state stays readable as of block to onwards.
+func PruneHistory(tx kv.RwTx, from, to uint64) error {
+	for _, table := range []string{modules.AccountChangeSet, modules.StorageChangeSet} {
+		keys, err := pruneChangeSets(tx, table, from, to)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := pruneIndex(tx, changeset.Mapper[table].IndexBucket, key, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pruneChangeSets deletes the changes of the blocks in [from, to) from a
+// changeset table and returns the index keys they touched.
+func pruneChangeSets(tx kv.RwTx, table string, from, to uint64) ([][]byte, error) {
+	c, err := tx.RwCursorDupSort(table)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var (
+		keys [][]byte
+		seen = make(map[string]struct{})
+	)
+	for k, v, err := c.Seek(modules.EncodeBlockNumber(from)); k != nil; k, v, err = c.NextNoDup() {
+		if err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint64(k) >= to {
+			break
+		}
+		for ; v != nil; _, v, err = c.NextDup() {
+			_, key, _, err := changeset.Mapper[table].Decode(k, v)
+			if err != nil {
+				return nil, err
+			}
+			key = modules.CompositeKeyWithoutIncarnation(key)
+			if _, ok := seen[string(key)]; !ok {
+				seen[string(key)] = struct{}{}
+				keys = append(keys, types.CopyBytes(key))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := c.DeleteCurrentDuplicates(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// pruneIndex removes the blocks below to from the history shards of a key.
+// Shards are keyed by their highest block, so the walk stops at the first one
+// reaching to.
+func pruneIndex(tx kv.RwTx, bucket string, key []byte, to uint64) error {
+	c, err := tx.RwCursor(bucket)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	buf := bytes.NewBuffer(nil)
+	for k, v, err := c.Seek(key); k != nil; k, v, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if len(k) != len(key)+8 || !bytes.HasPrefix(k, key) {
+			return nil
+		}
+		shard := binary.BigEndian.Uint64(k[len(key):])
+		index := roaring64.New()
+		if _, err := index.ReadFrom(bytes.NewReader(v)); err != nil {
+			return err
+		}
+		before := index.GetCardinality()
+		index.RemoveRange(0, to)
+		switch {
+		case index.IsEmpty():
+			if err := c.DeleteCurrent(); err != nil {
+				return err
+			}
+		case index.GetCardinality() != before:
+			buf.Reset()
+			if _, err := index.WriteTo(buf); err != nil {
+				return err
+			}
+			if err := c.Put(types.CopyBytes(k), types.CopyBytes(buf.Bytes())); err != nil {
+				return err
+			}
+		}
+		if shard >= to {
+			return nil
+		}
+	}
+	return nil
+}
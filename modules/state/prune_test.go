@@ -0,0 +1,123 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// readHistoryState reads the accounts and slots of the test blocks as of a
+// block through the history.
+func readHistoryState(t *testing.T, tx kv.Tx, blockNr uint64) []string {
+	s := NewPlainState(tx, blockNr)
+	var reads []string
+	for _, addr := range []types.Address{unwindAlice, unwindBob, unwindContract, unwindOther} {
+		acc, err := s.ReadAccountData(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reads = append(reads, fmt.Sprintf("%s: %+v", addr.Hex(), acc))
+		for incarnation := uint16(1); incarnation <= 2; incarnation++ {
+			for i := range unwindKeys {
+				value, err := s.ReadAccountStorage(addr, incarnation, &unwindKeys[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				reads = append(reads, fmt.Sprintf("%s/%d/%s: %x", addr.Hex(), incarnation, unwindKeys[i].Hex(), value))
+			}
+		}
+	}
+	return reads
+}
+
+func TestPruneHistory(t *testing.T) {
+	head := len(unwindTestBlocks) - 1
+	for to := 1; to <= head; to++ {
+		_, tx := memdb.NewTestTx(t)
+		executeUnwindTestBlocks(t, tx, 1, head)
+		want := make(map[uint64][]string)
+		for n := uint64(to); n <= uint64(head)+1; n++ {
+			want[n] = readHistoryState(t, tx, n)
+		}
+
+		if err := PruneHistory(tx, 0, uint64(to)); err != nil {
+			t.Fatalf("pruning below %d: %v", to, err)
+		}
+		// The state stays readable as of the blocks left.
+		for n := uint64(to); n <= uint64(head)+1; n++ {
+			if have := readHistoryState(t, tx, n); !reflect.DeepEqual(have, want[n]) {
+				t.Errorf("pruned below %d: state as of %d mismatch\nhave %v\nwant %v", to, n, have, want[n])
+			}
+		}
+		// Nothing is left of the pruned blocks.
+		for _, table := range []string{modules.AccountChangeSet, modules.StorageChangeSet} {
+			if err := tx.ForEach(table, nil, func(k, v []byte) error {
+				if number := binary.BigEndian.Uint64(k); number < uint64(to) {
+					t.Errorf("pruned below %d: %s holds block %d", to, table, number)
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, table := range []string{modules.AccountsHistory, modules.StorageHistory} {
+			if err := tx.ForEach(table, nil, func(k, v []byte) error {
+				index := roaring64.New()
+				if _, err := index.ReadFrom(bytes.NewReader(v)); err != nil {
+					return err
+				}
+				if index.IsEmpty() || index.Minimum() < uint64(to) {
+					t.Errorf("pruned below %d: %s %x holds %v", to, table, k, index.ToArray())
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestPruneHistoryInBatches(t *testing.T) {
+	head := len(unwindTestBlocks) - 1
+	_, want := memdb.NewTestTx(t)
+	executeUnwindTestBlocks(t, want, 1, head)
+	if err := PruneHistory(want, 0, uint64(head)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pruning batch after batch ends up with the same tables.
+	_, tx := memdb.NewTestTx(t)
+	executeUnwindTestBlocks(t, tx, 1, head)
+	for from := 0; from < head; from += 2 {
+		if err := PruneHistory(tx, uint64(from), uint64(from+2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have, expected := dumpUnwindTables(t, tx), dumpUnwindTables(t, want); !reflect.DeepEqual(have, expected) {
+		t.Errorf("state mismatch\nhave %v\nwant %v", have, expected)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/hash"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/avm/rlp"
+)
+
+// ErrUnsortedAccounts is returned by RootHasher for an account that doesn't
+// come after the previous one.
+var ErrUnsortedAccounts = errors.New("accounts not in ascending address order")
+
+// RootHasher computes the root GenerateRootHash returns for a set of accounts,
+// taking them one at a time in ascending address order instead of holding
+// them all in memory.
+type RootHasher struct {
+	sha   crypto.KeccakState
+	last  types.Address
+	count int
+}
+
+func NewRootHasher() *RootHasher {
+	return &RootHasher{sha: crypto.NewKeccakState()}
+}
+
+// Add hashes the next account.
+func (h *RootHasher) Add(addr types.Address, acc *account.StateAccount) error {
+	if h.count > 0 && bytes.Compare(addr[:], h.last[:]) <= 0 {
+		return fmt.Errorf("%w: %s after %s", ErrUnsortedAccounts, addr, h.last)
+	}
+	h.last = addr
+	h.count++
+	return hashAccount(h.sha, acc)
+}
+
+// Root returns the root of the accounts added so far.
+func (h *RootHasher) Root() types.Hash {
+	if h.count == 0 {
+		return hash.NilHash
+	}
+	var root types.Hash
+	h.sha.Read(root[:])
+	return root
+}
+
+// hashAccount writes the fields of an account that make up the state root.
+func hashAccount(w io.Writer, acc *account.StateAccount) error {
+	return rlp.Encode(w, []interface{}{
+		acc.Incarnation,
+		acc.Balance,
+		acc.Nonce,
+		acc.Initialised,
+		acc.CodeHash,
+		acc.Root,
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/types"
+	"github.com/holiman/uint256"
+)
+
+// NewScratch returns a state reading through sdb, the changes made to sdb
+// so far included. Whatever is done on the scratch state stays there, so
+// transactions can be tried out on it and dropped, sdb remains untouched.
+func NewScratch(sdb *IntraBlockState) *IntraBlockState {
+	return New(&scratchReader{sdb: sdb})
+}
+
+// scratchReader serves the current state of an IntraBlockState as a
+// StateReader.
+type scratchReader struct {
+	sdb *IntraBlockState
+}
+
+func (r *scratchReader) ReadAccountData(address types.Address) (*account.StateAccount, error) {
+	obj := r.sdb.getStateObject(address)
+	if obj == nil || obj.deleted {
+		return nil, r.sdb.Error()
+	}
+	acc := new(account.StateAccount)
+	acc.Copy(&obj.data)
+	return acc, r.sdb.Error()
+}
+
+func (r *scratchReader) ReadAccountStorage(address types.Address, incarnation uint16, key *types.Hash) ([]byte, error) {
+	var value uint256.Int
+	r.sdb.GetState(address, key, &value)
+	return value.Bytes(), r.sdb.Error()
+}
+
+func (r *scratchReader) ReadAccountCode(address types.Address, incarnation uint16, codeHash types.Hash) ([]byte, error) {
+	return r.sdb.GetCode(address), r.sdb.Error()
+}
+
+func (r *scratchReader) ReadAccountCodeSize(address types.Address, incarnation uint16, codeHash types.Hash) (int, error) {
+	return r.sdb.GetCodeSize(address), r.sdb.Error()
+}
+
+func (r *scratchReader) ReadAccountIncarnation(address types.Address) (uint16, error) {
+	// An account destructed earlier in the block has its incarnation in
+	// the state object, not yet in the database.
+	if obj := r.sdb.stateObjects[address]; obj != nil && obj.selfdestructed {
+		return obj.data.Incarnation, nil
+	}
+	return r.sdb.stateReader.ReadAccountIncarnation(address)
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+)
+
+// emptyReader is a StateReader over an empty state.
+type emptyReader struct{}
+
+func (emptyReader) ReadAccountData(types.Address) (*account.StateAccount, error) { return nil, nil }
+func (emptyReader) ReadAccountStorage(types.Address, uint16, *types.Hash) ([]byte, error) {
+	return nil, nil
+}
+func (emptyReader) ReadAccountCode(types.Address, uint16, types.Hash) ([]byte, error) {
+	return nil, nil
+}
+func (emptyReader) ReadAccountCodeSize(types.Address, uint16, types.Hash) (int, error) {
+	return 0, nil
+}
+func (emptyReader) ReadAccountIncarnation(types.Address) (uint16, error) { return 0, nil }
+
+func TestScratchState(t *testing.T) {
+	var (
+		rules    = &params.Rules{IsSpuriousDragon: true}
+		alice    = types.HexToAddress("0x01")
+		contract = types.HexToAddress("0x02")
+		key      = types.HexToHash("0x03")
+		code     = []byte{0x60, 0x00, 0x60, 0x00, 0xfd}
+	)
+	sdb := New(emptyReader{})
+	sdb.AddBalance(alice, uint256.NewInt(100))
+	sdb.CreateAccount(contract, true)
+	sdb.SetCode(contract, code)
+	sdb.SetState(contract, &key, *uint256.NewInt(7))
+	if err := sdb.FinalizeTx(rules, NewNoopWriter()); err != nil {
+		t.Fatal(err)
+	}
+
+	scratch := NewScratch(sdb)
+	if balance := scratch.GetBalance(alice); balance.Uint64() != 100 {
+		t.Fatalf("scratch balance = %v, want 100", balance)
+	}
+	if got := scratch.GetCode(contract); !bytes.Equal(got, code) {
+		t.Fatalf("scratch code = %x, want %x", got, code)
+	}
+	var value uint256.Int
+	scratch.GetState(contract, &key, &value)
+	if value.Uint64() != 7 {
+		t.Fatalf("scratch storage = %v, want 7", &value)
+	}
+
+	scratch.SubBalance(alice, uint256.NewInt(40))
+	scratch.SetState(contract, &key, *uint256.NewInt(8))
+	scratch.Selfdestruct(contract)
+	if err := scratch.FinalizeTx(rules, NewNoopWriter()); err != nil {
+		t.Fatal(err)
+	}
+	if balance := scratch.GetBalance(alice); balance.Uint64() != 60 {
+		t.Fatalf("scratch balance = %v, want 60", balance)
+	}
+	if balance := sdb.GetBalance(alice); balance.Uint64() != 100 {
+		t.Fatalf("balance changed by the scratch state: %v", balance)
+	}
+	sdb.GetState(contract, &key, &value)
+	if value.Uint64() != 7 || !sdb.Exist(contract) {
+		t.Fatalf("contract changed by the scratch state: exists %v, storage %v", sdb.Exist(contract), &value)
+	}
+
+	// A contract recreated on the scratch state takes the incarnation
+	// after the one destructed on the block state.
+	sdb.Selfdestruct(contract)
+	if err := sdb.FinalizeTx(rules, NewNoopWriter()); err != nil {
+		t.Fatal(err)
+	}
+	scratch = NewScratch(sdb)
+	if scratch.Exist(contract) {
+		t.Fatal("destructed contract exists on the scratch state")
+	}
+	scratch.CreateAccount(contract, true)
+	if inc := scratch.GetIncarnation(contract); inc != 2 {
+		t.Fatalf("recreated incarnation = %d, want 2", inc)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot keeps an in-memory snapshot of the flat state, so that
+// block execution doesn't go to the database for every account and storage
+// slot it reads.
+package snapshot
+
+import (
+	"math"
+	"sync"
+
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal/metrics/prometheus"
+	"github.com/amazechain/amc/modules"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+const (
+	// DefaultCacheSize is the memory allowance of the cache by default.
+	DefaultCacheSize = 256 * 1024 * 1024
+	// entryOverhead is roughly what the cache spends on an entry on top of
+	// its key and value.
+	entryOverhead = 96
+	// maxDiffLayers is the number of recent blocks whose writes are kept.
+	maxDiffLayers = 128
+)
+
+var (
+	snapshotHits   = prometheus.GetOrCreateCounter("state_snapshot_hits")
+	snapshotMisses = prometheus.GetOrCreateCounter("state_snapshot_misses")
+	snapshotResets = prometheus.GetOrCreateCounter("state_snapshot_resets")
+	snapshotEvicts = prometheus.GetOrCreateCounter("state_snapshot_evictions")
+	snapshotSize   = prometheus.GetOrCreateCounter("state_snapshot_size", true)
+)
+
+// cachedTables are the state tables served by the snapshot, with the prefix
+// of their cache keys.
+var cachedTables = map[string]byte{
+	modules.Account: 'a',
+	modules.Storage: 's',
+}
+
+// Tree is a snapshot of the flat state the database holds, made of a base
+// layer and a diff layer for each block executed on top of it.
+//
+// The base layer is the persistent flat state behind a cache of the entries
+// read lately. Once a block is committed, the database holds its state, so
+// its diff layer is flattened into the cache right away and the base moves
+// up to the block. The diff layers of the recent blocks are kept afterwards
+// to tell which entries changed since one of them.
+//
+// The snapshot only serves reads of the state the base is at. If the state
+// tables are written behind its back, as when a block is executed on a side
+// chain or a sync writes the state directly, the cache is dropped.
+type Tree struct {
+	lock   sync.RWMutex
+	base   types.Hash
+	cache  *lru.Cache[string, []byte]
+	size   int // memory taken by the cached entries
+	limit  int
+	layers []*diffLayer // oldest first, the last one is at the base
+}
+
+// diffLayer holds the keys of the state entries a block wrote.
+type diffLayer struct {
+	number   uint64
+	hash     types.Hash
+	accounts [][]byte
+	storage  [][]byte
+}
+
+// New returns a snapshot of the state at the given block, caching up to size
+// bytes of state entries.
+func New(base types.Hash, size int) *Tree {
+	// The cache is bounded by the memory of the entries, not their number.
+	cache, _ := lru.New[string, []byte](math.MaxInt32)
+	return &Tree{base: base, cache: cache, limit: size}
+}
+
+// SetCacheSize changes the memory allowance of the cache, evicting the least
+// recently used entries past it.
+func (t *Tree) SetCacheSize(size int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.limit = size
+	t.shrink()
+}
+
+// Reset drops the cache and the diff layers, the database being at the state
+// of the given block.
+func (t *Tree) Reset(base types.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.reset(base)
+}
+
+func (t *Tree) reset(base types.Hash) {
+	t.base = base
+	t.cache.Purge()
+	t.size = 0
+	snapshotSize.Set(0)
+	t.layers = nil
+	snapshotResets.Inc()
+}
+
+// Reader returns a getter reading the state tables through the snapshot if it
+// is at the given block. Otherwise, or for other tables, it reads db.
+func (t *Tree) Reader(db kv.Getter, hash types.Hash) kv.Getter {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.base != hash {
+		return db
+	}
+	return &reader{Getter: db, tree: t, base: hash}
+}
+
+// Recorder returns a state writer putting into db and recording the entries
+// written, for the diff layer of the block being committed.
+func (t *Tree) Recorder(db Writer) *Recorder {
+	return &Recorder{Writer: db, writes: make(map[string][]byte)}
+}
+
+// Update adds the diff layer of a committed block. A parent other than the
+// base means the state tables were at another block, the cache is then
+// dropped.
+func (t *Tree) Update(hash, parent types.Hash, number uint64, rec *Recorder) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.base != parent {
+		t.reset(hash)
+	} else {
+		for k, v := range rec.writes {
+			t.put(k, v)
+		}
+		t.shrink()
+		t.base = hash
+	}
+	layer := &diffLayer{number: number, hash: hash}
+	for k := range rec.writes {
+		switch k[0] {
+		case cachedTables[modules.Account]:
+			layer.accounts = append(layer.accounts, []byte(k[1:]))
+		case cachedTables[modules.Storage]:
+			layer.storage = append(layer.storage, []byte(k[1:]))
+		}
+	}
+	t.layers = append(t.layers, layer)
+	if len(t.layers) > maxDiffLayers {
+		t.layers[0] = nil
+		t.layers = t.layers[1:]
+	}
+}
+
+// Diff returns the keys of the accounts and storage slots written by a block
+// on the chain leading to the given head, false if the snapshot doesn't keep
+// that block or isn't on that chain.
+func (t *Tree) Diff(number uint64, head types.Hash, headNumber uint64) (accounts, storage [][]byte, ok bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for i := len(t.layers) - 1; i >= 0; i-- {
+		layer := t.layers[i]
+		if layer.number < headNumber {
+			return nil, nil, false
+		}
+		if layer.number > headNumber || layer.hash != head {
+			continue
+		}
+		// The layers are contiguous, each one is the parent of the next.
+		j := i - int(headNumber-number)
+		if number > headNumber || j < 0 || t.layers[j].number != number {
+			return nil, nil, false
+		}
+		return t.layers[j].accounts, t.layers[j].storage, true
+	}
+	return nil, nil, false
+}
+
+// get returns a cached entry of the state at base.
+func (t *Tree) get(base types.Hash, key string) ([]byte, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.base != base {
+		return nil, false
+	}
+	return t.cache.Get(key)
+}
+
+// add caches an entry read from the state at base.
+func (t *Tree) add(base types.Hash, key string, value []byte) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.base == base {
+		t.put(key, value)
+		t.shrink()
+	}
+}
+
+// put caches an entry, the lock being held.
+func (t *Tree) put(key string, value []byte) {
+	if old, ok := t.cache.Peek(key); ok {
+		t.size -= len(key) + len(old) + entryOverhead
+	}
+	t.cache.Add(key, value)
+	t.size += len(key) + len(value) + entryOverhead
+}
+
+// shrink evicts entries until the cache fits its allowance, the lock being
+// held.
+func (t *Tree) shrink() {
+	for t.size > t.limit {
+		key, value, ok := t.cache.RemoveOldest()
+		if !ok {
+			break
+		}
+		t.size -= len(key) + len(value) + entryOverhead
+		snapshotEvicts.Inc()
+	}
+	snapshotSize.Set(uint64(t.size))
+}
+
+func cacheKey(prefix byte, key []byte) string {
+	k := make([]byte, 1+len(key))
+	k[0] = prefix
+	copy(k[1:], key)
+	return string(k)
+}
+
+// reader reads the state tables through the snapshot.
+type reader struct {
+	kv.Getter
+	tree *Tree
+	base types.Hash
+}
+
+func (r *reader) GetOne(table string, key []byte) ([]byte, error) {
+	prefix, ok := cachedTables[table]
+	if !ok {
+		return r.Getter.GetOne(table, key)
+	}
+	k := cacheKey(prefix, key)
+	if v, ok := r.tree.get(r.base, k); ok {
+		snapshotHits.Inc()
+		return v, nil
+	}
+	snapshotMisses.Inc()
+	v, err := r.Getter.GetOne(table, key)
+	if err != nil {
+		return nil, err
+	}
+	r.tree.add(r.base, k, types.CopyBytes(v))
+	return v, nil
+}
+
+func (r *reader) Has(table string, key []byte) (bool, error) {
+	if _, ok := cachedTables[table]; !ok {
+		return r.Getter.Has(table, key)
+	}
+	v, err := r.GetOne(table, key)
+	return v != nil, err
+}
+
+// Writer is where the state of a block is written.
+type Writer interface {
+	kv.Putter
+	kv.Deleter
+}
+
+// Recorder writes the state of a block, recording the entries of the state
+// tables for its diff layer.
+type Recorder struct {
+	Writer
+	writes map[string][]byte // nil values are deleted entries
+}
+
+func (r *Recorder) Put(table string, k, v []byte) error {
+	if err := r.Writer.Put(table, k, v); err != nil {
+		return err
+	}
+	if prefix, ok := cachedTables[table]; ok {
+		r.writes[cacheKey(prefix, k)] = types.CopyBytes(v)
+	}
+	return nil
+}
+
+func (r *Recorder) Delete(table string, k []byte) error {
+	if err := r.Writer.Delete(table, k); err != nil {
+		return err
+	}
+	if prefix, ok := cachedTables[table]; ok {
+		r.writes[cacheKey(prefix, k)] = nil
+	}
+	return nil
+}
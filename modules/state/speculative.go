@@ -0,0 +1,249 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/types"
+	"github.com/holiman/uint256"
+)
+
+// SlotKey identifies a storage slot of an account.
+type SlotKey struct {
+	Address types.Address
+	Key     types.Hash
+}
+
+// AccessSet is a set of accounts and storage slots, read or written by a
+// transaction. An account entry stands for its data and code, a slot entry
+// for a single storage item.
+type AccessSet struct {
+	accounts map[types.Address]struct{}
+	slots    map[SlotKey]struct{}
+}
+
+func NewAccessSet() *AccessSet {
+	return &AccessSet{
+		accounts: make(map[types.Address]struct{}),
+		slots:    make(map[SlotKey]struct{}),
+	}
+}
+
+func (s *AccessSet) addAccount(addr types.Address) {
+	s.accounts[addr] = struct{}{}
+}
+
+func (s *AccessSet) addSlot(addr types.Address, key types.Hash) {
+	s.slots[SlotKey{Address: addr, Key: key}] = struct{}{}
+}
+
+// Merge adds the entries of o to the set.
+func (s *AccessSet) Merge(o *AccessSet) {
+	for addr := range o.accounts {
+		s.accounts[addr] = struct{}{}
+	}
+	for slot := range o.slots {
+		s.slots[slot] = struct{}{}
+	}
+}
+
+// Intersects reports whether the sets have an account or a slot in common.
+func (s *AccessSet) Intersects(o *AccessSet) bool {
+	a, b := s, o
+	if len(a.accounts)+len(a.slots) > len(b.accounts)+len(b.slots) {
+		a, b = b, a
+	}
+	for addr := range a.accounts {
+		if _, ok := b.accounts[addr]; ok {
+			return true
+		}
+	}
+	for slot := range a.slots {
+		if _, ok := b.slots[slot]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of accounts and slots in the set.
+func (s *AccessSet) Len() int {
+	return len(s.accounts) + len(s.slots)
+}
+
+// collectWrites adds what the journalled changes write to the set. Storage
+// changes only write their slot, every other change to an account, including
+// touches and blind balance increases, writes the account.
+func (j *journal) collectWrites(set *AccessSet) {
+	for _, entry := range j.entries {
+		switch ch := entry.(type) {
+		case storageChange:
+			set.addSlot(*ch.account, ch.key)
+		case fakeStorageChange:
+			set.addSlot(*ch.account, ch.key)
+		case balanceIncreaseTransfer:
+			// The increase itself was journalled when it was made.
+		default:
+			if addr := entry.dirtied(); addr != nil {
+				set.addAccount(*addr)
+			}
+		}
+	}
+}
+
+// recordingReader records the accounts and slots read through it.
+type recordingReader struct {
+	StateReader
+	reads *AccessSet
+}
+
+func (r *recordingReader) ReadAccountData(address types.Address) (*account.StateAccount, error) {
+	r.reads.addAccount(address)
+	return r.StateReader.ReadAccountData(address)
+}
+
+func (r *recordingReader) ReadAccountStorage(address types.Address, incarnation uint16, key *types.Hash) ([]byte, error) {
+	r.reads.addSlot(address, *key)
+	return r.StateReader.ReadAccountStorage(address, incarnation, key)
+}
+
+func (r *recordingReader) ReadAccountCode(address types.Address, incarnation uint16, codeHash types.Hash) ([]byte, error) {
+	r.reads.addAccount(address)
+	return r.StateReader.ReadAccountCode(address, incarnation, codeHash)
+}
+
+func (r *recordingReader) ReadAccountCodeSize(address types.Address, incarnation uint16, codeHash types.Hash) (int, error) {
+	r.reads.addAccount(address)
+	return r.StateReader.ReadAccountCodeSize(address, incarnation, codeHash)
+}
+
+func (r *recordingReader) ReadAccountIncarnation(address types.Address) (uint16, error) {
+	r.reads.addAccount(address)
+	return r.StateReader.ReadAccountIncarnation(address)
+}
+
+// speculation is what a speculative state keeps about its transaction.
+type speculation struct {
+	reads  *AccessSet
+	writes *AccessSet
+}
+
+// WriteSet holds the changes of a transaction executed speculatively, to be
+// applied on the block state at the transaction's position.
+type WriteSet struct {
+	keys      *AccessSet
+	objects   map[types.Address]*stateObject
+	increases map[types.Address]uint256.Int
+	logs      []*block.Log
+}
+
+// Keys returns the accounts and slots written.
+func (ws *WriteSet) Keys() *AccessSet {
+	return ws.keys
+}
+
+// NewSpeculative creates a state to execute a single transaction ahead of its
+// position in the block, on the state the reader gives. The state records
+// everything the transaction reads from the reader, and FinalizeTx keeps the
+// changes instead of writing them, for ReadSet and WriteSet.
+func NewSpeculative(stateReader StateReader) *IntraBlockState {
+	reads := NewAccessSet()
+	sdb := New(&recordingReader{StateReader: stateReader, reads: reads})
+	sdb.speculation = &speculation{reads: reads, writes: NewAccessSet()}
+	return sdb
+}
+
+// Speculatable reports whether the transactions of a block can be executed
+// speculatively and applied on the state. A state recording a snapshot, codes
+// or preimages, or traced, has to see every transaction itself.
+func (sdb *IntraBlockState) Speculatable() bool {
+	return sdb.snap == nil && sdb.codeMap == nil && sdb.preimages == nil && sdb.tracer == nil && sdb.speculation == nil
+}
+
+// TrackWrites makes FinalizeTx and ApplyWriteSet add the accounts and slots
+// written by every transaction to the set, until it is called with nil.
+func (sdb *IntraBlockState) TrackWrites(set *AccessSet) {
+	sdb.written = set
+}
+
+// ReadSet returns the accounts and slots a speculative state read.
+func (sdb *IntraBlockState) ReadSet() *AccessSet {
+	return sdb.speculation.reads
+}
+
+// WriteSet returns the changes of the transaction of a speculative state,
+// once it is finalized.
+func (sdb *IntraBlockState) WriteSet() *WriteSet {
+	ws := &WriteSet{
+		keys:      sdb.speculation.writes,
+		objects:   make(map[types.Address]*stateObject, len(sdb.stateObjectsDirty)),
+		increases: sdb.BalanceIncreaseSet(),
+		logs:      sdb.logs[sdb.thash],
+	}
+	for addr := range sdb.stateObjectsDirty {
+		ws.objects[addr] = sdb.stateObjects[addr]
+	}
+	return ws
+}
+
+// ApplyWriteSet applies the changes of a speculatively executed transaction,
+// as if it had been executed on this state. It is only correct if nothing the
+// transaction read was written since the state the speculation ran on; the
+// changes are then journalled for FinalizeTx like those of an executed one.
+func (sdb *IntraBlockState) ApplyWriteSet(ws *WriteSet) {
+	for addr, src := range ws.objects {
+		// Loading the account first transfers its pending increases.
+		dst := sdb.getStateObject(addr)
+		if dst == nil || src.created {
+			obj := newObject(sdb, addr, &src.data, &src.original)
+			obj.code, obj.dirtyCode = src.code, src.dirtyCode
+			obj.originStorage = src.originStorage.Copy()
+			obj.blockOriginStorage = src.blockOriginStorage.Copy()
+			obj.dirtyStorage = src.dirtyStorage.Copy()
+			obj.selfdestructed, obj.created = src.selfdestructed, src.created
+			sdb.stateObjects[addr] = obj
+		} else {
+			dst.data.Copy(&src.data)
+			if src.dirtyCode {
+				dst.code, dst.dirtyCode = src.code, true
+			}
+			for key, value := range src.dirtyStorage {
+				dst.dirtyStorage[key] = value
+				if _, ok := dst.blockOriginStorage[key]; !ok {
+					if original, ok := src.blockOriginStorage[key]; ok {
+						dst.blockOriginStorage[key] = original
+					}
+				}
+			}
+			if src.selfdestructed {
+				dst.selfdestructed, dst.created = true, false
+			}
+		}
+		sdb.journal.dirty(addr)
+	}
+	for addr, increase := range ws.increases {
+		increase := increase
+		sdb.AddBalance(addr, &increase)
+	}
+	for _, l := range ws.logs {
+		sdb.AddLog(l)
+	}
+	if sdb.written != nil {
+		sdb.written.Merge(ws.keys)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"encoding/binary"
+
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/changeset"
+	"github.com/amazechain/amc/modules/ethdb/bitmapdb"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+// UnwindState rolls the plain state back to the end of block to. Every key
+// changed after it gets the value recorded by its earliest change, then the
+// changes of the unwound blocks are dropped from the changesets and the
+// history indices. The changes must not have been pruned.
+func UnwindState(tx kv.RwTx, to uint64) error {
+	for _, table := range []string{modules.AccountChangeSet, modules.StorageChangeSet} {
+		var (
+			keys    [][]byte
+			changes = make(map[string][][]byte)
+		)
+		if err := changeset.ForEach(tx, table, modules.EncodeBlockNumber(to+1), func(_ uint64, k, v []byte) error {
+			if _, ok := changes[string(k)]; !ok {
+				keys = append(keys, types.CopyBytes(k))
+			}
+			changes[string(k)] = append(changes[string(k)], types.CopyBytes(v))
+			return nil
+		}); err != nil {
+			return err
+		}
+		indexed := make(map[string]struct{})
+		for _, key := range keys {
+			var err error
+			if table == modules.AccountChangeSet {
+				err = unwindAccount(tx, key, to, changes[string(key)])
+			} else {
+				err = unwindStorage(tx, key, changes[string(key)][0])
+			}
+			if err != nil {
+				return err
+			}
+			indexKey := modules.CompositeKeyWithoutIncarnation(key)
+			if _, ok := indexed[string(indexKey)]; ok {
+				continue
+			}
+			indexed[string(indexKey)] = struct{}{}
+			if err := bitmapdb.TruncateRange64(tx, changeset.Mapper[table].IndexBucket, indexKey, to+1); err != nil {
+				return err
+			}
+		}
+	}
+	return changeset.Truncate(tx, to+1)
+}
+
+// unwindAccount restores an account from its changeset values after block
+// to, oldest first. The changesets leave the hashes out: the code hash is
+// read back from the contract code of the incarnation, and the plain state
+// keeps no storage root. The code of the incarnations created after block to
+// is dropped, and so is the last deleted incarnation if it was deleted after.
+func unwindAccount(tx kv.RwTx, address []byte, to uint64, values [][]byte) error {
+	current, err := tx.GetOne(modules.Account, address)
+	if err != nil {
+		return err
+	}
+	deleted, err := readIncarnation(tx, address)
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		next := current
+		if i+1 < len(values) {
+			next = values[i+1]
+		}
+		if len(next) == 0 && accountIncarnation(value) > 0 {
+			if deleted, err = deletedIncarnationAt(tx, address, to, values[0]); err != nil {
+				return err
+			}
+			if err := writeIncarnation(tx, address, deleted); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	var acc account.StateAccount
+	if err := acc.DecodeForStorage(values[0]); err != nil {
+		return err
+	}
+	latest := acc.Incarnation
+	if deleted > latest {
+		latest = deleted
+	}
+	var codes [][]byte
+	if err := tx.ForPrefix(modules.PlainContractCode, address, func(k, _ []byte) error {
+		if binary.BigEndian.Uint16(k[types.AddressLength:]) > latest {
+			codes = append(codes, types.CopyBytes(k))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range codes {
+		if err := tx.Delete(modules.PlainContractCode, k); err != nil {
+			return err
+		}
+	}
+
+	if len(values[0]) == 0 {
+		return tx.Delete(modules.Account, address)
+	}
+	acc.Root = types.Hash{}
+	if acc.Incarnation > 0 && acc.IsEmptyCodeHash() {
+		codeHash, err := tx.GetOne(modules.PlainContractCode, modules.PlainGenerateStoragePrefix(address, acc.Incarnation))
+		if err != nil {
+			return err
+		}
+		if len(codeHash) > 0 {
+			acc.CodeHash.SetBytes(codeHash)
+		}
+	}
+	data := make([]byte, acc.EncodingLengthForStorage())
+	acc.EncodeForStorage(data)
+	return tx.Put(modules.Account, address, data)
+}
+
+// deletedIncarnationAt walks the account history back from block to, where
+// the account had the given value, to the last deletion of a contract
+// incarnation, the one the incarnation map held then.
+func deletedIncarnationAt(tx kv.Tx, address []byte, to uint64, value []byte) (uint16, error) {
+	index, err := bitmapdb.Get64(tx, modules.AccountsHistory, address, 0, to)
+	if err != nil {
+		return 0, err
+	}
+	c, err := tx.CursorDupSort(modules.AccountChangeSet)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	for it := index.ReverseIterator(); it.HasNext(); {
+		number := it.Next()
+		if number > to {
+			continue
+		}
+		original, err := changeset.FindAccount(c, number, address)
+		if err != nil {
+			return 0, err
+		}
+		if inc := accountIncarnation(original); len(value) == 0 && inc > 0 {
+			return inc, nil
+		}
+		value = types.CopyBytes(original)
+	}
+	return 0, nil
+}
+
+func accountIncarnation(value []byte) uint16 {
+	var acc account.StateAccount
+	if err := acc.DecodeForStorage(value); err != nil {
+		return 0
+	}
+	return acc.Incarnation
+}
+
+func readIncarnation(tx kv.Tx, address []byte) (uint16, error) {
+	b, err := tx.GetOne(modules.IncarnationMap, address)
+	if err != nil || len(b) == 0 {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func writeIncarnation(tx kv.RwTx, address []byte, incarnation uint16) error {
+	if incarnation == 0 {
+		return tx.Delete(modules.IncarnationMap, address)
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint16(b[:], incarnation)
+	return tx.Put(modules.IncarnationMap, address, b[:])
+}
+
+func unwindStorage(tx kv.RwTx, key, value []byte) error {
+	if len(value) == 0 {
+		return tx.Delete(modules.Storage, key)
+	}
+	return tx.Put(modules.Storage, key, value)
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/modules"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+var (
+	unwindAlice    = types.HexToAddress("0xa1")
+	unwindBob      = types.HexToAddress("0xb0")
+	unwindContract = types.HexToAddress("0xc1")
+	unwindOther    = types.HexToAddress("0xc2")
+	unwindKeys     = []types.Hash{types.HexToHash("0x01"), types.HexToHash("0x02")}
+)
+
+// unwindTestBlocks changes the state block after block: balances and
+// nonces, storage written and cleared, contracts created, destroyed and
+// created again at the same address.
+var unwindTestBlocks = []func(s *IntraBlockState){
+	1: func(s *IntraBlockState) {
+		s.AddBalance(unwindAlice, uint256.NewInt(1000))
+		s.AddBalance(unwindBob, uint256.NewInt(50))
+		s.CreateAccount(unwindContract, true)
+		s.SetCode(unwindContract, []byte{0x60, 0x01})
+		s.SetState(unwindContract, &unwindKeys[0], *uint256.NewInt(1))
+		s.SetState(unwindContract, &unwindKeys[1], *uint256.NewInt(2))
+	},
+	2: func(s *IntraBlockState) {
+		s.SubBalance(unwindAlice, uint256.NewInt(100))
+		s.SetNonce(unwindAlice, 1)
+		s.SetState(unwindContract, &unwindKeys[0], *uint256.NewInt(5))
+		s.SetState(unwindContract, &unwindKeys[1], uint256.Int{})
+	},
+	3: func(s *IntraBlockState) {
+		s.Selfdestruct(unwindContract)
+		s.CreateAccount(unwindOther, true)
+		s.SetCode(unwindOther, []byte{0x60, 0x02})
+		s.SetState(unwindOther, &unwindKeys[0], *uint256.NewInt(3))
+	},
+	4: func(s *IntraBlockState) {
+		s.CreateAccount(unwindContract, true)
+		s.SetCode(unwindContract, []byte{0x60, 0x03})
+		s.SetState(unwindContract, &unwindKeys[1], *uint256.NewInt(9))
+		s.SetNonce(unwindAlice, 2)
+	},
+	5: func(s *IntraBlockState) {
+		s.Selfdestruct(unwindBob)
+		s.SetState(unwindOther, &unwindKeys[0], *uint256.NewInt(4))
+		s.AddBalance(unwindOther, uint256.NewInt(7))
+	},
+	6: func(s *IntraBlockState) {
+		s.AddBalance(unwindBob, uint256.NewInt(1))
+		s.Selfdestruct(unwindContract)
+		s.Selfdestruct(unwindOther)
+	},
+}
+
+// executeUnwindTestBlocks applies the test blocks in the given range,
+// writing their changesets and history as the chain does.
+func executeUnwindTestBlocks(t *testing.T, tx kv.RwTx, from, to int) {
+	rules := &params.Rules{IsSpuriousDragon: true, IsByzantium: true}
+	for number := from; number <= to; number++ {
+		s := New(NewPlainStateReader(tx))
+		unwindTestBlocks[number](s)
+		w := NewPlainStateWriter(tx, tx, uint64(number))
+		if err := s.CommitBlock(rules, w); err != nil {
+			t.Fatalf("block %d: %v", number, err)
+		}
+		if err := w.WriteChangeSets(); err != nil {
+			t.Fatalf("block %d: %v", number, err)
+		}
+		if err := w.WriteHistory(); err != nil {
+			t.Fatalf("block %d: %v", number, err)
+		}
+	}
+}
+
+// unwindTables are the tables executing blocks writes, but for the code
+// table: codes are stored by hash and may outlive their contracts.
+var unwindTables = []string{
+	modules.Account,
+	modules.Storage,
+	modules.PlainContractCode,
+	modules.IncarnationMap,
+	modules.AccountChangeSet,
+	modules.StorageChangeSet,
+	modules.AccountsHistory,
+	modules.StorageHistory,
+}
+
+// dumpUnwindTables lists the rows of the tables. The history indices are
+// decoded, a bitmap has more than one encoding.
+func dumpUnwindTables(t *testing.T, tx kv.Tx) map[string][]string {
+	dump := make(map[string][]string)
+	for _, table := range unwindTables {
+		if err := tx.ForEach(table, nil, func(k, v []byte) error {
+			if table == modules.AccountsHistory || table == modules.StorageHistory {
+				index := roaring64.New()
+				if _, err := index.ReadFrom(bytes.NewReader(v)); err != nil {
+					return err
+				}
+				dump[table] = append(dump[table], fmt.Sprintf("%x: %v", k, index.ToArray()))
+				return nil
+			}
+			dump[table] = append(dump[table], fmt.Sprintf("%x: %x", k, v))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dump
+}
+
+func TestUnwindState(t *testing.T) {
+	head := len(unwindTestBlocks) - 1
+	for to := 0; to < head; to++ {
+		_, want := memdb.NewTestTx(t)
+		executeUnwindTestBlocks(t, want, 1, to)
+
+		_, tx := memdb.NewTestTx(t)
+		executeUnwindTestBlocks(t, tx, 1, head)
+		if err := UnwindState(tx, uint64(to)); err != nil {
+			t.Fatalf("unwinding to %d: %v", to, err)
+		}
+		have, expected := dumpUnwindTables(t, tx), dumpUnwindTables(t, want)
+		for _, table := range unwindTables {
+			if !reflect.DeepEqual(have[table], expected[table]) {
+				t.Errorf("unwinding from %d to %d: %s mismatch\nhave %v\nwant %v", head, to, table, have[table], expected[table])
+			}
+		}
+
+		// The unwound state carries on like the original one.
+		executeUnwindTestBlocks(t, want, to+1, head)
+		executeUnwindTestBlocks(t, tx, to+1, head)
+		if have, expected := dumpUnwindTables(t, tx), dumpUnwindTables(t, want); !reflect.DeepEqual(have, expected) {
+			t.Errorf("re-executing after unwinding to %d: state mismatch\nhave %v\nwant %v", to, have, expected)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/amazechain/amc/common/account"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/types"
+)
+
+// ErrMissingWitness is returned by a WitnessReader for state that isn't in
+// its witness.
+var ErrMissingWitness = errors.New("state missing from the witness")
+
+// Witness is everything the execution of a block read from the state of its
+// parent: the accounts, the storage slots, the codes and the hashes of the
+// ancestors asked for by BLOCKHASH. It is enough to execute the block again
+// without a database.
+//
+// The state is flat, so there are no trie nodes: the entries are the plain
+// state items themselves. Accounts are kept in their storage encoding, an
+// empty entry standing for an account that doesn't exist, and an empty slot
+// for a zero value.
+type Witness struct {
+	Accounts     map[types.Address]hexutil.Bytes                `json:"accounts"`
+	Incarnations map[types.Address]uint16                       `json:"incarnations"`
+	Storage      map[types.Address]map[types.Hash]hexutil.Bytes `json:"storage"`
+	Codes        map[types.Hash]hexutil.Bytes                   `json:"codes"`
+	BlockHashes  map[uint64]types.Hash                          `json:"blockHashes"`
+}
+
+func NewWitness() *Witness {
+	return &Witness{
+		Accounts:     make(map[types.Address]hexutil.Bytes),
+		Incarnations: make(map[types.Address]uint16),
+		Storage:      make(map[types.Address]map[types.Hash]hexutil.Bytes),
+		Codes:        make(map[types.Hash]hexutil.Bytes),
+		BlockHashes:  make(map[uint64]types.Hash),
+	}
+}
+
+var _ StateReader = (*WitnessRecorder)(nil)
+
+// WitnessRecorder is a StateReader recording into a witness everything read
+// through it. It isn't safe for concurrent use.
+type WitnessRecorder struct {
+	reader  StateReader
+	witness *Witness
+}
+
+func NewWitnessRecorder(reader StateReader) *WitnessRecorder {
+	return &WitnessRecorder{reader: reader, witness: NewWitness()}
+}
+
+// Witness returns the witness recorded so far.
+func (r *WitnessRecorder) Witness() *Witness {
+	return r.witness
+}
+
+// BlockHashes wraps a block hash function, recording the hashes it returns.
+func (r *WitnessRecorder) BlockHashes(getHash func(n uint64) types.Hash) func(n uint64) types.Hash {
+	return func(n uint64) types.Hash {
+		hash := getHash(n)
+		r.witness.BlockHashes[n] = hash
+		return hash
+	}
+}
+
+func (r *WitnessRecorder) ReadAccountData(address types.Address) (*account.StateAccount, error) {
+	acc, err := r.reader.ReadAccountData(address)
+	if err != nil {
+		return nil, err
+	}
+	var enc []byte
+	if acc != nil {
+		enc = make([]byte, acc.EncodingLengthForStorage())
+		acc.EncodeForStorage(enc)
+	}
+	r.witness.Accounts[address] = enc
+	return acc, nil
+}
+
+func (r *WitnessRecorder) ReadAccountStorage(address types.Address, incarnation uint16, key *types.Hash) ([]byte, error) {
+	enc, err := r.reader.ReadAccountStorage(address, incarnation, key)
+	if err != nil {
+		return nil, err
+	}
+	slots, ok := r.witness.Storage[address]
+	if !ok {
+		slots = make(map[types.Hash]hexutil.Bytes)
+		r.witness.Storage[address] = slots
+	}
+	slots[*key] = types.CopyBytes(enc)
+	return enc, nil
+}
+
+func (r *WitnessRecorder) ReadAccountCode(address types.Address, incarnation uint16, codeHash types.Hash) ([]byte, error) {
+	code, err := r.reader.ReadAccountCode(address, incarnation, codeHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) > 0 {
+		r.witness.Codes[codeHash] = types.CopyBytes(code)
+	}
+	return code, nil
+}
+
+// ReadAccountCodeSize reads the whole code, a stateless execution needs it to
+// know its size.
+func (r *WitnessRecorder) ReadAccountCodeSize(address types.Address, incarnation uint16, codeHash types.Hash) (int, error) {
+	code, err := r.ReadAccountCode(address, incarnation, codeHash)
+	return len(code), err
+}
+
+func (r *WitnessRecorder) ReadAccountIncarnation(address types.Address) (uint16, error) {
+	inc, err := r.reader.ReadAccountIncarnation(address)
+	if err != nil {
+		return 0, err
+	}
+	r.witness.Incarnations[address] = inc
+	return inc, nil
+}
+
+var _ StateReader = (*WitnessReader)(nil)
+
+// WitnessReader is a StateReader serving the state of a witness alone.
+//
+// Not every read error stops an execution, a missing incarnation or block
+// hash is read as zero, so the first missing entry is also kept and returned
+// by Err. Storage is looked up by address and slot only: within a block the
+// slots of a recreated contract are never read from the state.
+type WitnessReader struct {
+	witness *Witness
+	err     error
+}
+
+func NewWitnessReader(witness *Witness) *WitnessReader {
+	return &WitnessReader{witness: witness}
+}
+
+// Err returns the first entry that was asked for and isn't in the witness.
+func (r *WitnessReader) Err() error {
+	return r.err
+}
+
+func (r *WitnessReader) missing(format string, args ...interface{}) error {
+	err := fmt.Errorf("%w: %s", ErrMissingWitness, fmt.Sprintf(format, args...))
+	if r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+// BlockHash returns the hash of an ancestor, for the block context.
+func (r *WitnessReader) BlockHash(n uint64) types.Hash {
+	hash, ok := r.witness.BlockHashes[n]
+	if !ok {
+		r.missing("hash of block %d", n)
+	}
+	return hash
+}
+
+func (r *WitnessReader) ReadAccountData(address types.Address) (*account.StateAccount, error) {
+	enc, ok := r.witness.Accounts[address]
+	if !ok {
+		return nil, r.missing("account %s", address)
+	}
+	if len(enc) == 0 {
+		return nil, nil
+	}
+	var acc account.StateAccount
+	if err := acc.DecodeForStorage(enc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+func (r *WitnessReader) ReadAccountStorage(address types.Address, incarnation uint16, key *types.Hash) ([]byte, error) {
+	enc, ok := r.witness.Storage[address][*key]
+	if !ok {
+		return nil, r.missing("slot %s of %s", key, address)
+	}
+	if len(enc) == 0 {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+func (r *WitnessReader) ReadAccountCode(address types.Address, incarnation uint16, codeHash types.Hash) ([]byte, error) {
+	if account.IsEmptyCodeHash(codeHash) {
+		return nil, nil
+	}
+	code, ok := r.witness.Codes[codeHash]
+	if !ok {
+		return nil, r.missing("code %s of %s", codeHash, address)
+	}
+	return code, nil
+}
+
+func (r *WitnessReader) ReadAccountCodeSize(address types.Address, incarnation uint16, codeHash types.Hash) (int, error) {
+	code, err := r.ReadAccountCode(address, incarnation, codeHash)
+	return len(code), err
+}
+
+func (r *WitnessReader) ReadAccountIncarnation(address types.Address) (uint16, error) {
+	inc, ok := r.witness.Incarnations[address]
+	if !ok {
+		return 0, r.missing("incarnation of %s", address)
+	}
+	return inc, nil
+}
@@ -42,6 +42,9 @@ const (
 	Reward  = "Reward"  // ...
 	Deposit = "Deposit" // Deposit info
 
+	DepositLifecycle = "DepositLifecycle" // address -> validator lifecycle, 'a'/'e' + epoch_u64 -> queue size
+	DepositJournal   = "DepositJournal"   // "head" -> block_num_u64 + hash, 'e' + block_num_u64 + hash -> deposit events, 'u' + block_num_u64 -> hash + undo record
+
 	//key - addressHash+incarnation
 	//value - code hash
 	ContractCode = "HashedCodeHash"
@@ -73,6 +76,11 @@ const (
 
 	HeadHeaderKey = "LastHeader"
 
+	// SafeBlockKey and FinalizedBlockKey track the hashes of the latest
+	// justified and finalized consensus checkpoints.
+	SafeBlockKey      = "LastSafeBlock"
+	FinalizedBlockKey = "LastFinalizedBlock"
+
 	BlockBody       = "BlockBody"               // block_num_u64 + hash -> block body
 	BlockTx         = "BlockTransaction"        // tbl_sequence_u64 -> (tx)
 	NonCanonicalTxs = "NonCanonicalTransaction" // tbl_sequence_u64 -> rlp(tx)
@@ -85,8 +93,8 @@ const (
 	// Transaction senders - stored separately from the block bodies
 	Senders = "TxSender" // block_num_u64 + blockHash -> sendersList (no serialization format, every 20 bytes is new sender)
 
-	Receipts = "Receipt"        // block_num_u64 -> canonical block receipts (non-canonical are not stored)
-	Log      = "TransactionLog" // block_num_u64 + txId -> logs of transaction
+	Receipts = "Receipt"        // block_num_u64 -> canonical block receipts, compact (non-canonical are not stored)
+	Log      = "TransactionLog" // block_num_u64 + txId -> logs of transaction (no longer written, the receipts hold them)
 
 	// Stores bitmap indices - in which block numbers saw logs of given 'address' or 'topic'
 	// [addr or topic] + [2 bytes inverted shard number] -> bitmap(blockN)
@@ -119,6 +127,31 @@ const (
 	PoaSnapshot = "poaSnapshot"
 )
 
+// SnapSync keeps the progress of a state download and a copy of the genesis
+// state, restored when the node falls back to full sync.
+const SnapSync = "SnapSync" // "progress" -> pivot number_u64 + hash, table_id_u8 + key -> genesis value
+
+// TrustedHeaders is the header chain leading to the sync checkpoint, fetched
+// before the blocks so that they only need to match it.
+const TrustedHeaders = "TrustedHeaders" // number_u64 -> hash + parent hash
+
+// StatePrune records how far the state history has been pruned.
+const StatePrune = "StatePrune" // "history" -> first kept block number_u64
+
+// BadBlocks keeps the most recent blocks that failed validation, for inspection.
+const BadBlocks = "BadBlocks" // block_hash -> block + receipts + reason
+
+// Preimages maps the keccak256 hashes met while executing blocks back to
+// their inputs, when preimages are recorded.
+const Preimages = "Preimages" // hash -> preimage
+
+// BloomBits holds the header blooms of whole sections of the canonical chain
+// rotated into one bit vector per bloom bit, to match log filters quickly.
+const BloomBits = "BloomBits" // bit_u16 + section_u64 + section head hash -> compressed bit vector
+
+// BloomBitsIndex records the sections of the chain indexed into BloomBits.
+const BloomBitsIndex = "BloomBitsIndex" // section_u64 -> section head hash
+
 var AmcTables = []string{
 	Code,
 	Account,
@@ -141,6 +174,8 @@ var AmcTables = []string{
 
 	HeadBlockKey,
 	HeadHeaderKey,
+	SafeBlockKey,
+	FinalizedBlockKey,
 
 	BlockBody,
 	BlockTx,
@@ -153,9 +188,18 @@ var AmcTables = []string{
 	SignersDB,
 	PoaSnapshot,
 	Sequence,
+	SnapSync,
+	TrustedHeaders,
+	BadBlocks,
+	StatePrune,
+	Preimages,
+	BloomBits,
+	BloomBitsIndex,
 
 	Reward,
 	Deposit,
+	DepositLifecycle,
+	DepositJournal,
 	BlockVerify,
 	BlockRewards,
 }
@@ -0,0 +1,64 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/amazechain/amc/common/types"
+)
+
+// SyncCheckpoint is a canonical block trusted without verification. Sync
+// fetches the header chain leading to it first, and blocks on that chain only
+// need to match it instead of passing the consensus header checks.
+type SyncCheckpoint struct {
+	Number uint64     `json:"number"`
+	Hash   types.Hash `json:"hash"`
+}
+
+// syncCheckpoints are the default checkpoints of the known networks, keyed by
+// genesis hash. They are refreshed on releases.
+var syncCheckpoints = map[types.Hash]*SyncCheckpoint{}
+
+// SyncCheckpointByGenesisHash returns the default checkpoint of a network, nil
+// if there is none.
+func SyncCheckpointByGenesisHash(genesisHash types.Hash) *SyncCheckpoint {
+	return syncCheckpoints[genesisHash]
+}
+
+// ParseSyncCheckpoint parses a checkpoint given as "<number>:<hash>".
+func ParseSyncCheckpoint(s string) (*SyncCheckpoint, error) {
+	number, hash, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid checkpoint %q, want <number>:<hash>", s)
+	}
+	n, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || n == 0 {
+		return nil, fmt.Errorf("invalid checkpoint number %q", number)
+	}
+	var h types.Hash
+	if err := h.UnmarshalText([]byte(hash)); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint hash %q: %v", hash, err)
+	}
+	return &SyncCheckpoint{Number: n, Hash: h}, nil
+}
+
+func (c *SyncCheckpoint) String() string {
+	return fmt.Sprintf("%d:%s", c.Number, c.Hash)
+}
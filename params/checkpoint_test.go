@@ -0,0 +1,59 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+)
+
+func TestParseSyncCheckpoint(t *testing.T) {
+	hash := types.HexToHash("0x8b2a1d5c7e3f90a4b6c8d0e2f4163a5c7e9f1b3d5f7a9c1e3b5d7f9a1c3e5b7d")
+	tests := []struct {
+		input string
+		want  *SyncCheckpoint
+	}{
+		{"1000:" + hash.Hex(), &SyncCheckpoint{Number: 1000, Hash: hash}},
+		{"1000", nil},
+		{"0:" + hash.Hex(), nil},
+		{"-1:" + hash.Hex(), nil},
+		{"abc:" + hash.Hex(), nil},
+		{"1000:0x1234", nil},
+		{"1000:", nil},
+	}
+	for _, tt := range tests {
+		cp, err := ParseSyncCheckpoint(tt.input)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: parsed %v, want an error", tt.input, cp)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.input, err)
+			continue
+		}
+		if *cp != *tt.want {
+			t.Errorf("%q: parsed %v, want %v", tt.input, cp, tt.want)
+		}
+		// The flag value prints back as it was given.
+		if cp.String() != tt.input {
+			t.Errorf("%q: printed as %q", tt.input, cp.String())
+		}
+	}
+}
@@ -164,6 +164,10 @@ type ChainConfig struct {
 	Parlia *ParliaConfig `json:"parlia,omitempty" toml:",omitempty"`
 	Bor    *BorConfig    `json:"bor,omitempty"`
 	Apos   *APosConfig   `json:"apos,omitempty"`
+
+	// Rewards overrides the built-in block reward tiers and fee handling,
+	// letting private networks define their own economics.
+	Rewards *RewardConfig `json:"rewards,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -195,17 +199,181 @@ type APosConfig struct {
 	DepositContract     string `json:"depositContract"`     // Deposit contract
 	DepositNFTContract  string `json:"depositNFTContract"`  // Deposit NFT contract
 	DepositFUJIContract string `json:"depositFUJIContract"` // Deposit NFT contract
+
+	// EpochRotationBlock is the block from which passed votes only change the
+	// validator set at the next epoch boundary (nil = changes apply at once).
+	EpochRotationBlock *big.Int `json:"epochRotationBlock,omitempty"`
+
+	// SlashingBlock enables double-sign evidence in headers. Offenders lose
+	// up to SlashAmount of their balance, which must then be set, and are
+	// removed from the signer set.
+	SlashingBlock *big.Int `json:"slashingBlock,omitempty"`
+	SlashAmount   *big.Int `json:"slashAmount,omitempty"`
+
+	// ChurnLimit caps the validators activated, and separately exited, per
+	// epoch; further deposits and exits wait in a queue (0 = unlimited).
+	ChurnLimit uint64 `json:"churnLimit,omitempty"`
+}
+
+// Validate checks that slashing, if enabled, has a penalty a balance can pay.
+func (b *APosConfig) Validate() error {
+	if b.SlashingBlock == nil {
+		return nil
+	}
+	if b.SlashAmount == nil || b.SlashAmount.Sign() <= 0 {
+		return fmt.Errorf("slashing enabled at block %v without a positive slash amount", b.SlashingBlock)
+	}
+	if b.SlashAmount.BitLen() > 256 {
+		return fmt.Errorf("slash amount %v exceeds 256 bits", b.SlashAmount)
+	}
+	return nil
+}
+
+// IsSlashing returns whether double-sign evidence is accepted at the given block.
+func (b *APosConfig) IsSlashing(num uint64) bool {
+	return isForked(b.SlashingBlock, num)
+}
+
+// IsEpochRotation returns whether validator set changes are deferred to epoch
+// boundaries at the given block.
+func (b *APosConfig) IsEpochRotation(num uint64) bool {
+	return isForked(b.EpochRotationBlock, num)
 }
 
 // String implements the stringer interface, returning the consensus engine details.
 func (b *APosConfig) String() string {
-	return fmt.Sprintf("{DepositContract: %v, NFTDepositContract:%v, Period: %v, Epoch: %v, RewardEpoch: %v, RewardLimit: %v}",
+	return fmt.Sprintf("{DepositContract: %v, NFTDepositContract:%v, Period: %v, Epoch: %v, RewardEpoch: %v, RewardLimit: %v, EpochRotationBlock: %v, SlashingBlock: %v}",
 		b.DepositContract,
 		b.DepositNFTContract,
 		b.Period,
 		b.Epoch,
 		b.RewardEpoch,
 		b.RewardLimit,
+		b.EpochRotationBlock,
+		b.SlashingBlock,
+	)
+}
+
+// RewardConfig describes the block reward schedule and how transaction fees
+// are distributed. Percentages are whole numbers out of 100.
+type RewardConfig struct {
+	// BlockReward is paid to every verifier of a block, at most
+	// MaxEpochReward per verifier and reward epoch (nil = uncapped).
+	BlockReward    *big.Int `json:"blockReward"`
+	MaxEpochReward *big.Int `json:"maxEpochReward,omitempty"`
+
+	// Every ReductionInterval blocks the reward drops to ReductionPercent of
+	// its previous value, but never below MinBlockReward. A zero interval
+	// keeps the reward constant, a zero percent defaults to halving.
+	ReductionInterval uint64   `json:"reductionInterval,omitempty"`
+	ReductionPercent  uint64   `json:"reductionPercent,omitempty"`
+	MinBlockReward    *big.Int `json:"minBlockReward,omitempty"`
+
+	// Fees paid by transactions, base fee included, are split between the
+	// block proposer, the treasury and burning. When none is set the
+	// proposer earns the tips and the base fee is burnt.
+	FeeProposerPercent uint64         `json:"feeProposerPercent,omitempty"`
+	FeeTreasuryPercent uint64         `json:"feeTreasuryPercent,omitempty"`
+	FeeBurnPercent     uint64         `json:"feeBurnPercent,omitempty"`
+	Treasury           *types.Address `json:"treasury,omitempty"`
+}
+
+// Validate checks the reward schedule and fee split for consistency. A nil
+// config is valid and selects the built-in economics.
+func (c *RewardConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.BlockReward == nil || c.BlockReward.Sign() < 0 {
+		return fmt.Errorf("block reward must be set and not negative")
+	}
+	if c.MaxEpochReward != nil && c.MaxEpochReward.Cmp(c.BlockReward) < 0 {
+		return fmt.Errorf("max epoch reward %v below block reward %v", c.MaxEpochReward, c.BlockReward)
+	}
+	if c.ReductionPercent > 100 {
+		return fmt.Errorf("reduction percent %d above 100", c.ReductionPercent)
+	}
+	if c.ReductionPercent == 100 && c.ReductionInterval != 0 {
+		return fmt.Errorf("reduction percent of 100 never reduces the reward, set no reduction interval instead")
+	}
+	if c.MinBlockReward != nil && (c.MinBlockReward.Sign() < 0 || c.MinBlockReward.Cmp(c.BlockReward) > 0) {
+		return fmt.Errorf("min block reward %v outside [0, %v]", c.MinBlockReward, c.BlockReward)
+	}
+	if c.HasFeeSplit() {
+		if total := c.FeeProposerPercent + c.FeeTreasuryPercent + c.FeeBurnPercent; total != 100 {
+			return fmt.Errorf("fee split adds up to %d%%, want 100%%", total)
+		}
+		if c.FeeTreasuryPercent > 0 && (c.Treasury == nil || *c.Treasury == (types.Address{})) {
+			return fmt.Errorf("fee treasury share set without treasury address")
+		}
+	}
+	return nil
+}
+
+// HasFeeSplit reports whether fees are distributed by the configured shares.
+func (c *RewardConfig) HasFeeSplit() bool {
+	return c != nil && c.FeeProposerPercent+c.FeeTreasuryPercent+c.FeeBurnPercent > 0
+}
+
+// BlockRewardAt returns the per-verifier reward of the given block after the
+// reductions that took place until then. Each reduction rounds down, so they
+// are applied one at a time, but only until the reward reaches its floor or
+// stops changing: the steps are bounded by the size of the reward, not by
+// the block number.
+func (c *RewardConfig) BlockRewardAt(num uint64) *big.Int {
+	reward := new(big.Int).Set(c.BlockReward)
+	if c.ReductionInterval == 0 {
+		return reward
+	}
+	percent := c.ReductionPercent
+	if percent == 0 {
+		percent = 50
+	}
+	floor := c.MinBlockReward
+	if floor == nil {
+		floor = new(big.Int)
+	}
+	var (
+		factor  = new(big.Int).SetUint64(percent)
+		hundred = big.NewInt(100)
+		next    = new(big.Int)
+	)
+	for i := num / c.ReductionInterval; i > 0 && reward.Cmp(floor) > 0; i-- {
+		next.Mul(reward, factor)
+		next.Div(next, hundred)
+		if next.Cmp(reward) == 0 {
+			break
+		}
+		reward, next = next, reward
+	}
+	if reward.Cmp(floor) < 0 {
+		reward.Set(floor)
+	}
+	return reward
+}
+
+// SplitFee divides a fee into the proposer's and the treasury's shares, the
+// remainder is burnt.
+func (c *RewardConfig) SplitFee(fee *big.Int) (proposer, treasury *big.Int) {
+	proposer = new(big.Int).Mul(fee, new(big.Int).SetUint64(c.FeeProposerPercent))
+	proposer.Div(proposer, big.NewInt(100))
+	treasury = new(big.Int).Mul(fee, new(big.Int).SetUint64(c.FeeTreasuryPercent))
+	treasury.Div(treasury, big.NewInt(100))
+	return proposer, treasury
+}
+
+// String implements the stringer interface.
+func (c *RewardConfig) String() string {
+	return fmt.Sprintf("{BlockReward: %v, MaxEpochReward: %v, ReductionInterval: %v, ReductionPercent: %v, MinBlockReward: %v, FeeSplit: %d/%d/%d, Treasury: %v}",
+		c.BlockReward,
+		c.MaxEpochReward,
+		c.ReductionInterval,
+		c.ReductionPercent,
+		c.MinBlockReward,
+		c.FeeProposerPercent,
+		c.FeeTreasuryPercent,
+		c.FeeBurnPercent,
+		c.Treasury,
 	)
 }
 
@@ -385,6 +553,9 @@ func (c *ChainConfig) Description() string {
 	//if c.GrayGlacierBlock != nil {
 	//	banner += fmt.Sprintf(" - Gray Glacier:                #%-8v (https://github.com/ethereum/execution-specs/blob/master/network-upgrades/mainnet-upgrades/gray-glacier.md)\n", c.GrayGlacierBlock)
 	//}
+	if c.Rewards != nil {
+		banner += fmt.Sprintf("Rewards:   %v\n", c.Rewards)
+	}
 	banner += "\n"
 
 	// Add a special section for the merge as it's non-obvious
@@ -545,54 +716,62 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 	return lasterr
 }
 
+// CheckConsensus verifies that the selected consensus engine is supported and
+// configured, and that the reward settings are consistent.
+func (c *ChainConfig) CheckConsensus() error {
+	switch c.Consensus {
+	case CliqueConsensus:
+		if c.Clique == nil {
+			return fmt.Errorf("consensus %q selected without a clique section", c.Consensus)
+		}
+	case AposConsensu:
+		if c.Apos == nil {
+			return fmt.Errorf("consensus %q selected without an apos section", c.Consensus)
+		}
+		if err := c.Apos.Validate(); err != nil {
+			return fmt.Errorf("invalid apos config: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported consensus engine %q", c.Consensus)
+	}
+	if err := c.Rewards.Validate(); err != nil {
+		return fmt.Errorf("invalid reward config: %w", err)
+	}
+	return nil
+}
+
 // CheckConfigForkOrder checks that we don't "skip" any forks, geth isn't pluggable enough
-// to guarantee that forks can be implemented in a different order than on official networks
+// to guarantee that forks can be implemented in a different order than on official networks.
+// Forks scheduled by timestamp have to follow all those scheduled by block number.
 func (c *ChainConfig) CheckConfigForkOrder() error {
 	if c != nil && c.ChainID != nil && c.ChainID.Uint64() == 77 {
 		return nil
 	}
-	type fork struct {
-		name     string
-		block    *big.Int
-		optional bool // if true, the fork may be nil and next fork is still allowed
-	}
-	var lastFork fork
-	for _, cur := range []fork{
-		{name: "homesteadBlock", block: c.HomesteadBlock},
-		{name: "daoForkBlock", block: c.DAOForkBlock, optional: true},
-		{name: "eip150Block", block: c.TangerineWhistleBlock},
-		{name: "eip155Block", block: c.SpuriousDragonBlock},
-		{name: "byzantiumBlock", block: c.ByzantiumBlock},
-		{name: "constantinopleBlock", block: c.ConstantinopleBlock},
-		{name: "petersburgBlock", block: c.PetersburgBlock},
-		{name: "istanbulBlock", block: c.IstanbulBlock},
-		{name: "muirGlacierBlock", block: c.MuirGlacierBlock, optional: true},
-		//{name: "eulerBlock", block: c.EulerBlock, optional: true},
-		//{name: "gibbsBlock", block: c.GibbsBlock, optional: true},
-		{name: "berlinBlock", block: c.BerlinBlock},
-		{name: "londonBlock", block: c.LondonBlock},
-		{name: "arrowGlacierBlock", block: c.ArrowGlacierBlock, optional: true},
-		{name: "grayGlacierBlock", block: c.GrayGlacierBlock, optional: true},
-		{name: "mergeNetsplitBlock", block: c.MergeNetsplitBlock, optional: true},
-		{name: "shanghaiBlock", block: c.ShanghaiBlock},
-		{name: "cancunBlock", block: c.CancunBlock},
-	} {
-		if lastFork.name != "" {
-			// Next one must be higher number
-			if lastFork.block == nil && cur.block != nil {
+	var (
+		last   fork
+		lastAt *big.Int
+	)
+	for _, cur := range forks {
+		if cur.unordered {
+			continue
+		}
+		at := *cur.activation(c)
+		if last.name != "" && at != nil {
+			switch {
+			case lastAt == nil:
 				return fmt.Errorf("unsupported fork ordering: %v not enabled, but %v enabled at %v",
-					lastFork.name, cur.name, cur.block)
-			}
-			if lastFork.block != nil && cur.block != nil {
-				if lastFork.block.Cmp(cur.block) > 0 {
-					return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v",
-						lastFork.name, lastFork.block, cur.name, cur.block)
-				}
+					last.key, cur.key, at)
+			case last.timestamp && !cur.timestamp:
+				return fmt.Errorf("unsupported fork ordering: %v enabled at timestamp %v, but %v enabled at block %v",
+					last.key, lastAt, cur.key, at)
+			case last.timestamp == cur.timestamp && lastAt.Cmp(at) > 0:
+				return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v",
+					last.key, lastAt, cur.key, at)
 			}
-			// If it was optional and not set, then ignore it
 		}
-		if !cur.optional || cur.block != nil {
-			lastFork = cur
+		// If it was optional and not set, then ignore it
+		if !cur.optional || at != nil {
+			last, lastAt = cur, at
 		}
 	}
 	return nil
@@ -757,8 +936,9 @@ type Rules struct {
 	IsParlia, IsStarknet, IsAura, IsBeijing                 bool
 }
 
-// Rules ensures c's ChainID is not nil.
-func (c *ChainConfig) Rules(num uint64) *Rules {
+// Rules ensures c's ChainID is not nil. Forks scheduled by block number are
+// checked against num, those scheduled by timestamp against time.
+func (c *ChainConfig) Rules(num uint64, time uint64) *Rules {
 	chainID := c.ChainID
 	if chainID == nil {
 		chainID = new(big.Int)
@@ -776,7 +956,7 @@ func (c *ChainConfig) Rules(num uint64) *Rules {
 		IsLondon:              c.IsLondon(num),
 		IsShanghai:            c.IsShanghai(num),
 		IsCancun:              c.IsCancun(num),
-		IsPrague:              c.IsPrague(num),
+		IsPrague:              c.IsPrague(time),
 		IsNano:                c.IsNano(num),
 		IsMoran:               c.IsMoran(num),
 		IsEip1559FeeCollector: c.IsEip1559FeeCollector(num),
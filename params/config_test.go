@@ -0,0 +1,124 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/amazechain/amc/common/types"
+)
+
+func TestBlockRewardAt(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RewardConfig
+		num    uint64
+		want   int64
+	}{
+		{"constant", RewardConfig{BlockReward: big.NewInt(1000)}, 1 << 40, 1000},
+		{"before the first reduction", RewardConfig{BlockReward: big.NewInt(1000), ReductionInterval: 10}, 9, 1000},
+		{"halving by default", RewardConfig{BlockReward: big.NewInt(1000), ReductionInterval: 10}, 25, 250},
+		{"percent", RewardConfig{BlockReward: big.NewInt(1000), ReductionInterval: 10, ReductionPercent: 90}, 20, 810},
+		{"rounded down at every reduction", RewardConfig{BlockReward: big.NewInt(999), ReductionInterval: 1, ReductionPercent: 50}, 3, 124},
+		{"floor", RewardConfig{BlockReward: big.NewInt(1000), ReductionInterval: 10, MinBlockReward: big.NewInt(300)}, 25, 300},
+		{"down to zero", RewardConfig{BlockReward: big.NewInt(1000), ReductionInterval: 1}, 64, 0},
+		{"far block", RewardConfig{BlockReward: big.NewInt(1000), ReductionInterval: 1, ReductionPercent: 99, MinBlockReward: big.NewInt(1)}, math.MaxUint64, 1},
+		{"never changing", RewardConfig{BlockReward: big.NewInt(1000), ReductionInterval: 1, ReductionPercent: 100}, math.MaxUint64, 1000},
+	}
+	for _, tt := range tests {
+		if got := tt.config.BlockRewardAt(tt.num); got.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("%s: reward at %d = %v, want %d", tt.name, tt.num, got, tt.want)
+		}
+	}
+
+	// The reward of the config isn't touched.
+	config := RewardConfig{BlockReward: big.NewInt(1000), ReductionInterval: 1}
+	config.BlockRewardAt(5)
+	if config.BlockReward.Int64() != 1000 {
+		t.Fatalf("block reward changed to %v", config.BlockReward)
+	}
+}
+
+func TestSplitFee(t *testing.T) {
+	config := RewardConfig{FeeProposerPercent: 45, FeeTreasuryPercent: 35, FeeBurnPercent: 20}
+	proposer, treasury := config.SplitFee(big.NewInt(999))
+	if proposer.Int64() != 449 || treasury.Int64() != 349 {
+		t.Fatalf("split 999 into %v and %v, want 449 and 349", proposer, treasury)
+	}
+	config = RewardConfig{FeeProposerPercent: 100}
+	if proposer, treasury = config.SplitFee(big.NewInt(999)); proposer.Int64() != 999 || treasury.Sign() != 0 {
+		t.Fatalf("split 999 into %v and %v, want 999 and 0", proposer, treasury)
+	}
+}
+
+func TestRewardConfigValidate(t *testing.T) {
+	treasury := types.HexToAddress("0x01")
+	tests := []struct {
+		name   string
+		config *RewardConfig
+		valid  bool
+	}{
+		{"nil", nil, true},
+		{"no block reward", &RewardConfig{}, false},
+		{"negative block reward", &RewardConfig{BlockReward: big.NewInt(-1)}, false},
+		{"epoch cap below block reward", &RewardConfig{BlockReward: big.NewInt(10), MaxEpochReward: big.NewInt(9)}, false},
+		{"reduction above 100", &RewardConfig{BlockReward: big.NewInt(10), ReductionInterval: 1, ReductionPercent: 101}, false},
+		{"reduction of 100", &RewardConfig{BlockReward: big.NewInt(10), ReductionInterval: 1, ReductionPercent: 100}, false},
+		{"reduction of 100 without interval", &RewardConfig{BlockReward: big.NewInt(10), ReductionPercent: 100}, true},
+		{"floor above block reward", &RewardConfig{BlockReward: big.NewInt(10), MinBlockReward: big.NewInt(11)}, false},
+		{"split below 100", &RewardConfig{BlockReward: big.NewInt(10), FeeProposerPercent: 50, FeeBurnPercent: 40}, false},
+		{"treasury share without treasury", &RewardConfig{BlockReward: big.NewInt(10), FeeProposerPercent: 50, FeeTreasuryPercent: 50}, false},
+		{"full", &RewardConfig{
+			BlockReward:        big.NewInt(10),
+			MaxEpochReward:     big.NewInt(100),
+			ReductionInterval:  1000,
+			ReductionPercent:   90,
+			MinBlockReward:     big.NewInt(1),
+			FeeProposerPercent: 50,
+			FeeTreasuryPercent: 30,
+			FeeBurnPercent:     20,
+			Treasury:           &treasury,
+		}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v, valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestAPosConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config APosConfig
+		valid  bool
+	}{
+		{"no slashing", APosConfig{}, true},
+		{"no slash amount", APosConfig{SlashingBlock: big.NewInt(10)}, false},
+		{"zero slash amount", APosConfig{SlashingBlock: big.NewInt(10), SlashAmount: new(big.Int)}, false},
+		{"negative slash amount", APosConfig{SlashingBlock: big.NewInt(10), SlashAmount: big.NewInt(-1)}, false},
+		{"overflowing slash amount", APosConfig{SlashingBlock: big.NewInt(10), SlashAmount: new(big.Int).Lsh(big.NewInt(1), 256)}, false},
+		{"slash amount", APosConfig{SlashingBlock: big.NewInt(10), SlashAmount: big.NewInt(1e18)}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v, valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// fork is a hard fork of the chain config, activated at a block number or,
+// for the forks scheduled by timestamp, at the first block with that time.
+type fork struct {
+	name      string // name of the --override.<name> flag
+	key       string // JSON key of the activation point in the chain config
+	timestamp bool
+	// optional forks may be left out of the schedule while the later ones
+	// are enabled, unordered ones are checked against no other fork.
+	optional, unordered bool
+	activation          func(c *ChainConfig) **big.Int
+}
+
+// forks lists the hard forks in the order they must be scheduled in.
+var forks = []fork{
+	{name: "homestead", key: "homesteadBlock", activation: func(c *ChainConfig) **big.Int { return &c.HomesteadBlock }},
+	{name: "dao", key: "daoForkBlock", optional: true, activation: func(c *ChainConfig) **big.Int { return &c.DAOForkBlock }},
+	{name: "tangerinewhistle", key: "eip150Block", activation: func(c *ChainConfig) **big.Int { return &c.TangerineWhistleBlock }},
+	{name: "spuriousdragon", key: "eip155Block", activation: func(c *ChainConfig) **big.Int { return &c.SpuriousDragonBlock }},
+	{name: "byzantium", key: "byzantiumBlock", activation: func(c *ChainConfig) **big.Int { return &c.ByzantiumBlock }},
+	{name: "constantinople", key: "constantinopleBlock", activation: func(c *ChainConfig) **big.Int { return &c.ConstantinopleBlock }},
+	{name: "petersburg", key: "petersburgBlock", activation: func(c *ChainConfig) **big.Int { return &c.PetersburgBlock }},
+	{name: "istanbul", key: "istanbulBlock", activation: func(c *ChainConfig) **big.Int { return &c.IstanbulBlock }},
+	{name: "muirglacier", key: "muirGlacierBlock", optional: true, activation: func(c *ChainConfig) **big.Int { return &c.MuirGlacierBlock }},
+	{name: "berlin", key: "berlinBlock", activation: func(c *ChainConfig) **big.Int { return &c.BerlinBlock }},
+	{name: "london", key: "londonBlock", activation: func(c *ChainConfig) **big.Int { return &c.LondonBlock }},
+	{name: "arrowglacier", key: "arrowGlacierBlock", optional: true, activation: func(c *ChainConfig) **big.Int { return &c.ArrowGlacierBlock }},
+	{name: "grayglacier", key: "grayGlacierBlock", optional: true, activation: func(c *ChainConfig) **big.Int { return &c.GrayGlacierBlock }},
+	{name: "mergenetsplit", key: "mergeNetsplitBlock", optional: true, activation: func(c *ChainConfig) **big.Int { return &c.MergeNetsplitBlock }},
+	{name: "shanghai", key: "shanghaiBlock", activation: func(c *ChainConfig) **big.Int { return &c.ShanghaiBlock }},
+	{name: "cancun", key: "cancunTime", activation: func(c *ChainConfig) **big.Int { return &c.CancunBlock }},
+	{name: "prague", key: "pragueTime", timestamp: true, activation: func(c *ChainConfig) **big.Int { return &c.PragueTime }},
+
+	// The AmazeChain forks don't depend on the Ethereum ones.
+	{name: "nano", key: "nanoBlock", unordered: true, activation: func(c *ChainConfig) **big.Int { return &c.NanoBlock }},
+	{name: "moran", key: "moranBlock", unordered: true, activation: func(c *ChainConfig) **big.Int { return &c.MoranBlock }},
+	{name: "beijing", key: "beijingBlock", unordered: true, activation: func(c *ChainConfig) **big.Int { return &c.BeijingBlock }},
+}
+
+// ForkNames returns the names of the hard forks that can be rescheduled with
+// OverrideFork, in their activation order.
+func ForkNames() []string {
+	names := make([]string, len(forks))
+	for i, f := range forks {
+		names[i] = f.name
+	}
+	return names
+}
+
+// IsTimestampFork reports whether the named fork is scheduled by block time
+// rather than by block number.
+func IsTimestampFork(name string) bool {
+	for _, f := range forks {
+		if f.name == name {
+			return f.timestamp
+		}
+	}
+	return false
+}
+
+// OverrideFork reschedules the named fork at the given block number, or block
+// time for the forks scheduled by timestamp. It is meant for testing fork
+// transitions; the resulting schedule still has to pass CheckConfigForkOrder.
+func (c *ChainConfig) OverrideFork(name string, at uint64) error {
+	for _, f := range forks {
+		if f.name == name {
+			*f.activation(c) = new(big.Int).SetUint64(at)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown fork %q", name)
+}
+
+// ForkSchedule returns the distinct block numbers and block times at which
+// forks activate after the genesis, both in ascending order.
+func (c *ChainConfig) ForkSchedule() (blocks []uint64, times []uint64) {
+	seen := make(map[bool]map[uint64]bool, 2)
+	seen[false], seen[true] = make(map[uint64]bool), make(map[uint64]bool)
+	for _, f := range forks {
+		at := *f.activation(c)
+		if at == nil || at.Sign() == 0 || seen[f.timestamp][at.Uint64()] {
+			continue
+		}
+		seen[f.timestamp][at.Uint64()] = true
+		if f.timestamp {
+			times = append(times, at.Uint64())
+		} else {
+			blocks = append(blocks, at.Uint64())
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return blocks, times
+}
@@ -18,6 +18,9 @@ package params
 
 import (
 	"fmt"
+	"runtime"
+	"runtime/debug"
+
 	"github.com/amazechain/amc/modules"
 	"github.com/ledgerwatch/erigon-lib/kv"
 )
@@ -27,8 +30,26 @@ var (
 	GitCommit string
 	GitBranch string
 	GitTag    string
+	BuildDate string // UTC, formatted as yyyymmdd
 )
 
+// ClientName is the name the node identifies itself with.
+const ClientName = "amc"
+
+func init() {
+	// Binaries built with plain go build carry the commit in their build info.
+	if GitCommit != "" {
+		return
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				GitCommit = setting.Value
+			}
+		}
+	}
+}
+
 // see https://calver.org
 const (
 	VersionMajor       = 0  // Major version component of the current release
@@ -80,14 +101,25 @@ func ArchiveVersion(gitCommit string) string {
 	return vsn
 }
 
+// VersionWithCommit appends the short commit hash and the build date, when
+// known, to the version: "0.01.1-dea1ce05-20230611".
 func VersionWithCommit(gitCommit, gitDate string) string {
 	vsn := VersionWithMeta
 	if len(gitCommit) >= 8 {
 		vsn += "-" + gitCommit[:8]
 	}
+	if gitDate != "" {
+		vsn += "-" + gitDate
+	}
 	return vsn
 }
 
+// ClientVersion returns the identifier served by web3_clientVersion and
+// advertised to peers, e.g. "amc/v0.01.1-dea1ce05-20230611/linux-amd64/go1.20.4".
+func ClientVersion() string {
+	return fmt.Sprintf("%s/v%s/%s-%s/%s", ClientName, VersionWithCommit(GitCommit, BuildDate), runtime.GOOS, runtime.GOARCH, runtime.Version())
+}
+
 func SetAmcVersion(tx kv.RwTx, versionKey string) error {
 	versionKeyByte := []byte(versionKey)
 	hasVersion, err := tx.Has(modules.DatabaseInfo, versionKeyByte)
@@ -0,0 +1,415 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package testnode assembles an in-process amc node for integration tests and
+// applications that want to script a chain: the database lives in memory, the
+// network is a stub recording what the node broadcasts, and blocks are only
+// sealed when asked for. Block timestamps follow the parent by a fixed block
+// time rather than the wall clock, so the same genesis and transactions
+// always produce the same blocks.
+//
+// The events of the node go through the process wide event bus, nodes
+// running side by side in the same process see each other's events.
+package testnode
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/amazechain/amc/accounts"
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/block"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal"
+	"github.com/amazechain/amc/internal/api"
+	"github.com/amazechain/amc/internal/consensus"
+	"github.com/amazechain/amc/internal/consensus/apoa"
+	"github.com/amazechain/amc/internal/consensus/misc"
+	"github.com/amazechain/amc/internal/miner"
+	"github.com/amazechain/amc/internal/node"
+	"github.com/amazechain/amc/internal/txspool"
+	vm2 "github.com/amazechain/amc/internal/vm"
+	event "github.com/amazechain/amc/modules/event/v2"
+	"github.com/amazechain/amc/modules/rawdb"
+	"github.com/amazechain/amc/modules/rpc/jsonrpc"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+	"google.golang.org/protobuf/proto"
+)
+
+// GenesisTime is the timestamp of the default genesis.
+const GenesisTime = 1678174066
+
+var errNotClique = errors.New("the test node only seals Clique chains")
+
+// Config sets up a test node. The zero value runs a developer chain sealed
+// and funded by Key(0).
+type Config struct {
+	// Genesis is the chain to run, a Clique chain the signer seals. Nil runs
+	// the developer chain.
+	Genesis *conf.Genesis
+	// Alloc funds more accounts in the developer chain.
+	Alloc conf.GenesisAlloc
+	// SignerKey seals the blocks, Key(0) if nil.
+	SignerKey *ecdsa.PrivateKey
+	// BlockTime is the number of seconds a block follows its parent by, no
+	// less than the Clique period and one second.
+	BlockTime uint64
+	// GasCeil is the gas limit the blocks move towards, the genesis one if zero.
+	GasCeil uint64
+}
+
+// Key returns the i-th test key. The keys are derived from their index, the
+// same ones come back on every run.
+func Key(i int) *ecdsa.PrivateKey {
+	return crypto.ToECDSAUnsafe(crypto.Keccak256([]byte(fmt.Sprintf("amc testnode key %d", i))))
+}
+
+// Node is an in-process amc node producing blocks on demand.
+type Node struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	config  *params.ChainConfig
+	genesis *block.Block
+	db      kv.RwDB
+	chain   common.IBlockChain
+	engine  consensus.Engine
+	pool    common.ITxsPool
+	p2p     *mockP2P
+	api     *api.API
+	rpc     *jsonrpc.Server
+
+	key       *ecdsa.PrivateKey
+	signer    types.Address
+	signFn    apoa.SignerFn
+	blockTime uint64
+	gasCeil   uint64
+
+	lock sync.Mutex // serialises the block production
+}
+
+// New assembles and starts a test node.
+func New(cfg *Config) (*Node, error) {
+	if cfg == nil {
+		cfg = new(Config)
+	}
+	key := cfg.SignerKey
+	if key == nil {
+		key = Key(0)
+	}
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+
+	genesis := cfg.Genesis
+	if genesis == nil {
+		genesis = internal.DeveloperGenesisBlock(0, signer)
+		genesis.Timestamp = GenesisTime
+		for addr, account := range cfg.Alloc {
+			genesis.Alloc[addr] = account
+		}
+	}
+	if genesis.Config == nil || genesis.Config.Clique == nil {
+		return nil, errNotClique
+	}
+	if err := genesis.Config.CheckConfigForkOrder(); err != nil {
+		return nil, err
+	}
+	blockTime := cfg.BlockTime
+	if blockTime < genesis.Config.Clique.Period {
+		blockTime = genesis.Config.Clique.Period
+	}
+	if blockTime == 0 {
+		blockTime = 1
+	}
+	gasCeil := cfg.GasCeil
+	if gasCeil == 0 {
+		gasCeil = genesis.GasLimit
+	}
+
+	// Without a data directory the database is kept in memory.
+	db, err := node.OpenDatabase(&conf.Config{}, nil, kv.ChainDB.String())
+	if err != nil {
+		return nil, err
+	}
+	var genesisBlock *block.Block
+	if err := db.Update(context.Background(), func(tx kv.RwTx) (err error) {
+		genesisBlock, err = node.WriteGenesisBlock(tx, genesis)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	n := &Node{
+		config:    genesis.Config,
+		genesis:   genesisBlock,
+		db:        db,
+		key:       key,
+		signer:    signer,
+		blockTime: blockTime,
+		gasCeil:   gasCeil,
+		signFn: func(_ accounts.Account, _ string, message []byte) ([]byte, error) {
+			return crypto.Sign(crypto.Keccak256(message), key)
+		},
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+
+	n.p2p = newMockP2P(n.ctx)
+	n.engine = apoa.New(genesis.Config.Clique, db)
+	n.engine.(*apoa.Apoa).Authorize(signer, n.signFn)
+	if n.chain, err = internal.NewBlockChain(n.ctx, genesisBlock, n.engine, db, n.p2p, genesis.Config); err != nil {
+		n.Close()
+		return nil, err
+	}
+	if err := n.chain.Start(); err != nil {
+		n.Close()
+		return nil, err
+	}
+	if n.pool, err = txspool.NewTxsPool(n.ctx, n.chain, nil); err != nil {
+		n.Close()
+		return nil, err
+	}
+
+	n.api = api.NewAPI(n.chain, db, n.engine, n.pool, accounts.NewManager(&accounts.Config{}), genesis.Config)
+	n.api.SetGpo(api.NewOracle(n.chain, noMiner{}, genesis.Config, conf.FullNodeGPO))
+	n.rpc = jsonrpc.NewServer()
+	apis := append(n.api.Apis(), n.engine.APIs(n.chain)...)
+	for _, a := range apis {
+		if err := n.rpc.RegisterName(a.Namespace, a.Service); err != nil {
+			n.Close()
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// Close stops the node and drops its database.
+func (n *Node) Close() {
+	n.cancel()
+	if n.rpc != nil {
+		n.rpc.Stop()
+	}
+	if n.pool != nil {
+		n.pool.Stop()
+	}
+	if n.chain != nil {
+		n.chain.Close()
+	}
+	n.engine.Close()
+	n.db.Close()
+}
+
+// ChainConfig returns the configuration of the chain.
+func (n *Node) ChainConfig() *params.ChainConfig { return n.config }
+
+// Genesis returns the genesis block.
+func (n *Node) Genesis() *block.Block { return n.genesis }
+
+// Head returns the head of the chain.
+func (n *Node) Head() *block.Block { return n.chain.CurrentBlock().(*block.Block) }
+
+// BlockChain returns the chain of the node.
+func (n *Node) BlockChain() common.IBlockChain { return n.chain }
+
+// Database returns the in-memory database of the node.
+func (n *Node) Database() kv.RwDB { return n.db }
+
+// Engine returns the consensus engine sealing the blocks.
+func (n *Node) Engine() consensus.Engine { return n.engine }
+
+// TxsPool returns the transaction pool of the node.
+func (n *Node) TxsPool() common.ITxsPool { return n.pool }
+
+// Signer returns the address sealing the blocks.
+func (n *Node) Signer() types.Address { return n.signer }
+
+// Broadcasts returns the messages the node would have gossiped, oldest first.
+func (n *Node) Broadcasts() []proto.Message { return n.p2p.messages() }
+
+// Attach returns an in-process client of the RPC API of the node.
+func (n *Node) Attach() *jsonrpc.Client { return jsonrpc.DialInProc(n.rpc) }
+
+// Subscribe delivers the events of the channel's type to the channel, as
+// they are sent on the event bus.
+func (n *Node) Subscribe(channel interface{}) event.Subscription {
+	return event.GlobalEvent.Subscribe(channel)
+}
+
+// SignTx signs a transaction for the chain with the given key.
+func (n *Node) SignTx(key *ecdsa.PrivateKey, data transaction.TxData) (*transaction.Transaction, error) {
+	tx, err := transaction.SignNewTx(key, transaction.LatestSignerForChainID(n.config.ChainID), data)
+	if err != nil {
+		return nil, err
+	}
+	tx.SetFrom(crypto.PubkeyToAddress(key.PublicKey))
+	return tx, nil
+}
+
+// SendTx adds a transaction to the pool, for the next CommitPending.
+func (n *Node) SendTx(tx *transaction.Transaction) error {
+	return n.pool.AddLocal(tx)
+}
+
+// Commit seals a block on top of the head holding the given transactions, in
+// order, and makes it the new head. The block isn't sealed if one of the
+// transactions can't be included.
+func (n *Node) Commit(txs ...*transaction.Transaction) (*block.Block, error) {
+	return n.commit(txs, true)
+}
+
+// CommitPending seals a block with the executable transactions of the pool,
+// taken by sender address and nonce. The ones that can't be included are
+// left out.
+func (n *Node) CommitPending() (*block.Block, error) {
+	pending := n.pool.Pending(false)
+	senders := make([]types.Address, 0, len(pending))
+	for addr := range pending {
+		senders = append(senders, addr)
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return bytes.Compare(senders[i][:], senders[j][:]) < 0
+	})
+	var txs []*transaction.Transaction
+	for _, addr := range senders {
+		txs = append(txs, pending[addr]...)
+	}
+	return n.commit(txs, false)
+}
+
+// CommitBlocks seals count empty blocks.
+func (n *Node) CommitBlocks(count int) (*block.Block, error) {
+	var (
+		head *block.Block
+		err  error
+	)
+	for i := 0; i < count; i++ {
+		if head, err = n.commit(nil, true); err != nil {
+			return nil, err
+		}
+	}
+	return head, nil
+}
+
+// commit builds, seals and writes a block the way the miner does, then sends
+// the events of a sealed block.
+func (n *Node) commit(txs []*transaction.Transaction, strict bool) (*block.Block, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	parent := n.chain.CurrentBlock().Header().(*block.Header)
+	header := &block.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(uint256.Int).AddUint64(parent.Number, 1),
+		GasLimit:   miner.CalcGasLimit(parent.GasLimit, n.gasCeil),
+		Difficulty: uint256.NewInt(0),
+		BaseFee:    uint256.NewInt(0),
+	}
+	if n.config.IsLondon(header.Number.Uint64()) {
+		header.BaseFee, _ = uint256.FromBig(misc.CalcBaseFee(n.config, parent))
+		if !n.config.IsLondon(parent.Number.Uint64()) {
+			header.GasLimit = miner.CalcGasLimit(parent.GasLimit*params.ElasticityMultiplier, n.gasCeil)
+		}
+	}
+	if err := n.engine.Prepare(n.chain, header); err != nil {
+		return nil, err
+	}
+	// The engine stamps the wall clock, a test chain keeps its own.
+	header.Time = parent.Time + n.blockTime
+
+	tx, err := n.db.BeginRo(n.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		ibs      = state.New(state.NewPlainStateReader(tx))
+		noop     = state.NewNoopWriter()
+		gasPool  = new(common.GasPool).AddGas(header.GasLimit)
+		included []*transaction.Transaction
+		receipts []*block.Receipt
+	)
+	getHeader := func(hash types.Hash, number uint64) *block.Header {
+		return rawdb.ReadHeader(tx, hash, number)
+	}
+	for _, txn := range txs {
+		ibs.Prepare(txn.Hash(), types.Hash{}, len(included))
+		snap, gas := ibs.Snapshot(), gasPool.Gas()
+		receipt, _, err := internal.ApplyTransaction(n.config, internal.GetHashFn(header, getHeader), n.engine, &n.signer, gasPool, ibs, noop, header, txn, &header.GasUsed, vm2.Config{})
+		if err != nil {
+			if strict {
+				return nil, fmt.Errorf("transaction %v: %w", txn.Hash(), err)
+			}
+			ibs.RevertToSnapshot(snap)
+			gasPool = new(common.GasPool).AddGas(gas)
+			continue
+		}
+		included = append(included, txn)
+		receipts = append(receipts, receipt)
+	}
+
+	iblock, _, nopay, err := n.engine.FinalizeAndAssemble(n.chain, header, ibs, included, nil, receipts)
+	if err != nil {
+		return nil, err
+	}
+	sealed := iblock.Header().(*block.Header)
+	sig, err := n.signFn(accounts.Account{Address: n.signer}, accounts.MimetypeClique, apoa.ApoaProto(sealed))
+	if err != nil {
+		return nil, err
+	}
+	copy(sealed.Extra[len(sealed.Extra)-crypto.SignatureLength:], sig)
+	blk := iblock.WithSeal(sealed)
+
+	var logs []*block.Log
+	for i, receipt := range receipts {
+		receipt.BlockHash = blk.Hash()
+		receipt.BlockNumber = blk.Number64()
+		receipt.TransactionIndex = uint(i)
+		for _, l := range receipt.Logs {
+			l.BlockHash = blk.Hash()
+		}
+		logs = append(logs, receipt.Logs...)
+	}
+	if err := n.chain.WriteBlockWithState(blk, receipts, ibs, nopay); err != nil {
+		return nil, err
+	}
+	if len(logs) > 0 {
+		event.GlobalEvent.Send(common.NewLogsEvent{Logs: logs})
+	}
+	if err := n.chain.SealedBlock(blk); err != nil {
+		return nil, err
+	}
+	event.GlobalEvent.Send(common.ChainHighestBlock{Block: *blk, Inserted: true})
+	return blk, nil
+}
+
+// noMiner leaves the gas price oracle without a pending block, the test node
+// has none between two commits.
+type noMiner struct{}
+
+func (noMiner) Start() {}
+
+func (noMiner) PendingBlockAndReceipts() (block.IBlock, block.Receipts) { return nil, nil }
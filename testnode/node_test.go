@@ -0,0 +1,95 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package testnode
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+	"github.com/ledgerwatch/erigon-lib/kv"
+)
+
+func TestCommitTransfer(t *testing.T) {
+	n, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	to := crypto.PubkeyToAddress(Key(1).PublicKey)
+	tx, err := n.SignTx(Key(0), &transaction.LegacyTx{
+		GasPrice: uint256.NewInt(10 * params.GWei),
+		Gas:      params.TxGas,
+		To:       &to,
+		Value:    uint256.NewInt(1000),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := n.Commit(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blk.Number64().Uint64() != 1 || len(blk.Transactions()) != 1 {
+		t.Fatalf("block #%d with %d transactions, want #1 with 1", blk.Number64().Uint64(), len(blk.Transactions()))
+	}
+	if head := n.Head(); head.Hash() != blk.Hash() {
+		t.Fatalf("head %v, want %v", head.Hash(), blk.Hash())
+	}
+	if err := n.Database().View(context.Background(), func(tx kv.Tx) error {
+		if balance := n.BlockChain().StateAt(tx, 1).GetBalance(to); balance.Uint64() != 1000 {
+			t.Errorf("balance %v, want 1000", balance)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Broadcasts()) != 1 {
+		t.Errorf("%d broadcasts, want the sealed block", len(n.Broadcasts()))
+	}
+}
+
+func TestCommitDeterministic(t *testing.T) {
+	run := func() []types.Hash {
+		n, err := New(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer n.Close()
+
+		var hashes []types.Hash
+		for i := 0; i < 3; i++ {
+			blk, err := n.Commit()
+			if err != nil {
+				t.Fatal(err)
+			}
+			hashes = append(hashes, blk.Hash())
+		}
+		return hashes
+	}
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("block #%d: %v and %v", i+1, first[i], second[i])
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 The AmazeChain Authors
+// This file is part of the AmazeChain library.
+//
+// The AmazeChain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The AmazeChain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the AmazeChain library. If not, see <http://www.gnu.org/licenses/>.
+
+package testnode
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/amazechain/amc/api/protocol/sync_pb"
+	"github.com/amazechain/amc/conf"
+	"github.com/amazechain/amc/internal/p2p"
+	"github.com/amazechain/amc/internal/p2p/encoder"
+	"github.com/amazechain/amc/internal/p2p/enr"
+	"github.com/amazechain/amc/internal/p2p/peers"
+	"github.com/amazechain/amc/internal/p2p/peers/scorers"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"google.golang.org/protobuf/proto"
+)
+
+// errNoNetwork is returned by everything that would reach out to a peer.
+var errNoNetwork = errors.New("the test node has no network")
+
+// mockP2P stands in for the p2p service: the node has no peers, nothing is
+// dialled or accepted, and the broadcasts are recorded instead of gossiped.
+type mockP2P struct {
+	cfg   *conf.P2PConfig
+	peers *peers.Status
+
+	lock       sync.Mutex
+	broadcasts []proto.Message
+}
+
+func newMockP2P(ctx context.Context) *mockP2P {
+	return &mockP2P{
+		cfg: &conf.P2PConfig{P2PLimit: &conf.P2PLimit{}},
+		peers: peers.NewStatus(ctx, &peers.StatusConfig{
+			ScorerParams: &scorers.Config{},
+		}),
+	}
+}
+
+// messages returns the broadcast messages, oldest first.
+func (p *mockP2P) messages() []proto.Message {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]proto.Message(nil), p.broadcasts...)
+}
+
+func (p *mockP2P) Broadcast(_ context.Context, msg proto.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.broadcasts = append(p.broadcasts, proto.Clone(msg))
+	return nil
+}
+
+func (p *mockP2P) SetStreamHandler(string, network.StreamHandler) {}
+
+func (p *mockP2P) PubSub() *pubsub.PubSub { return nil }
+
+func (p *mockP2P) JoinTopic(string, ...pubsub.TopicOpt) (*pubsub.Topic, error) {
+	return nil, errNoNetwork
+}
+
+func (p *mockP2P) LeaveTopic(string) error { return nil }
+
+func (p *mockP2P) PublishToTopic(context.Context, string, []byte, ...pubsub.PubOpt) error {
+	return nil
+}
+
+func (p *mockP2P) SubscribeToTopic(string, ...pubsub.SubOpt) (*pubsub.Subscription, error) {
+	return nil, errNoNetwork
+}
+
+func (p *mockP2P) Encoding() encoder.NetworkEncoding { return &encoder.SszNetworkEncoder{} }
+
+func (p *mockP2P) Send(context.Context, interface{}, string, peer.ID) (network.Stream, error) {
+	return nil, errNoNetwork
+}
+
+func (p *mockP2P) Disconnect(peer.ID) error { return nil }
+
+func (p *mockP2P) PeerID() peer.ID { return "" }
+
+func (p *mockP2P) Host() host.Host { return nil }
+
+func (p *mockP2P) ENR() *enr.Record { return nil }
+
+func (p *mockP2P) DiscoveryAddresses() ([]multiaddr.Multiaddr, error) { return nil, nil }
+
+func (p *mockP2P) RefreshENR() {}
+
+func (p *mockP2P) AddPingMethod(func(ctx context.Context, id peer.ID) error) {}
+
+func (p *mockP2P) AddStaticPeer(peer.AddrInfo) {}
+
+func (p *mockP2P) RemoveStaticPeer(peer.ID) {}
+
+func (p *mockP2P) IsStatic(peer.ID) bool { return false }
+
+func (p *mockP2P) BanPeer(peer.ID, time.Duration) error { return nil }
+
+func (p *mockP2P) UnbanPeer(peer.ID) error { return nil }
+
+func (p *mockP2P) PeerTraffic(peer.ID) *p2p.PeerTraffic { return nil }
+
+func (p *mockP2P) AddConnectionHandler(_, _ func(ctx context.Context, id peer.ID) error) {}
+
+func (p *mockP2P) AddDisconnectionHandler(func(ctx context.Context, id peer.ID) error) {}
+
+func (p *mockP2P) InterceptPeerDial(peer.ID) bool { return false }
+
+func (p *mockP2P) InterceptAddrDial(peer.ID, multiaddr.Multiaddr) bool { return false }
+
+func (p *mockP2P) InterceptAccept(network.ConnMultiaddrs) bool { return false }
+
+func (p *mockP2P) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return false
+}
+
+func (p *mockP2P) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) { return false, 0 }
+
+func (p *mockP2P) Peers() *peers.Status { return p.peers }
+
+func (p *mockP2P) GetPing() *sync_pb.Ping { return &sync_pb.Ping{} }
+
+func (p *mockP2P) IncSeqNumber() {}
+
+func (p *mockP2P) Start() {}
+
+func (p *mockP2P) Stop() error { return nil }
+
+func (p *mockP2P) GetConfig() *conf.P2PConfig { return p.cfg }
+
+var _ p2p.P2P = (*mockP2P)(nil)
@@ -0,0 +1,101 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/amazechain/amc/params"
+)
+
+// forkSteps lists the forks named in the Ethereum test fixtures, in order,
+// with the hard forks each one enables on top of the previous ones.
+var forkSteps = []struct {
+	name  string
+	forks []string
+}{
+	{"Frontier", nil},
+	{"Homestead", []string{"homestead"}},
+	{"EIP150", []string{"tangerinewhistle"}},
+	{"EIP158", []string{"spuriousdragon"}},
+	{"Byzantium", []string{"byzantium"}},
+	{"Constantinople", []string{"constantinople"}},
+	{"ConstantinopleFix", []string{"petersburg"}},
+	{"Istanbul", []string{"istanbul"}},
+	{"MuirGlacier", []string{"muirglacier"}},
+	{"Berlin", []string{"berlin"}},
+	{"London", []string{"london"}},
+	{"ArrowGlacier", []string{"arrowglacier"}},
+	{"GrayGlacier", []string{"grayglacier"}},
+	{"Merge", []string{"mergenetsplit"}},
+	{"Shanghai", []string{"shanghai"}},
+	{"Cancun", []string{"cancun"}},
+}
+
+// Forks table defines supported forks and their chain config.
+var Forks = make(map[string]*params.ChainConfig)
+
+// mergeForks are the forks after the merge, whose blocks carry a random value
+// in place of the difficulty.
+var mergeForks = make(map[string]bool)
+
+func init() {
+	config := &params.ChainConfig{ChainID: big.NewInt(1)}
+	merged := false
+	for _, step := range forkSteps {
+		next := *config
+		for _, fork := range step.forks {
+			if err := next.OverrideFork(fork, 0); err != nil {
+				panic(err)
+			}
+		}
+		if step.name == "Merge" {
+			next.TerminalTotalDifficulty, next.TerminalTotalDifficultyPassed = new(big.Int), true
+			merged = true
+		}
+		config = &next
+		Forks[step.name] = config
+		mergeForks[step.name] = merged
+	}
+	// Constantinople was replaced by Petersburg before activating.
+	constantinople := *Forks["Constantinople"]
+	constantinople.PetersburgBlock = big.NewInt(10000000)
+	Forks["Constantinople"] = &constantinople
+
+	Forks["Paris"], mergeForks["Paris"] = Forks["Merge"], true
+}
+
+// AvailableForks returns the set of defined fork names
+func AvailableForks() []string {
+	var availableForks []string
+	for k := range Forks {
+		availableForks = append(availableForks, k)
+	}
+	sort.Strings(availableForks)
+	return availableForks
+}
+
+// UnsupportedForkError is returned when a test requests a fork that isn't implemented.
+type UnsupportedForkError struct {
+	Name string
+}
+
+func (e UnsupportedForkError) Error() string {
+	return fmt.Sprintf("unsupported fork %q", e.Name)
+}
@@ -0,0 +1,334 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/amazechain/amc/common"
+	"github.com/amazechain/amc/common/crypto"
+	"github.com/amazechain/amc/common/hexutil"
+	"github.com/amazechain/amc/common/math"
+	"github.com/amazechain/amc/common/transaction"
+	"github.com/amazechain/amc/common/types"
+	"github.com/amazechain/amc/internal"
+	"github.com/amazechain/amc/internal/vm"
+	"github.com/amazechain/amc/internal/vm/evmtypes"
+	"github.com/amazechain/amc/modules/ethdb/memdb"
+	"github.com/amazechain/amc/modules/state"
+	"github.com/amazechain/amc/params"
+	"github.com/holiman/uint256"
+)
+
+// StateTest checks transaction processing without block context.
+// See https://github.com/ethereum/EIPs/issues/176 for the test format specification.
+//
+// The post states of the fixtures are identified by the Ethereum state root,
+// which the flat state of amc doesn't compute: a test only checks whether the
+// transaction is rejected as expected, the traces are what gets compared across
+// clients.
+type StateTest struct {
+	json stJSON
+}
+
+// StateSubtest selects a specific configuration of a General State Test.
+type StateSubtest struct {
+	Fork  string
+	Index int
+}
+
+// StateResult is the outcome of a subtest.
+type StateResult struct {
+	Root    types.Hash // hash of the flat post state, not an Ethereum state root
+	GasUsed uint64
+	Output  []byte
+	Err     error // error of the EVM execution, if the transaction was applied
+}
+
+func (t *StateTest) UnmarshalJSON(in []byte) error {
+	return json.Unmarshal(in, &t.json)
+}
+
+type stJSON struct {
+	Env  stEnv                    `json:"env"`
+	Pre  map[types.Address]stAcct `json:"pre"`
+	Tx   stTransaction            `json:"transaction"`
+	Post map[string][]stPostState `json:"post"`
+}
+
+type stPostState struct {
+	Root            string `json:"hash"`
+	ExpectException string `json:"expectException"`
+	Indexes         struct {
+		Data  int `json:"data"`
+		Gas   int `json:"gas"`
+		Value int `json:"value"`
+	}
+}
+
+type stEnv struct {
+	Coinbase   types.Address         `json:"currentCoinbase"`
+	Difficulty *math.HexOrDecimal256 `json:"currentDifficulty"`
+	Random     *math.HexOrDecimal256 `json:"currentRandom"`
+	GasLimit   math.HexOrDecimal64   `json:"currentGasLimit"`
+	Number     math.HexOrDecimal64   `json:"currentNumber"`
+	Timestamp  math.HexOrDecimal64   `json:"currentTimestamp"`
+	BaseFee    *math.HexOrDecimal256 `json:"currentBaseFee"`
+}
+
+type stAcct struct {
+	Balance *math.HexOrDecimal256            `json:"balance"`
+	Code    hexutil.Bytes                    `json:"code"`
+	Nonce   math.HexOrDecimal64              `json:"nonce"`
+	Storage map[string]*math.HexOrDecimal256 `json:"storage"`
+}
+
+type stTransaction struct {
+	GasPrice             *math.HexOrDecimal256     `json:"gasPrice"`
+	MaxFeePerGas         *math.HexOrDecimal256     `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *math.HexOrDecimal256     `json:"maxPriorityFeePerGas"`
+	Nonce                math.HexOrDecimal64       `json:"nonce"`
+	To                   string                    `json:"to"`
+	Data                 []string                  `json:"data"`
+	AccessLists          []*transaction.AccessList `json:"accessLists,omitempty"`
+	GasLimit             []math.HexOrDecimal64     `json:"gasLimit"`
+	Value                []string                  `json:"value"`
+	PrivateKey           hexutil.Bytes             `json:"secretKey"`
+}
+
+// Subtests returns all valid subtests of the test.
+func (t *StateTest) Subtests() []StateSubtest {
+	var sub []StateSubtest
+	for fork, pss := range t.json.Post {
+		for i := range pss {
+			sub = append(sub, StateSubtest{fork, i})
+		}
+	}
+	return sub
+}
+
+// ExpectException returns the exception the subtest expects the transaction
+// to be rejected with, if any.
+func (t *StateTest) ExpectException(subtest StateSubtest) string {
+	return t.json.Post[subtest.Fork][subtest.Index].ExpectException
+}
+
+// Run executes a specific subtest and checks that the transaction is rejected
+// if and only if the fixture expects it.
+func (t *StateTest) Run(subtest StateSubtest, vmconfig vm.Config) (*StateResult, error) {
+	result, err := t.RunNoVerify(subtest, vmconfig)
+	expected := t.ExpectException(subtest)
+	switch {
+	case err != nil && expected == "":
+		return result, err
+	case err == nil && expected != "":
+		return result, fmt.Errorf("expected error %q, got no error", expected)
+	}
+	return result, nil
+}
+
+// RunNoVerify runs a specific subtest and returns the post state result, or
+// the error the transaction was rejected with.
+func (t *StateTest) RunNoVerify(subtest StateSubtest, vmconfig vm.Config) (*StateResult, error) {
+	config, ok := Forks[subtest.Fork]
+	if !ok {
+		return nil, UnsupportedForkError{subtest.Fork}
+	}
+	db := memdb.New()
+	defer db.Close()
+	tx, err := db.BeginRw(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	number, time := uint64(t.json.Env.Number), uint64(t.json.Env.Timestamp)
+	rules := config.Rules(number, time)
+	reader, writer := state.NewPlainStateReader(tx), state.NewPlainStateWriter(tx, tx, number)
+	if err := t.makePreState(rules, writer, reader); err != nil {
+		return nil, err
+	}
+
+	post := t.json.Post[subtest.Fork][subtest.Index]
+	var baseFee *uint256.Int
+	if config.IsLondon(number) {
+		baseFee = uint256.NewInt(params.InitialBaseFee)
+		if t.json.Env.BaseFee != nil {
+			baseFee, _ = uint256.FromBig((*big.Int)(t.json.Env.BaseFee))
+		}
+	}
+	msg, err := t.json.Tx.toMessage(post, baseFee)
+	if err != nil {
+		return nil, err
+	}
+
+	blockCtx := evmtypes.BlockContext{
+		CanTransfer: internal.CanTransfer,
+		Transfer:    internal.Transfer,
+		GetHash:     vmTestBlockHash,
+		Coinbase:    t.json.Env.Coinbase,
+		GasLimit:    uint64(t.json.Env.GasLimit),
+		BlockNumber: number,
+		Time:        time,
+		Difficulty:  new(big.Int),
+		BaseFee:     baseFee,
+	}
+	if t.json.Env.Difficulty != nil {
+		blockCtx.Difficulty = (*big.Int)(t.json.Env.Difficulty)
+	}
+	if mergeForks[subtest.Fork] && t.json.Env.Random != nil {
+		random := types.BigToHash((*big.Int)(t.json.Env.Random))
+		blockCtx.PrevRanDao = &random
+	}
+
+	statedb := state.New(reader)
+	evm := vm.NewEVM(blockCtx, internal.NewEVMTxContext(msg), statedb, config, vmconfig)
+	gp := new(common.GasPool).AddGas(blockCtx.GasLimit)
+	snapshot := statedb.Snapshot()
+	res, err := internal.ApplyMessage(evm, msg, gp, true /* refunds */, false /* gasBailout */)
+	if err != nil {
+		statedb.RevertToSnapshot(snapshot)
+	}
+	// Touch the coinbase, as a block reward would.
+	statedb.AddBalance(blockCtx.Coinbase, new(uint256.Int))
+	if ferr := statedb.FinalizeTx(rules, writer); ferr != nil {
+		return nil, ferr
+	}
+
+	result := &StateResult{Root: statedb.GenerateRootHash()}
+	if res != nil {
+		result.GasUsed, result.Output, result.Err = res.UsedGas, res.ReturnData, res.Err
+	}
+	return result, err
+}
+
+// makePreState writes the pre accounts of the test to the database.
+func (t *StateTest) makePreState(rules *params.Rules, writer state.StateWriter, reader state.StateReader) error {
+	statedb := state.New(reader)
+	for addr, a := range t.json.Pre {
+		balance := new(uint256.Int)
+		if a.Balance != nil {
+			balance, _ = uint256.FromBig((*big.Int)(a.Balance))
+		}
+		statedb.AddBalance(addr, balance)
+		statedb.SetCode(addr, a.Code)
+		statedb.SetNonce(addr, uint64(a.Nonce))
+		for k, v := range a.Storage {
+			slot, ok := math.ParseBig256(k)
+			if !ok {
+				return fmt.Errorf("invalid storage slot %q of %x", k, addr)
+			}
+			key := types.BigToHash(slot)
+			value, _ := uint256.FromBig((*big.Int)(v))
+			statedb.SetState(addr, &key, *value)
+		}
+		if len(a.Code) > 0 || len(a.Storage) > 0 {
+			statedb.SetIncarnation(addr, state.FirstContractIncarnation)
+		}
+	}
+	return statedb.FinalizeTx(rules, writer)
+}
+
+func (tx *stTransaction) toMessage(ps stPostState, baseFee *uint256.Int) (transaction.Message, error) {
+	// Derive sender from private key if present.
+	var from types.Address
+	if len(tx.PrivateKey) > 0 {
+		key, err := crypto.ToECDSA(tx.PrivateKey)
+		if err != nil {
+			return transaction.Message{}, fmt.Errorf("invalid private key: %v", err)
+		}
+		from = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	// Parse recipient if present.
+	var to *types.Address
+	if tx.To != "" {
+		addr, err := types.HexToString(tx.To)
+		if err != nil {
+			return transaction.Message{}, fmt.Errorf("invalid to address: %v", err)
+		}
+		to = &addr
+	}
+
+	// Get values specific to this post state.
+	if ps.Indexes.Data >= len(tx.Data) {
+		return transaction.Message{}, fmt.Errorf("tx data index %d out of bounds", ps.Indexes.Data)
+	}
+	if ps.Indexes.Value >= len(tx.Value) {
+		return transaction.Message{}, fmt.Errorf("tx value index %d out of bounds", ps.Indexes.Value)
+	}
+	if ps.Indexes.Gas >= len(tx.GasLimit) {
+		return transaction.Message{}, fmt.Errorf("tx gas limit index %d out of bounds", ps.Indexes.Gas)
+	}
+	dataHex := tx.Data[ps.Indexes.Data]
+	valueHex := tx.Value[ps.Indexes.Value]
+	gasLimit := tx.GasLimit[ps.Indexes.Gas]
+	// Value, Data hex encoding is messy: https://github.com/ethereum/tests/issues/203
+	value := new(uint256.Int)
+	if valueHex != "0x" {
+		v, ok := math.ParseBig256(valueHex)
+		if !ok {
+			return transaction.Message{}, fmt.Errorf("invalid tx value %q", valueHex)
+		}
+		value, _ = uint256.FromBig(v)
+	}
+	data, err := hexutil.Decode(dataHex)
+	if err != nil {
+		return transaction.Message{}, fmt.Errorf("invalid tx data %q", dataHex)
+	}
+	var accessList transaction.AccessList
+	if tx.AccessLists != nil && tx.AccessLists[ps.Indexes.Data] != nil {
+		accessList = *tx.AccessLists[ps.Indexes.Data]
+	}
+	// If baseFee provided, set gasPrice to effectiveGasPrice.
+	gasPrice, feeCap, tip := toUint256(tx.GasPrice), toUint256(tx.MaxFeePerGas), toUint256(tx.MaxPriorityFeePerGas)
+	if baseFee != nil {
+		if feeCap == nil {
+			feeCap = gasPrice
+		}
+		if feeCap == nil {
+			return transaction.Message{}, fmt.Errorf("no gas price provided")
+		}
+		if tip == nil {
+			tip = feeCap
+		}
+		gasPrice = new(uint256.Int).Add(tip, baseFee)
+		if gasPrice.Gt(feeCap) {
+			gasPrice = feeCap
+		}
+	}
+	if gasPrice == nil {
+		return transaction.Message{}, fmt.Errorf("no gas price provided")
+	}
+
+	msg := transaction.NewMessage(from, to, uint64(tx.Nonce), value, uint64(gasLimit), gasPrice, feeCap, tip, data, accessList, true /* checkNonce */, false /* isFree */)
+	return msg, nil
+}
+
+func toUint256(x *math.HexOrDecimal256) *uint256.Int {
+	if x == nil {
+		return nil
+	}
+	v, _ := uint256.FromBig((*big.Int)(x))
+	return v
+}
+
+func vmTestBlockHash(n uint64) types.Hash {
+	return types.BytesToHash(crypto.Keccak256([]byte(big.NewInt(int64(n)).String())))
+}
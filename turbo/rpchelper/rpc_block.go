@@ -18,6 +18,7 @@ package rpchelper
 
 import (
 	"fmt"
+	"github.com/amazechain/amc/common/types"
 	"github.com/amazechain/amc/modules/rawdb"
 	"github.com/holiman/uint256"
 	"github.com/ledgerwatch/erigon-lib/kv"
@@ -32,11 +33,22 @@ func GetLatestBlockNumber(tx kv.Tx) (*uint256.Int, error) {
 }
 
 func GetFinalizedBlockNumber(tx kv.Tx) (*uint256.Int, error) {
-	//todo
-	return GetLatestBlockNumber(tx)
+	return checkpointNumber(tx, rawdb.ReadFinalizedBlockHash(tx), "finalized")
 }
 
 func GetSafeBlockNumber(tx kv.Tx) (*uint256.Int, error) {
-	//todo
-	return GetLatestBlockNumber(tx)
+	return checkpointNumber(tx, rawdb.ReadSafeBlockHash(tx), "safe")
+}
+
+// checkpointNumber resolves a block tracked by the consensus engine, failing
+// if no block has reached that stage yet.
+func checkpointNumber(tx kv.Tx, hash types.Hash, tag string) (*uint256.Int, error) {
+	if hash == (types.Hash{}) {
+		return nil, fmt.Errorf("%s block not found", tag)
+	}
+	number := rawdb.ReadHeaderNumber(tx, hash)
+	if number == nil {
+		return nil, fmt.Errorf("%s block %x not found", tag, hash)
+	}
+	return uint256.NewInt(*number), nil
 }